@@ -1,14 +1,52 @@
 package core
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/sha3"
+	"encoding/base32"
 	"errors"
 	"github.com/yawning/bulb/utils/pkcs1"
 	"strings"
 )
 
-// Conversion functions between ricochet addresses, onion hostnames, and 80-bit base32 encoded fingerprints.
-// As used in this file, these are referred to as 'address', 'onion', and 'plain host' respectively.
+// Conversion functions between ricochet addresses, onion hostnames, and base32 encoded
+// onion service identifiers. As used in this file, these are referred to as 'address',
+// 'onion', and 'plain host' respectively.
+//
+// Both v2 (16 character, RSA) and v3 (56 character, ed25519) plain hosts are valid.
+
+const (
+	v2PlainHostLength = 16
+	v3PlainHostLength = 56
+	// Version byte at the end of a decoded v3 onion service identifier
+	v3OnionVersion = 3
+	// Prefix of the data hashed for the checksum in a v3 onion service identifier
+	v3ChecksumPrefix = ".onion checksum"
+)
+
+// InvalidAddressError is returned by NormalizeAddress for input that isn't a
+// ricochet address, onion hostname, or plain host in any accepted form
+var InvalidAddressError error = errors.New("Invalid ricochet address")
+
+// NormalizeAddress returns the canonical form of an address entered by the
+// user, which is 'ricochet:' followed by the lowercase plain host. Surrounding
+// whitespace, a 'ricochet:' prefix, and a '.onion' suffix are removed, and
+// case is ignored, so an address copied from anywhere it's commonly shown is
+// accepted. InvalidAddressError is returned if the rest isn't a valid plain
+// host.
+func NormalizeAddress(input string) (string, error) {
+	host := strings.ToLower(strings.TrimSpace(input))
+	host = strings.TrimPrefix(host, "ricochet:")
+	host = strings.TrimSuffix(host, ".onion")
+	address, ok := AddressFromPlainHost(host)
+	if !ok {
+		return "", InvalidAddressError
+	}
+	return address, nil
+}
 
 func isBase32Valid(str string) bool {
 	for _, c := range []byte(str) {
@@ -20,22 +58,59 @@ func isBase32Valid(str string) bool {
 }
 
 func IsAddressValid(addr string) bool {
-	return len(addr) == 25 && strings.HasPrefix(addr, "ricochet:") && isBase32Valid(addr[9:])
+	return strings.HasPrefix(addr, "ricochet:") && IsPlainHostValid(addr[9:])
 }
 
 func IsOnionValid(onion string) bool {
-	return len(onion) == 22 && strings.HasSuffix(onion, ".onion") && isBase32Valid(onion[0:16])
+	return strings.HasSuffix(onion, ".onion") && IsPlainHostValid(strings.TrimSuffix(onion, ".onion"))
 }
 
 func IsPlainHostValid(host string) bool {
-	return len(host) == 16 && isBase32Valid(host)
+	switch len(host) {
+	case v2PlainHostLength:
+		return isBase32Valid(host)
+	case v3PlainHostLength:
+		if !isBase32Valid(host) {
+			return false
+		}
+		// Public key (32 bytes), checksum (2 bytes), version (1 byte)
+		data, err := base32.StdEncoding.DecodeString(strings.ToUpper(host))
+		if err != nil || len(data) != 35 || data[34] != v3OnionVersion {
+			return false
+		}
+		checksum := v3Checksum(data[:ed25519.PublicKeySize])
+		return bytes.Equal(data[32:34], checksum[:])
+	default:
+		return false
+	}
+}
+
+// v3Checksum returns the checksum of a v3 onion service identifier with
+// publicKey, which is the first two bytes of
+// SHA3-256(".onion checksum" || publicKey || version)
+func v3Checksum(publicKey []byte) [2]byte {
+	data := append([]byte(v3ChecksumPrefix), publicKey...)
+	hash := sha3.Sum256(append(data, v3OnionVersion))
+	return [2]byte{hash[0], hash[1]}
+}
+
+// v3PlainHost returns the plain host of the v3 onion service with publicKey
+func v3PlainHost(publicKey ed25519.PublicKey) string {
+	checksum := v3Checksum(publicKey)
+	data := append(append([]byte{}, publicKey...), checksum[0], checksum[1], v3OnionVersion)
+	return strings.ToLower(base32.StdEncoding.EncodeToString(data))
+}
+
+// IsAddressV3 returns true if addr is a valid address of a v3 onion service
+func IsAddressV3(addr string) bool {
+	return IsAddressValid(addr) && len(addr[9:]) == v3PlainHostLength
 }
 
 func AddressFromOnion(onion string) (string, bool) {
 	if !IsOnionValid(onion) {
 		return "", false
 	}
-	return "ricochet:" + onion[0:16], true
+	return "ricochet:" + strings.TrimSuffix(onion, ".onion"), true
 }
 
 func OnionFromAddress(addr string) (string, bool) {
@@ -56,7 +131,7 @@ func PlainHostFromOnion(onion string) (string, bool) {
 	if !IsOnionValid(onion) {
 		return "", false
 	}
-	return onion[0:16], true
+	return strings.TrimSuffix(onion, ".onion"), true
 }
 
 func AddressFromPlainHost(host string) (string, bool) {
@@ -73,12 +148,24 @@ func PlainHostFromAddress(host string) (string, bool) {
 	return host[9:], true
 }
 
-func AddressFromKey(key *rsa.PublicKey) (string, error) {
-	addr, err := pkcs1.OnionAddr(key)
-	if err != nil {
-		return "", err
-	} else if addr == "" {
-		return "", errors.New("Invalid key")
+// AddressFromKey returns the address of the onion service with the public key,
+// which is an *rsa.PublicKey for v2 or an ed25519.PublicKey for v3.
+func AddressFromKey(key crypto.PublicKey) (string, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		addr, err := pkcs1.OnionAddr(key)
+		if err != nil {
+			return "", err
+		} else if addr == "" {
+			return "", errors.New("Invalid key")
+		}
+		return "ricochet:" + addr, nil
+	case ed25519.PublicKey:
+		if len(key) != ed25519.PublicKeySize {
+			return "", errors.New("Invalid key")
+		}
+		return "ricochet:" + v3PlainHost(key), nil
+	default:
+		return "", errors.New("Unsupported key type")
 	}
-	return "ricochet:" + addr, nil
 }
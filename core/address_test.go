@@ -0,0 +1,143 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Public key from the first test vector of RFC 8032, and its v3 plain host
+const (
+	testV3PublicKey = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	testV3PlainHost = "25njqamcweflpvkl73j4szahhihoc4xt3ktcgjnpaingr5yhkenl5sid"
+)
+
+// v3HostWith returns testV3PlainHost with its decoded data changed by change
+func v3HostWith(t *testing.T, change func(data []byte)) string {
+	data, err := base32.StdEncoding.DecodeString(strings.ToUpper(testV3PlainHost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(data)
+	return strings.ToLower(base32.StdEncoding.EncodeToString(data))
+}
+
+func TestIsPlainHostValid(t *testing.T) {
+	tests := []struct {
+		name  string
+		host  string
+		valid bool
+	}{
+		{"v2", "aaaaaaaaaaaaaaaa", true},
+		{"v2 invalid character", "aaaaaaaaaaaaaaa1", false},
+		{"v3", testV3PlainHost, true},
+		{"v3 uppercase", strings.ToUpper(testV3PlainHost), false},
+		{"v3 bad checksum", v3HostWith(t, func(data []byte) { data[32] ^= 1 }), false},
+		{"v3 bad version", v3HostWith(t, func(data []byte) { data[34] = 2 }), false},
+		{"v3 different key", v3HostWith(t, func(data []byte) { data[0] ^= 1 }), false},
+		{"v3 truncated", testV3PlainHost[:55], false},
+		{"empty", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := IsPlainHostValid(test.host); valid != test.valid {
+				t.Errorf("%q is valid is %v, expected %v", test.host, valid, test.valid)
+			}
+			if valid := IsAddressValid("ricochet:" + test.host); valid != test.valid {
+				t.Errorf("address for %q is valid is %v, expected %v", test.host, valid, test.valid)
+			}
+		})
+	}
+}
+
+func TestAddressFromKey(t *testing.T) {
+	publicKey, _ := hex.DecodeString(testV3PublicKey)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		address string
+		v3      bool
+		valid   bool
+	}{
+		{"v3", ed25519.PublicKey(publicKey), "ricochet:" + testV3PlainHost, true, true},
+		{"v2", &rsaKey.PublicKey, "", false, true},
+		{"short ed25519", ed25519.PublicKey(publicKey[:31]), "", false, false},
+		{"unsupported", rsaKey, "", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address, err := AddressFromKey(test.key)
+			if (err == nil) != test.valid {
+				t.Fatalf("error is %v, expected valid %v", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+			if test.address != "" && address != test.address {
+				t.Errorf("address is %s, expected %s", address, test.address)
+			}
+			if !IsAddressValid(address) {
+				t.Errorf("address %s is not valid", address)
+			}
+			if IsAddressV3(address) != test.v3 {
+				t.Errorf("address %s is v3 is %v, expected %v", address, !test.v3, test.v3)
+			}
+		})
+	}
+}
+
+// Addresses entered by the user are accepted in any of the forms they're
+// commonly shown in, and normalized to the canonical address
+func TestNormalizeAddress(t *testing.T) {
+	const v2 = "ricochet:bbbbbbbbbbbbbbbb"
+	tests := []struct {
+		name    string
+		input   string
+		address string
+	}{
+		{"canonical", v2, v2},
+		{"plain host", "bbbbbbbbbbbbbbbb", v2},
+		{"onion", "bbbbbbbbbbbbbbbb.onion", v2},
+		{"prefix and suffix", "ricochet:bbbbbbbbbbbbbbbb.onion", v2},
+		{"uppercase", "RICOCHET:BBBBBBBBBBBBBBBB", v2},
+		{"mixed case onion", "BbbbbbbbbbbbbbbB.Onion", v2},
+		{"whitespace", " \tricochet:bbbbbbbbbbbbbbbb\n", v2},
+		{"v3", testV3PlainHost + ".onion", "ricochet:" + testV3PlainHost},
+		{"v3 uppercase", "ricochet:" + strings.ToUpper(testV3PlainHost), "ricochet:" + testV3PlainHost},
+		{"empty", "", ""},
+		{"whitespace only", "  ", ""},
+		{"prefix only", "ricochet:", ""},
+		{"invalid character", "ricochet:bbbbbbbbbbbbbbb1", ""},
+		{"too short", "bbbbbbbbbbbbbbb.onion", ""},
+		{"inner whitespace", "bbbbbbbb bbbbbbbb", ""},
+		{"doubled prefix", "ricochet:ricochet:bbbbbbbbbbbbbbbb", ""},
+		{"other scheme", "http://bbbbbbbbbbbbbbbb.onion", ""},
+		{"port", "bbbbbbbbbbbbbbbb.onion:9878", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address, err := NormalizeAddress(test.input)
+			if test.address == "" {
+				if err != InvalidAddressError {
+					t.Errorf("%q normalized to %q with error %v, expected %v", test.input, address, err, InvalidAddressError)
+				}
+				return
+			}
+			if err != nil || address != test.address {
+				t.Errorf("%q normalized to %q with error %v, expected %q", test.input, address, err, test.address)
+			}
+		})
+	}
+}
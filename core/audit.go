@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of recent audit entries kept in memory for GetAuditLog
+const auditLogRecentEntries = 500
+
+// AuditLog records significant events for contacts and connections, such as
+// contact requests and authentication failures. Unlike the debug log, entries
+// are always recorded, and are appended to a file if one is configured, with
+// one line per entry:
+//
+//	2006-01-02T15:04:05Z07:00 connection-established <address> "inbound"
+//
+// The file can be rotated by renaming or removing it; a new file is created
+// for the next entry.
+type AuditLog struct {
+	path string
+
+	mutex  sync.Mutex
+	file   *os.File
+	recent []*ricochet.AuditEntry
+}
+
+// OpenAuditLog creates an audit log that appends to the file at path, creating
+// it if necessary. If path is empty, entries are only kept in memory.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	al := &AuditLog{path: path}
+	if path != "" {
+		if err := al.openFile(); err != nil {
+			return nil, err
+		}
+	}
+	return al, nil
+}
+
+// Assumes mutex is held
+func (al *AuditLog) openFile() error {
+	file, err := os.OpenFile(al.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if al.file != nil {
+		al.file.Close()
+	}
+	al.file = file
+	return nil
+}
+
+// isRotated returns true if the file at path is no longer the open file, after
+// it was moved away or removed. Assumes mutex is held.
+func (al *AuditLog) isRotated() bool {
+	pathInfo, err := os.Stat(al.path)
+	if err != nil {
+		return true
+	}
+	fileInfo, err := al.file.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(pathInfo, fileInfo)
+}
+
+// Record adds an entry to the audit log. Failures to write the file are
+// logged, but don't affect the event being recorded.
+func (al *AuditLog) Record(entryType ricochet.AuditEntry_Type, address, detail string) {
+	entry := &ricochet.AuditEntry{
+		Time:    time.Now().Format(time.RFC3339),
+		Type:    entryType,
+		Address: address,
+		Detail:  detail,
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	al.recent = append(al.recent, entry)
+	if len(al.recent) > auditLogRecentEntries {
+		al.recent = append([]*ricochet.AuditEntry(nil), al.recent[len(al.recent)-auditLogRecentEntries:]...)
+	}
+
+	if al.path == "" {
+		return
+	}
+	if al.file == nil || al.isRotated() {
+		if err := al.openFile(); err != nil {
+			log.Printf("Opening audit log failed: %v", err)
+			return
+		}
+	}
+	typeName := strings.Replace(strings.ToLower(entryType.String()), "_", "-", -1)
+	if address == "" {
+		address = "-"
+	}
+	line := fmt.Sprintf("%s %s %s %q\n", entry.Time, typeName, address, detail)
+	if _, err := al.file.WriteString(line); err != nil {
+		log.Printf("Writing audit log failed: %v", err)
+	}
+}
+
+// Recent returns up to limit of the most recent entries, oldest first, or all
+// entries kept in memory if limit is zero
+func (al *AuditLog) Recent(limit int) []*ricochet.AuditEntry {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	entries := al.recent
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return append([]*ricochet.AuditEntry(nil), entries...)
+}
+
+// Close closes the audit log file. Entries recorded later reopen it.
+func (al *AuditLog) Close() error {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
@@ -0,0 +1,591 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
+	"github.com/s-rah/go-ricochet/policies"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/auth"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"io"
+	"log"
+	"sync"
+)
+
+// Channel type of the protocol library's authentication, which proves that the
+// client owns the RSA key of a v2 onion service. Channels for contacts require
+// this authentication.
+const hiddenServiceAuthChannelType = "im.ricochet.auth.hidden-service"
+
+// Channel type for authentication when either peer has a v3 onion service. The
+// protocol library's authentication needs the server's RSA key to build the
+// challenge, so it's only used between v2 peers.
+//
+// The client opens the channel and sends a random cookie, and the server
+// replies with its own. The client proves that it owns the key of its onion
+// service by signing
+//
+//	HMAC-SHA256(clientCookie || serverCookie, type || clientHostname || serverHostname)
+//
+// and the server replies with the result. Tor authenticates the server, by
+// only connecting to the service with the key of its hostname. Each packet
+// begins with one of the packet types below.
+const onionAuthChannelType = "im.ricochet.auth.onion"
+
+const (
+	// Client: cookie (16)
+	authPacketClientCookie byte = iota + 1
+	// Server: cookie (16)
+	authPacketServerCookie
+	// Client: key type (1), public key size (2), public key, signature
+	authPacketProof
+	// Server: accepted (1), known contact (1)
+	authPacketResult
+)
+
+// Key types in a proof
+const (
+	authKeyRSA byte = iota + 1
+	authKeyEd25519
+)
+
+// Bounds on RSA keys accepted in a proof, checked before anything is parsed
+// or verified so that a peer can't make us hash or verify arbitrarily large
+// values. Onion service keys are 1024 bits, and their DER encoding is about
+// 140 bytes; larger keys are allowed for future use.
+const (
+	minProofKeyBits    = 1024
+	maxProofKeyBits    = 4096
+	maxProofKeyDERSize = 1024
+)
+
+var (
+	InvalidProofError              error = errors.New("Invalid authentication proof")
+	AuthenticationUnsupportedError error = errors.New("Peer does not support authentication for v3 onion services")
+)
+
+// authenticateOutbound proves our identity with key on an outbound connection
+// to conn.RemoteHostname, and returns whether the peer knows us as a contact.
+// Like the protocol library's ProcessAuthAsClient, which is used between v2
+// peers, it can't be called at the same time as another Process function.
+func authenticateOutbound(conn *connection.Connection, key crypto.PrivateKey) (bool, error) {
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok && len(conn.RemoteHostname) == v2PlainHostLength {
+		return connection.HandleOutboundConnection(conn).ProcessAuthAsClient(rsaKey)
+	}
+
+	handler := new(connection.AutoConnectionHandler)
+	handler.Init()
+
+	var breakOnce sync.Once
+	var accepted, known bool
+	var authErr error
+	result := func(a, k bool, err error) {
+		accepted, known, authErr = a, k, err
+		// This runs in the Process goroutine, so Break must not block it
+		breakOnce.Do(func() { go conn.Break() })
+	}
+
+	processResult := make(chan error, 1)
+	go func() {
+		defer breakOnce.Do(func() { conn.Break() })
+		policy := policies.UnknownPurposeTimeout
+		processResult <- policy.ExecuteAction(func() error {
+			return conn.Process(handler)
+		})
+	}()
+
+	err := conn.Do(func() error {
+		_, err := conn.RequestOpenChannel(onionAuthChannelType, &onionAuthChannel{
+			conn:           conn,
+			privateKey:     key,
+			serverHostname: conn.RemoteHostname,
+			clientResult:   result,
+		})
+		return err
+	})
+	if err != nil {
+		breakOnce.Do(func() { conn.Break() })
+		return false, err
+	}
+
+	if err := <-processResult; err != nil {
+		return false, err
+	}
+	if authErr != nil {
+		return false, authErr
+	} else if !accepted {
+		return false, ricochetutils.ServerRejectedClientConnectionError
+	}
+	return known, nil
+}
+
+// authenticateInbound waits for the client of an inbound connection to our
+// onion service with key to authenticate, and sets conn.RemoteHostname to
+// the client's authenticated plain host. accept is called with the hostname
+// and public key of a valid proof, and returns whether the connection is
+// allowed and whether the client is a known contact. A non-nil error is
+// returned unless the client authenticated and was allowed.
+func authenticateInbound(conn *connection.Connection, key crypto.PrivateKey, accept func(hostname string, publicKey crypto.PublicKey) (allowed, known bool)) error {
+	hostname, ok := PlainHostFromAddress(mustAddressFromPrivateKey(key))
+	if !ok {
+		return UnsupportedKeyError
+	}
+
+	var breakOnce sync.Once
+	var allowed, finished bool
+	var authHostname string
+	onValid := func(clientHostname string, publicKey crypto.PublicKey) (bool, bool) {
+		var known bool
+		allowed, known = accept(clientHostname, publicKey)
+		if allowed {
+			authHostname = clientHostname
+		}
+		finished = true
+		return allowed, known
+	}
+	onInvalid := func(err error) {
+		log.Printf("Inbound authentication proof is invalid: %v", err)
+		finished = true
+	}
+	// Called by the channel after it has handled an event, which has sent the
+	// result if there is one. Process is only stopped then, because the
+	// connection is closed when it stops and the client must see a rejection
+	// to tell it apart from a lost connection. This runs in the Process
+	// goroutine, so Break must not block it.
+	onDone := func() {
+		if finished {
+			breakOnce.Do(func() { go conn.Break() })
+		}
+	}
+
+	handler := new(connection.AutoConnectionHandler)
+	handler.Init()
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		handler.RegisterChannelHandler(hiddenServiceAuthChannelType, func() channels.Handler {
+			return &hiddenServiceAuthChannel{HiddenServiceAuthChannel: &channels.HiddenServiceAuthChannel{
+				PrivateKey: rsaKey,
+				ServerAuthValid: func(hostname string, publicKey rsa.PublicKey) (bool, bool) {
+					return onValid(hostname, &publicKey)
+				},
+				ServerAuthInvalid: onInvalid,
+			}, done: onDone}
+		})
+	}
+	handler.RegisterChannelHandler(onionAuthChannelType, func() channels.Handler {
+		return &onionAuthChannel{
+			conn:          conn,
+			hostname:      hostname,
+			serverValid:   onValid,
+			serverInvalid: onInvalid,
+			serverDone:    onDone,
+		}
+	})
+
+	// The Process call can't outlive this function, particularly when the
+	// timeout expires
+	defer breakOnce.Do(func() { conn.Break() })
+	policy := policies.UnknownPurposeTimeout
+	err := policy.ExecuteAction(func() error {
+		return conn.Process(handler)
+	})
+	if err != nil {
+		return err
+	} else if !allowed {
+		return ricochetutils.ClientFailedToAuthenticateError
+	}
+	conn.RemoteHostname = authHostname
+	return nil
+}
+
+func mustAddressFromPrivateKey(key crypto.PrivateKey) string {
+	address, _ := AddressFromPrivateKey(key)
+	return address
+}
+
+// hiddenServiceAuthChannel is the protocol library's HiddenServiceAuthChannel
+// for the server, which checks the client's key with checkRSAProofKey before
+// the library hashes it into the client's hostname and verifies the proof.
+// done, if set, is called after each packet.
+type hiddenServiceAuthChannel struct {
+	*channels.HiddenServiceAuthChannel
+	channel *channels.Channel
+	done    func()
+}
+
+func (ah *hiddenServiceAuthChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	ah.channel = channel
+	return ah.HiddenServiceAuthChannel.OpenInbound(channel, raw)
+}
+
+func (ah *hiddenServiceAuthChannel) Packet(data []byte) {
+	if ah.done != nil {
+		defer ah.done()
+	}
+	res := new(Protocol_Data_AuthHiddenService.Packet)
+	if err := proto.Unmarshal(data, res); err == nil && res.GetProof() != nil {
+		proof := res.GetProof()
+		if _, err := checkRSAProofKey(proof.GetPublicKey(), proof.GetSignature()); err != nil {
+			ah.ServerAuthInvalid(err)
+			ah.channel.CloseChannel()
+			return
+		}
+	}
+	ah.HiddenServiceAuthChannel.Packet(data)
+}
+
+// onionAuthChannel implements channels.Handler for onionAuthChannelType, as
+// the client when privateKey is set, or otherwise as the server
+type onionAuthChannel struct {
+	conn    *connection.Connection
+	channel *channels.Channel
+
+	// Client
+	privateKey     crypto.PrivateKey
+	serverHostname string
+	clientResult   func(accepted, known bool, err error)
+
+	// Server; hostname is our own. serverDone is called after each event.
+	hostname      string
+	serverValid   func(hostname string, publicKey crypto.PublicKey) (allowed, known bool)
+	serverInvalid func(err error)
+	serverDone    func()
+
+	clientCookie, serverCookie []byte
+	finished                   bool
+}
+
+func (ac *onionAuthChannel) Type() string {
+	return onionAuthChannelType
+}
+
+func (ac *onionAuthChannel) OnlyClientCanOpen() bool {
+	return true
+}
+
+func (ac *onionAuthChannel) Singleton() bool {
+	return true
+}
+
+func (ac *onionAuthChannel) Bidirectional() bool {
+	return true
+}
+
+func (ac *onionAuthChannel) RequiresAuthentication() string {
+	return "none"
+}
+
+func (ac *onionAuthChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	ac.channel = channel
+	channel.Pending = false
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.AckOpenChannel(channel.ID), nil
+}
+
+func (ac *onionAuthChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	ac.channel = channel
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.OpenChannel(channel.ID, ac.Type()), nil
+}
+
+func (ac *onionAuthChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	if err != nil || !crm.GetOpened() {
+		ac.fail(AuthenticationUnsupportedError)
+		return
+	}
+	ac.channel.Pending = false
+
+	cookie, err := newAuthCookie()
+	if err != nil {
+		ac.fail(err)
+		ac.channel.CloseChannel()
+		return
+	}
+	ac.clientCookie = cookie
+	ac.channel.SendMessage(append([]byte{authPacketClientCookie}, cookie...))
+}
+
+func (ac *onionAuthChannel) Closed(err error) {
+	if err == nil {
+		err = ricochetutils.ChannelClosedByPeerError
+	}
+	ac.fail(err)
+	if ac.serverDone != nil {
+		ac.serverDone()
+	}
+}
+
+func (ac *onionAuthChannel) Packet(data []byte) {
+	if ac.finished || len(data) < 1 {
+		return
+	}
+	if ac.serverDone != nil {
+		defer ac.serverDone()
+	}
+
+	if ac.channel.Direction == channels.Inbound {
+		ac.serverPacket(data)
+	} else {
+		ac.clientPacket(data)
+	}
+}
+
+func (ac *onionAuthChannel) serverPacket(data []byte) {
+	switch {
+	case data[0] == authPacketClientCookie && ac.clientCookie == nil:
+		if len(data) != 17 {
+			ac.reject(InvalidProofError)
+			return
+		}
+		cookie, err := newAuthCookie()
+		if err != nil {
+			ac.reject(err)
+			return
+		}
+		ac.clientCookie = data[1:]
+		ac.serverCookie = cookie
+		ac.channel.SendMessage(append([]byte{authPacketServerCookie}, cookie...))
+
+	case data[0] == authPacketProof && ac.serverCookie != nil:
+		publicKey, clientHostname, err := verifyAuthProof(data[1:], ac.challenge)
+		if err != nil {
+			ac.reject(err)
+			return
+		}
+		ac.finished = true
+		allowed, known := ac.serverValid(clientHostname, publicKey)
+		if allowed {
+			ac.delegateAuthorization()
+		}
+		ac.channel.SendMessage(encodeAuthResult(allowed, known))
+		ac.channel.CloseChannel()
+
+	default:
+		ac.reject(InvalidProofError)
+	}
+}
+
+func (ac *onionAuthChannel) clientPacket(data []byte) {
+	switch {
+	case data[0] == authPacketServerCookie && ac.clientCookie != nil && ac.serverCookie == nil:
+		if len(data) != 17 {
+			ac.fail(InvalidProofError)
+			ac.channel.CloseChannel()
+			return
+		}
+		ac.serverCookie = data[1:]
+		proof, err := signAuthProof(ac.privateKey, ac.challenge)
+		if err != nil {
+			ac.fail(err)
+			ac.channel.CloseChannel()
+			return
+		}
+		ac.channel.SendMessage(proof)
+
+	case data[0] == authPacketResult && ac.serverCookie != nil:
+		if len(data) != 3 {
+			ac.fail(InvalidProofError)
+			ac.channel.CloseChannel()
+			return
+		}
+		accepted := data[1] == 1
+		if accepted {
+			ac.delegateAuthorization()
+		}
+		ac.finished = true
+		ac.clientResult(accepted, data[2] == 1, nil)
+		ac.channel.CloseChannel()
+
+	default:
+		ac.fail(InvalidProofError)
+		ac.channel.CloseChannel()
+	}
+}
+
+// Authentication with this channel also satisfies channels that require the
+// protocol library's authentication, which proves the same thing
+func (ac *onionAuthChannel) delegateAuthorization() {
+	ac.channel.DelegateAuthorization()
+	ac.conn.Authentication[hiddenServiceAuthChannelType] = true
+}
+
+// challenge returns the value that the client signs, for clientHostname
+func (ac *onionAuthChannel) challenge(clientHostname string) []byte {
+	serverHostname := ac.hostname
+	if ac.channel.Direction == channels.Outbound {
+		serverHostname = ac.serverHostname
+	}
+	key := append(append([]byte{}, ac.clientCookie...), ac.serverCookie...)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(onionAuthChannelType + clientHostname + serverHostname))
+	return mac.Sum(nil)
+}
+
+// Reject the client's authentication on the server
+func (ac *onionAuthChannel) reject(err error) {
+	if ac.finished {
+		return
+	}
+	ac.finished = true
+	ac.channel.SendMessage(encodeAuthResult(false, false))
+	ac.channel.CloseChannel()
+	ac.serverInvalid(err)
+}
+
+// End the authentication without a result, unless it has already finished
+func (ac *onionAuthChannel) fail(err error) {
+	if ac.finished {
+		return
+	}
+	ac.finished = true
+	if ac.clientResult != nil {
+		ac.clientResult(false, false, err)
+	} else if ac.serverInvalid != nil {
+		ac.serverInvalid(err)
+	}
+}
+
+func newAuthCookie() ([]byte, error) {
+	cookie := make([]byte, 16)
+	if _, err := io.ReadFull(cryptorand.Reader, cookie); err != nil {
+		return nil, err
+	}
+	return cookie, nil
+}
+
+func encodeAuthResult(accepted, known bool) []byte {
+	data := []byte{authPacketResult, 0, 0}
+	if accepted {
+		data[1] = 1
+	}
+	if known {
+		data[2] = 1
+	}
+	return data
+}
+
+// signAuthProof returns the proof packet for key, signing the challenge for
+// the hostname of key
+func signAuthProof(key crypto.PrivateKey, challenge func(clientHostname string) []byte) ([]byte, error) {
+	clientHostname, ok := PlainHostFromAddress(mustAddressFromPrivateKey(key))
+	if !ok {
+		return nil, UnsupportedKeyError
+	}
+
+	var keyType byte
+	var publicKey, signature []byte
+	var err error
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		keyType = authKeyRSA
+		if publicKey, err = asn1.Marshal(key.PublicKey); err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(challenge(clientHostname))
+		if signature, err = rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:]); err != nil {
+			return nil, err
+		}
+	case ed25519.PrivateKey:
+		keyType = authKeyEd25519
+		publicKey = key.Public().(ed25519.PublicKey)
+		signature = ed25519.Sign(key, challenge(clientHostname))
+	default:
+		return nil, UnsupportedKeyError
+	}
+
+	data := make([]byte, 4, 4+len(publicKey)+len(signature))
+	data[0] = authPacketProof
+	data[1] = keyType
+	binary.BigEndian.PutUint16(data[2:], uint16(len(publicKey)))
+	data = append(data, publicKey...)
+	return append(data, signature...), nil
+}
+
+// verifyAuthProof checks the proof from a client, and returns its public key
+// and the plain host of its onion service
+func verifyAuthProof(data []byte, challenge func(clientHostname string) []byte) (crypto.PublicKey, string, error) {
+	if len(data) < 3 {
+		return nil, "", InvalidProofError
+	}
+	keyType := data[0]
+	keySize := int(binary.BigEndian.Uint16(data[1:]))
+	if len(data) < 3+keySize {
+		return nil, "", InvalidProofError
+	}
+	keyData, signature := data[3:3+keySize], data[3+keySize:]
+
+	var publicKey crypto.PublicKey
+	switch keyType {
+	case authKeyRSA:
+		key, err := checkRSAProofKey(keyData, signature)
+		if err != nil {
+			return nil, "", err
+		}
+		publicKey = key
+	case authKeyEd25519:
+		if len(keyData) != ed25519.PublicKeySize || len(signature) != ed25519.SignatureSize {
+			return nil, "", InvalidProofError
+		}
+		publicKey = ed25519.PublicKey(keyData)
+	default:
+		return nil, "", InvalidProofError
+	}
+
+	address, err := AddressFromKey(publicKey)
+	if err != nil {
+		return nil, "", InvalidProofError
+	}
+	clientHostname, _ := PlainHostFromAddress(address)
+	message := challenge(clientHostname)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return nil, "", InvalidProofError
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, signature) {
+			return nil, "", InvalidProofError
+		}
+	}
+	return publicKey, clientHostname, nil
+}
+
+// checkRSAProofKey parses the DER encoded RSA public key from a proof, and
+// checks that it and the signature have sensible sizes. The client's hostname
+// is the hash of the key's encoding, so it must be the one canonical encoding;
+// otherwise the same key could claim a hostname that isn't its own.
+func checkRSAProofKey(keyData, signature []byte) (*rsa.PublicKey, error) {
+	if len(keyData) == 0 || len(keyData) > maxProofKeyDERSize {
+		return nil, InvalidProofError
+	}
+	publicKey := &rsa.PublicKey{}
+	rest, err := asn1.Unmarshal(keyData, publicKey)
+	if err != nil || len(rest) != 0 || publicKey.N == nil {
+		return nil, InvalidProofError
+	}
+	if canonical, err := asn1.Marshal(*publicKey); err != nil || !bytes.Equal(canonical, keyData) {
+		return nil, InvalidProofError
+	}
+	if bits := publicKey.N.BitLen(); bits < minProofKeyBits || bits > maxProofKeyBits {
+		return nil, InvalidProofError
+	}
+	if publicKey.E < 3 || publicKey.E&1 == 0 {
+		return nil, InvalidProofError
+	}
+	if len(signature) != (publicKey.N.BitLen()+7)/8 {
+		return nil, InvalidProofError
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Contacts connect to each other with any combination of v2 and v3 services
+func TestAuthenticationVersions(t *testing.T) {
+	newKey := func(t *testing.T, v3 bool) crypto.PrivateKey {
+		if v3 {
+			key, err := newOnionKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return key
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	tests := []struct {
+		name     string
+		clientV3 bool
+		serverV3 bool
+	}{
+		{"v2 to v2", false, false},
+		{"v2 to v3", false, true},
+		{"v3 to v2", true, false},
+		{"v3 to v3", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			client := newTestPeerWithKey(t, network, newKey(t, test.clientV3))
+			server := newTestPeerWithKey(t, network, newKey(t, test.serverV3))
+			clientContact := newTestContact(t, client, server.Identity.Address())
+			serverContact := newTestContact(t, server, client.Identity.Address())
+
+			// The client can't be reached, so the connection is outbound
+			// from the client
+			onion, _ := OnionFromAddress(client.Identity.Address())
+			network.mutex.Lock()
+			delete(network.peers, onion)
+			network.mutex.Unlock()
+
+			go clientContact.StartConnection()
+			go serverContact.StartConnection()
+			deadline := time.Now().Add(10 * time.Second)
+			for clientContact.Status() != ricochet.Contact_ONLINE || serverContact.Status() != ricochet.Contact_ONLINE {
+				if time.Now().After(deadline) {
+					t.Fatalf("contacts are %v and %v, expected both online", clientContact.Status(), serverContact.Status())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if conn := serverContact.Connection(); conn == nil || !conn.IsInbound {
+				t.Error("server's contact doesn't have the inbound connection")
+			}
+		})
+	}
+}
+
+func TestVerifyAuthProof(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Key, err := newOnionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := func(hostname string) []byte {
+		return []byte("challenge for " + hostname)
+	}
+
+	// proof returns a proof packet for key, without the packet type, changed
+	// by change
+	proof := func(t *testing.T, key crypto.PrivateKey, change func(data []byte) []byte) []byte {
+		data, err := signAuthProof(key, challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return change(data[1:])
+	}
+	// withRSAKey replaces the public key in an RSA proof with publicKey
+	withRSAKey := func(publicKey []byte) func(data []byte) []byte {
+		return func(data []byte) []byte {
+			signature := data[3+binary.BigEndian.Uint16(data[1:]):]
+			changed := []byte{authKeyRSA, 0, 0}
+			binary.BigEndian.PutUint16(changed[1:], uint16(len(publicKey)))
+			return append(append(changed, publicKey...), signature...)
+		}
+	}
+	unchanged := func(data []byte) []byte { return data }
+	smallKey, err := asn1.Marshal(rsa.PublicKey{N: new(big.Int).Rsh(rsaKey.N, 600), E: 65537})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evenExponent, err := asn1.Marshal(rsa.PublicKey{N: rsaKey.N, E: 65536})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := asn1.Marshal(rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same key with a long form length, which hashes to another hostname
+	nonCanonical := append([]byte{0x30, 0x81, canonical[1]}, canonical[2:]...)
+
+	tests := []struct {
+		name  string
+		key   crypto.PrivateKey
+		data  func(data []byte) []byte
+		valid bool
+	}{
+		{"rsa", rsaKey, unchanged, true},
+		{"ed25519", ed25519Key, unchanged, true},
+		{"rsa bad signature", rsaKey, func(data []byte) []byte {
+			data[len(data)-1] ^= 1
+			return data
+		}, false},
+		{"ed25519 bad signature", ed25519Key, func(data []byte) []byte {
+			data[len(data)-1] ^= 1
+			return data
+		}, false},
+		{"ed25519 short signature", ed25519Key, func(data []byte) []byte { return data[:len(data)-1] }, false},
+		{"unknown key type", ed25519Key, func(data []byte) []byte {
+			data[0] = 3
+			return data
+		}, false},
+		{"key size past end", ed25519Key, func(data []byte) []byte {
+			binary.BigEndian.PutUint16(data[1:], 0xffff)
+			return data
+		}, false},
+		{"truncated", ed25519Key, func(data []byte) []byte { return data[:2] }, false},
+		{"rsa non-canonical key", rsaKey, withRSAKey(nonCanonical), false},
+		{"rsa small key", rsaKey, withRSAKey(smallKey), false},
+		{"rsa even exponent", rsaKey, withRSAKey(evenExponent), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publicKey, hostname, err := verifyAuthProof(proof(t, test.key, test.data), challenge)
+			if (err == nil) != test.valid {
+				t.Fatalf("error is %v, expected valid %v", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+			address, _ := AddressFromPrivateKey(test.key)
+			if "ricochet:"+hostname != address {
+				t.Errorf("proof is for %s, expected %s", hostname, address)
+			}
+			if keyAddress, _ := AddressFromKey(publicKey); keyAddress != address {
+				t.Errorf("proof has the key for %s, expected %s", keyAddress, address)
+			}
+		})
+	}
+}
+
+// The server of a v2 authentication checks the client's key and signature
+// before the protocol library uses them, so that the client's hostname is the
+// hash of a key that signed the proof
+func TestHiddenServiceAuthProofKey(t *testing.T) {
+	newKey := func() *rsa.PrivateKey {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	serverKey, clientKey, otherKey := newKey(), newKey(), newKey()
+	serverKeyData, _ := asn1.Marshal(serverKey.PublicKey)
+	canonical, _ := asn1.Marshal(clientKey.PublicKey)
+	// The same key with a long form length, which hashes to another hostname
+	nonCanonical := append([]byte{0x30, 0x81, canonical[1]}, canonical[2:]...)
+	smallKey, _ := asn1.Marshal(rsa.PublicKey{N: new(big.Int).Rsh(clientKey.N, 600), E: 65537})
+	oversized := make([]byte, maxProofKeyDERSize+1)
+	copy(oversized, canonical)
+
+	tests := []struct {
+		name   string
+		key    []byte
+		signer *rsa.PrivateKey
+		// Bytes removed from the end of the signature
+		truncate int
+		valid    bool
+	}{
+		{"valid", canonical, clientKey, 0, true},
+		{"signed by another key", canonical, otherKey, 0, false},
+		{"non-canonical key", nonCanonical, clientKey, 0, false},
+		{"truncated key", canonical[:len(canonical)-8], clientKey, 0, false},
+		{"oversized key", oversized, clientKey, 0, false},
+		{"small key", smallKey, clientKey, 0, false},
+		{"empty key", nil, clientKey, 0, false},
+		{"truncated signature", canonical, clientKey, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var validHostname string
+			var invalid error
+			ah := &hiddenServiceAuthChannel{HiddenServiceAuthChannel: &channels.HiddenServiceAuthChannel{
+				PrivateKey: serverKey,
+				ServerAuthValid: func(hostname string, publicKey rsa.PublicKey) (bool, bool) {
+					validHostname = hostname
+					return true, false
+				},
+				ServerAuthInvalid: func(err error) { invalid = err },
+			}}
+			channel := &channels.Channel{
+				ID:                    1,
+				Direction:             channels.Inbound,
+				SendMessage:           func([]byte) {},
+				CloseChannel:          func() {},
+				DelegateAuthorization: func() {},
+			}
+			packet := new(Protocol_Data_Control.Packet)
+			err := proto.Unmarshal(new(ricochetutils.MessageBuilder).OpenAuthenticationChannel(1, [16]byte{1}), packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ah.OpenInbound(channel, packet.GetOpenChannel()); err != nil {
+				t.Fatal(err)
+			}
+
+			clientHostname := ricochetutils.GetTorHostname(test.key)
+			challenge := ah.GenChallenge(clientHostname, ricochetutils.GetTorHostname(serverKeyData))
+			signature, err := rsa.SignPKCS1v15(nil, test.signer, crypto.SHA256, challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ah.Packet(new(ricochetutils.MessageBuilder).Proof(test.key, signature[:len(signature)-test.truncate]))
+
+			if valid := validHostname != ""; valid != test.valid || (invalid == nil) != test.valid {
+				t.Fatalf("proof was accepted for %q and rejected with %v, expected valid %v", validHostname, invalid, test.valid)
+			}
+			if expected, _ := AddressFromKey(&clientKey.PublicKey); test.valid && "ricochet:"+validHostname != expected {
+				t.Errorf("proof was accepted for %s, expected %s", validHostname, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/wire/control"
+)
+
+// SupportsChannel returns whether the contact's client is known to support a
+// channel type, and false for known if it hasn't been seen either way.
+func (c *Contact) SupportsChannel(channelType string) (supported bool, known bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	caps := c.data.Capabilities
+	if caps == nil {
+		return false, false
+	}
+	if containsString(caps.Supported, channelType) {
+		return true, true
+	} else if containsString(caps.Unsupported, channelType) {
+		return false, true
+	}
+	return false, false
+}
+
+// setChannelSupport records whether the contact supports a channel type, as
+// seen on a connection. Clients can be upgraded, so later results replace
+// earlier ones.
+func (c *Contact) setChannelSupport(channelType string, supported bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	caps := c.data.Capabilities
+	if caps == nil {
+		caps = &ricochet.ContactCapabilities{}
+	}
+	if supported && containsString(caps.Supported, channelType) {
+		return
+	} else if !supported && containsString(caps.Unsupported, channelType) {
+		return
+	}
+
+	if supported {
+		caps.Unsupported = removeString(caps.Unsupported, channelType)
+		caps.Supported = append(caps.Supported, channelType)
+	} else {
+		c.core.Log.Infof("Contact %s does not support %s channels", c.data.Address, channelType)
+		caps.Supported = removeString(caps.Supported, channelType)
+		caps.Unsupported = append(caps.Unsupported, channelType)
+	}
+	c.data.Capabilities = caps
+	c.saveDataDeferred()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
+// outboundChannelResult records the contact's support for a channel type from
+// the result of opening it. Only an UnknownTypeError means it isn't supported;
+// other rejections can be temporary.
+func (c *Contact) outboundChannelResult(channelType string, err error, crm *Protocol_Data_Control.ChannelResult) {
+	if err == nil && crm.GetOpened() {
+		c.setChannelSupport(channelType, true)
+	} else if crm.GetCommonError() == Protocol_Data_Control.ChannelResult_UnknownTypeError {
+		c.setChannelSupport(channelType, false)
+	}
+}
+
+// supportedChannelHandler wraps a channel handler factory to record that the
+// contact supports channels it opens to us
+func (c *Contact) supportedChannelHandler(channelType string, factory func() channels.Handler) func() channels.Handler {
+	return func() channels.Handler {
+		c.setChannelSupport(channelType, true)
+		return factory()
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, value string) []string {
+	result := list[:0]
+	for _, s := range list {
+		if s != value {
+			result = append(result, s)
+		}
+	}
+	return result
+}
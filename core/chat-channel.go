@@ -0,0 +1,200 @@
+package core
+
+import (
+	"crypto/rand"
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/chat"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"math"
+	"math/big"
+	"time"
+)
+
+const chatChannelType = "im.ricochet.chat"
+
+// chatReply identifies the earlier message that a chat message replies to.
+// MessageID is the ID given to that message by the peer that sent it, and Own
+// is true if it was sent by the peer that sent the reply.
+type chatReply struct {
+	MessageID uint32
+	Own       bool
+}
+
+// chatHandler receives events from a chatChannel
+type chatHandler interface {
+	// ChatMessage is called when a chat message is received, with the message
+	// it replies to if any. Return true to acknowledge the message, and false
+	// to refuse it.
+	ChatMessage(messageID uint32, when time.Time, message string, replyTo *chatReply) bool
+	// ChatMessageAck is called when an acknowledgement of a sent message is received
+	ChatMessageAck(messageID uint32, accepted bool)
+}
+
+// chatPacket and chatMessage are the wire format of the chat channel, which
+// is Protocol_Data_Chat.Packet with two optional fields added to ChatMessage
+// for replies. Peers without replies ignore those fields.
+type chatPacket struct {
+	ChatMessage      *chatMessage                        `protobuf:"bytes,1,opt,name=chat_message" json:"chat_message,omitempty"`
+	ChatAcknowledge  *Protocol_Data_Chat.ChatAcknowledge `protobuf:"bytes,2,opt,name=chat_acknowledge" json:"chat_acknowledge,omitempty"`
+	XXX_unrecognized []byte                              `json:"-"`
+}
+
+func (m *chatPacket) Reset()         { *m = chatPacket{} }
+func (m *chatPacket) String() string { return proto.CompactTextString(m) }
+func (*chatPacket) ProtoMessage()    {}
+
+type chatMessage struct {
+	MessageText *string `protobuf:"bytes,1,req,name=message_text" json:"message_text,omitempty"`
+	MessageId   *uint32 `protobuf:"varint,2,opt,name=message_id" json:"message_id,omitempty"`
+	TimeDelta   *int64  `protobuf:"varint,3,opt,name=time_delta" json:"time_delta,omitempty"`
+	// Message_id of the message that this one replies to, and whether that
+	// message was sent by the sender of this one
+	ReplyToId        *uint32 `protobuf:"varint,4,opt,name=reply_to_id" json:"reply_to_id,omitempty"`
+	ReplyToOwn       *bool   `protobuf:"varint,5,opt,name=reply_to_own" json:"reply_to_own,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *chatMessage) Reset()         { *m = chatMessage{} }
+func (m *chatMessage) String() string { return proto.CompactTextString(m) }
+func (*chatMessage) ProtoMessage()    {}
+
+func (m *chatMessage) GetMessageId() uint32 {
+	if m != nil && m.MessageId != nil {
+		return *m.MessageId
+	}
+	return 0
+}
+
+func (m *chatMessage) GetTimeDelta() int64 {
+	if m != nil && m.TimeDelta != nil {
+		return *m.TimeDelta
+	}
+	return 0
+}
+
+func (m *chatMessage) GetMessageText() string {
+	if m != nil && m.MessageText != nil {
+		return *m.MessageText
+	}
+	return ""
+}
+
+// replyTo returns the message that m replies to, or nil
+func (m *chatMessage) replyTo() *chatReply {
+	if m.ReplyToId == nil {
+		return nil
+	}
+	return &chatReply{MessageID: *m.ReplyToId, Own: m.ReplyToOwn != nil && *m.ReplyToOwn}
+}
+
+// chatChannel implements channels.Handler for im.ricochet.chat. It's the same
+// as the protocol library's ChatChannel, but also sends and receives replies.
+type chatChannel struct {
+	handler       chatHandler
+	channel       *channels.Channel
+	lastMessageID uint32
+}
+
+// sendMessage sends message with the time it was originally sent, which is
+// the time it was queued, and returns its messageID for the ack. If replyTo
+// isn't nil, it's sent as the message that this one replies to.
+func (cc *chatChannel) sendMessage(message string, when time.Time, replyTo *chatReply) uint32 {
+	messageID := cc.lastMessageID
+	cc.lastMessageID++
+	cm := &chatMessage{
+		MessageId:   proto.Uint32(messageID),
+		MessageText: proto.String(message),
+		TimeDelta:   proto.Int64(int64(time.Now().Sub(when) / time.Second)),
+	}
+	if replyTo != nil {
+		cm.ReplyToId = proto.Uint32(replyTo.MessageID)
+		cm.ReplyToOwn = proto.Bool(replyTo.Own)
+	}
+	data, err := proto.Marshal(&chatPacket{ChatMessage: cm})
+	ricochetutils.CheckError(err)
+	cc.channel.SendMessage(data)
+	return messageID
+}
+
+func (cc *chatChannel) acknowledge(messageID uint32, accepted bool) {
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	cc.channel.SendMessage(messageBuilder.AckChatMessage(messageID, accepted))
+}
+
+func (cc *chatChannel) Type() string {
+	return chatChannelType
+}
+
+func (cc *chatChannel) Closed(err error) {
+}
+
+func (cc *chatChannel) OnlyClientCanOpen() bool {
+	return false
+}
+
+func (cc *chatChannel) Singleton() bool {
+	return true
+}
+
+func (cc *chatChannel) Bidirectional() bool {
+	return false
+}
+
+func (cc *chatChannel) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+
+// Message IDs start at a random value on each channel
+func (cc *chatChannel) initMessageID() error {
+	id, err := rand.Int(rand.Reader, big.NewInt(math.MaxUint32))
+	if err != nil {
+		return err
+	}
+	cc.lastMessageID = uint32(id.Uint64())
+	return nil
+}
+
+func (cc *chatChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	cc.channel = channel
+	if err := cc.initMessageID(); err != nil {
+		return nil, err
+	}
+	channel.Pending = false
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.AckOpenChannel(channel.ID), nil
+}
+
+func (cc *chatChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	cc.channel = channel
+	if err := cc.initMessageID(); err != nil {
+		return nil, err
+	}
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.OpenChannel(channel.ID, cc.Type()), nil
+}
+
+func (cc *chatChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	if err == nil && crm.GetOpened() {
+		cc.channel.Pending = false
+	}
+}
+
+func (cc *chatChannel) Packet(data []byte) {
+	if cc.channel.Pending {
+		return
+	}
+	packet := new(chatPacket)
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return
+	}
+	if cm := packet.ChatMessage; cm != nil {
+		// The time delta is how long ago the message was originally sent
+		when := time.Now().Add(-time.Duration(cm.GetTimeDelta()) * time.Second)
+		ack := cc.handler.ChatMessage(cm.GetMessageId(), when, cm.GetMessageText(), cm.replyTo())
+		cc.acknowledge(cm.GetMessageId(), ack)
+	} else if ack := packet.ChatAcknowledge; ack != nil {
+		cc.handler.ChatMessageAck(ack.GetMessageId(), ack.GetAccepted())
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/chat"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testChatHandler records the last message received by a chatChannel
+type testChatHandler struct {
+	messageID uint32
+	when      time.Time
+	message   string
+	replyTo   *chatReply
+}
+
+func (h *testChatHandler) ChatMessage(messageID uint32, when time.Time, message string, replyTo *chatReply) bool {
+	h.messageID, h.when, h.message, h.replyTo = messageID, when, message, replyTo
+	return true
+}
+
+func (h *testChatHandler) ChatMessageAck(messageID uint32, accepted bool) {
+}
+
+// newTestChatChannel returns an open chatChannel that appends packets it
+// sends to sent
+func newTestChatChannel(handler chatHandler, sent *[][]byte) *chatChannel {
+	cc := &chatChannel{handler: handler}
+	cc.channel = &channels.Channel{
+		SendMessage: func(data []byte) { *sent = append(*sent, data) },
+	}
+	return cc
+}
+
+// Replies are sent as optional fields of the chat message, which peers
+// without replies ignore
+func TestChatChannelReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		replyTo *chatReply
+	}{
+		{"message", nil},
+		{"reply to own message", &chatReply{MessageID: 7, Own: true}},
+		{"reply to peer's message", &chatReply{MessageID: 4294967295, Own: false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent [][]byte
+			sender := newTestChatChannel(nil, &sent)
+			messageID := sender.sendMessage("hello", time.Now(), test.replyTo)
+			if len(sent) != 1 {
+				t.Fatalf("sent %d packets, expected 1", len(sent))
+			}
+
+			handler := &testChatHandler{}
+			var acks [][]byte
+			receiver := newTestChatChannel(handler, &acks)
+			receiver.Packet(sent[0])
+			if handler.messageID != messageID || handler.message != "hello" {
+				t.Errorf("received message %d %q, expected %d %q", handler.messageID, handler.message, messageID, "hello")
+			}
+			if !reflect.DeepEqual(handler.replyTo, test.replyTo) {
+				t.Errorf("received reply to %+v, expected %+v", handler.replyTo, test.replyTo)
+			}
+			if len(acks) != 1 {
+				t.Errorf("sent %d acks, expected 1", len(acks))
+			}
+
+			// A peer using the protocol library reads the same message
+			packet := new(Protocol_Data_Chat.Packet)
+			if err := proto.Unmarshal(sent[0], packet); err != nil {
+				t.Fatal(err)
+			}
+			if cm := packet.GetChatMessage(); cm.GetMessageId() != messageID || cm.GetMessageText() != "hello" {
+				t.Errorf("library read message %d %q, expected %d %q", cm.GetMessageId(), cm.GetMessageText(), messageID, "hello")
+			}
+		})
+	}
+
+	// Messages from a peer using the protocol library aren't replies
+	handler := &testChatHandler{replyTo: &chatReply{}}
+	var acks [][]byte
+	newTestChatChannel(handler, &acks).Packet(new(ricochetutils.MessageBuilder).ChatMessage("hello", 3, 0))
+	if handler.messageID != 3 || handler.message != "hello" || handler.replyTo != nil {
+		t.Errorf("received message %d %q replying to %+v from the library", handler.messageID, handler.message, handler.replyTo)
+	}
+}
+
+// Messages are received with the time they were originally sent, from the
+// time delta sent with them
+func TestChatChannelTimeDelta(t *testing.T) {
+	tests := []struct {
+		name string
+		// How long before sending the message was queued
+		age time.Duration
+	}{
+		{"sent immediately", 0},
+		{"queued for a minute", time.Minute},
+		{"queued for a day", 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent [][]byte
+			when := time.Now().Add(-test.age)
+			newTestChatChannel(nil, &sent).sendMessage("hello", when, nil)
+
+			handler := &testChatHandler{}
+			var acks [][]byte
+			newTestChatChannel(handler, &acks).Packet(sent[0])
+			// The delta is in whole seconds
+			if diff := handler.when.Sub(when); diff < -time.Second || diff > time.Second {
+				t.Errorf("received message sent at %v, expected %v", handler.when, when)
+			}
+		})
+	}
+}
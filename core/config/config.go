@@ -1,20 +1,33 @@
 package config
 
 import (
+	"bytes"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// DeferredSaveDelay is the longest that changes made with UnlockDeferred wait
+// before they are saved
+const DeferredSaveDelay = 5 * time.Second
+
 type ConfigFile struct {
 	filePath     string
 	root         *ricochet.Config
 	readSnapshot atomic.Value
 	mutex        sync.Mutex
+	// Non-nil while changes from UnlockDeferred are waiting to be saved
+	saveTimer *time.Timer
+	// Non-nil if the file is encrypted with a passphrase
+	cipher *utils.PassphraseCipher
 }
 
 func NewConfigFile(path string) (*ConfigFile, error) {
@@ -29,22 +42,45 @@ func NewConfigFile(path string) (*ConfigFile, error) {
 	return cfg, nil
 }
 
+// LoadConfigFile reads the config from path. If the file is encrypted, it
+// returns ConfigEncryptedError, and LoadEncryptedConfigFile must be used.
 func LoadConfigFile(path string) (*ConfigFile, error) {
-	cfg := &ConfigFile{
-		filePath: path,
-		root:     &ricochet.Config{},
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isEncryptedConfig(data) {
+		return nil, ConfigEncryptedError
 	}
+	return loadConfigData(path, data, nil)
+}
 
-	file, err := os.Open(cfg.filePath)
+// LoadEncryptedConfigFile reads and decrypts the config from path, returning
+// WrongPassphraseError if passphrase does not decrypt it. Changes are saved
+// encrypted with the same passphrase.
+func LoadEncryptedConfigFile(path, passphrase string) (*ConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, cipher, err := decryptConfig(data, passphrase)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return loadConfigData(path, plaintext, cipher)
+}
+
+func loadConfigData(path string, data []byte, cipher *utils.PassphraseCipher) (*ConfigFile, error) {
+	cfg := &ConfigFile{
+		filePath: path,
+		root:     &ricochet.Config{},
+		cipher:   cipher,
+	}
 
 	json := jsonpb.Unmarshaler{
 		AllowUnknownFields: true,
 	}
-	if err := json.Unmarshal(file, cfg.root); err != nil {
+	if err := json.Unmarshal(bytes.NewReader(data), cfg.root); err != nil {
 		return nil, err
 	}
 
@@ -84,32 +120,157 @@ func (cfg *ConfigFile) Unlock() {
 	cfg.mutex.Unlock()
 }
 
+// UnlockDeferred is the same as Unlock, but the changes are saved up to
+// DeferredSaveDelay later, so that frequent changes of little importance are
+// combined into one write. Any call to Unlock or Save in the meantime also
+// saves these changes.
+func (cfg *ConfigFile) UnlockDeferred() {
+	cfg.root = proto.Clone(cfg.root).(*ricochet.Config)
+	cfg.readSnapshot.Store(cfg.root)
+	if cfg.saveTimer == nil {
+		cfg.saveTimer = time.AfterFunc(DeferredSaveDelay, cfg.deferredSave)
+	}
+	cfg.mutex.Unlock()
+}
+
+func (cfg *ConfigFile) deferredSave() {
+	cfg.mutex.Lock()
+	defer cfg.mutex.Unlock()
+	if cfg.saveTimer == nil {
+		// Already saved
+		return
+	}
+	if err := cfg.save(); err != nil {
+		log.Printf("WARNING: Unable to save configuration: %s", err)
+	}
+}
+
+// Save writes the current configuration to the file. Changes are saved by
+// Unlock, so this is only needed to be certain that changes from UnlockDeferred
+// are written, e.g. before exiting.
+func (cfg *ConfigFile) Save() error {
+	cfg.mutex.Lock()
+	defer cfg.mutex.Unlock()
+	return cfg.save()
+}
+
+// IsEncrypted returns true if the file is saved encrypted with a passphrase
+func (cfg *ConfigFile) IsEncrypted() bool {
+	cfg.mutex.Lock()
+	defer cfg.mutex.Unlock()
+	return cfg.cipher != nil
+}
+
+// SetPassphrase encrypts the file with passphrase, replacing any previous
+// passphrase, and saves it immediately. An empty passphrase saves the file
+// unencrypted.
+func (cfg *ConfigFile) SetPassphrase(passphrase string) error {
+	var cipher *utils.PassphraseCipher
+	if passphrase != "" {
+		var err error
+		if cipher, err = newConfigCipher(passphrase); err != nil {
+			return err
+		}
+	}
+
+	cfg.mutex.Lock()
+	defer cfg.mutex.Unlock()
+	previous := cfg.cipher
+	cfg.cipher = cipher
+	if err := cfg.save(); err != nil {
+		cfg.cipher = previous
+		return err
+	}
+	return nil
+}
+
+// Assumes mutex is held
 func (cfg *ConfigFile) save() error {
+	if cfg.saveTimer != nil {
+		cfg.saveTimer.Stop()
+		cfg.saveTimer = nil
+	}
+	if cfg.cipher == nil {
+		return saveFile(cfg.filePath, cfg.root)
+	}
+
+	data, err := marshalJSON(cfg.root)
+	if err != nil {
+		log.Printf("Config encoding error: %v", err)
+		return err
+	}
+	if data, err = cfg.cipher.Seal(data); err != nil {
+		log.Printf("Config encryption error: %v", err)
+		return err
+	}
+	return writeFileAtomic(cfg.filePath, data)
+}
+
+func marshalJSON(msg proto.Message) ([]byte, error) {
 	json := jsonpb.Marshaler{Indent: "  "}
+	var buf bytes.Buffer
+	if err := json.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// saveFile writes msg as JSON to the file at path, atomically replacing any
+// existing file
+func saveFile(path string, msg proto.Message) error {
+	data, err := marshalJSON(msg)
+	if err != nil {
+		log.Printf("Config encoding error: %v", err)
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
 
-	// Make a pathetic attempt at atomic file write by writing into a
-	// temporary file and renaming over the original; this is probably
-	// imperfect as-implemented, but better than truncating and writing
-	// directly.
-	tempPath := cfg.filePath + ".new"
+// writeFileAtomic writes data to the file at path, atomically replacing any
+// existing file. The data is written to a temporary file in the same directory,
+// synced to disk, and renamed over the original, so a crash at any point leaves
+// either the old or the new file intact. This matters because the config holds
+// the identity's private key.
+func writeFileAtomic(path string, data []byte) error {
+	tempPath := path + ".new"
 	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err != nil {
 		log.Printf("Config save error: %v", err)
 		return err
 	}
 
-	err = json.Marshal(file, cfg.root)
-	if err != nil {
-		log.Printf("Config encoding error: %v", err)
+	if _, err := file.Write(data); err != nil {
+		log.Printf("Config save error: %v", err)
 		file.Close()
+		os.Remove(tempPath)
 		return err
 	}
 
-	file.Close()
-	if err := os.Rename(tempPath, cfg.filePath); err != nil {
+	// The data must be on disk before the rename, or a crash could leave an
+	// empty or partial file in its place
+	if err := file.Sync(); err != nil {
+		log.Printf("Config sync error: %v", err)
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Config save error: %v", err)
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
 		log.Printf("Config replace error: %v", err)
 		return err
 	}
 
+	// Sync the directory to persist the rename. Not all platforms support
+	// this, and the file itself is safe either way, so errors are ignored.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
 	return nil
 }
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A save that is interrupted partway leaves the previous config intact
+func TestConfigPartialWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		// Simulates an interrupted save of the config at path
+		interrupt func(t *testing.T, path string)
+		// True if the next save can replace the file
+		recovers bool
+	}{
+		{"crash while writing", func(t *testing.T, path string) {
+			// A crash leaves a truncated temporary file behind
+			if err := ioutil.WriteFile(path+".new", []byte(`{"secrets": {"servicePriv`), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"write fails", func(t *testing.T, path string) {
+			// The temporary file can't be opened for writing
+			if err := os.MkdirAll(filepath.Join(path+".new", "busy"), 0700); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "identity.json")
+			cfg, err := NewConfigFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := []byte("private key")
+			config := cfg.Lock()
+			config.Secrets = &ricochet.Secrets{ServicePrivateKey: key}
+			cfg.Unlock()
+
+			test.interrupt(t, path)
+			loaded, err := LoadConfigFile(path)
+			if err != nil {
+				t.Fatalf("config can't be loaded after an interrupted save: %v", err)
+			}
+			if !bytes.Equal(loaded.Read().Secrets.GetServicePrivateKey(), key) {
+				t.Fatal("interrupted save changed the config")
+			}
+
+			config = cfg.Lock()
+			config.ServicePort = 1234
+			cfg.Unlock()
+			loaded, err = LoadConfigFile(path)
+			if err != nil {
+				t.Fatalf("config can't be loaded after saving again: %v", err)
+			}
+			if !bytes.Equal(loaded.Read().Secrets.GetServicePrivateKey(), key) {
+				t.Error("saving again lost the private key")
+			}
+			if saved := loaded.Read().ServicePort == 1234; saved != test.recovers {
+				t.Errorf("change saved is %v, expected %v", saved, test.recovers)
+			}
+		})
+	}
+}
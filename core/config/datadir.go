@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// DataDir is a directory holding the files of one profile: its config, which
+// contains the identity, the message history, and other state kept by the
+// backend. Profiles in different data directories share no files, and can run
+// at the same time.
+type DataDir struct {
+	Path string
+}
+
+func NewDataDir(path string) *DataDir {
+	return &DataDir{Path: path}
+}
+
+// Create creates the directory if it doesn't exist, accessible only to the
+// current user
+func (d *DataDir) Create() error {
+	if err := os.MkdirAll(d.Path, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(d.Path)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return errors.New("Data directory is not a directory")
+	}
+	return nil
+}
+
+// ConfigPath is the config file, which contains the identity and contacts
+func (d *DataDir) ConfigPath() string {
+	return filepath.Join(d.Path, "identity.json")
+}
+
+// HistoryPath is the message history file
+func (d *DataDir) HistoryPath() string {
+	return filepath.Join(d.Path, "history.json")
+}
+
+// TorDataPath is the data directory of a tor process launched for the profile
+func (d *DataDir) TorDataPath() string {
+	return filepath.Join(d.Path, "tor")
+}
+
+// RpcSocketPath is a unix socket for the backend's RPC server
+func (d *DataDir) RpcSocketPath() string {
+	return filepath.Join(d.Path, "rpc.sock")
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+)
+
+// Encrypted config files hold the same JSON as plaintext ones, encrypted by a
+// utils.PassphraseCipher. The key is derived once when the file is loaded or
+// encrypted, and every save uses a new nonce.
+const encryptedConfigMagic = "RICOCHET-CF1"
+
+var (
+	ConfigEncryptedError    error = errors.New("Config is encrypted; a passphrase is required")
+	WrongPassphraseError    error = errors.New("Wrong passphrase or damaged config")
+	ConfigNotEncryptedError error = errors.New("Config is not encrypted")
+)
+
+func isEncryptedConfig(data []byte) bool {
+	return utils.IsPassphraseEncrypted(data, encryptedConfigMagic)
+}
+
+// newConfigCipher derives a key from passphrase with a new random salt
+func newConfigCipher(passphrase string) (*utils.PassphraseCipher, error) {
+	return utils.NewPassphraseCipher(encryptedConfigMagic, passphrase)
+}
+
+// decryptConfig returns the plaintext of an encrypted config file, along with
+// the cipher to encrypt later saves with the same passphrase
+func decryptConfig(data []byte, passphrase string) ([]byte, *utils.PassphraseCipher, error) {
+	plaintext, c, err := utils.OpenWithPassphrase(data, encryptedConfigMagic, passphrase)
+	switch err {
+	case utils.NotPassphraseEncryptedError:
+		return nil, nil, ConfigNotEncryptedError
+	case utils.WrongPassphraseError:
+		return nil, nil, WrongPassphraseError
+	}
+	return plaintext, c, err
+}
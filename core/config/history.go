@@ -0,0 +1,134 @@
+package config
+
+import (
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// HistoryFile stores the message history of conversations, keyed by contact
+// address. It's kept separately from ConfigFile so that the frequent writes
+// for new messages don't require rewriting the rest of the configuration.
+// Changes are combined and written up to DeferredSaveDelay later, rather than
+// rewriting the whole file for each message.
+type HistoryFile struct {
+	filePath string
+	root     *ricochet.History
+	mutex    sync.Mutex
+	// Non-nil while changes from Store are waiting to be saved
+	saveTimer *time.Timer
+}
+
+// LoadHistoryFile reads the history from path, or starts an empty history
+// if the file does not exist yet.
+func LoadHistoryFile(path string) (*HistoryFile, error) {
+	h := &HistoryFile{
+		filePath: path,
+		root:     &ricochet.History{},
+	}
+
+	file, err := os.Open(h.filePath)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	json := jsonpb.Unmarshaler{
+		AllowUnknownFields: true,
+	}
+	if err := json.Unmarshal(file, h.root); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Messages returns a copy of the stored messages for the contact address.
+func (h *HistoryFile) Messages(address string) []*ricochet.Message {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	list := h.root.Conversations[address]
+	if list == nil {
+		return nil
+	}
+	re := make([]*ricochet.Message, 0, len(list.Messages))
+	for _, message := range list.Messages {
+		re = append(re, proto.Clone(message).(*ricochet.Message))
+	}
+	return re
+}
+
+// Store replaces the stored messages for the contact address, which are saved
+// up to DeferredSaveDelay later. The messages are copied, so callers may
+// continue to modify them.
+func (h *HistoryFile) Store(address string, messages []*ricochet.Message) {
+	list := &ricochet.MessageList{
+		Messages: make([]*ricochet.Message, 0, len(messages)),
+	}
+	for _, message := range messages {
+		list.Messages = append(list.Messages, proto.Clone(message).(*ricochet.Message))
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.root.Conversations == nil {
+		h.root.Conversations = make(map[string]*ricochet.MessageList)
+	}
+	h.root.Conversations[address] = list
+	if h.saveTimer == nil {
+		h.saveTimer = time.AfterFunc(DeferredSaveDelay, h.deferredSave)
+	}
+}
+
+// Delete removes all stored messages for the contact address, and saves the
+// history immediately.
+func (h *HistoryFile) Delete(address string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, exists := h.root.Conversations[address]; !exists {
+		return
+	}
+	delete(h.root.Conversations, address)
+	h.save()
+}
+
+func (h *HistoryFile) deferredSave() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.saveTimer == nil {
+		// Already saved
+		return
+	}
+	h.save()
+}
+
+// Save writes any changes from Store that are waiting to be saved, e.g.
+// before exiting.
+func (h *HistoryFile) Save() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.saveTimer == nil {
+		return nil
+	}
+	h.saveTimer.Stop()
+	h.saveTimer = nil
+	return saveFile(h.filePath, h.root)
+}
+
+// Assumes mutex is held
+func (h *HistoryFile) save() {
+	if h.saveTimer != nil {
+		h.saveTimer.Stop()
+		h.saveTimer = nil
+	}
+	if err := saveFile(h.filePath, h.root); err != nil {
+		log.Printf("WARNING: Unable to save message history: %s", err)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryFileSave(t *testing.T) {
+	const address = "ricochet:bbbbbbbbbbbbbbbb"
+	messages := []*ricochet.Message{{Text: "hello"}}
+
+	tests := []struct {
+		name string
+		// Changes the stored history, which has a saved message for address
+		change func(h *HistoryFile)
+		// Number of messages for address in the file before Save is called
+		saved int
+		// Number of messages for address after Save
+		expected int
+	}{
+		{"store is deferred", func(h *HistoryFile) {
+			h.Store(address, append(messages, &ricochet.Message{Text: "again"}))
+		}, 1, 2},
+		{"stores are combined", func(h *HistoryFile) {
+			for i := 0; i < 100; i++ {
+				h.Store(address, nil)
+			}
+		}, 1, 0},
+		{"delete is immediate", func(h *HistoryFile) {
+			h.Store(address, append(messages, &ricochet.Message{Text: "again"}))
+			h.Delete(address)
+		}, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.json")
+			history, err := LoadHistoryFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			history.Store(address, messages)
+			if err := history.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			count := func() int {
+				saved, err := LoadHistoryFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(saved.Messages(address))
+			}
+
+			test.change(history)
+			if n := count(); n != test.saved {
+				t.Errorf("file has %d messages before saving, expected %d", n, test.saved)
+			}
+			if err := history.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if n := count(); n != test.expected {
+				t.Errorf("file has %d messages after saving, expected %d", n, test.expected)
+			}
+		})
+	}
+}
@@ -1,29 +1,193 @@
 package core
 
 import (
+	"errors"
 	"github.com/s-rah/go-ricochet/channels"
 	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"sync"
+)
+
+// Most channels that the contact can have open or pending at once. Singleton
+// channel types are limited anyway, but others, like file transfers, could
+// otherwise be opened without end.
+const maxPeerChannels = 32
+
+var (
+	TooManyChannelsError     error = errors.New("Too many channels opened by the contact")
+	ChannelUnauthorizedError error = errors.New("Channel requires authentication the contact doesn't have")
+	ClientOnlyChannelError   error = errors.New("Channel can only be opened by the client")
+	DuplicateChannelError    error = errors.New("Channel is already open")
 )
 
 type ContactProtocolHandler struct {
 	connection.AutoConnectionHandler
 	conn    *connection.Connection
 	contact *Contact
+	// Channels opened by the contact that are still open. They're opened and
+	// closed by the peer from the connection's Process routine, but can be
+	// closed locally from any call to Do.
+	peerChannelsMutex sync.Mutex
+	peerChannels      map[*channels.Channel]*peerChannel
 }
 
+// NewContactProtocolHandler returns the handler for an authenticated contact
+// connection. Only the channel types registered here can be opened by the
+// contact; others are rejected with UnknownTypeError, which tells the peer
+// that we don't support them.
 func NewContactProtocolHandler(contact *Contact, conn *connection.Connection) *ContactProtocolHandler {
 	handler := &ContactProtocolHandler{
-		conn:    conn,
-		contact: contact,
+		conn:         conn,
+		contact:      contact,
+		peerChannels: make(map[*channels.Channel]*peerChannel),
 	}
 	handler.Init()
 
-	handler.RegisterChannelHandler("im.ricochet.chat", func() channels.Handler {
-		chat := &channels.ChatChannel{
-			Handler: contact.Conversation(),
+	// Inbound chat messages are rate limited across all chat channels on the
+	// connection
+	limiter := contact.core.newInboundMessageLimiter()
+	handler.RegisterChannelHandler(chatChannelType, func() channels.Handler {
+		return &chatChannel{
+			handler: contact.Conversation().newInboundChatHandler(conn, limiter),
 		}
-		return chat
 	})
 
+	// Optional channels opened by the contact show which features they support
+	handler.RegisterChannelHandler(typingChannelType, contact.supportedChannelHandler(typingChannelType, func() channels.Handler {
+		return &typingChannel{conversation: contact.Conversation()}
+	}))
+
+	handler.RegisterChannelHandler(presenceChannelType, contact.supportedChannelHandler(presenceChannelType, func() channels.Handler {
+		return &presenceChannel{contact: contact}
+	}))
+
+	handler.RegisterChannelHandler(fileTransferChannelType, contact.supportedChannelHandler(fileTransferChannelType, func() channels.Handler {
+		return &fileTransferChannel{
+			list:    contact.core.Identity.FileTransfers(),
+			contact: contact,
+			conn:    conn,
+		}
+	}))
+
 	return handler
 }
+
+// OnOpenChannelRequest returns the handler for a channel opened by the contact,
+// which counts it towards maxPeerChannels while it's open
+func (handler *ContactProtocolHandler) OnOpenChannelRequest(ctype string) (channels.Handler, error) {
+	h, err := handler.AutoConnectionHandler.OnOpenChannelRequest(ctype)
+	if err != nil {
+		return nil, err
+	}
+	return &peerChannel{Handler: h, protocol: handler}, nil
+}
+
+// OnClosed closes the channels that the contact left open, so their handlers
+// aren't left waiting for packets that will never arrive
+func (handler *ContactProtocolHandler) OnClosed(err error) {
+	for _, pc := range handler.openPeerChannels() {
+		pc.Closed(err)
+	}
+}
+
+// openPeerChannels returns the channels opened by the contact that are open
+func (handler *ContactProtocolHandler) openPeerChannels() []*peerChannel {
+	handler.peerChannelsMutex.Lock()
+	defer handler.peerChannelsMutex.Unlock()
+	open := make([]*peerChannel, 0, len(handler.peerChannels))
+	for _, pc := range handler.peerChannels {
+		open = append(open, pc)
+	}
+	return open
+}
+
+func (handler *ContactProtocolHandler) setPeerChannelOpen(pc *peerChannel, open bool) {
+	handler.peerChannelsMutex.Lock()
+	defer handler.peerChannelsMutex.Unlock()
+	if open {
+		handler.peerChannels[pc.channel] = pc
+	} else {
+		delete(handler.peerChannels, pc.channel)
+	}
+}
+
+// peerChannel wraps the handler of a channel opened by the contact, to track
+// the channels that are open on the connection. It also checks authorization
+// and singletons itself, because the protocol library doesn't tell the
+// contact why a channel was rejected.
+type peerChannel struct {
+	channels.Handler
+	protocol *ContactProtocolHandler
+	channel  *channels.Channel
+}
+
+func (pc *peerChannel) RequiresAuthentication() string {
+	return "none"
+}
+
+func (pc *peerChannel) Singleton() bool {
+	return false
+}
+
+// allowed returns the protocol error name and error if the contact can't open
+// this channel now
+func (pc *peerChannel) allowed() (string, error) {
+	conn := pc.protocol.conn
+	if auth := pc.Handler.RequiresAuthentication(); auth != "none" && !conn.Authentication[auth] {
+		return "UnauthorizedError", ChannelUnauthorizedError
+	}
+	if !conn.IsInbound && pc.Handler.OnlyClientCanOpen() {
+		return "BadUsageError", ClientOnlyChannelError
+	}
+	open := pc.protocol.openPeerChannels()
+	if pc.Handler.Singleton() {
+		for _, other := range open {
+			if other.Type() == pc.Type() {
+				return "BadUsageError", DuplicateChannelError
+			}
+		}
+	}
+	if len(open) >= maxPeerChannels {
+		return "FailedError", TooManyChannelsError
+	}
+	return "", nil
+}
+
+// reject sends a result with errorName for the channel, which the protocol
+// library doesn't do when OpenInbound fails
+func (pc *peerChannel) reject(channel *channels.Channel, errorName string) {
+	conn := pc.protocol.conn
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	conn.SendRicochetPacket(conn.Conn, 0, messageBuilder.RejectOpenChannel(channel.ID, errorName))
+}
+
+func (pc *peerChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	if errorName, err := pc.allowed(); err != nil {
+		pc.protocol.contact.core.Log.Warnf("Rejecting %s channel from %s: %s", pc.Type(), pc.protocol.contact.Address(), err)
+		pc.reject(channel, errorName)
+		return nil, err
+	}
+
+	response, err := pc.Handler.OpenInbound(channel, raw)
+	if err != nil {
+		pc.reject(channel, "FailedError")
+		return nil, err
+	} else if channel.Pending {
+		// Not opened; the protocol library removes the channel
+		return response, nil
+	}
+	pc.channel = channel
+	pc.protocol.setPeerChannelOpen(pc, true)
+	closeChannel := channel.CloseChannel
+	channel.CloseChannel = func() {
+		pc.protocol.setPeerChannelOpen(pc, false)
+		closeChannel()
+	}
+	return response, nil
+}
+
+func (pc *peerChannel) Closed(err error) {
+	pc.protocol.setPeerChannelOpen(pc, false)
+	pc.Handler.Closed(err)
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"net"
+	"testing"
+	"time"
+)
+
+// testPeerChannelHandler is a non-singleton channel that records whether it
+// was closed. It opens inbound channels unless failing is set.
+type testPeerChannelHandler struct {
+	channels.Handler
+	ctype      string
+	clientOnly bool
+	failing    bool
+	closed     error
+}
+
+func (h *testPeerChannelHandler) Type() string            { return h.ctype }
+func (h *testPeerChannelHandler) OnlyClientCanOpen() bool { return h.clientOnly }
+func (h *testPeerChannelHandler) Singleton() bool         { return false }
+func (h *testPeerChannelHandler) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+func (h *testPeerChannelHandler) Closed(err error) {
+	h.closed = err
+}
+func (h *testPeerChannelHandler) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	if h.failing {
+		return nil, errors.New("Test channel failed")
+	}
+	channel.Pending = false
+	return nil, nil
+}
+
+// The contact can't have more than maxPeerChannels open at once, and channels
+// left open are closed with the connection
+func TestPeerChannelLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		open   int
+		closed func(pc *peerChannel)
+		// Whether another channel can be opened
+		allowed bool
+	}{
+		{"under the limit", maxPeerChannels - 1, nil, true},
+		{"at the limit", maxPeerChannels, nil, false},
+		{"closed by the contact", maxPeerChannels, func(pc *peerChannel) {
+			pc.Closed(errors.New("Closed by peer"))
+		}, true},
+		{"closed locally", maxPeerChannels, func(pc *peerChannel) {
+			pc.channel.CloseChannel()
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			handler := &ContactProtocolHandler{
+				conn:         newTestConnection(t, "bbbbbbbbbbbbbbbb"),
+				contact:      contact,
+				peerChannels: make(map[*channels.Channel]*peerChannel),
+			}
+			open := func(id int32) (*peerChannel, *testPeerChannelHandler, error) {
+				inner := &testPeerChannelHandler{ctype: fileTransferChannelType}
+				pc := &peerChannel{Handler: inner, protocol: handler}
+				channel := &channels.Channel{ID: id, Pending: true, CloseChannel: func() {}}
+				_, err := pc.OpenInbound(channel, &Protocol_Data_Control.OpenChannel{})
+				return pc, inner, err
+			}
+
+			var opened []*testPeerChannelHandler
+			var last *peerChannel
+			for i := 0; i < test.open; i++ {
+				pc, inner, err := open(int32(2*i + 1))
+				if err != nil {
+					t.Fatalf("channel %d was rejected: %v", i, err)
+				}
+				opened = append(opened, inner)
+				last = pc
+			}
+			if test.closed != nil {
+				test.closed(last)
+				opened = opened[:len(opened)-1]
+			}
+
+			_, inner, err := open(int32(2*test.open + 1))
+			if allowed := err == nil; allowed != test.allowed {
+				t.Fatalf("another channel was allowed %v, expected %v (%v)", allowed, test.allowed, err)
+			}
+			if err == nil {
+				opened = append(opened, inner)
+			} else if err != TooManyChannelsError {
+				t.Errorf("channel was rejected with %v, expected TooManyChannelsError", err)
+			}
+
+			connErr := errors.New("Connection closed")
+			handler.OnClosed(connErr)
+			for i, inner := range opened {
+				if inner.closed != connErr {
+					t.Errorf("channel %d was closed with %v, expected the connection's error", i, inner.closed)
+				}
+			}
+		})
+	}
+}
+
+// newTestRejectConnection returns an authenticated connection to remote, with
+// the CommonError of channel results sent on it written to rejected
+func newTestRejectConnection(t *testing.T, remote string, inbound bool) (*connection.Connection, chan string) {
+	local, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+	rejected := make(chan string, 8)
+	go func() {
+		network := new(ricochetutils.RicochetNetwork)
+		for {
+			data, err := network.RecvRicochetPacket(peer)
+			if err != nil {
+				return
+			}
+			packet := new(Protocol_Data_Control.Packet)
+			if data.Channel == 0 && proto.Unmarshal(data.Data, packet) == nil && packet.GetChannelResult() != nil {
+				rejected <- packet.GetChannelResult().GetCommonError().String()
+			}
+		}
+	}()
+	var conn *connection.Connection
+	if inbound {
+		conn = connection.NewInboundConnection(local)
+		conn.RemoteHostname = remote
+	} else {
+		conn = connection.NewOutboundConnection(local, remote)
+	}
+	return conn, rejected
+}
+
+// Only registered channel types can be opened by the contact, and channels
+// that can't be opened are rejected with the matching protocol error
+func TestPeerChannelTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctype string
+		// Connection is inbound from the contact, so they're the client
+		inbound       bool
+		authenticated bool
+		// Channels of the same type already open
+		open int
+		// Expected rejection, or empty if the channel is opened
+		rejected string
+	}{
+		{"chat", chatChannelType, false, true, 0, ""},
+		{"typing", typingChannelType, false, true, 0, ""},
+		{"presence", presenceChannelType, false, true, 0, ""},
+		{"file transfer", fileTransferChannelType, false, true, 0, ""},
+		{"second file transfer", fileTransferChannelType, false, true, 1, ""},
+		{"second chat", chatChannelType, false, true, 1, "BadUsageError"},
+		{"unknown", "im.ricochet.unknown", false, true, 0, "UnknownTypeError"},
+		{"contact request", contactRequestChannelType, true, true, 0, "UnknownTypeError"},
+		{"authentication", hiddenServiceAuthChannelType, true, true, 0, "UnknownTypeError"},
+		{"unauthenticated", chatChannelType, false, false, 0, "UnauthorizedError"},
+		{"client only from client", "test.client-only", true, true, 0, ""},
+		{"client only from server", "test.client-only", false, true, 0, "BadUsageError"},
+		{"failed", "test.failing", false, true, 0, "FailedError"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			conn, rejected := newTestRejectConnection(t, "bbbbbbbbbbbbbbbb", test.inbound)
+			if test.authenticated {
+				conn.Authentication["im.ricochet.auth.hidden-service"] = true
+			}
+			handler := NewContactProtocolHandler(contact, conn)
+			handler.RegisterChannelHandler("test.client-only", func() channels.Handler {
+				return &testPeerChannelHandler{ctype: "test.client-only", clientOnly: true}
+			})
+			handler.RegisterChannelHandler("test.failing", func() channels.Handler {
+				return &testPeerChannelHandler{ctype: "test.failing", failing: true}
+			})
+
+			// open returns the rejection of a channel from the contact, as the
+			// protocol library would handle it
+			nextID := int32(2)
+			if test.inbound {
+				nextID = 1
+			}
+			open := func() string {
+				h, err := handler.OnOpenChannelRequest(test.ctype)
+				if err != nil {
+					return "UnknownTypeError"
+				}
+				channel := &channels.Channel{
+					ID:           nextID,
+					Type:         test.ctype,
+					Direction:    channels.Inbound,
+					Handler:      h,
+					Pending:      true,
+					SendMessage:  func([]byte) {},
+					CloseChannel: func() {},
+				}
+				nextID += 2
+				raw := &Protocol_Data_Control.OpenChannel{
+					ChannelIdentifier: proto.Int32(channel.ID),
+					ChannelType:       proto.String(test.ctype),
+				}
+				if _, err := h.OpenInbound(channel, raw); err == nil {
+					if channel.Pending {
+						t.Fatal("channel is still pending")
+					}
+					return ""
+				}
+				select {
+				case result := <-rejected:
+					return result
+				case <-time.After(5 * time.Second):
+					t.Fatal("channel wasn't rejected")
+					return ""
+				}
+			}
+
+			for i := 0; i < test.open; i++ {
+				if result := open(); result != "" {
+					t.Fatalf("channel %d was rejected with %s", i, result)
+				}
+			}
+			if result := open(); result != test.rejected {
+				t.Errorf("channel was rejected with %q, expected %q", result, test.rejected)
+			}
+		})
+	}
+}
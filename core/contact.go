@@ -1,17 +1,22 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	protocol "github.com/s-rah/go-ricochet"
-	channels "github.com/s-rah/go-ricochet/channels"
 	connection "github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
 	"golang.org/x/net/context"
 	"log"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type Contact struct {
@@ -27,8 +32,35 @@ type Contact struct {
 	connChannel       chan *connection.Connection
 	connEnabledSignal chan bool
 	connectionOnce    sync.Once
+	// Closed by shutdown to end contactConnection, which closes connStopped on exit
+	connStop     chan struct{}
+	connStopped  chan struct{}
+	connStopOnce sync.Once
+	// Set once the contact has been removed; its data is no longer saved
+	destroyed bool
+	// Set while an outbound connection attempt has begun authentication
+	outboundAuthenticating bool
+	// Number of connectOutbound goroutines running
+	outboundAttempts int
+	// Set when outbound connections to a dormant contact have been asked for
+	connectRequested bool
+	// Set while our contact request is being sent, after which it can't be
+	// edited until the reply shows whether it was delivered
+	requestSending bool
+	// Unread messages in the conversation, kept here so Data doesn't need the
+	// conversation's mutex
+	unreadCount int
+	// Presence sent by the contact on the active connection, or nil
+	remotePresence *ricochet.Presence
+	// Set while the active connection is shown offline after the contact has
+	// sent nothing for longer than its keepalive interval
+	silent bool
 
 	timeConnected time.Time
+	// Start of the active connection for online time statistics, or zero when
+	// not connected. Unlike timeConnected, this isn't reset when a connection
+	// is replaced.
+	onlineSince time.Time
 
 	conversation *Conversation
 }
@@ -40,13 +72,23 @@ func ContactFromConfig(core *Ricochet, data *ricochet.Contact, events *utils.Pub
 		events:            events,
 		connChannel:       make(chan *connection.Connection),
 		connEnabledSignal: make(chan bool),
+		connStop:          make(chan struct{}),
+		connStopped:       make(chan struct{}),
 	}
 
 	if !IsAddressValid(data.Address) {
 		return nil, fmt.Errorf("Invalid contact address '%s", data.Address)
 	}
 
-	if data.Request != nil {
+	// The conversation is loaded when it's first used, but the unread count
+	// is needed by clients before then
+	if core.History != nil {
+		contact.unreadCount = countUnread(core.History.Messages(data.Address))
+	}
+
+	if data.Blocked {
+		contact.data.Status = ricochet.Contact_BLOCKED
+	} else if data.Request != nil {
 		if data.Request.Rejected {
 			contact.data.Status = ricochet.Contact_REJECTED
 		} else {
@@ -101,7 +143,181 @@ func (c *Contact) Status() ricochet.Contact_Status {
 func (c *Contact) Data() *ricochet.Contact {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return proto.Clone(c.data).(*ricochet.Contact)
+	return c.dataLocked()
+}
+
+// Same as Data, but assumes the mutex is already held
+func (c *Contact) dataLocked() *ricochet.Contact {
+	data := proto.Clone(c.data).(*ricochet.Contact)
+	if data.Request != nil && data.Request.Direction == ricochet.ContactRequest_OUTBOUND {
+		data.Request.Phase = contactRequestPhase(data.Request)
+	}
+	if c.connection != nil {
+		data.Connection = &ricochet.ContactConnection{
+			Inbound:       c.connection.IsInbound,
+			WhenConnected: c.timeConnected.Format(time.RFC3339),
+		}
+	}
+	if !c.onlineSince.IsZero() {
+		// The contact is seen now, and the active connection counts as online time
+		data.LastSeen = time.Now().Format(time.RFC3339)
+		data.OnlineSeconds += int64(time.Since(c.onlineSince).Seconds())
+	}
+	data.UnreadCount = uint32(c.unreadCount)
+	if c.remotePresence != nil {
+		data.Presence = proto.Clone(c.remotePresence).(*ricochet.Presence)
+	}
+	// Drafts are only available from Draft
+	data.Draft = ""
+	return data
+}
+
+// Draft returns the unsent message saved for the contact by SetDraft
+func (c *Contact) Draft() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.data.Draft
+}
+
+// SetDraft saves an unsent message for the contact, or removes it if text is
+// empty. Drafts can change with every keystroke, so the config is written later.
+func (c *Contact) SetDraft(text string) error {
+	if len(text) > c.core.Acceptance.MaxMessageLength {
+		return errors.New("Draft is too long")
+	} else if !utf8.ValidString(text) {
+		return errors.New("Draft is not valid UTF-8")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data.Draft == text {
+		return nil
+	}
+	c.data.Draft = text
+	c.saveDataDeferred()
+	return nil
+}
+
+// UnreadCount returns the number of unread messages in the conversation
+func (c *Contact) UnreadCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.unreadCount
+}
+
+// setUnreadCount is called by the conversation when its number of unread
+// messages changes, and publishes the new count
+func (c *Contact) setUnreadCount(count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.unreadCount == count {
+		return
+	}
+	c.unreadCount = count
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
+// Diagnostics describes the connection state of the contact for debugging. It
+// only reads state, and never waits for the connection.
+func (c *Contact) Diagnostics() *ricochet.ContactDiagnostics {
+	c.mutex.Lock()
+	diag := &ricochet.ContactDiagnostics{
+		Address:                c.data.Address,
+		Nickname:               c.data.Nickname,
+		Status:                 c.data.Status,
+		ConnectionsEnabled:     c.connEnabled,
+		OutboundAttempting:     c.outboundAttempts > 0,
+		OutboundAuthenticating: c.outboundAuthenticating,
+	}
+	if c.connection != nil {
+		diag.Connected = true
+		diag.Inbound = c.connection.IsInbound
+		diag.WhenConnected = c.timeConnected.Format(time.RFC3339)
+	}
+	c.mutex.Unlock()
+
+	// The conversation is locked separately; it may lock the contact
+	conversation := c.Conversation()
+	queued, oldest := conversation.QueueDepth()
+	diag.QueuedCount = uint32(queued)
+	diag.OldestQueued = oldest
+	diag.UnreadCount = uint32(conversation.UnreadCount())
+	return diag
+}
+
+// Errors from EditRequest
+var (
+	NoOutboundRequestError error = errors.New("Contact has no outbound request")
+	RequestDeliveredError  error = errors.New("Contact request has already been delivered")
+)
+
+// EditRequest changes the nickname and message sent with our contact request,
+// which can only be done before it's delivered. The new values are used for
+// the next delivery attempt. An empty fromNickname sends no nickname.
+func (c *Contact) EditRequest(fromNickname, text string) error {
+	if len(fromNickname) > 0 && !IsNicknameAcceptable(fromNickname) {
+		return errors.New("Invalid 'from' nickname")
+	}
+	if len(text) > 0 && !IsMessageAcceptable(text) {
+		return errors.New("Invalid message")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data.Request == nil || c.data.Request.Direction != ricochet.ContactRequest_OUTBOUND {
+		return NoOutboundRequestError
+	} else if c.data.Request.WhenDelivered != "" || c.data.Request.Rejected || c.requestSending {
+		return RequestDeliveredError
+	}
+	if c.data.Request.FromNickname == fromNickname && c.data.Request.Text == text {
+		return nil
+	}
+	c.data.Request.FromNickname = fromNickname
+	c.data.Request.Text = text
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+		Change: ricochet.ContactEvent_REQUEST,
+	}
+	c.events.Publish(event)
+	return nil
+}
+
+func contactRequestPhase(request *ricochet.ContactRequest) ricochet.ContactRequest_Phase {
+	if request.Rejected {
+		return ricochet.ContactRequest_REJECTED
+	} else if request.RemoteError != "" {
+		return ricochet.ContactRequest_ERROR
+	} else if request.WhenDelivered != "" {
+		return ricochet.ContactRequest_DELIVERED
+	}
+	return ricochet.ContactRequest_UNDELIVERED
+}
+
+func (c *Contact) IsBlocked() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.data.Blocked
+}
+
+// IsRejected returns true if the contact rejected our contact request
+func (c *Contact) IsRejected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.data.Status == ricochet.Contact_REJECTED ||
+		(c.data.Request != nil && c.data.Request.Rejected)
 }
 
 func (c *Contact) IsRequest() bool {
@@ -110,16 +326,42 @@ func (c *Contact) IsRequest() bool {
 	return c.data.Request != nil
 }
 
-func (c *Contact) Conversation() *Conversation {
+// cancelRequest marks the contact as destroyed if it's still an outbound contact
+// request, so a response from the peer can't accept it after it's cancelled.
+// Returns an error if the request has already been accepted. The caller must
+// remove the contact, which finishes destroying it.
+func (c *Contact) cancelRequest() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	if c.conversation == nil {
-		entity := &ricochet.Entity{
-			Address: c.data.Address,
-		}
-		c.conversation = NewConversation(c, entity, c.core.Identity.ConversationStream)
+	if c.data.Request == nil {
+		return errors.New("Contact request has already been accepted")
+	}
+	c.destroyed = true
+	return nil
+}
+
+func (c *Contact) Conversation() *Conversation {
+	c.mutex.Lock()
+	if c.conversation != nil {
+		defer c.mutex.Unlock()
+		return c.conversation
+	}
+	entity := &ricochet.Entity{
+		Address: c.data.Address,
 	}
-	return c.conversation
+	conversation := NewConversation(c, entity, c.core.Identity.ConversationStream)
+	c.conversation = conversation
+	if c.core.History != nil {
+		conversation.loadHistory(c.core.History.Messages(c.data.Address))
+	}
+	c.mutex.Unlock()
+
+	// Loading may have discarded unread messages over the limit, which were
+	// counted when the contact was created
+	conversation.mutex.Lock()
+	conversation.unreadChanged()
+	conversation.mutex.Unlock()
+	return conversation
 }
 
 func (c *Contact) Connection() *connection.Connection {
@@ -135,8 +377,13 @@ func (c *Contact) StartConnection() {
 		go c.contactConnection()
 	})
 
+	c.mutex.Lock()
 	c.connEnabled = true
-	c.connEnabledSignal <- true
+	c.mutex.Unlock()
+	select {
+	case c.connEnabledSignal <- true:
+	case <-c.connStop:
+	}
 }
 
 func (c *Contact) StopConnection() {
@@ -145,16 +392,154 @@ func (c *Contact) StopConnection() {
 		go c.contactConnection()
 	})
 
+	c.mutex.Lock()
 	c.connEnabled = false
-	c.connEnabledSignal <- false
+	c.mutex.Unlock()
+	select {
+	case c.connEnabledSignal <- false:
+	case <-c.connStop:
+	}
+}
+
+// RestartOutboundConnection cancels an outbound connection attempt in progress
+// and starts a new one, which reads the contact's hostname and the network's
+// proxy settings again. An established connection is kept.
+func (c *Contact) RestartOutboundConnection() {
+	c.mutex.Lock()
+	attempting := c.outboundAttempts > 0
+	c.mutex.Unlock()
+	if !attempting {
+		// Nothing is running with the old settings
+		return
+	}
+
+	// A nil connection makes the connection loop stop its outbound connector and
+	// start another, if one is still needed
+	select {
+	case c.connChannel <- nil:
+	case <-c.connStop:
+	}
+}
+
+// Connect starts outbound connection attempts to a dormant contact, which
+// continue until the backend is restarted. It has no effect on other contacts.
+func (c *Contact) Connect() {
+	c.mutex.Lock()
+	if !c.data.Dormant || c.connectRequested {
+		c.mutex.Unlock()
+		return
+	}
+	c.connectRequested = true
+	c.mutex.Unlock()
+
+	// A nil connection makes the connection loop start an outbound connector
+	select {
+	case c.connChannel <- nil:
+	case <-c.connStop:
+	}
+}
+
+// ConnectOnStartup returns false if the contact is dormant, and isn't connected
+// when the backend starts
+func (c *Contact) ConnectOnStartup() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.data.Dormant
+}
+
+// SetConnectOnStartup chooses whether outbound connections to the contact are
+// made when the backend starts. A contact made dormant stays connected until
+// the next restart; one that's no longer dormant is connected right away.
+func (c *Contact) SetConnectOnStartup(enabled bool) {
+	c.mutex.Lock()
+	if c.data.Dormant == !enabled {
+		c.mutex.Unlock()
+		return
+	}
+	c.data.Dormant = !enabled
+	// Don't change the connections of this session
+	c.connectRequested = !enabled
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+	c.mutex.Unlock()
+
+	if enabled {
+		select {
+		case c.connChannel <- nil:
+		case <-c.connStop:
+		}
+	}
+}
+
+// destroy is the same as shutdown, but data changes after this point are not
+// saved to the config. The contact must not be used afterwards.
+func (c *Contact) destroy() {
+	c.mutex.Lock()
+	c.destroyed = true
+	c.mutex.Unlock()
+
+	c.shutdown()
+}
+
+// shutdown permanently stops all connections to the contact, closing any active
+// connection, and waits for the connection goroutine to exit. Unlike
+// StopConnection, connections can't be started again afterwards.
+//
+// This blocks until the active connection has closed, so c.mutex must not be held.
+func (c *Contact) shutdown() {
+	c.connectionOnce.Do(func() {
+		go c.contactConnection()
+	})
+	c.connStopOnce.Do(func() {
+		close(c.connStop)
+	})
+	<-c.connStopped
+}
+
+// Write the contact's data to the config, unless it has been destroyed.
+// Assumes c.mutex is held.
+func (c *Contact) saveData() {
+	if c.destroyed {
+		return
+	}
+	config := c.core.Config.Lock()
+	config.Contacts[c.data.Address] = c.data
+	c.core.Config.Unlock()
+}
+
+// Same as saveData, but the config file is written later, combined with other
+// changes. Used for changes that are unimportant if lost, like LastConnected.
+// Assumes c.mutex is held.
+func (c *Contact) saveDataDeferred() {
+	if c.destroyed {
+		return
+	}
+	config := c.core.Config.Lock()
+	config.Contacts[c.data.Address] = c.data
+	c.core.Config.UnlockDeferred()
 }
 
 func (c *Contact) shouldMakeOutboundConnections() bool {
+	if c.core.connectionMode() != ricochet.ConnectionMode_ONLINE {
+		return false
+	}
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	// Don't make connections to contacts in the REJECTED state
-	if c.data.Status == ricochet.Contact_REJECTED {
+	if c.data.Status == ricochet.Contact_REJECTED || c.data.Blocked {
+		return false
+	}
+	if c.data.Dormant && !c.connectRequested {
 		return false
 	}
 
@@ -178,31 +563,69 @@ func closeUnhandledConnection(conn *connection.Connection) {
 // reacting to connection loss. Nothing else may write Contact.connection.
 //
 // This goroutine is started by the first call to StartConnection or StopConnection
-// and persists for the lifetime of the contact. When connections are stopped, it
-// consumes connChannel and closes all (presumably inbound) connections.
-// XXX Need a hard kill for destroying contacts
+// and persists until the contact is destroyed. When connections are stopped, it
+// closes the active connection, consumes connChannel and closes all (presumably
+// inbound) connections.
+//
+// The only way a closed connection is reported is by its handleConnection sending
+// it on connClosedChannel, which this goroutine owns and always receives from, so
+// there is no other path that could race with stopping or replacing connections.
 func (c *Contact) contactConnection() {
-	// Signalled when the active connection is closed
-	connClosedChannel := make(chan struct{})
+	defer close(c.connStopped)
+	// Sent the connection by its handleConnection goroutine when it's closed
+	connClosedChannel := make(chan *connection.Connection)
 	connectionsEnabled := false
+	// Sent authentication failures by the outbound connector
+	authFailedChannel := make(chan error)
+	// Number of consecutive outbound attempts that failed authentication, counted
+	// by countOutboundFailure. Used as the starting point for the backoff of the
+	// next outbound connector, so these aren't retried at full speed.
+	outboundFailures := 0
+	// Cancels the running outbound connector, if any. This persists across iterations,
+	// because an outbound attempt may be allowed to continue after an inbound
+	// connection is accepted.
+	var outboundCancel context.CancelFunc
+	stopOutbound := func() {
+		if outboundCancel != nil {
+			outboundCancel()
+			outboundCancel = nil
+		}
+	}
+	// Clears c.connection when its handleConnection has returned
+	connectionClosed := func(conn *connection.Connection) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if conn != c.connection {
+			// Not possible while only the active connection has a handler
+			c.core.Log.Debugf("Ignoring close of an inactive connection to %s", c.data.Address)
+			return
+		}
+		c.connection = nil
+		c.onConnectionStateChanged()
+	}
 
+connectionLoop:
 	for {
 		if !connectionsEnabled {
 			// Reject all connections on connChannel and wait for start signal
 			select {
 			case conn := <-c.connChannel:
 				if conn != nil {
-					log.Printf("Discarded connection to %s because connections are disabled", c.Address())
+					c.core.Log.Infof("Discarded connection to %s because connections are disabled", c.Address())
 					go closeUnhandledConnection(conn)
 					// XXX-protocol doing this here instead of during auth means they'll keep trying endlessly. Doing it in
 					// auth means they'll never try again. Both are sometimes wrong. Hmm.
 				}
 			case enable := <-c.connEnabledSignal:
 				if enable {
-					log.Printf("Contact %s connections are enabled", c.Address())
+					c.core.Log.Debugf("Contact %s connections are enabled", c.Address())
 					connectionsEnabled = true
 				}
-				// XXX hard kill
+			case conn := <-connClosedChannel:
+				// The connection closed when connections were disabled
+				connectionClosed(conn)
+			case <-c.connStop:
+				break connectionLoop
 			}
 			continue
 		}
@@ -210,16 +633,18 @@ func (c *Contact) contactConnection() {
 		// If there is no active connection, spawn an outbound connector. A successful connection
 		// is returned via connChannel, and otherwise it will keep trying until cancelled via
 		// the context.
-		var outboundCtx context.Context
-		outboundCancel := func() {}
-		if c.connection == nil && c.shouldMakeOutboundConnections() {
+		if outboundCancel == nil && c.connection == nil && c.shouldMakeOutboundConnections() {
+			var outboundCtx context.Context
 			outboundCtx, outboundCancel = context.WithCancel(context.Background())
-			go c.connectOutbound(outboundCtx, c.connChannel)
+			go c.connectOutbound(outboundCtx, outboundFailures, c.connChannel, authFailedChannel)
 		}
 
 		select {
 		case conn := <-c.connChannel:
-			outboundCancel()
+			if conn == nil || !conn.IsInbound {
+				// Either way, the outbound connector has finished
+				stopOutbound()
+			}
 			if conn == nil {
 				// Signal used to restart outbound connection attempts
 				continue
@@ -230,15 +655,23 @@ func (c *Contact) contactConnection() {
 			// already closed. If there was an existing connection and this returns nil,
 			// the old connection is closed but c.connection has not been reset.
 			if err := c.considerUsingConnection(conn); err != nil {
-				log.Printf("Discarded new contact %s connection: %s", c.data.Address, err)
+				c.core.Log.Warnf("Discarded new contact %s connection: %s", c.data.Address, err)
 				go closeUnhandledConnection(conn)
+				if !conn.IsInbound {
+					outboundFailures = countOutboundFailure(outboundFailures, err)
+				}
 				c.mutex.Unlock()
 				continue
 			}
+			outboundFailures = 0
+			if conn.IsInbound && !c.shouldKeepOutboundAttempt(conn) {
+				stopOutbound()
+			}
 			replacingConn := c.connection != nil
 			c.connection = conn
 			if replacingConn {
-				// Wait for old handleConnection to return
+				// Wait for old handleConnection to return; it's no longer the
+				// active connection, so there is nothing else to do with it
 				c.mutex.Unlock()
 				<-connClosedChannel
 				c.mutex.Lock()
@@ -247,23 +680,33 @@ func (c *Contact) contactConnection() {
 			c.onConnectionStateChanged()
 			c.mutex.Unlock()
 
-		case <-connClosedChannel:
-			outboundCancel()
-			c.mutex.Lock()
-			c.connection = nil
-			c.onConnectionStateChanged()
-			c.mutex.Unlock()
+		case conn := <-connClosedChannel:
+			stopOutbound()
+			connectionClosed(conn)
+
+		case err := <-authFailedChannel:
+			outboundFailures = countOutboundFailure(outboundFailures, err)
 
 		case enable := <-c.connEnabledSignal:
-			outboundCancel()
+			stopOutbound()
 			if !enable {
 				connectionsEnabled = false
-				log.Printf("Contact %s connections are disabled", c.Address())
+				c.core.Log.Debugf("Contact %s connections are disabled", c.Address())
+				// Close the active connection; it's reported on connClosedChannel
+				c.mutex.Lock()
+				if c.connection != nil {
+					c.connection.Conn.Close()
+				}
+				c.mutex.Unlock()
 			}
+
+		case <-c.connStop:
+			break connectionLoop
 		}
 	}
 
-	log.Printf("Exiting contact connection loop for %s", c.Address())
+	stopOutbound()
+	c.core.Log.Debugf("Exiting contact connection loop for %s", c.Address())
 	c.mutex.Lock()
 	if c.connection != nil {
 		c.connection.Conn.Close()
@@ -276,38 +719,93 @@ func (c *Contact) contactConnection() {
 	}
 }
 
+// countOutboundFailure returns the number of consecutive failed outbound attempts
+// after one that ended with err. Only failures of authentication are counted;
+// anything else, like the connection closing, leaves the count unchanged.
+func countOutboundFailure(failures int, err error) int {
+	switch err {
+	case ricochetutils.ServerRejectedClientConnectionError, ricochetutils.ClientFailedToAuthenticateError,
+		ConnectionNotAuthenticatedError:
+		return failures + 1
+	}
+	return failures
+}
+
 // Goroutine to maintain an open contact connection, calls Process and reports when closed.
-func (c *Contact) handleConnection(conn *connection.Connection, closedChannel chan struct{}) {
+func (c *Contact) handleConnection(conn *connection.Connection, closedChannel chan<- *connection.Connection) {
+	keepaliveDone := make(chan struct{})
 	// Connection does not outlive this function
 	defer func() {
+		close(keepaliveDone)
 		conn.Conn.Close()
-		closedChannel <- struct{}{}
+		closedChannel <- conn
 	}()
-	log.Printf("Contact connection for %s ready", conn.RemoteHostname)
+	c.core.Log.Infof("Contact connection for %s ready", conn.RemoteHostname)
+	go c.keepaliveConnection(conn, keepaliveDone)
 	handler := NewContactProtocolHandler(c, conn)
 	err := conn.Process(handler)
 	if err == nil {
 		// Somebody called Break?
 		err = fmt.Errorf("Connection handler interrupted unexpectedly")
 	}
-	log.Printf("Contact connection for %s closed: %s", conn.RemoteHostname, err)
+	c.core.Log.Infof("Contact connection for %s closed: %s", conn.RemoteHostname, err)
+	c.core.Identity.FileTransfers().connectionClosed(conn)
 }
 
 // Attempt an outbound connection to the contact, retrying automatically using OnionConnector.
 // This function _must_ send something to connChannel before returning, unless the context has
 // been cancelled.
-func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connection.Connection) {
+//
+// previousFailures is the number of earlier attempts which failed authentication. If
+// non-zero, the backoff schedule continues from that point and the first attempt is
+// delayed. Authentication failures are sent to authFailed, to be counted for the next
+// outbound connector.
+func (c *Contact) connectOutbound(ctx context.Context, previousFailures int, connChannel chan *connection.Connection, authFailed chan<- error) {
 	c.mutex.Lock()
-	connector := OnionConnector{
-		Network:     c.core.Network,
-		NeverGiveUp: true,
+	c.outboundAttempts++
+	defer func() {
+		c.mutex.Lock()
+		c.outboundAttempts--
+		// However the attempt ended, it's no longer authenticating
+		c.outboundAuthenticating = false
+		c.mutex.Unlock()
+		c.setConnecting(false)
+	}()
+	connector := c.core.newOnionConnector()
+	connector.NeverGiveUp = true
+	connector.AttemptStateChanged = c.setConnecting
+	if c.core.IsolateContactCircuits {
+		connector.IsolationKey = c.data.Address
 	}
 	hostname, _ := OnionFromAddress(c.data.Address)
 	isRequest := c.data.Request != nil
 	c.mutex.Unlock()
+	address := net.JoinHostPort(hostname, strconv.Itoa(c.core.ServicePort))
+
+	if previousFailures > 0 {
+		connector.AttemptCount = previousFailures - 1
+		c.core.Log.Debugf("Delaying outbound connection to %s after %d failed attempts", hostname, previousFailures)
+		if err := connector.Backoff(ctx); err != nil {
+			return
+		}
+	}
 
 	for {
-		conn, err := connector.Connect(hostname+":9878", ctx)
+		c.mutex.Lock()
+		c.outboundAuthenticating = false
+		connected := c.connection != nil
+		c.mutex.Unlock()
+		if connected {
+			// An attempt that was kept running alongside a new inbound connection
+			// has failed; don't keep trying while that connection is up.
+			select {
+			case connChannel <- nil:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		conn, err := connector.Connect(address, ctx)
 		if err != nil {
 			// The only failure here should be context, because NeverGiveUp
 			// is set, but be robust anyway.
@@ -315,16 +813,16 @@ func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connect
 				return
 			}
 
-			log.Printf("Contact connection failure: %s", err)
+			c.core.Log.Warnf("Contact connection failure: %s", err)
 			continue
 		}
 
 		// XXX-protocol Ideally this should all take place under ctx also; easy option is a goroutine
 		// blocked on ctx that kills the connection.
-		log.Printf("Successful outbound connection to contact %s", hostname)
-		oc, err := protocol.NegotiateVersionOutbound(conn, hostname[0:16])
+		c.core.Log.Debugf("Successful outbound connection to contact %s", hostname)
+		oc, err := protocol.NegotiateVersionOutbound(newActivityConn(conn), strings.TrimSuffix(hostname, ".onion"))
 		if err != nil {
-			log.Printf("Outbound connection version negotiation failed: %v", err)
+			c.core.Log.Warnf("Outbound connection version negotiation failed: %v", err)
 			conn.Close()
 			if err := connector.Backoff(ctx); err != nil {
 				return
@@ -332,12 +830,21 @@ func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connect
 			continue
 		}
 
-		log.Printf("Outbound connection negotiated version; authenticating")
-		privateKey := c.core.Identity.PrivateKey()
-		known, err := connection.HandleOutboundConnection(oc).ProcessAuthAsClient(&privateKey)
+		c.core.Log.Debugf("Outbound connection negotiated version; authenticating")
+		c.setOutboundAuthenticating(true)
+		known, err := authenticateOutbound(oc, c.core.Identity.PrivateKey())
 		if err != nil {
-			log.Printf("Outbound connection authentication failed: %v", err)
+			c.core.Log.Warnf("Outbound connection authentication failed: %v", err)
+			c.core.Audit.Record(ricochet.AuditEntry_AUTHENTICATION_FAILED, c.Address(), "outbound: "+err.Error())
 			closeUnhandledConnection(oc)
+			if err == ricochetutils.ServerRejectedClientConnectionError {
+				c.setAuthenticationRejected()
+			}
+			select {
+			case authFailed <- err:
+			case <-ctx.Done():
+				return
+			}
 			if err := connector.Backoff(ctx); err != nil {
 				return
 			}
@@ -345,16 +852,19 @@ func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connect
 		}
 
 		if !known && !isRequest {
-			log.Printf("Outbound connection to contact says we are not a known contact for %v", c)
-			// XXX Should move to rejected status, stop attempting connections.
+			c.core.Log.Infof("Outbound connection to contact says we are not a known contact for %v", c)
 			closeUnhandledConnection(oc)
-			if err := connector.Backoff(ctx); err != nil {
-				return
+			// The peer has removed us; stop attempting connections until they
+			// connect to us again or the contact is re-added.
+			c.setRemovedByPeer()
+			select {
+			case connChannel <- nil:
+			case <-ctx.Done():
 			}
-			continue
+			return
 		} else if known && isRequest {
-			log.Printf("Contact request implicitly accepted for outbound connection by contact %v", c)
-			c.UpdateContactRequest("Accepted")
+			c.core.Log.Infof("Contact request implicitly accepted for outbound connection by contact %v", c)
+			c.UpdateContactRequest("Accepted", "")
 			isRequest = false
 		}
 
@@ -362,17 +872,17 @@ func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connect
 			// Need to send a contact request; this will block until the peer accepts or rejects,
 			// the connection fails, or the context is cancelled (which also closes the connection).
 			if err := c.sendContactRequest(oc, ctx); err != nil {
-				log.Printf("Outbound contact request connection closed: %s", err)
+				c.core.Log.Infof("Outbound contact request connection closed: %s", err)
 				if err := connector.Backoff(ctx); err != nil {
 					return
 				}
 				continue
 			} else {
-				log.Printf("Outbound contact request accepted, assigning connection")
+				c.core.Log.Debugf("Outbound contact request accepted, assigning connection")
 			}
 		}
 
-		log.Printf("Assigning outbound connection to contact")
+		c.core.Log.Debugf("Assigning outbound connection to contact")
 		c.AssignConnection(oc)
 		break
 	}
@@ -380,15 +890,24 @@ func (c *Contact) connectOutbound(ctx context.Context, connChannel chan *connect
 
 type requestChannelHandler struct {
 	Response chan string
+	Channel  *contactRequestChannel
+	// Reason given by the contact for a rejection, set before the response is sent
+	Reason string
 }
 
 func (r *requestChannelHandler) ContactRequest(name, message string) string {
 	log.Printf("BUG: inbound ContactRequest handler called for outbound channel")
 	return "Error"
 }
-func (r *requestChannelHandler) ContactRequestRejected() { r.Response <- "Rejected" }
+func (r *requestChannelHandler) ContactRequestRejected() {
+	r.Reason = r.Channel.Reason
+	r.Response <- "Rejected"
+}
 func (r *requestChannelHandler) ContactRequestAccepted() { r.Response <- "Accepted" }
-func (r *requestChannelHandler) ContactRequestError()    { r.Response <- "Error" }
+func (r *requestChannelHandler) ContactRequestError() {
+	r.Reason = r.Channel.Reason
+	r.Response <- "Error"
+}
 
 // sendContactRequest synchronously delivers a contact request to an authenticated
 // outbound connection and waits for a final (yes/no) reply. This may be cancelled
@@ -397,7 +916,7 @@ func (r *requestChannelHandler) ContactRequestError()    { r.Response <- "Error"
 // returned for an accepted request when the connection is still established. In all
 // other cases, an error is returned and the connection will be closed.
 func (c *Contact) sendContactRequest(conn *connection.Connection, ctx context.Context) error {
-	log.Printf("Sending request to outbound contact %v", c)
+	c.core.Log.Infof("Sending request to outbound contact %v", c)
 	ach := &connection.AutoConnectionHandler{}
 	ach.Init()
 
@@ -409,13 +928,25 @@ func (c *Contact) sendContactRequest(conn *connection.Connection, ctx context.Co
 		processChan <- conn.Process(ach)
 	}()
 
+	// The nickname we present to this contact is chosen for each request, and
+	// is not sent at all if empty
+	c.mutex.Lock()
+	fromNickname, text := c.data.Request.FromNickname, c.data.Request.Text
+	c.requestSending = true
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		c.requestSending = false
+		c.mutex.Unlock()
+	}()
+	handler := &requestChannelHandler{Response: responseChan}
+	handler.Channel = &contactRequestChannel{
+		handler: handler,
+		Name:    fromNickname,
+		Message: text,
+	}
 	err := conn.Do(func() error {
-		_, err := conn.RequestOpenChannel("im.ricochet.contact.request",
-			&channels.ContactRequestChannel{
-				Handler: &requestChannelHandler{Response: responseChan},
-				Name:    c.data.Request.FromNickname, // XXX mutex
-				Message: c.data.Request.Text,
-			})
+		_, err := conn.RequestOpenChannel(contactRequestChannelType, handler.Channel)
 		return err
 	})
 	if err != nil {
@@ -434,7 +965,7 @@ func (c *Contact) sendContactRequest(conn *connection.Connection, ctx context.Co
 		return err
 
 	case response := <-responseChan:
-		c.UpdateContactRequest(response)
+		c.UpdateContactRequest(response, handler.Reason)
 		if response == "Accepted" {
 			conn.Break()
 			return <-processChan // nil if connection is still alive
@@ -449,6 +980,10 @@ func (c *Contact) sendContactRequest(conn *connection.Connection, ctx context.Co
 	}
 }
 
+// ConnectionNotAuthenticatedError is returned by considerUsingConnection for a
+// connection that hasn't proven it's from the contact
+var ConnectionNotAuthenticatedError error = errors.New("Connection is not authenticated as the contact")
+
 // considerUsingConnection takes a newly established connection and decides whether
 // the new connection is valid and acceptable, and whether to replace or keep an
 // existing connection. To handle race cases when peers are connecting to eachother,
@@ -469,89 +1004,206 @@ func (c *Contact) considerUsingConnection(conn *connection.Connection) error {
 	}()
 
 	if conn.IsInbound {
-		log.Printf("Contact %s has a new inbound connection", c.data.Address)
+		c.core.Log.Infof("Contact %s has a new inbound connection", c.data.Address)
 	} else {
-		log.Printf("Contact %s has a new outbound connection", c.data.Address)
+		c.core.Log.Infof("Contact %s has a new outbound connection", c.data.Address)
 	}
 
 	if conn == c.connection {
 		return fmt.Errorf("Duplicate assignment of connection %v to contact %v", conn, c)
 	}
 
+	if c.data.Blocked {
+		return fmt.Errorf("Contact is blocked")
+	}
+
 	if !conn.Authentication["im.ricochet.auth.hidden-service"] {
-		return fmt.Errorf("Connection %v is not authenticated", conn)
+		c.core.Log.Warnf("Connection %v to contact %s has not authenticated", conn, c.data.Address)
+		return ConnectionNotAuthenticatedError
+	}
+
+	if selfHost, _ := PlainHostFromAddress(c.core.Identity.Address()); conn.RemoteHostname == selfHost {
+		c.core.Log.Warnf("Connection %v to contact %s is authenticated as our own hostname", conn, c.data.Address)
+		return ConnectionNotAuthenticatedError
 	}
 
 	plainHost, _ := PlainHostFromAddress(c.data.Address)
 	if plainHost != conn.RemoteHostname {
-		return fmt.Errorf("Connection hostname %s doesn't match contact hostname %s when assigning connection", conn.RemoteHostname, plainHost)
+		c.core.Log.Warnf("Connection hostname %s doesn't match contact hostname %s when assigning connection", conn.RemoteHostname, plainHost)
+		return ConnectionNotAuthenticatedError
 	}
 
 	if c.connection != nil && !c.shouldReplaceConnection(conn) {
 		return fmt.Errorf("Using existing connection")
 	}
 
-	// If this connection is inbound and there's an outbound attempt, keep this
-	// connection and cancel outbound if we haven't sent authentication yet, or
-	// if the outbound connection will lose the fallback comparison above.
-	// XXX implement this; currently outbound is always cancelled when an inbound
-	// connection succeeds.
+	// If this connection is inbound and there's an outbound attempt, the connection
+	// loop uses shouldKeepOutboundAttempt to decide whether to cancel the outbound.
 
 	// We will keep conn, close c.connection instead if there was one
 	killConn = c.connection
 	return nil
 }
 
+// shouldKeepOutboundAttempt decides whether an outbound connection attempt that is
+// in progress should continue after the inbound connection conn has been accepted.
+// The outbound attempt is cancelled if it hasn't sent authentication yet, or if it
+// would lose the fallback comparison in shouldReplaceConnection against conn once
+// it completed.
+//
+// Assumes c.mutex is held.
+func (c *Contact) shouldKeepOutboundAttempt(conn *connection.Connection) bool {
+	if !c.outboundAuthenticating {
+		return false
+	}
+	myHostname, _ := PlainHostFromAddress(c.core.Identity.Address())
+	preferOutbound := preferOutboundConnection(myHostname, conn.RemoteHostname)
+	if preferOutbound {
+		c.core.Log.Debugf("Keeping outbound connection attempt to contact %v, which will replace the new inbound connection", c)
+	}
+	return preferOutbound
+}
+
+func (c *Contact) setOutboundAuthenticating(authenticating bool) {
+	c.mutex.Lock()
+	c.outboundAuthenticating = authenticating
+	c.mutex.Unlock()
+}
+
+// setConnecting shows an offline contact as CONNECTING while an outbound attempt
+// is in progress, and as OFFLINE again once the attempt has failed. The status
+// only changes when an attempt actually starts, and stays CONNECTING for the
+// rest of it, including negotiating and authenticating the connection, so it
+// doesn't flap while the connector is waiting for the network or the backoff.
+func (c *Contact) setConnecting(connecting bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	oldStatus := c.data.Status
+	if connecting && c.connection == nil &&
+		(oldStatus == ricochet.Contact_UNKNOWN || oldStatus == ricochet.Contact_OFFLINE) {
+		c.data.Status = ricochet.Contact_CONNECTING
+	} else if !connecting && oldStatus == ricochet.Contact_CONNECTING {
+		c.data.Status = ricochet.Contact_OFFLINE
+	} else {
+		return
+	}
+
+	// Not worth saving; a saved CONNECTING status is reset when contacts are loaded
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
 // onConnectionStateChanged is called by the connection loop when the c.connection
 // is changed, which can be a transition to online or offline or a replacement.
 // Assumes c.mutex is held.
 func (c *Contact) onConnectionStateChanged() {
+	oldStatus := c.data.Status
+	change := ricochet.ContactEvent_OTHER
 	if c.connection != nil {
 		if c.data.Request != nil && c.connection.IsInbound {
 			// Inbound connection implicitly accepts the contact request and can continue as a contact
 			// Outbound request logic is all handled by connectOutbound.
-			log.Printf("Contact request implicitly accepted by contact %v", c)
-			c.updateContactRequest("Accepted")
+			c.core.Log.Infof("Contact request implicitly accepted by contact %v", c)
+			c.updateContactRequest("Accepted", "")
+			change = ricochet.ContactEvent_REQUEST_ACCEPTED
 		} else {
 			c.data.Status = ricochet.Contact_ONLINE
 		}
+		c.data.AuthenticationRejected = ""
 	} else {
-		if c.data.Status == ricochet.Contact_ONLINE {
+		if c.data.Status == ricochet.Contact_ONLINE && !c.data.Blocked {
 			c.data.Status = ricochet.Contact_OFFLINE
 		}
+		c.remotePresence = nil
 	}
 
+	c.silent = false
+
 	// Update LastConnected time
 	c.timeConnected = time.Now()
 	c.data.LastConnected = c.timeConnected.Format(time.RFC3339)
 
-	config := c.core.Config.Lock()
-	config.Contacts[c.data.Address] = c.data
-	c.core.Config.Unlock()
+	// Only authenticated connections to the contact are assigned to
+	// c.connection, so failed attempts don't count towards online time
+	c.data.LastSeen = c.data.LastConnected
+	if !c.onlineSince.IsZero() && c.connection == nil {
+		c.data.OnlineSeconds += int64(c.timeConnected.Sub(c.onlineSince).Seconds())
+		c.onlineSince = time.Time{}
+		c.core.Audit.Record(ricochet.AuditEntry_CONNECTION_LOST, c.data.Address, "")
+	} else if c.onlineSince.IsZero() && c.connection != nil {
+		c.onlineSince = c.timeConnected
+		direction := "outbound"
+		if c.connection.IsInbound {
+			direction = "inbound"
+		}
+		c.core.Audit.Record(ricochet.AuditEntry_CONNECTION_ESTABLISHED, c.data.Address, direction)
+	}
 
-	// XXX I wonder if events and config updates can be combined now, and made safer...
-	// _really_ assumes c.mutex was held
-	c.mutex.Unlock()
+	// Connections can change often, so only write these changes periodically.
+	// An accepted request has already been saved by updateContactRequest.
+	c.saveDataDeferred()
+
+	if change == ricochet.ContactEvent_OTHER {
+		change = contactStatusChange(oldStatus, c.data.Status)
+	}
 	event := ricochet.ContactEvent{
 		Type: ricochet.ContactEvent_UPDATE,
 		Subject: &ricochet.ContactEvent_Contact{
-			Contact: c.Data(),
+			Contact: c.dataLocked(),
 		},
+		Change: change,
 	}
+
+	// XXX I wonder if events and config updates can be combined now, and made safer...
+	// _really_ assumes c.mutex was held
+	c.mutex.Unlock()
 	c.events.Publish(event)
 
 	if c.connection != nil {
 		// Send any queued messages
 		sent := c.Conversation().SendQueuedMessages()
 		if sent > 0 {
-			log.Printf("Sent %d queued messages to contact", sent)
+			c.core.Log.Infof("Sent %d queued messages to contact", sent)
+		}
+		// Offer pending file transfers, and resume interrupted ones
+		c.core.Identity.FileTransfers().contactConnected(c)
+		if err := c.sendPresence(c.core.Identity.Presence()); err != nil {
+			c.core.Log.Warnf("Sending presence to contact failed: %v", err)
 		}
+	} else {
+		c.Conversation().resetTyping()
 	}
 
 	c.mutex.Lock()
 }
 
+// preferOutboundConnection decides which of two connections in opposite
+// directions between the same peers is kept: the one made by the peer with
+// the lower hostname. That peer prefers its outbound connection, and the other
+// peer, evaluating the same comparison with the hostnames swapped, prefers its
+// inbound connection, which is the same connection. Both peers always keep the
+// same connection and close the other, so they never both drop a connection
+// or both keep one that the other has closed.
+func preferOutboundConnection(myHostname, remoteHostname string) bool {
+	return myHostname < remoteHostname
+}
+
 // Decide whether to replace the existing connection with conn.
+//
+// Connections in the same direction replace each other, because the peer has
+// only opened a new one if it's given up on the old. Otherwise, connections are
+// replaced after 30 seconds, in case the peer has lost the old connection without
+// us noticing; within that time, preferOutboundConnection decides consistently on
+// both peers. Peers evaluate the age separately, so a new connection arriving at
+// very nearly 30 seconds may be chosen differently by each side, closing both;
+// the connection is then remade as usual.
+//
 // Assumes mutex is held.
 func (c *Contact) shouldReplaceConnection(conn *connection.Connection) bool {
 	myHostname, _ := PlainHostFromAddress(c.core.Identity.Address())
@@ -559,42 +1211,280 @@ func (c *Contact) shouldReplaceConnection(conn *connection.Connection) bool {
 		return true
 	} else if c.connection.IsInbound == conn.IsInbound {
 		// If the existing connection is in the same direction, always use the new one
-		log.Printf("Replacing existing same-direction connection %v with new connection %v for contact %v", c.connection, conn, c)
+		c.core.Log.Debugf("Replacing existing same-direction connection %v with new connection %v for contact %v", c.connection, conn, c)
 		return true
 	} else if time.Since(c.timeConnected) > (30 * time.Second) {
 		// If the existing connection is more than 30 seconds old, use the new one
-		log.Printf("Replacing existing %v old connection %v with new connection %v for contact %v", time.Since(c.timeConnected), c.connection, conn, c)
+		c.core.Log.Debugf("Replacing existing %v old connection %v with new connection %v for contact %v", time.Since(c.timeConnected), c.connection, conn, c)
 		return true
-	} else if preferOutbound := myHostname < conn.RemoteHostname; preferOutbound != conn.IsInbound {
+	} else if preferOutbound := preferOutboundConnection(myHostname, conn.RemoteHostname); preferOutbound != conn.IsInbound {
 		// Fall back to string comparison of hostnames for a stable resolution
 		// New connection wins
-		log.Printf("Replacing existing connection %v with new connection %v for contact %v according to fallback order", c.connection, conn, c)
+		c.core.Log.Debugf("Replacing existing connection %v with new connection %v for contact %v according to fallback order", c.connection, conn, c)
 		return true
 	} else {
 		// Old connection wins fallback
-		log.Printf("Keeping existing connection %v instead of new connection %v for contact %v according to fallback order", c.connection, conn, c)
+		c.core.Log.Debugf("Keeping existing connection %v instead of new connection %v for contact %v according to fallback order", c.connection, conn, c)
 		return false
 	}
 	return false
 }
 
-// Update the status of a contact request from a protocol event. Returns
-// true if the contact request channel should remain open.
-func (c *Contact) UpdateContactRequest(status string) bool {
+// SetNickname changes the local nickname of the contact
+func (c *Contact) SetNickname(nickname string) error {
+	if !IsNicknameAcceptable(nickname) {
+		return errors.New("Invalid nickname")
+	}
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	if c.data.Nickname == nickname {
+		return nil
+	}
+	c.data.Nickname = nickname
 
-	if c.data.Request == nil {
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+	return nil
+}
+
+// SetOrder changes whether the contact is pinned and its sort index, which
+// determine its position in the contact list.
+func (c *Contact) SetOrder(pinned bool, sortIndex int32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data.Pinned == pinned && c.data.SortIndex == sortIndex {
+		return
+	}
+	c.data.Pinned = pinned
+	c.data.SortIndex = sortIndex
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
+// Muted returns true if notifications of messages from the contact are muted
+func (c *Contact) Muted() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.data.Muted
+}
+
+// SetMuted mutes or unmutes notifications of messages from the contact.
+// Messages are still received and stored while muted.
+func (c *Contact) SetMuted(muted bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data.Muted == muted {
+		return
+	}
+	c.data.Muted = muted
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
+// Returns true if the contact is listed before other, according to the
+// pinned and sortIndex fields. Assumes neither mutex is held.
+func (c *Contact) sortsBefore(other *Contact) bool {
+	c.mutex.Lock()
+	pinned, index, nickname := c.data.Pinned, c.data.SortIndex, c.data.Nickname
+	c.mutex.Unlock()
+	other.mutex.Lock()
+	otherPinned, otherIndex, otherNickname := other.data.Pinned, other.data.SortIndex, other.data.Nickname
+	other.mutex.Unlock()
+
+	if pinned != otherPinned {
+		return pinned
+	} else if index != otherIndex {
+		return index < otherIndex
+	}
+	return nickname < otherNickname
+}
+
+// SetBlocked blocks or unblocks the contact. Blocking closes any active connection
+// and prevents all connections to or from the contact until it's unblocked.
+func (c *Contact) SetBlocked(blocked bool) {
+	c.mutex.Lock()
+	if c.data.Blocked == blocked {
+		c.mutex.Unlock()
+		return
+	}
+
+	oldStatus := c.data.Status
+	c.data.Blocked = blocked
+	if blocked {
+		c.data.Status = ricochet.Contact_BLOCKED
+		if c.connection != nil {
+			// The connection loop will notice the connection closing
+			c.connection.Conn.Close()
+		}
+	} else if c.data.Request != nil {
+		if c.data.Request.Rejected {
+			c.data.Status = ricochet.Contact_REJECTED
+		} else {
+			c.data.Status = ricochet.Contact_REQUEST
+		}
+	} else {
+		c.data.Status = ricochet.Contact_UNKNOWN
+	}
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+		Change: contactStatusChange(oldStatus, c.data.Status),
+	}
+	c.events.Publish(event)
+	enabled := c.connEnabled
+	c.mutex.Unlock()
+
+	if !blocked && enabled {
+		// Restart outbound connection attempts
+		c.StartConnection()
+	}
+}
+
+// setRemovedByPeer moves a contact to the REJECTED status after the peer has
+// told us that we are not a known contact. Outbound connections are no longer
+// attempted in this status, but an inbound connection from the contact will
+// bring it back online.
+func (c *Contact) setRemovedByPeer() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.data.Status == ricochet.Contact_REJECTED {
+		return
+	}
+	oldStatus := c.data.Status
+	c.data.Status = ricochet.Contact_REJECTED
+
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+		Change: contactStatusChange(oldStatus, c.data.Status),
+	}
+	c.events.Publish(event)
+}
+
+// setAuthenticationRejected records that the contact refused our authentication,
+// which is reported until the next successful connection. Attempts continue
+// with backoff, because the contact may change their mind.
+func (c *Contact) setAuthenticationRejected() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	firstRejection := c.data.AuthenticationRejected == ""
+	c.data.AuthenticationRejected = time.Now().Format(time.RFC3339)
+	if !firstRejection {
+		c.saveDataDeferred()
+		return
+	}
+	c.core.Log.Warnf("Contact %s rejected our authentication", c.data.Address)
+	c.saveData()
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+		Change: ricochet.ContactEvent_AUTHENTICATION_REJECTED,
+	}
+	c.events.Publish(event)
+}
+
+// contactStatusChange describes a change of contact status for ContactEvent.Change
+func contactStatusChange(oldStatus, newStatus ricochet.Contact_Status) ricochet.ContactEvent_Change {
+	if oldStatus != ricochet.Contact_ONLINE && newStatus == ricochet.Contact_ONLINE {
+		return ricochet.ContactEvent_CAME_ONLINE
+	} else if oldStatus == ricochet.Contact_ONLINE && newStatus != ricochet.Contact_ONLINE {
+		return ricochet.ContactEvent_WENT_OFFLINE
+	}
+	return ricochet.ContactEvent_OTHER
+}
+
+// contactDataChange describes the difference between two versions of a
+// contact's data for ContactEvent.Change
+func contactDataChange(oldData, newData *ricochet.Contact) ricochet.ContactEvent_Change {
+	if oldData.Request != nil && newData.Request == nil {
+		return ricochet.ContactEvent_REQUEST_ACCEPTED
+	} else if change := contactStatusChange(oldData.Status, newData.Status); change != ricochet.ContactEvent_OTHER {
+		return change
+	} else if oldData.AuthenticationRejected == "" && newData.AuthenticationRejected != "" {
+		return ricochet.ContactEvent_AUTHENTICATION_REJECTED
+	} else if !proto.Equal(oldData.Request, newData.Request) {
+		return ricochet.ContactEvent_REQUEST
+	}
+	return ricochet.ContactEvent_OTHER
+}
+
+// Same as contactStatusChange, for changes to a contact request that may also
+// change the contact's status
+func contactRequestChange(oldStatus, newStatus ricochet.Contact_Status) ricochet.ContactEvent_Change {
+	if change := contactStatusChange(oldStatus, newStatus); change != ricochet.ContactEvent_OTHER {
+		return change
+	}
+	return ricochet.ContactEvent_REQUEST
+}
+
+// Update the status of a contact request from a protocol event. detail is the
+// reason given by the contact for a rejection or error, if any, which is kept
+// as the request's RemoteError. Returns true if the contact request channel
+// should remain open.
+func (c *Contact) UpdateContactRequest(status, detail string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.data.Request == nil || c.destroyed {
+		// A cancelled request ignores any response from the peer
 		return false
 	}
 
-	re := c.updateContactRequest(status)
+	if len(detail) > 0 && !IsRejectReasonAcceptable(detail) {
+		c.core.Log.Warnf("protocol: Ignoring unacceptable contact request %s reason; len: %d", strings.ToLower(status), len(detail))
+		detail = ""
+	}
+	oldStatus := c.data.Status
+	re := c.updateContactRequest(status, detail)
 
+	change := contactRequestChange(oldStatus, c.data.Status)
+	if c.data.Request == nil {
+		change = ricochet.ContactEvent_REQUEST_ACCEPTED
+	}
 	event := ricochet.ContactEvent{
 		Type: ricochet.ContactEvent_UPDATE,
 		Subject: &ricochet.ContactEvent_Contact{
-			Contact: c.Data(),
+			Contact: c.dataLocked(),
 		},
+		Change: change,
 	}
 	c.events.Publish(event)
 
@@ -603,7 +1493,7 @@ func (c *Contact) UpdateContactRequest(status string) bool {
 
 // Same as above, but assumes the mutex is already held and that the caller
 // will send an UPDATE event
-func (c *Contact) updateContactRequest(status string) bool {
+func (c *Contact) updateContactRequest(status, detail string) bool {
 	now := time.Now().Format(time.RFC3339)
 	// Whether to keep the channel open
 	var re bool
@@ -611,9 +1501,12 @@ func (c *Contact) updateContactRequest(status string) bool {
 	switch status {
 	case "Pending":
 		c.data.Request.WhenDelivered = now
+		c.data.Request.WhenRejected = ""
+		c.data.Request.RemoteError = ""
 		re = true
 
 	case "Accepted":
+		c.core.Audit.Record(ricochet.AuditEntry_REQUEST_ACCEPTED, c.data.Address, "outbound")
 		c.data.Request = nil
 		if c.connection != nil {
 			c.data.Status = ricochet.Contact_ONLINE
@@ -622,29 +1515,42 @@ func (c *Contact) updateContactRequest(status string) bool {
 		}
 
 	case "Rejected":
+		c.core.Audit.Record(ricochet.AuditEntry_REQUEST_REJECTED, c.data.Address, "outbound")
 		c.data.Request.WhenRejected = now
+		c.data.Request.Rejected = true
+		c.data.Request.RemoteError = detail
+		c.data.Status = ricochet.Contact_REJECTED
 
 	case "Error":
 		c.data.Request.WhenRejected = now
-		c.data.Request.RemoteError = "error occurred"
+		// The error phase is shown by a non-empty RemoteError
+		if detail == "" {
+			detail = "the contact reported an error without a reason"
+		}
+		c.data.Request.RemoteError = detail
 
 	default:
-		log.Printf("Unknown contact request status '%s'", status)
+		c.core.Log.Warnf("Unknown contact request status '%s'", status)
 	}
 
-	config := c.core.Config.Lock()
-	defer c.core.Config.Unlock()
-	config.Contacts[c.data.Address] = c.data
+	c.saveData()
 	return re
 }
 
 // AssignConnection takes new connections, inbound or outbound, to this contact, and
-// asynchronously decides whether to keep or close them.
+// asynchronously decides whether to keep or close them. It never blocks on a
+// contact whose connections were never started or are stopped: the connection
+// goroutine is started if needed and closes connections while disabled, and
+// after shutdown the connection is closed here instead.
 func (c *Contact) AssignConnection(conn *connection.Connection) {
 	c.connectionOnce.Do(func() {
 		go c.contactConnection()
 	})
 
 	// If connections are disabled, this connection will be closed by contactConnection
-	c.connChannel <- conn
+	select {
+	case c.connChannel <- conn:
+	case <-c.connStop:
+		go closeUnhandledConnection(conn)
+	}
 }
@@ -0,0 +1,497 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/contact"
+	"golang.org/x/net/context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// keptConnection returns the name of the connection that the peer myHost keeps
+// when newConn arrives after existing, which may be empty. Connections are
+// named by the peer that made them.
+func keptConnection(t *testing.T, myHost, remoteHost, existing, newConn string) string {
+	core := newTestCore(t)
+	core.Identity.address = "ricochet:" + myHost
+	contact := newTestContact(t, core, "ricochet:"+remoteHost)
+
+	conn := func(madeBy string) *connection.Connection {
+		return &connection.Connection{
+			IsInbound:      madeBy != myHost,
+			RemoteHostname: remoteHost,
+		}
+	}
+	if existing != "" {
+		contact.connection = conn(existing)
+		contact.timeConnected = time.Now()
+	}
+
+	contact.mutex.Lock()
+	defer contact.mutex.Unlock()
+	if contact.shouldReplaceConnection(conn(newConn)) {
+		return newConn
+	}
+	return existing
+}
+
+func TestShouldReplaceConnection(t *testing.T) {
+	const lowHost, highHost = "bbbbbbbbbbbbbbbb", "cccccccccccccccc"
+
+	tests := []struct {
+		name       string
+		myHost     string
+		remoteHost string
+		// Whether the new connection is inbound for myHost
+		inbound  bool
+		existing bool
+	}{
+		{"inbound to lower hostname with existing", lowHost, highHost, true, true},
+		{"inbound to higher hostname with existing", highHost, lowHost, true, true},
+		{"outbound from lower hostname with existing", lowHost, highHost, false, true},
+		{"outbound from higher hostname with existing", highHost, lowHost, false, true},
+		{"inbound to lower hostname without existing", lowHost, highHost, true, false},
+		{"inbound to higher hostname without existing", highHost, lowHost, true, false},
+		{"outbound from lower hostname without existing", lowHost, highHost, false, false},
+		{"outbound from higher hostname without existing", highHost, lowHost, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Connections are named by the peer that made them
+			newConn, otherConn := test.myHost, test.remoteHost
+			if test.inbound {
+				newConn, otherConn = otherConn, newConn
+			}
+			existing := ""
+			if test.existing {
+				existing = otherConn
+			}
+
+			mine := keptConnection(t, test.myHost, test.remoteHost, existing, newConn)
+			if !test.existing && mine != newConn {
+				t.Fatalf("kept %q, expected the only connection %q", mine, newConn)
+			}
+
+			// The connections may arrive at the peer in either order, and it
+			// must keep the same one
+			theirs := keptConnection(t, test.remoteHost, test.myHost, existing, newConn)
+			if theirs != mine {
+				t.Errorf("peers kept different connections (%q and %q) when they arrived in the same order", mine, theirs)
+			}
+			if test.existing {
+				theirs = keptConnection(t, test.remoteHost, test.myHost, newConn, existing)
+				if theirs != mine {
+					t.Errorf("peers kept different connections (%q and %q) when they arrived in opposite orders", mine, theirs)
+				}
+				// The connection made by the lower hostname is kept
+				if mine != lowHost {
+					t.Errorf("kept connection made by %q, expected the one made by %q", mine, lowHost)
+				}
+			}
+		})
+	}
+}
+
+func TestCountOutboundFailure(t *testing.T) {
+	tests := []struct {
+		err     error
+		counted bool
+	}{
+		{ricochetutils.ServerRejectedClientConnectionError, true},
+		{ricochetutils.ClientFailedToAuthenticateError, true},
+		{ConnectionNotAuthenticatedError, true},
+		{ricochetutils.ConnectionClosedError, false},
+		{io.EOF, false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		expected := 3
+		if test.counted {
+			expected++
+		}
+		if failures := countOutboundFailure(3, test.err); failures != expected {
+			t.Errorf("%v: counted %d failures, expected %d", test.err, failures, expected)
+		}
+	}
+}
+
+// Repeated authentication rejections by a peer that has blocked us are retried
+// with increasing delays, which continue from the same point when the outbound
+// connector is restarted
+func TestOutboundAuthenticationBackoff(t *testing.T) {
+	schedule := BackoffSchedule{
+		Initial:    100 * time.Millisecond,
+		Max:        time.Minute,
+		Multiplier: 2,
+	}
+	tests := []struct {
+		name string
+		// Attempts before the outbound connector is restarted, or 0
+		restartAfter int
+	}{
+		{"one connector", 0},
+		{"restarted connector", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			me := newTestPeer(t, network)
+			me.ConnectBackoff = schedule
+			recorder := &recordingResolver{Resolver: network, attempted: make(chan time.Time, 10)}
+			me.Network.SetResolver(recorder)
+			peer := newTestPeer(t, network)
+			newTestContact(t, peer, me.Identity.Address()).SetBlocked(true)
+			contact := newTestContact(t, me, peer.Identity.Address())
+			go contact.StartConnection()
+
+			const attempts = 5
+			var times []time.Time
+			for len(times) < attempts {
+				select {
+				case when := <-recorder.attempted:
+					times = append(times, when)
+				case <-time.After(10 * time.Second):
+					t.Fatalf("only %d attempts were made", len(times))
+				}
+				if len(times) == test.restartAfter {
+					// Once the rejection has been counted, during the backoff
+					time.Sleep(schedule.Initial / 2)
+					contact.RestartOutboundConnection()
+				}
+			}
+
+			var lastGap time.Duration
+			for i := 1; i < attempts; i++ {
+				gap := times[i].Sub(times[i-1])
+				if gap < schedule.Delay(i) || gap <= lastGap {
+					t.Errorf("attempt %d was %v after the last, expected at least %v and more than %v", i+1, gap, schedule.Delay(i), lastGap)
+				}
+				lastGap = gap
+			}
+			contact.mutex.Lock()
+			defer contact.mutex.Unlock()
+			if contact.data.AuthenticationRejected == "" {
+				t.Error("rejected authentication wasn't recorded")
+			}
+		})
+	}
+}
+
+// recordingResolver sends the time of each connection attempt to attempted
+type recordingResolver struct {
+	Resolver
+	attempted chan time.Time
+}
+
+func (rr *recordingResolver) Resolve(address string) (net.Conn, string, error) {
+	rr.attempted <- time.Now()
+	return rr.Resolver.Resolve(address)
+}
+
+// However an outbound attempt ends, it isn't left marked as authenticating
+func TestConnectOutboundClearsAuthenticating(t *testing.T) {
+	tests := []struct {
+		name string
+		// Sets up the peer's contact for us
+		setup func(t *testing.T, peer *Ricochet, address string)
+	}{
+		{"assigned", func(t *testing.T, peer *Ricochet, address string) {
+			newTestContact(t, peer, address)
+		}},
+		{"removed by peer", func(t *testing.T, peer *Ricochet, address string) {}},
+		{"rejected and cancelled", func(t *testing.T, peer *Ricochet, address string) {
+			newTestContact(t, peer, address).data.Blocked = true
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			me := newTestPeer(t, network)
+			peer := newTestPeer(t, network)
+			test.setup(t, peer, me.Identity.Address())
+			contact := newTestContact(t, me, peer.Identity.Address())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			connChannel := make(chan *connection.Connection)
+			authFailed := make(chan error)
+			done := make(chan struct{})
+			go func() {
+				contact.connectOutbound(ctx, 0, connChannel, authFailed)
+				close(done)
+			}()
+
+			timeout := time.After(10 * time.Second)
+			for returned := false; !returned; {
+				select {
+				case <-connChannel:
+				case <-authFailed:
+					// Otherwise it retries forever
+					cancel()
+				case <-done:
+					returned = true
+				case <-timeout:
+					t.Fatal("outbound attempt did not return")
+				}
+			}
+
+			contact.mutex.Lock()
+			defer contact.mutex.Unlock()
+			if contact.outboundAuthenticating {
+				t.Error("outbound attempt is still marked as authenticating")
+			}
+		})
+	}
+}
+
+// Peers that connect to each other at the same time settle on one connection,
+// which is the one made by the peer with the lower hostname
+func TestSimultaneousConnections(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the peer that starts connecting first has the lower hostname
+		lowerFirst bool
+	}{
+		{"lower hostname first", true},
+		{"higher hostname first", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			low, high := newTestPeer(t, network), newTestPeer(t, network)
+			lowHost, _ := PlainHostFromAddress(low.Identity.Address())
+			highHost, _ := PlainHostFromAddress(high.Identity.Address())
+			if highHost < lowHost {
+				low, high = high, low
+			}
+			lowContact := newTestContact(t, low, high.Identity.Address())
+			highContact := newTestContact(t, high, low.Identity.Address())
+
+			if test.lowerFirst {
+				go lowContact.StartConnection()
+				go highContact.StartConnection()
+			} else {
+				go highContact.StartConnection()
+				go lowContact.StartConnection()
+			}
+
+			settled := func() bool {
+				lowContact.mutex.Lock()
+				defer lowContact.mutex.Unlock()
+				highContact.mutex.Lock()
+				defer highContact.mutex.Unlock()
+				return lowContact.connection != nil && highContact.connection != nil &&
+					lowContact.connection.IsInbound != highContact.connection.IsInbound &&
+					lowContact.outboundAttempts == 0 && highContact.outboundAttempts == 0
+			}
+			deadline := time.Now().Add(10 * time.Second)
+			for !settled() {
+				if time.Now().After(deadline) {
+					t.Fatal("peers did not settle on one connection")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			lowConn, highConn := lowContact.Connection(), highContact.Connection()
+			if lowConn.IsInbound || !highConn.IsInbound {
+				t.Error("kept the connection made by the peer with the higher hostname")
+			}
+			time.Sleep(100 * time.Millisecond)
+			if lowContact.Connection() != lowConn || highContact.Connection() != highConn {
+				t.Error("connection was replaced after the peers settled")
+			}
+			if lowContact.Status() != ricochet.Contact_ONLINE || highContact.Status() != ricochet.Contact_ONLINE {
+				t.Errorf("contacts are %v and %v, expected both online", lowContact.Status(), highContact.Status())
+			}
+		})
+	}
+}
+
+// The reason in a response to an outbound request is kept as the request's
+// RemoteError, instead of a placeholder
+func TestContactRequestRemoteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		reason string
+		// Expected RemoteError, and whether the request is rejected
+		remoteError string
+		rejected    bool
+	}{
+		{"rejected with reason", "Rejected", "not now", "not now", true},
+		{"rejected without reason", "Rejected", "", "", true},
+		{"error with reason", "Error", "disk full", "disk full", false},
+		{"error without reason", "Error", "", "the contact reported an error without a reason", false},
+		{"unacceptable reason", "Rejected", "not\x00now", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			contact.data.Request = &ricochet.ContactRequest{Address: contact.data.Address}
+
+			// The response arrives on the contact request channel as it would
+			// from the contact, with the reason that the channel received
+			responseChan := make(chan string, 1)
+			handler := &requestChannelHandler{Response: responseChan}
+			var sent [][]byte
+			handler.Channel = newTestRequestChannel(handler, &sent)
+			status := Protocol_Data_ContactRequest.Response_Status(Protocol_Data_ContactRequest.Response_Status_value[test.status])
+			response := &contactRequestResponse{Status: &status}
+			if test.reason != "" {
+				response.Reason = proto.String(test.reason)
+			}
+			data, err := proto.Marshal(response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler.Channel.Packet(data)
+			contact.UpdateContactRequest(<-responseChan, handler.Reason)
+
+			request := contact.Data().Request
+			if request == nil {
+				t.Fatal("request was removed")
+			}
+			if request.RemoteError != test.remoteError || request.Rejected != test.rejected {
+				t.Errorf("request has RemoteError %q and rejected %v, expected %q and %v", request.RemoteError, request.Rejected, test.remoteError, test.rejected)
+			}
+		})
+	}
+}
+
+// A connection that closes while connections are being stopped is reported
+// once, and the contact ends up offline without blocking or racing
+func TestDisconnectDuringStop(t *testing.T) {
+	tests := []struct {
+		name string
+		// Delay before closing the connection, and before stopping
+		closeDelay time.Duration
+		stopDelay  time.Duration
+		// Close the peer's side instead of our own
+		remote bool
+	}{
+		{"at once", 0, 0, false},
+		{"close first", 0, time.Millisecond, false},
+		{"stop first", time.Millisecond, 0, false},
+		{"peer closes", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			local, peer := newTestPeer(t, network), newTestPeer(t, network)
+			contact := newTestContact(t, local, peer.Identity.Address())
+			peerContact := newTestContact(t, peer, local.Identity.Address())
+			go contact.StartConnection()
+			go peerContact.StartConnection()
+			deadline := time.Now().Add(10 * time.Second)
+			for contact.Status() != ricochet.Contact_ONLINE || peerContact.Connection() == nil {
+				if time.Now().After(deadline) {
+					t.Fatalf("contact is %v, expected online", contact.Status())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			conn := contact.Connection()
+			if test.remote {
+				conn = peerContact.Connection()
+			}
+			stopped := make(chan struct{})
+			go func() {
+				time.Sleep(test.closeDelay)
+				conn.Conn.Close()
+			}()
+			go func() {
+				time.Sleep(test.stopDelay)
+				contact.StopConnection()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(5 * time.Second):
+				t.Fatal("StopConnection did not return")
+			}
+
+			deadline = time.Now().Add(5 * time.Second)
+			for contact.Connection() != nil || contact.Status() != ricochet.Contact_OFFLINE {
+				if time.Now().After(deadline) {
+					t.Fatalf("contact is %v with connection %v, expected offline", contact.Status(), contact.Connection())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			// Stay offline; the peer's reconnections are refused while stopped
+			time.Sleep(100 * time.Millisecond)
+			if contact.Connection() != nil {
+				t.Error("contact reconnected while stopped")
+			}
+		})
+	}
+}
+
+// An authenticated inbound connection to a contact whose connections aren't
+// running is closed, without blocking or keeping the connection
+func TestAssignConnectionWhileDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		state func(contact *Contact)
+	}{
+		{"never started", func(contact *Contact) {}},
+		{"stopped", func(contact *Contact) {
+			contact.StartConnection()
+			contact.StopConnection()
+		}},
+		{"shut down", func(contact *Contact) { contact.shutdown() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			t.Cleanup(contact.shutdown)
+			test.state(contact)
+
+			local, peer := net.Pipe()
+			defer peer.Close()
+			conn := connection.NewInboundConnection(local)
+			conn.RemoteHostname = "bbbbbbbbbbbbbbbb"
+			conn.Authentication["im.ricochet.auth.hidden-service"] = true
+
+			assigned := make(chan struct{})
+			go func() {
+				contact.AssignConnection(conn)
+				close(assigned)
+			}()
+			select {
+			case <-assigned:
+			case <-time.After(5 * time.Second):
+				t.Fatal("AssignConnection did not return")
+			}
+
+			closed := make(chan error, 1)
+			go func() {
+				_, err := io.Copy(ioutil.Discard, peer)
+				closed <- err
+			}()
+			select {
+			case <-closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("connection was not closed")
+			}
+			if contact.Connection() != nil {
+				t.Error("contact kept the connection")
+			}
+		})
+	}
+}
@@ -5,10 +5,22 @@ import (
 	"fmt"
 	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
+	"log"
+	"sort"
 	"sync"
 	"time"
 )
 
+// SelfContactError is returned when adding the identity's own address as a contact
+var SelfContactError error = errors.New("Cannot add yourself as a contact")
+
+// Errors from MessageRecipient
+var (
+	UnknownRecipientError error = errors.New("Recipient is not a contact")
+	PendingRecipientError error = errors.New("Recipient is a contact request that hasn't been accepted")
+	BlockedRecipientError error = errors.New("Recipient is blocked")
+)
+
 type ContactList struct {
 	core *Ricochet
 
@@ -17,6 +29,9 @@ type ContactList struct {
 
 	contacts        map[string]*Contact
 	inboundRequests map[string]*InboundContactRequest
+
+	// Closed by shutdown to end background work
+	stop chan struct{}
 }
 
 func LoadContactList(core *Ricochet) (*ContactList, error) {
@@ -24,6 +39,7 @@ func LoadContactList(core *Ricochet) (*ContactList, error) {
 		core:            core,
 		events:          utils.CreatePublisher(),
 		inboundRequests: make(map[string]*InboundContactRequest),
+		stop:            make(chan struct{}),
 	}
 
 	config := core.Config.Read()
@@ -50,13 +66,16 @@ func (this *ContactList) EventMonitor() utils.Subscribable {
 	return this.events
 }
 
+// Contacts returns all contacts, in the order they should be listed
 func (this *ContactList) Contacts() []*Contact {
 	this.mutex.RLock()
-	defer this.mutex.RUnlock()
 	re := make([]*Contact, 0, len(this.contacts))
 	for _, contact := range this.contacts {
 		re = append(re, contact)
 	}
+	this.mutex.RUnlock()
+
+	sort.Slice(re, func(i, j int) bool { return re[i].sortsBefore(re[j]) })
 	return re
 }
 
@@ -72,6 +91,57 @@ func (cl *ContactList) InboundRequestByAddress(address string) *InboundContactRe
 	return cl.inboundRequests[address]
 }
 
+// MessageRecipient returns the contact that messages to address are sent to,
+// which must be an accepted contact that isn't blocked. The address may also
+// be given as an onion hostname, with or without the .onion suffix.
+func (cl *ContactList) MessageRecipient(address string) (*Contact, error) {
+	if !IsAddressValid(address) {
+		if onionAddress, ok := AddressFromOnion(address); ok {
+			address = onionAddress
+		} else if hostAddress, ok := AddressFromPlainHost(address); ok {
+			address = hostAddress
+		} else {
+			return nil, errors.New("Invalid recipient address")
+		}
+	}
+
+	contact := cl.ContactByAddress(address)
+	if contact == nil {
+		if cl.InboundRequestByAddress(address) != nil {
+			return nil, PendingRecipientError
+		}
+		return nil, UnknownRecipientError
+	} else if contact.IsRequest() {
+		return nil, PendingRecipientError
+	} else if contact.IsBlocked() {
+		return nil, BlockedRecipientError
+	}
+	return contact, nil
+}
+
+// UnreadCount returns the total number of unread messages from all contacts
+// that aren't muted
+func (cl *ContactList) UnreadCount() int {
+	total := 0
+	for _, contact := range cl.Contacts() {
+		if !contact.Muted() {
+			total += contact.UnreadCount()
+		}
+	}
+	return total
+}
+
+// InboundRequests returns all pending inbound contact requests
+func (cl *ContactList) InboundRequests() []*InboundContactRequest {
+	cl.mutex.RLock()
+	re := make([]*InboundContactRequest, 0, len(cl.inboundRequests))
+	for _, request := range cl.inboundRequests {
+		re = append(re, request)
+	}
+	cl.mutex.RUnlock()
+	return re
+}
+
 // AddNewContact adds a new contact to the persistent contact list, broadcasts a
 // contact add RPC event, and returns a newly constructed Contact. AddNewContact
 // does not create contact requests or trigger any other protocol behavior.
@@ -82,7 +152,14 @@ func (cl *ContactList) InboundRequestByAddress(address string) *InboundContactRe
 func (this *ContactList) AddNewContact(data *ricochet.Contact) (*Contact, error) {
 	this.mutex.Lock()
 	defer this.mutex.Unlock()
+	return this.addNewContact(data)
+}
 
+// Assumes mutex is held
+func (this *ContactList) addNewContact(data *ricochet.Contact) (*Contact, error) {
+	if data.Address == this.core.Identity.Address() {
+		return nil, SelfContactError
+	}
 	if this.contacts[data.Address] != nil {
 		return nil, errors.New("Contact already exists with this address")
 	}
@@ -116,20 +193,81 @@ func (this *ContactList) AddNewContact(data *ricochet.Contact) (*Contact, error)
 		},
 	}
 	this.events.Publish(event)
+	this.core.Audit.Record(ricochet.AuditEntry_CONTACT_ADDED, data.Address, "")
 
 	// XXX Should this be here? Is it ok for inbound where we might pass conn over momentarily?
-	contact.StartConnection()
+	this.startConnection(contact)
 	return contact, nil
 }
 
+// ExportContacts returns the established contacts in the portable export format.
+// Contact requests and blocked contacts are not included.
+func (cl *ContactList) ExportContacts() *ricochet.ContactListExport {
+	export := &ricochet.ContactListExport{}
+	for _, contact := range cl.Contacts() {
+		data := contact.Data()
+		if data.Request != nil || data.Blocked {
+			continue
+		}
+		export.Contacts = append(export.Contacts, &ricochet.ContactExport{
+			Address:     data.Address,
+			Nickname:    data.Nickname,
+			WhenCreated: data.WhenCreated,
+		})
+	}
+	return export
+}
+
+// ImportContacts adds the contacts from an export as established contacts, and
+// starts connecting to them. Addresses may be ricochet addresses or onion
+// hostnames. Invalid entries and contacts that already exist are skipped, and
+// returned with the reason.
+func (cl *ContactList) ImportContacts(export *ricochet.ContactListExport) ([]*Contact, []string) {
+	var added []*Contact
+	var skipped []string
+	for _, entry := range export.Contacts {
+		address := entry.Address
+		if IsOnionValid(address) {
+			address, _ = AddressFromOnion(address)
+		}
+
+		var err error
+		if !IsAddressValid(address) {
+			err = errors.New("Invalid ricochet address")
+		} else if !IsNicknameAcceptable(entry.Nickname) {
+			err = errors.New("Invalid nickname")
+		}
+		if err == nil {
+			whenCreated := entry.WhenCreated
+			if _, parseErr := time.Parse(time.RFC3339, whenCreated); parseErr != nil {
+				whenCreated = time.Now().Format(time.RFC3339)
+			}
+			var contact *Contact
+			contact, err = cl.AddNewContact(&ricochet.Contact{
+				Address:     address,
+				Nickname:    entry.Nickname,
+				WhenCreated: whenCreated,
+			})
+			if err == nil {
+				added = append(added, contact)
+				continue
+			}
+		}
+		skipped = append(skipped, fmt.Sprintf("%s: %s", entry.Address, err))
+	}
+	return added, skipped
+}
+
 // AddContactRequest creates a new outbound contact request with the given parameters,
-// adds it to the contact list, and returns the newly constructed Contact.
+// adds it to the contact list, and returns the newly constructed Contact. The address
+// may be in any form accepted by NormalizeAddress, and is stored in canonical form.
 //
 // If an inbound request already exists for this address, that request will be automatically
 // accepted, and the returned contact will already be fully established.
 func (cl *ContactList) AddContactRequest(address, name, fromName, text string) (*Contact, error) {
-	if !IsAddressValid(address) {
-		return nil, errors.New("Invalid ricochet address")
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
 	}
 	if !IsNicknameAcceptable(name) {
 		return nil, errors.New("Invalid nickname")
@@ -158,9 +296,10 @@ func (cl *ContactList) AddContactRequest(address, name, fromName, text string) (
 	if err != nil {
 		return nil, err
 	}
+	cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_SENT, address, "")
 
 	if inboundRequest := cl.InboundRequestByAddress(address); inboundRequest != nil {
-		contact.UpdateContactRequest("Accepted")
+		contact.UpdateContactRequest("Accepted", "")
 		inboundRequest.AcceptWithContact(contact)
 	}
 
@@ -169,23 +308,57 @@ func (cl *ContactList) AddContactRequest(address, name, fromName, text string) (
 
 func (this *ContactList) RemoveContact(contact *Contact) error {
 	this.mutex.Lock()
-	defer this.mutex.Unlock()
-
 	address := contact.Address()
 	if this.contacts[address] != contact {
+		this.mutex.Unlock()
+		return errors.New("Not in contact list")
+	}
+	delete(this.contacts, address)
+	this.mutex.Unlock()
+
+	this.removeContact(contact, address)
+	return nil
+}
+
+// CancelContactRequest removes a contact whose outbound contact request hasn't
+// been accepted yet, closing its connection and the request. Fails without
+// changing anything if the request has already been accepted, including by a
+// response that arrives while it's being cancelled.
+func (cl *ContactList) CancelContactRequest(contact *Contact) error {
+	cl.mutex.Lock()
+	address := contact.Address()
+	if cl.contacts[address] != contact {
+		cl.mutex.Unlock()
 		return errors.New("Not in contact list")
 	}
+	if err := contact.cancelRequest(); err != nil {
+		cl.mutex.Unlock()
+		return err
+	}
+	delete(cl.contacts, address)
+	cl.mutex.Unlock()
+
+	log.Printf("Cancelled contact request to %s", address)
+	cl.removeContact(contact, address)
+	return nil
+}
 
-	// XXX How do we safely make sure that the contact has stopped everything, and that
-	// nobody is going to block on it or keep referencing it..? This is insufficient, it
-	// leaves a goroutine up among other things.
-	contact.StopConnection()
+// removeContact destroys a contact that has been taken out of cl.contacts,
+// deletes its config and history, and publishes its removal
+func (this *ContactList) removeContact(contact *Contact, address string) {
+	// Waits for the connection to close, so neither mutex can be held. This must
+	// happen before the config is changed, because closing the connection updates
+	// the contact's data.
+	contact.destroy()
 
 	config := this.core.Config.Lock()
 	delete(config.Contacts, address)
 	this.core.Config.Unlock()
 
-	delete(this.contacts, address)
+	if this.core.History != nil {
+		this.core.History.Delete(address)
+	}
+	this.core.Audit.Record(ricochet.AuditEntry_CONTACT_REMOVED, address, "")
 
 	event := ricochet.ContactEvent{
 		Type: ricochet.ContactEvent_DELETE,
@@ -196,8 +369,6 @@ func (this *ContactList) RemoveContact(contact *Contact) error {
 		},
 	}
 	this.events.Publish(event)
-
-	return nil
 }
 
 // AddOrUpdateInboundContactRequest creates or modifies an inbound contact request for
@@ -212,8 +383,17 @@ func (this *ContactList) RemoveContact(contact *Contact) error {
 //
 // This function may return either an InboundContactRequest (which may be pending or already
 // rejected), an existing contact (which should be treated as accepting the request), or
-// neither, which is considered a rejection.
+// neither, which is considered a rejection. Requests from blocked contacts, or from
+// contacts that rejected our own request, are rejected without changing the contact.
+// New requests are accepted or rejected immediately if the identity's
+// ContactRequestPolicy says so.
 func (cl *ContactList) AddOrUpdateInboundContactRequest(address, nickname, message string) (*InboundContactRequest, *Contact) {
+	if address == cl.core.Identity.Address() {
+		// A request from our own address can't be genuine; treated as a rejection
+		log.Printf("Ignoring inbound contact request from our own address")
+		return nil, nil
+	}
+
 	cl.mutex.Lock()
 	defer cl.mutex.Unlock()
 
@@ -223,17 +403,49 @@ func (cl *ContactList) AddOrUpdateInboundContactRequest(address, nickname, messa
 		request.Update(nickname, message)
 		return request, nil
 	}
+	cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_RECEIVED, address, "")
 
 	// Check for existing contacts or outbound contact requests
 	for _, contact := range cl.contacts {
 		if contact.Address() == address {
+			if contact.IsBlocked() {
+				// Treated as a rejection
+				cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_REJECTED, address, "inbound, contact is blocked")
+				return nil, nil
+			}
+			if contact.IsRejected() {
+				// They rejected our request; their request doesn't undo that, and
+				// is rejected too. The user can send our request again.
+				log.Printf("Rejecting contact request from %s, which rejected our request", address)
+				cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_REJECTED, address, "inbound, contact rejected our request")
+				return nil, nil
+			}
 			if contact.IsRequest() {
-				contact.UpdateContactRequest("Accepted")
+				contact.UpdateContactRequest("Accepted", "")
 			}
 			return nil, contact
 		}
 	}
 
+	accept, reject := cl.core.Identity.checkContactRequestPolicy(message)
+	if reject {
+		log.Printf("Rejecting contact request from %s by policy", address)
+		cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_REJECTED, address, "inbound, by policy")
+		return nil, nil
+	} else if accept {
+		log.Printf("Accepting contact request from %s by policy", address)
+		contact, err := cl.addNewContact(&ricochet.Contact{
+			Address:     address,
+			Nickname:    nickname,
+			WhenCreated: time.Now().Format(time.RFC3339),
+		})
+		if err == nil {
+			cl.core.Audit.Record(ricochet.AuditEntry_REQUEST_ACCEPTED, address, "inbound, by policy")
+			return nil, contact
+		}
+		log.Printf("Accepting contact request by policy failed, leaving it pending: %s", err)
+	}
+
 	// Create new request
 	request := CreateInboundContactRequest(cl.core, address, nickname, message)
 	request.StatusChanged = cl.inboundRequestChanged
@@ -294,14 +506,56 @@ func (cl *ContactList) inboundRequestChanged(request *InboundContactRequest) {
 	cl.events.Publish(event)
 }
 
+// StartConnections enables connections to all contacts as allowed by the
+// identity's ConnectionMode. Contacts that are already enabled re-evaluate
+// whether to make outbound connections.
 func (this *ContactList) StartConnections() {
 	for _, contact := range this.Contacts() {
+		this.startConnection(contact)
+	}
+}
+
+// startConnection enables connections to a contact, unless the connection mode
+// is INVISIBLE, which disables them
+func (cl *ContactList) startConnection(contact *Contact) {
+	if cl.core.connectionMode() == ricochet.ConnectionMode_INVISIBLE {
+		contact.StopConnection()
+	} else {
 		contact.StartConnection()
 	}
 }
 
+// RestartOutboundConnections restarts outbound connection attempts to all
+// contacts, e.g. after the proxy settings have changed
+func (cl *ContactList) RestartOutboundConnections() {
+	for _, contact := range cl.Contacts() {
+		contact.RestartOutboundConnection()
+	}
+}
+
 func (this *ContactList) StopConnections() {
 	for _, contact := range this.Contacts() {
 		contact.StopConnection()
 	}
 }
+
+// shutdown permanently stops connections to all contacts and waits for them
+// to close. Only the first call has any effect.
+func (cl *ContactList) shutdown() {
+	select {
+	case <-cl.stop:
+		return
+	default:
+		close(cl.stop)
+	}
+
+	var wg sync.WaitGroup
+	for _, contact := range cl.Contacts() {
+		wg.Add(1)
+		go func(contact *Contact) {
+			contact.shutdown()
+			wg.Done()
+		}(contact)
+	}
+	wg.Wait()
+}
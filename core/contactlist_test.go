@@ -0,0 +1,249 @@
+package core
+
+import (
+	"fmt"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/connection"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Removing a contact stops its connection goroutines and closes its connection,
+// in any state of the connection, without leaking goroutines
+func TestRemoveContactStopsConnection(t *testing.T) {
+	tests := []struct {
+		name string
+		// Start the connection, and whether the peer can be reached
+		start     bool
+		reachable bool
+	}{
+		{"never started", false, false},
+		{"connecting", true, false},
+		{"connected", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			local, peer := newTestPeer(t, network), newTestPeer(t, network)
+			contact := newTestContact(t, local, peer.Identity.Address())
+			peerContact := newTestContact(t, peer, local.Identity.Address())
+			if !test.reachable {
+				onion, _ := OnionFromAddress(peer.Identity.Address())
+				network.mutex.Lock()
+				delete(network.peers, onion)
+				network.mutex.Unlock()
+			}
+			goroutines := runtime.NumGoroutine()
+
+			if test.start {
+				go contact.StartConnection()
+				if test.reachable {
+					go peerContact.StartConnection()
+					deadline := time.Now().Add(10 * time.Second)
+					for contact.Status() != ricochet.Contact_ONLINE {
+						if time.Now().After(deadline) {
+							t.Fatalf("contact is %v, expected online", contact.Status())
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+				} else {
+					time.Sleep(50 * time.Millisecond)
+				}
+			}
+
+			removed := make(chan error, 1)
+			go func() { removed <- local.Identity.ContactList().RemoveContact(contact) }()
+			select {
+			case err := <-removed:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("RemoveContact did not return")
+			}
+			if contact.Connection() != nil {
+				t.Error("removed contact still has a connection")
+			}
+			if _, exists := local.Config.Read().Contacts[contact.Address()]; exists {
+				t.Error("removed contact is still in the config")
+			}
+
+			// The peer's side is removed too, so that it stops reconnecting
+			if err := peer.Identity.ContactList().RemoveContact(peerContact); err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > goroutines {
+				if time.Now().After(deadline) {
+					buf := make([]byte, 1<<16)
+					t.Fatalf("%d goroutines are running, expected %d:\n%s", runtime.NumGoroutine(), goroutines, buf[:runtime.Stack(buf, true)])
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// Our own address is refused as a contact, in any form and by any path that
+// adds contacts, and connections authenticated as our own hostname are refused
+func TestAddSelfAsContact(t *testing.T) {
+	tests := []struct {
+		name string
+		// Adds self, returning the error it's refused with
+		add func(cl *ContactList) error
+	}{
+		{"new contact", func(cl *ContactList) error {
+			_, err := cl.AddNewContact(&ricochet.Contact{Address: testSelfAddress, Nickname: "me"})
+			return err
+		}},
+		{"request", func(cl *ContactList) error {
+			_, err := cl.AddContactRequest(testSelfAddress, "me", "", "")
+			return err
+		}},
+		{"request to onion", func(cl *ContactList) error {
+			_, err := cl.AddContactRequest("AAAAAAAAAAAAAAAA.onion", "me", "", "")
+			return err
+		}},
+		{"import", func(cl *ContactList) error {
+			_, skipped := cl.ImportContacts(&ricochet.ContactListExport{
+				Contacts: []*ricochet.ContactExport{{Address: "aaaaaaaaaaaaaaaa.onion", Nickname: "me"}},
+			})
+			if len(skipped) != 1 || !strings.HasSuffix(skipped[0], SelfContactError.Error()) {
+				return fmt.Errorf("skipped %v", skipped)
+			}
+			return SelfContactError
+		}},
+		{"inbound request", func(cl *ContactList) error {
+			// Refused as a rejection, with neither a request nor a contact
+			request, contact := cl.AddOrUpdateInboundContactRequest(testSelfAddress, "me", "hello")
+			if request != nil || contact != nil || cl.InboundRequestByAddress(testSelfAddress) != nil {
+				return fmt.Errorf("returned request %v and contact %v", request, contact)
+			}
+			return SelfContactError
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			cl := core.Identity.ContactList()
+			if err := test.add(cl); err != SelfContactError {
+				t.Errorf("error is %v, expected %v", err, SelfContactError)
+			}
+			if len(cl.Contacts()) != 0 {
+				t.Errorf("contact list has %d contacts, expected none", len(cl.Contacts()))
+			}
+			if _, exists := core.Config.Read().Contacts[testSelfAddress]; exists {
+				t.Error("self was added to the config")
+			}
+		})
+	}
+
+	core := newTestCore(t)
+	contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+	conn := newTestConnection(t, "aaaaaaaaaaaaaaaa")
+	contact.mutex.Lock()
+	err := contact.considerUsingConnection(conn)
+	contact.mutex.Unlock()
+	if err == nil {
+		t.Error("used a connection authenticated as our own hostname")
+	}
+}
+
+// An inbound request from an address that's already a contact is answered
+// from the contact's state: accepted for contacts and our pending requests,
+// rejected for blocked contacts and contacts that rejected our request, which
+// are left as they were
+func TestInboundRequestFromExistingContact(t *testing.T) {
+	const peerHost = "bbbbbbbbbbbbbbbb"
+	tests := []struct {
+		name string
+		// Puts the contact in the state being tested
+		setup    func(contact *Contact)
+		response string
+		status   ricochet.Contact_Status
+		request  bool
+	}{
+		{"contact", func(contact *Contact) {}, "Accepted", ricochet.Contact_UNKNOWN, false},
+		{"pending request", func(contact *Contact) {
+			contact.mutex.Lock()
+			contact.data.Request = &ricochet.ContactRequest{Direction: ricochet.ContactRequest_OUTBOUND}
+			contact.mutex.Unlock()
+		}, "Accepted", ricochet.Contact_UNKNOWN, false},
+		{"blocked", func(contact *Contact) {
+			contact.SetBlocked(true)
+		}, "Rejected", ricochet.Contact_BLOCKED, false},
+		{"rejected our request", func(contact *Contact) {
+			contact.mutex.Lock()
+			contact.data.Request = &ricochet.ContactRequest{Direction: ricochet.ContactRequest_OUTBOUND}
+			contact.mutex.Unlock()
+			contact.UpdateContactRequest("Rejected", "")
+		}, "Rejected", ricochet.Contact_REJECTED, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			local := newTestPeer(t, newTestNetwork())
+			contact := newTestContact(t, local, "ricochet:"+peerHost)
+			test.setup(contact)
+
+			conn, peerConn, err := loopbackPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			rc := connection.NewInboundConnection(peerConn)
+			rc.RemoteHostname = peerHost
+			rc.Authentication["im.ricochet.auth.hidden-service"] = true
+			handled := make(chan error, 1)
+			go func() { handled <- HandleInboundRequestConnection(rc, local.Identity.ContactList()) }()
+
+			// The peer sends its request as a contact would
+			localHost, _ := PlainHostFromAddress(local.Identity.Address())
+			peer := connection.NewOutboundConnection(conn, localHost)
+			peer.Authentication["im.ricochet.auth.hidden-service"] = true
+			go peer.Process(&testConnectionHandler{})
+			responseChan := make(chan string, 1)
+			handler := &requestChannelHandler{Response: responseChan}
+			handler.Channel = &contactRequestChannel{handler: handler, Name: "peer", Message: "hello"}
+			err = peer.Do(func() error {
+				_, err := peer.RequestOpenChannel(contactRequestChannelType, handler.Channel)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case response := <-responseChan:
+				if response != test.response {
+					t.Errorf("peer received %q, expected %q", response, test.response)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("peer received no response, expected %q", test.response)
+			}
+			select {
+			case err := <-handled:
+				if (err == nil) != (test.response == "Accepted") {
+					t.Errorf("request handler returned %v for %q", err, test.response)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("request handler did not return")
+			}
+
+			data := contact.Data()
+			if data.Status != test.status || (data.Request != nil) != test.request {
+				t.Errorf("contact is %v with request %v, expected %v", data.Status, data.Request, test.status)
+			}
+			if local.Identity.ContactList().ContactByAddress(contact.Address()) != contact {
+				t.Error("contact was replaced")
+			}
+			if local.Identity.ContactList().InboundRequestByAddress(contact.Address()) != nil {
+				t.Error("an inbound request was added for an existing contact")
+			}
+		})
+	}
+}
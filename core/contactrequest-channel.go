@@ -0,0 +1,198 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/contact"
+	"github.com/s-rah/go-ricochet/wire/control"
+)
+
+const contactRequestChannelType = "im.ricochet.contact.request"
+
+// contactRequestResponse is the wire format of Protocol_Data_ContactRequest.Response,
+// with an optional reason for a rejection or error. Peers without reasons
+// ignore it.
+type contactRequestResponse struct {
+	Status           *Protocol_Data_ContactRequest.Response_Status `protobuf:"varint,1,req,name=status,enum=Protocol.Data.ContactRequest.Response_Status" json:"status,omitempty"`
+	Reason           *string                                       `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+	XXX_unrecognized []byte                                        `json:"-"`
+}
+
+func (m *contactRequestResponse) Reset()         { *m = contactRequestResponse{} }
+func (m *contactRequestResponse) String() string { return proto.CompactTextString(m) }
+func (*contactRequestResponse) ProtoMessage()    {}
+
+func (m *contactRequestResponse) GetStatus() Protocol_Data_ContactRequest.Response_Status {
+	if m != nil && m.Status != nil {
+		return *m.Status
+	}
+	return Protocol_Data_ContactRequest.Response_Undefined
+}
+
+func (m *contactRequestResponse) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+// The response in the ChannelResult of a contact request channel, which is
+// Protocol_Data_ContactRequest.E_Response for contactRequestResponse
+var contactRequestResponseExtension = &proto.ExtensionDesc{
+	ExtendedType:  (*Protocol_Data_Control.ChannelResult)(nil),
+	ExtensionType: (*contactRequestResponse)(nil),
+	Field:         Protocol_Data_ContactRequest.E_Response.Field,
+	Name:          Protocol_Data_ContactRequest.E_Response.Name,
+	Tag:           Protocol_Data_ContactRequest.E_Response.Tag,
+}
+
+// newContactRequestResponse returns a response with status, and with reason
+// if the status is "Rejected"
+func newContactRequestResponse(status, reason string) *contactRequestResponse {
+	responseStatus := Protocol_Data_ContactRequest.Response_Status(Protocol_Data_ContactRequest.Response_Status_value[status])
+	response := &contactRequestResponse{Status: &responseStatus}
+	if reason != "" && status == "Rejected" {
+		response.Reason = proto.String(reason)
+	}
+	return response
+}
+
+// contactRequestChannel implements channels.Handler for im.ricochet.contact.request.
+// It's the same as the protocol library's ContactRequestChannel, but also sends
+// and receives the reason for a rejection.
+type contactRequestChannel struct {
+	handler channels.ContactRequestChannelHandler
+	channel *channels.Channel
+
+	// Properties of the request
+	Name    string
+	Message string
+
+	// Reason is an optional explanation for a rejection. For inbound channels,
+	// it's sent with a "Rejected" response; for outbound channels, it's set to
+	// the reason received before ContactRequestRejected or ContactRequestError
+	// is called.
+	Reason string
+}
+
+func (crc *contactRequestChannel) Type() string {
+	return contactRequestChannelType
+}
+
+func (crc *contactRequestChannel) Closed(err error) {
+}
+
+func (crc *contactRequestChannel) OnlyClientCanOpen() bool {
+	return true
+}
+
+func (crc *contactRequestChannel) Singleton() bool {
+	return true
+}
+
+func (crc *contactRequestChannel) Bidirectional() bool {
+	return false
+}
+
+func (crc *contactRequestChannel) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+
+func (crc *contactRequestChannel) OpenInbound(channel *channels.Channel, oc *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	crc.channel = channel
+	contactRequestI, err := proto.GetExtension(oc, Protocol_Data_ContactRequest.E_ContactRequest)
+	if err != nil {
+		return nil, channels.InvalidContactRequestError
+	}
+	contactRequest, ok := contactRequestI.(*Protocol_Data_ContactRequest.ContactRequest)
+	if !ok {
+		return nil, channels.InvalidContactRequestError
+	}
+	if len(contactRequest.GetNickname()) > int(Protocol_Data_ContactRequest.Limits_NicknameMaxCharacters) {
+		return nil, channels.InvalidContactNameError
+	}
+	if len(contactRequest.GetMessageText()) > int(Protocol_Data_ContactRequest.Limits_MessageMaxCharacters) {
+		return nil, channels.InvalidContactMessageError
+	}
+
+	crc.Name = contactRequest.GetNickname()
+	crc.Message = contactRequest.GetMessageText()
+	status := crc.handler.ContactRequest(crc.Name, crc.Message)
+
+	channel.Pending = false
+	cr := &Protocol_Data_Control.ChannelResult{
+		ChannelIdentifier: proto.Int32(channel.ID),
+		Opened:            proto.Bool(true),
+	}
+	err = proto.SetExtension(cr, contactRequestResponseExtension, newContactRequestResponse(status, crc.Reason))
+	ricochetutils.CheckError(err)
+	data, err := proto.Marshal(&Protocol_Data_Control.Packet{ChannelResult: cr})
+	ricochetutils.CheckError(err)
+	return data, nil
+}
+
+// OpenOutbound sends the request with Name and Message, each of which is
+// omitted if it's empty
+func (crc *contactRequestChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	crc.channel = channel
+	oc := &Protocol_Data_Control.OpenChannel{
+		ChannelIdentifier: proto.Int32(channel.ID),
+		ChannelType:       proto.String(contactRequestChannelType),
+	}
+	contactRequest := &Protocol_Data_ContactRequest.ContactRequest{}
+	if crc.Name != "" {
+		contactRequest.Nickname = proto.String(crc.Name)
+	}
+	if crc.Message != "" {
+		contactRequest.MessageText = proto.String(crc.Message)
+	}
+	err := proto.SetExtension(oc, Protocol_Data_ContactRequest.E_ContactRequest, contactRequest)
+	ricochetutils.CheckError(err)
+	data, err := proto.Marshal(&Protocol_Data_Control.Packet{OpenChannel: oc})
+	ricochetutils.CheckError(err)
+	return data, nil
+}
+
+func (crc *contactRequestChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	if err == nil && crm.GetOpened() {
+		crc.channel.Pending = false
+		responseI, err := proto.GetExtension(crm, contactRequestResponseExtension)
+		if response, ok := responseI.(*contactRequestResponse); err == nil && ok {
+			crc.handleResponse(response)
+			return
+		}
+	}
+	crc.channel.SendMessage([]byte{})
+}
+
+// sendResponse sends the final response to an inbound request, with Reason
+// if it's rejected
+func (crc *contactRequestChannel) sendResponse(status string) {
+	data, err := proto.Marshal(newContactRequestResponse(status, crc.Reason))
+	ricochetutils.CheckError(err)
+	crc.channel.SendMessage(data)
+}
+
+func (crc *contactRequestChannel) handleResponse(response *contactRequestResponse) {
+	crc.Reason = response.GetReason()
+	switch response.GetStatus() {
+	case Protocol_Data_ContactRequest.Response_Accepted:
+		crc.handler.ContactRequestAccepted()
+	case Protocol_Data_ContactRequest.Response_Rejected:
+		crc.handler.ContactRequestRejected()
+	case Protocol_Data_ContactRequest.Response_Error:
+		crc.handler.ContactRequestError()
+	}
+}
+
+func (crc *contactRequestChannel) Packet(data []byte) {
+	if !crc.channel.Pending {
+		response := new(contactRequestResponse)
+		if err := proto.Unmarshal(data, response); err == nil {
+			crc.handleResponse(response)
+			return
+		}
+	}
+	crc.channel.SendMessage([]byte{})
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/contact"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"testing"
+)
+
+// testRequestHandler answers inbound requests with status, and records the
+// last response to an outbound request
+type testRequestHandler struct {
+	status   string
+	response string
+}
+
+func (h *testRequestHandler) ContactRequest(name, message string) string {
+	return h.status
+}
+func (h *testRequestHandler) ContactRequestRejected() { h.response = "Rejected" }
+func (h *testRequestHandler) ContactRequestAccepted() { h.response = "Accepted" }
+func (h *testRequestHandler) ContactRequestError()    { h.response = "Error" }
+
+// newTestRequestChannel returns a contactRequestChannel that appends packets
+// it sends to sent
+func newTestRequestChannel(handler channels.ContactRequestChannelHandler, sent *[][]byte) *contactRequestChannel {
+	return &contactRequestChannel{
+		handler: handler,
+		channel: &channels.Channel{
+			SendMessage: func(data []byte) { *sent = append(*sent, data) },
+		},
+	}
+}
+
+// The reason for a rejection is sent with the response, either when the
+// channel is opened or later, and peers without reasons still read the status
+func TestContactRequestReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		// Reason set by the recipient
+		reason string
+		// Reason received by the requester
+		expected string
+		// Responded when the channel is opened, instead of after Pending
+		immediate bool
+	}{
+		{"rejected", "Rejected", "not now", "not now", false},
+		{"rejected immediately", "Rejected", "not now", "not now", true},
+		{"rejected without reason", "Rejected", "", "", false},
+		{"accepted", "Accepted", "not now", "", false},
+		{"accepted immediately", "Accepted", "not now", "", true},
+		{"error", "Error", "not now", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := "Pending"
+			if test.immediate {
+				status = test.status
+			}
+			var sent [][]byte
+			inbound := newTestRequestChannel(&testRequestHandler{status: status}, &sent)
+			inbound.Reason = test.reason
+			oc := &Protocol_Data_Control.OpenChannel{
+				ChannelIdentifier: proto.Int32(1),
+				ChannelType:       proto.String(contactRequestChannelType),
+			}
+			err := proto.SetExtension(oc, Protocol_Data_ContactRequest.E_ContactRequest, &Protocol_Data_ContactRequest.ContactRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opened, err := inbound.OpenInbound(&channels.Channel{ID: 1}, oc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			packet := new(Protocol_Data_Control.Packet)
+			if err := proto.Unmarshal(opened, packet); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := &testRequestHandler{}
+			var outboundSent [][]byte
+			outbound := newTestRequestChannel(handler, &outboundSent)
+			outbound.OpenOutboundResult(nil, packet.GetChannelResult())
+			var response []byte
+			if !test.immediate {
+				inbound.channel.SendMessage = func(data []byte) { response = data }
+				inbound.sendResponse(test.status)
+				outbound.Packet(response)
+			}
+
+			if handler.response != test.status || outbound.Reason != test.expected {
+				t.Errorf("requester received %q with reason %q, expected %q with %q", handler.response, outbound.Reason, test.status, test.expected)
+			}
+			if len(outboundSent) != 0 {
+				t.Errorf("requester closed the channel after a valid response")
+			}
+
+			// A peer using the protocol library reads the same status
+			var libraryStatus Protocol_Data_ContactRequest.Response_Status
+			if test.immediate {
+				packet := new(Protocol_Data_Control.Packet)
+				if err := proto.Unmarshal(opened, packet); err != nil {
+					t.Fatal(err)
+				}
+				responseI, err := proto.GetExtension(packet.GetChannelResult(), Protocol_Data_ContactRequest.E_Response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				libraryStatus = responseI.(*Protocol_Data_ContactRequest.Response).GetStatus()
+			} else {
+				libraryResponse := new(Protocol_Data_ContactRequest.Response)
+				if err := proto.Unmarshal(response, libraryResponse); err != nil {
+					t.Fatal(err)
+				}
+				libraryStatus = libraryResponse.GetStatus()
+			}
+			if libraryStatus.String() != test.status {
+				t.Errorf("library read status %v, expected %s", libraryStatus, test.status)
+			}
+		})
+	}
+
+	// Responses from a peer using the protocol library have no reason
+	handler := &testRequestHandler{}
+	var sent [][]byte
+	outbound := newTestRequestChannel(handler, &sent)
+	outbound.Reason = "stale"
+	outbound.Packet(new(ricochetutils.MessageBuilder).ReplyToContactRequest(1, "Rejected"))
+	if handler.response != "Rejected" || outbound.Reason != "" {
+		t.Errorf("received %q with reason %q from the library, expected Rejected without a reason", handler.response, outbound.Reason)
+	}
+}
+
+// An empty nickname or message is omitted from the request, rather than sent
+// as an empty string
+func TestContactRequestOmitsEmpty(t *testing.T) {
+	tests := []struct {
+		name     string
+		nickname string
+		message  string
+	}{
+		{"both", "alice", "hello"},
+		{"no nickname", "", "hello"},
+		{"no message", "alice", ""},
+		{"neither", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent [][]byte
+			outbound := newTestRequestChannel(&testRequestHandler{}, &sent)
+			outbound.Name, outbound.Message = test.nickname, test.message
+			data, err := outbound.OpenOutbound(&channels.Channel{ID: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			packet := new(Protocol_Data_Control.Packet)
+			if err := proto.Unmarshal(data, packet); err != nil {
+				t.Fatal(err)
+			}
+			oc := packet.GetOpenChannel()
+			if oc.GetChannelIdentifier() != 1 || oc.GetChannelType() != contactRequestChannelType {
+				t.Fatalf("opened channel %d of type %q", oc.GetChannelIdentifier(), oc.GetChannelType())
+			}
+			requestI, err := proto.GetExtension(oc, Protocol_Data_ContactRequest.E_ContactRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := requestI.(*Protocol_Data_ContactRequest.ContactRequest)
+			if (request.Nickname != nil) != (test.nickname != "") || request.GetNickname() != test.nickname {
+				t.Errorf("sent nickname %v, expected %q", request.Nickname, test.nickname)
+			}
+			if (request.MessageText != nil) != (test.message != "") || request.GetMessageText() != test.message {
+				t.Errorf("sent message %v, expected %q", request.MessageText, test.message)
+			}
+
+			// The recipient reads the same request
+			inbound := newTestRequestChannel(&testRequestHandler{status: "Pending"}, &sent)
+			if _, err := inbound.OpenInbound(&channels.Channel{ID: 1}, oc); err != nil {
+				t.Fatal(err)
+			}
+			if inbound.Name != test.nickname || inbound.Message != test.message {
+				t.Errorf("received %q and %q, expected %q and %q", inbound.Name, inbound.Message, test.nickname, test.message)
+			}
+		})
+	}
+}
@@ -2,16 +2,20 @@ package core
 
 import (
 	"errors"
+	"github.com/golang/protobuf/proto"
 	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
 	"log"
 	"math/rand"
 	"sync"
 	"time"
 )
 
-// XXX should have limits on backlog size/duration
+// DefaultMaxConversationMessages is used when Ricochet.MaxConversationMessages
+// is unset
+const DefaultMaxConversationMessages = 5000
 
 type Conversation struct {
 	Contact *Contact
@@ -22,6 +26,25 @@ type Conversation struct {
 	remoteEntity      *ricochet.Entity
 	messages          []*ricochet.Message
 	lastSentMessageId uint32
+	// Highest Message.Sequence assigned in the conversation
+	lastSequence uint64
+	// Most recent received messages, to detect retransmits
+	recentReceived  []receivedChatMessage
+	lastChatChannel uint64
+	// Time each SENDING message was last sent, for the ack timeout. Kept across
+	// connections, so messages sent on an earlier one still time out.
+	sentAt map[*ricochet.Message]time.Time
+	// Sequence of the message last sent with each protocol identifier on the
+	// current connection, which acks refer to
+	sentIds map[uint64]uint64
+	// Messages that failed because no ack arrived, since the current
+	// connection was established
+	timedOut []*ricochet.Message
+
+	localTyping       bool
+	localTypingSent   time.Time
+	remoteTyping      bool
+	remoteTypingTimer *time.Timer
 
 	events *utils.Publisher
 }
@@ -40,56 +63,197 @@ func (c *Conversation) EventMonitor() utils.Subscribable {
 	return c.events
 }
 
+// Messages returns copies of the messages in the conversation
 func (c *Conversation) Messages() []*ricochet.Message {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	re := make([]*ricochet.Message, 0, len(c.messages))
 	for _, message := range c.messages {
-		re = append(re, message)
+		re = append(re, proto.Clone(message).(*ricochet.Message))
 	}
 	return re
 }
 
+// UnreadCount returns the number of received messages that haven't been read
+func (c *Conversation) UnreadCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return countUnread(c.messages)
+}
+
+func countUnread(messages []*ricochet.Message) int {
+	count := 0
+	for _, message := range messages {
+		if message.Status == ricochet.Message_UNREAD {
+			count++
+		}
+	}
+	return count
+}
+
+// unreadChanged updates the contact's unread count after messages have been
+// received or read. Assumes c.mutex is held, and must not be called while the
+// contact's mutex is held.
+func (c *Conversation) unreadChanged() {
+	c.Contact.setUnreadCount(countUnread(c.messages))
+}
+
+// Number of received messages remembered to detect retransmits
+const duplicateWindow = 10
+
+// Maximum difference in the timestamps of a message and its retransmit, which
+// are from the same send time but may be rounded differently
+const duplicateTimestampSlack = 30
+
+// A received message, and the inbound chat channel it arrived on
+type receivedChatMessage struct {
+	channel   uint64
+	id        uint64
+	timestamp int64
+	text      string
+	// The message this one replies to, if any
+	replyTo *chatReply
+}
+
+// isRetransmitOf returns true if m is likely to be a retransmit of other by the
+// peer, after an ack for other was lost. Peers only retransmit on a new channel,
+// so messages on the same channel are always distinct, even if the peer reuses
+// identifiers. Identifiers can also repeat after wrapping around, but not within
+// the time allowed between a message and its retransmit.
+func (m receivedChatMessage) isRetransmitOf(other receivedChatMessage) bool {
+	if m.channel == 0 || other.channel == 0 || m.channel == other.channel {
+		return false
+	}
+	delta := m.timestamp - other.timestamp
+	return m.id == other.id && m.text == other.text &&
+		delta <= duplicateTimestampSlack && delta >= -duplicateTimestampSlack
+}
+
+// inboundChatHandler handles one inbound chat channel from the contact. Each
+// channel has its own handler, so retransmitted messages can be recognized by
+// arriving on a different channel.
+type inboundChatHandler struct {
+	*Conversation
+	channel uint64
+	// Connection the channel is on, and its limiter for inbound messages,
+	// which is nil if unlimited
+	conn    *connection.Connection
+	limiter *MessageRateLimiter
+}
+
+// newInboundChatHandler returns a chatHandler for a new inbound channel
+// on conn. Channels on the same connection share limiter.
+func (c *Conversation) newInboundChatHandler(conn *connection.Connection, limiter *MessageRateLimiter) *inboundChatHandler {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastChatChannel++
+	return &inboundChatHandler{
+		Conversation: c,
+		channel:      c.lastChatChannel,
+		conn:         conn,
+		limiter:      limiter,
+	}
+}
+
+// Implement chatHandler (im.ricochet.chat)
+func (h *inboundChatHandler) ChatMessage(messageID uint32, when time.Time, message string, replyTo *chatReply) bool {
+	if !h.allowMessage(messageID) {
+		return false
+	}
+	return h.Conversation.chatMessage(h.channel, messageID, when, message, replyTo)
+}
+
+// allowMessage applies the connection's rate limit to a received message.
+// Messages over the limit are refused with a NACK before any other work is
+// done, and the connection is closed if the contact keeps sending them.
+func (h *inboundChatHandler) allowMessage(messageID uint32) bool {
+	if h.limiter == nil {
+		return true
+	}
+	allowed, abusive := h.limiter.Allow()
+	if abusive {
+		h.Contact.core.Log.Warnf("Closing connection from %s, which is sending messages too quickly", h.remoteEntity.Address)
+		h.conn.Conn.Close()
+	} else if !allowed {
+		h.Contact.core.Log.Debugf("Refusing message %d from %s over the rate limit", messageID, h.remoteEntity.Address)
+	}
+	return allowed
+}
+
+// Receive adds a message from the contact to the conversation
 func (c *Conversation) Receive(id uint64, timestamp int64, text string) {
+	c.receive(receivedChatMessage{id: id, timestamp: timestamp, text: text})
+}
+
+func (c *Conversation) receive(received receivedChatMessage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, other := range c.recentReceived {
+		if received.isRetransmitOf(other) {
+			log.Printf("Ignoring retransmit of message %d from %s", received.id, c.remoteEntity.Address)
+			return
+		}
+	}
+	if len(c.recentReceived) >= duplicateWindow {
+		c.recentReceived = append(c.recentReceived[:0], c.recentReceived[1:]...)
+	}
+	c.recentReceived = append(c.recentReceived, received)
+
 	message := &ricochet.Message{
 		Sender:     c.remoteEntity,
 		Recipient:  c.localEntity,
-		Timestamp:  timestamp,
-		Identifier: id,
+		Timestamp:  received.timestamp,
+		Identifier: received.id,
 		Status:     ricochet.Message_UNREAD,
-		Text:       text,
+		Text:       received.text,
+		Sequence:   c.nextSequence(),
+	}
+	if received.replyTo != nil {
+		message.InReplyTo = &ricochet.MessageReference{}
+		if replied := c.findRepliedMessage(*received.replyTo); replied != nil {
+			message.InReplyTo.Sequence = replied.Sequence
+		}
 	}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	// XXX The Qt implementation would discard duplicate messages by checking
-	// the most recent 5 messages for any received message that matches this one
-	// in both id and text. Should do that here.
+	// The contact has stopped typing this message
+	c.setRemoteTyping(false)
 
 	c.messages = append(c.messages, message)
+	c.trimMessages()
+	c.saveHistory()
 	event := ricochet.ConversationEvent{
 		Type: ricochet.ConversationEvent_RECEIVE,
 		Msg:  message,
 	}
 	c.events.Publish(event)
+	c.unreadChanged()
+	if !c.Contact.Muted() {
+		c.Contact.core.Notify.notify(c.Contact.Nickname(), c.remoteEntity.Address, message.Text)
+	}
 }
 
 func (c *Conversation) UpdateSentStatus(id uint64, success bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for i := len(c.messages) - 1; i >= 0; i-- {
-		message := c.messages[i]
-		if message.Status != ricochet.Message_SENDING || message.Identifier != id {
-			continue
-		}
+	// The protocol identifier is only used to find the message it was sent
+	// with; a reused or zero identifier can't match an older message
+	var message *ricochet.Message
+	if sequence, ok := c.sentIds[id]; ok {
+		message = c.messageBySequence(sequence)
+	}
+	if message != nil && (message.Status == ricochet.Message_SENDING || c.isTimedOut(message)) {
+		delete(c.sentIds, id)
+		delete(c.sentAt, message)
+		c.forgetTimedOut(message)
 
 		if success {
 			message.Status = ricochet.Message_DELIVERED
 		} else {
 			message.Status = ricochet.Message_ERROR
 		}
+		c.saveHistory()
 
 		event := ricochet.ConversationEvent{
 			Type: ricochet.ConversationEvent_UPDATE,
@@ -102,16 +266,21 @@ func (c *Conversation) UpdateSentStatus(id uint64, success bool) {
 	log.Printf("Ignoring ack for unknown message id %d", id)
 }
 
-func (c *Conversation) Send(text string) (*ricochet.Message, error) {
-	if len(text) == 0 {
-		return nil, errors.New("Message text is empty")
-	} else if len(text) > 2000 {
-		return nil, errors.New("Message is too long")
+// Send a message to the contact, or queue it to send when they're online. If
+// inReplyTo isn't zero, the message is a reply to the message in the
+// conversation with that sequence.
+func (c *Conversation) Send(text string, inReplyTo uint64) (*ricochet.Message, error) {
+	if err := c.Contact.core.Acceptance.CheckMessage(text); err != nil {
+		return nil, err
 	}
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	if inReplyTo != 0 && c.messageBySequence(inReplyTo) == nil {
+		return nil, errors.New("Replied message is not in the conversation")
+	}
+
 	if c.lastSentMessageId == 0 {
 		// Rand is seeded by Ricochet.Init
 		c.lastSentMessageId = rand.Uint32()
@@ -127,31 +296,58 @@ func (c *Conversation) Send(text string) (*ricochet.Message, error) {
 		Identifier: uint64(c.lastSentMessageId),
 		Status:     ricochet.Message_QUEUED,
 		Text:       text,
+		Sequence:   c.nextSequence(),
+	}
+	if inReplyTo != 0 {
+		message.InReplyTo = &ricochet.MessageReference{Sequence: inReplyTo}
 	}
 
 	if online, err := c.sendMessageToConnection(message); err != nil {
 		if online {
 			message.Status = ricochet.Message_ERROR
+		} else if c.isQueueFull() {
+			log.Printf("Too many messages queued for %s; not queueing another", c.remoteEntity.Address)
+			message.Status = ricochet.Message_ERROR
 		} else {
 			message.Status = ricochet.Message_QUEUED
+			if c.Contact != nil {
+				// Wake a dormant contact to deliver the message. This can't
+				// wait on the connection loop while the mutex is held.
+				go c.Contact.Connect()
+			}
 		}
 	} else {
 		message.Status = ricochet.Message_SENDING
 	}
 
+	// The contact clears the typing indicator when the message arrives
+	c.localTyping = false
+
 	c.messages = append(c.messages, message)
+	discardedUnread := c.trimMessages()
+	c.saveHistory()
 	event := ricochet.ConversationEvent{
 		Type: ricochet.ConversationEvent_SEND,
 		Msg:  message,
 	}
 	c.events.Publish(event)
+	if discardedUnread > 0 {
+		c.unreadChanged()
+	}
 
 	return message, nil
 }
 
-// Send all messages in the QUEUED state to the contact, if
-// a connection is available. Should be called after a new
-// connection is established.
+// Send all messages in the QUEUED state to the contact, in the order
+// they were written, if a connection is available. Should be called
+// after a new connection is established. Messages that have been queued
+// for longer than Ricochet.MaxQueuedMessageAge fail instead.
+//
+// Messages still waiting for an ack from a previous connection aren't
+// sent again, because the contact may have received them. An ack on the
+// new connection can't refer to them, so they fail once their ack times
+// out, and are sent again on the next connection if
+// Ricochet.ResendUnackedMessages is set.
 func (c *Conversation) SendQueuedMessages() int {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -161,6 +357,9 @@ func (c *Conversation) SendQueuedMessages() int {
 		return 0
 	}
 
+	changed := c.requeueTimedOutMessages()
+	c.expireQueuedMessages()
+
 	sent := 0
 	for _, message := range c.messages {
 		if message.Status != ricochet.Message_QUEUED {
@@ -178,6 +377,7 @@ func (c *Conversation) SendQueuedMessages() int {
 			message.Status = ricochet.Message_SENDING
 			sent++
 		}
+		changed = true
 
 		event := ricochet.ConversationEvent{
 			Type: ricochet.ConversationEvent_UPDATE,
@@ -186,9 +386,77 @@ func (c *Conversation) SendQueuedMessages() int {
 		c.events.Publish(event)
 	}
 
+	if changed {
+		c.saveHistory()
+	}
 	return sent
 }
 
+// Returns the message with the given Sequence, or nil. Assumes c.mutex is held.
+func (c *Conversation) messageBySequence(sequence uint64) *ricochet.Message {
+	for _, message := range c.messages {
+		if message.Sequence == sequence {
+			return message
+		}
+	}
+	return nil
+}
+
+// findRepliedMessage returns the message that a reply from the contact refers
+// to, or nil if it isn't known. Protocol identifiers can be reused, so this is
+// the most recent message with the identifier. Assumes c.mutex is held.
+func (c *Conversation) findRepliedMessage(replyTo chatReply) *ricochet.Message {
+	// Own messages of the contact are the ones we received
+	fromSelf := !replyTo.Own
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		message := c.messages[i]
+		if message.Identifier == uint64(replyTo.MessageID) && message.Sender.IsSelf == fromSelf {
+			return message
+		}
+	}
+	return nil
+}
+
+// Returns the Sequence for a new message. Assumes c.mutex is held.
+func (c *Conversation) nextSequence() uint64 {
+	c.lastSequence++
+	return c.lastSequence
+}
+
+// MarkReadBeforeSequence marks unread messages up to and including the received
+// message with the given sequence as read, and returns the number marked.
+func (c *Conversation) MarkReadBeforeSequence(sequence uint64) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	marked := 0
+	for _, message := range c.messages {
+		if message.Sequence > sequence {
+			break
+		}
+		if message.Status == ricochet.Message_UNREAD {
+			message.Status = ricochet.Message_READ
+			marked++
+
+			event := ricochet.ConversationEvent{
+				Type: ricochet.ConversationEvent_UPDATE,
+				Msg:  message,
+			}
+			c.events.Publish(event)
+		}
+	}
+
+	if marked > 0 {
+		c.saveHistory()
+		c.unreadChanged()
+	}
+	return marked
+}
+
+// MarkReadBeforeMessage is like MarkReadBeforeSequence, but finds the message
+// by its protocol identifier. If the peer has reused the identifier, this stops
+// at the first message that has it; use MarkReadBeforeSequence instead.
+//
 // XXX This is inefficient -- it'll usually only be marking the last message
 // or few messages. Need a better way to know what's unread.
 func (c *Conversation) MarkReadBeforeMessage(msgId uint64) int {
@@ -213,24 +481,144 @@ func (c *Conversation) MarkReadBeforeMessage(msgId uint64) int {
 		}
 	}
 
+	if marked > 0 {
+		c.saveHistory()
+		c.unreadChanged()
+	}
 	return marked
 }
 
-// Implement ChatChannelHandler (im.ricochet.chat)
-func (c *Conversation) ChatMessage(messageID uint32, when time.Time, message string) bool {
-	// XXX sanity checks, message contents, etc
-	log.Printf("chat message: %d %d %v %s", messageID, when, message)
+// loadHistory populates an empty conversation with messages from the persistent
+// history. Messages that were still being sent are queued to send again, and
+// queued messages past Ricochet.MaxQueuedMessageAge fail. Messages saved
+// without a sequence are given one. The contact's mutex is held
+// by the caller, so its unread count isn't updated; see ContactFromConfig.
+func (c *Conversation) loadHistory(messages []*ricochet.Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, message := range messages {
+		if message.Sequence > c.lastSequence {
+			c.lastSequence = message.Sequence
+		}
+	}
+	for _, message := range messages {
+		if message.Sequence == 0 {
+			message.Sequence = c.nextSequence()
+		}
+		if message.Sender.GetIsSelf() {
+			message.Sender = c.localEntity
+			message.Recipient = c.remoteEntity
+			if message.Status == ricochet.Message_SENDING {
+				message.Status = ricochet.Message_QUEUED
+			}
+		} else {
+			message.Sender = c.remoteEntity
+			message.Recipient = c.localEntity
+		}
+		c.messages = append(c.messages, message)
+	}
+	c.trimMessages()
+	c.expireQueuedMessages()
+}
+
+// trimMessages discards the oldest messages once the conversation has more than
+// Ricochet.MaxConversationMessages, so a contact can't grow it without bound by
+// flooding us. Unread messages are discarded like any other, with a warning,
+// but outbound messages that are still waiting to be sent are kept. Returns the
+// number of unread messages discarded. Assumes c.mutex is held.
+func (c *Conversation) trimMessages() int {
+	max := c.Contact.core.MaxConversationMessages
+	if max < 0 || len(c.messages) <= max {
+		return 0
+	}
+
+	excess := len(c.messages) - max
+	kept := make([]*ricochet.Message, 0, max)
+	discardedUnread := 0
+	for _, message := range c.messages {
+		pending := message.Status == ricochet.Message_QUEUED ||
+			message.Status == ricochet.Message_SENDING || c.isTimedOut(message)
+		if excess > 0 && !pending {
+			excess--
+			if message.Status == ricochet.Message_UNREAD {
+				discardedUnread++
+			}
+			continue
+		}
+		kept = append(kept, message)
+	}
+	c.messages = kept
+
+	if discardedUnread > 0 {
+		c.Contact.core.Log.Warnf("Discarded %d unread messages from %s to keep the conversation under %d messages", discardedUnread, c.remoteEntity.Address, max)
+	}
+	return discardedUnread
+}
+
+// saveHistory writes the messages of this conversation to the persistent
+// history, if enabled. Assumes c.mutex is held.
+func (c *Conversation) saveHistory() {
+	if history := c.Contact.core.History; history != nil {
+		history.Store(c.remoteEntity.Address, c.messages)
+	}
+}
+
+// Implement chatHandler (im.ricochet.chat)
+func (c *Conversation) ChatMessage(messageID uint32, when time.Time, message string, replyTo *chatReply) bool {
+	return c.chatMessage(0, messageID, when, message, replyTo)
+}
+
+// Handle a chat message received on an inbound channel, which is zero if unknown.
+// Retransmits are acked, but not added to the conversation again. replyTo is
+// the message that this one replies to, if any.
+func (c *Conversation) chatMessage(channel uint64, messageID uint32, when time.Time, message string, replyTo *chatReply) bool {
+	if c.isContactRemoved() {
+		log.Printf("protocol: Refusing chat message from %s, which is no longer a contact", c.remoteEntity.Address)
+		return false
+	}
+	if !IsMessageAcceptable(message) {
+		// Refused with a NACK; the peer will show the message as failed
+		log.Printf("Rejecting unacceptable chat message %d from %s; len: %d, encoded: %x", messageID, c.remoteEntity.Address, len(message), []byte(message))
+		return false
+	}
+	log.Printf("chat message: %d %v %s", messageID, when, message)
 
-	c.Receive(uint64(messageID), when.Unix(), message)
+	// The peer's clock (via the message's time delta) can't put messages in the future
+	if now := time.Now(); when.After(now) {
+		when = now
+	}
+	c.receive(receivedChatMessage{
+		channel:   channel,
+		id:        uint64(messageID),
+		timestamp: when.Unix(),
+		text:      message,
+		replyTo:   replyTo,
+	})
 	return true
 }
 
 func (c *Conversation) ChatMessageAck(messageID uint32, accepted bool) {
+	if c.isContactRemoved() {
+		log.Printf("protocol: Ignoring chat ack from %s, which is no longer a contact", c.remoteEntity.Address)
+		return
+	}
 	log.Printf("chat ack: %d %v", messageID, accepted)
 
 	c.UpdateSentStatus(uint64(messageID), accepted)
 }
 
+// isContactRemoved returns true if the contact of this conversation has been
+// removed from the contact list, but a connection to them is still delivering
+// chat events. Those must not be stored, which would recreate their history.
+func (c *Conversation) isContactRemoved() bool {
+	if c.Contact == nil {
+		return true
+	}
+	contactList := c.Contact.core.Identity.ContactList()
+	return contactList.ContactByAddress(c.remoteEntity.Address) != c.Contact
+}
+
 func (c *Conversation) sendMessageToConnection(message *ricochet.Message) (connected bool, err error) {
 	conn := c.Contact.Connection()
 	if conn == nil {
@@ -239,16 +627,28 @@ func (c *Conversation) sendMessageToConnection(message *ricochet.Message) (conne
 	}
 	connected = true
 
+	// The peer knows the replied message by the identifier it was sent or
+	// received with
+	var replyTo *chatReply
+	if message.InReplyTo != nil {
+		if replied := c.messageBySequence(message.InReplyTo.Sequence); replied != nil {
+			replyTo = &chatReply{
+				MessageID: uint32(replied.Identifier),
+				Own:       replied.Sender.IsSelf,
+			}
+		}
+	}
+
 	err = conn.Do(func() error {
-		channel := conn.Channel("im.ricochet.chat", channels.Outbound)
+		channel := conn.Channel(chatChannelType, channels.Outbound)
 		if channel == nil {
-			if ch, err := conn.RequestOpenChannel("im.ricochet.chat", &channels.ChatChannel{Handler: c}); err != nil {
+			if ch, err := conn.RequestOpenChannel(chatChannelType, &chatChannel{handler: c}); err != nil {
 				return err
 			} else {
 				channel = ch
 			}
 		}
-		chat, ok := channel.Handler.(*channels.ChatChannel)
+		chat, ok := channel.Handler.(*chatChannel)
 		if !ok {
 			channel.CloseChannel()
 			return errors.New("invalid chat channel")
@@ -258,12 +658,33 @@ func (c *Conversation) sendMessageToConnection(message *ricochet.Message) (conne
 		if message.Timestamp == 0 {
 			message.Timestamp = time.Now().Unix()
 		}
-		message.Identifier = uint64(chat.SendMessageWithTime(message.Text, time.Unix(message.Timestamp, 0)))
+		message.Identifier = uint64(chat.sendMessage(message.Text, time.Unix(message.Timestamp, 0), replyTo))
 		return nil
 	})
 
 	if err != nil {
 		log.Printf("chat send failed: %s", err)
+		return
+	}
+	// If the channel's ID counter has wrapped around to a message that was never
+	// acked, that message can no longer be told apart and won't be acked.
+	if sequence, ok := c.sentIds[message.Identifier]; ok {
+		if other := c.messageBySequence(sequence); other != nil && other != message &&
+			other.Status == ricochet.Message_SENDING {
+			log.Printf("Message id %d reused before it was acked", other.Identifier)
+			other.Status = ricochet.Message_ERROR
+			delete(c.sentAt, other)
+			c.events.Publish(ricochet.ConversationEvent{
+				Type: ricochet.ConversationEvent_UPDATE,
+				Msg:  other,
+			})
+		}
+	}
+
+	if c.sentIds == nil {
+		c.sentIds = make(map[uint64]uint64)
 	}
+	c.sentIds[message.Identifier] = message.Sequence
+	c.trackSent(message)
 	return
 }
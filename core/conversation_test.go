@@ -0,0 +1,525 @@
+package core
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// addSentMessage adds an outbound message that was sent with identifier and
+// is waiting for its ack
+func addSentMessage(conversation *Conversation, identifier uint64) *ricochet.Message {
+	conversation.mutex.Lock()
+	defer conversation.mutex.Unlock()
+
+	message := &ricochet.Message{
+		Sender:     conversation.localEntity,
+		Recipient:  conversation.remoteEntity,
+		Identifier: identifier,
+		Sequence:   conversation.nextSequence(),
+		Status:     ricochet.Message_SENDING,
+	}
+	conversation.messages = append(conversation.messages, message)
+	if conversation.sentIds == nil {
+		conversation.sentIds = make(map[uint64]uint64)
+	}
+	conversation.sentIds[identifier] = message.Sequence
+	conversation.trackSent(message)
+	return message
+}
+
+func messageStatus(conversation *Conversation, message *ricochet.Message) ricochet.Message_Status {
+	conversation.mutex.Lock()
+	defer conversation.mutex.Unlock()
+	return message.Status
+}
+
+// Chat channels start their message counter at a random uint32, so acks past
+// the int32 range must match the message they were sent with.
+func TestChatAckWideIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uint32
+	}{
+		{"zero", 0},
+		{"max int32", math.MaxInt32},
+		{"past int32", math.MaxInt32 + 1},
+		{"max uint32", math.MaxUint32},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			// A message whose identifier collides if the ack is truncated to int32
+			other := addSentMessage(conversation, uint64(uint32(int32(test.id))))
+			message := addSentMessage(conversation, uint64(test.id))
+
+			conversation.ChatMessageAck(test.id, true)
+			if status := messageStatus(conversation, message); status != ricochet.Message_DELIVERED {
+				t.Errorf("message %d is %v after its ack, expected DELIVERED", test.id, status)
+			}
+			if other != message {
+				if status := messageStatus(conversation, other); status != ricochet.Message_SENDING {
+					t.Errorf("ack for %d changed message %d to %v", test.id, other.Identifier, status)
+				}
+			}
+		})
+	}
+}
+
+// A new connection only sends queued messages; messages waiting for an ack
+// from the previous connection wait for their timeout instead of being
+// duplicated.
+func TestSendQueuedMessagesAfterReconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		timedOut bool
+		resend   bool
+		status   ricochet.Message_Status
+	}{
+		{"awaiting ack", false, true, ricochet.Message_SENDING},
+		{"timed out", true, false, ricochet.Message_ERROR},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			core.AckTimeout = 50 * time.Millisecond
+			core.ResendUnackedMessages = test.resend
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			conversation := contact.Conversation()
+			message := addSentMessage(conversation, 1)
+			if test.timedOut {
+				time.Sleep(2 * core.AckTimeout)
+			}
+
+			conn := newTestConnection(t, contact.Address())
+			contact.mutex.Lock()
+			contact.connection = conn
+			contact.mutex.Unlock()
+			if sent := conversation.SendQueuedMessages(); sent != 0 {
+				t.Errorf("sent %d messages on the new connection, expected none", sent)
+			}
+			if status := messageStatus(conversation, message); status != test.status {
+				t.Errorf("message is %v after reconnecting, expected %v", status, test.status)
+			}
+
+			// The ack can't arrive on the new connection
+			conversation.ChatMessageAck(1, true)
+			if status := messageStatus(conversation, message); status != test.status {
+				t.Errorf("message is %v after an ack on the new connection, expected %v", status, test.status)
+			}
+
+			time.Sleep(2 * core.AckTimeout)
+			if status := messageStatus(conversation, message); status != ricochet.Message_ERROR {
+				t.Errorf("message is %v after its ack timed out, expected ERROR", status)
+			}
+		})
+	}
+}
+
+// Chat events on a connection whose contact was removed, or that has no
+// contact, are refused instead of recreating the conversation or panicking
+func TestChatEventsForRemovedContact(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(list *ContactList, conversation *Conversation)
+		// Whether the message is stored and the ack is applied
+		handled bool
+	}{
+		{"contact", func(list *ContactList, conversation *Conversation) {}, true},
+		{"removed contact", func(list *ContactList, conversation *Conversation) {
+			list.mutex.Lock()
+			delete(list.contacts, conversation.remoteEntity.Address)
+			list.mutex.Unlock()
+		}, false},
+		{"no contact", func(list *ContactList, conversation *Conversation) {
+			conversation.Contact = nil
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			sent := addSentMessage(conversation, 1)
+			test.change(core.Identity.ContactList(), conversation)
+
+			if accepted := conversation.ChatMessage(7, time.Now(), "hello", nil); accepted != test.handled {
+				t.Errorf("message was accepted %v, expected %v", accepted, test.handled)
+			}
+			conversation.ChatMessageAck(1, true)
+
+			conversation.mutex.Lock()
+			received := len(conversation.messages) - 1
+			conversation.mutex.Unlock()
+			if stored := received == 1; stored != test.handled {
+				t.Errorf("message was stored %v, expected %v", stored, test.handled)
+			}
+			if acked := messageStatus(conversation, sent) == ricochet.Message_DELIVERED; acked != test.handled {
+				t.Errorf("ack was applied %v, expected %v", acked, test.handled)
+			}
+		})
+	}
+}
+
+// Messages from a peer that always sends identifier 0 can be marked as read by
+// their sequence; the identifier can only find the first of them
+func TestMarkReadWithZeroIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		// Index of the message to mark up to, and whether to use its
+		// identifier instead of its sequence
+		index      int
+		identifier bool
+		read       int
+	}{
+		{"first", 0, false, 1},
+		{"middle", 1, false, 2},
+		{"last", 2, false, 3},
+		{"by identifier", 2, true, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			conversation := contact.Conversation()
+			for _, text := range []string{"one", "two", "three"} {
+				if !conversation.ChatMessage(0, time.Now(), text, nil) {
+					t.Fatalf("message %q was refused", text)
+				}
+			}
+			messages := conversation.Messages()
+			if len(messages) != 3 {
+				t.Fatalf("received %d messages, expected 3", len(messages))
+			}
+
+			request := &ricochet.MarkConversationReadRequest{
+				Entity: &ricochet.Entity{Address: contact.Address()},
+			}
+			if test.identifier {
+				request.LastRecvIdentifier = messages[test.index].Identifier
+			} else {
+				request.LastRecvSequence = messages[test.index].Sequence
+			}
+			server := &RpcServer{Core: core}
+			if _, err := server.MarkConversationRead(context.Background(), request); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, message := range conversation.Messages() {
+				expected := ricochet.Message_UNREAD
+				if i < test.read {
+					expected = ricochet.Message_READ
+				}
+				if message.Status != expected {
+					t.Errorf("message %d is %v, expected %v", i, message.Status, expected)
+				}
+			}
+			if unread := conversation.UnreadCount(); unread != 3-test.read {
+				t.Errorf("%d messages are unread, expected %d", unread, 3-test.read)
+			}
+		})
+	}
+}
+
+// Acks are matched to the message that was sent with their identifier on the
+// current connection, so a zero or reused identifier can't change another
+// message that has the same one
+func TestAckWithCollidingIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		// Adds another message, and returns the identifier that the message
+		// being acked is sent with and the status the other message keeps
+		setup func(c *Conversation) (uint64, ricochet.Message_Status)
+		ack   uint32
+		// Status of the sent message after the ack
+		status ricochet.Message_Status
+	}{
+		{"zero identifier", func(c *Conversation) (uint64, ricochet.Message_Status) {
+			addSentMessage(c, 0)
+			c.ChatMessageAck(0, false)
+			return 0, ricochet.Message_ERROR
+		}, 0, ricochet.Message_DELIVERED},
+		{"sent on an earlier connection", func(c *Conversation) (uint64, ricochet.Message_Status) {
+			addSentMessage(c, 5)
+			c.mutex.Lock()
+			c.requeueTimedOutMessages()
+			c.mutex.Unlock()
+			return 5, ricochet.Message_SENDING
+		}, 5, ricochet.Message_DELIVERED},
+		{"received", func(c *Conversation) (uint64, ricochet.Message_Status) {
+			c.ChatMessage(5, time.Now(), "hello", nil)
+			return 5, ricochet.Message_UNREAD
+		}, 5, ricochet.Message_DELIVERED},
+		{"never sent", func(c *Conversation) (uint64, ricochet.Message_Status) {
+			c.ChatMessage(9, time.Now(), "hello", nil)
+			return 5, ricochet.Message_UNREAD
+		}, 9, ricochet.Message_SENDING},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			identifier, otherStatus := test.setup(conversation)
+			conversation.mutex.Lock()
+			other := conversation.messages[len(conversation.messages)-1]
+			conversation.mutex.Unlock()
+			message := addSentMessage(conversation, identifier)
+
+			conversation.ChatMessageAck(test.ack, true)
+			if status := messageStatus(conversation, message); status != test.status {
+				t.Errorf("message is %v after the ack, expected %v", status, test.status)
+			}
+			if status := messageStatus(conversation, other); status != otherStatus {
+				t.Errorf("other message with identifier %d is %v, expected %v", other.Identifier, status, otherStatus)
+			}
+			if message.Sequence == other.Sequence {
+				t.Errorf("messages share sequence %d", message.Sequence)
+			}
+		})
+	}
+}
+
+// Received messages that reuse an identifier are marked as read by sequence,
+// without changing a sent message that has the same identifier
+func TestMarkReadWithCollidingIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		// Index of the received message to mark up to
+		index int
+		read  int
+	}{
+		{"first", 0, 1},
+		{"second", 1, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			conversation.ChatMessage(7, time.Now(), "one", nil)
+			sent := addSentMessage(conversation, 7)
+			conversation.ChatMessage(7, time.Now(), "two", nil)
+
+			var received []*ricochet.Message
+			for _, message := range conversation.Messages() {
+				if !message.Sender.GetIsSelf() {
+					received = append(received, message)
+				}
+			}
+			if len(received) != 2 {
+				t.Fatalf("received %d messages, expected 2", len(received))
+			}
+			conversation.MarkReadBeforeSequence(received[test.index].Sequence)
+
+			if unread := conversation.UnreadCount(); unread != 2-test.read {
+				t.Errorf("%d messages are unread, expected %d", unread, 2-test.read)
+			}
+			if status := messageStatus(conversation, sent); status != ricochet.Message_SENDING {
+				t.Errorf("sent message is %v, expected SENDING", status)
+			}
+		})
+	}
+}
+
+// A retransmit of a received message on a new channel is acked but not stored
+// again, while distinct messages that reuse an identifier are stored
+func TestReceiveRetransmit(t *testing.T) {
+	type received struct {
+		// Index of the inbound channel it arrives on
+		channel int
+		id      uint32
+		// Seconds before now that it was sent
+		age  int
+		text string
+	}
+	window := make([]received, duplicateWindow)
+	for i := range window {
+		window[i] = received{0, uint32(10 + i), 0, "filler"}
+	}
+	tests := []struct {
+		name     string
+		messages []received
+		stored   int
+	}{
+		{"retransmit", []received{{0, 5, 0, "hi"}, {1, 5, 0, "hi"}}, 1},
+		{"retransmit with rounded time", []received{{0, 5, 1, "hi"}, {1, 5, 0, "hi"}}, 1},
+		{"repeated on the same channel", []received{{0, 5, 0, "hi"}, {0, 5, 0, "hi"}}, 2},
+		{"different text", []received{{0, 5, 0, "hi"}, {1, 5, 0, "hello"}}, 2},
+		{"wrapped identifier", []received{{0, 5, 3600, "hi"}, {1, 5, 0, "hi"}}, 2},
+		{"outside the window", append(append([]received{{0, 5, 0, "hi"}}, window...), received{1, 5, 0, "hi"}), duplicateWindow + 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			handlers := []*inboundChatHandler{
+				conversation.newInboundChatHandler(nil, nil),
+				conversation.newInboundChatHandler(nil, nil),
+			}
+			now := time.Now()
+			for _, m := range test.messages {
+				when := now.Add(-time.Duration(m.age) * time.Second)
+				if !handlers[m.channel].ChatMessage(m.id, when, m.text, nil) {
+					t.Errorf("message %d %q wasn't acked", m.id, m.text)
+				}
+			}
+			if stored := len(conversation.Messages()); stored != test.stored {
+				t.Errorf("stored %d messages, expected %d", stored, test.stored)
+			}
+		})
+	}
+}
+
+// A conversation keeps at most MaxConversationMessages, discarding the oldest
+// messages even if they're unread, but never outbound messages waiting to be
+// sent, and the unread count follows what's kept
+func TestConversationMessageLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		// Unread messages in the stored history, outbound messages queued, then
+		// messages received and sent, in that order
+		stored, queued, received, sent int
+		// Messages kept, and how many are unread
+		kept, unread int
+	}{
+		{"flood", 10, 0, 0, 25, 0, 10, 10},
+		{"under limit", 10, 0, 0, 10, 0, 10, 10},
+		{"queued kept", 10, 0, 3, 20, 0, 10, 7},
+		{"only queued", 10, 0, 12, 5, 0, 12, 0},
+		{"send discards unread", 10, 0, 0, 10, 2, 10, 8},
+		{"stored history", 10, 25, 0, 0, 0, 10, 10},
+		{"no limit", -1, 0, 0, 25, 0, 25, 25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			history, err := config.LoadHistoryFile(filepath.Join(t.TempDir(), "history.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			core.History = history
+			core.MaxConversationMessages = test.max
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+
+			var stored []*ricochet.Message
+			for i := 0; i < test.stored; i++ {
+				stored = append(stored, &ricochet.Message{
+					Sender:     &ricochet.Entity{Address: contact.Address()},
+					Identifier: uint64(i),
+					Timestamp:  time.Now().Unix(),
+					Status:     ricochet.Message_UNREAD,
+					Text:       fmt.Sprintf("stored %d", i),
+				})
+			}
+			history.Store(contact.Address(), stored)
+			// Created with the stored history, as it is when loading the config
+			contact.mutex.Lock()
+			contact.unreadCount = countUnread(stored)
+			contact.mutex.Unlock()
+			conversation := contact.Conversation()
+
+			for i := 0; i < test.queued; i++ {
+				if _, err := conversation.Send(fmt.Sprintf("queued %d", i), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < test.received; i++ {
+				if !conversation.ChatMessage(uint32(i), time.Now(), fmt.Sprintf("received %d", i), nil) {
+					t.Fatalf("message %d was refused", i)
+				}
+			}
+			for i := 0; i < test.sent; i++ {
+				if _, err := conversation.Send(fmt.Sprintf("sent %d", i), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			messages := conversation.Messages()
+			if len(messages) != test.kept {
+				t.Fatalf("conversation has %d messages, expected %d", len(messages), test.kept)
+			}
+			if unread := conversation.UnreadCount(); unread != test.unread {
+				t.Errorf("conversation has %d unread messages, expected %d", unread, test.unread)
+			}
+			if unread := contact.UnreadCount(); unread != test.unread {
+				t.Errorf("contact has %d unread messages, expected %d", unread, test.unread)
+			}
+			if stored := len(history.Messages(contact.Address())); stored != test.kept && test.stored == 0 {
+				t.Errorf("history has %d messages, expected %d", stored, test.kept)
+			}
+
+			// The newest messages are kept, and every queued message
+			queued := 0
+			for _, message := range messages {
+				if message.Status == ricochet.Message_QUEUED {
+					queued++
+				}
+			}
+			if queued != test.queued+test.sent {
+				t.Errorf("%d queued messages were kept, expected %d", queued, test.queued+test.sent)
+			}
+			last := messages[len(messages)-1].Text
+			if expected := fmt.Sprintf("received %d", test.received-1); test.received > 0 && test.queued+test.sent == 0 && last != expected {
+				t.Errorf("last message is %q, expected %q", last, expected)
+			}
+		})
+	}
+}
+
+// Received messages are stored with the time they were sent, from the time
+// delta sent with them, but a peer's clock can't put them in the future
+func TestReceivedMessageTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		// Time delta sent with the message, in seconds before now
+		delta int64
+		// Expected age of the stored message
+		age time.Duration
+	}{
+		{"now", 0, 0},
+		{"an hour ago", 3600, time.Hour},
+		{"a day ago", 86400, 24 * time.Hour},
+		{"in the future", -3600, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			conversation := contact.Conversation()
+			data, err := proto.Marshal(&chatPacket{ChatMessage: &chatMessage{
+				MessageId:   proto.Uint32(1),
+				MessageText: proto.String("hello"),
+				TimeDelta:   proto.Int64(test.delta),
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var acks [][]byte
+			newTestChatChannel(conversation, &acks).Packet(data)
+
+			messages := conversation.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("conversation has %d messages, expected 1", len(messages))
+			}
+			expected := time.Now().Add(-test.age).Unix()
+			if timestamp := messages[0].Timestamp; timestamp < expected-1 || timestamp > expected+1 {
+				t.Errorf("message has timestamp %v, expected %v", time.Unix(timestamp, 0), time.Unix(expected, 0))
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var (
+	InvalidDirectAddressError error = errors.New("Direct address must be host:port|onion")
+	NoDirectAddressError      error = errors.New("No direct address for onion")
+)
+
+// DirectResolver is a Resolver that connects to contacts directly instead of
+// through tor, for testing and loopback setups. Each contact's address is given
+// as host:port|onion, the same syntax go-ricochet accepts for 127.0.0.1, but
+// with any host and port accepted by net.ResolveTCPAddr, such as
+// [::1]:55555|jlq67qzo6s4yp3sp or localhost:55555|jlq67qzo6s4yp3sp.
+//
+// Onions without a direct address can't be reached.
+type DirectResolver struct {
+	// Map of onion hostname (with .onion) to host:port
+	addresses map[string]string
+}
+
+// NewDirectResolver returns a DirectResolver for addresses in host:port|onion
+// form. The onion may be a plain host, a hostname, or a ricochet: address.
+func NewDirectResolver(addresses []string) (*DirectResolver, error) {
+	dr := &DirectResolver{addresses: make(map[string]string)}
+	for _, address := range addresses {
+		sep := strings.LastIndex(address, "|")
+		if sep < 0 {
+			return nil, InvalidDirectAddressError
+		}
+		hostPort, onion := address[:sep], address[sep+1:]
+		if _, _, err := net.SplitHostPort(hostPort); err != nil {
+			return nil, InvalidDirectAddressError
+		}
+
+		hostname, ok := OnionFromAddress(onion)
+		if !ok {
+			hostname, ok = OnionFromPlainHost(strings.TrimSuffix(onion, ".onion"))
+		}
+		if !ok {
+			return nil, InvalidDirectAddressError
+		}
+		dr.addresses[hostname] = hostPort
+	}
+	return dr, nil
+}
+
+// Resolve connects to the direct address of the onion in address, which is
+// hostname:port; the port is ignored. It returns the onion hostname, not the
+// direct address, as the hostname that was reached.
+func (dr *DirectResolver) Resolve(address string) (net.Conn, string, error) {
+	hostname, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, "", err
+	}
+	hostPort, ok := dr.addresses[hostname]
+	if !ok {
+		return nil, "", NoDirectAddressError
+	}
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", hostPort)
+	if err != nil {
+		return nil, "", err
+	}
+	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	if err != nil {
+		return nil, "", err
+	}
+	return conn, hostname, nil
+}
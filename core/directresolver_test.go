@@ -0,0 +1,75 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+// Direct addresses accept any host that net.ResolveTCPAddr does, and connect
+// to it for the matching onion
+func TestDirectResolver(t *testing.T) {
+	tests := []struct {
+		name string
+		// Network to listen on, or empty if the address is invalid
+		network string
+		// Direct address without the port of the listener
+		host  string
+		onion string
+		valid bool
+	}{
+		{"ipv4", "tcp4", "127.0.0.1", "aaaaaaaaaaaaaaaa", true},
+		{"ipv6", "tcp6", "[::1]", "aaaaaaaaaaaaaaaa", true},
+		{"hostname", "tcp4", "localhost", "aaaaaaaaaaaaaaaa", true},
+		{"onion hostname", "tcp4", "127.0.0.1", "aaaaaaaaaaaaaaaa.onion", true},
+		{"ricochet address", "tcp4", "127.0.0.1", "ricochet:aaaaaaaaaaaaaaaa", true},
+		{"v3", "tcp4", "127.0.0.1", testV3PlainHost, true},
+		{"no port", "", "127.0.0.1", "aaaaaaaaaaaaaaaa", false},
+		{"no onion", "", "127.0.0.1:9878", "", false},
+		{"invalid onion", "", "127.0.0.1:9878", "aaaaaaaaaaaaaaa1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := test.host
+			if test.network != "" {
+				listener, err := net.Listen(test.network, host+":0")
+				if err != nil {
+					t.Skipf("can't listen on %s: %v", test.network, err)
+				}
+				defer listener.Close()
+				go func() {
+					if conn, err := listener.Accept(); err == nil {
+						conn.Close()
+					}
+				}()
+				_, port, _ := net.SplitHostPort(listener.Addr().String())
+				host += ":" + port
+			}
+
+			resolver, err := NewDirectResolver([]string{host + "|" + test.onion})
+			if (err == nil) != test.valid {
+				t.Fatalf("error is %v, expected valid %v", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+
+			conn, hostname, err := resolver.Resolve("bbbbbbbbbbbbbbbb.onion:9878")
+			if err != NoDirectAddressError {
+				t.Errorf("resolving another onion returned %v, expected NoDirectAddressError", err)
+			}
+			expected := "aaaaaaaaaaaaaaaa.onion"
+			if test.onion == testV3PlainHost {
+				expected = testV3PlainHost + ".onion"
+			}
+			conn, hostname, err = resolver.Resolve(expected + ":9878")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+			if hostname != expected {
+				t.Errorf("resolved hostname %q, expected %q", hostname, expected)
+			}
+		})
+	}
+}
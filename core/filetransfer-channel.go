@@ -0,0 +1,294 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"log"
+)
+
+// Channel type for file transfers. The sender opens a new channel for each transfer,
+// and each packet on the channel begins with one of the packet types below.
+const fileTransferChannelType = "im.ricochet.file-transfer"
+
+const (
+	// Sender: transfer id (8), size (8), sha256 (32), file name
+	ftPacketOffer byte = iota + 1
+	// Receiver: offset to begin sending from (8)
+	ftPacketAccept
+	// Receiver: no payload
+	ftPacketReject
+	// Sender: offset (8), data
+	ftPacketChunk
+	// Sender: all data has been sent
+	ftPacketComplete
+	// Either: transfer is cancelled and the channel will be closed
+	ftPacketCancel
+	// Receiver: 1 if the file was received and verified, or 0
+	ftPacketResult
+)
+
+// Size of the file data in each chunk packet, which must fit in a single
+// protocol packet with its header.
+const fileTransferChunkSize = 32 * 1024
+
+type fileTransferOffer struct {
+	TransferId uint64
+	Size       uint64
+	Hash       [sha256.Size]byte
+	Name       string
+}
+
+func encodeFileTransferOffer(offer *fileTransferOffer) []byte {
+	data := make([]byte, 17, 17+sha256.Size+len(offer.Name))
+	data[0] = ftPacketOffer
+	binary.BigEndian.PutUint64(data[1:], offer.TransferId)
+	binary.BigEndian.PutUint64(data[9:], offer.Size)
+	data = append(data, offer.Hash[:]...)
+	return append(data, offer.Name...)
+}
+
+func decodeFileTransferOffer(data []byte) (*fileTransferOffer, error) {
+	if len(data) < 17+sha256.Size {
+		return nil, errors.New("Invalid file transfer offer")
+	}
+	offer := &fileTransferOffer{
+		TransferId: binary.BigEndian.Uint64(data[1:]),
+		Size:       binary.BigEndian.Uint64(data[9:]),
+		Name:       string(data[17+sha256.Size:]),
+	}
+	copy(offer.Hash[:], data[17:])
+	return offer, nil
+}
+
+func encodeFileTransferAccept(offset uint64) []byte {
+	data := make([]byte, 9)
+	data[0] = ftPacketAccept
+	binary.BigEndian.PutUint64(data[1:], offset)
+	return data
+}
+
+func encodeFileTransferChunk(offset uint64, chunk []byte) []byte {
+	data := make([]byte, 9, 9+len(chunk))
+	data[0] = ftPacketChunk
+	binary.BigEndian.PutUint64(data[1:], offset)
+	return append(data, chunk...)
+}
+
+// fileTransferChannel implements channels.Handler for the file transfer channel.
+// Outbound channels are created with the transfer they offer; inbound channels
+// find or create their transfer when the offer is received.
+type fileTransferChannel struct {
+	list     *FileTransferList
+	contact  *Contact
+	conn     *connection.Connection
+	channel  *channels.Channel
+	transfer *FileTransfer
+}
+
+func (h *fileTransferChannel) Type() string {
+	return fileTransferChannelType
+}
+
+func (h *fileTransferChannel) OnlyClientCanOpen() bool {
+	return false
+}
+
+func (h *fileTransferChannel) Singleton() bool {
+	return false
+}
+
+func (h *fileTransferChannel) Bidirectional() bool {
+	return true
+}
+
+func (h *fileTransferChannel) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+
+func (h *fileTransferChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	h.channel = channel
+	channel.Pending = false
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.AckOpenChannel(channel.ID), nil
+}
+
+func (h *fileTransferChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	h.channel = channel
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.OpenChannel(channel.ID, h.Type()), nil
+}
+
+func (h *fileTransferChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	h.contact.outboundChannelResult(fileTransferChannelType, err, crm)
+	if err != nil || !crm.GetOpened() {
+		log.Printf("File transfer channel to %s was rejected: %v", h.contact.Address(), err)
+		return
+	}
+	h.channel.Pending = false
+
+	ft := h.transfer
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	if ft.channel != h.channel {
+		return
+	}
+	h.channel.SendMessage(encodeFileTransferOffer(&fileTransferOffer{
+		TransferId: ft.transferId,
+		Size:       ft.data.Size,
+		Hash:       ft.hash,
+		Name:       ft.data.Name,
+	}))
+}
+
+func (h *fileTransferChannel) Closed(err error) {
+	ft := h.transfer
+	if ft == nil {
+		return
+	}
+
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	if ft.channel != h.channel {
+		return
+	}
+	// Closed without finishing the transfer; it can resume on a later connection
+	ft.detach()
+	if ft.data.Status == ricochet.FileTransfer_TRANSFERRING {
+		ft.data.Status = ricochet.FileTransfer_INTERRUPTED
+		ft.publish()
+	}
+}
+
+func (h *fileTransferChannel) Packet(data []byte) {
+	if h.channel.Pending || len(data) < 1 {
+		return
+	}
+
+	if h.transfer == nil {
+		// The first packet on an inbound channel must be the offer
+		if data[0] != ftPacketOffer || h.channel.Direction != channels.Inbound {
+			h.channel.CloseChannel()
+			return
+		}
+		offer, err := decodeFileTransferOffer(data)
+		if err != nil {
+			log.Printf("File transfer from %s: %v", h.contact.Address(), err)
+			h.channel.CloseChannel()
+			return
+		}
+		h.transfer = h.list.inboundOffer(h.contact, h.conn, h.channel, offer)
+		if h.transfer == nil {
+			h.channel.SendMessage([]byte{ftPacketReject})
+			h.channel.CloseChannel()
+		}
+		return
+	}
+
+	ft := h.transfer
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	if ft.channel != h.channel {
+		// Transfer was finished or moved to another channel
+		h.channel.CloseChannel()
+		return
+	}
+
+	var err error
+	if h.channel.Direction == channels.Outbound {
+		err = h.senderPacket(ft, data)
+	} else {
+		err = h.receiverPacket(ft, data)
+	}
+	if err != nil {
+		log.Printf("File transfer with %s failed: %v", h.contact.Address(), err)
+		h.channel.SendMessage([]byte{ftPacketCancel})
+		ft.finish(ricochet.FileTransfer_FAILED, err.Error())
+	}
+	if ft.isFinished() {
+		h.channel.CloseChannel()
+	}
+}
+
+// Handle a packet received by the sender of a transfer. Assumes ft.mutex is held.
+func (h *fileTransferChannel) senderPacket(ft *FileTransfer, data []byte) error {
+	switch data[0] {
+	case ftPacketAccept:
+		if len(data) != 9 || ft.data.Status == ricochet.FileTransfer_TRANSFERRING {
+			return errors.New("Unexpected accept packet")
+		}
+		offset := binary.BigEndian.Uint64(data[1:])
+		if offset > ft.data.Size {
+			return errors.New("Invalid offset in accept packet")
+		}
+		ft.data.Status = ricochet.FileTransfer_TRANSFERRING
+		ft.data.Transferred = offset
+		ft.publish()
+		go ft.sendData(h.channel, offset)
+
+	case ftPacketReject:
+		ft.finish(ricochet.FileTransfer_REJECTED, "")
+
+	case ftPacketCancel:
+		ft.finish(ricochet.FileTransfer_CANCELLED, "")
+
+	case ftPacketResult:
+		if len(data) != 2 || ft.data.Status != ricochet.FileTransfer_TRANSFERRING {
+			return errors.New("Unexpected result packet")
+		}
+		if data[1] == 1 {
+			ft.finish(ricochet.FileTransfer_COMPLETE, "")
+		} else {
+			ft.finish(ricochet.FileTransfer_FAILED, "Contact could not verify the file")
+		}
+
+	default:
+		return errors.New("Unexpected packet type")
+	}
+	return nil
+}
+
+// Handle a packet received by the recipient of a transfer. Assumes ft.mutex is held.
+func (h *fileTransferChannel) receiverPacket(ft *FileTransfer, data []byte) error {
+	switch data[0] {
+	case ftPacketChunk:
+		if len(data) < 9 || ft.data.Status != ricochet.FileTransfer_TRANSFERRING || ft.file == nil {
+			return errors.New("Unexpected chunk packet")
+		}
+		offset := binary.BigEndian.Uint64(data[1:])
+		chunk := data[9:]
+		if offset != ft.data.Transferred || offset+uint64(len(chunk)) > ft.data.Size {
+			return errors.New("Invalid offset in chunk packet")
+		}
+		if _, err := ft.file.Write(chunk); err != nil {
+			return err
+		}
+		ft.data.Transferred += uint64(len(chunk))
+		ft.publishProgress()
+
+	case ftPacketComplete:
+		if ft.data.Status != ricochet.FileTransfer_TRANSFERRING {
+			return errors.New("Unexpected complete packet")
+		}
+		if err := ft.completeInbound(); err != nil {
+			log.Printf("File transfer from %s could not be completed: %v", h.contact.Address(), err)
+			h.channel.SendMessage([]byte{ftPacketResult, 0})
+			ft.finish(ricochet.FileTransfer_FAILED, err.Error())
+		} else {
+			h.channel.SendMessage([]byte{ftPacketResult, 1})
+			ft.finish(ricochet.FileTransfer_COMPLETE, "")
+		}
+
+	case ftPacketCancel:
+		ft.finish(ricochet.FileTransfer_CANCELLED, "")
+
+	default:
+		return errors.New("Unexpected packet type")
+	}
+	return nil
+}
@@ -0,0 +1,594 @@
+package core
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MaxFileTransferSize is the largest file that will be sent or accepted
+const MaxFileTransferSize = 100 * 1024 * 1024
+
+// Minimum time between UPDATE events for the progress of a transfer
+const fileTransferProgressInterval = time.Second
+
+// FileTransferList tracks all file transfers with contacts for the identity.
+// Transfers are kept for the lifetime of the backend, and are not persistent.
+type FileTransferList struct {
+	core   *Ricochet
+	mutex  sync.Mutex
+	events *utils.Publisher
+
+	transfers map[uint32]*FileTransfer
+	lastId    uint32
+}
+
+type FileTransfer struct {
+	list    *FileTransferList
+	Contact *Contact
+
+	mutex sync.Mutex
+	data  ricochet.FileTransfer
+
+	// Identifies the transfer with the peer across connections, to allow resuming
+	transferId uint64
+	hash       [sha256.Size]byte
+
+	// Channel of the current attempt, or nil if there is none
+	conn    *connection.Connection
+	channel *channels.Channel
+	// Partial file being written by an inbound transfer
+	file *os.File
+
+	lastProgress time.Time
+}
+
+func NewFileTransferList(core *Ricochet) *FileTransferList {
+	return &FileTransferList{
+		core:      core,
+		events:    utils.CreatePublisher(),
+		transfers: make(map[uint32]*FileTransfer),
+	}
+}
+
+func (fl *FileTransferList) EventMonitor() utils.Subscribable {
+	return fl.events
+}
+
+func (fl *FileTransferList) Transfers() []*FileTransfer {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	re := make([]*FileTransfer, 0, len(fl.transfers))
+	for _, ft := range fl.transfers {
+		re = append(re, ft)
+	}
+	return re
+}
+
+func (fl *FileTransferList) TransferByIdentifier(id uint32) *FileTransfer {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	return fl.transfers[id]
+}
+
+func (fl *FileTransferList) add(ft *FileTransfer) {
+	fl.mutex.Lock()
+	fl.lastId++
+	ft.data.Identifier = fl.lastId
+	fl.transfers[ft.data.Identifier] = ft
+	fl.mutex.Unlock()
+
+	fl.events.Publish(ricochet.FileTransferEvent{
+		Type:     ricochet.FileTransferEvent_ADD,
+		Transfer: ft.Data(),
+	})
+}
+
+// SendFile offers the file at path to a contact. The offer is delivered when the
+// contact is online, and the transfer begins when they accept it.
+func (fl *FileTransferList) SendFile(contact *Contact, path string) (*FileTransfer, error) {
+	if supported, known := contact.SupportsChannel(fileTransferChannelType); known && !supported {
+		return nil, errors.New("Contact does not support file transfers")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return nil, errors.New("Not a regular file")
+	} else if info.Size() > MaxFileTransferSize {
+		return nil, errors.New("File is too large")
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+
+	var idBytes [8]byte
+	if _, err := cryptorand.Read(idBytes[:]); err != nil {
+		return nil, err
+	}
+
+	ft := &FileTransfer{
+		list:    fl,
+		Contact: contact,
+		data: ricochet.FileTransfer{
+			Address:   contact.Address(),
+			Direction: ricochet.FileTransfer_OUTBOUND,
+			Name:      filepath.Base(path),
+			Size:      uint64(info.Size()),
+			Status:    ricochet.FileTransfer_OFFERED,
+			Path:      path,
+		},
+		transferId: binary.BigEndian.Uint64(idBytes[:]),
+	}
+	copy(ft.hash[:], hash.Sum(nil))
+
+	fl.add(ft)
+	go ft.offer()
+	return ft, nil
+}
+
+// contactConnected is called when a new connection to contact is established, to
+// deliver pending offers and resume interrupted outbound transfers.
+func (fl *FileTransferList) contactConnected(contact *Contact) {
+	for _, ft := range fl.Transfers() {
+		if ft.Contact != contact {
+			continue
+		}
+		ft.mutex.Lock()
+		resume := ft.data.Direction == ricochet.FileTransfer_OUTBOUND && ft.channel == nil &&
+			(ft.data.Status == ricochet.FileTransfer_OFFERED || ft.data.Status == ricochet.FileTransfer_INTERRUPTED)
+		ft.mutex.Unlock()
+		if resume {
+			go ft.offer()
+		}
+	}
+}
+
+// connectionClosed is called when a contact connection is closed, to interrupt any
+// transfers using that connection.
+func (fl *FileTransferList) connectionClosed(conn *connection.Connection) {
+	for _, ft := range fl.Transfers() {
+		ft.mutex.Lock()
+		if ft.conn == conn {
+			ft.detach()
+			if ft.data.Status == ricochet.FileTransfer_TRANSFERRING {
+				ft.data.Status = ricochet.FileTransfer_INTERRUPTED
+			}
+			ft.publish()
+			if ft.data.Direction == ricochet.FileTransfer_OUTBOUND && !ft.isFinished() {
+				// The contact may already have a replacement connection
+				go ft.offer()
+			}
+		}
+		ft.mutex.Unlock()
+	}
+}
+
+// inboundOffer handles an offer received on an inbound channel. If the offer
+// matches a known transfer that was interrupted or accepted while the contact was
+// offline, the transfer resumes immediately. Otherwise, a new offer is added for
+// the user to accept or reject. nil is returned if the offer must be rejected.
+//
+// Called from the connection's Process routine.
+func (fl *FileTransferList) inboundOffer(contact *Contact, conn *connection.Connection, channel *channels.Channel, offer *fileTransferOffer) *FileTransfer {
+	if offer.Size > MaxFileTransferSize {
+		log.Printf("Rejecting file transfer from %s of %d bytes, which is too large", contact.Address(), offer.Size)
+		return nil
+	}
+	name := filepath.Base(offer.Name)
+	if !IsMessageAcceptable(name) || name == "." || name == string(filepath.Separator) {
+		log.Printf("Rejecting file transfer from %s with unacceptable name; encoded: %x", contact.Address(), []byte(offer.Name))
+		return nil
+	}
+
+	for _, ft := range fl.Transfers() {
+		if ft.Contact != contact || ft.data.Direction != ricochet.FileTransfer_INBOUND {
+			continue
+		}
+		ft.mutex.Lock()
+		if ft.transferId != offer.TransferId || ft.hash != offer.Hash || ft.data.Size != offer.Size {
+			ft.mutex.Unlock()
+			continue
+		}
+		defer ft.mutex.Unlock()
+
+		if ft.data.Status != ricochet.FileTransfer_OFFERED && ft.data.Status != ricochet.FileTransfer_INTERRUPTED {
+			return nil
+		}
+		ft.detach()
+		ft.conn = conn
+		ft.channel = channel
+		if ft.data.Path == "" {
+			// Still waiting for the user to accept
+			return ft
+		}
+
+		// Continue from the end of the partial file
+		var offset uint64
+		if info, err := os.Stat(ft.partialPath()); err == nil && uint64(info.Size()) <= ft.data.Size {
+			offset = uint64(info.Size())
+		}
+		if err := ft.start(offset); err != nil {
+			log.Printf("Resuming file transfer failed: %v", err)
+			ft.finish(ricochet.FileTransfer_FAILED, err.Error())
+			return nil
+		}
+		channel.SendMessage(encodeFileTransferAccept(offset))
+		ft.publish()
+		return ft
+	}
+
+	ft := &FileTransfer{
+		list:    fl,
+		Contact: contact,
+		data: ricochet.FileTransfer{
+			Address:   contact.Address(),
+			Direction: ricochet.FileTransfer_INBOUND,
+			Name:      name,
+			Size:      offer.Size,
+			Status:    ricochet.FileTransfer_OFFERED,
+		},
+		transferId: offer.TransferId,
+		hash:       offer.Hash,
+		conn:       conn,
+		channel:    channel,
+	}
+	fl.add(ft)
+	return ft
+}
+
+func (ft *FileTransfer) Data() *ricochet.FileTransfer {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	return proto.Clone(&ft.data).(*ricochet.FileTransfer)
+}
+
+// Publish an UPDATE event. Assumes mutex is held.
+func (ft *FileTransfer) publish() {
+	ft.lastProgress = time.Now()
+	ft.list.events.Publish(ricochet.FileTransferEvent{
+		Type:     ricochet.FileTransferEvent_UPDATE,
+		Transfer: proto.Clone(&ft.data).(*ricochet.FileTransfer),
+	})
+}
+
+// Publish an UPDATE event for transfer progress, if one hasn't been sent recently.
+// Assumes mutex is held.
+func (ft *FileTransfer) publishProgress() {
+	if time.Since(ft.lastProgress) >= fileTransferProgressInterval {
+		ft.publish()
+	}
+}
+
+// Forget the channel and partial file for the current attempt. Assumes mutex is held.
+func (ft *FileTransfer) detach() {
+	ft.conn = nil
+	ft.channel = nil
+	if ft.file != nil {
+		ft.file.Close()
+		ft.file = nil
+	}
+}
+
+func (ft *FileTransfer) isFinished() bool {
+	switch ft.data.Status {
+	case ricochet.FileTransfer_COMPLETE, ricochet.FileTransfer_REJECTED,
+		ricochet.FileTransfer_CANCELLED, ricochet.FileTransfer_FAILED:
+		return true
+	}
+	return false
+}
+
+func (ft *FileTransfer) partialPath() string {
+	return ft.data.Path + ".part"
+}
+
+// Finish the transfer with a final status. Assumes mutex is held.
+func (ft *FileTransfer) finish(status ricochet.FileTransfer_Status, errorMessage string) {
+	ft.detach()
+	if ft.data.Direction == ricochet.FileTransfer_INBOUND && ft.data.Path != "" &&
+		status != ricochet.FileTransfer_COMPLETE {
+		os.Remove(ft.partialPath())
+	}
+	ft.data.Status = status
+	ft.data.ErrorMessage = errorMessage
+	ft.publish()
+}
+
+// Send a packet on a channel of conn from outside of the connection's Process
+// routine. The transfer's mutex must not be held, because channel handlers lock
+// it from the Process routine while conn.Do is waiting.
+func sendFileTransferPacket(conn *connection.Connection, channel *channels.Channel, data []byte) error {
+	if conn == nil || channel == nil {
+		return errors.New("not connected")
+	}
+	return conn.Do(func() error {
+		channel.SendMessage(data)
+		return nil
+	})
+}
+
+// offer opens a new channel to deliver the offer for an outbound transfer, if the
+// contact is connected. The offer itself is sent when the channel is opened.
+func (ft *FileTransfer) offer() {
+	conn := ft.Contact.Connection()
+	if conn == nil {
+		return
+	}
+
+	ft.mutex.Lock()
+	if ft.conn != nil || ft.isFinished() {
+		// Already offered on a connection, or nothing to do
+		ft.mutex.Unlock()
+		return
+	}
+	handler := &fileTransferChannel{
+		list:     ft.list,
+		contact:  ft.Contact,
+		conn:     conn,
+		transfer: ft,
+	}
+	ft.conn = conn
+	ft.mutex.Unlock()
+
+	err := conn.Do(func() error {
+		channel, err := conn.RequestOpenChannel(handler.Type(), handler)
+		if err == nil {
+			ft.mutex.Lock()
+			ft.channel = channel
+			ft.mutex.Unlock()
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("Opening file transfer channel failed: %v", err)
+		ft.mutex.Lock()
+		if ft.conn == conn {
+			ft.detach()
+		}
+		ft.mutex.Unlock()
+	}
+}
+
+// Accept an inbound offer and save the file to path once it has been received.
+// If the contact is not connected, the transfer starts when they reconnect.
+func (ft *FileTransfer) Accept(path string) error {
+	if path == "" {
+		return errors.New("No destination for file")
+	} else if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return errors.New("Destination file already exists")
+	}
+
+	ft.mutex.Lock()
+	if ft.data.Direction != ricochet.FileTransfer_INBOUND || ft.data.Status != ricochet.FileTransfer_OFFERED {
+		ft.mutex.Unlock()
+		return errors.New("Transfer is not an inbound offer")
+	}
+
+	ft.data.Path = path
+	conn, channel := ft.conn, ft.channel
+	err := errors.New("not connected")
+	if conn != nil && channel != nil {
+		err = ft.start(0)
+	}
+	if err != nil {
+		ft.interrupt(err)
+		ft.mutex.Unlock()
+		return nil
+	}
+	ft.publish()
+	ft.mutex.Unlock()
+
+	if err := sendFileTransferPacket(conn, channel, encodeFileTransferAccept(0)); err != nil {
+		ft.mutex.Lock()
+		if ft.channel == channel {
+			ft.interrupt(err)
+		}
+		ft.mutex.Unlock()
+	}
+	return nil
+}
+
+// Leave an accepted inbound transfer to start when the contact reconnects.
+// Assumes mutex is held.
+func (ft *FileTransfer) interrupt(err error) {
+	log.Printf("File transfer not started yet: %v", err)
+	ft.detach()
+	ft.data.Status = ricochet.FileTransfer_INTERRUPTED
+	ft.publish()
+}
+
+// Start receiving an accepted inbound transfer from offset, which is the size of the
+// partial file so far. The caller must send the accept packet. Assumes mutex is held.
+func (ft *FileTransfer) start(offset uint64) error {
+	if err := ft.openPartialFile(offset); err != nil {
+		return err
+	}
+	ft.data.Status = ricochet.FileTransfer_TRANSFERRING
+	return nil
+}
+
+// Open the partial file for an inbound transfer, truncated to offset. Assumes mutex
+// is held.
+func (ft *FileTransfer) openPartialFile(offset uint64) error {
+	file, err := os.OpenFile(ft.partialPath(), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(int64(offset)); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	ft.file = file
+	ft.data.Transferred = offset
+	return nil
+}
+
+// Reject an inbound offer
+func (ft *FileTransfer) Reject() error {
+	ft.mutex.Lock()
+	if ft.data.Direction != ricochet.FileTransfer_INBOUND || ft.data.Status != ricochet.FileTransfer_OFFERED {
+		ft.mutex.Unlock()
+		return errors.New("Transfer is not an inbound offer")
+	}
+
+	conn, channel := ft.conn, ft.channel
+	ft.finish(ricochet.FileTransfer_REJECTED, "")
+	ft.mutex.Unlock()
+
+	sendFileTransferPacket(conn, channel, []byte{ftPacketReject})
+	return nil
+}
+
+// Cancel a transfer in any direction that is not already finished
+func (ft *FileTransfer) Cancel() error {
+	ft.mutex.Lock()
+	if ft.isFinished() {
+		ft.mutex.Unlock()
+		return errors.New("Transfer is already finished")
+	}
+
+	conn, channel := ft.conn, ft.channel
+	ft.finish(ricochet.FileTransfer_CANCELLED, "")
+	ft.mutex.Unlock()
+
+	sendFileTransferPacket(conn, channel, []byte{ftPacketCancel})
+	return nil
+}
+
+// Goroutine to send the file data for an outbound transfer on channel, starting
+// from offset.
+func (ft *FileTransfer) sendData(channel *channels.Channel, offset uint64) {
+	ft.mutex.Lock()
+	path, size := ft.data.Path, ft.data.Size
+	ft.mutex.Unlock()
+
+	fail := func(err error) {
+		log.Printf("File transfer send failed: %v", err)
+		ft.mutex.Lock()
+		if ft.channel != channel || ft.isFinished() {
+			ft.mutex.Unlock()
+			return
+		}
+		conn := ft.conn
+		ft.finish(ricochet.FileTransfer_FAILED, err.Error())
+		ft.mutex.Unlock()
+		sendFileTransferPacket(conn, channel, []byte{ftPacketCancel})
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		fail(err)
+		return
+	}
+
+	buf := make([]byte, fileTransferChunkSize)
+	for offset < size {
+		n, err := file.Read(buf)
+		if n == 0 && err != nil {
+			fail(fmt.Errorf("Reading file: %v", err))
+			return
+		} else if offset+uint64(n) > size {
+			fail(errors.New("File has changed"))
+			return
+		}
+
+		ft.mutex.Lock()
+		if ft.channel != channel || ft.isFinished() {
+			// Cancelled or interrupted
+			ft.mutex.Unlock()
+			return
+		}
+		conn := ft.conn
+		ft.mutex.Unlock()
+
+		if err := sendFileTransferPacket(conn, channel, encodeFileTransferChunk(offset, buf[:n])); err != nil {
+			// Connection closed; will be resumed on reconnection
+			return
+		}
+		offset += uint64(n)
+
+		ft.mutex.Lock()
+		if ft.channel == channel {
+			ft.data.Transferred = offset
+			ft.publishProgress()
+		}
+		ft.mutex.Unlock()
+	}
+
+	ft.mutex.Lock()
+	if ft.channel != channel {
+		ft.mutex.Unlock()
+		return
+	}
+	conn := ft.conn
+	ft.publish()
+	ft.mutex.Unlock()
+	sendFileTransferPacket(conn, channel, []byte{ftPacketComplete})
+}
+
+// Verify and move a completely received file into place. Assumes mutex is held.
+func (ft *FileTransfer) completeInbound() error {
+	if ft.file == nil {
+		return errors.New("Transfer was not started")
+	}
+	ft.file.Close()
+	ft.file = nil
+
+	if ft.data.Transferred != ft.data.Size {
+		return errors.New("Received file is incomplete")
+	}
+
+	file, err := os.Open(ft.partialPath())
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	if sum != ft.hash {
+		return errors.New("Received file does not match the sender's hash")
+	}
+
+	if _, err := os.Stat(ft.data.Path); !os.IsNotExist(err) {
+		return errors.New("Destination file already exists")
+	}
+	return os.Rename(ft.partialPath(), ft.data.Path)
+}
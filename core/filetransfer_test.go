@@ -0,0 +1,73 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Accept, Reject and Cancel must not hold the transfer's mutex while waiting
+// for the connection's Process routine, which locks it to handle packets.
+func TestFileTransferActionsReleaseMutex(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(ft *FileTransfer) error
+		status ricochet.FileTransfer_Status
+	}{
+		{"accept", func(ft *FileTransfer) error {
+			return ft.Accept(filepath.Join(t.TempDir(), "file"))
+		}, ricochet.FileTransfer_TRANSFERRING},
+		{"reject", (*FileTransfer).Reject, ricochet.FileTransfer_REJECTED},
+		{"cancel", (*FileTransfer).Cancel, ricochet.FileTransfer_CANCELLED},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := newTestConnection(t, "")
+
+			sent := make(chan []byte, 1)
+			channel := &channels.Channel{
+				SendMessage:  func(data []byte) { sent <- data },
+				CloseChannel: func() {},
+			}
+			ft := &FileTransfer{
+				list: NewFileTransferList(nil),
+				data: ricochet.FileTransfer{
+					Direction: ricochet.FileTransfer_INBOUND,
+					Status:    ricochet.FileTransfer_OFFERED,
+				},
+				conn:    conn,
+				channel: channel,
+			}
+			handler := &fileTransferChannel{conn: conn, channel: channel, transfer: ft}
+
+			// Process isn't running yet, so the action blocks in conn.Do
+			result := make(chan error, 1)
+			go func() { result <- test.action(ft) }()
+
+			handled := make(chan struct{})
+			go func() {
+				for ft.Data().Status != test.status {
+					time.Sleep(time.Millisecond)
+				}
+				handler.Packet([]byte{ftPacketCancel})
+				close(handled)
+			}()
+			select {
+			case <-handled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("packet handler blocked on the transfer's mutex")
+			}
+
+			go conn.Process(&testConnectionHandler{})
+			if err := <-result; err != nil {
+				t.Errorf("%s failed: %v", test.name, err)
+			}
+			if len(sent) != 1 {
+				t.Errorf("%s sent %d packets, expected 1", test.name, len(sent))
+			}
+		})
+	}
+}
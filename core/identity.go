@@ -1,18 +1,25 @@
 package core
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"errors"
 	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	protocol "github.com/s-rah/go-ricochet"
-	connection "github.com/s-rah/go-ricochet/connection"
-	"github.com/yawning/bulb/utils/pkcs1"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
+	"strings"
 	"sync"
+	"time"
 )
 
+// How long a connection that failed authentication is kept open for the
+// client to read the result and close it
+const rejectedConnectionLinger = 5 * time.Second
+
 // Identity represents the local user, including their contact address,
 // and contains the contacts list.
 type Identity struct {
@@ -21,17 +28,30 @@ type Identity struct {
 	mutex sync.Mutex
 
 	address     string
-	privateKey  *rsa.PrivateKey
+	privateKey  crypto.PrivateKey
 	contactList *ContactList
+	transfers   *FileTransferList
+	// Set once the onion service has been published. The listener republishes
+	// it when the control connection comes back, so this isn't cleared.
+	published bool
 
 	ConversationStream *utils.Publisher
 }
 
+// ServicePublished returns true once the identity's onion service has been
+// published through tor
+func (me *Identity) ServicePublished() bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.published
+}
+
 func CreateIdentity(core *Ricochet) (*Identity, error) {
 	me := &Identity{
 		core:               core,
 		ConversationStream: utils.CreatePublisher(),
 	}
+	me.transfers = NewFileTransferList(core)
 
 	if err := me.loadIdentity(); err != nil {
 		log.Printf("Failed loading identity: %v", err)
@@ -46,20 +66,20 @@ func CreateIdentity(core *Ricochet) (*Identity, error) {
 	me.contactList = contactList
 
 	contactList.StartConnections()
+	go contactList.expireQueuedMessages()
 	go me.publishService(me.privateKey)
 	return me, nil
 }
 
 func (me *Identity) loadIdentity() error {
-	config := me.core.Config.Read()
+	key, err := me.core.KeyStore.LoadKey()
+	if err != nil {
+		return err
+	}
 
-	if keyData := config.Secrets.GetServicePrivateKey(); keyData != nil {
-		var err error
-		me.privateKey, _, err = pkcs1.DecodePrivateKeyDER(keyData)
-		if err != nil {
-			return err
-		}
-		me.address, err = AddressFromKey(&me.privateKey.PublicKey)
+	if key != nil {
+		me.privateKey = key
+		me.address, err = AddressFromPrivateKey(me.privateKey)
 		if err != nil {
 			return err
 		}
@@ -72,7 +92,7 @@ func (me *Identity) loadIdentity() error {
 	return nil
 }
 
-func (me *Identity) setPrivateKey(key *rsa.PrivateKey) error {
+func (me *Identity) setPrivateKey(key crypto.PrivateKey) error {
 	me.mutex.Lock()
 	defer me.mutex.Unlock()
 
@@ -80,23 +100,22 @@ func (me *Identity) setPrivateKey(key *rsa.PrivateKey) error {
 		return errors.New("Cannot change private key on identity")
 	}
 
-	// Save key to config
-	keyData, err := pkcs1.EncodePrivateKeyDER(key)
-	if err != nil {
+	// Save key to the key store
+	if existing, err := me.core.KeyStore.LoadKey(); err != nil {
+		return err
+	} else if existing != nil {
+		// An identity was imported since loading; it's used after restarting
+		log.Printf("Not saving new identity, because an imported identity is in the key store")
+	} else if err := me.core.KeyStore.StoreKey(key); err != nil {
 		return err
 	}
-	config := me.core.Config.Lock()
-	if config.Secrets == nil {
-		config.Secrets = &ricochet.Secrets{}
-	}
-	config.Secrets.ServicePrivateKey = keyData
-	me.core.Config.Unlock()
 
 	// Update Identity
-	me.address, err = AddressFromKey(&key.PublicKey)
+	address, err := AddressFromPrivateKey(key)
 	if err != nil {
 		return err
 	}
+	me.address = address
 	me.privateKey = key
 
 	log.Printf("Created new identity %s", me.address)
@@ -104,34 +123,42 @@ func (me *Identity) setPrivateKey(key *rsa.PrivateKey) error {
 }
 
 // BUG(special): No error handling for failures under publishService
-func (me *Identity) publishService(key *rsa.PrivateKey) {
-	// This call will block until a control connection is available and the
-	// ADD_ONION command has returned. After creating the listener, it will
-	// be automatically re-published if the control connection is lost and
-	// later reconnected.
-	service, listener, err := me.core.Network.NewOnionListener(9878, key)
-	if err != nil {
-		log.Printf("Identity listener failed: %v", err)
-		// XXX handle
-		return
-	}
-
+func (me *Identity) publishService(key crypto.PrivateKey) {
 	if key == nil {
-		if service.PrivateKey == nil {
-			log.Printf("Setting private key failed: no key returned")
+		// New identities are v3 onion services. Tor doesn't return the keys
+		// it generates for them, so the key is generated here.
+		newKey, err := newOnionKey()
+		if err != nil {
+			log.Printf("Generating private key failed: %v", err)
 			// XXX handle
 			return
 		}
-
-		err := me.setPrivateKey(service.PrivateKey.(*rsa.PrivateKey))
-		if err != nil {
+		if err := me.setPrivateKey(newKey); err != nil {
 			log.Printf("Setting private key failed: %v", err)
 			// XXX handle
 			return
 		}
+		key = newKey
+	}
+
+	// This call will block until a control connection is available and the
+	// ADD_ONION command has returned. After creating the listener, it will
+	// be automatically re-published if the control connection is lost and
+	// later reconnected.
+	service, listener, err := me.core.Network.NewOnionListener(uint16(me.core.ServicePort), torOnionKey(key))
+	if err != nil {
+		log.Printf("Identity listener failed: %v", err)
+		// XXX handle
+		return
 	}
 
 	log.Printf("Identity service published, accepting connections")
+	me.mutex.Lock()
+	me.published = true
+	me.mutex.Unlock()
+	if me.core.SelfCheckInterval > 0 {
+		go me.checkServiceReachable(service)
+	}
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -161,25 +188,57 @@ func (me *Identity) handleInboundConnection(conn net.Conn) error {
 		}
 		return me.contactList.ContactByAddress(address), nil
 	}
-	lookupContactAuth := func(hostname string, publicKey rsa.PublicKey) (bool, bool) {
+	lookupContactAuth := func(hostname string, publicKey crypto.PublicKey) (bool, bool) {
+		if me.core.connectionMode() == ricochet.ConnectionMode_INVISIBLE {
+			// Changed to invisible while this connection was authenticating
+			return false, false
+		}
+		if address, err := AddressFromKey(publicKey); err != nil || address != "ricochet:"+hostname {
+			// The hostname is derived from the key during authentication, so
+			// this would be a bug; never trust a hostname the key doesn't match
+			log.Printf("Refusing inbound connection authenticated as %s with a key for %s", hostname, address)
+			return false, false
+		}
+		if selfHost, _ := PlainHostFromAddress(me.Address()); hostname == selfHost {
+			log.Printf("Refusing inbound connection authenticated as our own hostname")
+			return false, false
+		}
 		contact, err := contactByHostname(hostname)
 		if err != nil {
 			return false, false
 		}
+		if contact != nil && contact.IsBlocked() {
+			log.Printf("Refusing inbound connection from blocked contact %s", contact.Address())
+			return false, false
+		}
 		// allowed, known
 		return true, contact != nil
 	}
 
-	rc, err := protocol.NegotiateVersionInbound(conn)
+	if me.core.connectionMode() == ricochet.ConnectionMode_INVISIBLE {
+		me.core.Log.Debugf("Refusing inbound connection while invisible")
+		return nil
+	}
+
+	rc, err := protocol.NegotiateVersionInbound(newActivityConn(conn))
 	if err != nil {
 		log.Printf("Inbound connection failed: %v", err)
 		return err
 	}
 
-	authHandler := connection.HandleInboundConnection(rc)
-	err = authHandler.ProcessAuthAsServer(me.privateKey, lookupContactAuth)
+	err = authenticateInbound(rc, me.PrivateKey(), lookupContactAuth)
 	if err != nil {
 		log.Printf("Inbound connection auth failed: %v", err)
+		// The hostname is only known if the peer proved it
+		address, _ := AddressFromPlainHost(rc.RemoteHostname)
+		me.core.Audit.Record(ricochet.AuditEntry_AUTHENTICATION_FAILED, address, "inbound: "+err.Error())
+		if err == ricochetutils.ClientFailedToAuthenticateError {
+			// The protocol library returns a closed connection instead of the
+			// result if the connection closes before it has handled the
+			// result, and a client that can't tell a rejection from a lost
+			// connection retries without backing off
+			lingerUntilClosed(conn, rejectedConnectionLinger)
+		}
 		return err
 	}
 	contact, err := contactByHostname(rc.RemoteHostname)
@@ -203,6 +262,13 @@ func (me *Identity) handleInboundConnection(conn net.Conn) error {
 	return err
 }
 
+// lingerUntilClosed discards anything received on conn until the peer closes
+// it, or until timeout
+func lingerUntilClosed(conn net.Conn, timeout time.Duration) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	io.Copy(ioutil.Discard, conn)
+}
+
 func (me *Identity) Address() string {
 	return me.address
 }
@@ -211,6 +277,118 @@ func (me *Identity) ContactList() *ContactList {
 	return me.contactList
 }
 
-func (me *Identity) PrivateKey() rsa.PrivateKey {
-	return *me.privateKey
+// ContactRequestPolicy returns how new inbound contact requests are handled
+func (me *Identity) ContactRequestPolicy() ricochet.ContactRequestPolicy {
+	config := me.core.Config.Read()
+	if config.ContactRequestPolicy == nil {
+		return ricochet.ContactRequestPolicy{}
+	}
+	return *config.ContactRequestPolicy
+}
+
+// SetContactRequestPolicy changes and saves how new inbound contact requests
+// are handled. Pending requests aren't affected.
+func (me *Identity) SetContactRequestPolicy(policy ricochet.ContactRequestPolicy) error {
+	if _, ok := ricochet.ContactRequestPolicy_Mode_name[int32(policy.Mode)]; !ok {
+		return errors.New("Invalid contact request policy")
+	}
+	if policy.Token != "" && (policy.Mode != ricochet.ContactRequestPolicy_AUTO_ACCEPT || !IsMessageAcceptable(policy.Token)) {
+		return errors.New("Invalid contact request token")
+	}
+
+	config := me.core.Config.Lock()
+	config.ContactRequestPolicy = &policy
+	me.core.Config.Unlock()
+	log.Printf("Contact request policy changed to %s", policy.Mode)
+	return nil
+}
+
+// ConnectionMode returns which connections to and from contacts are allowed
+func (me *Identity) ConnectionMode() ricochet.ConnectionMode_Mode {
+	return me.core.connectionMode()
+}
+
+// SetConnectionMode changes and saves which connections to and from contacts
+// are allowed, and starts or stops connections to match
+func (me *Identity) SetConnectionMode(mode ricochet.ConnectionMode_Mode) error {
+	if _, ok := ricochet.ConnectionMode_Mode_name[int32(mode)]; !ok {
+		return errors.New("Invalid connection mode")
+	}
+
+	config := me.core.Config.Lock()
+	config.ConnectionMode = &ricochet.ConnectionMode{Mode: mode}
+	me.core.Config.Unlock()
+	log.Printf("Connection mode changed to %s", mode)
+
+	me.contactList.StartConnections()
+	if mode == ricochet.ConnectionMode_INVISIBLE {
+		// Inbound requests stay pending, but their requesters are disconnected
+		for _, request := range me.contactList.InboundRequests() {
+			request.CloseConnection()
+		}
+	}
+	return nil
+}
+
+// Presence returns our presence, which is sent to connected contacts
+func (me *Identity) Presence() ricochet.Presence {
+	config := me.core.Config.Read()
+	if config.Presence == nil {
+		return ricochet.Presence{}
+	}
+	return *config.Presence
+}
+
+// SetPresence changes and saves our presence, and sends it to all connected
+// contacts
+func (me *Identity) SetPresence(presence ricochet.Presence) error {
+	if _, ok := ricochet.Presence_Status_name[int32(presence.Status)]; !ok {
+		return errors.New("Invalid presence status")
+	}
+	if !IsStatusMessageAcceptable(presence.Message) {
+		return errors.New("Invalid status message")
+	}
+
+	config := me.core.Config.Lock()
+	config.Presence = &presence
+	me.core.Config.Unlock()
+	log.Printf("Presence changed to %s", presence.Status)
+
+	for _, contact := range me.contactList.Contacts() {
+		if err := contact.sendPresence(presence); err != nil {
+			me.core.Log.Warnf("Sending presence to contact %s failed: %v", contact.Address(), err)
+		}
+	}
+	return nil
+}
+
+// connectionMode is the same as Identity.ConnectionMode, for use by contacts
+// while the identity is being created
+func (core *Ricochet) connectionMode() ricochet.ConnectionMode_Mode {
+	return core.Config.Read().ConnectionMode.GetMode()
+}
+
+// Decide whether a new inbound request with message is accepted or rejected
+// by the contact request policy. If neither, it's pending for the user.
+func (me *Identity) checkContactRequestPolicy(message string) (accept bool, reject bool) {
+	policy := me.ContactRequestPolicy()
+	switch policy.Mode {
+	case ricochet.ContactRequestPolicy_REJECT_ALL:
+		return false, true
+	case ricochet.ContactRequestPolicy_AUTO_ACCEPT:
+		return policy.Token == "" || strings.Contains(message, policy.Token), false
+	}
+	return false, false
+}
+
+func (me *Identity) FileTransfers() *FileTransferList {
+	return me.transfers
+}
+
+// PrivateKey returns the key of the identity's onion service, which is an
+// *rsa.PrivateKey for a v2 service or an ed25519.PrivateKey for v3
+func (me *Identity) PrivateKey() crypto.PrivateKey {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.privateKey
 }
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"crypto"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"log"
+)
+
+// Exported identities contain the private key of the onion service, which is
+// all that's needed to impersonate the identity. The file is an IdentityExport
+// message encrypted by a utils.PassphraseCipher.
+const identityExportMagic = "RICOCHET-ID1"
+
+// Passphrases shorter than this are refused for export
+const MinIdentityPassphraseLength = 8
+
+// ExportIdentity returns the identity's address and private key, encrypted with
+// passphrase. The result must be handled with great care; anyone who can decrypt
+// it can take over the identity.
+func (me *Identity) ExportIdentity(passphrase string) ([]byte, error) {
+	if len(passphrase) < MinIdentityPassphraseLength {
+		return nil, errors.New("Passphrase is too short")
+	}
+
+	me.mutex.Lock()
+	key := me.privateKey
+	address := me.address
+	me.mutex.Unlock()
+	if key == nil {
+		return nil, errors.New("Identity has not been created yet")
+	}
+
+	return encryptIdentity(key, address, passphrase)
+}
+
+// encryptIdentity returns the key and its address in the format of
+// ExportIdentity, encrypted with passphrase
+func encryptIdentity(key crypto.PrivateKey, address, passphrase string) ([]byte, error) {
+	keyData, err := encodeOnionKey(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := proto.Marshal(&ricochet.IdentityExport{
+		Address:           address,
+		ServicePrivateKey: keyData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := utils.NewPassphraseCipher(identityExportMagic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return c.Seal(plaintext)
+}
+
+// DecryptIdentityExport decrypts an identity exported by ExportIdentity, and
+// checks that the private key belongs to the address it claims.
+func DecryptIdentityExport(data []byte, passphrase string) (crypto.PrivateKey, string, error) {
+	plaintext, _, err := utils.OpenWithPassphrase(data, identityExportMagic, passphrase)
+	switch err {
+	case nil:
+	case utils.NotPassphraseEncryptedError:
+		return nil, "", errors.New("Not an exported identity")
+	case utils.WrongPassphraseError:
+		return nil, "", errors.New("Wrong passphrase or damaged file")
+	default:
+		return nil, "", err
+	}
+
+	var export ricochet.IdentityExport
+	if err := proto.Unmarshal(plaintext, &export); err != nil {
+		return nil, "", err
+	}
+	key, err := decodeOnionKey(export.ServicePrivateKey)
+	if err != nil {
+		return nil, "", err
+	}
+	address, err := AddressFromPrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if address != export.Address {
+		return nil, "", errors.New("Private key does not match the exported address")
+	}
+	return key, address, nil
+}
+
+// ImportIdentity decrypts an exported identity and saves its private key in
+// store, replacing the current identity only if force is set. The identity is
+// used the next time the backend starts; a running Identity isn't changed.
+func ImportIdentity(store KeyStore, data []byte, passphrase string, force bool) (string, error) {
+	key, address, err := DecryptIdentityExport(data, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	if existing, err := store.LoadKey(); err != nil {
+		return "", err
+	} else if existing != nil && !force {
+		return "", errors.New("An identity already exists; it would be replaced by the import")
+	}
+	if err := store.StoreKey(key); err != nil {
+		return "", err
+	}
+
+	log.Printf("Imported identity %s", address)
+	return address, nil
+}
@@ -19,6 +19,8 @@ type InboundContactRequest struct {
 	// If non-nil, sent the new contact on accept or nil on reject.
 	// Used to signal back to active connections when request state changes.
 	contactResultChan chan *Contact
+	// Optional reason sent to the requester on reject
+	rejectReason string
 
 	// Called when the request state is changed
 	StatusChanged func(request *InboundContactRequest)
@@ -74,8 +76,8 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 		ResponseChan:        make(chan string),
 	}
 	// XXX should close conn if the channel goes away...
-	ach.RegisterChannelHandler("im.ricochet.contact.request", func() channels.Handler {
-		return &channels.ContactRequestChannel{Handler: req}
+	ach.RegisterChannelHandler(contactRequestChannelType, func() channels.Handler {
+		return &contactRequestChannel{handler: req}
 	})
 
 	processChan := make(chan error)
@@ -105,6 +107,15 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 		return <-processChan
 	}
 
+	if contactList.core.connectionMode() == ricochet.ConnectionMode_INVISIBLE {
+		// Ignored as if we weren't online. The connection is closed before the
+		// handler is released, so its response is never delivered.
+		log.Printf("Ignoring contact request from %s while invisible", address)
+		conn.Conn.Close()
+		req.ResponseChan <- "Error"
+		return <-processChan
+	}
+
 	// Function to respond to the request; changed after the initial response
 	respond := func(status string) { req.ResponseChan <- status }
 
@@ -125,10 +136,11 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 			break
 		case err := <-processChan:
 			request.clearContactResultChannel(contactChan)
-			for {
-				_, ok := <-contactChan
-				if !ok {
-					break
+			// The request may have been answered just as the connection was lost.
+			// An accepted contact connects to the requester on its own.
+			for c := range contactChan {
+				if c != nil {
+					log.Printf("Contact request from %s was accepted as its connection was lost; the contact will reconnect", address)
 				}
 			}
 			return err
@@ -137,11 +149,15 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 		// Change how future responses are sent
 		respond = func(status string) {
 			conn.Do(func() error {
-				channel := conn.Channel("im.ricochet.contact.request", channels.Inbound)
+				channel := conn.Channel(contactRequestChannelType, channels.Inbound)
 				if channel == nil {
 					return errors.New("no channel")
 				}
-				channel.Handler.(*channels.ContactRequestChannel).SendResponse(status)
+				handler := channel.Handler.(*contactRequestChannel)
+				if status == "Rejected" {
+					handler.Reason = request.RejectReason()
+				}
+				handler.sendResponse(status)
 				// Also close the channel; this was a final response
 				channel.CloseChannel()
 				return nil
@@ -151,10 +167,12 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 
 	// Have a response (either immediately or after pending)
 	if contact != nil {
-		// Accepted
+		// Accepted; the connection is already authenticated, so it's handed to
+		// the contact instead of making the requester connect again
 		respond("Accepted")
 		if err := conn.Break(); err != nil {
 			// Connection lost; but request was accepted anyway, so it'll get reconnected later
+			log.Printf("Connection for accepted contact request from %s was lost: %v", address, err)
 			return err
 		}
 		contact.AssignConnection(conn)
@@ -165,8 +183,14 @@ func HandleInboundRequestConnection(conn *connection.Connection, contactList *Co
 		if request != nil {
 			contactList.RemoveInboundContactRequest(request)
 		}
+		// An immediate response is sent by Process after the channel handler
+		// returns, so Process is stopped before the connection is closed
+		conn.Break()
 		conn.Conn.Close()
-		return <-processChan
+		if err := <-processChan; err != nil {
+			return err
+		}
+		return errors.New("contact request rejected")
 	}
 }
 
@@ -193,6 +217,10 @@ func CreateInboundContactRequest(core *Ricochet, address, nickname, message stri
 // getContactResultChannel returns a channel that will be sent a Contact if the request is
 // accepted, nil if the request is rejected, or closed if the channel is no longer used.
 // This is used to communciate with active connections for pending requests.
+//
+// The channel is buffered, so that a reply never waits for the connection. A reply sent
+// just as the connection is lost would otherwise deadlock with
+// clearContactResultChannel, which needs the mutex held while replying.
 func (cr *InboundContactRequest) getContactResultChannel() chan *Contact {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
@@ -200,16 +228,32 @@ func (cr *InboundContactRequest) getContactResultChannel() chan *Contact {
 	if cr.contactResultChan != nil {
 		close(cr.contactResultChan)
 	}
-	cr.contactResultChan = make(chan *Contact)
+	cr.contactResultChan = make(chan *Contact, 1)
+	cr.setConnected(true)
 	return cr.contactResultChan
 }
 
+// clearContactResultChannel is called when the connection waiting on c is lost
+// before a reply. The request stays pending, and can still be accepted.
 func (cr *InboundContactRequest) clearContactResultChannel(c chan *Contact) {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
 	if cr.contactResultChan == c {
 		close(cr.contactResultChan)
 		cr.contactResultChan = nil
+		cr.setConnected(false)
+	}
+}
+
+// Change whether the requester is connected, and signal the change to the
+// callback if the request is still pending. Assumes mutex.
+func (cr *InboundContactRequest) setConnected(connected bool) {
+	if cr.data.Connected == connected {
+		return
+	}
+	cr.data.Connected = connected
+	if !cr.data.Rejected && cr.StatusChanged != nil {
+		cr.StatusChanged(cr)
 	}
 }
 
@@ -219,6 +263,7 @@ func (cr *InboundContactRequest) CloseConnection() {
 	if cr.contactResultChan != nil {
 		close(cr.contactResultChan)
 		cr.contactResultChan = nil
+		cr.setConnected(false)
 	}
 }
 
@@ -265,6 +310,7 @@ func (cr *InboundContactRequest) Accept() (*Contact, error) {
 	}
 
 	log.Printf("Accepting contact request from %s", cr.data.Address)
+	cr.core.Audit.Record(ricochet.AuditEntry_REQUEST_ACCEPTED, cr.data.Address, "inbound")
 
 	data := &ricochet.Contact{
 		Address:     cr.data.Address,
@@ -307,15 +353,28 @@ func (cr *InboundContactRequest) acceptWithContact(contact *Contact) error {
 }
 
 func (cr *InboundContactRequest) Reject() {
+	cr.RejectWithReason("")
+}
+
+// RejectWithReason rejects the request, and sends reason to the requester if they
+// are still connected. The reason is optional, and must be acceptable according
+// to IsRejectReasonAcceptable.
+func (cr *InboundContactRequest) RejectWithReason(reason string) error {
+	if len(reason) > 0 && !IsRejectReasonAcceptable(reason) {
+		return errors.New("Invalid reason")
+	}
+
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
 
 	if cr.data.Rejected {
-		return
+		return nil
 	}
 
 	log.Printf("Rejecting contact request from %s", cr.data.Address)
+	cr.core.Audit.Record(ricochet.AuditEntry_REQUEST_REJECTED, cr.data.Address, "inbound")
 	cr.data.Rejected = true
+	cr.rejectReason = reason
 
 	// Signal to the active connection
 	if cr.contactResultChan != nil {
@@ -330,6 +389,14 @@ func (cr *InboundContactRequest) Reject() {
 	}
 
 	cr.core.Identity.ContactList().RemoveInboundContactRequest(cr)
+	return nil
+}
+
+// RejectReason returns the reason given when the request was rejected, if any
+func (cr *InboundContactRequest) RejectReason() string {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	return cr.rejectReason
 }
 
 func (cr *InboundContactRequest) Data() ricochet.ContactRequest {
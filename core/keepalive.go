@@ -0,0 +1,136 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultKeepaliveInterval is used when Ricochet.KeepaliveInterval is unset
+const DefaultKeepaliveInterval = 30 * time.Second
+
+// DefaultPresenceGrace is used when Ricochet.PresenceGrace is unset
+const DefaultPresenceGrace = 15 * time.Second
+
+// activityConn wraps a net.Conn to record when data was last received, which
+// is used to detect connections that have silently died.
+type activityConn struct {
+	net.Conn
+	lastRead int64 // UnixNano, accessed atomically
+}
+
+func newActivityConn(conn net.Conn) *activityConn {
+	return &activityConn{
+		Conn:     conn,
+		lastRead: time.Now().UnixNano(),
+	}
+}
+
+func (ac *activityConn) Read(b []byte) (int, error) {
+	n, err := ac.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&ac.lastRead, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// IdleTime returns the time since data was last received on this connection
+func (ac *activityConn) IdleTime() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&ac.lastRead)))
+}
+
+// Goroutine to send keepalive packets on an active contact connection and close it
+// if nothing is received from the peer for two keepalive intervals. Closing the
+// connection ends the Process routine, which lets the contact's connection loop
+// notice the loss and go offline. Returns when done is closed.
+//
+// Keepalives don't ask for a response, because the protocol library answers a
+// request with another request and two peers would echo them back and forth.
+// Liveness is judged from the peer's own traffic instead: ricochet-go peers
+// send their own keepalives, so a peer that is still there is never silent for
+// much longer than one interval. Tearing down a connection on a broken tor
+// circuit can take a long time, so the contact is shown offline as soon as it
+// has been silent for PresenceGrace beyond an interval, and online again when
+// anything is received before the connection is closed.
+func (c *Contact) keepaliveConnection(conn *connection.Connection, done <-chan struct{}) {
+	interval := c.core.KeepaliveInterval
+	ac, ok := conn.Conn.(*activityConn)
+	if !ok || interval <= 0 {
+		return
+	}
+	silenceLimit := interval + c.core.PresenceGrace
+
+	sendTicker := time.NewTicker(interval)
+	defer sendTicker.Stop()
+	checkTicker := time.NewTicker(presenceCheckInterval(c.core.PresenceGrace))
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-checkTicker.C:
+			c.setSilent(conn, ac.IdleTime() > silenceLimit)
+			continue
+		case <-sendTicker.C:
+		}
+
+		if idle := ac.IdleTime(); idle > 2*interval {
+			c.core.Log.Infof("Contact connection for %s has been idle for %v; closing", conn.RemoteHostname, idle)
+			conn.Conn.Close()
+			return
+		}
+
+		err := conn.Do(func() error {
+			raw := new(ricochetutils.MessageBuilder).KeepAlive(false)
+			return conn.SendRicochetPacket(conn.Conn, 0, raw)
+		})
+		if err != nil {
+			c.core.Log.Warnf("Sending keepalive to %s failed: %v", conn.RemoteHostname, err)
+		}
+	}
+}
+
+// presenceCheckInterval is how often to check whether the peer has gone silent,
+// which is often enough to notice it within about half of grace
+func presenceCheckInterval(grace time.Duration) time.Duration {
+	if check := grace / 2; check > time.Second {
+		return check
+	}
+	return time.Second
+}
+
+// setSilent shows the contact offline while nothing has been received on its
+// active connection conn for too long, and online again if traffic resumes
+// before the connection is closed
+func (c *Contact) setSilent(conn *connection.Connection, silent bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.connection != conn || c.silent == silent {
+		return
+	}
+
+	oldStatus := c.data.Status
+	if silent && oldStatus == ricochet.Contact_ONLINE {
+		c.core.Log.Infof("Contact %s has been silent too long; showing as offline", c.data.Address)
+		c.data.Status = ricochet.Contact_OFFLINE
+	} else if !silent && oldStatus == ricochet.Contact_OFFLINE {
+		c.core.Log.Infof("Contact %s is sending again", c.data.Address)
+		c.data.Status = ricochet.Contact_ONLINE
+	} else {
+		return
+	}
+	c.silent = silent
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+		Change: contactStatusChange(oldStatus, c.data.Status),
+	}
+	c.events.Publish(event)
+}
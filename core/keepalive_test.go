@@ -0,0 +1,197 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/connection"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A contact whose connection goes silent is shown offline once it has sent
+// nothing for PresenceGrace beyond the keepalive interval, before the
+// connection is torn down, and online again if it sends anything. Echoes of
+// keepalives don't count, because none are asked for.
+func TestSilentConnectionPresence(t *testing.T) {
+	const (
+		interval = 2 * time.Second
+		grace    = 500 * time.Millisecond
+	)
+	tests := []struct {
+		name string
+		// Whether the peer processes packets, whether it sends its own
+		// keepalives as ricochet-go does, and whether it speaks up after it's
+		// shown offline
+		library, keepalives bool
+		recovers            bool
+		// Status changes expected, in order
+		changes []ricochet.Contact_Status
+	}{
+		{"peer keepalives", true, true, false, nil},
+		{"library peer without keepalives", true, false, false, []ricochet.Contact_Status{ricochet.Contact_OFFLINE}},
+		{"silent", false, false, false, []ricochet.Contact_Status{ricochet.Contact_OFFLINE}},
+		{"recovers", false, false, true, []ricochet.Contact_Status{ricochet.Contact_OFFLINE, ricochet.Contact_ONLINE}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			core.KeepaliveInterval = interval
+			core.PresenceGrace = grace
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			events := core.Identity.ContactList().EventMonitor().Subscribe(20)
+			defer core.Identity.ContactList().EventMonitor().Unsubscribe(events)
+
+			local, remote, err := loopbackPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer local.Close()
+			defer remote.Close()
+			conn := connection.NewInboundConnection(newActivityConn(local))
+			go conn.Process(&testConnectionHandler{})
+			peer := connection.NewOutboundConnection(newActivityConn(remote), "aaaaaaaaaaaaaaaa")
+			done := make(chan struct{})
+			defer close(done)
+			if test.library {
+				go peer.Process(&testConnectionHandler{})
+			} else {
+				go io.Copy(ioutil.Discard, remote)
+			}
+
+			contact.mutex.Lock()
+			contact.connection = conn
+			contact.data.Status = ricochet.Contact_ONLINE
+			contact.mutex.Unlock()
+			started := time.Now()
+			go contact.keepaliveConnection(conn, done)
+			if test.keepalives {
+				peerContact := newTestContact(t, core, "ricochet:cccccccccccccccc")
+				go peerContact.keepaliveConnection(peer, done)
+			}
+
+			// The peer's keepalive is due within the interval, and it's shown
+			// silent after the grace, which is noticed at the next check
+			deadline := time.After(interval + grace + 2*presenceCheckInterval(grace))
+			var changes []ricochet.Contact_Status
+		wait:
+			for len(changes) < len(test.changes) || test.changes == nil {
+				select {
+				case v := <-events:
+					event := v.(ricochet.ContactEvent)
+					data := event.GetContact()
+					if event.Type != ricochet.ContactEvent_UPDATE || data == nil || data.Address != contact.Address() {
+						continue
+					}
+					changes = append(changes, data.Status)
+					if data.Status == ricochet.Contact_OFFLINE {
+						if elapsed := time.Since(started); elapsed < interval+grace {
+							t.Errorf("shown offline after %v, expected at least %v", elapsed, interval+grace)
+						}
+						if test.recovers {
+							// A packet from the peer shows it's there again
+							raw := new(ricochetutils.MessageBuilder).KeepAlive(false)
+							if err := peer.SendRicochetPacket(remote, 0, raw); err != nil {
+								t.Fatal(err)
+							}
+							deadline = time.After(2 * presenceCheckInterval(grace))
+						}
+					}
+				case <-deadline:
+					break wait
+				}
+			}
+
+			if len(changes) != len(test.changes) {
+				t.Fatalf("status changed to %v, expected %v", changes, test.changes)
+			}
+			for i := range changes {
+				if changes[i] != test.changes[i] {
+					t.Fatalf("status changed to %v, expected %v", changes, test.changes)
+				}
+			}
+			if contact.Connection() != conn {
+				t.Error("connection was replaced")
+			}
+		})
+	}
+}
+
+// countingConn counts the packets written to a connection, which the protocol
+// library writes with one call each
+type countingConn struct {
+	net.Conn
+	writes int64
+}
+
+func (cc *countingConn) Write(b []byte) (int, error) {
+	atomic.AddInt64(&cc.writes, 1)
+	return cc.Conn.Write(b)
+}
+
+// Keepalives are only sent once per interval by each side, and aren't echoed
+// back and forth by peers using the protocol library
+func TestKeepaliveTraffic(t *testing.T) {
+	const (
+		interval = 200 * time.Millisecond
+		period   = 2 * time.Second
+	)
+	tests := []struct {
+		name string
+		// Whether the peer sends its own keepalives, as ricochet-go does
+		peerKeepalive bool
+	}{
+		{"library peer", false},
+		{"ricochet-go peer", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			core.KeepaliveInterval = interval
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			peerContact := newTestContact(t, core, "ricochet:cccccccccccccccc")
+
+			local, remote, err := loopbackPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer local.Close()
+			defer remote.Close()
+			localCount := &countingConn{Conn: local}
+			remoteCount := &countingConn{Conn: remote}
+			conn := connection.NewInboundConnection(newActivityConn(localCount))
+			go conn.Process(&testConnectionHandler{})
+			peer := connection.NewOutboundConnection(newActivityConn(remoteCount), "aaaaaaaaaaaaaaaa")
+			go peer.Process(&testConnectionHandler{})
+
+			done := make(chan struct{})
+			go contact.keepaliveConnection(conn, done)
+			if test.peerKeepalive {
+				go peerContact.keepaliveConnection(peer, done)
+			}
+			time.Sleep(period)
+			close(done)
+
+			sent, received := atomic.LoadInt64(&localCount.writes), atomic.LoadInt64(&remoteCount.writes)
+			expected := int64(period / interval)
+			expectedReceived := int64(0)
+			if test.peerKeepalive {
+				expectedReceived = expected
+			}
+			// Allow for a tick gained at either end of the period
+			if sent > expected+1 || received > expectedReceived+1 {
+				t.Errorf("sent %d packets and received %d in %v, expected at most %d and %d", sent, received, period, expected+1, expectedReceived+1)
+			}
+			// A peer sending its own keepalives isn't idle, so the connection
+			// is kept
+			if test.peerKeepalive && (sent < expected-1 || received < expected-1) {
+				t.Errorf("sent %d packets and received %d in %v, expected about %d each", sent, received, period, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"crypto"
+	"errors"
+	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// KeyStore stores the private key of the identity's onion service, which is
+// all that's needed to impersonate the identity. The key is loaded through the
+// store when the identity is created, and saved through it when a new identity
+// is created or imported.
+//
+// By default, the key is kept in the config file. Other implementations can
+// keep it elsewhere, such as an OS keyring, and are set as Ricochet.KeyStore.
+//
+// Keys are an *rsa.PrivateKey for a v2 onion service, or an ed25519.PrivateKey
+// for a v3 onion service.
+type KeyStore interface {
+	// LoadKey returns the stored key, or nil if no key has been stored
+	LoadKey() (crypto.PrivateKey, error)
+	// StoreKey saves key, replacing any stored key
+	StoreKey(key crypto.PrivateKey) error
+}
+
+// ConfigKeyStore keeps the key unencrypted in the config, as
+// Secrets.ServicePrivateKey
+type ConfigKeyStore struct {
+	Config *config.ConfigFile
+}
+
+func (ks *ConfigKeyStore) LoadKey() (crypto.PrivateKey, error) {
+	keyData := ks.Config.Read().Secrets.GetServicePrivateKey()
+	if keyData == nil {
+		return nil, nil
+	}
+	return decodeOnionKey(keyData)
+}
+
+func (ks *ConfigKeyStore) StoreKey(key crypto.PrivateKey) error {
+	keyData, err := encodeOnionKey(key)
+	if err != nil {
+		return err
+	}
+	config := ks.Config.Lock()
+	if config.Secrets == nil {
+		config.Secrets = &ricochet.Secrets{}
+	}
+	config.Secrets.ServicePrivateKey = keyData
+	ks.Config.Unlock()
+	return nil
+}
+
+// EncryptedFileKeyStore keeps the key in a separate file, encrypted with a
+// passphrase in the same format as ExportIdentity, so the config file alone
+// doesn't reveal it. The passphrase is needed each time the backend starts.
+type EncryptedFileKeyStore struct {
+	Path       string
+	Passphrase string
+}
+
+func NewEncryptedFileKeyStore(path, passphrase string) (*EncryptedFileKeyStore, error) {
+	if len(passphrase) < MinIdentityPassphraseLength {
+		return nil, errors.New("Passphrase is too short")
+	}
+	return &EncryptedFileKeyStore{Path: path, Passphrase: passphrase}, nil
+}
+
+func (ks *EncryptedFileKeyStore) LoadKey() (crypto.PrivateKey, error) {
+	data, err := ioutil.ReadFile(ks.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	key, _, err := DecryptIdentityExport(data, ks.Passphrase)
+	return key, err
+}
+
+func (ks *EncryptedFileKeyStore) StoreKey(key crypto.PrivateKey) error {
+	address, err := AddressFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := encryptIdentity(key, address, ks.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	// Replace the file atomically, so a crash can't lose the key
+	tempPath := ks.Path + ".new"
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, ks.Path)
+}
+
+// keyStoreFromConfig returns the key store selected by the config's
+// KeyStorage, using passphrase for an encrypted file
+func keyStoreFromConfig(conf *config.ConfigFile, passphrase string) (KeyStore, error) {
+	storage := conf.Read().KeyStorage
+	switch storage.GetType() {
+	case ricochet.KeyStorage_CONFIG:
+		return &ConfigKeyStore{Config: conf}, nil
+	case ricochet.KeyStorage_ENCRYPTED_FILE:
+		if passphrase == "" {
+			return nil, errors.New("The identity's key is in an encrypted file, and needs a passphrase")
+		}
+		return NewEncryptedFileKeyStore(storage.Path, passphrase)
+	default:
+		return nil, errors.New("Unknown key storage in config")
+	}
+}
+
+// UseEncryptedKeyFile changes the config to keep the identity's key in the
+// encrypted file at path. A key in the config is moved to the file, and removed
+// from the config once the file is written. This must be done before the
+// config is used by Ricochet.Init.
+func UseEncryptedKeyFile(conf *config.ConfigFile, path, passphrase string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	store, err := NewEncryptedFileKeyStore(path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	storage := conf.Read().KeyStorage
+	if storage.GetType() == ricochet.KeyStorage_ENCRYPTED_FILE {
+		if storage.Path != path {
+			return errors.New("The identity's key is already in a different encrypted file")
+		}
+		return nil
+	}
+
+	key, err := (&ConfigKeyStore{Config: conf}).LoadKey()
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		if existing, err := store.LoadKey(); err != nil {
+			return err
+		} else if existing != nil {
+			return errors.New("Encrypted key file already exists")
+		}
+		if err := store.StoreKey(key); err != nil {
+			return err
+		}
+	}
+
+	config := conf.Lock()
+	config.KeyStorage = &ricochet.KeyStorage{
+		Type: ricochet.KeyStorage_ENCRYPTED_FILE,
+		Path: path,
+	}
+	if config.Secrets != nil {
+		config.Secrets.ServicePrivateKey = nil
+	}
+	conf.Unlock()
+	if key != nil {
+		log.Printf("Moved identity key to encrypted file %s", path)
+	}
+	return nil
+}
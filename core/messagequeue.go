@@ -0,0 +1,198 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultMaxQueuedMessageAge is used when Ricochet.MaxQueuedMessageAge is unset
+	DefaultMaxQueuedMessageAge = 7 * 24 * time.Hour
+	// DefaultMaxQueuedMessages is used when Ricochet.MaxQueuedMessages is unset
+	DefaultMaxQueuedMessages = 100
+	// DefaultAckTimeout is used when Ricochet.AckTimeout is unset
+	DefaultAckTimeout = 2 * time.Minute
+)
+
+// How often queued messages are checked for expiry
+const queueExpiryInterval = time.Minute
+
+// QueueDepth returns the number of outbound messages waiting to be sent to the
+// contact, and the timestamp of the oldest of them.
+func (c *Conversation) QueueDepth() (int, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	count := 0
+	var oldest int64
+	for _, message := range c.messages {
+		if message.Status == ricochet.Message_QUEUED {
+			if count == 0 {
+				oldest = message.Timestamp
+			}
+			count++
+		}
+	}
+	return count, oldest
+}
+
+// Returns true if another message can't be queued for the contact.
+// Assumes c.mutex is held.
+func (c *Conversation) isQueueFull() bool {
+	max := c.Contact.core.MaxQueuedMessages
+	if max < 0 {
+		return false
+	}
+	count := 0
+	for _, message := range c.messages {
+		if message.Status == ricochet.Message_QUEUED {
+			count++
+		}
+	}
+	return count >= max
+}
+
+// expireQueuedMessages marks queued messages older than the maximum age as
+// failed, so they aren't sent long after they were written. Assumes c.mutex
+// is held.
+func (c *Conversation) expireQueuedMessages() {
+	maxAge := c.Contact.core.MaxQueuedMessageAge
+	if maxAge < 0 {
+		return
+	}
+
+	expired := 0
+	for _, message := range c.messages {
+		if message.Status != ricochet.Message_QUEUED ||
+			time.Since(time.Unix(message.Timestamp, 0)) < maxAge {
+			continue
+		}
+		message.Status = ricochet.Message_ERROR
+		expired++
+
+		event := ricochet.ConversationEvent{
+			Type: ricochet.ConversationEvent_UPDATE,
+			Msg:  message,
+		}
+		c.events.Publish(event)
+	}
+
+	if expired > 0 {
+		log.Printf("Expired %d queued messages to %s", expired, c.remoteEntity.Address)
+		c.saveHistory()
+	}
+}
+
+// Goroutine to periodically expire queued messages to offline contacts
+func (cl *ContactList) expireQueuedMessages() {
+	ticker := time.NewTicker(queueExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-cl.stop:
+			return
+		}
+		cl.expireLoadedQueues()
+	}
+}
+
+// expireLoadedQueues expires queued messages in each loaded conversation.
+// Conversations that haven't been loaded are skipped, rather than reading
+// every contact's history; their messages expire when it is loaded.
+func (cl *ContactList) expireLoadedQueues() {
+	for _, contact := range cl.Contacts() {
+		contact.mutex.Lock()
+		conversation := contact.conversation
+		contact.mutex.Unlock()
+		if conversation == nil {
+			continue
+		}
+		conversation.mutex.Lock()
+		conversation.expireQueuedMessages()
+		conversation.mutex.Unlock()
+	}
+}
+
+// trackSent starts the ack timeout for a message that was just sent with its
+// current Identifier. Assumes c.mutex is held.
+func (c *Conversation) trackSent(message *ricochet.Message) {
+	timeout := c.Contact.core.AckTimeout
+	if timeout < 0 {
+		return
+	}
+	if c.sentAt == nil {
+		c.sentAt = make(map[*ricochet.Message]time.Time)
+	}
+	sentAt := time.Now()
+	c.sentAt[message] = sentAt
+	time.AfterFunc(timeout, func() {
+		c.ackTimedOut(message, sentAt)
+	})
+}
+
+// ackTimedOut fails a message that hasn't been acked since it was sent at
+// sentAt, unless it has been acked or sent again since.
+func (c *Conversation) ackTimedOut(message *ricochet.Message, sentAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if message.Status != ricochet.Message_SENDING || c.sentAt[message] != sentAt {
+		return
+	}
+	delete(c.sentAt, message)
+
+	log.Printf("Message %d to %s was not acked after %v", message.Identifier, c.remoteEntity.Address, time.Since(sentAt))
+	message.Status = ricochet.Message_ERROR
+	c.timedOut = append(c.timedOut, message)
+	c.saveHistory()
+
+	event := ricochet.ConversationEvent{
+		Type: ricochet.ConversationEvent_UPDATE,
+		Msg:  message,
+	}
+	c.events.Publish(event)
+}
+
+// requeueTimedOutMessages queues messages that failed for lack of an ack to
+// be sent again, if Ricochet.ResendUnackedMessages is set. Acks on a new
+// connection can't refer to them or to messages still waiting for one, so
+// their identifiers are forgotten either way. Returns true if any message was
+// queued. Assumes c.mutex is held.
+func (c *Conversation) requeueTimedOutMessages() bool {
+	requeued := false
+	if c.Contact.core.ResendUnackedMessages {
+		for _, message := range c.timedOut {
+			if message.Status == ricochet.Message_ERROR {
+				message.Status = ricochet.Message_QUEUED
+				requeued = true
+			}
+		}
+	}
+	c.timedOut = nil
+	c.sentIds = nil
+	return requeued
+}
+
+// isTimedOut returns true if the message failed because no ack arrived on the
+// current connection, in which case a late ack still applies to it. Assumes
+// c.mutex is held.
+func (c *Conversation) isTimedOut(message *ricochet.Message) bool {
+	for _, m := range c.timedOut {
+		if m == message {
+			return true
+		}
+	}
+	return false
+}
+
+// Assumes c.mutex is held
+func (c *Conversation) forgetTimedOut(message *ricochet.Message) {
+	for i, m := range c.timedOut {
+		if m == message {
+			c.timedOut = append(c.timedOut[:i], c.timedOut[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Periodic expiry only checks loaded conversations; the others expire their
+// queued messages when they're loaded.
+func TestExpireLoadedQueues(t *testing.T) {
+	tests := []struct {
+		name   string
+		loaded bool
+	}{
+		{"loaded", true},
+		{"not loaded", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			history, err := config.LoadHistoryFile(filepath.Join(t.TempDir(), "history.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			core.History = history
+			core.MaxQueuedMessageAge = time.Hour
+
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			history.Store(contact.Address(), []*ricochet.Message{{
+				Sender:    &ricochet.Entity{IsSelf: true},
+				Timestamp: time.Now().Add(-2 * time.Hour).Unix(),
+				Status:    ricochet.Message_QUEUED,
+				Text:      "old",
+			}})
+			if test.loaded {
+				contact.Conversation()
+			}
+
+			core.Identity.contactList.expireLoadedQueues()
+
+			contact.mutex.Lock()
+			loaded := contact.conversation != nil
+			contact.mutex.Unlock()
+			if loaded != test.loaded {
+				t.Fatalf("conversation loaded is %v, expected %v", loaded, test.loaded)
+			}
+			if status := history.Messages(contact.Address())[0].Status; !test.loaded && status != ricochet.Message_QUEUED {
+				t.Errorf("unloaded message is %v, expected it to stay queued", status)
+			}
+
+			messages := contact.Conversation().Messages()
+			if len(messages) != 1 || messages[0].Status != ricochet.Message_ERROR {
+				t.Errorf("expected the queued message to expire, got %v", messages)
+			}
+		})
+	}
+}
+
+// A sent message that isn't acked within AckTimeout fails with an update
+// event, on schedule; an ack that arrives on the same connection still
+// applies, and a failed message is queued again on the next connection if
+// ResendUnackedMessages is set
+func TestAckTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		resend  bool
+		// Delay before the ack, or no ack if negative, and whether it's accepted
+		ackDelay time.Duration
+		accepted bool
+		// Status of the message in each update event, and on the next connection
+		updates []ricochet.Message_Status
+		resent  ricochet.Message_Status
+	}{
+		{"never acked", timeout, false, -1, false,
+			[]ricochet.Message_Status{ricochet.Message_ERROR}, ricochet.Message_ERROR},
+		{"never acked, resent", timeout, true, -1, false,
+			[]ricochet.Message_Status{ricochet.Message_ERROR}, ricochet.Message_QUEUED},
+		{"acked", timeout, true, timeout / 2, true,
+			[]ricochet.Message_Status{ricochet.Message_DELIVERED}, ricochet.Message_DELIVERED},
+		{"refused", timeout, true, timeout / 2, false,
+			[]ricochet.Message_Status{ricochet.Message_ERROR}, ricochet.Message_ERROR},
+		{"acked late", timeout, true, 2 * timeout, true,
+			[]ricochet.Message_Status{ricochet.Message_ERROR, ricochet.Message_DELIVERED}, ricochet.Message_DELIVERED},
+		{"no timeout", -1, true, -1, false, nil, ricochet.Message_SENDING},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			core.AckTimeout = test.timeout
+			core.ResendUnackedMessages = test.resend
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			events := conversation.EventMonitor().Subscribe(10)
+			defer conversation.EventMonitor().Unsubscribe(events)
+			message := addSentMessage(conversation, 1)
+			sentAt := time.Now()
+			if test.ackDelay >= 0 {
+				time.AfterFunc(test.ackDelay, func() { conversation.ChatMessageAck(1, test.accepted) })
+			}
+
+			var updates []ricochet.Message_Status
+			var failedAfter time.Duration
+			deadline := time.After(3 * timeout)
+		wait:
+			for {
+				select {
+				case v := <-events:
+					event := v.(ricochet.ConversationEvent)
+					if event.Type != ricochet.ConversationEvent_UPDATE || event.Msg != message {
+						t.Errorf("unexpected %v event", event.Type)
+						continue
+					}
+					status := messageStatus(conversation, message)
+					if status == ricochet.Message_ERROR && failedAfter == 0 {
+						failedAfter = time.Since(sentAt)
+					}
+					updates = append(updates, status)
+				case <-deadline:
+					break wait
+				}
+			}
+			if !reflect.DeepEqual(updates, test.updates) {
+				t.Errorf("message was updated to %v, expected %v", updates, test.updates)
+			}
+			if test.ackDelay < 0 || test.ackDelay > timeout {
+				if failedAfter != 0 && (failedAfter < timeout || failedAfter > 2*timeout) {
+					t.Errorf("message failed after %v, expected %v", failedAfter, timeout)
+				}
+			}
+
+			conversation.mutex.Lock()
+			conversation.requeueTimedOutMessages()
+			conversation.mutex.Unlock()
+			if status := messageStatus(conversation, message); status != test.resent {
+				t.Errorf("message is %v on the next connection, expected %v", status, test.resent)
+			}
+		})
+	}
+}
@@ -2,13 +2,19 @@ package core
 
 import (
 	"crypto"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"github.com/yawning/bulb"
 	"golang.org/x/net/context"
 	"golang.org/x/net/proxy"
+	"io"
 	"log"
 	"net"
 	"strings"
@@ -18,10 +24,29 @@ import (
 
 // XXX Network disconnect should kill open connections ... somehow
 
+// DefaultSocksAddress is used for connections if no SOCKS address is configured
+// and tor doesn't report any SOCKS ports.
+const DefaultSocksAddress = "127.0.0.1:9050"
+
+// Resolver makes outbound connections to contacts in place of tor, e.g. for
+// tests using in-memory connections or for direct connections on a trusted
+// network. Resolve is given the onion hostname and port to connect to, and
+// returns the connection and the hostname it reached.
+//
+// DirectResolver and the Resolve method of utils.NetworkResolver from go-ricochet
+// satisfy this.
+type Resolver interface {
+	Resolve(hostname string) (net.Conn, string, error)
+}
+
 type Network struct {
 	// Connection settings; can only change while stopped
 	controlAddress  string
 	controlPassword string
+	// Random key for deriving SOCKS credentials that isolate circuits
+	isolationSecret []byte
+	// If set, used for outbound connections instead of tor
+	resolver Resolver
 
 	// Events
 	events *utils.Publisher
@@ -43,8 +68,21 @@ type Network struct {
 	// et al for each change.
 	status ricochet.NetworkStatus
 
+	// SOCKS settings; can change at any time with SetSocksAddress and
+	// SetSocksAuth. If set, configuredSocks is used instead of the SOCKS
+	// ports reported by tor, and socksAuth is used to authenticate.
+	configuredSocks socksAddress
+	socksAuth       *proxy.Auth
+	// Best SOCKS port reported by tor, or the default
+	discoveredSocks socksAddress
+	// Called after the SOCKS settings change while connected
+	proxyChanged func()
+
 	socksAddress socksAddress
 	onions       []*OnionService
+
+	// Consecutive connections that failed to reach the SOCKS port
+	socksFailures int
 }
 
 type OnionService struct {
@@ -60,8 +98,13 @@ type OnionServiceListener struct {
 }
 
 func CreateNetwork() *Network {
+	secret := make([]byte, 32)
+	if _, err := cryptorand.Read(secret); err != nil {
+		log.Panicf("rng failed: %v", err)
+	}
 	return &Network{
-		events: utils.CreatePublisher(),
+		events:          utils.CreatePublisher(),
+		isolationSecret: secret,
 	}
 }
 
@@ -87,6 +130,118 @@ func (n *Network) SetControlPassword(password string) error {
 	return nil
 }
 
+// SetSocksAddress sets the tor SOCKS port used for outbound connections, which
+// may be 'host:port' or 'unix:/path'. If unset or empty, the SOCKS ports are
+// discovered from tor using the control connection. See changeSocks for
+// changes while the network is started.
+func (n *Network) SetSocksAddress(address string) error {
+	var socks socksAddress
+	if address != "" {
+		var err error
+		if socks, err = parseSocksAddress(address); err != nil {
+			return err
+		}
+	}
+
+	n.controlMutex.Lock()
+	auth := n.socksAuth
+	n.controlMutex.Unlock()
+	return n.changeSocks(socks, auth)
+}
+
+// SetSocksAuth sets a username and password for the SOCKS port, for proxies
+// that require authentication. If the username is empty, which is the default,
+// no authentication is used. The credentials are checked when connecting to
+// the network. See changeSocks for changes while the network is started.
+func (n *Network) SetSocksAuth(username, password string) error {
+	var auth *proxy.Auth
+	if username != "" {
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("SOCKS username and password must be at most 255 bytes")
+		}
+		auth = &proxy.Auth{User: username, Password: password}
+	}
+
+	n.controlMutex.Lock()
+	configured := n.configuredSocks
+	n.controlMutex.Unlock()
+	return n.changeSocks(configured, auth)
+}
+
+// SetProxyChangedCallback sets a function that is called after the SOCKS
+// settings are changed while connected to tor, so that outbound connection
+// attempts can be restarted with the new settings.
+func (n *Network) SetProxyChangedCallback(callback func()) {
+	n.controlMutex.Lock()
+	defer n.controlMutex.Unlock()
+	n.proxyChanged = callback
+}
+
+// changeSocks sets the configured SOCKS address, which may be unset to use the
+// one discovered from tor, and credentials. If the network is connected to tor,
+// the new settings are checked the same way as when connecting, and an error
+// is returned without changing anything if they don't work. Otherwise, they
+// are used for connections from now on, and the proxy changed callback is
+// called. Established connections are not affected.
+func (n *Network) changeSocks(configured socksAddress, auth *proxy.Auth) error {
+	n.controlMutex.Lock()
+	if n.conn == nil {
+		// Used the next time the control connection is made
+		n.configuredSocks = configured
+		n.socksAuth = auth
+		n.controlMutex.Unlock()
+		return nil
+	}
+	socks := configured
+	if !socks.IsValid() {
+		socks = n.discoveredSocks
+	}
+	n.controlMutex.Unlock()
+
+	if err := socks.checkReachable(auth); err != nil {
+		return err
+	}
+
+	n.controlMutex.Lock()
+	n.configuredSocks = configured
+	n.socksAuth = auth
+	if n.conn == nil {
+		// Disconnected while checking; the settings are checked again when
+		// reconnecting
+		n.controlMutex.Unlock()
+		return nil
+	}
+	n.socksAddress = socks
+	n.socksFailures = 0
+	n.status.Socks = nil
+	status := n.status
+	callback := n.proxyChanged
+	n.controlMutex.Unlock()
+	n.events.Publish(status)
+
+	log.Printf("SOCKS settings changed, using %s %s", socks.Network, socks.Address)
+	if callback != nil {
+		callback()
+	}
+	return nil
+}
+
+// SetResolver replaces tor with resolver for outbound connections to contacts.
+// If nil, which is the default, connections are made through tor.
+func (n *Network) SetResolver(resolver Resolver) {
+	n.controlMutex.Lock()
+	defer n.controlMutex.Unlock()
+	n.resolver = resolver
+}
+
+// Resolver returns the resolver set by SetResolver, or nil if connections are
+// made through tor.
+func (n *Network) Resolver() Resolver {
+	n.controlMutex.Lock()
+	defer n.controlMutex.Unlock()
+	return n.resolver
+}
+
 // Start connection to the tor control port. This function blocks until the first
 // connection attempt is finished. The first return value says whether the
 // connection has been started; if true, the connection is up even if the first
@@ -159,6 +314,86 @@ func (sa socksAddress) IsValid() bool {
 	return sa.Network != "" && sa.Address != ""
 }
 
+// Parse a SOCKS address in the form "127.0.0.1:9050", "unix:...", or "[::1]:9050"
+func parseSocksAddress(addr string) (socksAddress, error) {
+	if strings.HasPrefix(addr, "unix:") {
+		return socksAddress{
+			Network: "unix",
+			Address: addr[5:],
+		}, nil
+	}
+
+	ipStr, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return socksAddress{}, err
+	}
+	return socksAddress{
+		Network: "tcp",
+		Address: addr,
+		IP:      net.ParseIP(ipStr),
+	}, nil
+}
+
+// Check that something is listening on the SOCKS address, and that it accepts
+// auth if set, so a misconfigured address or credentials are reported clearly
+// instead of as failed contact connections.
+func (sa socksAddress) checkReachable(auth *proxy.Auth) error {
+	conn, err := net.DialTimeout(sa.Network, sa.Address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("Tor SOCKS port is not reachable at %s: %v", sa.Address, err)
+	}
+	defer conn.Close()
+
+	if auth != nil {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := checkSocksAuth(conn, auth); err != nil {
+			return fmt.Errorf("Tor SOCKS port at %s %v", sa.Address, err)
+		}
+	}
+	return nil
+}
+
+// socksIsolationAuth returns SOCKS credentials for isolating the circuits used
+// for key. The password is a keyed hash, so the key can't be recovered from it
+// by anyone who sees the credentials, such as the SOCKS proxy's logs.
+func socksIsolationAuth(secret []byte, key string) *proxy.Auth {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key))
+	return &proxy.Auth{
+		User:     "ricochet-isolation",
+		Password: hex.EncodeToString(mac.Sum(nil)),
+	}
+}
+
+// Authenticate to a SOCKS5 server with username and password (RFC 1929),
+// without requesting a connection
+func checkSocksAuth(conn net.Conn, auth *proxy.Auth) error {
+	// Version 5, offering only the username/password method
+	if _, err := conn.Write([]byte{5, 1, 2}); err != nil {
+		return fmt.Errorf("failed during handshake: %v", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed during handshake: %v", err)
+	} else if reply[0] != 5 || reply[1] != 2 {
+		return errors.New("does not support username and password authentication")
+	}
+
+	request := []byte{1, byte(len(auth.User))}
+	request = append(request, auth.User...)
+	request = append(request, byte(len(auth.Password)))
+	request = append(request, auth.Password...)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("failed during authentication: %v", err)
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed during authentication: %v", err)
+	} else if reply[1] != 0 {
+		return errors.New("rejected the username and password")
+	}
+	return nil
+}
+
 func (sa socksAddress) PreferredTo(other socksAddress, preferredIP net.IP) bool {
 	// Prefer, in order:
 	//   - any over null
@@ -207,34 +442,16 @@ func chooseSocksAddress(addresses []string, controlAddress string) (socksAddress
 	// List of SOCKS ports, relative to the tor daemon
 	// Can be in the form "127.0.0.1:9050", "unix:...", or "[::1]:9050"
 	for _, addr := range addresses {
-		var socks socksAddress
-
 		// Parse into 'socks' and filter out localhost if necessary
-		if strings.HasPrefix(addr, "unix:") {
-			// Ignore unix ports for remote tor
-			if !torOnLocalhost {
-				log.Printf("Ignoring loopback SOCKS port %s", addr)
-				continue
-			}
-			socks = socksAddress{
-				Network: "unix",
-				Address: addr[5:],
-			}
-		} else {
-			ipStr, _, err := net.SplitHostPort(addr)
-			if err != nil {
-				log.Printf("Ignoring malformed SOCKS address '%s': %s", addr, err)
-				continue
-			}
-			socks = socksAddress{
-				Network: "tcp",
-				Address: addr,
-				IP:      net.ParseIP(ipStr),
-			}
-			if !torOnLocalhost && socks.IP.IsLoopback() {
-				log.Printf("Ignoring loopback SOCKS port %s", addr)
-				continue
-			}
+		socks, err := parseSocksAddress(addr)
+		if err != nil {
+			log.Printf("Ignoring malformed SOCKS address '%s': %s", addr, err)
+			continue
+		}
+		if !torOnLocalhost && (socks.Network == "unix" || socks.IP.IsLoopback()) {
+			// Ignore loopback ports for remote tor
+			log.Printf("Ignoring loopback SOCKS port %s", addr)
+			continue
 		}
 
 		// Compare to current selection
@@ -253,21 +470,35 @@ func chooseSocksAddress(addresses []string, controlAddress string) (socksAddress
 func (n *Network) GetProxyDialer(forward proxy.Dialer) (proxy.Dialer, error) {
 	n.controlMutex.Lock()
 	socks := n.socksAddress
+	auth := n.socksAuth
 	n.controlMutex.Unlock()
 
 	if !socks.IsValid() {
 		return nil, errors.New("No valid SOCKS configuration")
 	}
 
-	return proxy.SOCKS5(socks.Network, socks.Address, nil, forward)
+	return proxy.SOCKS5(socks.Network, socks.Address, auth, forward)
 }
 
-func (n *Network) WaitForProxyDialer(forward proxy.Dialer, c context.Context) (proxy.Dialer, error) {
+// WaitForProxyDialer returns a dialer for the SOCKS port once the network is
+// ready, or an error if c is cancelled first.
+//
+// If isolationKey isn't empty and no SOCKS credentials are configured, the
+// dialer authenticates with credentials derived from the key. Tor puts streams
+// with different credentials on different circuits (IsolateSOCKSAuth, which is
+// on by default), so connections with different keys never share a circuit.
+// Configured credentials are always used as they are, because a proxy that
+// requires them would refuse any others.
+func (n *Network) WaitForProxyDialer(forward proxy.Dialer, isolationKey string, c context.Context) (proxy.Dialer, error) {
 	var monitor <-chan interface{}
 	for {
 		// Check if there's a proxy address available and connection status is Ready
 		n.controlMutex.Lock()
 		socks := n.socksAddress
+		auth := n.socksAuth
+		if auth == nil && isolationKey != "" {
+			auth = socksIsolationAuth(n.isolationSecret, isolationKey)
+		}
 		var connectionStatus ricochet.TorConnectionStatus
 		if n.status.Connection != nil {
 			connectionStatus = *n.status.Connection
@@ -275,7 +506,7 @@ func (n *Network) WaitForProxyDialer(forward proxy.Dialer, c context.Context) (p
 		n.controlMutex.Unlock()
 
 		if connectionStatus.Status == ricochet.TorConnectionStatus_READY && socks.IsValid() {
-			return proxy.SOCKS5(socks.Network, socks.Address, nil, forward)
+			return proxy.SOCKS5(socks.Network, socks.Address, auth, forward)
 		}
 
 		if monitor == nil {
@@ -301,6 +532,70 @@ func (n *Network) WaitForProxyDialer(forward proxy.Dialer, c context.Context) (p
 	return nil, errors.New("No valid SOCKS configuration")
 }
 
+// Number of consecutive connections that must fail to reach the SOCKS port
+// before tor is reported as unavailable
+const socksFailureThreshold = 3
+
+// reportSocksResult records the result of a connection through the SOCKS port,
+// and changes the SOCKS status if tor has become unavailable or available again.
+// Errors from tor, such as the onion service being unreachable, show that tor is
+// available.
+func (n *Network) reportSocksResult(err error) {
+	n.controlMutex.Lock()
+	var newStatus ricochet.TorSocksStatus_Status
+	if isSocksUnreachable(err) {
+		n.socksFailures++
+		if n.socksFailures < socksFailureThreshold {
+			n.controlMutex.Unlock()
+			return
+		}
+		newStatus = ricochet.TorSocksStatus_UNAVAILABLE
+	} else {
+		n.socksFailures = 0
+		newStatus = ricochet.TorSocksStatus_AVAILABLE
+	}
+
+	if n.status.Socks != nil && n.status.Socks.Status == newStatus {
+		n.controlMutex.Unlock()
+		return
+	}
+	n.status.Socks = &ricochet.TorSocksStatus{Status: newStatus}
+	if newStatus == ricochet.TorSocksStatus_UNAVAILABLE {
+		n.status.Socks.ErrorMessage = err.Error()
+		log.Printf("Tor SOCKS port is unavailable: %v", err)
+	}
+	status := n.status
+	n.controlMutex.Unlock()
+	n.events.Publish(status)
+}
+
+// reportServiceResult records the result of a self-check of our onion service
+func (n *Network) reportServiceResult(err error) {
+	n.controlMutex.Lock()
+	if n.stoppedSignal == nil {
+		// Stopped while checking
+		n.controlMutex.Unlock()
+		return
+	}
+	n.status.Service = &ricochet.OnionServiceStatus{
+		Status:      ricochet.OnionServiceStatus_REACHABLE,
+		LastChecked: time.Now().Unix(),
+	}
+	if err != nil {
+		n.status.Service.Status = ricochet.OnionServiceStatus_UNREACHABLE
+		n.status.Service.ErrorMessage = err.Error()
+	}
+	status := n.status
+	n.controlMutex.Unlock()
+	n.events.Publish(status)
+}
+
+// Returns true if err is from failing to connect to the SOCKS port itself
+func isSocksUnreachable(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
 // Return the control connection, blocking until connected if necessary
 // May return nil on failure, and the returned connection can be closed
 // or otherwise fail at any time.
@@ -423,6 +718,26 @@ func (n *Network) DeleteOnionService(onionID string) error {
 	return nil
 }
 
+// RepublishOnionService removes the service from tor and adds it again with the
+// same key, for when its registration has become stale. It returns an error if
+// there's no control connection or the service can't be added.
+func (n *Network) RepublishOnionService(service *OnionService) error {
+	n.controlMutex.Lock()
+	conn := n.conn
+	n.controlMutex.Unlock()
+	if conn == nil {
+		return errors.New("Not connected to tor")
+	}
+
+	// The service may already be gone, in which case this fails harmlessly
+	conn.DeleteOnion(service.OnionID)
+	if _, err := conn.AddOnion(service.Ports, service.PrivateKey, false); err != nil {
+		return err
+	}
+	log.Printf("Re-published onion service %s", service.OnionID)
+	return nil
+}
+
 func (s *OnionServiceListener) Accept() (net.Conn, error) {
 	return s.InternalListener.Accept()
 }
@@ -461,6 +776,7 @@ func (n *Network) run(connectChannel chan<- error) {
 			Status: ricochet.TorControlStatus_CONNECTING,
 		}
 		n.status.Connection = &ricochet.TorConnectionStatus{}
+		n.status.Service = nil
 		status := n.status
 		n.controlMutex.Unlock()
 		n.events.Publish(status)
@@ -494,6 +810,7 @@ func (n *Network) run(connectChannel chan<- error) {
 			}
 			n.stoppedSignal = nil
 			n.status = ricochet.NetworkStatus{}
+			n.socksFailures = 0
 			n.controlMutex.Unlock()
 			n.events.Publish(ricochet.NetworkStatus{})
 
@@ -517,6 +834,7 @@ func (n *Network) run(connectChannel chan<- error) {
 				ErrorMessage: err.Error(),
 			}
 			n.status.Connection = &ricochet.TorConnectionStatus{}
+			n.status.Service = nil
 			status := n.status
 			n.controlMutex.Unlock()
 			n.events.Publish(status)
@@ -556,12 +874,27 @@ func (n *Network) connectControl() error {
 		return err
 	}
 
-	// Choose default SOCKS port
-	socks, err := chooseSocksAddress(connStatus.SocksAddress, n.controlAddress)
-	if socks.IsValid() {
-		log.Printf("Discovered SOCKS port %s %s", socks.Network, socks.Address)
-	} else {
-		log.Printf("No SOCKS port: %v", err)
+	// Use the configured SOCKS port, or choose the best one reported by tor
+	discovered, err := chooseSocksAddress(connStatus.SocksAddress, n.controlAddress)
+	if !discovered.IsValid() {
+		discovered, _ = parseSocksAddress(DefaultSocksAddress)
+	}
+	n.controlMutex.Lock()
+	socks := n.configuredSocks
+	auth := n.socksAuth
+	n.controlMutex.Unlock()
+	if !socks.IsValid() {
+		socks = discovered
+		if err == nil {
+			log.Printf("Discovered SOCKS port %s %s", socks.Network, socks.Address)
+		} else {
+			log.Printf("No SOCKS port discovered (%v), using default %s", err, DefaultSocksAddress)
+		}
+	}
+	if err := socks.checkReachable(auth); err != nil {
+		log.Printf("%v", err)
+		conn.Close()
+		return err
 	}
 
 	n.controlMutex.Lock()
@@ -580,6 +913,7 @@ func (n *Network) connectControl() error {
 		TorVersion: pinfo.TorVersion,
 	}
 	n.status.Connection = &connStatus
+	n.discoveredSocks = discovered
 	n.socksAddress = socks
 	status := n.status
 	n.controlMutex.Unlock()
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/yawning/bulb"
+	"golang.org/x/net/context"
+	"golang.org/x/net/proxy"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// socksCredentials accepts one SOCKS5 connection on listener, and returns the
+// username and password it authenticated with, or empty strings if it didn't
+// authenticate. The connection request is refused.
+func socksCredentials(t *testing.T, listener net.Listener) (string, string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	request, err := readSocksRequest(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request.user, request.password
+}
+
+// socksRequest is a connection request received by a test SOCKS5 server
+type socksRequest struct {
+	user, password string
+	// Host:port of the destination
+	address string
+}
+
+// readSocksRequest reads a SOCKS5 handshake and connection request for a
+// domain name from conn, and refuses the request
+func readSocksRequest(conn net.Conn) (socksRequest, error) {
+	var request socksRequest
+	// Version, and the authentication methods offered
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return request, err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return request, err
+	}
+	if !strings.Contains(string(methods), "\x02") {
+		conn.Write([]byte{5, 0})
+	} else {
+		// Username and password authentication (RFC 1929)
+		conn.Write([]byte{5, 2})
+		credentials := make([]string, 2)
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return request, err
+		}
+		for i := range credentials {
+			if _, err := io.ReadFull(conn, header[:1]); err != nil {
+				return request, err
+			}
+			value := make([]byte, header[0])
+			if _, err := io.ReadFull(conn, value); err != nil {
+				return request, err
+			}
+			credentials[i] = string(value)
+		}
+		conn.Write([]byte{1, 0})
+		request.user, request.password = credentials[0], credentials[1]
+	}
+
+	// Version, command, reserved, address type 3 and the domain name's length
+	connect := make([]byte, 5)
+	if _, err := io.ReadFull(conn, connect); err != nil {
+		return request, err
+	}
+	if connect[3] != 3 {
+		return request, errors.New("not a domain name")
+	}
+	host := make([]byte, int(connect[4])+2)
+	if _, err := io.ReadFull(conn, host); err != nil {
+		return request, err
+	}
+	port := int(host[len(host)-2])<<8 | int(host[len(host)-1])
+	request.address = net.JoinHostPort(string(host[:len(host)-2]), strconv.Itoa(port))
+	// Host unreachable
+	conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+	return request, nil
+}
+
+// Connections with different isolation keys authenticate to tor's SOCKS port
+// with different credentials, which don't reveal the key, so tor puts them on
+// different circuits. Configured credentials are always used as they are.
+func TestSocksIsolation(t *testing.T) {
+	tests := []struct {
+		name string
+		// Isolation keys of two connections, and the configured credentials
+		keys       [2]string
+		configured *proxy.Auth
+		// Whether each connection authenticates, and with the same credentials
+		authenticated bool
+		same          bool
+	}{
+		{"different contacts", [2]string{"ricochet:bbbbbbbbbbbbbbbb", "ricochet:cccccccccccccccc"}, nil, true, false},
+		{"same contact", [2]string{"ricochet:bbbbbbbbbbbbbbbb", "ricochet:bbbbbbbbbbbbbbbb"}, nil, true, true},
+		{"not isolated", [2]string{"", ""}, nil, false, true},
+		{"configured credentials", [2]string{"ricochet:bbbbbbbbbbbbbbbb", "ricochet:cccccccccccccccc"}, &proxy.Auth{User: "user", Password: "pass"}, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			network := CreateNetwork()
+			network.controlMutex.Lock()
+			network.socksAddress = socksAddress{Network: "tcp", Address: listener.Addr().String()}
+			network.socksAuth = test.configured
+			network.status.Connection = &ricochet.TorConnectionStatus{Status: ricochet.TorConnectionStatus_READY}
+			network.controlMutex.Unlock()
+
+			var users, passwords [2]string
+			for i, key := range test.keys {
+				dialer, err := network.WaitForProxyDialer(proxy.Direct, key, context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				go func() {
+					if conn, err := dialer.Dial("tcp", "bbbbbbbbbbbbbbbb.onion:9878"); err == nil {
+						conn.Close()
+					}
+				}()
+				users[i], passwords[i] = socksCredentials(t, listener)
+
+				if authenticated := users[i] != ""; authenticated != test.authenticated {
+					t.Errorf("connection %d authenticated %v, expected %v", i, authenticated, test.authenticated)
+				}
+				if test.configured != nil && (users[i] != test.configured.User || passwords[i] != test.configured.Password) {
+					t.Errorf("connection %d authenticated as %q %q, expected the configured credentials", i, users[i], passwords[i])
+				}
+				if key != "" && (strings.Contains(users[i], key) || strings.Contains(passwords[i], key)) {
+					t.Errorf("connection %d credentials %q %q contain the isolation key", i, users[i], passwords[i])
+				}
+			}
+			if same := users[0] == users[1] && passwords[0] == passwords[1]; same != test.same {
+				t.Errorf("connections used the same credentials %v, expected %v", same, test.same)
+			}
+		})
+	}
+}
+
+// serveSocks accepts SOCKS5 connections on listener until it's closed, and
+// sends the connection requests they make, refusing each one. Connections
+// that don't make a request, such as checks of the SOCKS port, are ignored.
+func serveSocks(listener net.Listener) <-chan socksRequest {
+	requests := make(chan socksRequest, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if request, err := readSocksRequest(conn); err == nil {
+					requests <- request
+				}
+			}()
+		}
+	}()
+	return requests
+}
+
+// Changing the SOCKS settings while connected to tor restarts outbound
+// connection attempts right away through the new proxy, instead of waiting out
+// their backoff with the old one. Settings that don't work are refused, and
+// changes while disconnected wait until tor is connected.
+func TestProxyChangeRestartsConnections(t *testing.T) {
+	tests := []struct {
+		name      string
+		connected bool
+		// Changes the settings, given the address of a new proxy
+		change func(n *Network, address string) error
+		// The new proxy can't be reached
+		unreachable bool
+		// Whether the change is refused, and which proxy the attempt restarts on,
+		// if it restarts
+		refused   bool
+		restartOn string
+		user      string
+	}{
+		{"address", true, func(n *Network, address string) error {
+			return n.SetSocksAddress(address)
+		}, false, false, "new", ""},
+		{"credentials", true, func(n *Network, address string) error {
+			return n.SetSocksAuth("user", "pass")
+		}, false, false, "old", "user"},
+		{"unreachable", true, func(n *Network, address string) error {
+			return n.SetSocksAddress(address)
+		}, true, true, "", ""},
+		{"disconnected", false, func(n *Network, address string) error {
+			return n.SetSocksAddress(address)
+		}, false, false, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proxies := make(map[string]<-chan socksRequest)
+			addresses := make(map[string]string)
+			for _, name := range []string{"old", "new"} {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer listener.Close()
+				proxies[name] = serveSocks(listener)
+				addresses[name] = listener.Addr().String()
+				if name == "new" && test.unreachable {
+					listener.Close()
+				}
+			}
+
+			core := newTestCore(t)
+			core.ServicePort = 9878
+			// Attempts wait for a restart after failing
+			core.ConnectBackoff = BackoffSchedule{Initial: time.Hour, Max: time.Hour, Multiplier: 2}
+			core.Network = CreateNetwork()
+			core.Network.SetProxyChangedCallback(core.proxyChanged)
+			t.Cleanup(core.Identity.contactList.shutdown)
+			network := core.Network
+			network.controlMutex.Lock()
+			network.configuredSocks = socksAddress{Network: "tcp", Address: addresses["old"]}
+			network.socksAddress = network.configuredSocks
+			network.status.Connection = &ricochet.TorConnectionStatus{Status: ricochet.TorConnectionStatus_READY}
+			if test.connected {
+				network.conn = &bulb.Conn{}
+			}
+			network.controlMutex.Unlock()
+
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			go contact.StartConnection()
+			select {
+			case request := <-proxies["old"]:
+				if host, _, _ := net.SplitHostPort(request.address); host != "bbbbbbbbbbbbbbbb.onion" {
+					t.Fatalf("connected to %s, expected the contact", request.address)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("contact didn't connect through the proxy")
+			}
+			// Wait for the attempts to settle into their backoff
+		settle:
+			for {
+				select {
+				case <-proxies["old"]:
+				case <-time.After(200 * time.Millisecond):
+					break settle
+				}
+			}
+
+			err := test.change(network, addresses["new"])
+			if refused := err != nil; refused != test.refused {
+				t.Fatalf("change returned %v, expected refused %v", err, test.refused)
+			}
+
+			wait := 5 * time.Second
+			if test.restartOn == "" {
+				wait = 200 * time.Millisecond
+			}
+			select {
+			case request := <-proxies["old"]:
+				if test.restartOn != "old" {
+					t.Fatalf("reconnected through the old proxy to %s", request.address)
+				} else if request.user != test.user {
+					t.Errorf("reconnected as %q, expected %q", request.user, test.user)
+				}
+			case request := <-proxies["new"]:
+				if test.restartOn != "new" {
+					t.Fatalf("reconnected through the new proxy to %s", request.address)
+				} else if request.user != test.user {
+					t.Errorf("reconnected as %q, expected %q", request.user, test.user)
+				}
+			case <-time.After(wait):
+				if test.restartOn != "" {
+					t.Fatalf("didn't reconnect through the %s proxy", test.restartOn)
+				}
+			}
+		})
+	}
+}
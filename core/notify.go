@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+	"golang.org/x/net/context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultNotifyRate is used when MessageNotifier.Rate is unset
+	DefaultNotifyRate = 10
+	// DefaultNotifyBurst is used when MessageNotifier.Burst is unset
+	DefaultNotifyBurst = 5
+	// Messages are cut to this many characters for {preview}
+	notifyPreviewLength = 80
+	// Commands still running after this long are killed
+	notifyCommandTimeout = 30 * time.Second
+	// Notifications waiting to run; more are dropped
+	notifyQueueSize = 16
+)
+
+// MessageNotifier runs an external command when a message is received, e.g. to
+// show a desktop notification from a headless backend.
+//
+// The command is run directly, not through a shell. In its arguments, the
+// placeholders {nickname}, {address} and {preview} are replaced by the contact's
+// nickname and address and the start of the message, and the same values are
+// set in the environment as RICOCHET_NICKNAME, RICOCHET_ADDRESS and
+// RICOCHET_PREVIEW. Nicknames and messages are chosen by contacts and must be
+// treated as untrusted by the command: passing them to a shell, or to anything
+// else that interprets them, lets contacts run commands on this machine.
+//
+// Runs are limited to Rate per minute after an initial Burst; notifications
+// over the limit, or while too many are waiting to run, are dropped.
+type MessageNotifier struct {
+	// Command and arguments to run
+	Command []string
+	// Maximum runs per minute; DefaultNotifyRate if zero
+	Rate int
+	// Runs allowed at once before the rate applies; DefaultNotifyBurst if zero
+	Burst int
+
+	log     *utils.Logger
+	limiter *MessageRateLimiter
+	queue   chan notification
+}
+
+type notification struct {
+	args []string
+	env  []string
+}
+
+// start validates the command and starts running notifications. It's called by
+// Ricochet.Init.
+func (mn *MessageNotifier) start(log *utils.Logger) error {
+	if len(mn.Command) == 0 || mn.Command[0] == "" {
+		return errors.New("Notify command is empty")
+	}
+	if mn.Rate == 0 {
+		mn.Rate = DefaultNotifyRate
+	}
+	if mn.Burst == 0 {
+		mn.Burst = DefaultNotifyBurst
+	}
+	mn.log = log
+	mn.limiter = NewMessageRateLimiter(mn.Rate, mn.Burst)
+	mn.queue = make(chan notification, notifyQueueSize)
+	go mn.run()
+	return nil
+}
+
+// notify queues the command for a message from the contact, without waiting.
+// Messages are received while holding the conversation's mutex, so this must
+// never block.
+func (mn *MessageNotifier) notify(nickname, address, text string) {
+	if mn == nil || mn.queue == nil {
+		return
+	}
+	if allowed, _ := mn.limiter.Allow(); !allowed {
+		return
+	}
+
+	preview := text
+	if utf8.RuneCountInString(preview) > notifyPreviewLength {
+		preview = string([]rune(preview)[:notifyPreviewLength]) + "..."
+	}
+	env := []string{
+		"RICOCHET_NICKNAME=" + nickname,
+		"RICOCHET_ADDRESS=" + address,
+		"RICOCHET_PREVIEW=" + preview,
+	}
+	replacer := strings.NewReplacer("{nickname}", nickname, "{address}", address, "{preview}", preview)
+	args := make([]string, len(mn.Command)-1)
+	for i, arg := range mn.Command[1:] {
+		args[i] = replacer.Replace(arg)
+	}
+
+	select {
+	case mn.queue <- notification{args: args, env: env}:
+	default:
+		mn.log.Debugf("Dropped message notification because too many are waiting")
+	}
+}
+
+// run executes queued notifications one at a time
+func (mn *MessageNotifier) run() {
+	for n := range mn.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyCommandTimeout)
+		cmd := exec.CommandContext(ctx, mn.Command[0], n.args...)
+		cmd.Env = append(os.Environ(), n.env...)
+		if err := cmd.Run(); err != nil {
+			mn.log.Warnf("Notify command failed: %v", err)
+		}
+		cancel()
+	}
+}
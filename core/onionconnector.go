@@ -2,11 +2,12 @@ package core
 
 import (
 	"errors"
+	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"golang.org/x/net/context"
-	"log"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -14,6 +15,150 @@ type OnionConnector struct {
 	Network      *Network
 	NeverGiveUp  bool
 	AttemptCount int
+	// If set, each connection attempt waits for a slot from Limiter
+	Limiter *ConnectLimiter
+	// Maximum time for a single connection attempt, including building the tor
+	// circuit. If zero, DefaultConnectAttemptTimeout is used.
+	AttemptTimeout time.Duration
+	// Delays between failed attempts. If Schedule.Initial is zero,
+	// DefaultBackoffSchedule is used.
+	Schedule BackoffSchedule
+	// After the network status changes, wait a random time up to this before
+	// the next attempt. Otherwise, every connector waiting for the network
+	// would try to connect at the same moment once it's back.
+	ReconnectJitter time.Duration
+	// If set, called with true when a connection attempt starts, and with
+	// false when the connector starts waiting before the next attempt
+	AttemptStateChanged func(attempting bool)
+	// If set, connections are isolated onto tor circuits that aren't shared
+	// with connectors using a different key; see Network.WaitForProxyDialer
+	IsolationKey string
+	// Receives failed attempts; if nil, they're logged at LogInfo
+	Log *utils.Logger
+}
+
+// DefaultReconnectJitter is used when Ricochet.ReconnectJitter is unset
+const DefaultReconnectJitter = 20 * time.Second
+
+// BackoffSchedule defines the delays between failed connection attempts. The
+// first retry waits Initial, and each later retry waits Multiplier times longer
+// than the last, up to Max. Each delay is then randomly adjusted by up to
+// Jitter, as a fraction of the delay, so that many connectors that failed at
+// once don't all retry at once.
+type BackoffSchedule struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// DefaultBackoffSchedule is used when OnionConnector.Schedule is unset
+var DefaultBackoffSchedule = BackoffSchedule{
+	Initial:    30 * time.Second,
+	Max:        15 * time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Validate returns an error if the schedule can't be used
+func (bs BackoffSchedule) Validate() error {
+	if bs.Initial <= 0 || bs.Max < bs.Initial {
+		return errors.New("Backoff delays must be positive, and the maximum must not be less than the initial delay")
+	} else if bs.Multiplier < 1 {
+		return errors.New("Backoff multiplier must be at least 1")
+	} else if bs.Jitter < 0 || bs.Jitter >= 1 {
+		return errors.New("Backoff jitter must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+// Delay returns the delay before retrying after the given number of failed
+// attempts, without jitter. There is no delay before the first attempt.
+func (bs BackoffSchedule) Delay(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	delay := float64(bs.Initial)
+	for i := 1; i < attempts && delay < float64(bs.Max); i++ {
+		delay *= bs.Multiplier
+	}
+	if delay > float64(bs.Max) {
+		return bs.Max
+	}
+	return time.Duration(delay)
+}
+
+// JitteredDelay returns Delay adjusted randomly by up to +/-Jitter
+func (bs BackoffSchedule) JitteredDelay(attempts int) time.Duration {
+	delay := float64(bs.Delay(attempts))
+	return time.Duration(delay + delay*bs.Jitter*(rand.Float64()*2-1))
+}
+
+// DefaultConnectAttemptTimeout is used when OnionConnector.AttemptTimeout is unset
+const DefaultConnectAttemptTimeout = 60 * time.Second
+
+// deadlineDialer sets a deadline on the connection to the SOCKS port, which
+// bounds the whole SOCKS handshake and not only connecting to the port.
+type deadlineDialer struct {
+	dialer   *net.Dialer
+	deadline time.Time
+	conn     net.Conn
+}
+
+func (d *deadlineDialer) Dial(network, address string) (net.Conn, error) {
+	d.dialer.Deadline = d.deadline
+	conn, err := d.dialer.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(d.deadline)
+	d.conn = conn
+	return conn, nil
+}
+
+// ConnectLimiter limits the number of connection attempts in progress at once,
+// which may be shared by many OnionConnectors. Without a limit, starting with
+// many offline contacts would ask tor to build circuits for all of them at once.
+//
+// A slot is only held for the duration of one attempt, not while backing off,
+// and waiting attempts are given slots in the order they asked for them, so
+// contacts that are slow to connect don't starve the others.
+type ConnectLimiter struct {
+	slots chan struct{}
+}
+
+// NewConnectLimiter returns a limiter allowing up to max attempts at once
+func NewConnectLimiter(max int) *ConnectLimiter {
+	return &ConnectLimiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// Acquire waits for a slot or for the context to be cancelled
+func (cl *ConnectLimiter) Acquire(c context.Context) error {
+	select {
+	case cl.slots <- struct{}{}:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// Release returns a slot taken by Acquire
+func (cl *ConnectLimiter) Release() {
+	<-cl.slots
+}
+
+// newOnionConnector returns a connector with the network, limiter, and attempt
+// settings configured on core
+func (core *Ricochet) newOnionConnector() *OnionConnector {
+	return &OnionConnector{
+		Network:         core.Network,
+		Limiter:         core.connectLimiter,
+		AttemptTimeout:  core.ConnectAttemptTimeout,
+		Schedule:        core.ConnectBackoff,
+		ReconnectJitter: core.ReconnectJitter,
+	}
 }
 
 // Attempt to connect to 'address', which must be a .onion address and port,
@@ -40,22 +185,33 @@ func (oc *OnionConnector) Connect(address string, c context.Context) (net.Conn,
 		return nil, errors.New("Invalid address")
 	}
 
-	options := &net.Dialer{
-		Cancel:  c.Done(),
-		Timeout: 60 * time.Second, // Maximum time for a single connect attempt
+	attemptTimeout := oc.AttemptTimeout
+	if attemptTimeout <= 0 {
+		attemptTimeout = DefaultConnectAttemptTimeout
+	}
+	options := &deadlineDialer{
+		dialer: &net.Dialer{Cancel: c.Done()},
 	}
 
-	// Internal context used by blocking functions, assigned in the loop
+	// Internal context used by blocking functions, assigned in the loop.
+	// cancelWaitFunc is also called by the network monitor, so waitMutex
+	// must be held to use it.
 	var waitCtx context.Context
+	var waitMutex sync.Mutex
 	cancelWaitFunc := func() {}
 	// Cancel at return, extra closure required to call the current value
-	defer func() { cancelWaitFunc() }()
+	defer func() {
+		waitMutex.Lock()
+		cancelWaitFunc()
+		waitMutex.Unlock()
+	}()
 
-	// Monitor for network connection status changes. On any change, reset backoff
-	// and call cancelWaitFunc, which is set with waitCtx in the loop below, to cancel
-	// the current wait and try again.
+	// Monitor for network connection status changes. On any change, signal the loop
+	// below to reset backoff, and call cancelWaitFunc, which is set with waitCtx in
+	// the loop, to cancel the current wait and try again.
 	networkMonitor := oc.Network.EventMonitor().Subscribe(20)
 	defer oc.Network.EventMonitor().Unsubscribe(networkMonitor)
+	networkChanged := make(chan struct{}, 1)
 	go func() {
 		var prevConnStatus ricochet.TorConnectionStatus_Status
 
@@ -77,17 +233,54 @@ func (oc *OnionConnector) Connect(address string, c context.Context) (net.Conn,
 				}
 				if connStatus != prevConnStatus {
 					prevConnStatus = connStatus
-					oc.ResetBackoff()
+					select {
+					case networkChanged <- struct{}{}:
+					default:
+					}
+					waitMutex.Lock()
 					cancelWaitFunc()
+					waitMutex.Unlock()
 				}
 			}
 		}
 	}()
 
 	for {
+		select {
+		case <-networkChanged:
+			oc.ResetBackoff()
+			if err := oc.waitReconnectJitter(c); err != nil {
+				return nil, err
+			}
+		default:
+		}
+		waitMutex.Lock()
 		waitCtx, cancelWaitFunc = context.WithCancel(c)
+		waitMutex.Unlock()
+
+		if resolver := oc.Network.Resolver(); resolver != nil {
+			attemptCtx, cancelAttempt := context.WithTimeout(waitCtx, attemptTimeout)
+			oc.attemptStateChanged(true)
+			conn, err := oc.resolve(resolver, address, attemptCtx)
+			cancelAttempt()
+			if err == nil {
+				return conn, nil
+			} else if c.Err() != nil {
+				return nil, c.Err()
+			} else if waitCtx.Err() != nil {
+				continue
+			} else if !oc.NeverGiveUp {
+				return nil, err
+			}
 
-		proxy, err := oc.Network.WaitForProxyDialer(options, waitCtx)
+			oc.Log.Infof("Connection attempt %d to %s failed: %s", oc.AttemptCount, address, err)
+			if err := oc.Backoff(waitCtx); err != nil && c.Err() != nil {
+				return nil, c.Err()
+			}
+			continue
+		}
+
+		proxy, err := oc.Network.WaitForProxyDialer(options, oc.IsolationKey, waitCtx)
 		if err != nil {
 			if c.Err() != nil {
 				return nil, c.Err()
@@ -98,7 +291,28 @@ func (oc *OnionConnector) Connect(address string, c context.Context) (net.Conn,
 			}
 		}
 
+		if oc.Limiter != nil {
+			if err := oc.Limiter.Acquire(waitCtx); err != nil {
+				if c.Err() != nil {
+					return nil, c.Err()
+				}
+				continue
+			}
+		}
+		// The attempt is abandoned at the deadline, and retried after the backoff
+		options.deadline = time.Now().Add(attemptTimeout)
+		options.conn = nil
+		oc.attemptStateChanged(true)
 		conn, err := proxy.Dial("tcp", address)
+		if oc.Limiter != nil {
+			oc.Limiter.Release()
+		}
+		if err == nil && options.conn != nil {
+			options.conn.SetDeadline(time.Time{})
+		}
+		if c.Err() == nil {
+			oc.Network.reportSocksResult(err)
+		}
 		if err == nil {
 			// Success!
 			return conn, nil
@@ -108,7 +322,7 @@ func (oc *OnionConnector) Connect(address string, c context.Context) (net.Conn,
 			return nil, err
 		}
 
-		log.Printf("Connection attempt %d to %s failed: %s", oc.AttemptCount, address, err)
+		oc.Log.Infof("Connection attempt %d to %s failed: %s", oc.AttemptCount, address, err)
 
 		if err := oc.Backoff(waitCtx); err != nil {
 			if c.Err() != nil {
@@ -122,26 +336,76 @@ func (oc *OnionConnector) Connect(address string, c context.Context) (net.Conn,
 	}
 }
 
-var backoffDelay [7]int = [7]int{0, 30, 60, 120, 300, 600, 900}
+// Make one connection attempt with a custom resolver, which is abandoned if
+// the context is cancelled.
+func (oc *OnionConnector) resolve(resolver Resolver, address string, c context.Context) (net.Conn, error) {
+	if oc.Limiter != nil {
+		if err := oc.Limiter.Acquire(c); err != nil {
+			return nil, err
+		}
+		defer oc.Limiter.Release()
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	resultChannel := make(chan result, 1)
+	go func() {
+		conn, _, err := resolver.Resolve(address)
+		resultChannel <- result{conn, err}
+	}()
+
+	select {
+	case r := <-resultChannel:
+		return r.conn, r.err
+	case <-c.Done():
+		// Close the connection if the attempt finishes later
+		go func() {
+			if r := <-resultChannel; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, c.Err()
+	}
+}
+
+// Wait for a random time up to ReconnectJitter, or until the context is cancelled
+func (oc *OnionConnector) waitReconnectJitter(c context.Context) error {
+	if oc.ReconnectJitter <= 0 {
+		return nil
+	}
+	delay := time.Duration(rand.Int63n(int64(oc.ReconnectJitter)))
+	waitCtx, finish := context.WithTimeout(c, delay)
+	defer finish()
+	<-waitCtx.Done()
+	return c.Err()
+}
 
+// Backoff counts a failed attempt and waits for the delay from Schedule, or
+// until the context is cancelled.
 func (oc *OnionConnector) Backoff(c context.Context) error {
 	oc.AttemptCount++
+	oc.attemptStateChanged(false)
 
-	var delay int
-	if oc.AttemptCount < len(backoffDelay) {
-		delay = backoffDelay[oc.AttemptCount]
-	} else {
-		delay = backoffDelay[len(backoffDelay)-1]
+	schedule := oc.Schedule
+	if schedule.Initial == 0 {
+		schedule = DefaultBackoffSchedule
 	}
-	// Jitter by +/-20%
-	delay += int(float32(delay) * (rand.Float32()*0.4 - 0.2))
+	delay := schedule.JitteredDelay(oc.AttemptCount)
 
-	waitCtx, finish := context.WithTimeout(c, time.Duration(delay)*time.Second)
+	waitCtx, finish := context.WithTimeout(c, delay)
 	defer finish()
 	<-waitCtx.Done()
 	return c.Err()
 }
 
+func (oc *OnionConnector) attemptStateChanged(attempting bool) {
+	if oc.AttemptStateChanged != nil {
+		oc.AttemptStateChanged(attempting)
+	}
+}
+
 func (oc *OnionConnector) ResetBackoff() {
 	oc.AttemptCount = 0
 }
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"errors"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hangingResolver hangs on its first hang attempts until the test ends, and
+// connects on later attempts. It records when each attempt started.
+type hangingResolver struct {
+	hang    int
+	release chan struct{}
+
+	mutex  sync.Mutex
+	starts []time.Time
+}
+
+func (r *hangingResolver) Resolve(address string) (net.Conn, string, error) {
+	r.mutex.Lock()
+	r.starts = append(r.starts, time.Now())
+	attempt := len(r.starts)
+	r.mutex.Unlock()
+
+	if attempt <= r.hang {
+		<-r.release
+		return nil, "", errors.New("Test resolver released")
+	}
+	conn, peer := net.Pipe()
+	peer.Close()
+	host, _, _ := net.SplitHostPort(address)
+	return conn, host, nil
+}
+
+// Stuck attempts are abandoned after AttemptTimeout, and retried after the
+// backoff if NeverGiveUp is set
+func TestConnectAttemptTimeout(t *testing.T) {
+	const attemptTimeout = 50 * time.Millisecond
+	tests := []struct {
+		name        string
+		neverGiveUp bool
+		// Number of attempts that hang
+		hang     int
+		attempts int
+		success  bool
+	}{
+		{"gives up after a stuck attempt", false, 1, 1, false},
+		{"retries a stuck attempt", true, 1, 2, true},
+		{"retries stuck attempts", true, 3, 4, true},
+		{"connects", false, 0, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := &hangingResolver{hang: test.hang, release: make(chan struct{})}
+			defer close(resolver.release)
+			network := CreateNetwork()
+			network.SetResolver(resolver)
+			connector := &OnionConnector{
+				Network:        network,
+				NeverGiveUp:    test.neverGiveUp,
+				AttemptTimeout: attemptTimeout,
+				Schedule:       BackoffSchedule{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := connector.Connect("aaaaaaaaaaaaaaaa.onion:9878", ctx)
+			if conn != nil {
+				conn.Close()
+			}
+			if (err == nil) != test.success {
+				t.Fatalf("error is %v, expected success %v", err, test.success)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("attempts weren't abandoned before the overall timeout")
+			}
+
+			resolver.mutex.Lock()
+			defer resolver.mutex.Unlock()
+			if len(resolver.starts) != test.attempts {
+				t.Fatalf("made %d attempts, expected %d", len(resolver.starts), test.attempts)
+			}
+			for i := 1; i < len(resolver.starts); i++ {
+				if d := resolver.starts[i].Sub(resolver.starts[i-1]); d < attemptTimeout {
+					t.Errorf("attempt %d started %v after the previous one, expected at least %v", i+1, d, attemptTimeout)
+				}
+			}
+		})
+	}
+}
+
+// Delays follow the configured schedule, and jitter stays within its bounds
+func TestBackoffSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule BackoffSchedule
+		// Delays after 0, 1, 2... failed attempts, before jitter
+		delays []time.Duration
+	}{
+		{"default", DefaultBackoffSchedule, []time.Duration{0, 30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 15 * time.Minute, 15 * time.Minute}},
+		{"constant", BackoffSchedule{Initial: time.Second, Max: time.Second, Multiplier: 1}, []time.Duration{0, time.Second, time.Second, time.Second}},
+		{"fractional multiplier", BackoffSchedule{Initial: 4 * time.Second, Max: 10 * time.Second, Multiplier: 1.5, Jitter: 0.5}, []time.Duration{0, 4 * time.Second, 6 * time.Second, 9 * time.Second, 10 * time.Second}},
+		{"no multiplier", BackoffSchedule{Initial: time.Second, Max: time.Minute, Multiplier: 1, Jitter: 0.9}, []time.Duration{0, time.Second, time.Second}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.schedule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			for attempts, expected := range test.delays {
+				if delay := test.schedule.Delay(attempts); delay != expected {
+					t.Errorf("delay after %d attempts is %v, expected %v", attempts, delay, expected)
+				}
+
+				min := time.Duration(float64(expected) * (1 - test.schedule.Jitter))
+				max := time.Duration(float64(expected) * (1 + test.schedule.Jitter))
+				for i := 0; i < 100; i++ {
+					if delay := test.schedule.JitteredDelay(attempts); delay < min || delay > max {
+						t.Fatalf("jittered delay after %d attempts is %v, expected between %v and %v", attempts, delay, min, max)
+					}
+				}
+			}
+		})
+	}
+
+	// Connectors for contacts use the schedule configured on the core
+	core := newTestCore(t)
+	core.ConnectBackoff = BackoffSchedule{Initial: time.Second, Max: time.Minute, Multiplier: 3, Jitter: 0.1}
+	if schedule := core.newOnionConnector().Schedule; schedule != core.ConnectBackoff {
+		t.Errorf("connector's schedule is %+v, expected %+v", schedule, core.ConnectBackoff)
+	}
+}
+
+// Schedules that would never back off, or never retry, are refused
+func TestBackoffScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule BackoffSchedule
+		ok       bool
+	}{
+		{"default", DefaultBackoffSchedule, true},
+		{"no jitter", BackoffSchedule{Initial: time.Second, Max: time.Second, Multiplier: 1}, true},
+		{"no initial delay", BackoffSchedule{Max: time.Second, Multiplier: 1}, false},
+		{"maximum below initial", BackoffSchedule{Initial: time.Minute, Max: time.Second, Multiplier: 2}, false},
+		{"shrinking", BackoffSchedule{Initial: time.Second, Max: time.Minute, Multiplier: 0.5}, false},
+		{"negative jitter", BackoffSchedule{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: -0.1}, false},
+		{"full jitter", BackoffSchedule{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 1}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.schedule.Validate(); (err == nil) != test.ok {
+				t.Errorf("error is %v, expected valid %v", err, test.ok)
+			}
+		})
+	}
+}
+
+// recoveringResolver fails until recovered is closed, and then connects and
+// records when each connection was made
+type recoveringResolver struct {
+	recovered chan struct{}
+
+	mutex    sync.Mutex
+	failures int
+	connects []time.Time
+}
+
+func (r *recoveringResolver) Resolve(address string) (net.Conn, string, error) {
+	select {
+	case <-r.recovered:
+	default:
+		r.mutex.Lock()
+		r.failures++
+		r.mutex.Unlock()
+		return nil, "", errors.New("Test network is down")
+	}
+	r.mutex.Lock()
+	r.connects = append(r.connects, time.Now())
+	r.mutex.Unlock()
+	conn, peer := net.Pipe()
+	peer.Close()
+	host, _, _ := net.SplitHostPort(address)
+	return conn, host, nil
+}
+
+// When the network comes back, connectors waiting to retry reconnect at
+// random times within ReconnectJitter, rather than all at once
+func TestReconnectJitter(t *testing.T) {
+	const connectors = 20
+	tests := []struct {
+		name   string
+		jitter time.Duration
+		// Expected range of the time between the first and last reconnection
+		minSpread time.Duration
+		maxSpread time.Duration
+	}{
+		{"no jitter", 0, 0, 200 * time.Millisecond},
+		{"jitter", time.Second, 300 * time.Millisecond, time.Second + 200*time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := &recoveringResolver{recovered: make(chan struct{})}
+			network := CreateNetwork()
+			network.SetResolver(resolver)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var wg sync.WaitGroup
+			for i := 0; i < connectors; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					connector := &OnionConnector{
+						Network:         network,
+						NeverGiveUp:     true,
+						Schedule:        BackoffSchedule{Initial: time.Minute, Max: time.Minute, Multiplier: 1},
+						ReconnectJitter: test.jitter,
+					}
+					if conn, err := connector.Connect("aaaaaaaaaaaaaaaa.onion:9878", ctx); err == nil {
+						conn.Close()
+					}
+				}()
+			}
+
+			// Every connector fails once and waits a minute to retry, until the
+			// network status changes
+			for {
+				resolver.mutex.Lock()
+				failures := resolver.failures
+				resolver.mutex.Unlock()
+				if failures >= connectors {
+					break
+				} else if ctx.Err() != nil {
+					t.Fatalf("%d connectors attempted to connect, expected %d", failures, connectors)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			close(resolver.recovered)
+			recovered := time.Now()
+			network.events.Publish(ricochet.NetworkStatus{
+				Connection: &ricochet.TorConnectionStatus{Status: ricochet.TorConnectionStatus_READY},
+			})
+			wg.Wait()
+
+			resolver.mutex.Lock()
+			defer resolver.mutex.Unlock()
+			if len(resolver.connects) != connectors {
+				t.Fatalf("%d connectors reconnected, expected %d", len(resolver.connects), connectors)
+			}
+			first, last := resolver.connects[0], resolver.connects[0]
+			for _, when := range resolver.connects {
+				if when.Before(first) {
+					first = when
+				}
+				if when.After(last) {
+					last = when
+				}
+			}
+			if spread := last.Sub(first); spread < test.minSpread || spread > test.maxSpread {
+				t.Errorf("reconnections were spread over %v, expected between %v and %v", spread, test.minSpread, test.maxSpread)
+			}
+			if delay := last.Sub(recovered); delay > test.maxSpread {
+				t.Errorf("last reconnection was %v after the network changed, expected at most %v", delay, test.maxSpread)
+			}
+		})
+	}
+}
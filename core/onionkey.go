@@ -0,0 +1,87 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"github.com/yawning/bulb"
+	"github.com/yawning/bulb/utils/pkcs1"
+)
+
+// The identity's onion service key is an *rsa.PrivateKey for a v2 service, or
+// an ed25519.PrivateKey for a v3 service. New identities are v3, because tor no
+// longer supports creating v2 services; existing v2 identities keep working.
+//
+// Stored keys are the PKCS#1 DER encoding of RSA keys, and the 32 byte seed of
+// ed25519 keys, which can't be mistaken for DER.
+
+var UnsupportedKeyError error = errors.New("Unsupported onion service key type")
+
+// newOnionKey generates the key of a new v3 onion service
+func newOnionKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(cryptorand.Reader)
+	return key, err
+}
+
+func encodeOnionKey(key crypto.PrivateKey) ([]byte, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return pkcs1.EncodePrivateKeyDER(key)
+	case ed25519.PrivateKey:
+		return key.Seed(), nil
+	default:
+		return nil, UnsupportedKeyError
+	}
+}
+
+func decodeOnionKey(data []byte) (crypto.PrivateKey, error) {
+	if len(data) == ed25519.SeedSize {
+		return ed25519.NewKeyFromSeed(data), nil
+	}
+	key, _, err := pkcs1.DecodePrivateKeyDER(data)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// onionPublicKey returns the public key for an onion service key, which is an
+// *rsa.PublicKey or an ed25519.PublicKey
+func onionPublicKey(key crypto.PrivateKey) crypto.PublicKey {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case ed25519.PrivateKey:
+		return key.Public()
+	default:
+		return nil
+	}
+}
+
+// AddressFromPrivateKey returns the address of the onion service with key
+func AddressFromPrivateKey(key crypto.PrivateKey) (string, error) {
+	return AddressFromKey(onionPublicKey(key))
+}
+
+// torOnionKey returns key in the form used by bulb to add an onion service.
+// Tor takes ed25519 keys in their expanded form, as the clamped SHA-512 hash
+// of the seed.
+func torOnionKey(key crypto.PrivateKey) crypto.PrivateKey {
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		expanded := sha512.Sum512(key.Seed())
+		expanded[0] &= 248
+		expanded[31] &= 127
+		expanded[31] |= 64
+		return &bulb.OnionPrivateKey{
+			KeyType: "ED25519-V3",
+			Key:     base64.StdEncoding.EncodeToString(expanded[:]),
+		}
+	default:
+		return key
+	}
+}
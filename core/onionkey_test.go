@@ -0,0 +1,86 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"github.com/yawning/bulb"
+	"testing"
+)
+
+func TestOnionKeyEncoding(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Key, err := newOnionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.PrivateKey
+		v3   bool
+	}{
+		{"v2", rsaKey, false},
+		{"v3", ed25519Key, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := encodeOnionKey(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := decodeOnionKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			address, err := AddressFromPrivateKey(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded, err := AddressFromPrivateKey(key); err != nil || decoded != address {
+				t.Errorf("decoded key has address %s (%v), expected %s", decoded, err, address)
+			}
+			if IsAddressV3(address) != test.v3 {
+				t.Errorf("address %s is v3 is %v, expected %v", address, !test.v3, test.v3)
+			}
+		})
+	}
+
+	if _, err := encodeOnionKey(&rsaKey.PublicKey); err != UnsupportedKeyError {
+		t.Errorf("encoding a public key returned %v, expected UnsupportedKeyError", err)
+	}
+}
+
+// Tor takes the expanded form of ed25519 keys, checked here against the first
+// test vector of RFC 8032
+func TestTorOnionKey(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	key := ed25519.NewKeyFromSeed(seed)
+	if address, _ := AddressFromPrivateKey(key); address != "ricochet:"+testV3PlainHost {
+		t.Errorf("address is %s, expected ricochet:%s", address, testV3PlainHost)
+	}
+
+	torKey, ok := torOnionKey(key).(*bulb.OnionPrivateKey)
+	if !ok {
+		t.Fatalf("tor key is %T, expected *bulb.OnionPrivateKey", torOnionKey(key))
+	}
+	const expanded = "MHyDhk8oM8tCei7xwAoBPP3/J2jZgMCjpSDwBpBN6U+bTwr+KAt0aneGhOdUQlAgV7dHOgPwj5b1o46Sh+Afjw=="
+	if torKey.KeyType != "ED25519-V3" || torKey.Key != expanded {
+		t.Errorf("tor key is %s:%s, expected ED25519-V3:%s", torKey.KeyType, torKey.Key, expanded)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if torOnionKey(rsaKey) != crypto.PrivateKey(rsaKey) {
+		t.Error("RSA key was changed for tor")
+	}
+}
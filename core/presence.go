@@ -0,0 +1,163 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+)
+
+// Channel type for presence. Each packet is the sender's current presence: a
+// byte with the status, followed by the status message in UTF-8, which may be
+// empty. Presence is sent when the connection is established, and whenever it
+// changes.
+const presenceChannelType = "im.ricochet.presence"
+
+func encodePresence(presence *ricochet.Presence) []byte {
+	return append([]byte{byte(presence.Status)}, presence.Message...)
+}
+
+// decodePresence parses a presence packet from a contact. Unknown status values
+// are treated as available, and the message is sanitized for display.
+func decodePresence(data []byte) *ricochet.Presence {
+	presence := &ricochet.Presence{}
+	if _, ok := ricochet.Presence_Status_name[int32(data[0])]; ok {
+		presence.Status = ricochet.Presence_Status(data[0])
+	}
+	presence.Message = SanitizeStatusMessage(string(data[1:]))
+	return presence
+}
+
+// Presence is only exchanged with established contacts
+func (c *Contact) presenceAllowed() bool {
+	data := c.Data()
+	return data.Request == nil && !data.Blocked && data.Status != ricochet.Contact_REJECTED
+}
+
+// sendPresence sends our presence to the contact, if the contact is connected,
+// is not a request, rejected, or blocked, and isn't known to lack support for
+// presence.
+func (c *Contact) sendPresence(presence ricochet.Presence) error {
+	if !c.presenceAllowed() {
+		return nil
+	}
+	if supported, known := c.SupportsChannel(presenceChannelType); known && !supported {
+		return nil
+	}
+	conn := c.Connection()
+	if conn == nil {
+		return nil
+	}
+
+	data := encodePresence(&presence)
+	return conn.Do(func() error {
+		channel := conn.Channel(presenceChannelType, channels.Outbound)
+		if channel == nil {
+			ch, err := conn.RequestOpenChannel(presenceChannelType, &presenceChannel{contact: c})
+			if err != nil {
+				return err
+			}
+			channel = ch
+		}
+		handler, ok := channel.Handler.(*presenceChannel)
+		if !ok {
+			channel.CloseChannel()
+			return nil
+		}
+		handler.send(data)
+		return nil
+	})
+}
+
+// Set the contact's presence from a packet, and publish an event if it changed
+func (c *Contact) presenceNotification(presence *ricochet.Presence) {
+	if !c.presenceAllowed() {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.connection == nil || (c.remotePresence != nil && proto.Equal(c.remotePresence, presence)) {
+		return
+	}
+	c.remotePresence = presence
+
+	event := ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_UPDATE,
+		Subject: &ricochet.ContactEvent_Contact{
+			Contact: c.dataLocked(),
+		},
+	}
+	c.events.Publish(event)
+}
+
+// presenceChannel implements channels.Handler for presence
+type presenceChannel struct {
+	contact *Contact
+	channel *channels.Channel
+	// Presence to send once a pending outbound channel is opened
+	pendingState []byte
+}
+
+func (pc *presenceChannel) Type() string {
+	return presenceChannelType
+}
+
+func (pc *presenceChannel) Closed(err error) {
+}
+
+func (pc *presenceChannel) OnlyClientCanOpen() bool {
+	return false
+}
+
+func (pc *presenceChannel) Singleton() bool {
+	return true
+}
+
+func (pc *presenceChannel) Bidirectional() bool {
+	return false
+}
+
+func (pc *presenceChannel) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+
+func (pc *presenceChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	pc.channel = channel
+	channel.Pending = false
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.AckOpenChannel(channel.ID), nil
+}
+
+func (pc *presenceChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	pc.channel = channel
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.OpenChannel(channel.ID, pc.Type()), nil
+}
+
+func (pc *presenceChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	pc.contact.outboundChannelResult(presenceChannelType, err, crm)
+	if err == nil && crm.GetOpened() {
+		pc.channel.Pending = false
+		if pc.pendingState != nil {
+			pc.channel.SendMessage(pc.pendingState)
+			pc.pendingState = nil
+		}
+	}
+}
+
+func (pc *presenceChannel) Packet(data []byte) {
+	if pc.channel.Direction != channels.Inbound || len(data) < 1 {
+		return
+	}
+	pc.contact.presenceNotification(decodePresence(data))
+}
+
+func (pc *presenceChannel) send(data []byte) {
+	if pc.channel.Pending {
+		pc.pendingState = data
+	} else {
+		pc.channel.SendMessage(data)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInboundMessageRate is used when Ricochet.InboundMessageRate is unset
+	DefaultInboundMessageRate = 60
+	// DefaultInboundMessageBurst is used when Ricochet.InboundMessageBurst is unset
+	DefaultInboundMessageBurst = 30
+)
+
+// MessageRateLimiter limits the rate of messages received on one connection,
+// using a token bucket: up to burst messages are allowed at once, and the
+// allowance refills at rate messages per minute.
+//
+// A peer that keeps sending after it's been refused a full burst of messages
+// in a row is considered abusive, and its connection should be closed.
+type MessageRateLimiter struct {
+	rate  float64
+	burst float64
+
+	mutex   sync.Mutex
+	tokens  float64
+	last    time.Time
+	refused int
+}
+
+// NewMessageRateLimiter returns a limiter allowing rate messages per minute,
+// after an initial burst
+func NewMessageRateLimiter(rate, burst int) *MessageRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &MessageRateLimiter{
+		rate:   float64(rate) / time.Minute.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow returns whether another message is allowed now, and whether the peer
+// has become abusive by sending too many refused messages
+func (rl *MessageRateLimiter) Allow() (allowed bool, abusive bool) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+
+	if rl.tokens >= 1 {
+		rl.tokens--
+		rl.refused = 0
+		return true, false
+	}
+	rl.refused++
+	return false, float64(rl.refused) >= rl.burst
+}
+
+// newInboundMessageLimiter returns a limiter for a new contact connection
+// with the rate configured on core, or nil if inbound messages are unlimited
+func (core *Ricochet) newInboundMessageLimiter() *MessageRateLimiter {
+	if core.InboundMessageRate < 0 {
+		return nil
+	}
+	return NewMessageRateLimiter(core.InboundMessageRate, core.InboundMessageBurst)
+}
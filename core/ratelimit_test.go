@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// The limiter allows a burst of messages at once, then refills at its rate,
+// and reports the peer as abusive once a whole burst in a row is refused
+func TestMessageRateLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate, burst int
+		// Time passed since the limiter was created, before sending
+		elapsed time.Duration
+		sent    int
+		// Messages allowed, and the first message after which the peer is
+		// abusive, or zero if it isn't
+		allowed int
+		abusive int
+	}{
+		{"within burst", 60, 10, 0, 10, 10, 0},
+		{"over burst", 60, 10, 0, 15, 10, 0},
+		{"burst refused", 60, 10, 0, 20, 10, 20},
+		{"refilled", 60, 10, 5 * time.Second, 20, 10, 20},
+		{"partly spent", 60, 10, 0, 5, 5, 0},
+		{"zero burst", 60, 0, 0, 3, 1, 2},
+		{"zero rate", 0, 5, time.Hour, 10, 5, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewMessageRateLimiter(test.rate, test.burst)
+			limiter.last = limiter.last.Add(-test.elapsed)
+			allowed, abusiveAt := 0, 0
+			for i := 1; i <= test.sent; i++ {
+				ok, abusive := limiter.Allow()
+				if ok {
+					allowed++
+				}
+				if abusive && abusiveAt == 0 {
+					abusiveAt = i
+				}
+			}
+			if allowed != test.allowed || abusiveAt != test.abusive {
+				t.Errorf("allowed %d and abusive after %d, expected %d and %d", allowed, abusiveAt, test.allowed, test.abusive)
+			}
+		})
+	}
+
+	// The allowance refills at the rate, and an allowed message resets the
+	// count of refused messages
+	limiter := NewMessageRateLimiter(60, 2)
+	for i := 0; i < 3; i++ {
+		limiter.Allow()
+	}
+	limiter.last = limiter.last.Add(-time.Second)
+	if ok, _ := limiter.Allow(); !ok {
+		t.Error("refused a message after the allowance refilled")
+	}
+	if _, abusive := limiter.Allow(); abusive {
+		t.Error("abusive after one refused message following an allowed one")
+	}
+}
+
+// A contact flooding chat messages has the messages over the limit refused
+// without being stored, and its connection closed, across all chat channels
+// on the connection
+func TestInboundMessageFlood(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate, burst int
+		channels    int
+		// Messages stored, and whether the connection is closed
+		stored int
+		closed bool
+	}{
+		{"limited", 60, 30, 1, 30, true},
+		{"limited across channels", 60, 30, 3, 30, true},
+		{"unlimited", -1, 30, 1, 1000, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			core.InboundMessageRate = test.rate
+			core.InboundMessageBurst = test.burst
+			core.MaxConversationMessages = -1
+			conversation := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb").Conversation()
+			conn := newTestConnection(t, "bbbbbbbbbbbbbbbb")
+			limiter := core.newInboundMessageLimiter()
+			var handlers []*inboundChatHandler
+			for i := 0; i < test.channels; i++ {
+				handlers = append(handlers, conversation.newInboundChatHandler(conn, limiter))
+			}
+
+			accepted := 0
+			for i := 0; i < 1000; i++ {
+				handler := handlers[i%len(handlers)]
+				if handler.ChatMessage(uint32(i), time.Now(), fmt.Sprintf("flood %d", i), nil) {
+					accepted++
+				}
+			}
+			if stored := len(conversation.Messages()); accepted != test.stored || stored != test.stored {
+				t.Errorf("accepted %d and stored %d messages, expected %d", accepted, stored, test.stored)
+			}
+			_, err := conn.Conn.Write([]byte{0})
+			if closed := err != nil; closed != test.closed {
+				t.Errorf("connection closed is %v, expected %v", closed, test.closed)
+			}
+		})
+	}
+}
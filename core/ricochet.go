@@ -3,31 +3,330 @@ package core
 import (
 	cryptorand "crypto/rand"
 	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+	"golang.org/x/net/context"
 	"log"
 	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"os"
+	"sync"
+	"time"
 )
 
+// ServerVersion is the backend version reported over RPC
+const ServerVersion = "0.0.0"
+
+// DefaultServicePort is the standard port for the ricochet protocol service
+const DefaultServicePort = 9878
+
+// DefaultMaxConcurrentConnects is used when Ricochet.MaxConcurrentConnects is unset
+const DefaultMaxConcurrentConnects = 6
+
 type Ricochet struct {
 	Config   *config.ConfigFile
 	Network  *Network
 	Identity *Identity
+	// History stores conversation messages persistently. If nil when Init is
+	// called, message history is kept in memory only.
+	History *config.HistoryFile
+	// KeyStore stores the identity's private key. If nil when Init is called,
+	// the store is chosen by the config's KeyStorage: the config itself by
+	// default, or an encrypted file unlocked with KeyPassphrase.
+	KeyStore      KeyStore
+	KeyPassphrase string
+
+	// ServicePort is the onion service port used for our own service and for
+	// connections to contacts. If zero when Init is called, it is taken from
+	// the config or defaults to DefaultServicePort.
+	ServicePort int
+
+	// KeepaliveInterval is how often keepalives are sent on contact connections.
+	// Connections are closed if nothing is received for two intervals. If zero
+	// when Init is called, DefaultKeepaliveInterval is used; a negative value
+	// disables keepalives.
+	KeepaliveInterval time.Duration
+	// PresenceGrace is how long a contact may be silent beyond a keepalive
+	// interval before it's shown offline, even if its connection hasn't closed yet.
+	// Larger values avoid showing contacts on slow circuits as offline, but
+	// leave contacts on broken circuits shown as online for longer. If zero
+	// when Init is called, DefaultPresenceGrace is used.
+	PresenceGrace time.Duration
+
+	// MaxQueuedMessageAge is how long messages to an offline contact are queued
+	// before they fail. MaxQueuedMessages limits the number of queued messages to
+	// each contact; messages sent beyond this fail immediately. If zero when Init
+	// is called, DefaultMaxQueuedMessageAge and DefaultMaxQueuedMessages are used;
+	// negative values disable the limits.
+	MaxQueuedMessageAge time.Duration
+	MaxQueuedMessages   int
+
+	// InboundMessageRate is how many chat messages per minute are accepted from
+	// a contact on each connection, after a burst of up to InboundMessageBurst.
+	// Messages beyond the rate are refused, and the connection is closed if the
+	// contact sends a whole burst of refused messages in a row. If zero when
+	// Init is called, DefaultInboundMessageRate and DefaultInboundMessageBurst
+	// are used; a negative rate disables the limit.
+	InboundMessageRate  int
+	InboundMessageBurst int
+
+	// MaxConversationMessages is the most messages kept in each conversation,
+	// in memory and in the history. Beyond this, the oldest messages are
+	// discarded, even if unread, so a contact can't exhaust memory by flooding
+	// us with messages. If zero when Init is called,
+	// DefaultMaxConversationMessages is used; negative values disable the limit.
+	MaxConversationMessages int
+
+	// AckTimeout is how long a sent message waits for the contact to ack it,
+	// after which it fails. If zero when Init is called, DefaultAckTimeout is
+	// used; a negative value waits forever. If ResendUnackedMessages is set,
+	// messages that failed this way are sent again on the next connection.
+	AckTimeout            time.Duration
+	ResendUnackedMessages bool
+
+	// MaxConcurrentConnects limits the number of outbound connection attempts to
+	// contacts in progress at once; others wait for their turn. If zero when Init
+	// is called, DefaultMaxConcurrentConnects is used; a negative value disables
+	// the limit.
+	MaxConcurrentConnects int
+	connectLimiter        *ConnectLimiter
+
+	// ConnectAttemptTimeout is the maximum time for a single outbound connection
+	// attempt, after which it's abandoned and retried with backoff. If zero,
+	// DefaultConnectAttemptTimeout is used.
+	ConnectAttemptTimeout time.Duration
+
+	// ConnectBackoff is the schedule of delays between failed connection
+	// attempts to contacts. Unset fields are taken from DefaultBackoffSchedule
+	// when Init is called.
+	ConnectBackoff BackoffSchedule
+
+	// ReconnectJitter spreads out connection attempts to contacts when the
+	// network becomes available, over a random time up to this. If zero when
+	// Init is called, DefaultReconnectJitter is used; a negative value disables
+	// the jitter.
+	ReconnectJitter time.Duration
+
+	// SelfCheckInterval is how often we connect to our own onion service through
+	// tor, to detect when contacts can't reach us. If zero when Init is called,
+	// DefaultSelfCheckInterval is used; a negative value disables the check, which
+	// avoids the extra tor traffic.
+	SelfCheckInterval time.Duration
+
+	// IsolateContactCircuits makes tor use separate circuits for connections to
+	// each contact. Otherwise, connections to different contacts can share a
+	// circuit, and the relays on it could tell that the same user is talking
+	// to each of those contacts. Isolation builds more circuits, which takes
+	// longer and adds load on tor. It has no effect if SOCKS credentials are
+	// configured with Network.SetSocksAuth.
+	IsolateContactCircuits bool
+
+	// Acceptance is the policy for nicknames and messages from contacts and
+	// clients. Unset limits are taken from DefaultAcceptancePolicy when Init is
+	// called, and the policy applies to the whole process.
+	Acceptance AcceptancePolicy
+
+	// Log receives messages about connections to contacts, filtered by its
+	// level, which can be changed while running. If nil when Init is called, a
+	// logger at LogInfo is used.
+	Log *utils.Logger
+
+	// AuditLogPath is a file that significant events for contacts and
+	// connections are appended to. If empty, the audit log is only kept in
+	// memory. Audit is created by Init.
+	AuditLogPath string
+	Audit        *AuditLog
+
+	// Tor is an optional tor process to launch and use for the network, instead
+	// of connecting to an existing tor. If set, it is started by Init, and
+	// stopped by Shutdown.
+	Tor *TorProcess
+
+	// Notify is an optional command to run when a message is received. If
+	// set, it's started by Init; see MessageNotifier for the security
+	// implications.
+	Notify *MessageNotifier
+
+	// Time Init was called, for uptime
+	started time.Time
+
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
 }
 
 func (core *Ricochet) Init(conf *config.ConfigFile) (err error) {
 	initRand()
 
+	core.started = time.Now()
 	core.Config = conf
+	if core.Log == nil {
+		core.Log = utils.NewLogger(utils.LogInfo)
+	}
+	if core.ServicePort == 0 {
+		core.ServicePort = int(conf.Read().ServicePort)
+	}
+	if core.ServicePort == 0 {
+		core.ServicePort = DefaultServicePort
+	}
+	if core.KeepaliveInterval == 0 {
+		core.KeepaliveInterval = DefaultKeepaliveInterval
+	}
+	if core.PresenceGrace <= 0 {
+		core.PresenceGrace = DefaultPresenceGrace
+	}
+	if core.MaxConcurrentConnects == 0 {
+		core.MaxConcurrentConnects = DefaultMaxConcurrentConnects
+	}
+	if core.MaxConcurrentConnects > 0 {
+		core.connectLimiter = NewConnectLimiter(core.MaxConcurrentConnects)
+	}
+	if core.SelfCheckInterval == 0 {
+		core.SelfCheckInterval = DefaultSelfCheckInterval
+	}
+	if core.ConnectBackoff.Initial == 0 {
+		core.ConnectBackoff.Initial = DefaultBackoffSchedule.Initial
+	}
+	if core.ConnectBackoff.Max == 0 {
+		core.ConnectBackoff.Max = DefaultBackoffSchedule.Max
+		if core.ConnectBackoff.Max < core.ConnectBackoff.Initial {
+			core.ConnectBackoff.Max = core.ConnectBackoff.Initial
+		}
+	}
+	if core.ConnectBackoff.Multiplier == 0 {
+		core.ConnectBackoff.Multiplier = DefaultBackoffSchedule.Multiplier
+	}
+	if core.ConnectBackoff.Jitter == 0 {
+		core.ConnectBackoff.Jitter = DefaultBackoffSchedule.Jitter
+	}
+	if err = core.ConnectBackoff.Validate(); err != nil {
+		return
+	}
+	if core.ReconnectJitter == 0 {
+		core.ReconnectJitter = DefaultReconnectJitter
+	}
+	if core.MaxQueuedMessageAge == 0 {
+		core.MaxQueuedMessageAge = DefaultMaxQueuedMessageAge
+	}
+	if core.MaxQueuedMessages == 0 {
+		core.MaxQueuedMessages = DefaultMaxQueuedMessages
+	}
+	if core.InboundMessageRate == 0 {
+		core.InboundMessageRate = DefaultInboundMessageRate
+	}
+	if core.InboundMessageBurst == 0 {
+		core.InboundMessageBurst = DefaultInboundMessageBurst
+	}
+	if core.MaxConversationMessages == 0 {
+		core.MaxConversationMessages = DefaultMaxConversationMessages
+	}
+	if core.AckTimeout == 0 {
+		core.AckTimeout = DefaultAckTimeout
+	}
+
+	if core.Audit, err = OpenAuditLog(core.AuditLogPath); err != nil {
+		return
+	}
+
+	core.Acceptance = core.Acceptance.withDefaults()
+	if err = SetAcceptancePolicy(core.Acceptance); err != nil {
+		return
+	}
+
+	if core.Notify != nil {
+		if err = core.Notify.start(core.Log); err != nil {
+			return
+		}
+	}
+
+	if core.KeyStore == nil {
+		if core.KeyStore, err = keyStoreFromConfig(conf, core.KeyPassphrase); err != nil {
+			return
+		}
+	}
 
 	core.Network = CreateNetwork()
 	core.setupNetwork()
+	core.Network.SetProxyChangedCallback(core.proxyChanged)
+	if core.Tor != nil {
+		var controlAddress string
+		if controlAddress, err = core.Tor.Start(); err != nil {
+			return
+		}
+		core.Network.SetControlAddress(controlAddress)
+	}
 	core.Identity, err = CreateIdentity(core)
 	return
 }
 
+// Uptime returns the time since Init was called
+func (core *Ricochet) Uptime() time.Duration {
+	return time.Since(core.started)
+}
+
+// proxyChanged restarts outbound connection attempts, so they're made through
+// the new proxy settings instead of finishing their backoff with the old ones
+func (core *Ricochet) proxyChanged() {
+	if core.Identity != nil {
+		core.Identity.ContactList().RestartOutboundConnections()
+	}
+}
+
+// Shutdown closes all contact connections, takes the network offline, which
+// removes our onion service, stops the tor process if it was launched by Init,
+// and saves the config and message history. It returns once everything has
+// stopped, or with the error from ctx if that happens first, in which case
+// shutdown continues in the background. Only the first call has any effect;
+// later calls wait for it.
+//
+// The RPC server isn't owned by Ricochet, and should be stopped before calling
+// Shutdown so that clients don't make changes meanwhile.
+func (core *Ricochet) Shutdown(ctx context.Context) error {
+	core.shutdownOnce.Do(func() {
+		core.shutdownDone = make(chan struct{})
+		go func() {
+			core.shutdown()
+			close(core.shutdownDone)
+		}()
+	})
+
+	select {
+	case <-core.shutdownDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (core *Ricochet) shutdown() {
+	log.Printf("Shutting down")
+	if core.Identity != nil {
+		// Stop contacts first, so they don't try to reconnect as the network stops
+		core.Identity.ContactList().shutdown()
+	}
+	if core.Network != nil {
+		core.Network.Stop()
+	}
+	if core.Tor != nil {
+		core.Tor.Stop()
+	}
+	if core.Audit != nil {
+		core.Audit.Close()
+	}
+	if core.Config != nil {
+		// Writes any deferred changes, like the time contacts were last connected
+		if err := core.Config.Save(); err != nil {
+			log.Printf("Saving config on shutdown failed: %v", err)
+		}
+	}
+	if core.History != nil {
+		if err := core.History.Save(); err != nil {
+			log.Printf("Saving message history on shutdown failed: %v", err)
+		}
+	}
+	log.Printf("Shutdown complete")
+}
+
 func initRand() {
 	n, err := cryptorand.Int(cryptorand.Reader, big.NewInt(math.MaxInt64))
 	if err != nil {
@@ -57,4 +356,28 @@ func (core *Ricochet) setupNetwork() {
 	if passwd != "" {
 		core.Network.SetControlPassword(passwd)
 	}
+
+	if socksUser := os.Getenv("TOR_SOCKS_USER"); socksUser != "" {
+		if err := core.Network.SetSocksAuth(socksUser, os.Getenv("TOR_SOCKS_PASSWD")); err != nil {
+			log.Printf("Ignoring invalid SOCKS credentials: %v", err)
+		}
+	}
+
+	socksSocket := os.Getenv("TOR_SOCKS_SOCKET")
+	socksHost := os.Getenv("TOR_SOCKS_HOST")
+	socksPort := os.Getenv("TOR_SOCKS_PORT")
+
+	if socksSocket != "" {
+		core.Network.SetSocksAddress("unix:" + socksSocket)
+	} else if socksHost != "" || socksPort != "" {
+		if socksHost == "" {
+			socksHost = "127.0.0.1"
+		}
+		if socksPort == "" {
+			socksPort = "9050"
+		}
+		if err := core.Network.SetSocksAddress(net.JoinHostPort(socksHost, socksPort)); err != nil {
+			log.Printf("Ignoring invalid SOCKS address: %v", err)
+		}
+	}
 }
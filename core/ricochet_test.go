@@ -0,0 +1,282 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/core/utils"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	"github.com/s-rah/go-ricochet/connection"
+	"golang.org/x/net/context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSelfAddress = "ricochet:aaaaaaaaaaaaaaaa"
+
+// newTestCore returns a core with an identity and an empty contact list, but
+// no network, keys or connections
+func newTestCore(t *testing.T) *Ricochet {
+	conf, err := config.NewConfigFile(filepath.Join(t.TempDir(), "ricochet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	core := &Ricochet{
+		Config: conf,
+		Log:    utils.NewLogger(utils.LogError),
+		ConnectBackoff: BackoffSchedule{
+			Initial:    10 * time.Millisecond,
+			Max:        100 * time.Millisecond,
+			Multiplier: 2,
+		},
+		MaxQueuedMessageAge:     DefaultMaxQueuedMessageAge,
+		MaxQueuedMessages:       DefaultMaxQueuedMessages,
+		InboundMessageRate:      DefaultInboundMessageRate,
+		InboundMessageBurst:     DefaultInboundMessageBurst,
+		MaxConversationMessages: DefaultMaxConversationMessages,
+		AckTimeout:              DefaultAckTimeout,
+		Acceptance:              DefaultAcceptancePolicy,
+	}
+	if core.Audit, err = OpenAuditLog(""); err != nil {
+		t.Fatal(err)
+	}
+	core.Identity = &Identity{
+		core:               core,
+		address:            testSelfAddress,
+		transfers:          NewFileTransferList(core),
+		ConversationStream: utils.CreatePublisher(),
+	}
+	if core.Identity.contactList, err = LoadContactList(core); err != nil {
+		t.Fatal(err)
+	}
+	return core
+}
+
+// newTestContact adds a contact to the config and contact list of core without
+// starting its connections
+func newTestContact(t *testing.T, core *Ricochet, address string) *Contact {
+	data := &ricochet.Contact{Address: address}
+	config := core.Config.Lock()
+	if config.Contacts == nil {
+		config.Contacts = make(map[string]*ricochet.Contact)
+	}
+	config.Contacts[address] = data
+	core.Config.Unlock()
+
+	list := core.Identity.ContactList()
+	contact, err := ContactFromConfig(core, data, list.events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list.mutex.Lock()
+	list.contacts[address] = contact
+	list.mutex.Unlock()
+	return contact
+}
+
+type testConnectionHandler struct{}
+
+func (h *testConnectionHandler) OnReady(oc *connection.Connection) {}
+func (h *testConnectionHandler) OnClosed(err error)                {}
+func (h *testConnectionHandler) OnOpenChannelRequest(ctype string) (channels.Handler, error) {
+	return nil, nil
+}
+
+// newTestConnection returns an authenticated outbound connection to remote.
+// Anything sent on it is discarded, and it's closed at the end of the test.
+// Process isn't running until the caller starts it, so calls to Do block.
+func newTestConnection(t *testing.T, remote string) *connection.Connection {
+	local, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+	go io.Copy(ioutil.Discard, peer)
+	conn := connection.NewOutboundConnection(local, remote)
+	conn.Authentication["im.ricochet.auth.hidden-service"] = true
+	return conn
+}
+
+// testNetwork connects test peers to each other in memory, in place of tor
+type testNetwork struct {
+	mutex sync.Mutex
+	peers map[string]*Ricochet
+}
+
+func newTestNetwork() *testNetwork {
+	return &testNetwork{peers: make(map[string]*Ricochet)}
+}
+
+func (tn *testNetwork) Resolve(address string) (net.Conn, string, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, "", err
+	}
+	tn.mutex.Lock()
+	peer := tn.peers[host]
+	tn.mutex.Unlock()
+	if peer == nil {
+		return nil, "", errors.New("Unknown test peer")
+	}
+	conn, peerConn, err := loopbackPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	go peer.Identity.handleInboundConnection(peerConn)
+	return conn, host, nil
+}
+
+// loopbackPipe returns both ends of a loopback TCP connection. Unlike net.Pipe,
+// writes are buffered, so both peers can send from their Process routines at
+// the same time, as they would over tor.
+func loopbackPipe() (net.Conn, net.Conn, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	peerConn, err := listener.Accept()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, peerConn, nil
+}
+
+// newTestPeer returns a test core with its own v2 key, which connects to other
+// peers on network. Its contacts' connections are stopped at the end of the test.
+func newTestPeer(t *testing.T, network *testNetwork) *Ricochet {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestPeerWithKey(t, network, key)
+}
+
+// newTestPeerWithKey is newTestPeer for an onion service with key
+func newTestPeerWithKey(t *testing.T, network *testNetwork, key crypto.PrivateKey) *Ricochet {
+	core := newTestCore(t)
+	var err error
+	core.Identity.privateKey = key
+	if core.Identity.address, err = AddressFromPrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	core.Network = CreateNetwork()
+	core.Network.SetResolver(network)
+
+	onion, _ := OnionFromAddress(core.Identity.address)
+	network.mutex.Lock()
+	network.peers[onion] = core
+	network.mutex.Unlock()
+	t.Cleanup(core.Identity.contactList.shutdown)
+	return core
+}
+
+// Shutdown closes contact connections, stops background work and saves
+// deferred config changes, leaving no goroutines behind. Calls after the first
+// wait for it to finish.
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// Add a contact, and whether it can be reached
+		contact   bool
+		reachable bool
+		// The first call to Shutdown gives up waiting immediately
+		cancelled bool
+	}{
+		{"no contacts", false, false, false},
+		{"offline contact", true, false, false},
+		{"online contact", true, true, false},
+		{"cancelled", true, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			local, peer := newTestPeer(t, network), newTestPeer(t, network)
+			configPath := filepath.Join(t.TempDir(), "shutdown.json")
+			var err error
+			if local.Config, err = config.NewConfigFile(configPath); err != nil {
+				t.Fatal(err)
+			}
+			if !test.reachable {
+				onion, _ := OnionFromAddress(peer.Identity.Address())
+				network.mutex.Lock()
+				delete(network.peers, onion)
+				network.mutex.Unlock()
+			}
+			goroutines := runtime.NumGoroutine()
+			go local.Identity.contactList.expireQueuedMessages()
+
+			var peerContact *Contact
+			if test.contact {
+				contact := newTestContact(t, local, peer.Identity.Address())
+				peerContact = newTestContact(t, peer, local.Identity.Address())
+				go contact.StartConnection()
+				if test.reachable {
+					go peerContact.StartConnection()
+					deadline := time.Now().Add(10 * time.Second)
+					for contact.Status() != ricochet.Contact_ONLINE {
+						if time.Now().After(deadline) {
+							t.Fatalf("contact is %v, expected online", contact.Status())
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+				} else {
+					time.Sleep(50 * time.Millisecond)
+				}
+			}
+			local.Config.Lock().ServicePort = 9879
+			local.Config.UnlockDeferred()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.cancelled {
+				cancel()
+			}
+			defer cancel()
+			if err := local.Shutdown(ctx); err != nil && !test.cancelled {
+				t.Fatal(err)
+			}
+			if err := local.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, err := config.LoadConfigFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Read().ServicePort != 9879 {
+				t.Error("deferred config change wasn't saved")
+			}
+			if test.contact {
+				if _, exists := saved.Read().Contacts[peer.Identity.Address()]; !exists {
+					t.Error("contact isn't in the saved config")
+				}
+			}
+
+			// The peer's side is stopped too, so that it stops reconnecting
+			if peerContact != nil {
+				peer.Identity.contactList.shutdown()
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > goroutines {
+				if time.Now().After(deadline) {
+					buf := make([]byte, 1<<16)
+					t.Fatalf("%d goroutines are running, expected %d:\n%s", runtime.NumGoroutine(), goroutines, buf[:runtime.Stack(buf, true)])
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
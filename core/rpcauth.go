@@ -0,0 +1,72 @@
+package core
+
+import (
+	"crypto/subtle"
+	"errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// RpcTokenMetadataKey is the request metadata that carries the RPC token
+const RpcTokenMetadataKey = "ricochet-rpc-token"
+
+var RpcUnauthenticatedError error = errors.New("RPC token is missing or wrong")
+
+// RpcTokenAuth requires every RPC call to carry a shared token, so that only
+// frontends knowing the token can use the backend. The token is sent with each
+// request, so it should only be used with TLS or over a local socket.
+type RpcTokenAuth struct {
+	Token string
+}
+
+// ServerOptions returns the options for a grpc.Server to reject calls without
+// the token
+func (a *RpcTokenAuth) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(a.unaryInterceptor),
+		grpc.StreamInterceptor(a.streamInterceptor),
+	}
+}
+
+func (a *RpcTokenAuth) check(ctx context.Context) error {
+	md, ok := metadata.FromContext(ctx)
+	if !ok || len(md[RpcTokenMetadataKey]) != 1 {
+		return grpc.Errorf(codes.Unauthenticated, "%s", RpcUnauthenticatedError)
+	}
+	if subtle.ConstantTimeCompare([]byte(md[RpcTokenMetadataKey][0]), []byte(a.Token)) != 1 {
+		return grpc.Errorf(codes.Unauthenticated, "%s", RpcUnauthenticatedError)
+	}
+	return nil
+}
+
+func (a *RpcTokenAuth) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *RpcTokenAuth) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// RpcTokenCredentials sends the RPC token with each call from a client, and
+// can be used with grpc.WithPerRPCCredentials
+type RpcTokenCredentials struct {
+	Token string
+}
+
+func (c RpcTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{RpcTokenMetadataKey: c.Token}, nil
+}
+
+// RequireTransportSecurity is false to allow the token over local sockets;
+// callers must use TLS for other connections.
+func (c RpcTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -2,8 +2,12 @@ package core
 
 import (
 	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/core/utils"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"log"
 )
 
@@ -20,7 +24,59 @@ func (s *RpcServer) GetServerStatus(ctx context.Context, req *ricochet.ServerSta
 
 	return &ricochet.ServerStatusReply{
 		RpcVersion:    1,
-		ServerVersion: "0.0.0",
+		ServerVersion: ServerVersion,
+		Limits: &ricochet.AcceptanceLimits{
+			MaxNicknameLength:     int32(s.Core.Acceptance.MaxNicknameLength),
+			MaxMessageLength:      int32(s.Core.Acceptance.MaxMessageLength),
+			MaxRejectReasonLength: int32(s.Core.Acceptance.MaxRejectReasonLength),
+		},
+	}, nil
+}
+
+func (s *RpcServer) GetHealth(ctx context.Context, req *ricochet.HealthRequest) (*ricochet.HealthReply, error) {
+	reply := &ricochet.HealthReply{
+		ServerVersion: ServerVersion,
+		UptimeSeconds: int64(s.Core.Uptime().Seconds()),
+	}
+
+	status := s.Core.Network.GetStatus()
+	reply.TorReachable = status.Control.GetStatus() == ricochet.TorControlStatus_CONNECTED &&
+		status.Connection.GetStatus() == ricochet.TorConnectionStatus_READY &&
+		status.Socks.GetStatus() != ricochet.TorSocksStatus_UNAVAILABLE
+
+	if identity := s.Core.Identity; identity != nil {
+		reply.IdentityLoaded = identity.Address() != ""
+		reply.OnionPublished = identity.ServicePublished()
+		for _, contact := range identity.ContactList().Contacts() {
+			reply.TotalContacts++
+			if contact.Status() == ricochet.Contact_ONLINE {
+				reply.ConnectedContacts++
+			}
+		}
+	}
+
+	reply.Ready = reply.IdentityLoaded && reply.TorReachable && reply.OnionPublished
+	return reply, nil
+}
+
+func (s *RpcServer) GetLogLevel(ctx context.Context, req *ricochet.LogLevelRequest) (*ricochet.LogLevel, error) {
+	return &ricochet.LogLevel{
+		Level: ricochet.LogLevel_Level(s.Core.Log.Level()),
+	}, nil
+}
+
+func (s *RpcServer) SetLogLevel(ctx context.Context, req *ricochet.LogLevel) (*ricochet.LogLevel, error) {
+	if _, ok := ricochet.LogLevel_Level_name[int32(req.Level)]; !ok {
+		return nil, errors.New("Unknown log level")
+	}
+	s.Core.Log.SetLevel(utils.LogLevel(req.Level))
+	log.Printf("Log level changed to %s", utils.LogLevel(req.Level))
+	return s.GetLogLevel(ctx, &ricochet.LogLevelRequest{})
+}
+
+func (s *RpcServer) GetAuditLog(ctx context.Context, req *ricochet.AuditLogRequest) (*ricochet.AuditLogReply, error) {
+	return &ricochet.AuditLogReply{
+		Entries: s.Core.Audit.Recent(int(req.Limit)),
 	}, nil
 }
 
@@ -39,7 +95,8 @@ func (s *RpcServer) MonitorNetwork(req *ricochet.MonitorNetworkRequest, stream r
 	for {
 		event, ok := (<-events).(ricochet.NetworkStatus)
 		if !ok {
-			break
+			// The publisher drops subscribers that fall too far behind
+			return errors.New("Network monitor fell behind")
 		}
 
 		log.Printf("RPC monitor event: %v", event)
@@ -47,8 +104,6 @@ func (s *RpcServer) MonitorNetwork(req *ricochet.MonitorNetworkRequest, stream r
 			return err
 		}
 	}
-
-	return nil
 }
 
 func (s *RpcServer) StartNetwork(ctx context.Context, req *ricochet.StartNetworkRequest) (*ricochet.NetworkStatus, error) {
@@ -76,28 +131,101 @@ func (s *RpcServer) GetIdentity(ctx context.Context, req *ricochet.IdentityReque
 	return &reply, nil
 }
 
+func (s *RpcServer) ExportIdentity(ctx context.Context, req *ricochet.ExportIdentityRequest) (*ricochet.ExportIdentityReply, error) {
+	data, err := s.Core.Identity.ExportIdentity(req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &ricochet.ExportIdentityReply{Data: data}, nil
+}
+
+func (s *RpcServer) ImportIdentity(ctx context.Context, req *ricochet.ImportIdentityRequest) (*ricochet.ImportIdentityReply, error) {
+	address, err := ImportIdentity(s.Core.KeyStore, req.Data, req.Passphrase, req.Force)
+	if err != nil {
+		return nil, err
+	}
+	return &ricochet.ImportIdentityReply{Address: address}, nil
+}
+
+func (s *RpcServer) GetContactRequestPolicy(ctx context.Context, req *ricochet.ContactRequestPolicyRequest) (*ricochet.ContactRequestPolicy, error) {
+	policy := s.Core.Identity.ContactRequestPolicy()
+	return &policy, nil
+}
+
+func (s *RpcServer) SetContactRequestPolicy(ctx context.Context, req *ricochet.ContactRequestPolicy) (*ricochet.ContactRequestPolicy, error) {
+	if err := s.Core.Identity.SetContactRequestPolicy(*req); err != nil {
+		return nil, err
+	}
+	policy := s.Core.Identity.ContactRequestPolicy()
+	return &policy, nil
+}
+
+func (s *RpcServer) GetConnectionMode(ctx context.Context, req *ricochet.ConnectionModeRequest) (*ricochet.ConnectionMode, error) {
+	return &ricochet.ConnectionMode{Mode: s.Core.Identity.ConnectionMode()}, nil
+}
+
+func (s *RpcServer) SetConnectionMode(ctx context.Context, req *ricochet.ConnectionMode) (*ricochet.ConnectionMode, error) {
+	if err := s.Core.Identity.SetConnectionMode(req.Mode); err != nil {
+		return nil, err
+	}
+	return &ricochet.ConnectionMode{Mode: s.Core.Identity.ConnectionMode()}, nil
+}
+
+func (s *RpcServer) GetPresence(ctx context.Context, req *ricochet.PresenceRequest) (*ricochet.Presence, error) {
+	presence := s.Core.Identity.Presence()
+	return &presence, nil
+}
+
+func (s *RpcServer) SetPresence(ctx context.Context, req *ricochet.Presence) (*ricochet.Presence, error) {
+	if err := s.Core.Identity.SetPresence(*req); err != nil {
+		return nil, err
+	}
+	presence := s.Core.Identity.Presence()
+	return &presence, nil
+}
+
+// MonitorContacts sends all contacts and inbound requests as POPULATE events,
+// followed by any changes. Each call has its own subscription to events, so any
+// number of clients can monitor contacts at once.
+//
+// Events are published outside of the locks that population takes, so an event
+// received from the monitor may describe a change that population already
+// included, or an older state than the client has seen. Rather than forward
+// events as they were published, each one is reconciled with the current state
+// of its contact or request, and only sent if that differs from what this client
+// was last sent. Every change publishes an event after it is made, so the client
+// always ends up with the current state, and never sees a duplicate add or an
+// update for something it doesn't know about.
 func (s *RpcServer) MonitorContacts(req *ricochet.MonitorContactsRequest, stream ricochet.RicochetCore_MonitorContactsServer) error {
-	monitor := s.Core.Identity.ContactList().EventMonitor().Subscribe(20)
-	defer s.Core.Identity.ContactList().EventMonitor().Unsubscribe(monitor)
-
-	// Populate
-	contacts := s.Core.Identity.ContactList().Contacts()
-	for _, contact := range contacts {
-		event := &ricochet.ContactEvent{
-			Type: ricochet.ContactEvent_POPULATE,
-			Subject: &ricochet.ContactEvent_Contact{
-				Contact: contact.Data(),
-			},
-		}
-		if err := stream.Send(event); err != nil {
-			return err
-		}
+	contactList := s.Core.Identity.ContactList()
+	monitor := contactList.EventMonitor().Subscribe(20)
+	defer contactList.EventMonitor().Unsubscribe(monitor)
+
+	// Take the whole snapshot before sending any of it
+	state := newContactMonitorState(contactList)
+	var populate []*ricochet.ContactEvent
+	for _, contact := range contactList.Contacts() {
+		data := contact.Data()
+		state.contacts[data.Address] = data
+		populate = append(populate, &ricochet.ContactEvent{
+			Type:    ricochet.ContactEvent_POPULATE,
+			Subject: &ricochet.ContactEvent_Contact{Contact: data},
+		})
+	}
+	for _, request := range contactList.InboundRequests() {
+		data := request.Data()
+		state.requests[data.Address] = &data
+		populate = append(populate, &ricochet.ContactEvent{
+			Type:    ricochet.ContactEvent_POPULATE,
+			Subject: &ricochet.ContactEvent_Request{Request: &data},
+		})
 	}
 	// Terminate populate list with a null subject
-	{
-		event := &ricochet.ContactEvent{
-			Type: ricochet.ContactEvent_POPULATE,
-		}
+	populate = append(populate, &ricochet.ContactEvent{
+		Type: ricochet.ContactEvent_POPULATE,
+	})
+
+	for _, event := range populate {
 		if err := stream.Send(event); err != nil {
 			return err
 		}
@@ -106,16 +234,103 @@ func (s *RpcServer) MonitorContacts(req *ricochet.MonitorContactsRequest, stream
 	for {
 		event, ok := (<-monitor).(ricochet.ContactEvent)
 		if !ok {
-			break
+			// The publisher drops subscribers that fall too far behind
+			return errors.New("Contact monitor fell behind")
 		}
 
 		log.Printf("Contact event: %v", event)
-		if err := stream.Send(&event); err != nil {
+		reconciled := state.reconcile(&event)
+		if reconciled == nil {
+			continue
+		}
+		if err := stream.Send(reconciled); err != nil {
 			return err
 		}
 	}
+}
+
+// contactMonitorState is the contact and request data that one MonitorContacts
+// client was last sent, by address.
+type contactMonitorState struct {
+	contactList *ContactList
+	contacts    map[string]*ricochet.Contact
+	requests    map[string]*ricochet.ContactRequest
+}
+
+func newContactMonitorState(contactList *ContactList) *contactMonitorState {
+	return &contactMonitorState{
+		contactList: contactList,
+		contacts:    make(map[string]*ricochet.Contact),
+		requests:    make(map[string]*ricochet.ContactRequest),
+	}
+}
+
+// reconcile returns the event that brings the client up to date with the
+// current state of the subject of event, or nil if it already is.
+func (state *contactMonitorState) reconcile(event *ricochet.ContactEvent) *ricochet.ContactEvent {
+	if data := event.GetContact(); data != nil {
+		address := data.Address
+		sent, known := state.contacts[address]
+		contact := state.contactList.ContactByAddress(address)
+		if contact == nil {
+			if !known {
+				return nil
+			}
+			delete(state.contacts, address)
+			return &ricochet.ContactEvent{
+				Type:    ricochet.ContactEvent_DELETE,
+				Subject: &ricochet.ContactEvent_Contact{Contact: sent},
+			}
+		}
+
+		current := contact.Data()
+		if !known {
+			state.contacts[address] = current
+			return &ricochet.ContactEvent{
+				Type:    ricochet.ContactEvent_ADD,
+				Subject: &ricochet.ContactEvent_Contact{Contact: current},
+			}
+		} else if proto.Equal(sent, current) {
+			return nil
+		}
+		state.contacts[address] = current
+		// Changes are described relative to what this client has seen, which
+		// can span more than one published event
+		return &ricochet.ContactEvent{
+			Type:    ricochet.ContactEvent_UPDATE,
+			Subject: &ricochet.ContactEvent_Contact{Contact: current},
+			Change:  contactDataChange(sent, current),
+		}
+	} else if data := event.GetRequest(); data != nil {
+		address := data.Address
+		sent, known := state.requests[address]
+		request := state.contactList.InboundRequestByAddress(address)
+		if request == nil {
+			if !known {
+				return nil
+			}
+			delete(state.requests, address)
+			return &ricochet.ContactEvent{
+				Type:    ricochet.ContactEvent_DELETE,
+				Subject: &ricochet.ContactEvent_Request{Request: sent},
+			}
+		}
 
-	return nil
+		current := request.Data()
+		if known && proto.Equal(sent, &current) {
+			return nil
+		}
+		state.requests[address] = &current
+		eventType := ricochet.ContactEvent_ADD
+		if known {
+			eventType = ricochet.ContactEvent_UPDATE
+		}
+		return &ricochet.ContactEvent{
+			Type:    eventType,
+			Subject: &ricochet.ContactEvent_Request{Request: &current},
+		}
+	}
+	return event
 }
 
 func (s *RpcServer) AddContactRequest(ctx context.Context, req *ricochet.ContactRequest) (*ricochet.Contact, error) {
@@ -125,7 +340,9 @@ func (s *RpcServer) AddContactRequest(ctx context.Context, req *ricochet.Contact
 	}
 
 	contact, err := contactList.AddContactRequest(req.Address, req.Nickname, req.FromNickname, req.Text)
-	if err != nil {
+	if err == InvalidAddressError {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -136,6 +353,18 @@ func (s *RpcServer) UpdateContact(ctx context.Context, req *ricochet.Contact) (*
 	return nil, NotImplementedError
 }
 
+func (s *RpcServer) GetContact(ctx context.Context, req *ricochet.GetContactRequest) (*ricochet.Contact, error) {
+	address, err := NormalizeAddress(req.Address)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	contact := s.Core.Identity.ContactList().ContactByAddress(address)
+	if contact == nil {
+		return nil, grpc.Errorf(codes.NotFound, "Contact not found")
+	}
+	return contact.Data(), nil
+}
+
 func (s *RpcServer) DeleteContact(ctx context.Context, req *ricochet.DeleteContactRequest) (*ricochet.DeleteContactReply, error) {
 	contactList := s.Core.Identity.ContactList()
 	contact := contactList.ContactByAddress(req.Address)
@@ -180,24 +409,170 @@ func (s *RpcServer) RejectInboundRequest(ctx context.Context, req *ricochet.Cont
 	if request == nil {
 		return nil, errors.New("Request does not exist")
 	}
-	request.Reject()
+	if err := request.RejectWithReason(req.RejectReason); err != nil {
+		return nil, err
+	}
 	return &ricochet.RejectInboundRequestReply{}, nil
 }
 
+func (s *RpcServer) CancelOutboundRequest(ctx context.Context, req *ricochet.ContactRequest) (*ricochet.CancelOutboundRequestReply, error) {
+	if req.Direction != ricochet.ContactRequest_OUTBOUND {
+		return nil, errors.New("Request must be outbound")
+	}
+	contactList := s.Core.Identity.ContactList()
+	contact := contactList.ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Request does not exist")
+	}
+	if err := contactList.CancelContactRequest(contact); err != nil {
+		return nil, err
+	}
+	return &ricochet.CancelOutboundRequestReply{}, nil
+}
+
+func (s *RpcServer) EditOutboundRequest(ctx context.Context, req *ricochet.ContactRequest) (*ricochet.Contact, error) {
+	if req.Direction != ricochet.ContactRequest_OUTBOUND {
+		return nil, errors.New("Request must be outbound")
+	}
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, grpc.Errorf(codes.NotFound, "Request does not exist")
+	}
+	switch err := contact.EditRequest(req.FromNickname, req.Text); err {
+	case nil:
+	case NoOutboundRequestError, RequestDeliveredError:
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", err)
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) SetContactBlocked(ctx context.Context, req *ricochet.SetContactBlockedRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	contact.SetBlocked(req.Blocked)
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) SetContactConnectOnStartup(ctx context.Context, req *ricochet.SetContactConnectOnStartupRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	contact.SetConnectOnStartup(req.ConnectOnStartup)
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) SetContactMuted(ctx context.Context, req *ricochet.SetContactMutedRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	contact.SetMuted(req.Muted)
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) ConnectContact(ctx context.Context, req *ricochet.ConnectContactRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	contact.Connect()
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) SetContactNickname(ctx context.Context, req *ricochet.SetContactNicknameRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	if err := contact.SetNickname(req.Nickname); err != nil {
+		return nil, err
+	}
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) SetContactOrder(ctx context.Context, req *ricochet.SetContactOrderRequest) (*ricochet.Contact, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	}
+
+	contact.SetOrder(req.Pinned, req.SortIndex)
+	return contact.Data(), nil
+}
+
+func (s *RpcServer) GetContactDiagnostics(ctx context.Context, req *ricochet.ContactDiagnosticsRequest) (*ricochet.ContactDiagnosticsReply, error) {
+	contactList := s.Core.Identity.ContactList()
+	reply := &ricochet.ContactDiagnosticsReply{}
+	if req.Address != "" {
+		contact := contactList.ContactByAddress(req.Address)
+		if contact == nil {
+			return nil, errors.New("Contact not found")
+		}
+		reply.Contacts = append(reply.Contacts, contact.Diagnostics())
+		return reply, nil
+	}
+
+	for _, contact := range contactList.Contacts() {
+		reply.Contacts = append(reply.Contacts, contact.Diagnostics())
+	}
+	return reply, nil
+}
+
+func (s *RpcServer) ExportContacts(ctx context.Context, req *ricochet.ExportContactsRequest) (*ricochet.ContactListExport, error) {
+	return s.Core.Identity.ContactList().ExportContacts(), nil
+}
+
+func (s *RpcServer) ImportContacts(ctx context.Context, req *ricochet.ContactListExport) (*ricochet.ImportContactsReply, error) {
+	added, skipped := s.Core.Identity.ContactList().ImportContacts(req)
+	reply := &ricochet.ImportContactsReply{Skipped: skipped}
+	for _, contact := range added {
+		reply.Contacts = append(reply.Contacts, contact.Data())
+	}
+	return reply, nil
+}
+
+// Identifies a message within all conversations, for MonitorConversations
+type conversationMessageKey struct {
+	address  string
+	sequence uint64
+}
+
+func messageKey(message *ricochet.Message) conversationMessageKey {
+	if message.Sender.GetIsSelf() {
+		return conversationMessageKey{message.Recipient.GetAddress(), message.Sequence}
+	}
+	return conversationMessageKey{message.Sender.GetAddress(), message.Sequence}
+}
+
+// MonitorConversations sends the messages of all conversations as POPULATE
+// events, followed by any changes. Each call has its own subscription to events,
+// so any number of clients can monitor conversations at once.
 func (s *RpcServer) MonitorConversations(req *ricochet.MonitorConversationsRequest, stream ricochet.RicochetCore_MonitorConversationsServer) error {
-	// XXX Technically there is a race between starting to monitor
-	// and the list and state of messages used to populate, that could
-	// result in duplicate messages or other weird behavior.
-	// Same problem exists for other places this pattern is used.
+	// Subscribe before populating, so no events are missed. Events are published
+	// while holding the conversation's mutex, so any event for a message that
+	// appears in the populated messages was queued before population finished.
+	// Those messages are skipped when they're received from the monitor.
 	monitor := s.Core.Identity.ConversationStream.Subscribe(100)
 	defer s.Core.Identity.ConversationStream.Unsubscribe(monitor)
 
+	populated := make(map[conversationMessageKey]struct{})
 	{
 		// Populate with existing conversations
 		contacts := s.Core.Identity.ContactList().Contacts()
 		for _, contact := range contacts {
 			messages := contact.Conversation().Messages()
 			for _, message := range messages {
+				populated[messageKey(message)] = struct{}{}
 				event := ricochet.ConversationEvent{
 					Type: ricochet.ConversationEvent_POPULATE,
 					Msg:  message,
@@ -217,37 +592,61 @@ func (s *RpcServer) MonitorConversations(req *ricochet.MonitorConversationsReque
 		}
 	}
 
+	// Only events that were already queued can duplicate populated messages
+	queued := len(monitor)
 	for {
 		event, ok := (<-monitor).(ricochet.ConversationEvent)
 		if !ok {
-			break
+			// The publisher drops subscribers that fall too far behind
+			return errors.New("Conversation monitor fell behind")
+		}
+
+		if queued > 0 {
+			queued--
+			if event.Msg != nil && (event.Type == ricochet.ConversationEvent_RECEIVE || event.Type == ricochet.ConversationEvent_SEND) {
+				if _, exists := populated[messageKey(event.Msg)]; exists {
+					continue
+				}
+			}
 		}
 
 		if err := stream.Send(&event); err != nil {
 			return err
 		}
 	}
-
-	return nil
 }
 
+// SendMessage sends a message to the contact with the recipient's address. Only
+// the address is needed to identify the recipient; a missing sender is taken
+// to be self.
 func (s *RpcServer) SendMessage(ctx context.Context, req *ricochet.Message) (*ricochet.Message, error) {
-	if req.Sender == nil || !req.Sender.IsSelf {
+	if req.Sender != nil && !req.Sender.IsSelf {
 		return nil, errors.New("Invalid message sender")
 	} else if req.Recipient == nil || req.Recipient.IsSelf {
 		return nil, errors.New("Invalid message recipient")
 	}
 
-	contact := s.Core.Identity.ContactList().ContactByAddress(req.Recipient.Address)
-	if contact == nil {
-		return nil, errors.New("Unknown recipient")
+	contact, err := s.Core.Identity.ContactList().MessageRecipient(req.Recipient.Address)
+	switch err {
+	case nil:
+	case UnknownRecipientError:
+		return nil, grpc.Errorf(codes.NotFound, "%s", err)
+	case PendingRecipientError, BlockedRecipientError:
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", err)
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
 	}
 
-	// XXX timestamp
-	// XXX validate text
-	// XXX identifier
+	// Clients are expected to check messages against the limits from
+	// GetServerStatus, but nothing they send is trusted
+	if err := s.Core.Acceptance.CheckMessage(req.Text); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
 
-	message, err := contact.Conversation().Send(req.Text)
+	// Timestamp and sequence are assigned by the backend, and the protocol
+	// identifier when the message is sent
+
+	message, err := contact.Conversation().Send(req.Text, req.InReplyTo.GetSequence())
 	if err != nil {
 		return nil, err
 	}
@@ -265,6 +664,166 @@ func (s *RpcServer) MarkConversationRead(ctx context.Context, req *ricochet.Mark
 		return nil, errors.New("Unknown entity")
 	}
 
-	contact.Conversation().MarkReadBeforeMessage(req.LastRecvIdentifier)
+	if req.LastRecvSequence != 0 {
+		contact.Conversation().MarkReadBeforeSequence(req.LastRecvSequence)
+	} else {
+		contact.Conversation().MarkReadBeforeMessage(req.LastRecvIdentifier)
+	}
 	return &ricochet.Reply{}, nil
 }
+
+func (s *RpcServer) GetUnreadCount(ctx context.Context, req *ricochet.UnreadCountRequest) (*ricochet.UnreadCountReply, error) {
+	total := s.Core.Identity.ContactList().UnreadCount()
+	return &ricochet.UnreadCountReply{Total: uint32(total)}, nil
+}
+
+func (s *RpcServer) GetQueuedMessages(ctx context.Context, req *ricochet.QueuedMessagesRequest) (*ricochet.QueuedMessagesReply, error) {
+	if req.Entity == nil || req.Entity.IsSelf {
+		return nil, errors.New("Invalid entity")
+	}
+
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Entity.Address)
+	if contact == nil {
+		return nil, errors.New("Unknown entity")
+	}
+
+	count, oldest := contact.Conversation().QueueDepth()
+	return &ricochet.QueuedMessagesReply{
+		Count:           uint32(count),
+		OldestTimestamp: oldest,
+	}, nil
+}
+
+func (s *RpcServer) SetConversationTyping(ctx context.Context, req *ricochet.SetConversationTypingRequest) (*ricochet.Reply, error) {
+	if req.Entity == nil || req.Entity.IsSelf {
+		return nil, errors.New("Invalid entity")
+	}
+
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Entity.Address)
+	if contact == nil {
+		return nil, errors.New("Unknown entity")
+	}
+
+	if err := contact.Conversation().SetTyping(req.Typing); err != nil {
+		return nil, err
+	}
+	return &ricochet.Reply{}, nil
+}
+
+func (s *RpcServer) GetConversationDraft(ctx context.Context, req *ricochet.ConversationDraftRequest) (*ricochet.ConversationDraft, error) {
+	if req.Entity == nil || req.Entity.IsSelf {
+		return nil, errors.New("Invalid entity")
+	}
+
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Entity.Address)
+	if contact == nil {
+		return nil, errors.New("Unknown entity")
+	}
+
+	return &ricochet.ConversationDraft{
+		Entity: &ricochet.Entity{Address: contact.Address()},
+		Text:   contact.Draft(),
+	}, nil
+}
+
+func (s *RpcServer) SetConversationDraft(ctx context.Context, req *ricochet.ConversationDraft) (*ricochet.ConversationDraft, error) {
+	if req.Entity == nil || req.Entity.IsSelf {
+		return nil, errors.New("Invalid entity")
+	}
+
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Entity.Address)
+	if contact == nil {
+		return nil, errors.New("Unknown entity")
+	}
+
+	if err := contact.SetDraft(req.Text); err != nil {
+		return nil, err
+	}
+	return &ricochet.ConversationDraft{
+		Entity: &ricochet.Entity{Address: contact.Address()},
+		Text:   contact.Draft(),
+	}, nil
+}
+
+func (s *RpcServer) MonitorFileTransfers(req *ricochet.MonitorFileTransfersRequest, stream ricochet.RicochetCore_MonitorFileTransfersServer) error {
+	transfers := s.Core.Identity.FileTransfers()
+	monitor := transfers.EventMonitor().Subscribe(100)
+	defer transfers.EventMonitor().Unsubscribe(monitor)
+
+	// Populate with existing transfers
+	for _, ft := range transfers.Transfers() {
+		event := &ricochet.FileTransferEvent{
+			Type:     ricochet.FileTransferEvent_POPULATE,
+			Transfer: ft.Data(),
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	// End population with an empty populate event
+	{
+		event := &ricochet.FileTransferEvent{
+			Type: ricochet.FileTransferEvent_POPULATE,
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		event, ok := (<-monitor).(ricochet.FileTransferEvent)
+		if !ok {
+			// The publisher drops subscribers that fall too far behind
+			return errors.New("File transfer monitor fell behind")
+		}
+
+		if err := stream.Send(&event); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *RpcServer) SendFile(ctx context.Context, req *ricochet.SendFileRequest) (*ricochet.FileTransfer, error) {
+	contact := s.Core.Identity.ContactList().ContactByAddress(req.Address)
+	if contact == nil {
+		return nil, errors.New("Contact not found")
+	} else if contact.IsRequest() || contact.IsBlocked() {
+		return nil, errors.New("Files can only be sent to accepted contacts")
+	}
+
+	ft, err := s.Core.Identity.FileTransfers().SendFile(contact, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ft.Data(), nil
+}
+
+func (s *RpcServer) RespondFileTransfer(ctx context.Context, req *ricochet.RespondFileTransferRequest) (*ricochet.FileTransfer, error) {
+	ft := s.Core.Identity.FileTransfers().TransferByIdentifier(req.Identifier)
+	if ft == nil {
+		return nil, errors.New("Transfer not found")
+	}
+
+	var err error
+	if req.Accept {
+		err = ft.Accept(req.Path)
+	} else {
+		err = ft.Reject()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ft.Data(), nil
+}
+
+func (s *RpcServer) CancelFileTransfer(ctx context.Context, req *ricochet.CancelFileTransferRequest) (*ricochet.FileTransfer, error) {
+	ft := s.Core.Identity.FileTransfers().TransferByIdentifier(req.Identifier)
+	if ft == nil {
+		return nil, errors.New("Transfer not found")
+	}
+
+	if err := ft.Cancel(); err != nil {
+		return nil, err
+	}
+	return ft.Data(), nil
+}
@@ -0,0 +1,536 @@
+package core
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testContactStream is a MonitorContacts client, which applies the events it's
+// sent to its own copy of the contacts and requests, and records any event
+// that doesn't make sense for that state
+type testContactStream struct {
+	grpc.ServerStream
+
+	mutex     sync.Mutex
+	closed    bool
+	populated bool
+	contacts  map[string]*ricochet.Contact
+	requests  map[string]*ricochet.ContactRequest
+	errors    []string
+}
+
+func newTestContactStream() *testContactStream {
+	return &testContactStream{
+		contacts: make(map[string]*ricochet.Contact),
+		requests: make(map[string]*ricochet.ContactRequest),
+	}
+}
+
+func (s *testContactStream) Send(event *ricochet.ContactEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return io.EOF
+	}
+
+	if event.Type == ricochet.ContactEvent_POPULATE {
+		if s.populated {
+			s.errors = append(s.errors, fmt.Sprintf("populate after the end of population: %v", event))
+		} else if event.Subject == nil {
+			s.populated = true
+			return nil
+		}
+	} else if !s.populated {
+		s.errors = append(s.errors, fmt.Sprintf("%v before the end of population", event.Type))
+	}
+
+	var address string
+	var known bool
+	if contact := event.GetContact(); contact != nil {
+		address = contact.Address
+		_, known = s.contacts[address]
+	} else if request := event.GetRequest(); request != nil {
+		address = request.Address
+		_, known = s.requests[address]
+	}
+	adding := event.Type == ricochet.ContactEvent_POPULATE || event.Type == ricochet.ContactEvent_ADD
+	if adding == known {
+		s.errors = append(s.errors, fmt.Sprintf("%v of %s, which is known %v", event.Type, address, known))
+	}
+
+	if contact := event.GetContact(); contact != nil {
+		if event.Type == ricochet.ContactEvent_DELETE {
+			delete(s.contacts, address)
+		} else {
+			s.contacts[address] = contact
+		}
+	} else if request := event.GetRequest(); request != nil {
+		if event.Type == ricochet.ContactEvent_DELETE {
+			delete(s.requests, address)
+		} else {
+			s.requests[address] = request
+		}
+	}
+	return nil
+}
+
+// repopulate clears the state before monitoring again, as a client does when
+// its monitor ends because it fell behind. It returns false if the stream is
+// closed.
+func (s *testContactStream) repopulate() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.populated = false
+	s.contacts = make(map[string]*ricochet.Contact)
+	s.requests = make(map[string]*ricochet.ContactRequest)
+	return !s.closed
+}
+
+// matches returns an error if the stream's state differs from contactList
+func (s *testContactStream) matches(contactList *ContactList) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	contacts := contactList.Contacts()
+	if len(contacts) != len(s.contacts) {
+		return fmt.Errorf("client has %d contacts, expected %d", len(s.contacts), len(contacts))
+	}
+	for _, contact := range contacts {
+		if data := contact.Data(); !proto.Equal(s.contacts[data.Address], data) {
+			return fmt.Errorf("client has contact %v, expected %v", s.contacts[data.Address], data)
+		}
+	}
+	requests := contactList.InboundRequests()
+	if len(requests) != len(s.requests) {
+		return fmt.Errorf("client has %d requests, expected %d", len(s.requests), len(requests))
+	}
+	for _, request := range requests {
+		if data := request.Data(); !proto.Equal(s.requests[data.Address], &data) {
+			return fmt.Errorf("client has request %v, expected %v", s.requests[data.Address], &data)
+		}
+	}
+	return nil
+}
+
+// Clients that monitor contacts at the same time both end up with the current
+// contacts and requests, however their population overlaps with changes, and
+// are never sent duplicate or unknown contacts. Clients that fall behind
+// monitor again, and are populated again.
+func TestMonitorContactsClients(t *testing.T) {
+	const changes = 10
+	tests := []struct {
+		name string
+		// Number of changes made before the second client starts
+		secondAfter int
+	}{
+		{"second before changes", 0},
+		{"second during changes", changes / 2},
+		{"second after changes", changes},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := newTestNetwork()
+			core := newTestPeer(t, network)
+			contactList := core.Identity.ContactList()
+			server := &RpcServer{Core: core}
+
+			var wg sync.WaitGroup
+			var streams []*testContactStream
+			monitor := func() {
+				stream := newTestContactStream()
+				streams = append(streams, stream)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						server.MonitorContacts(&ricochet.MonitorContactsRequest{}, stream)
+						if !stream.repopulate() {
+							return
+						}
+					}
+				}()
+			}
+
+			monitor()
+			for i := 0; i <= changes; i++ {
+				if i == test.secondAfter {
+					monitor()
+				}
+				if i == changes {
+					break
+				}
+
+				contactAddress, _ := AddressFromPlainHost(fmt.Sprintf("cccccccccccccc%c%c", 'a'+i/26, 'a'+i%26))
+				contact, err := contactList.AddNewContact(&ricochet.Contact{
+					Address:  contactAddress,
+					Nickname: fmt.Sprintf("contact%d", i),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := contact.SetNickname(fmt.Sprintf("renamed%d", i)); err != nil {
+					t.Fatal(err)
+				}
+				if i%3 == 0 {
+					if err := contactList.RemoveContact(contact); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				requestAddress, _ := AddressFromPlainHost(fmt.Sprintf("dddddddddddddd%c%c", 'a'+i/26, 'a'+i%26))
+				request, _ := contactList.AddOrUpdateInboundContactRequest(requestAddress, fmt.Sprintf("request%d", i), "hello")
+				if request == nil {
+					t.Fatal("inbound request wasn't added")
+				}
+				if i%2 == 0 {
+					if _, err := request.Accept(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			deadline := time.Now().Add(10 * time.Second)
+			for i, stream := range streams {
+				for {
+					err := stream.matches(contactList)
+					if err == nil {
+						break
+					} else if time.Now().After(deadline) {
+						t.Fatalf("client %d: %v", i, err)
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+
+			for i, stream := range streams {
+				stream.mutex.Lock()
+				stream.closed = true
+				for _, err := range stream.errors {
+					t.Errorf("client %d: %s", i, err)
+				}
+				stream.mutex.Unlock()
+			}
+			// The monitors return when they're sent an event after the stream closes
+			contactList.events.Publish(ricochet.ContactEvent{})
+			wg.Wait()
+		})
+	}
+}
+
+// Messages from clients are checked by the backend, and refused with
+// InvalidArgument without being sent or queued
+func TestSendMessageValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		ok   bool
+	}{
+		{"message", "hello", true},
+		{"multiple lines", "hello\n\tthere", true},
+		{"at limit", strings.Repeat("a", MaxMessageLength), true},
+		{"empty", "", false},
+		{"oversized", strings.Repeat("a", MaxMessageLength+1), false},
+		{"control character", "hello\x1b[2J", false},
+		{"invalid utf-8", "hello\xff", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			server := &RpcServer{Core: core}
+			_, err := server.SendMessage(context.Background(), &ricochet.Message{
+				Sender:    &ricochet.Entity{IsSelf: true},
+				Recipient: &ricochet.Entity{Address: contact.Address()},
+				Text:      test.text,
+			})
+			if test.ok && err != nil {
+				t.Fatal(err)
+			} else if !test.ok && grpc.Code(err) != codes.InvalidArgument {
+				t.Fatalf("error is %v, expected InvalidArgument", err)
+			}
+
+			expected := 0
+			if test.ok {
+				expected = 1
+			}
+			if messages := contact.Conversation().Messages(); len(messages) != expected {
+				t.Errorf("conversation has %d messages, expected %d", len(messages), expected)
+			}
+		})
+	}
+}
+
+// SendMessage finds the recipient by its address alone, in any form, and only
+// sends to accepted contacts that aren't blocked
+func TestSendMessageByAddress(t *testing.T) {
+	const address = "ricochet:bbbbbbbbbbbbbbbb"
+	tests := []struct {
+		name string
+		// Adds the recipient to the contact list, if it's there at all
+		setup     func(t *testing.T, core *Ricochet)
+		recipient string
+		code      codes.Code
+	}{
+		{"contact", addAcceptedContact, address, codes.OK},
+		{"contact by onion", addAcceptedContact, "bbbbbbbbbbbbbbbb.onion", codes.OK},
+		{"contact by hostname", addAcceptedContact, "bbbbbbbbbbbbbbbb", codes.OK},
+		{"unknown", func(t *testing.T, core *Ricochet) {}, address, codes.NotFound},
+		{"outbound request", func(t *testing.T, core *Ricochet) {
+			contact := newTestContact(t, core, address)
+			contact.mutex.Lock()
+			contact.data.Request = &ricochet.ContactRequest{Direction: ricochet.ContactRequest_OUTBOUND}
+			contact.mutex.Unlock()
+		}, address, codes.FailedPrecondition},
+		{"inbound request", func(t *testing.T, core *Ricochet) {
+			if request, _ := core.Identity.ContactList().AddOrUpdateInboundContactRequest(address, "", ""); request == nil {
+				t.Fatal("inbound request wasn't added")
+			}
+		}, address, codes.FailedPrecondition},
+		{"blocked", func(t *testing.T, core *Ricochet) {
+			newTestContact(t, core, address).SetBlocked(true)
+		}, address, codes.FailedPrecondition},
+		{"invalid", addAcceptedContact, "bbbb", codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			test.setup(t, core)
+			server := &RpcServer{Core: core}
+			// Scripted clients may leave out everything but the address
+			message, err := server.SendMessage(context.Background(), &ricochet.Message{
+				Recipient: &ricochet.Entity{Address: test.recipient},
+				Text:      "hello",
+			})
+			if code := grpc.Code(err); code != test.code {
+				t.Fatalf("error is %v, expected %v", err, test.code)
+			}
+
+			expected := 0
+			if test.code == codes.OK {
+				expected = 1
+				if message.Recipient.GetAddress() != address || !message.Sender.GetIsSelf() {
+					t.Errorf("message is from %v to %v, expected from self to %s", message.Sender, message.Recipient, address)
+				}
+			}
+			if contact := core.Identity.ContactList().ContactByAddress(address); contact != nil {
+				if messages := contact.Conversation().Messages(); len(messages) != expected {
+					t.Errorf("conversation has %d messages, expected %d", len(messages), expected)
+				}
+			}
+		})
+	}
+}
+
+func addAcceptedContact(t *testing.T, core *Ricochet) {
+	newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+}
+
+// Contact requests are stored with the canonical form of the address they're
+// given, and refused as invalid arguments for addresses that aren't valid
+func TestAddContactRequestAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		code    codes.Code
+	}{
+		{"canonical", "ricochet:bbbbbbbbbbbbbbbb", codes.OK},
+		{"onion", " BBBBBBBBBBBBBBBB.onion ", codes.OK},
+		{"plain host", "bbbbbbbbbbbbbbbb", codes.OK},
+		{"invalid", "ricochet:bbbb", codes.InvalidArgument},
+		{"empty", "", codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			t.Cleanup(core.Identity.contactList.shutdown)
+			server := &RpcServer{Core: core}
+			contact, err := server.AddContactRequest(context.Background(), &ricochet.ContactRequest{
+				Direction: ricochet.ContactRequest_OUTBOUND,
+				Address:   test.address,
+				Nickname:  "bob",
+			})
+			if code := grpc.Code(err); code != test.code {
+				t.Fatalf("error is %v, expected %v", err, test.code)
+			}
+
+			contacts := core.Identity.ContactList().Contacts()
+			if test.code != codes.OK {
+				if len(contacts) != 0 {
+					t.Errorf("added %d contacts for an invalid address", len(contacts))
+				}
+				return
+			}
+			if contact.Address != "ricochet:bbbbbbbbbbbbbbbb" || contact.Request.GetAddress() != contact.Address {
+				t.Errorf("contact has address %q and request address %q, expected the canonical address", contact.Address, contact.Request.GetAddress())
+			}
+			if _, exists := core.Config.Read().Contacts[contact.Address]; !exists {
+				t.Error("contact wasn't saved with the canonical address")
+			}
+		})
+	}
+}
+
+// An outbound request's nickname and message can be edited until it's sent,
+// and the edit is saved for the next delivery attempt
+func TestEditOutboundRequest(t *testing.T) {
+	const address = "ricochet:bbbbbbbbbbbbbbbb"
+	tests := []struct {
+		name string
+		// Changes the request added with nickname "alice" and message "hello",
+		// if it's kept at all
+		setup              func(contact *Contact)
+		fromNickname, text string
+		code               codes.Code
+	}{
+		{"pending", func(contact *Contact) {}, "bob", "hi there", codes.OK},
+		{"unchanged", func(contact *Contact) {}, "alice", "hello", codes.OK},
+		{"cleared", func(contact *Contact) {}, "", "", codes.OK},
+		{"sending", func(contact *Contact) {
+			contact.requestSending = true
+		}, "bob", "hi there", codes.FailedPrecondition},
+		{"delivered", func(contact *Contact) {
+			contact.data.Request.WhenDelivered = time.Now().Format(time.RFC3339)
+		}, "bob", "hi there", codes.FailedPrecondition},
+		{"rejected", func(contact *Contact) {
+			contact.data.Request.Rejected = true
+		}, "bob", "hi there", codes.FailedPrecondition},
+		{"accepted", func(contact *Contact) {
+			contact.data.Request = nil
+		}, "bob", "hi there", codes.FailedPrecondition},
+		{"invalid message", func(contact *Contact) {}, "bob", strings.Repeat("x", 100000), codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			t.Cleanup(core.Identity.contactList.shutdown)
+			server := &RpcServer{Core: core}
+			if _, err := server.AddContactRequest(context.Background(), &ricochet.ContactRequest{
+				Direction:    ricochet.ContactRequest_OUTBOUND,
+				Address:      address,
+				Nickname:     "bob",
+				FromNickname: "alice",
+				Text:         "hello",
+			}); err != nil {
+				t.Fatal(err)
+			}
+			contact := core.Identity.ContactList().ContactByAddress(address)
+			contact.mutex.Lock()
+			test.setup(contact)
+			contact.mutex.Unlock()
+
+			data, err := server.EditOutboundRequest(context.Background(), &ricochet.ContactRequest{
+				Direction:    ricochet.ContactRequest_OUTBOUND,
+				Address:      address,
+				FromNickname: test.fromNickname,
+				Text:         test.text,
+			})
+			if code := grpc.Code(err); code != test.code {
+				t.Fatalf("error is %v, expected %v", err, test.code)
+			}
+
+			request := contact.Data().Request
+			if request == nil {
+				return
+			}
+			fromNickname, text := "alice", "hello"
+			if test.code == codes.OK {
+				fromNickname, text = test.fromNickname, test.text
+				if data.Request.GetFromNickname() != fromNickname || data.Request.GetText() != text {
+					t.Errorf("replied with %q and %q, expected %q and %q", data.Request.GetFromNickname(), data.Request.GetText(), fromNickname, text)
+				}
+			}
+			if request.FromNickname != fromNickname || request.Text != text {
+				t.Errorf("request has %q and %q, expected %q and %q", request.FromNickname, request.Text, fromNickname, text)
+			}
+			saved := core.Config.Read().Contacts[address].Request
+			if saved.GetFromNickname() != fromNickname || saved.GetText() != text {
+				t.Errorf("saved %q and %q, expected %q and %q", saved.GetFromNickname(), saved.GetText(), fromNickname, text)
+			}
+		})
+	}
+
+	// Unknown contacts have no request to edit
+	core := newTestCore(t)
+	server := &RpcServer{Core: core}
+	_, err := server.EditOutboundRequest(context.Background(), &ricochet.ContactRequest{
+		Direction: ricochet.ContactRequest_OUTBOUND,
+		Address:   address,
+		Text:      "hello",
+	})
+	if code := grpc.Code(err); code != codes.NotFound {
+		t.Errorf("error for an unknown contact is %v, expected %v", err, codes.NotFound)
+	}
+}
+
+// Events sent to MonitorContacts clients describe the change since the client's
+// last copy of the contact
+func TestReconcileChange(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether our authentication was already rejected when the client
+		// saw the contact
+		rejected bool
+		// Changes the contact after the client has seen it
+		change   func(contact *Contact)
+		expected ricochet.ContactEvent_Change
+	}{
+		{"authentication rejected", false, func(contact *Contact) {
+			contact.setAuthenticationRejected()
+		}, ricochet.ContactEvent_AUTHENTICATION_REJECTED},
+		{"authentication accepted", true, func(contact *Contact) {
+			contact.mutex.Lock()
+			contact.data.AuthenticationRejected = ""
+			contact.mutex.Unlock()
+		}, ricochet.ContactEvent_OTHER},
+		{"came online", true, func(contact *Contact) {
+			contact.mutex.Lock()
+			contact.data.Status = ricochet.Contact_ONLINE
+			contact.mutex.Unlock()
+		}, ricochet.ContactEvent_CAME_ONLINE},
+		{"nickname", true, func(contact *Contact) {
+			contact.SetNickname("carol")
+		}, ricochet.ContactEvent_OTHER},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := newTestCore(t)
+			contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+			if test.rejected {
+				contact.mutex.Lock()
+				contact.data.AuthenticationRejected = time.Now().Format(time.RFC3339)
+				contact.mutex.Unlock()
+			}
+			state := newContactMonitorState(core.Identity.ContactList())
+			subject := &ricochet.ContactEvent{
+				Subject: &ricochet.ContactEvent_Contact{Contact: contact.Data()},
+			}
+			if event := state.reconcile(subject); event == nil || event.Type != ricochet.ContactEvent_ADD {
+				t.Fatalf("first event is %v, expected ADD", event)
+			}
+
+			test.change(contact)
+			event := state.reconcile(subject)
+			if event == nil || event.Type != ricochet.ContactEvent_UPDATE {
+				t.Fatalf("event after the change is %v, expected UPDATE", event)
+			}
+			if event.Change != test.expected {
+				t.Errorf("change is %v, expected %v", event.Change, test.expected)
+			}
+		})
+	}
+}
@@ -1,25 +1,134 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
 
 const (
-	// Consistent with protocol's ContactRequestChannel
+	// Consistent with protocol's ContactRequestChannel. An AcceptancePolicy
+	// can't allow longer nicknames or messages than these.
 	MaxMessageLength  = 2000
 	MaxNicknameLength = 30
+	// Reasons given when rejecting a contact request
+	MaxRejectReasonLength = 200
+	// Presence status messages, in bytes of UTF-8
+	MaxStatusMessageLength = 140
 )
 
+// AcceptancePolicy holds the rules for nicknames, messages, and other text from
+// contacts and clients. The same policy is used for the protocol and the RPC
+// server, and is sent to clients so they can check text before sending it.
+type AcceptancePolicy struct {
+	// Maximum length of nicknames in unicode characters, up to MaxNicknameLength
+	MaxNicknameLength int
+	// Maximum length of messages in bytes of UTF-8, up to MaxMessageLength
+	MaxMessageLength int
+	// Maximum length of contact request rejection reasons in bytes of UTF-8, up
+	// to MaxMessageLength
+	MaxRejectReasonLength int
+	// If not empty, nicknames may only contain characters from these tables,
+	// such as unicode.L for letters. Characters forbidden by IsNicknameAcceptable
+	// are never allowed.
+	NicknameCharacters []*unicode.RangeTable
+}
+
+// DefaultAcceptancePolicy is used for fields of Ricochet.Acceptance that are unset
+var DefaultAcceptancePolicy = AcceptancePolicy{
+	MaxNicknameLength:     MaxNicknameLength,
+	MaxMessageLength:      MaxMessageLength,
+	MaxRejectReasonLength: MaxRejectReasonLength,
+}
+
+var (
+	acceptancePolicy      = DefaultAcceptancePolicy
+	acceptancePolicyMutex sync.RWMutex
+)
+
+// SetAcceptancePolicy changes the policy used by IsNicknameAcceptable and the
+// other package functions. This is done by Ricochet.Init, and should not be
+// needed otherwise.
+func SetAcceptancePolicy(policy AcceptancePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	acceptancePolicyMutex.Lock()
+	acceptancePolicy = policy
+	acceptancePolicyMutex.Unlock()
+	return nil
+}
+
+// CurrentAcceptancePolicy returns the policy used by IsNicknameAcceptable and the
+// other package functions
+func CurrentAcceptancePolicy() AcceptancePolicy {
+	acceptancePolicyMutex.RLock()
+	defer acceptancePolicyMutex.RUnlock()
+	return acceptancePolicy
+}
+
+// withDefaults returns the policy with DefaultAcceptancePolicy used for any
+// unset limits
+func (p AcceptancePolicy) withDefaults() AcceptancePolicy {
+	if p.MaxNicknameLength == 0 {
+		p.MaxNicknameLength = DefaultAcceptancePolicy.MaxNicknameLength
+	}
+	if p.MaxMessageLength == 0 {
+		p.MaxMessageLength = DefaultAcceptancePolicy.MaxMessageLength
+	}
+	if p.MaxRejectReasonLength == 0 {
+		p.MaxRejectReasonLength = DefaultAcceptancePolicy.MaxRejectReasonLength
+	}
+	return p
+}
+
+// Validate returns an error if the limits are out of range. Policies can be
+// stricter than the protocol, but not more permissive.
+func (p AcceptancePolicy) Validate() error {
+	if p.MaxNicknameLength < 1 || p.MaxNicknameLength > MaxNicknameLength {
+		return errors.New("Maximum nickname length must be between 1 and 30 characters")
+	}
+	if p.MaxMessageLength < 1 || p.MaxMessageLength > MaxMessageLength {
+		return errors.New("Maximum message length must be between 1 and 2000 bytes")
+	}
+	if p.MaxRejectReasonLength < 1 || p.MaxRejectReasonLength > MaxMessageLength {
+		return errors.New("Maximum reject reason length must be between 1 and 2000 bytes")
+	}
+	return nil
+}
+
+// IsNicknameAcceptable returns true for strings that are usable as contact nicknames
+// under the current AcceptancePolicy. See AcceptancePolicy.IsNicknameAcceptable.
+func IsNicknameAcceptable(nickname string) bool {
+	policy := CurrentAcceptancePolicy()
+	return policy.IsNicknameAcceptable(nickname)
+}
+
+// IsMessageAcceptable returns true for strings that are usable as chat messages
+// under the current AcceptancePolicy. See AcceptancePolicy.IsMessageAcceptable.
+func IsMessageAcceptable(message string) bool {
+	policy := CurrentAcceptancePolicy()
+	return policy.IsMessageAcceptable(message)
+}
+
+// IsRejectReasonAcceptable returns true for strings that are usable as the reason
+// for rejecting a contact request under the current AcceptancePolicy. See
+// AcceptancePolicy.IsRejectReasonAcceptable.
+func IsRejectReasonAcceptable(reason string) bool {
+	policy := CurrentAcceptancePolicy()
+	return policy.IsRejectReasonAcceptable(reason)
+}
+
 // IsNicknameAcceptable returns true for strings that are usable as contact nicknames.
 // A nickname is acceptable if it:
 //   - Is composed of only valid UTF-8 sequences
 //   - Has between 1 and MaxNicknameLength unicode characters
 //   - Doesn't contain any characters from unicode Cf or Cc
 //   - Doesn't contain any of these HTML-sensitive characters: "<>&\
-//   - XXX This could use more thought on valid codepoints; note that Go
-//     has a good set of built-in range tables in `unicode`
-func IsNicknameAcceptable(nickname string) bool {
+//   - Only contains characters from NicknameCharacters, if set
+func (p *AcceptancePolicy) IsNicknameAcceptable(nickname string) bool {
 	length := 0
 
 	blacklist := []rune{'"', '<', '>', '&', '\\'}
@@ -40,8 +149,12 @@ func IsNicknameAcceptable(nickname string) bool {
 			}
 		}
 
+		if len(p.NicknameCharacters) > 0 && !unicode.In(r, p.NicknameCharacters...) {
+			return false
+		}
+
 		length++
-		if length > MaxNicknameLength {
+		if length > p.MaxNicknameLength {
 			return false
 		}
 		nickname = nickname[sz:]
@@ -54,9 +167,76 @@ func IsNicknameAcceptable(nickname string) bool {
 // A message is acceptable if it:
 //   - Is composed of only valid UTF-8 sequences
 //   - Encodes to between 1 and MaxMessageLength bytes in UTF-8
+//   - Doesn't contain control characters (unicode Cc), other than newline and tab,
+//     which would allow terminal escape sequences in the backlog
 //   - XXX This also needs more thought on valid unicode characters
-func IsMessageAcceptable(message string) bool {
-	return len(message) > 0 &&
-		len(message) <= MaxMessageLength &&
-		utf8.ValidString(message)
+func (p *AcceptancePolicy) IsMessageAcceptable(message string) bool {
+	if len(message) == 0 || len(message) > p.MaxMessageLength || !utf8.ValidString(message) {
+		return false
+	}
+
+	for _, r := range message {
+		if unicode.Is(unicode.Cc, r) && r != '\n' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckMessage is the same as IsMessageAcceptable, but returns an error that
+// says why the message isn't acceptable, for reporting to the sender.
+func (p *AcceptancePolicy) CheckMessage(message string) error {
+	if len(message) == 0 {
+		return errors.New("Message text is empty")
+	} else if len(message) > p.MaxMessageLength {
+		return fmt.Errorf("Message is too long; the limit is %d bytes", p.MaxMessageLength)
+	} else if !utf8.ValidString(message) {
+		return errors.New("Message is not valid UTF-8")
+	} else if !p.IsMessageAcceptable(message) {
+		return errors.New("Message contains control characters")
+	}
+	return nil
+}
+
+// IsRejectReasonAcceptable returns true for strings that are usable as the reason
+// for rejecting a contact request. The rules are the same as for messages, but
+// reasons may not be longer than MaxRejectReasonLength bytes.
+func (p *AcceptancePolicy) IsRejectReasonAcceptable(reason string) bool {
+	return len(reason) <= p.MaxRejectReasonLength && p.IsMessageAcceptable(reason)
+}
+
+// IsStatusMessageAcceptable returns true for strings that are usable as the
+// message of our presence. A status message is acceptable if it:
+//   - Is composed of only valid UTF-8 sequences
+//   - Encodes to at most MaxStatusMessageLength bytes in UTF-8; it may be empty
+//   - Doesn't contain any characters from unicode Cc or Cf, including newlines
+func IsStatusMessageAcceptable(message string) bool {
+	if len(message) > MaxStatusMessageLength || !utf8.ValidString(message) {
+		return false
+	}
+	for _, r := range message {
+		if unicode.In(r, unicode.Cc, unicode.Cf) {
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeStatusMessage makes a status message received from a contact
+// acceptable, by removing invalid UTF-8 and the characters forbidden by
+// IsStatusMessageAcceptable, and truncating it to MaxStatusMessageLength bytes.
+func SanitizeStatusMessage(message string) string {
+	result := make([]rune, 0, len(message))
+	length := 0
+	for _, r := range message {
+		if r == utf8.RuneError || unicode.In(r, unicode.Cc, unicode.Cf) {
+			continue
+		}
+		length += utf8.RuneLen(r)
+		if length > MaxStatusMessageLength {
+			break
+		}
+		result = append(result, r)
+	}
+	return string(result)
 }
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// Nicknames, messages and reasons are checked against the limits of the
+// policy, at and around its boundaries
+func TestAcceptancePolicy(t *testing.T) {
+	strict := AcceptancePolicy{
+		MaxNicknameLength:     5,
+		MaxMessageLength:      10,
+		MaxRejectReasonLength: 4,
+		NicknameCharacters:    []*unicode.RangeTable{unicode.L},
+	}
+	tests := []struct {
+		name   string
+		policy AcceptancePolicy
+		check  func(p *AcceptancePolicy, text string) bool
+		text   string
+		ok     bool
+	}{
+		{"nickname at limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, strings.Repeat("a", MaxNicknameLength), true},
+		{"nickname over limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, strings.Repeat("a", MaxNicknameLength+1), false},
+		{"nickname limit in characters", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, strings.Repeat("é", MaxNicknameLength), true},
+		{"empty nickname", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, "", false},
+		{"nickname with html", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, "a<b", false},
+		{"nickname with digits", DefaultAcceptancePolicy, (*AcceptancePolicy).IsNicknameAcceptable, "alice2", true},
+		{"strict nickname at limit", strict, (*AcceptancePolicy).IsNicknameAcceptable, "alice", true},
+		{"strict nickname over limit", strict, (*AcceptancePolicy).IsNicknameAcceptable, "alicia", false},
+		{"strict nickname with digits", strict, (*AcceptancePolicy).IsNicknameAcceptable, "bob2", false},
+		{"message at limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, strings.Repeat("a", MaxMessageLength), true},
+		{"message over limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, strings.Repeat("a", MaxMessageLength+1), false},
+		{"message limit in bytes", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, strings.Repeat("é", MaxMessageLength/2+1), false},
+		{"empty message", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, "", false},
+		{"message with newline", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, "a\nb\tc", true},
+		{"message with escape", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, "a\x1b[2J", false},
+		{"invalid utf-8 message", DefaultAcceptancePolicy, (*AcceptancePolicy).IsMessageAcceptable, "a\xff", false},
+		{"strict message at limit", strict, (*AcceptancePolicy).IsMessageAcceptable, strings.Repeat("a", 10), true},
+		{"strict message over limit", strict, (*AcceptancePolicy).IsMessageAcceptable, strings.Repeat("a", 11), false},
+		{"reason at limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsRejectReasonAcceptable, strings.Repeat("a", MaxRejectReasonLength), true},
+		{"reason over limit", DefaultAcceptancePolicy, (*AcceptancePolicy).IsRejectReasonAcceptable, strings.Repeat("a", MaxRejectReasonLength+1), false},
+		{"strict reason at limit", strict, (*AcceptancePolicy).IsRejectReasonAcceptable, "nope", true},
+		{"strict reason over limit", strict, (*AcceptancePolicy).IsRejectReasonAcceptable, "nope!", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok := test.check(&test.policy, test.text); ok != test.ok {
+				t.Errorf("acceptable is %v, expected %v", ok, test.ok)
+			}
+		})
+	}
+
+	// The package functions use the policy set for the process
+	if err := SetAcceptancePolicy(strict); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAcceptancePolicy(DefaultAcceptancePolicy)
+	if IsNicknameAcceptable("alicia") || IsMessageAcceptable(strings.Repeat("a", 11)) || IsRejectReasonAcceptable("nope!") {
+		t.Error("text over the limits of the current policy was accepted")
+	}
+	if !IsNicknameAcceptable("alice") || !IsMessageAcceptable(strings.Repeat("a", 10)) || !IsRejectReasonAcceptable("nope") {
+		t.Error("text within the limits of the current policy was refused")
+	}
+}
+
+// Policies may be stricter than the protocol but not more permissive, and
+// unset limits are defaults
+func TestAcceptancePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AcceptancePolicy
+		ok     bool
+	}{
+		{"default", DefaultAcceptancePolicy, true},
+		{"unset", AcceptancePolicy{}, true},
+		{"minimum", AcceptancePolicy{MaxNicknameLength: 1, MaxMessageLength: 1, MaxRejectReasonLength: 1}, true},
+		{"maximum", AcceptancePolicy{MaxNicknameLength: MaxNicknameLength, MaxMessageLength: MaxMessageLength, MaxRejectReasonLength: MaxMessageLength}, true},
+		{"nickname too long", AcceptancePolicy{MaxNicknameLength: MaxNicknameLength + 1}, false},
+		{"message too long", AcceptancePolicy{MaxMessageLength: MaxMessageLength + 1}, false},
+		{"reason too long", AcceptancePolicy{MaxRejectReasonLength: MaxMessageLength + 1}, false},
+		{"negative", AcceptancePolicy{MaxMessageLength: -1}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := test.policy.withDefaults()
+			if err := policy.Validate(); (err == nil) != test.ok {
+				t.Errorf("error is %v, expected valid %v", err, test.ok)
+			}
+			if test.policy.MaxNicknameLength == 0 && policy.MaxNicknameLength != MaxNicknameLength {
+				t.Errorf("unset nickname length became %d, expected %d", policy.MaxNicknameLength, MaxNicknameLength)
+			}
+		})
+	}
+}
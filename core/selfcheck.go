@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultSelfCheckInterval is used when Ricochet.SelfCheckInterval is unset
+const DefaultSelfCheckInterval = time.Hour
+
+const (
+	// Delay before the first self-check, to give tor time to publish the
+	// onion service descriptor
+	selfCheckDelay = 2 * time.Minute
+	// Interval between self-checks while the service is unreachable
+	selfCheckRetryInterval = 10 * time.Minute
+	// Maximum time for a self-check, including waiting for the network
+	selfCheckTimeout = 5 * time.Minute
+)
+
+// Goroutine to periodically connect to our own onion service through tor. If
+// the registration has gone stale, for example because the descriptor expired
+// or tor lost the service, contacts can't connect to us and nothing else would
+// notice. Failures are reported in the network status, and the service is
+// re-published once in an attempt to recover.
+func (me *Identity) checkServiceReachable(service *OnionService) {
+	stop := me.contactList.stop
+	delay := selfCheckDelay
+	republished := false
+	for {
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := me.connectToSelf(ctx)
+		cancel()
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		me.core.Network.reportServiceResult(err)
+
+		if err == nil {
+			delay = me.core.SelfCheckInterval
+			republished = false
+			continue
+		}
+
+		log.Printf("Our onion service could not be reached through tor: %v", err)
+		delay = selfCheckRetryInterval
+		if !republished {
+			republished = true
+			if err := me.core.Network.RepublishOnionService(service); err != nil {
+				log.Printf("Re-publishing onion service failed: %v", err)
+			}
+		}
+		log.Printf("Contacts may be unable to connect to us. Check that the system clock is correct and that tor is connected, or restart tor")
+	}
+}
+
+// Make one connection to our own onion service, which succeeds only if tor
+// was able to find the service descriptor and reach us
+func (me *Identity) connectToSelf(ctx context.Context) error {
+	hostname, ok := OnionFromAddress(me.Address())
+	if !ok {
+		return errors.New("Invalid identity address")
+	}
+
+	// Not limited like connections to contacts, which would delay the check
+	connector := me.core.newOnionConnector()
+	connector.Limiter = nil
+	conn, err := connector.Connect(net.JoinHostPort(hostname, strconv.Itoa(me.core.ServicePort)), ctx)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum time to wait for a launched tor to open its control port
+const torStartTimeout = 30 * time.Second
+
+// TorProcess launches and manages a tor instance owned by this process, for
+// use instead of an externally configured tor. The control port uses cookie
+// authentication and is only reachable locally; onion services are published
+// through it by Network as usual.
+type TorProcess struct {
+	// Path of the tor executable; if empty, "tor" is found in PATH
+	Path string
+	// Directory for tor's state, which is created if necessary. Keeping this
+	// between runs lets tor start much faster.
+	DataDir string
+
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// Start launches tor and waits until its control port is available, returning
+// the control address for use with Network.SetControlAddress.
+func (tp *TorProcess) Start() (string, error) {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	if tp.cmd != nil {
+		return "", errors.New("Tor is already running")
+	}
+
+	path := tp.Path
+	if path == "" {
+		path = "tor"
+	}
+	dataDir, err := filepath.Abs(tp.DataDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return "", err
+	}
+
+	portFile := filepath.Join(dataDir, "control-port")
+	os.Remove(portFile)
+	// An empty torrc, so the system configuration doesn't interfere
+	torrc := filepath.Join(dataDir, "torrc")
+	if err := ioutil.WriteFile(torrc, nil, 0600); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(path,
+		"-f", torrc,
+		"--ignore-missing-torrc",
+		"DataDirectory", dataDir,
+		"ControlPort", "auto",
+		"ControlPortWriteToFile", portFile,
+		"CookieAuthentication", "1",
+		"SocksPort", "auto",
+		// Tor exits if this process does
+		"__OwningControllerProcess", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	log.Printf("Started tor process %d", cmd.Process.Pid)
+
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		log.Printf("Tor process exited: %v", err)
+		close(exited)
+	}()
+	tp.cmd = cmd
+	tp.exited = exited
+
+	address, err := waitForControlPortFile(portFile, exited)
+	if err != nil {
+		tp.stop()
+		return "", err
+	}
+	return address, nil
+}
+
+// Poll for the file written by tor with its control port address
+func waitForControlPortFile(path string, exited <-chan struct{}) (string, error) {
+	timeout := time.After(torStartTimeout)
+	for {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			// Written as "PORT=127.0.0.1:1234\n"
+			line := strings.TrimSpace(string(data))
+			if strings.HasPrefix(line, "PORT=") {
+				return line[5:], nil
+			}
+		}
+
+		select {
+		case <-exited:
+			return "", errors.New("Tor exited during startup")
+		case <-timeout:
+			return "", fmt.Errorf("Tor did not start within %v", torStartTimeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop kills the tor process and waits for it to exit. Onion services are
+// removed by tor when the process exits.
+func (tp *TorProcess) Stop() {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	tp.stop()
+}
+
+// Assumes mutex is held
+func (tp *TorProcess) stop() {
+	if tp.cmd == nil {
+		return
+	}
+
+	tp.cmd.Process.Kill()
+	<-tp.exited
+	tp.cmd = nil
+	tp.exited = nil
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"github.com/s-rah/go-ricochet/channels"
+	ricochetutils "github.com/s-rah/go-ricochet/utils"
+	"github.com/s-rah/go-ricochet/wire/control"
+	"log"
+	"time"
+)
+
+// Channel type for typing notifications. Each packet is a single byte, which is
+// 1 while the sender is typing and 0 when they've stopped.
+const typingChannelType = "im.ricochet.typing"
+
+const (
+	// While the user keeps typing, the contact is notified at most this often,
+	// so notifications don't reveal the timing of keystrokes.
+	typingRefreshInterval = 5 * time.Second
+	// The contact's typing indicator is cleared if it isn't renewed for this long.
+	typingTimeout = 3 * typingRefreshInterval
+)
+
+// SetTyping sends the user's typing state to the contact, if the contact is
+// connected and is not a request, rejected, or blocked. Repeated calls with the
+// same state are only sent once per typingRefreshInterval.
+func (c *Conversation) SetTyping(typing bool) error {
+	c.mutex.Lock()
+	if typing == c.localTyping && (!typing || time.Since(c.localTypingSent) < typingRefreshInterval) {
+		c.mutex.Unlock()
+		return nil
+	}
+	if !c.typingAllowed() {
+		c.mutex.Unlock()
+		return nil
+	}
+	conn := c.Contact.Connection()
+	if conn == nil {
+		c.localTyping = false
+		c.mutex.Unlock()
+		return nil
+	}
+	// Inbound handlers lock the mutex from the Process routine, so it can't
+	// be held while waiting for conn.Do
+	c.mutex.Unlock()
+
+	err := conn.Do(func() error {
+		channel := conn.Channel(typingChannelType, channels.Outbound)
+		if channel == nil {
+			ch, err := conn.RequestOpenChannel(typingChannelType, &typingChannel{conversation: c})
+			if err != nil {
+				return err
+			}
+			channel = ch
+		}
+		handler, ok := channel.Handler.(*typingChannel)
+		if !ok {
+			channel.CloseChannel()
+			return nil
+		}
+		handler.send(typing)
+		return nil
+	})
+	if err != nil {
+		log.Printf("typing notification failed: %s", err)
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.localTyping = typing
+	c.localTypingSent = time.Now()
+	return nil
+}
+
+// Typing notifications are only exchanged with established contacts
+func (c *Conversation) typingAllowed() bool {
+	data := c.Contact.Data()
+	return data.Request == nil && !data.Blocked && data.Status != ricochet.Contact_REJECTED
+}
+
+// Set the typing state of the contact, and publish an event if it changed.
+// Assumes c.mutex is held.
+func (c *Conversation) setRemoteTyping(typing bool) {
+	if c.remoteTypingTimer != nil {
+		c.remoteTypingTimer.Stop()
+		c.remoteTypingTimer = nil
+	}
+	if typing {
+		var timer *time.Timer
+		timer = time.AfterFunc(typingTimeout, func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			if c.remoteTypingTimer == timer {
+				c.setRemoteTyping(false)
+			}
+		})
+		c.remoteTypingTimer = timer
+	}
+
+	if typing != c.remoteTyping {
+		c.remoteTyping = typing
+		event := ricochet.ConversationEvent{
+			Type:   ricochet.ConversationEvent_TYPING,
+			Entity: c.remoteEntity,
+			Typing: typing,
+		}
+		c.events.Publish(event)
+	}
+}
+
+// resetTyping clears the typing state in both directions, which is called when
+// the connection to the contact is lost.
+func (c *Conversation) resetTyping() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.localTyping = false
+	c.setRemoteTyping(false)
+}
+
+func (c *Conversation) typingNotification(typing bool) {
+	if c.isContactRemoved() || !c.typingAllowed() {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.setRemoteTyping(typing)
+}
+
+// typingChannel implements channels.Handler for typing notifications
+type typingChannel struct {
+	conversation *Conversation
+	channel      *channels.Channel
+	// State to send once a pending outbound channel is opened
+	pendingState []byte
+}
+
+func (tc *typingChannel) Type() string {
+	return typingChannelType
+}
+
+func (tc *typingChannel) Closed(err error) {
+}
+
+func (tc *typingChannel) OnlyClientCanOpen() bool {
+	return false
+}
+
+func (tc *typingChannel) Singleton() bool {
+	return true
+}
+
+func (tc *typingChannel) Bidirectional() bool {
+	return false
+}
+
+func (tc *typingChannel) RequiresAuthentication() string {
+	return "im.ricochet.auth.hidden-service"
+}
+
+func (tc *typingChannel) OpenInbound(channel *channels.Channel, raw *Protocol_Data_Control.OpenChannel) ([]byte, error) {
+	tc.channel = channel
+	channel.Pending = false
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.AckOpenChannel(channel.ID), nil
+}
+
+func (tc *typingChannel) OpenOutbound(channel *channels.Channel) ([]byte, error) {
+	tc.channel = channel
+	messageBuilder := new(ricochetutils.MessageBuilder)
+	return messageBuilder.OpenChannel(channel.ID, tc.Type()), nil
+}
+
+func (tc *typingChannel) OpenOutboundResult(err error, crm *Protocol_Data_Control.ChannelResult) {
+	tc.conversation.Contact.outboundChannelResult(typingChannelType, err, crm)
+	if err == nil && crm.GetOpened() {
+		tc.channel.Pending = false
+		if tc.pendingState != nil {
+			tc.channel.SendMessage(tc.pendingState)
+			tc.pendingState = nil
+		}
+	}
+}
+
+func (tc *typingChannel) Packet(data []byte) {
+	if tc.channel.Direction != channels.Inbound || len(data) != 1 {
+		return
+	}
+	tc.conversation.typingNotification(data[0] == 1)
+}
+
+func (tc *typingChannel) send(typing bool) {
+	data := []byte{0}
+	if typing {
+		data[0] = 1
+	}
+	if tc.channel.Pending {
+		tc.pendingState = data
+	} else {
+		tc.channel.SendMessage(data)
+	}
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// SetTyping must not hold the conversation's mutex while waiting for the
+// connection's Process routine, which locks it to handle typing packets.
+func TestSetTypingReleasesMutex(t *testing.T) {
+	core := newTestCore(t)
+	contact := newTestContact(t, core, "ricochet:bbbbbbbbbbbbbbbb")
+	conn := newTestConnection(t, "bbbbbbbbbbbbbbbb")
+	contact.connection = conn
+	conversation := contact.Conversation()
+
+	result := make(chan error, 1)
+	go func() { result <- conversation.SetTyping(true) }()
+	// Give SetTyping time to block in conn.Do, which waits for Process
+	time.Sleep(10 * time.Millisecond)
+
+	handled := make(chan struct{})
+	go func() {
+		conversation.typingNotification(true)
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("typing notification blocked on the conversation's mutex")
+	}
+
+	go conn.Process(&testConnectionHandler{})
+	if err := <-result; err != nil {
+		t.Fatalf("SetTyping failed: %v", err)
+	}
+	conversation.mutex.Lock()
+	defer conversation.mutex.Unlock()
+	if !conversation.localTyping || !conversation.remoteTyping {
+		t.Errorf("typing state is %v locally and %v remotely, expected both", conversation.localTyping, conversation.remoteTyping)
+	}
+}
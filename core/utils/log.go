@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel is the severity of a log message. Messages below a Logger's level
+// are discarded.
+type LogLevel int32
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+var logLevelNames = []string{"debug", "info", "warning", "error"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || l > LogError {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel returns the level named by s, which is one of debug, info,
+// warning, or error
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogInfo, errors.New("Unknown log level")
+}
+
+// Logger writes messages at or above a level, which can be changed at any
+// time, to the standard log package's output. Each message is prefixed with
+// its level. A nil Logger is usable, and logs at LogInfo.
+type Logger struct {
+	level int32
+}
+
+func NewLogger(level LogLevel) *Logger {
+	return &Logger{level: int32(level)}
+}
+
+// Level returns the lowest level of messages that are logged
+func (l *Logger) Level() LogLevel {
+	if l == nil {
+		return LogInfo
+	}
+	return LogLevel(atomic.LoadInt32(&l.level))
+}
+
+func (l *Logger) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *Logger) output(level LogLevel, format string, v ...interface{}) {
+	if level < l.Level() {
+		return
+	}
+	log.Output(3, level.String()+": "+fmt.Sprintf(format, v...))
+}
+
+// Debugf logs details that are only useful when debugging connections
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.output(LogDebug, format, v...)
+}
+
+// Infof logs events in normal operation
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.output(LogInfo, format, v...)
+}
+
+// Warnf logs failures that are expected to recover, like lost connections
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.output(LogWarning, format, v...)
+}
+
+// Errorf logs failures that need attention, and bugs
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.output(LogError, format, v...)
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// PassphraseCipher encrypts data with a key derived from a passphrase, for
+// files that are identified by a magic string. Encrypted data is:
+//
+//	magic | iterations (4) | salt (16) | nonce (12) | AES-256-GCM ciphertext
+//
+// The header before the nonce is authenticated as additional data. The key is
+// derived once, and every Seal uses a new nonce.
+type PassphraseCipher struct {
+	header []byte
+	aead   cipher.AEAD
+}
+
+const (
+	// PBKDF2 iterations used for new ciphers. Up to 100 times as many are
+	// accepted when opening data, which allows for stronger settings later
+	// without letting a damaged header make us derive a key forever.
+	PassphraseIterations = 200000
+	passphraseSaltSize   = 16
+)
+
+var (
+	NotPassphraseEncryptedError error = errors.New("Data is not encrypted with a passphrase")
+	WrongPassphraseError        error = errors.New("Wrong passphrase or damaged data")
+)
+
+// IsPassphraseEncrypted returns true if data begins with magic
+func IsPassphraseEncrypted(data []byte, magic string) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// NewPassphraseCipher derives a key from passphrase with a new random salt
+func NewPassphraseCipher(magic, passphrase string) (*PassphraseCipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("Passphrase is empty")
+	}
+	header := make([]byte, len(magic)+4+passphraseSaltSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(magic):], PassphraseIterations)
+	if _, err := cryptorand.Read(header[len(magic)+4:]); err != nil {
+		return nil, err
+	}
+	return passphraseCipherFromHeader(header, len(magic), passphrase)
+}
+
+func passphraseCipherFromHeader(header []byte, magicSize int, passphrase string) (*PassphraseCipher, error) {
+	iterations := binary.BigEndian.Uint32(header[magicSize:])
+	if iterations < 1 || iterations > 100*PassphraseIterations {
+		return nil, NotPassphraseEncryptedError
+	}
+	salt := header[magicSize+4:]
+	block, err := aes.NewCipher(PBKDF2SHA256([]byte(passphrase), salt, int(iterations), 32))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PassphraseCipher{header: append([]byte(nil), header...), aead: aead}, nil
+}
+
+// OpenWithPassphrase returns the plaintext of data encrypted by a
+// PassphraseCipher for magic, along with a cipher that encrypts with the same
+// key. It returns NotPassphraseEncryptedError if data doesn't have a valid
+// header, and WrongPassphraseError if it can't be decrypted.
+func OpenWithPassphrase(data []byte, magic, passphrase string) ([]byte, *PassphraseCipher, error) {
+	headerSize := len(magic) + 4 + passphraseSaltSize
+	if len(data) < headerSize || !IsPassphraseEncrypted(data, magic) {
+		return nil, nil, NotPassphraseEncryptedError
+	}
+	c, err := passphraseCipherFromHeader(data[:headerSize], len(magic), passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(data) < headerSize+nonceSize {
+		return nil, nil, WrongPassphraseError
+	}
+	nonce := data[headerSize : headerSize+nonceSize]
+	plaintext, err := c.aead.Open(nil, nonce, data[headerSize+nonceSize:], c.header)
+	if err != nil {
+		return nil, nil, WrongPassphraseError
+	}
+	return plaintext, c, nil
+}
+
+// Seal returns plaintext encrypted with a new nonce
+func (c *PassphraseCipher) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return nil, err
+	}
+	data := append(append([]byte(nil), c.header...), nonce...)
+	return c.aead.Seal(data, nonce, plaintext, c.header), nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Sealed data opens only with the same magic and passphrase, and a damaged
+// header or ciphertext is refused
+func TestPassphraseCipher(t *testing.T) {
+	const magic = "TEST-MAGIC"
+	plaintext := []byte("sealed contents")
+
+	c, err := NewPassphraseCipher(magic, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPassphraseEncrypted(sealed, magic) {
+		t.Fatal("sealed data doesn't begin with magic")
+	}
+
+	damaged := func(offset int, value byte) []byte {
+		data := append([]byte(nil), sealed...)
+		data[offset] ^= value
+		return data
+	}
+	iterations := func(n uint32) []byte {
+		data := append([]byte(nil), sealed...)
+		binary.BigEndian.PutUint32(data[len(magic):], n)
+		return data
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		magic      string
+		passphrase string
+		expected   error
+	}{
+		{"correct passphrase", sealed, magic, "correct horse", nil},
+		{"wrong passphrase", sealed, magic, "battery staple", WrongPassphraseError},
+		{"other magic", sealed, "OTHER-MAGIC", "correct horse", NotPassphraseEncryptedError},
+		{"truncated header", sealed[:len(magic)+8], magic, "correct horse", NotPassphraseEncryptedError},
+		{"truncated nonce", sealed[:len(magic)+24], magic, "correct horse", WrongPassphraseError},
+		{"no iterations", iterations(0), magic, "correct horse", NotPassphraseEncryptedError},
+		{"too many iterations", iterations(100*PassphraseIterations + 1), magic, "correct horse", NotPassphraseEncryptedError},
+		{"damaged salt", damaged(len(magic)+4, 1), magic, "correct horse", WrongPassphraseError},
+		{"damaged ciphertext", damaged(len(sealed)-1, 1), magic, "correct horse", WrongPassphraseError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opened, reopened, err := OpenWithPassphrase(test.data, test.magic, test.passphrase)
+			if err != test.expected {
+				t.Fatalf("open returned %v, expected %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("opened %q, expected %q", opened, plaintext)
+			}
+
+			// The returned cipher seals with the same key and a new nonce
+			resealed, err := reopened.Seal(plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(resealed, sealed) {
+				t.Fatal("resealing reused the nonce")
+			}
+			if opened, _, err := OpenWithPassphrase(resealed, magic, "correct horse"); err != nil || !bytes.Equal(opened, plaintext) {
+				t.Fatalf("resealed data opened as %q, %v", opened, err)
+			}
+		})
+	}
+}
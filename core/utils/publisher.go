@@ -1,5 +1,11 @@
 package utils
 
+// Publisher broadcasts values to any number of subscribers. Each subscriber has
+// its own buffered channel, and publishing never waits for subscribers to read.
+// A subscriber that falls so far behind that its buffer is full is dropped: its
+// channel is closed after the values already buffered, and it receives nothing
+// more. Subscribers must treat a closed channel as having missed events, e.g. by
+// resubscribing and fetching the current state again.
 type Publisher struct {
 	subscribeChannel   chan chan interface{}
 	unsubscribeChannel chan (<-chan interface{})
@@ -25,6 +31,9 @@ func CreatePublisher() *Publisher {
 	return re
 }
 
+// Subscribe returns a channel that receives all values published from now on.
+// Up to queueSize values are buffered for the subscriber; if it falls further
+// behind than that, the channel is closed and the subscriber is dropped.
 func (pub *Publisher) Subscribe(queueSize int) <-chan interface{} {
 	channel := make(chan interface{}, queueSize)
 	pub.subscribeChannel <- channel
@@ -33,6 +42,8 @@ func (pub *Publisher) Subscribe(queueSize int) <-chan interface{} {
 	return channel
 }
 
+// Unsubscribe stops sending values to channel, and waits for it to be closed.
+// It's safe to call for a subscriber that has been dropped.
 func (pub *Publisher) Unsubscribe(channel <-chan interface{}) {
 	pub.unsubscribeChannel <- channel
 	// Wait for channel close
@@ -44,6 +55,8 @@ func (pub *Publisher) Unsubscribe(channel <-chan interface{}) {
 	}
 }
 
+// Publish sends value to all subscribers. It doesn't block on subscribers that
+// are slow to read.
 func (pub *Publisher) Publish(value interface{}) {
 	pub.broadcastChannel <- value
 }
@@ -78,13 +91,21 @@ func (pub *Publisher) broadcast() {
 				break
 			}
 		case value := <-pub.broadcastChannel:
+			// Drop subscribers with a full queue immediately, so they never
+			// receive later values after missing this one
+			kept := channels[:0]
 			for _, c := range channels {
 				select {
 				case c <- value:
+					kept = append(kept, c)
 				default:
-					go pub.Unsubscribe(c)
+					close(c)
 				}
 			}
+			for i := len(kept); i < len(channels); i++ {
+				channels[i] = nil
+			}
+			channels = kept
 		case <-pub.closeChannel:
 			for _, c := range channels {
 				close(c)
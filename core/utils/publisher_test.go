@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// A stalled subscriber receives the values that fit in its queue and is then
+// dropped, without holding up publishers or other subscribers
+func TestPublisherStalledSubscriber(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		published int
+	}{
+		{"within queue", 4, 4},
+		{"beyond queue", 4, 10},
+		{"unbuffered", 0, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pub := CreatePublisher()
+			defer pub.Close()
+			stalled := pub.Subscribe(test.queueSize)
+			reader := pub.Subscribe(1)
+
+			for i := 0; i < test.published; i++ {
+				published := make(chan struct{})
+				go func(value int) {
+					pub.Publish(value)
+					close(published)
+				}(i)
+				select {
+				case value := <-reader:
+					if value != i {
+						t.Fatalf("reader received %v, expected %d", value, i)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("reader didn't receive value %d", i)
+				}
+				<-published
+			}
+
+			// Values are sent to subscribers in the order they subscribed, so
+			// everything for the stalled subscriber has been sent by now
+			var received []interface{}
+			closed := false
+		drain:
+			for {
+				select {
+				case value, ok := <-stalled:
+					if !ok {
+						closed = true
+						break drain
+					}
+					received = append(received, value)
+				default:
+					break drain
+				}
+			}
+			expected := test.published
+			if expected > test.queueSize {
+				expected = test.queueSize
+			}
+			if len(received) != expected {
+				t.Errorf("stalled subscriber received %d values, expected %d", len(received), expected)
+			}
+			for i, value := range received {
+				if value != i {
+					t.Errorf("stalled subscriber received %v, expected %d", value, i)
+				}
+			}
+			if dropped := test.published > test.queueSize; closed != dropped {
+				t.Errorf("stalled subscriber's channel closed is %v, expected %v", closed, dropped)
+			}
+
+			// Unsubscribing is still safe after being dropped
+			pub.Unsubscribe(stalled)
+			pub.Unsubscribe(reader)
+		})
+	}
+}
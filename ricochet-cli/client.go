@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"github.com/ricochet-im/ricochet-go/core"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"golang.org/x/net/context"
 	"log"
@@ -12,9 +13,14 @@ type Client struct {
 
 	ServerStatus ricochet.ServerStatusReply
 	Identity     ricochet.Identity
+	// Rules for text sent to the backend, from the limits in ServerStatus
+	Acceptance core.AcceptancePolicy
 
 	NetworkStatus ricochet.NetworkStatus
 	Contacts      *ContactList
+	Transfers     *FileTransferList
+	// Limits for the message backlog of each conversation
+	Backlog BacklogLimits
 
 	monitorsChannel chan interface{}
 	blockChannel    chan struct{}
@@ -29,6 +35,7 @@ type Client struct {
 // XXX need to handle backend connection loss/reconnection..
 func (c *Client) Initialize() error {
 	c.Contacts = NewContactList(c)
+	c.Transfers = NewFileTransferList(c)
 	c.monitorsChannel = make(chan interface{}, 10)
 	c.blockChannel = make(chan struct{})
 	c.unblockChannel = make(chan struct{})
@@ -45,6 +52,15 @@ func (c *Client) Initialize() error {
 	if status.RpcVersion != 1 {
 		return fmt.Errorf("unsupported backend RPC version %d", status.RpcVersion)
 	}
+	c.Acceptance = core.DefaultAcceptancePolicy
+	if limits := status.Limits; limits != nil {
+		c.Acceptance.MaxNicknameLength = int(limits.MaxNicknameLength)
+		c.Acceptance.MaxMessageLength = int(limits.MaxMessageLength)
+		c.Acceptance.MaxRejectReasonLength = int(limits.MaxRejectReasonLength)
+		if err := c.Acceptance.Validate(); err != nil {
+			return fmt.Errorf("invalid backend limits: %v", err)
+		}
+	}
 
 	// Query identity
 	identity, err := c.Backend.GetIdentity(context.Background(), &ricochet.IdentityRequest{})
@@ -56,7 +72,7 @@ func (c *Client) Initialize() error {
 	// Spawn routines to query and monitor state changes
 	go c.monitorNetwork()
 	go c.monitorContacts()
-	// Conversation monitor isn't started until contacts are populated
+	// Conversation and file transfer monitors aren't started until contacts are populated
 
 	// Spawn routine to handle all events
 	go c.Run()
@@ -77,6 +93,8 @@ func (c *Client) Run() {
 				c.onContactEvent(event)
 			case *ricochet.ConversationEvent:
 				c.onConversationEvent(event)
+			case *ricochet.FileTransferEvent:
+				c.onFileTransferEvent(event)
 			default:
 				log.Panicf("Unknown event type on monitor channel: %v", event)
 			}
@@ -168,7 +186,15 @@ func (c *Client) monitorConversations() {
 
 func (c *Client) onNetworkStatus(status *ricochet.NetworkStatus) {
 	log.Printf("Network status changed: %v", status)
+	oldSocksStatus := c.NetworkSocksStatus().Status
+	oldServiceStatus := c.NetworkServiceStatus().Status
 	c.NetworkStatus = *status
+	if c.populatedNetwork && oldSocksStatus != c.NetworkSocksStatus().Status {
+		Ui.PrintSocksStatusChange(oldSocksStatus)
+	}
+	if c.populatedNetwork && oldServiceStatus != c.NetworkServiceStatus().Status {
+		Ui.PrintServiceStatusChange(oldServiceStatus)
+	}
 	c.populatedNetwork = true
 	c.checkIfPopulated()
 }
@@ -196,6 +222,11 @@ func (c *Client) onContactEvent(event *ricochet.ContactEvent) {
 				log.Printf("Ignoring contact update event for unknown contact: %v", cData)
 			} else {
 				contact.Updated(cData)
+				if event.Change == ricochet.ContactEvent_REQUEST_ACCEPTED {
+					fmt.Fprintf(Ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1m%s\x1b[0m accepted your contact request. Type \x1b[1m%s\x1b[0m to talk \x1b[31m]]\x1b[39m\n", cData.Nickname, Ui.PrefixForAddress(cData.Address))
+				} else if event.Change == ricochet.ContactEvent_AUTHENTICATION_REJECTED {
+					fmt.Fprintf(Ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1m%s\x1b[0m refused our connection. They may have blocked you, or added a different address \x1b[31m]]\x1b[39m\n", cData.Nickname)
+				}
 			}
 
 		case ricochet.ContactEvent_DELETE:
@@ -214,7 +245,13 @@ func (c *Client) onContactEvent(event *ricochet.ContactEvent) {
 		case ricochet.ContactEvent_ADD:
 			fallthrough
 		case ricochet.ContactEvent_UPDATE:
+			oldData := c.Contacts.Requests[reqData.Address]
 			c.Contacts.Requests[reqData.Address] = reqData
+			if oldData != nil && oldData.Connected != reqData.Connected &&
+				oldData.FromNickname == reqData.FromNickname && oldData.Text == reqData.Text {
+				// Only the requester's connection changed
+				break
+			}
 			fmt.Fprintf(Ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1m%s\x1b[0m wants to be your contact. Type \x1b[1m%s\x1b[0m to respond \x1b[31m]]\x1b[39m\n", reqData.Address, Ui.PrefixForAddress(reqData.Address))
 
 		case ricochet.ContactEvent_DELETE:
@@ -228,6 +265,7 @@ func (c *Client) onContactEvent(event *ricochet.ContactEvent) {
 		log.Printf("Loaded %d contacts and %d requests", len(c.Contacts.Contacts), len(c.Contacts.Requests))
 		c.checkIfPopulated()
 		go c.monitorConversations()
+		go c.monitorFileTransfers()
 	} else {
 		log.Printf("Ignoring event with an unexpected subject")
 	}
@@ -236,6 +274,15 @@ func (c *Client) onContactEvent(event *ricochet.ContactEvent) {
 func (c *Client) onConversationEvent(event *ricochet.ConversationEvent) {
 	message := event.Msg
 
+	if event.Type == ricochet.ConversationEvent_TYPING {
+		if event.Entity == nil {
+			log.Printf("Ignoring invalid typing event: %v", event)
+		} else if contact := c.Contacts.ByAddress(event.Entity.Address); contact != nil {
+			contact.Conversation.SetRemoteTyping(event.Typing)
+		}
+		return
+	}
+
 	if event.Type == ricochet.ConversationEvent_POPULATE && message == nil {
 		c.populatedConversations = true
 		c.checkIfPopulated()
@@ -286,6 +333,22 @@ func (c *Client) NetworkControlStatus() ricochet.TorControlStatus {
 	}
 }
 
+func (c *Client) NetworkSocksStatus() ricochet.TorSocksStatus {
+	if c.NetworkStatus.Socks != nil {
+		return *c.NetworkStatus.Socks
+	} else {
+		return ricochet.TorSocksStatus{}
+	}
+}
+
+func (c *Client) NetworkServiceStatus() ricochet.OnionServiceStatus {
+	if c.NetworkStatus.Service != nil {
+		return *c.NetworkStatus.Service
+	} else {
+		return ricochet.OnionServiceStatus{}
+	}
+}
+
 func (c *Client) NetworkConnectionStatus() ricochet.TorConnectionStatus {
 	if c.NetworkStatus.Connection != nil {
 		return *c.NetworkStatus.Connection
@@ -75,10 +75,52 @@ func (c *Contact) Updated(newData *ricochet.Contact) error {
 		return errors.New("Contact address is immutable")
 	}
 
+	oldData := c.Data
 	c.Data = newData
+
+	if oldData.Request != nil && newData.Request == nil {
+		c.Conversation.AddStatusMessage("Contact request accepted", false)
+	} else if oldData.Request != nil && newData.Request != nil && oldData.Request.Phase != newData.Request.Phase {
+		switch newData.Request.Phase {
+		case ricochet.ContactRequest_DELIVERED:
+			c.Conversation.AddStatusMessage("Contact request delivered", false)
+		case ricochet.ContactRequest_REJECTED:
+			if newData.Request.RemoteError != "" {
+				c.Conversation.AddStatusMessage("Contact request rejected: "+newData.Request.RemoteError, false)
+			} else {
+				c.Conversation.AddStatusMessage("Contact request rejected", false)
+			}
+		case ricochet.ContactRequest_ERROR:
+			c.Conversation.AddStatusMessage("Contact request failed: "+newData.Request.RemoteError, false)
+		}
+	}
+	if oldData.Status != newData.Status {
+		if newData.Status == ricochet.Contact_ONLINE {
+			c.Conversation.AddStatusMessage("Contact is online", false)
+		} else if oldData.Status == ricochet.Contact_ONLINE {
+			c.Conversation.AddStatusMessage("Contact is offline", false)
+		}
+	}
 	return nil
 }
 
+// Channel type used for file transfers, as listed in ContactCapabilities
+const fileTransferChannelType = "im.ricochet.file-transfer"
+
+// SupportsFileTransfer returns false if the contact's client is known not to
+// support file transfers. Contacts that haven't been seen either way might.
+func (c *Contact) SupportsFileTransfer() bool {
+	if c.Data.Capabilities == nil {
+		return true
+	}
+	for _, channelType := range c.Data.Capabilities.Unsupported {
+		if channelType == fileTransferChannelType {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Contact) Deleted() {
 	c.Data = &ricochet.Contact{
 		Address: c.Data.Address,
@@ -3,46 +3,101 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/ricochet-im/ricochet-go/core"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"golang.org/x/net/context"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
-const (
-	maxMessageTextLength = 2000
+// BacklogLimits controls how many messages are kept in each conversation
+type BacklogLimits struct {
 	// Number of messages before the first unread message shown when
 	// opening a conversation
-	backlogContextNum = 3
+	ContextNum int
 	// Maximum number of messages to keep in the backlog. Unread messages
 	// will never be discarded to keep this limit, and at least
-	// backlogContextNum messages are kept before the first unread message.
-	backlogSoftLimit = 100
+	// ContextNum messages are kept before the first unread message.
+	SoftLimit int
 	// Hard limit for the maximum numer of messages to keep in the backlog
-	backlogHardLimit = 200
-)
+	HardLimit int
+}
+
+var DefaultBacklogLimits = BacklogLimits{
+	ContextNum: 3,
+	SoftLimit:  100,
+	HardLimit:  200,
+}
+
+func (bl BacklogLimits) Validate() error {
+	if bl.ContextNum < 0 || bl.SoftLimit < 1 {
+		return errors.New("Backlog limits must be positive")
+	} else if bl.HardLimit < bl.SoftLimit {
+		return errors.New("Backlog hard limit must not be less than the soft limit")
+	} else if bl.ContextNum >= bl.SoftLimit {
+		return errors.New("Backlog context must be less than the soft limit")
+	}
+	return nil
+}
 
+// Conversation is the backlog of messages with a contact. Backend events are
+// handled on the client's event goroutine, while commands and the input line
+// use it from other goroutines, so its state is guarded by mutex. Methods that
+// start with a lowercase letter assume it's held.
 type Conversation struct {
 	Client  *Client
 	Contact *Contact
 
+	mutex     sync.Mutex
 	messages  []*ricochet.Message
 	numUnread int
 	active    bool
+	// End of the page of messages shown by ScrollOlder or ScrollNewer, as an
+	// index into messages, or zero when not scrolled back
+	scrollEnd int
+
+	localTyping     bool
+	localTypingSent time.Time
+	remoteTyping    bool
 }
 
+// Typing notifications are renewed this often while the user keeps typing.
+// The backend limits notifications to the contact independently.
+const typingRefreshInterval = 5 * time.Second
+
 // Send an outbound message to the contact and add that message into the
 // conversation backlog. Blocking API call.
 func (c *Conversation) SendMessage(text string) error {
-	msg, err := c.Client.Backend.SendMessage(context.Background(), &ricochet.Message{
+	return c.SendReply(text, nil)
+}
+
+// Same as SendMessage, but the message is a reply to replyTo, if not nil
+func (c *Conversation) SendReply(text string, replyTo *ricochet.Message) error {
+	if !c.Client.Acceptance.IsMessageAcceptable(text) {
+		err := errors.New("Message is too long or contains invalid characters")
+		fmt.Fprintf(Ui.Stdout, "send message error: %v\n", err)
+		return err
+	}
+
+	request := &ricochet.Message{
 		Sender:    &ricochet.Entity{IsSelf: true},
 		Recipient: &ricochet.Entity{Address: c.Contact.Data.Address},
 		Text:      text,
-	})
+	}
+	if replyTo != nil {
+		request.InReplyTo = &ricochet.MessageReference{Sequence: replyTo.Sequence}
+	}
+	msg, err := c.Client.Backend.SendMessage(context.Background(), request)
 	if err != nil {
 		fmt.Fprintf(Ui.Stdout, "send message error: %v\n", err)
 		return err
 	}
+	// The backend stops the typing indicator when the message is sent
+	c.mutex.Lock()
+	c.localTyping = false
+	c.mutex.Unlock()
 
 	if err := c.validateMessage(msg); err != nil {
 		log.Printf("Conversation sent message does not validate: %v", err)
@@ -60,16 +115,28 @@ func (c *Conversation) AddMessage(msg *ricochet.Message, populating bool) {
 		return
 	}
 
-	c.messages = append(c.messages, msg)
+	c.mutex.Lock()
+	if i := c.findMessage(msg); i >= 0 && msg.Sequence != 0 {
+		// Sent again, e.g. when history is populated while events arrive
+		c.updateMessage(msg)
+		c.mutex.Unlock()
+		return
+	}
+
+	c.insertMessage(msg)
 	if msg.Status == ricochet.Message_UNREAD {
 		c.numUnread++
 	}
 	c.trimBacklog()
+	markRead := false
 	if !populating {
 		c.printMessage(msg)
-		if c.active {
-			c.MarkAsReadBefore(msg)
-		}
+		markRead = c.active
+	}
+	c.mutex.Unlock()
+
+	if markRead {
+		c.MarkAsReadBefore(msg)
 	}
 }
 
@@ -79,51 +146,149 @@ func (c *Conversation) UpdateMessage(updatedMsg *ricochet.Message) {
 		return
 	}
 
-	for i := len(c.messages) - 1; i >= 0; i-- {
-		msg := c.messages[i]
-		if msg.Sender.IsSelf != updatedMsg.Sender.IsSelf ||
-			msg.Identifier != updatedMsg.Identifier {
-			continue
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.updateMessage(updatedMsg)
+}
+
+func (c *Conversation) updateMessage(updatedMsg *ricochet.Message) {
+	i := c.findMessage(updatedMsg)
+	if i < 0 {
+		log.Printf("Ignoring message update for unknown message: %v", updatedMsg)
+		return
+	}
+	msg := c.messages[i]
+
+	if msg.Status == ricochet.Message_UNREAD &&
+		updatedMsg.Status != ricochet.Message_UNREAD {
+		c.numUnread--
+	}
+
+	c.messages[i] = updatedMsg
+	if updatedMsg.Sender.IsSelf && msg.Status != updatedMsg.Status {
+		c.printDeliveryUpdate(updatedMsg)
+	}
+}
+
+// insertMessage adds msg to the backlog in order of sequence, which the
+// backend assigns in the order messages were added to the conversation, so
+// the backlog stays in that order even if events arrive out of order. Status
+// notices have no sequence, and stay before messages that are newer than them.
+// Messages without a sequence, from older backends, are kept in arrival order.
+func (c *Conversation) insertMessage(msg *ricochet.Message) {
+	i := len(c.messages)
+	for ; i > 0 && msg.Sequence != 0; i-- {
+		other := c.messages[i-1]
+		if isStatusMessage(other) {
+			if other.Timestamp <= msg.Timestamp {
+				break
+			}
+		} else if other.Sequence < msg.Sequence {
+			break
 		}
+	}
+
+	c.messages = append(c.messages, nil)
+	copy(c.messages[i+1:], c.messages[i:])
+	c.messages[i] = msg
+	if i < c.scrollEnd {
+		// Keep the same page when scrolled back
+		c.scrollEnd++
+	}
+}
 
-		if msg.Status == ricochet.Message_UNREAD &&
-			updatedMsg.Status != ricochet.Message_UNREAD {
-			c.numUnread--
+// Find the index of the backlog message that msg is an update of, or -1
+func (c *Conversation) findMessage(msg *ricochet.Message) int {
+	if msg.Sequence != 0 {
+		for i := len(c.messages) - 1; i >= 0; i-- {
+			if c.messages[i].Sequence == msg.Sequence && !isStatusMessage(c.messages[i]) {
+				return i
+			}
 		}
+		return -1
+	}
 
-		c.messages[i] = updatedMsg
-		return
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		other := c.messages[i]
+		if !isStatusMessage(other) &&
+			other.Sender.IsSelf == msg.Sender.IsSelf &&
+			other.Identifier == msg.Identifier {
+			return i
+		}
 	}
 
-	log.Printf("Ignoring message update for unknown message: %v", updatedMsg)
+	if !msg.Sender.IsSelf {
+		return -1
+	}
+	// The backend assigns a new identifier when a queued message is sent, so
+	// outbound messages still in progress are also matched by their content.
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		other := c.messages[i]
+		if !isStatusMessage(other) && other.Sender.IsSelf &&
+			(other.Status == ricochet.Message_QUEUED || other.Status == ricochet.Message_SENDING) &&
+			other.Timestamp == msg.Timestamp && other.Text == msg.Text {
+			return i
+		}
+	}
+	return -1
 }
 
-// XXX
+// Add a status notice, such as a change in the contact's connection, to the
+// conversation. These are kept in the backlog as messages with no sender and a
+// NULL status, which are never sent to or updated by the backend. If 'backlog'
+// is true, the notice is only added to the backlog and not printed.
 func (c *Conversation) AddStatusMessage(text string, backlog bool) {
+	msg := &ricochet.Message{
+		Timestamp: time.Now().Unix(),
+		Status:    ricochet.Message_NULL,
+		Text:      text,
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.messages = append(c.messages, msg)
+	c.trimBacklog()
+	if !backlog {
+		c.printMessage(msg)
+	}
+}
+
+func isStatusMessage(msg *ricochet.Message) bool {
+	return msg.Sender == nil && msg.Status == ricochet.Message_NULL
 }
 
 // Mark all unread messages in this conversation as read on the backend.
+// Blocking API call.
 func (c *Conversation) MarkAsRead() error {
-	// Get the identifier of the last received message
-	var lastRecvMsg *ricochet.Message
+	c.mutex.Lock()
+	lastRecvMsg := c.lastUnreadMessage()
+	c.mutex.Unlock()
+
+	if lastRecvMsg != nil {
+		return c.MarkAsReadBefore(lastRecvMsg)
+	}
+	return nil
+}
 
-findMessageId:
+// lastUnreadMessage returns the last received message if it's unread, or nil.
+// Assumes c.mutex is held.
+func (c *Conversation) lastUnreadMessage() *ricochet.Message {
 	for i := len(c.messages) - 1; i >= 0; i-- {
 		switch c.messages[i].Status {
 		case ricochet.Message_UNREAD:
-			lastRecvMsg = c.messages[i]
-			break findMessageId
+			return c.messages[i]
 		case ricochet.Message_READ:
-			break findMessageId
+			return nil
 		}
 	}
-
-	if lastRecvMsg != nil {
-		return c.MarkAsReadBefore(lastRecvMsg)
-	}
 	return nil
 }
 
+// MarkAsReadBefore marks unread messages up to and including message as read
+// on the backend. It doesn't use the conversation's state, and backlog messages
+// are replaced rather than modified by updates, so message can be taken from
+// the backlog under the mutex and used after it's unlocked. Blocking API call,
+// which must not be made with the mutex held.
 func (c *Conversation) MarkAsReadBefore(message *ricochet.Message) error {
 	if err := c.validateMessage(message); err != nil {
 		return err
@@ -131,13 +296,13 @@ func (c *Conversation) MarkAsReadBefore(message *ricochet.Message) error {
 		return errors.New("Outbound messages cannot be marked as read")
 	}
 
-	// XXX This probably means it's impossible to mark messages as read
-	// if the sender uses 0 identifiers. We really should not use actual
-	// protocol identifiers in RPC API.
+	// Backends that don't set a sequence can only find the message by its
+	// protocol identifier
 	_, err := c.Client.Backend.MarkConversationRead(context.Background(),
 		&ricochet.MarkConversationReadRequest{
 			Entity:             message.Sender,
 			LastRecvIdentifier: message.Identifier,
+			LastRecvSequence:   message.Sequence,
 		})
 	if err != nil {
 		log.Printf("Mark conversation read failed: %v", err)
@@ -146,11 +311,18 @@ func (c *Conversation) MarkAsReadBefore(message *ricochet.Message) error {
 }
 
 func (c *Conversation) PrintContext() {
-	// Print starting from backlogContextNum messages before the first unread
-	start := len(c.messages) - backlogContextNum
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.printContext()
+}
+
+func (c *Conversation) printContext() {
+	// Print starting from ContextNum messages before the first unread
+	contextNum := c.Client.Backlog.ContextNum
+	start := len(c.messages) - contextNum
 	for i, message := range c.messages {
 		if message.Status == ricochet.Message_UNREAD {
-			start = i - backlogContextNum
+			start = i - contextNum
 			break
 		}
 	}
@@ -165,11 +337,17 @@ func (c *Conversation) PrintContext() {
 }
 
 func (c *Conversation) trimBacklog() {
-	if len(c.messages) > backlogHardLimit {
-		c.messages = c.messages[len(c.messages)-backlogHardLimit:]
+	limits := c.Client.Backlog
+	if len(c.messages) > limits.HardLimit {
+		c.trimmed(len(c.messages) - limits.HardLimit)
+		c.messages = c.messages[len(c.messages)-limits.HardLimit:]
+		oldUnread := c.numUnread
 		c.recountUnread()
+		if discarded := oldUnread - c.numUnread; discarded > 0 {
+			log.Printf("Discarded %d unread messages from %s over the backlog limit", discarded, c.Contact.Data.Address)
+		}
 	}
-	if len(c.messages) <= backlogSoftLimit {
+	if len(c.messages) <= limits.SoftLimit {
 		return
 	}
 
@@ -177,13 +355,13 @@ func (c *Conversation) trimBacklog() {
 	var keepIndex int
 	for i, message := range c.messages {
 		if message.Status == ricochet.Message_UNREAD {
-			// Keep backlogContextNum messages before the first unread one
-			keepIndex = i - backlogContextNum
+			// Keep ContextNum messages before the first unread one
+			keepIndex = i - limits.ContextNum
 			if keepIndex < 0 {
 				keepIndex = 0
 			}
 			break
-		} else if len(c.messages)-i <= backlogSoftLimit {
+		} else if len(c.messages)-i <= limits.SoftLimit {
 			// Remove all messages before this one to reduce to the limit
 			keepIndex = i
 			break
@@ -191,6 +369,18 @@ func (c *Conversation) trimBacklog() {
 	}
 
 	c.messages = c.messages[keepIndex:]
+	c.trimmed(keepIndex)
+}
+
+// trimmed adjusts the scroll position after n messages were removed from the
+// start of the backlog
+func (c *Conversation) trimmed(n int) {
+	if c.scrollEnd == 0 {
+		return
+	}
+	if c.scrollEnd -= n; c.scrollEnd < 1 {
+		c.scrollEnd = 1
+	}
 }
 
 // Validate that a message object is well-formed, sane, and belongs
@@ -219,15 +409,20 @@ func (c *Conversation) validateMessage(msg *ricochet.Message) error {
 		return fmt.Errorf("Invalid remote entity on message: %v", remoteEntity)
 	}
 
-	// XXX timestamp
-	// XXX identifier
+	if msg.Timestamp <= 0 {
+		return fmt.Errorf("Message has invalid timestamp: %v", msg)
+	}
+
+	// The protocol identifier may be zero or reused, so it isn't checked;
+	// messages are matched by sequence
 
 	if msg.Status == ricochet.Message_NULL {
 		return fmt.Errorf("Message has null status: %v", msg)
 	}
 
-	// XXX more sanity checks on message text?
-	if len(msg.Text) == 0 || len(msg.Text) > maxMessageTextLength {
+	// Messages are checked against the protocol limits rather than the backend's
+	// policy, which may have changed since older messages were received
+	if !core.DefaultAcceptancePolicy.IsMessageAcceptable(msg.Text) {
 		return errors.New("Message text is unacceptable")
 	}
 
@@ -235,8 +430,15 @@ func (c *Conversation) validateMessage(msg *ricochet.Message) error {
 }
 
 func (c *Conversation) printMessage(msg *ricochet.Message) {
+	if isStatusMessage(msg) {
+		if c.active {
+			fmt.Fprintf(Ui.Stdout, "%s\n", c.formatMessage(msg))
+		}
+		return
+	}
+
 	if !c.active {
-		if msg.Sender.IsSelf {
+		if msg.Sender.IsSelf || c.Contact.Data.Muted {
 			return
 		}
 		messages := fmt.Sprintf("%d new message", c.numUnread)
@@ -247,8 +449,15 @@ func (c *Conversation) printMessage(msg *ricochet.Message) {
 		return
 	}
 
-	// XXX actual timestamp
-	ts := "\x1b[90m" + time.Now().Format("15:04") + "\x1b[39m"
+	fmt.Fprintf(Ui.Stdout, "%s\n", c.formatMessage(msg))
+}
+
+// formatMessage returns the line used to show a message in the conversation
+func (c *Conversation) formatMessage(msg *ricochet.Message) string {
+	ts := "\x1b[90m" + formatTimestamp(msg.Timestamp) + "\x1b[39m"
+	if isStatusMessage(msg) {
+		return fmt.Sprintf("%s | \x1b[33m-- %s --\x1b[39m", ts, msg.Text)
+	}
 
 	var direction string
 	if msg.Sender.IsSelf {
@@ -257,15 +466,253 @@ func (c *Conversation) printMessage(msg *ricochet.Message) {
 		direction = "\x1b[31m>>\x1b[39m"
 	}
 
+	var quote string
+	if msg.InReplyTo != nil {
+		quote = fmt.Sprintf("%s | \x1b[90m↪ %s\x1b[39m\n", ts, c.replyDescription(msg.InReplyTo))
+	}
+
 	// XXX shell escaping
-	fmt.Fprintf(Ui.Stdout, "%s | %s %s %s\n",
+	return fmt.Sprintf("%s%s | %s %s %s%s",
+		quote,
 		ts,
 		c.Contact.Data.Nickname,
 		direction,
-		msg.Text)
+		msg.Text,
+		deliveryStatusGlyph(msg))
+}
+
+// Length of the snippet of a replied message shown with the reply
+const replySnippetLength = 40
+
+// replyDescription returns a snippet of the message that a reply refers to, or
+// just "reply" if it's not in the backlog
+func (c *Conversation) replyDescription(ref *ricochet.MessageReference) string {
+	if ref.Sequence == 0 {
+		return "reply"
+	}
+	var replied *ricochet.Message
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if c.messages[i].Sequence == ref.Sequence && !isStatusMessage(c.messages[i]) {
+			replied = c.messages[i]
+			break
+		}
+	}
+	if replied == nil {
+		return "reply"
+	}
+
+	snippet := []rune(strings.Replace(replied.Text, "\n", " ", -1))
+	if len(snippet) > replySnippetLength {
+		snippet = append(snippet[:replySnippetLength], '…')
+	}
+	name := c.Contact.Data.Nickname
+	if replied.Sender.IsSelf {
+		name = "you"
+	}
+	return fmt.Sprintf("reply to %s: \"%s\"", name, string(snippet))
+}
+
+// LastReceivedMessage returns the most recent message from the contact in the
+// backlog, or nil
+func (c *Conversation) LastReceivedMessage() *ricochet.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if !isStatusMessage(c.messages[i]) && !c.messages[i].Sender.IsSelf {
+			return c.messages[i]
+		}
+	}
+	return nil
+}
+
+// Messages delivered later than this after they were sent print a notice
+const lateDeliveryNotice = 10 * time.Second
+
+// deliveryStatusGlyph returns a suffix showing the status of outbound messages
+func deliveryStatusGlyph(msg *ricochet.Message) string {
+	if !msg.Sender.IsSelf {
+		return ""
+	}
+	switch msg.Status {
+	case ricochet.Message_QUEUED:
+		return " \x1b[33m(queued)\x1b[39m"
+	case ricochet.Message_SENDING:
+		return " \x1b[90m…\x1b[39m"
+	case ricochet.Message_DELIVERED:
+		return " \x1b[32m✓\x1b[39m"
+	case ricochet.Message_ERROR:
+		return " \x1b[31m✗ failed\x1b[39m"
+	default:
+		return ""
+	}
+}
+
+// printDeliveryUpdate shows a change in the status of an outbound message that
+// was already printed. Failures are always shown, and deliveries only if the
+// message was delayed, e.g. because it was queued while the contact was offline.
+func (c *Conversation) printDeliveryUpdate(msg *ricochet.Message) {
+	if !c.active {
+		return
+	}
+	switch msg.Status {
+	case ricochet.Message_ERROR:
+	case ricochet.Message_DELIVERED:
+		if time.Since(time.Unix(msg.Timestamp, 0)) < lateDeliveryNotice {
+			return
+		}
+	default:
+		return
+	}
+	fmt.Fprintf(Ui.Stdout, "%s\n", c.formatMessage(msg))
+}
+
+// Number of messages shown by each ScrollOlder or ScrollNewer
+const scrollPageSize = 10
+
+// ScrollOlder prints the page of messages before the one shown last, starting
+// from the newest page. Only the messages retained in the backlog are shown.
+func (c *Conversation) ScrollOlder() {
+	c.mutex.Lock()
+	end := c.scrollEnd
+	if end == 0 {
+		end = len(c.messages)
+	}
+	if end -= scrollPageSize; end <= 0 {
+		fmt.Fprintf(Ui.Stdout, "No older messages in the last %d messages\n", len(c.messages))
+		c.mutex.Unlock()
+		return
+	}
+	c.scrollEnd = end
+	lastUnread := c.printPage(end)
+	c.mutex.Unlock()
+	// Messages are only marked read once they've been shown
+	if lastUnread != nil {
+		c.MarkAsReadBefore(lastUnread)
+	}
+}
+
+// ScrollNewer prints the page of messages after the one shown last, until it
+// reaches the newest messages
+func (c *Conversation) ScrollNewer() {
+	c.mutex.Lock()
+	if c.scrollEnd == 0 {
+		fmt.Fprintf(Ui.Stdout, "No newer messages\n")
+		c.mutex.Unlock()
+		return
+	}
+	end := c.scrollEnd + scrollPageSize
+	if end >= len(c.messages) {
+		end = len(c.messages)
+		c.scrollEnd = 0
+	} else {
+		c.scrollEnd = end
+	}
+	lastUnread := c.printPage(end)
+	c.mutex.Unlock()
+	// Messages are only marked read once they've been shown
+	if lastUnread != nil {
+		c.MarkAsReadBefore(lastUnread)
+	}
+}
+
+// printPage prints up to scrollPageSize messages ending before index end, and
+// returns the last unread message among them, or nil
+func (c *Conversation) printPage(end int) *ricochet.Message {
+	start := end - scrollPageSize
+	if start < 0 {
+		start = 0
+	}
+	fmt.Fprintf(Ui.Stdout, "-- messages %d-%d of %d --\n", start+1, end, len(c.messages))
+	var lastUnread *ricochet.Message
+	for _, message := range c.messages[start:end] {
+		c.printMessage(message)
+		if message.Status == ricochet.Message_UNREAD {
+			lastUnread = message
+		}
+	}
+	if end == len(c.messages) {
+		fmt.Fprintf(Ui.Stdout, "-- end of conversation --\n")
+	}
+	return lastUnread
+}
+
+// Number of messages shown before and after each search result
+const searchContextNum = 1
+
+// Search returns the messages with text containing query, ignoring case, with
+// the most recent first. Only the messages retained in the backlog are searched,
+// so older messages may be missing; see BacklogLimits.
+func (c *Conversation) Search(query string) []*ricochet.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var results []*ricochet.Message
+	for _, i := range c.searchIndexes(query) {
+		results = append(results, c.messages[i])
+	}
+	return results
+}
+
+// Indexes in c.messages of messages matching query, most recent first
+func (c *Conversation) searchIndexes(query string) []int {
+	query = strings.ToLower(query)
+	var indexes []int
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		message := c.messages[i]
+		if !isStatusMessage(message) && strings.Contains(strings.ToLower(message.Text), query) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// PrintSearch prints the results of Search with the messages around them
+func (c *Conversation) PrintSearch(query string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	indexes := c.searchIndexes(query)
+	if len(indexes) == 0 {
+		fmt.Fprintf(Ui.Stdout, "No messages found in the last %d messages\n", len(c.messages))
+		return
+	}
+
+	for n, index := range indexes {
+		if n > 0 {
+			fmt.Fprintf(Ui.Stdout, "--\n")
+		}
+		start, end := index-searchContextNum, index+searchContextNum
+		if start < 0 {
+			start = 0
+		}
+		if end >= len(c.messages) {
+			end = len(c.messages) - 1
+		}
+		for i := start; i <= end; i++ {
+			if i == index {
+				fmt.Fprintf(Ui.Stdout, "\x1b[1m*\x1b[0m %s\n", c.formatMessage(c.messages[i]))
+			} else {
+				fmt.Fprintf(Ui.Stdout, "  %s\n", c.formatMessage(c.messages[i]))
+			}
+		}
+	}
+	fmt.Fprintf(Ui.Stdout, "Found %d messages in the last %d messages\n", len(indexes), len(c.messages))
+}
+
+// formatTimestamp returns a short local time for a message timestamp, including
+// the date for messages that weren't sent today, e.g. from an older backlog.
+func formatTimestamp(timestamp int64) string {
+	t := time.Unix(timestamp, 0)
+	now := time.Now()
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04")
+	} else if y == now.Year() {
+		return t.Format("Jan 2 15:04")
+	}
+	return t.Format("Jan 2 2006 15:04")
 }
 
 func (c *Conversation) UnreadCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return c.numUnread
 }
 
@@ -279,13 +726,70 @@ func (c *Conversation) recountUnread() {
 }
 
 func (c *Conversation) SetActive(active bool) {
+	c.mutex.Lock()
 	if active == c.active {
+		c.mutex.Unlock()
 		return
 	}
 
 	c.active = active
+	c.scrollEnd = 0
+	var lastRecvMsg *ricochet.Message
 	if active {
-		c.PrintContext()
-		c.MarkAsRead()
+		c.printContext()
+		lastRecvMsg = c.lastUnreadMessage()
+	}
+	c.mutex.Unlock()
+
+	if lastRecvMsg != nil {
+		c.MarkAsReadBefore(lastRecvMsg)
+	}
+}
+
+// Tell the backend whether the user is typing a message in this conversation.
+// Called for every change to the input line; only changes in state, and renewals
+// while typing continues, are sent to the backend. Blocking API call.
+func (c *Conversation) SetTyping(typing bool) {
+	c.mutex.Lock()
+	if typing == c.localTyping && (!typing || time.Since(c.localTypingSent) < typingRefreshInterval) {
+		c.mutex.Unlock()
+		return
+	}
+	if c.Contact.Data.Status != ricochet.Contact_ONLINE {
+		c.localTyping = false
+		c.mutex.Unlock()
+		return
 	}
+	address := c.Contact.Data.Address
+	c.mutex.Unlock()
+
+	_, err := c.Client.Backend.SetConversationTyping(context.Background(),
+		&ricochet.SetConversationTypingRequest{
+			Entity: &ricochet.Entity{Address: address},
+			Typing: typing,
+		})
+	if err != nil {
+		log.Printf("Typing notification failed: %v", err)
+		return
+	}
+
+	c.mutex.Lock()
+	c.localTyping = typing
+	c.localTypingSent = time.Now()
+	c.mutex.Unlock()
+}
+
+func (c *Conversation) SetRemoteTyping(typing bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.remoteTyping = typing
+	if c.active {
+		Ui.RefreshConversationPrompt()
+	}
+}
+
+func (c *Conversation) RemoteTyping() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.remoteTyping
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBackend implements the calls made by a Conversation, and panics for
+// any others
+type testBackend struct {
+	ricochet.RicochetCoreClient
+
+	mutex       sync.Mutex
+	readCalls   int
+	typingCalls int
+}
+
+func (b *testBackend) MarkConversationRead(ctx context.Context, in *ricochet.MarkConversationReadRequest, opts ...grpc.CallOption) (*ricochet.Reply, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.readCalls++
+	return &ricochet.Reply{}, nil
+}
+
+func (b *testBackend) SetConversationTyping(ctx context.Context, in *ricochet.SetConversationTypingRequest, opts ...grpc.CallOption) (*ricochet.Reply, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.typingCalls++
+	return &ricochet.Reply{}, nil
+}
+
+func newTestConversation(backend ricochet.RicochetCoreClient) *Conversation {
+	Ui.Stdout = ioutil.Discard
+	client := &Client{
+		Backend: backend,
+		Backlog: DefaultBacklogLimits,
+	}
+	contact := initContact(client, &ricochet.Contact{
+		Address:  "ricochet:bbbbbbbbbbbbbbbb",
+		Nickname: "contact",
+		Status:   ricochet.Contact_ONLINE,
+	})
+	return contact.Conversation
+}
+
+// Incoming messages are added from the client's event goroutine while the
+// input line and commands use the conversation, which is meant to be run with
+// the race detector.
+func TestConversationConcurrentUse(t *testing.T) {
+	backend := &testBackend{}
+	conv := newTestConversation(backend)
+	conv.SetActive(true)
+
+	const numMessages = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= numMessages; i++ {
+			conv.AddMessage(&ricochet.Message{
+				Sender:    &ricochet.Entity{Address: conv.Contact.Data.Address},
+				Recipient: &ricochet.Entity{IsSelf: true},
+				Timestamp: time.Now().Unix(),
+				Sequence:  uint64(i),
+				Status:    ricochet.Message_UNREAD,
+				Text:      "hello",
+			}, false)
+		}
+	}()
+
+	readers := []func(){
+		func() { conv.UnreadCount() },
+		func() { conv.LastReceivedMessage() },
+		func() { conv.MarkAsRead() },
+		func() { conv.Search("hello") },
+		func() { conv.ScrollOlder(); conv.ScrollNewer() },
+		func() { conv.SetTyping(true); conv.SetTyping(false) },
+	}
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for i := 0; i < numMessages; i++ {
+				read()
+			}
+		}(read)
+	}
+	wg.Wait()
+
+	if n := len(conv.Search("hello")); n != DefaultBacklogLimits.HardLimit {
+		t.Errorf("backlog has %d messages, expected %d", n, DefaultBacklogLimits.HardLimit)
+	}
+	if backend.typingCalls == 0 {
+		t.Error("typing state was never sent")
+	}
+}
+
+// SetTyping only records the state once the backend has accepted it, and
+// doesn't hold the mutex while waiting for the backend.
+func TestConversationSetTyping(t *testing.T) {
+	backend := &blockingTypingBackend{called: make(chan struct{}), release: make(chan struct{})}
+	conv := newTestConversation(backend)
+
+	done := make(chan struct{})
+	go func() {
+		conv.SetTyping(true)
+		close(done)
+	}()
+	<-backend.called
+
+	locked := make(chan struct{})
+	go func() {
+		conv.RemoteTyping()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetTyping held the mutex during the backend call")
+	}
+
+	close(backend.release)
+	<-done
+	conv.mutex.Lock()
+	defer conv.mutex.Unlock()
+	if !conv.localTyping {
+		t.Error("typing state was not recorded after the backend call")
+	}
+}
+
+type blockingTypingBackend struct {
+	ricochet.RicochetCoreClient
+	called, release chan struct{}
+}
+
+func (b *blockingTypingBackend) SetConversationTyping(ctx context.Context, in *ricochet.SetConversationTypingRequest, opts ...grpc.CallOption) (*ricochet.Reply, error) {
+	close(b.called)
+	<-b.release
+	return &ricochet.Reply{}, nil
+}
+
+// Marking messages read doesn't hold the mutex while waiting for the backend,
+// so events and input can still use the conversation
+func TestConversationMarkReadUnlocked(t *testing.T) {
+	tests := []struct {
+		name   string
+		active bool
+		// Makes the call that marks messages read
+		call func(conv *Conversation)
+	}{
+		{"new message", true, func(conv *Conversation) {
+			conv.AddMessage(testReceivedMessage(conv, 100), false)
+		}},
+		{"activated", false, func(conv *Conversation) { conv.SetActive(true) }},
+		{"mark as read", false, func(conv *Conversation) { conv.MarkAsRead() }},
+		{"scroll older", true, func(conv *Conversation) { conv.ScrollOlder() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := &blockingReadBackend{called: make(chan struct{}, 1), release: make(chan struct{})}
+			conv := newTestConversation(backend)
+			for i := 1; i <= 2*scrollPageSize; i++ {
+				conv.AddMessage(testReceivedMessage(conv, uint64(i)), true)
+			}
+			conv.mutex.Lock()
+			conv.active = test.active
+			conv.mutex.Unlock()
+
+			done := make(chan struct{})
+			go func() {
+				test.call(conv)
+				close(done)
+			}()
+			select {
+			case <-backend.called:
+			case <-time.After(5 * time.Second):
+				t.Fatal("messages weren't marked read")
+			}
+
+			locked := make(chan struct{})
+			go func() {
+				conv.RemoteTyping()
+				close(locked)
+			}()
+			select {
+			case <-locked:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the mutex was held during the backend call")
+			}
+			close(backend.release)
+			<-done
+		})
+	}
+}
+
+// testReceivedMessage returns an unread message from the contact of conv
+func testReceivedMessage(conv *Conversation, sequence uint64) *ricochet.Message {
+	return &ricochet.Message{
+		Sender:    &ricochet.Entity{Address: conv.Contact.Data.Address},
+		Recipient: &ricochet.Entity{IsSelf: true},
+		Timestamp: time.Now().Unix(),
+		Sequence:  sequence,
+		Status:    ricochet.Message_UNREAD,
+		Text:      "hello",
+	}
+}
+
+type blockingReadBackend struct {
+	ricochet.RicochetCoreClient
+	called, release chan struct{}
+}
+
+func (b *blockingReadBackend) MarkConversationRead(ctx context.Context, in *ricochet.MarkConversationReadRequest, opts ...grpc.CallOption) (*ricochet.Reply, error) {
+	select {
+	case b.called <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return &ricochet.Reply{}, nil
+}
+
+// The backlog is trimmed to the soft limit without discarding unread messages
+// or the context before them, and to the hard limit regardless
+func TestTrimBacklog(t *testing.T) {
+	limits := BacklogLimits{ContextNum: 2, SoftLimit: 5, HardLimit: 8}
+	tests := []struct {
+		name string
+		// Read messages followed by unread messages
+		read, unread int
+		// Messages kept, and how many of them are unread
+		kept, keptUnread int
+	}{
+		{"under the soft limit", 4, 0, 4, 0},
+		{"at the soft limit", 5, 0, 5, 0},
+		{"over the soft limit", 7, 0, 5, 0},
+		{"read before unread", 6, 1, 5, 1},
+		{"context before unread", 3, 5, 7, 5},
+		{"unread over the soft limit", 1, 6, 7, 6},
+		{"at the hard limit", 0, 8, 8, 8},
+		{"unread over the hard limit", 0, 10, 8, 8},
+		{"read and unread over the hard limit", 3, 7, 8, 7},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conv := newTestConversation(&testBackend{})
+			conv.Client.Backlog = limits
+			for i := 0; i < test.read+test.unread; i++ {
+				status := ricochet.Message_READ
+				if i >= test.read {
+					status = ricochet.Message_UNREAD
+				}
+				conv.messages = append(conv.messages, &ricochet.Message{Sequence: uint64(i), Status: status})
+			}
+			conv.recountUnread()
+			conv.trimBacklog()
+
+			if len(conv.messages) != test.kept || conv.numUnread != test.keptUnread {
+				t.Errorf("kept %d messages with %d unread, expected %d with %d unread", len(conv.messages), conv.numUnread, test.kept, test.keptUnread)
+			}
+			if last := conv.messages[len(conv.messages)-1].Sequence; last != uint64(test.read+test.unread-1) {
+				t.Errorf("last message is %d, expected the newest", last)
+			}
+		})
+	}
+}
+
+func TestBacklogLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits BacklogLimits
+		valid  bool
+	}{
+		{"default", DefaultBacklogLimits, true},
+		{"equal limits", BacklogLimits{ContextNum: 0, SoftLimit: 10, HardLimit: 10}, true},
+		{"hard under soft", BacklogLimits{ContextNum: 3, SoftLimit: 10, HardLimit: 9}, false},
+		{"context at soft", BacklogLimits{ContextNum: 10, SoftLimit: 10, HardLimit: 20}, false},
+		{"negative context", BacklogLimits{ContextNum: -1, SoftLimit: 10, HardLimit: 20}, false},
+		{"zero soft", BacklogLimits{ContextNum: 0, SoftLimit: 0, HardLimit: 20}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.limits.Validate(); (err == nil) != test.valid {
+				t.Errorf("error is %v, expected valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+// Messages are kept in order of sequence however their events arrive, and
+// messages sent again when history is populated aren't repeated
+func TestMessageOrdering(t *testing.T) {
+	tests := []struct {
+		name string
+		// Sequences of messages as their events arrive, then as the history is
+		// populated. In events, 0 is a status notice, which is added 10 seconds
+		// after the start; each message is sent its sequence in seconds after
+		// the start.
+		events, history []uint64
+		// Sequences in the backlog, with 0 for status notices
+		expected []uint64
+	}{
+		{"in order", []uint64{1, 2, 3}, nil, []uint64{1, 2, 3}},
+		{"reversed", []uint64{3, 2, 1}, nil, []uint64{1, 2, 3}},
+		{"interleaved", []uint64{1, 3, 2, 5, 4}, nil, []uint64{1, 2, 3, 4, 5}},
+		{"history after events", []uint64{4, 5}, []uint64{1, 2, 3, 4}, []uint64{1, 2, 3, 4, 5}},
+		{"history out of order", nil, []uint64{2, 4, 1, 3}, []uint64{1, 2, 3, 4}},
+		{"events during history", []uint64{3}, []uint64{1, 3, 2}, []uint64{1, 2, 3}},
+		{"late message before notice", []uint64{1, 3, 0, 2}, nil, []uint64{1, 2, 3, 0}},
+		{"new messages after notice", []uint64{1, 0, 15, 12}, nil, []uint64{1, 0, 12, 15}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conv := newTestConversation(&testBackend{})
+			conv.SetActive(true)
+			start := time.Now().Unix() - 10
+			message := func(sequence uint64) *ricochet.Message {
+				return &ricochet.Message{
+					Sender:    &ricochet.Entity{Address: conv.Contact.Data.Address},
+					Recipient: &ricochet.Entity{IsSelf: true},
+					Timestamp: start + int64(sequence),
+					Sequence:  sequence,
+					Status:    ricochet.Message_UNREAD,
+					Text:      "hello",
+				}
+			}
+			for _, sequence := range test.events {
+				if sequence == 0 {
+					conv.AddStatusMessage("status", false)
+				} else {
+					conv.AddMessage(message(sequence), false)
+				}
+			}
+			for _, sequence := range test.history {
+				conv.AddMessage(message(sequence), true)
+			}
+
+			var sequences []uint64
+			unread := 0
+			for _, msg := range conv.messages {
+				sequences = append(sequences, msg.Sequence)
+				if msg.Status == ricochet.Message_UNREAD {
+					unread++
+				}
+			}
+			if !reflect.DeepEqual(sequences, test.expected) {
+				t.Errorf("backlog is %v, expected %v", sequences, test.expected)
+			}
+			if conv.UnreadCount() != unread {
+				t.Errorf("unread count is %d, expected %d", conv.UnreadCount(), unread)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FileTransferList struct {
+	Client    *Client
+	Transfers map[uint32]*ricochet.FileTransfer
+}
+
+func NewFileTransferList(client *Client) *FileTransferList {
+	return &FileTransferList{
+		Client:    client,
+		Transfers: make(map[uint32]*ricochet.FileTransfer),
+	}
+}
+
+func (fl *FileTransferList) Populate(data *ricochet.FileTransfer) {
+	fl.Transfers[data.Identifier] = data
+}
+
+func (fl *FileTransferList) Added(data *ricochet.FileTransfer) {
+	fl.Transfers[data.Identifier] = data
+
+	contact := fl.Client.Contacts.ByAddress(data.Address)
+	if contact == nil {
+		return
+	}
+	if data.Direction == ricochet.FileTransfer_INBOUND {
+		contact.Conversation.AddStatusMessage(fmt.Sprintf("Offered file %q (%s) -- type accept-file %d to receive it", data.Name, formatFileSize(data.Size), data.Identifier), false)
+		if Ui.CurrentContact != contact {
+			fmt.Fprintf(Ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1m%s\x1b[0m offered file %q. Type \x1b[1maccept-file %d\x1b[0m to receive it \x1b[31m]]\x1b[39m\n", contact.Data.Nickname, data.Name, data.Identifier)
+		}
+	} else {
+		contact.Conversation.AddStatusMessage(fmt.Sprintf("Offered file %q (%s)", data.Name, formatFileSize(data.Size)), false)
+	}
+}
+
+func (fl *FileTransferList) Updated(data *ricochet.FileTransfer) {
+	oldData := fl.Transfers[data.Identifier]
+	if oldData == nil {
+		log.Printf("Ignoring update for unknown file transfer: %v", data)
+		return
+	}
+	fl.Transfers[data.Identifier] = data
+
+	if oldData.Status == data.Status {
+		return
+	}
+	contact := fl.Client.Contacts.ByAddress(data.Address)
+	if contact == nil {
+		return
+	}
+
+	var text string
+	switch data.Status {
+	case ricochet.FileTransfer_TRANSFERRING:
+		text = fmt.Sprintf("Transferring file %q", data.Name)
+	case ricochet.FileTransfer_INTERRUPTED:
+		text = fmt.Sprintf("Transfer of file %q interrupted; it will resume when the contact is online", data.Name)
+	case ricochet.FileTransfer_COMPLETE:
+		text = fmt.Sprintf("Transfer of file %q complete", data.Name)
+	case ricochet.FileTransfer_REJECTED:
+		text = fmt.Sprintf("File %q was rejected", data.Name)
+	case ricochet.FileTransfer_CANCELLED:
+		text = fmt.Sprintf("Transfer of file %q cancelled", data.Name)
+	case ricochet.FileTransfer_FAILED:
+		text = fmt.Sprintf("Transfer of file %q failed: %s", data.Name, data.ErrorMessage)
+	default:
+		return
+	}
+	contact.Conversation.AddStatusMessage(text, false)
+}
+
+func (fl *FileTransferList) ByIdentifier(param string) *ricochet.FileTransfer {
+	id, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		return nil
+	}
+	return fl.Transfers[uint32(id)]
+}
+
+func (c *Client) monitorFileTransfers() {
+	stream, err := c.Backend.MonitorFileTransfers(context.Background(), &ricochet.MonitorFileTransfersRequest{})
+	if err != nil {
+		log.Printf("Initializing file transfer monitor failed: %v", err)
+		// XXX handle
+		return
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			log.Printf("File transfer monitor error: %v", err)
+			// XXX handle
+			break
+		}
+
+		c.monitorsChannel <- event
+	}
+}
+
+func (c *Client) onFileTransferEvent(event *ricochet.FileTransferEvent) {
+	data := event.Transfer
+	if data == nil {
+		// Populate is terminated by an empty event
+		if event.Type == ricochet.FileTransferEvent_POPULATE {
+			log.Printf("Loaded %d file transfers", len(c.Transfers.Transfers))
+		}
+		return
+	}
+
+	switch event.Type {
+	case ricochet.FileTransferEvent_POPULATE:
+		c.Transfers.Populate(data)
+	case ricochet.FileTransferEvent_ADD:
+		c.Transfers.Added(data)
+	case ricochet.FileTransferEvent_UPDATE:
+		c.Transfers.Updated(data)
+	default:
+		log.Printf("Ignoring unknown file transfer event: %v", event)
+	}
+}
+
+func (ui *UI) ListFileTransfers() {
+	if len(ui.Client.Transfers.Transfers) == 0 {
+		fmt.Fprintf(ui.Stdout, "No file transfers\n")
+		return
+	}
+
+	ids := make([]int, 0, len(ui.Client.Transfers.Transfers))
+	for id := range ui.Client.Transfers.Transfers {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		data := ui.Client.Transfers.Transfers[uint32(id)]
+		direction := "to"
+		if data.Direction == ricochet.FileTransfer_INBOUND {
+			direction = "from"
+		}
+		name := data.Address
+		if contact := ui.Client.Contacts.ByAddress(data.Address); contact != nil {
+			name = contact.Data.Nickname
+		}
+		fmt.Fprintf(ui.Stdout, "    %d: %q %s \x1b[1m%s\x1b[0m -- %s, %s of %s\n", data.Identifier, data.Name, direction, name,
+			strings.ToLower(data.Status.String()), formatFileSize(data.Transferred), formatFileSize(data.Size))
+	}
+}
+
+func (ui *UI) SendFile(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 2 {
+		fmt.Fprintf(ui.Stdout, "Usage: send-file [address] [path]\n")
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(words[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(words[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", words[0])
+		return
+	}
+	if !contact.SupportsFileTransfer() {
+		fmt.Fprintf(ui.Stdout, "%s's client does not support file transfers\n", contact.Data.Nickname)
+		return
+	}
+
+	_, err := ui.Client.Backend.SendFile(context.Background(), &ricochet.SendFileRequest{
+		Address: contact.Data.Address,
+		Path:    words[1],
+	})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+	}
+}
+
+func (ui *UI) RespondFileTransfer(params []string, accept bool) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 1 {
+		if accept {
+			fmt.Fprintf(ui.Stdout, "Usage: accept-file [id] [path]\n")
+		} else {
+			fmt.Fprintf(ui.Stdout, "Usage: reject-file [id]\n")
+		}
+		return
+	}
+	data := ui.Client.Transfers.ByIdentifier(words[0])
+	if data == nil {
+		fmt.Fprintf(ui.Stdout, "No file transfer %s\n", words[0])
+		return
+	}
+
+	req := &ricochet.RespondFileTransferRequest{
+		Identifier: data.Identifier,
+		Accept:     accept,
+	}
+	if accept {
+		// Saved by the backend, relative to its working directory by default
+		req.Path = data.Name
+		if len(words) > 1 {
+			req.Path = words[1]
+		}
+	}
+
+	if _, err := ui.Client.Backend.RespondFileTransfer(context.Background(), req); err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+	}
+}
+
+func (ui *UI) CancelFileTransfer(params []string) {
+	if len(params) < 1 {
+		fmt.Fprintf(ui.Stdout, "Usage: cancel-file [id]\n")
+		return
+	}
+	data := ui.Client.Transfers.ByIdentifier(params[0])
+	if data == nil {
+		fmt.Fprintf(ui.Stdout, "No file transfer %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.CancelFileTransfer(context.Background(),
+		&ricochet.CancelFileTransferRequest{Identifier: data.Identifier})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+	}
+}
+
+func formatFileSize(size uint64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
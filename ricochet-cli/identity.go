@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"github.com/chzyer/readline"
+	"github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func (ui *UI) ExportIdentity(params []string) {
+	if len(params) < 1 || params[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: export-identity [path]\n")
+		return
+	}
+	path := params[0]
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(ui.Stdout, "File %s already exists\n", path)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "\nThe exported file contains your \x1b[31mprivate key\x1b[0m. Anyone who has it and the\n")
+	fmt.Fprintf(ui.Stdout, "passphrase can use your ricochet ID and talk to your contacts as you. Keep it\n")
+	fmt.Fprintf(ui.Stdout, "somewhere safe, and delete it once it's no longer needed.\n\n")
+	passphrase, err := readline.Password("Passphrase: ")
+	if err != nil {
+		return
+	}
+	confirm, err := readline.Password("Repeat passphrase: ")
+	if err != nil {
+		return
+	}
+	if string(passphrase) != string(confirm) {
+		fmt.Fprintf(ui.Stdout, "Passphrases do not match\n")
+		return
+	}
+
+	reply, err := ui.Client.Backend.ExportIdentity(context.Background(),
+		&ricochet.ExportIdentityRequest{Passphrase: string(passphrase)})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(path, reply.Data, 0600); err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "Exported identity to %s\n", path)
+}
+
+func (ui *UI) ImportIdentity(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 1 || words[0] == "" || (len(words) > 1 && words[1] != "force") {
+		fmt.Fprintf(ui.Stdout, "Usage: import-identity [path] [force]\n")
+		return
+	}
+	force := len(words) > 1
+
+	data, err := ioutil.ReadFile(words[0])
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	if force {
+		fmt.Fprintf(ui.Stdout, "\nYour current identity \x1b[1m%s\x1b[0m will be \x1b[31mreplaced\x1b[0m, and is lost unless you\n", ui.Client.Identity.Address)
+		fmt.Fprintf(ui.Stdout, "have exported it.\n\n")
+		confirm, err := readline.Line("Type YES to confirm: ")
+		if err != nil || confirm != "YES" {
+			fmt.Fprintf(ui.Stdout, "Aborted\n")
+			return
+		}
+	}
+	passphrase, err := readline.Password("Passphrase: ")
+	if err != nil {
+		return
+	}
+
+	reply, err := ui.Client.Backend.ImportIdentity(context.Background(),
+		&ricochet.ImportIdentityRequest{
+			Data:       data,
+			Passphrase: string(passphrase),
+			Force:      force,
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "Imported identity \x1b[1m%s\x1b[0m, which will be used after restarting\n", reply.Address)
+}
+
+var requestPolicyModes = map[string]ricochet.ContactRequestPolicy_Mode{
+	"manual": ricochet.ContactRequestPolicy_MANUAL,
+	"reject": ricochet.ContactRequestPolicy_REJECT_ALL,
+	"accept": ricochet.ContactRequestPolicy_AUTO_ACCEPT,
+}
+
+func (ui *UI) ContactRequestPolicy(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+
+	var policy *ricochet.ContactRequestPolicy
+	var err error
+	if len(words) < 1 || words[0] == "" {
+		policy, err = ui.Client.Backend.GetContactRequestPolicy(context.Background(),
+			&ricochet.ContactRequestPolicyRequest{})
+	} else {
+		mode, ok := requestPolicyModes[words[0]]
+		if !ok || (len(words) > 1 && mode != ricochet.ContactRequestPolicy_AUTO_ACCEPT) {
+			fmt.Fprintf(ui.Stdout, "Usage: request-policy [manual|reject|accept [token]]\n")
+			return
+		}
+		request := &ricochet.ContactRequestPolicy{Mode: mode}
+		if len(words) > 1 {
+			request.Token = words[1]
+		}
+		policy, err = ui.Client.Backend.SetContactRequestPolicy(context.Background(), request)
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	switch policy.Mode {
+	case ricochet.ContactRequestPolicy_MANUAL:
+		fmt.Fprintf(ui.Stdout, "Contact requests are pending until you accept or reject them\n")
+	case ricochet.ContactRequestPolicy_REJECT_ALL:
+		fmt.Fprintf(ui.Stdout, "Contact requests are rejected automatically\n")
+	case ricochet.ContactRequestPolicy_AUTO_ACCEPT:
+		if policy.Token != "" {
+			fmt.Fprintf(ui.Stdout, "Contact requests with a message containing \"%s\" are accepted automatically\n", policy.Token)
+		} else {
+			fmt.Fprintf(ui.Stdout, "Contact requests are accepted automatically\n")
+		}
+	}
+}
+
+var connectionModes = map[string]ricochet.ConnectionMode_Mode{
+	"online":      ricochet.ConnectionMode_ONLINE,
+	"no-outbound": ricochet.ConnectionMode_NO_OUTBOUND,
+	"invisible":   ricochet.ConnectionMode_INVISIBLE,
+}
+
+func (ui *UI) ConnectionMode(params []string) {
+	var mode *ricochet.ConnectionMode
+	var err error
+	if len(params) < 1 || params[0] == "" {
+		mode, err = ui.Client.Backend.GetConnectionMode(context.Background(),
+			&ricochet.ConnectionModeRequest{})
+	} else {
+		value, ok := connectionModes[params[0]]
+		if !ok {
+			fmt.Fprintf(ui.Stdout, "Usage: connection-mode [online|no-outbound|invisible]\n")
+			return
+		}
+		mode, err = ui.Client.Backend.SetConnectionMode(context.Background(),
+			&ricochet.ConnectionMode{Mode: value})
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	ui.printConnectionMode(mode.Mode)
+}
+
+// ToggleInvisible switches between appearing offline and the online mode
+func (ui *UI) ToggleInvisible() {
+	mode, err := ui.Client.Backend.GetConnectionMode(context.Background(), &ricochet.ConnectionModeRequest{})
+	if err == nil {
+		if mode.Mode == ricochet.ConnectionMode_INVISIBLE {
+			mode.Mode = ricochet.ConnectionMode_ONLINE
+		} else {
+			mode.Mode = ricochet.ConnectionMode_INVISIBLE
+		}
+		mode, err = ui.Client.Backend.SetConnectionMode(context.Background(), mode)
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	ui.printConnectionMode(mode.Mode)
+}
+
+func (ui *UI) printConnectionMode(mode ricochet.ConnectionMode_Mode) {
+	switch mode {
+	case ricochet.ConnectionMode_ONLINE:
+		fmt.Fprintf(ui.Stdout, "Connecting to contacts and accepting their connections\n")
+	case ricochet.ConnectionMode_NO_OUTBOUND:
+		fmt.Fprintf(ui.Stdout, "Not connecting to contacts, but accepting their connections -- type 'connection-mode online' to resume\n")
+	case ricochet.ConnectionMode_INVISIBLE:
+		fmt.Fprintf(ui.Stdout, "Invisible; contacts can't connect to you -- type 'connection-mode online' to resume\n")
+	}
+}
+
+var presenceStatuses = map[string]ricochet.Presence_Status{
+	"available": ricochet.Presence_AVAILABLE,
+	"away":      ricochet.Presence_AWAY,
+	"busy":      ricochet.Presence_BUSY,
+}
+
+func (ui *UI) Presence(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+
+	var presence *ricochet.Presence
+	var err error
+	if len(words) < 1 || words[0] == "" {
+		presence, err = ui.Client.Backend.GetPresence(context.Background(),
+			&ricochet.PresenceRequest{})
+	} else {
+		status, ok := presenceStatuses[words[0]]
+		if !ok {
+			fmt.Fprintf(ui.Stdout, "Usage: presence [available|away|busy [message]]\n")
+			return
+		}
+		request := &ricochet.Presence{Status: status}
+		if len(words) > 1 {
+			request.Message = words[1]
+		}
+		presence, err = ui.Client.Backend.SetPresence(context.Background(), request)
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "Your presence is %s\n", presenceText(presence))
+}
+
+// Describe a presence, e.g. " [away: back at 5]", or nothing for a contact
+// that is available without a status message
+func presenceDescription(presence *ricochet.Presence) string {
+	if presence == nil || (presence.Status == ricochet.Presence_AVAILABLE && presence.Message == "") {
+		return ""
+	}
+	return " [" + presenceText(presence) + "]"
+}
+
+func presenceText(presence *ricochet.Presence) string {
+	text := strings.ToLower(presence.Status.String())
+	if presence.Message != "" {
+		text += ": " + presence.Message
+	}
+	return text
+}
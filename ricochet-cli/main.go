@@ -2,32 +2,84 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/chzyer/readline"
 	ricochet "github.com/ricochet-im/ricochet-go/core"
 	"github.com/ricochet-im/ricochet-go/core/config"
+	"github.com/ricochet-im/ricochet-go/core/utils"
 	rpc "github.com/ricochet-im/ricochet-go/rpc"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
+// How long to wait for contact connections to close when exiting
+const shutdownTimeout = 10 * time.Second
+
 var (
 	LogBuffer bytes.Buffer
+	// Files of the profile, if -data-dir is used
+	profileDir *config.DataDir
+	// In-process backend, if one was started
+	backendCore *ricochet.Ricochet
+	backendRpc  *grpc.Server
+	// Closed when the backend is stopping, so the RPC server exiting is expected
+	backendStopping = make(chan struct{})
+	// Token required for RPC calls, read from -rpc-token-file
+	rpcToken string
 
 	// Flags
 	backendConnect string
 	backendServer  string
 	unsafeBackend  bool
+	rpcTokenFile   string
+	rpcTLSCert     string
+	rpcTLSKey      string
 	backendMode    bool
 	connectAuto    bool
 	configPath     string = "identity.json"
+	dataDirPath    string
 	torAddress     string
 	torPassword    string
+	torSocks       string
+	torSocksUser   string
+	torSocksPasswd string
+	launchTor      bool
+	torExecutable  string
+	servicePort    int
+	maxConnects    int
+	connectTimeout time.Duration
+	queueAge       time.Duration
+	queueMax       int
+	ackTimeout     time.Duration
+	resendUnacked  bool
+	historyMax     int
+	messageRate    int
+	messageBurst   int
+	selfCheck      time.Duration
+	maxNickname    int
+	maxMessage     int
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+	connectSpread  time.Duration
+	isolateCircuit bool
+	logLevel       string = "info"
+	auditLogPath   string
+	ephemeral      bool
+	keyFilePath    string
+	encryptConfig  bool
+	notifyCommand  string
+	backlog        = DefaultBacklogLimits
 )
 
 func main() {
@@ -41,13 +93,48 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 	flag.StringVar(&configPath, "identity", configPath, "Load identity from `<file>`")
+	flag.StringVar(&dataDirPath, "data-dir", "", "Keep the identity, history, and other files of this profile in `<dir>`. With this, -listen and -attach accept 'unix:' for a socket in the directory")
 	flag.StringVar(&backendConnect, "attach", "", "Attach to the client backend running on `<address>`")
 	flag.StringVar(&backendServer, "listen", "", "Listen on `<address>` for client frontend connections")
-	flag.BoolVar(&unsafeBackend, "allow-unsafe-backend", false, "Allow a remote backend address. This is NOT RECOMMENDED and may harm your security or privacy. Do not use without a secure, trusted link")
+	flag.BoolVar(&unsafeBackend, "allow-unsafe-backend", false, "Allow a remote backend address without TLS and a token. This is NOT RECOMMENDED and may harm your security or privacy. Do not use without a secure, trusted link")
+	flag.StringVar(&rpcTokenFile, "rpc-token-file", "", "Require the token in `<file>` for backend connections with -listen, or send it with -attach")
+	flag.StringVar(&rpcTLSCert, "rpc-tls-cert", "", "Use TLS for backend connections, with the certificate in `<file>`. With -listen, the backend presents it along with -rpc-tls-key; with -attach, the backend must present it. The certificate must be valid for the backend address")
+	flag.StringVar(&rpcTLSKey, "rpc-tls-key", "", "Use the private key in `<file>` for -rpc-tls-cert with -listen")
 	flag.BoolVar(&backendMode, "only-backend", false, "Run backend without any commandline UI")
 	flag.BoolVar(&connectAuto, "connect", true, "Start connecting to the network automatically")
 	flag.StringVar(&torAddress, "tor-control", "", "Use the tor control port at `<address>`, which may be 'host:port' or 'unix:/path'")
 	flag.StringVar(&torPassword, "tor-control-password", "", "Use `<password>` to authenticate to the tor control port")
+	flag.BoolVar(&launchTor, "launch-tor", false, "Launch and manage a tor process for this identity, instead of using an existing tor")
+	flag.StringVar(&torExecutable, "tor-executable", "", "Use the tor executable at `<path>` with -launch-tor, instead of finding it in PATH")
+	flag.StringVar(&torSocks, "tor-socks", "", "Use the tor SOCKS port at `<address>`, which may be 'host:port' or 'unix:/path', instead of asking tor")
+	flag.StringVar(&torSocksUser, "tor-socks-user", "", "Authenticate to the tor SOCKS port with `<username>`, for proxies that require it")
+	flag.StringVar(&torSocksPasswd, "tor-socks-password", "", "Authenticate to the tor SOCKS port with `<password>`, along with -tor-socks-user")
+	flag.StringVar(&keyFilePath, "key-file", "", "Keep the identity's private key in `<file>`, encrypted with a passphrase, instead of in the identity config. The passphrase is read from RICOCHET_KEY_PASSPHRASE or asked for at startup")
+	flag.BoolVar(&encryptConfig, "encrypt-config", false, "Encrypt the identity config with a passphrase, which is then needed to start the backend. The passphrase is read from RICOCHET_CONFIG_PASSPHRASE or asked for at startup")
+	flag.StringVar(&notifyCommand, "notify-command", "", "Run `<command>` when a message is received, e.g. to show a notification. Arguments are split on spaces, without a shell, and {nickname}, {address} and {preview} in them are replaced; the same values are in RICOCHET_NICKNAME, RICOCHET_ADDRESS and RICOCHET_PREVIEW. Contacts choose these values, so never pass them to a shell")
+	flag.BoolVar(&ephemeral, "ephemeral", false, "Keep message history in memory only, instead of saving it alongside the identity")
+	flag.IntVar(&servicePort, "service-port", 0, "Use `<port>` for the ricochet service instead of the configured or standard port")
+	flag.IntVar(&maxConnects, "max-connects", 0, "Make at most `<num>` connection attempts to contacts at once, or unlimited if negative (default 6)")
+	flag.DurationVar(&connectTimeout, "connect-timeout", 0, "Abandon and retry connection attempts to contacts after `<duration>` (default 1m0s)")
+	flag.DurationVar(&backoffInitial, "connect-backoff", 0, "Wait `<duration>` before retrying a failed connection to a contact, doubling for each later failure (default 30s)")
+	flag.DurationVar(&backoffMax, "connect-backoff-max", 0, "Wait at most `<duration>` between connection attempts to a contact (default 15m0s)")
+	flag.DurationVar(&connectSpread, "reconnect-spread", 0, "Spread connection attempts to contacts over a random time up to `<duration>` when the network comes online, or not if negative (default 20s)")
+	flag.BoolVar(&isolateCircuit, "isolate-contacts", false, "Use separate tor circuits for connections to each contact, so relays can't link contacts to each other")
+	flag.DurationVar(&queueAge, "queue-age", 0, "Fail messages to offline contacts after they have been queued for `<duration>`, or never if negative (default 168h)")
+	flag.IntVar(&queueMax, "queue-max", 0, "Queue at most `<num>` messages for each offline contact, or unlimited if negative (default 100)")
+	flag.DurationVar(&ackTimeout, "ack-timeout", 0, "Fail sent messages that the contact hasn't acknowledged after `<duration>`, or never if negative (default 2m0s)")
+	flag.BoolVar(&resendUnacked, "resend-unacked", false, "Send messages that failed without an acknowledgement again when the contact reconnects")
+	flag.IntVar(&messageRate, "message-rate", 0, "Accept at most `<num>` messages per minute from each contact connection, or unlimited if negative (default 60)")
+	flag.IntVar(&messageBurst, "message-burst", 0, "Accept bursts of up to `<num>` messages from a contact before applying -message-rate (default 30)")
+	flag.IntVar(&historyMax, "history-max", 0, "Keep at most `<num>` messages in each conversation in the backend, even if unread, or unlimited if negative (default 5000)")
+	flag.DurationVar(&selfCheck, "self-check", 0, "Check that contacts can reach our onion service every `<duration>`, or never if negative (default 1h0m0s)")
+	flag.IntVar(&maxNickname, "max-nickname-length", 0, "Refuse nicknames longer than `<num>` characters, up to 30 (default 30)")
+	flag.IntVar(&maxMessage, "max-message-length", 0, "Refuse messages longer than `<num>` bytes, up to 2000 (default 2000)")
+	flag.IntVar(&backlog.SoftLimit, "backlog", backlog.SoftLimit, "Keep up to `<num>` messages in each conversation; unread messages are always kept")
+	flag.IntVar(&backlog.HardLimit, "backlog-max", backlog.HardLimit, "Never keep more than `<num>` messages in each conversation, even if unread")
+	flag.IntVar(&backlog.ContextNum, "backlog-context", backlog.ContextNum, "Show `<num>` messages before the first unread message when opening a conversation")
+	flag.StringVar(&logLevel, "log-level", logLevel, "Log backend messages at `<level>` and above, which is one of debug, info, warning, or error")
+	flag.StringVar(&auditLogPath, "audit-log", "", "Append significant contact and connection events to the audit log at `<path>`")
 	flag.Parse()
 	if len(flag.Args()) > 1 {
 		flag.Usage()
@@ -56,6 +143,21 @@ func main() {
 		configPath = flag.Arg(0)
 	}
 
+	if dataDirPath != "" {
+		if len(flag.Args()) > 0 || isFlagSet("identity") {
+			fmt.Printf("Cannot use -identity with -data-dir, because the identity is kept in the data directory\n")
+			os.Exit(1)
+		}
+		profileDir = config.NewDataDir(dataDirPath)
+		configPath = profileDir.ConfigPath()
+		if backendServer == "unix:" {
+			backendServer += profileDir.RpcSocketPath()
+		}
+		if backendConnect == "unix:" {
+			backendConnect += profileDir.RpcSocketPath()
+		}
+	}
+
 	// Check for flag combinations that make no sense
 	if backendConnect != "" {
 		if backendMode {
@@ -64,11 +166,46 @@ func main() {
 		} else if backendServer != "" {
 			fmt.Printf("Cannot use -listen with -attach, because attach implies not running a backend\n")
 			os.Exit(1)
-		} else if torAddress != "" || torPassword != "" {
+		} else if torAddress != "" || torPassword != "" || torSocks != "" || torSocksUser != "" || launchTor {
 			fmt.Printf("Cannot use -tor-control with -attach, because tor connections happen on the backend\n")
 			os.Exit(1)
+		} else if keyFilePath != "" || encryptConfig {
+			fmt.Printf("Cannot use -key-file or -encrypt-config with -attach, because the config is loaded by the backend\n")
+			os.Exit(1)
+		} else if notifyCommand != "" {
+			fmt.Printf("Cannot use -notify-command with -attach, because messages are received by the backend\n")
+			os.Exit(1)
 		}
 	}
+	if (rpcTokenFile != "" || rpcTLSCert != "") && backendConnect == "" && backendServer == "" {
+		fmt.Printf("Cannot use -rpc-token-file or -rpc-tls-cert without -listen or -attach, because the backend is in-process\n")
+		os.Exit(1)
+	} else if (rpcTLSKey != "") != (rpcTLSCert != "" && backendConnect == "") {
+		fmt.Printf("Must use -rpc-tls-key with -rpc-tls-cert and -listen, and not with -attach\n")
+		os.Exit(1)
+	} else if rpcTLSCert != "" && strings.HasPrefix(backendConnect+backendServer, "unix:") {
+		fmt.Printf("Cannot use -rpc-tls-cert with a unix socket\n")
+		os.Exit(1)
+	}
+	if rpcTokenFile != "" {
+		var err error
+		if rpcToken, err = readRpcToken(rpcTokenFile); err != nil {
+			fmt.Printf("Invalid -rpc-token-file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if _, err := utils.ParseLogLevel(logLevel); err != nil {
+		fmt.Printf("Invalid -log-level: %s\n", logLevel)
+		os.Exit(1)
+	}
+	if err := backlog.Validate(); err != nil {
+		fmt.Printf("Invalid backlog flags: %v\n", err)
+		os.Exit(1)
+	}
+	if launchTor && (torAddress != "" || torPassword != "") {
+		fmt.Printf("Cannot use -tor-control with -launch-tor, because the launched tor is used\n")
+		os.Exit(1)
+	}
 
 	// Redirect log before starting backend, unless in backend mode
 	if !backendMode {
@@ -84,9 +221,11 @@ func main() {
 		}
 
 		if backendMode {
-			// Block forever; backend uses os.Exit on failure
-			c := make(chan struct{})
+			// Run until interrupted; backend uses os.Exit on failure
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 			<-c
+			stopBackend()
 			os.Exit(0)
 		}
 	}
@@ -113,6 +252,7 @@ func main() {
 	// Configure client and UI
 	client := &Client{
 		Backend: rpc.NewRicochetCoreClient(conn),
+		Backlog: backlog,
 	}
 	Ui = UI{
 		Input:  input,
@@ -133,6 +273,23 @@ func main() {
 	}()
 
 	Ui.CommandLoop()
+
+	if backendCore != nil {
+		stopBackend()
+	}
+}
+
+// stopBackend stops the RPC server and shuts down the in-process backend,
+// waiting up to shutdownTimeout for contact connections to close.
+func stopBackend() {
+	close(backendStopping)
+	backendRpc.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := backendCore.Shutdown(ctx); err != nil {
+		log.Printf("Backend shutdown did not finish: %v", err)
+	}
 }
 
 func connectClientBackend() (*grpc.ClientConn, error) {
@@ -147,17 +304,69 @@ func connectClientBackend() (*grpc.ClientConn, error) {
 	}
 
 	// External backend
+	var opts []grpc.DialOption
+	if rpcToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(ricochet.RpcTokenCredentials{Token: rpcToken}))
+	}
 	if strings.HasPrefix(address, "unix:") {
-		return grpc.Dial(address[5:], grpc.WithInsecure(),
+		opts = append(opts, grpc.WithInsecure(),
 			grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
 				return net.DialTimeout("unix", addr, timeout)
 			}))
+		return grpc.Dial(address[5:], opts...)
 	} else {
 		if err := checkBackendAddressSafety(address); err != nil {
 			return nil, err
 		}
-		return grpc.Dial(address, grpc.WithInsecure())
+		if rpcTLSCert != "" {
+			creds, err := credentials.NewClientTLSFromFile(rpcTLSCert, "")
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, grpc.WithTransportCredentials(creds))
+		} else {
+			opts = append(opts, grpc.WithInsecure())
+		}
+		return grpc.Dial(address, opts...)
+	}
+}
+
+// readRpcToken returns the RPC token from the first line of a file
+func readRpcToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if token == "" {
+		return "", errors.New("token is empty")
+	}
+	return token, nil
+}
+
+// isFlagSet returns true if the named flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// removeStaleSocket removes the unix socket at path if it was left behind by a
+// backend that has exited. If a backend is still listening, the profile is in
+// use and an error is returned.
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another backend is already using this profile at %s", path)
 	}
+	return os.Remove(path)
 }
 
 func checkBackendAddressSafety(address string) error {
@@ -166,25 +375,97 @@ func checkBackendAddressSafety(address string) error {
 		return err
 	}
 	ip := net.ParseIP(host)
-	if !unsafeBackend && (ip == nil || !ip.IsLoopback()) {
-		return fmt.Errorf("Host '%s' is not a loopback address.\nRead the warnings and use -allow-unsafe-backend for non-local addresses", host)
+	secured := rpcTLSCert != "" && rpcToken != ""
+	if !unsafeBackend && !secured && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("Host '%s' is not a loopback address.\nUse -rpc-tls-cert and -rpc-token-file for non-local addresses, or read the warnings and use -allow-unsafe-backend", host)
 	}
 	return nil
 }
 
 func startBackend() error {
+	historyPath := strings.TrimSuffix(configPath, ".json") + ".history.json"
+	torDataPath := strings.TrimSuffix(configPath, ".json") + ".tor"
+	if profileDir != nil {
+		if err := profileDir.Create(); err != nil {
+			return err
+		}
+		historyPath = profileDir.HistoryPath()
+		torDataPath = profileDir.TorDataPath()
+	}
+
+	// The RPC server isn't started until the config is unlocked, so nothing
+	// can use the backend without the passphrase
 	cfg, err := config.LoadConfigFile(configPath)
-	if err != nil && os.IsNotExist(err) {
+	if err == config.ConfigEncryptedError {
+		cfg, err = loadEncryptedConfig(configPath)
+	} else if err != nil && os.IsNotExist(err) {
 		cfg, err = config.NewConfigFile(configPath)
 	}
 	if err != nil {
 		return err
 	}
+	if encryptConfig && !cfg.IsEncrypted() {
+		passphrase, err := readNewConfigPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := cfg.SetPassphrase(passphrase); err != nil {
+			return err
+		}
+	}
+
+	var keyPassphrase string
+	if keyFilePath != "" || cfg.Read().KeyStorage.GetType() == rpc.KeyStorage_ENCRYPTED_FILE {
+		if keyPassphrase, err = readKeyPassphrase(); err != nil {
+			return err
+		}
+		if keyFilePath != "" {
+			if err := ricochet.UseEncryptedKeyFile(cfg, keyFilePath, keyPassphrase); err != nil {
+				return err
+			}
+		}
+	}
 
 	core := new(ricochet.Ricochet)
+	core.KeyPassphrase = keyPassphrase
+	level, _ := utils.ParseLogLevel(logLevel)
+	core.Log = utils.NewLogger(level)
+	core.AuditLogPath = auditLogPath
+	core.ServicePort = servicePort
+	core.MaxConcurrentConnects = maxConnects
+	core.ConnectAttemptTimeout = connectTimeout
+	core.ConnectBackoff.Initial = backoffInitial
+	core.ConnectBackoff.Max = backoffMax
+	core.ReconnectJitter = connectSpread
+	core.IsolateContactCircuits = isolateCircuit
+	core.MaxQueuedMessageAge = queueAge
+	core.MaxQueuedMessages = queueMax
+	core.AckTimeout = ackTimeout
+	core.ResendUnackedMessages = resendUnacked
+	core.MaxConversationMessages = historyMax
+	core.InboundMessageRate = messageRate
+	core.InboundMessageBurst = messageBurst
+	core.SelfCheckInterval = selfCheck
+	core.Acceptance.MaxNicknameLength = maxNickname
+	core.Acceptance.MaxMessageLength = maxMessage
+	if notifyCommand != "" {
+		core.Notify = &ricochet.MessageNotifier{Command: strings.Fields(notifyCommand)}
+	}
+	if launchTor {
+		core.Tor = &ricochet.TorProcess{
+			Path:    torExecutable,
+			DataDir: torDataPath,
+		}
+	}
+	if !ephemeral {
+		if core.History, err = config.LoadHistoryFile(historyPath); err != nil {
+			return err
+		}
+	}
 	if err := core.Init(cfg); err != nil {
 		return err
 	}
+	backendCore = core
 
 	if torAddress != "" {
 		core.Network.SetControlAddress(torAddress)
@@ -192,6 +473,16 @@ func startBackend() error {
 	if torPassword != "" {
 		core.Network.SetControlPassword(torPassword)
 	}
+	if torSocks != "" {
+		if err := core.Network.SetSocksAddress(torSocks); err != nil {
+			return fmt.Errorf("invalid tor SOCKS address: %v", err)
+		}
+	}
+	if torSocksUser != "" {
+		if err := core.Network.SetSocksAuth(torSocksUser, torSocksPasswd); err != nil {
+			return fmt.Errorf("invalid tor SOCKS credentials: %v", err)
+		}
+	}
 
 	var listener net.Listener
 	if backendServer == "" {
@@ -200,6 +491,11 @@ func startBackend() error {
 	} else {
 		if strings.HasPrefix(backendServer, "unix:") {
 			// XXX Need the right behavior for cleaning up old sockets, permissions, etc
+			if profileDir != nil && backendServer[5:] == profileDir.RpcSocketPath() {
+				if err := removeStaleSocket(backendServer[5:]); err != nil {
+					return err
+				}
+			}
 			listener, err = net.Listen("unix", backendServer[5:])
 		} else {
 			if err := checkBackendAddressSafety(backendServer); err != nil {
@@ -216,10 +512,31 @@ func startBackend() error {
 		Core: core,
 	}
 
+	var opts []grpc.ServerOption
+	if backendServer != "" {
+		if rpcToken != "" {
+			auth := &ricochet.RpcTokenAuth{Token: rpcToken}
+			opts = append(opts, auth.ServerOptions()...)
+		}
+		if rpcTLSCert != "" {
+			creds, err := credentials.NewServerTLSFromFile(rpcTLSCert, rpcTLSKey)
+			if err != nil {
+				listener.Close()
+				return err
+			}
+			opts = append(opts, grpc.Creds(creds))
+		}
+	}
+
+	backendRpc = grpc.NewServer(opts...)
+	rpc.RegisterRicochetCoreServer(backendRpc, server)
 	go func() {
-		grpcServer := grpc.NewServer()
-		rpc.RegisterRicochetCoreServer(grpcServer, server)
-		err := grpcServer.Serve(listener)
+		err := backendRpc.Serve(listener)
+		select {
+		case <-backendStopping:
+			return
+		default:
+		}
 		if err != nil {
 			log.Printf("backend exited: %v", err)
 			os.Exit(1)
@@ -234,3 +551,63 @@ func startBackend() error {
 
 	return nil
 }
+
+// readKeyPassphrase returns the passphrase of an encrypted key file from the
+// environment, or asks for it on the terminal
+func readKeyPassphrase() (string, error) {
+	if passphrase := os.Getenv("RICOCHET_KEY_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := readline.Password("Key passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
+// Number of times to ask for the config passphrase before giving up
+const configPassphraseAttempts = 3
+
+// loadEncryptedConfig decrypts the config with a passphrase from the
+// environment, or asks for it on the terminal until it's right
+func loadEncryptedConfig(path string) (*config.ConfigFile, error) {
+	if passphrase := os.Getenv("RICOCHET_CONFIG_PASSPHRASE"); passphrase != "" {
+		return config.LoadEncryptedConfigFile(path, passphrase)
+	}
+	for i := 0; ; i++ {
+		passphrase, err := readline.Password("Config passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := config.LoadEncryptedConfigFile(path, string(passphrase))
+		if err == config.WrongPassphraseError && i+1 < configPassphraseAttempts {
+			fmt.Println("Wrong passphrase")
+			continue
+		}
+		return cfg, err
+	}
+}
+
+// readNewConfigPassphrase returns the passphrase to encrypt the config with
+// from the environment, or asks for it twice on the terminal
+func readNewConfigPassphrase() (string, error) {
+	passphrase := os.Getenv("RICOCHET_CONFIG_PASSPHRASE")
+	if passphrase == "" {
+		first, err := readline.Password("New config passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		second, err := readline.Password("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("Passphrases do not match")
+		}
+		passphrase = string(first)
+	}
+	if len(passphrase) < ricochet.MinIdentityPassphraseLength {
+		return "", fmt.Errorf("Passphrase must be at least %d characters", ricochet.MinIdentityPassphraseLength)
+	}
+	return passphrase, nil
+}
@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/chzyer/readline"
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/ricochet-im/ricochet-go/core"
 	"github.com/ricochet-im/ricochet-go/rpc"
 	"golang.org/x/net/context"
 	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -100,9 +104,114 @@ func (ui *UI) Execute(line string) error {
 	case "delete-contact":
 		ui.DeleteContact(words[1:])
 
+	case "block":
+		ui.SetContactBlocked(words[1:], true)
+
+	case "unblock":
+		ui.SetContactBlocked(words[1:], false)
+
+	case "dormant":
+		ui.SetContactConnectOnStartup(words[1:], false)
+
+	case "autoconnect":
+		ui.SetContactConnectOnStartup(words[1:], true)
+
+	case "mute":
+		ui.SetContactMuted(words[1:], true)
+
+	case "unmute":
+		ui.SetContactMuted(words[1:], false)
+
+	case "wake":
+		ui.ConnectContact(words[1:])
+
+	case "rename":
+		ui.RenameContact(words[1:])
+
+	case "pin":
+		ui.SetContactPinned(words[1:], true)
+
+	case "unpin":
+		ui.SetContactPinned(words[1:], false)
+
+	case "search":
+		ui.Search(words[1:])
+
+	case "reply":
+		ui.Reply(words[1:])
+
+	case "older":
+		ui.Scroll(true)
+
+	case "newer":
+		ui.Scroll(false)
+
+	case "diagnostics":
+		ui.ContactDiagnostics(words[1:])
+
+	case "transfers":
+		ui.ListFileTransfers()
+
+	case "send-file":
+		ui.SendFile(words[1:])
+
+	case "accept-file":
+		ui.RespondFileTransfer(words[1:], true)
+
+	case "reject-file":
+		ui.RespondFileTransfer(words[1:], false)
+
+	case "cancel-file":
+		ui.CancelFileTransfer(words[1:])
+
+	case "export-contacts":
+		ui.ExportContacts(words[1:])
+
+	case "import-contacts":
+		ui.ImportContacts(words[1:])
+
+	case "export-identity":
+		ui.ExportIdentity(words[1:])
+
+	case "import-identity":
+		ui.ImportIdentity(words[1:])
+
+	case "requests":
+		ui.ListContactRequests()
+
+	case "accept-request":
+		ui.AcceptContactRequest(words[1:])
+
+	case "reject-request":
+		ui.RejectContactRequest(words[1:])
+
+	case "edit-request":
+		ui.EditContactRequest(words[1:])
+
+	case "cancel-request":
+		ui.CancelContactRequest(words[1:])
+
+	case "request-policy":
+		ui.ContactRequestPolicy(words[1:])
+
+	case "connection-mode":
+		ui.ConnectionMode(words[1:])
+
+	case "invisible":
+		ui.ToggleInvisible()
+
+	case "presence":
+		ui.Presence(words[1:])
+
 	case "log":
 		fmt.Fprint(ui.Stdout, LogBuffer.String())
 
+	case "log-level":
+		ui.LogLevel(words[1:])
+
+	case "audit":
+		ui.AuditLog(words[1:])
+
 	case "close":
 		ui.SetCurrentContact(nil)
 
@@ -124,7 +233,60 @@ func (ui *UI) Execute(line string) error {
 }
 
 func (ui *UI) printHelp() {
-	fmt.Fprintf(ui.Stdout, "Commands: clear, quit, status, connect, disconnect, contacts, add-contact, delete-contact, log, close, help\n")
+	fmt.Fprintf(ui.Stdout, "Commands: clear, quit, status, connect, disconnect, contacts, add-contact, delete-contact, rename, pin, unpin, search, reply, older, newer, diagnostics, block, unblock, dormant, autoconnect, wake, mute, unmute, transfers, send-file, accept-file, reject-file, cancel-file, export-contacts, import-contacts, export-identity, import-identity, requests, accept-request, reject-request, edit-request, cancel-request, request-policy, connection-mode, invisible, presence, log, log-level, audit, close, help\n")
+}
+
+func (ui *UI) LogLevel(params []string) {
+	var level *ricochet.LogLevel
+	var err error
+	if len(params) < 1 || params[0] == "" {
+		level, err = ui.Client.Backend.GetLogLevel(context.Background(), &ricochet.LogLevelRequest{})
+	} else {
+		value, ok := ricochet.LogLevel_Level_value[strings.ToUpper(params[0])]
+		if !ok {
+			fmt.Fprintf(ui.Stdout, "Usage: log-level [debug|info|warning|error]\n")
+			return
+		}
+		level, err = ui.Client.Backend.SetLogLevel(context.Background(),
+			&ricochet.LogLevel{Level: ricochet.LogLevel_Level(value)})
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	fmt.Fprintf(ui.Stdout, "Backend log level is %s\n", strings.ToLower(level.Level.String()))
+}
+
+func (ui *UI) AuditLog(params []string) {
+	limit := 20
+	if len(params) > 0 && params[0] != "" {
+		var err error
+		if limit, err = strconv.Atoi(params[0]); err != nil || limit < 1 {
+			fmt.Fprintf(ui.Stdout, "Usage: audit [count]\n")
+			return
+		}
+	}
+	reply, err := ui.Client.Backend.GetAuditLog(context.Background(),
+		&ricochet.AuditLogRequest{Limit: uint32(limit)})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	if len(reply.Entries) == 0 {
+		fmt.Fprintf(ui.Stdout, "No audit log entries\n")
+		return
+	}
+	for _, entry := range reply.Entries {
+		name := entry.Address
+		if contact := ui.Client.Contacts.ByAddress(entry.Address); contact != nil {
+			name = contact.Data.Nickname
+		} else if name == "" {
+			name = "unknown"
+		}
+		t, _ := time.Parse(time.RFC3339, entry.Time)
+		fmt.Fprintf(ui.Stdout, "%s %s \x1b[1m%s\x1b[0m %s\n", t.Local().Format("2006-01-02 15:04:05"),
+			strings.Replace(strings.ToLower(entry.Type.String()), "_", " ", -1), name, entry.Detail)
+	}
 }
 
 func (ui *UI) PrintStatus() {
@@ -155,6 +317,16 @@ func (ui *UI) PrintStatus() {
 			fmt.Fprintf(ui.Stdout, "Network is online\n")
 		}
 	}
+	if socksStatus := ui.Client.NetworkSocksStatus(); socksStatus.Status == ricochet.TorSocksStatus_UNAVAILABLE {
+		fmt.Fprintf(ui.Stdout, "Tor is not reachable, so no contacts can connect: %s\n", socksStatus.ErrorMessage)
+	}
+	if serviceStatus := ui.Client.NetworkServiceStatus(); serviceStatus.Status == ricochet.OnionServiceStatus_UNREACHABLE {
+		fmt.Fprintf(ui.Stdout, "Your onion service is not reachable, so contacts may not be able to connect to you: %s\n", serviceStatus.ErrorMessage)
+	}
+
+	if mode, err := ui.Client.Backend.GetConnectionMode(context.Background(), &ricochet.ConnectionModeRequest{}); err == nil && mode.Mode != ricochet.ConnectionMode_ONLINE {
+		ui.printConnectionMode(mode.Mode)
+	}
 
 	fmt.Fprintf(ui.Stdout, "Your ricochet ID is %s\n", ui.Client.Identity.Address)
 
@@ -180,25 +352,56 @@ func (ui *UI) PrintStatus() {
 	}
 }
 
+// Show a banner when connections through tor start or stop failing, to tell this
+// apart from contacts being offline
+func (ui *UI) PrintSocksStatusChange(oldStatus ricochet.TorSocksStatus_Status) {
+	socksStatus := ui.Client.NetworkSocksStatus()
+	switch socksStatus.Status {
+	case ricochet.TorSocksStatus_UNAVAILABLE:
+		fmt.Fprintf(ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1mTor is not reachable\x1b[0m; contacts can't be reached until it is: %s \x1b[31m]]\x1b[39m\n", socksStatus.ErrorMessage)
+	case ricochet.TorSocksStatus_AVAILABLE:
+		if oldStatus != ricochet.TorSocksStatus_UNAVAILABLE {
+			return
+		}
+		fmt.Fprintf(ui.Stdout, "\r\x1b[31m[[\x1b[0m Tor is reachable again \x1b[31m]]\x1b[39m\n")
+	}
+}
+
+// Show a banner when the self-check finds that contacts can't reach us, or can
+// again after that
+func (ui *UI) PrintServiceStatusChange(oldStatus ricochet.OnionServiceStatus_Status) {
+	serviceStatus := ui.Client.NetworkServiceStatus()
+	switch serviceStatus.Status {
+	case ricochet.OnionServiceStatus_UNREACHABLE:
+		fmt.Fprintf(ui.Stdout, "\r\x1b[31m[[\x1b[0m \x1b[1mYour onion service is not reachable\x1b[0m; contacts may not be able to connect to you: %s \x1b[31m]]\x1b[39m\n", serviceStatus.ErrorMessage)
+	case ricochet.OnionServiceStatus_REACHABLE:
+		if oldStatus != ricochet.OnionServiceStatus_UNREACHABLE {
+			return
+		}
+		fmt.Fprintf(ui.Stdout, "\r\x1b[31m[[\x1b[0m Your onion service is reachable again \x1b[31m]]\x1b[39m\n")
+	}
+}
+
 func (ui *UI) ListContacts() {
 	byStatus := make(map[ricochet.Contact_Status][]*Contact)
 	for _, contact := range ui.Client.Contacts.Contacts {
 		byStatus[contact.Data.Status] = append(byStatus[contact.Data.Status], contact)
 	}
 
-	order := []ricochet.Contact_Status{ricochet.Contact_ONLINE, ricochet.Contact_UNKNOWN, ricochet.Contact_OFFLINE, ricochet.Contact_REQUEST, ricochet.Contact_REJECTED}
+	order := []ricochet.Contact_Status{ricochet.Contact_ONLINE, ricochet.Contact_CONNECTING, ricochet.Contact_UNKNOWN, ricochet.Contact_OFFLINE, ricochet.Contact_REQUEST, ricochet.Contact_REJECTED, ricochet.Contact_BLOCKED}
 	for _, status := range order {
 		contacts := byStatus[status]
 		if len(contacts) == 0 {
 			continue
 		}
 		fmt.Fprintf(ui.Stdout, "%s\n", ColoredContactStatus(status))
+		sort.Slice(contacts, func(i, j int) bool { return contactSortsBefore(contacts[i].Data, contacts[j].Data) })
 		for _, contact := range contacts {
 			unreadCount := contact.Conversation.UnreadCount()
 			if unreadCount > 0 {
-				fmt.Fprintf(ui.Stdout, "    \x1b[1m%s\x1b[0m (\x1b[1m%s\x1b[0m) -- \x1b[34;1m%d new messages\x1b[0m\n", contact.Data.Nickname, ui.PrefixForAddress(contact.Data.Address), unreadCount)
+				fmt.Fprintf(ui.Stdout, "    \x1b[1m%s\x1b[0m%s%s (\x1b[1m%s\x1b[0m) -- \x1b[34;1m%d new messages\x1b[0m%s\n", contact.Data.Nickname, presenceDescription(contact.Data.Presence), mutedDescription(contact.Data), ui.PrefixForAddress(contact.Data.Address), unreadCount, connectionDescription(contact.Data))
 			} else {
-				fmt.Fprintf(ui.Stdout, "    %s (\x1b[1m%s\x1b[0m)\n", contact.Data.Nickname, ui.PrefixForAddress(contact.Data.Address))
+				fmt.Fprintf(ui.Stdout, "    %s%s%s (\x1b[1m%s\x1b[0m)%s%s\n", contact.Data.Nickname, presenceDescription(contact.Data.Presence), mutedDescription(contact.Data), ui.PrefixForAddress(contact.Data.Address), connectionDescription(contact.Data), requestDescription(contact.Data))
 			}
 		}
 	}
@@ -211,6 +414,86 @@ func (ui *UI) ListContacts() {
 	}
 }
 
+// Describe whether the contact is muted, e.g. " (muted)"
+func mutedDescription(data *ricochet.Contact) string {
+	if data.Muted {
+		return " \x1b[90m(muted)\x1b[39m"
+	}
+	return ""
+}
+
+// Pinned contacts are listed first, then by sort index and nickname
+func contactSortsBefore(a, b *ricochet.Contact) bool {
+	if a.Pinned != b.Pinned {
+		return a.Pinned
+	} else if a.SortIndex != b.SortIndex {
+		return a.SortIndex < b.SortIndex
+	}
+	return a.Nickname < b.Nickname
+}
+
+// Describe the progress of an outbound request, e.g. " -- rejected: no thanks"
+func requestDescription(data *ricochet.Contact) string {
+	if data.Request == nil || data.Request.Direction != ricochet.ContactRequest_OUTBOUND {
+		return ""
+	}
+	switch data.Request.Phase {
+	case ricochet.ContactRequest_UNDELIVERED:
+		return " -- request not delivered yet"
+	case ricochet.ContactRequest_DELIVERED:
+		return " -- request delivered, waiting for a reply"
+	case ricochet.ContactRequest_REJECTED:
+		if data.Request.RemoteError != "" {
+			return fmt.Sprintf(" -- rejected: %s", data.Request.RemoteError)
+		}
+		return " -- rejected"
+	case ricochet.ContactRequest_ERROR:
+		return fmt.Sprintf(" -- request failed: %s", data.Request.RemoteError)
+	}
+	return ""
+}
+
+// Describe the contact's active connection, e.g. " -- connected for 12m via inbound",
+// or when it was last seen if there is none
+func connectionDescription(data *ricochet.Contact) string {
+	if data.Connection == nil {
+		if rejected, err := time.Parse(time.RFC3339, data.AuthenticationRejected); err == nil {
+			return fmt.Sprintf(" -- \x1b[31mrefused our connection %s ago\x1b[0m", durationText(time.Since(rejected)))
+		}
+		dormant := ""
+		if data.Dormant {
+			dormant = " (dormant)"
+		}
+		if lastSeen, err := time.Parse(time.RFC3339, data.LastSeen); err == nil {
+			return fmt.Sprintf(" -- last seen %s ago%s", durationText(time.Since(lastSeen)), dormant)
+		} else if data.Dormant {
+			return " -- dormant"
+		}
+		return ""
+	}
+	direction := "outbound"
+	if data.Connection.Inbound {
+		direction = "inbound"
+	}
+	when, err := time.Parse(time.RFC3339, data.Connection.WhenConnected)
+	if err != nil {
+		return fmt.Sprintf(" -- connected via %s", direction)
+	}
+	uptime := time.Since(when)
+	if uptime < time.Minute {
+		return fmt.Sprintf(" -- connected just now via %s", direction)
+	}
+	return fmt.Sprintf(" -- connected for %s via %s", durationText(uptime), direction)
+}
+
+// Format a duration to the minute, e.g. "1h12m"
+func durationText(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	return strings.TrimSuffix(d.Truncate(time.Minute).String(), "0s")
+}
+
 func (ui *UI) AddContact(params []string) {
 	var address string
 
@@ -224,16 +507,24 @@ func (ui *UI) AddContact(params []string) {
 		address = str
 	}
 
-	// XXX validate address
+	address, err := core.NormalizeAddress(address)
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
 
 	nickname, err := readline.Line("Nickname: ")
 	if err != nil {
 		return
 	}
-	fromNickname, err := readline.Line("From (your nickname): ")
+	fromNickname, err := readline.Line("From (your nickname, optional): ")
 	if err != nil {
 		return
 	}
+	if fromNickname != "" && !ui.Client.Acceptance.IsNicknameAcceptable(fromNickname) {
+		fmt.Fprintf(ui.Stdout, "Failed: Invalid 'from' nickname\n")
+		return
+	}
 	message, err := readline.Line("Message: ")
 	if err != nil {
 		return
@@ -291,21 +582,393 @@ func (ui *UI) DeleteContact(params []string) {
 	fmt.Fprintf(ui.Stdout, "Contact deleted\n")
 }
 
+func (ui *UI) ExportContacts(params []string) {
+	if len(params) < 1 || params[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: export-contacts [path]\n")
+		return
+	}
+
+	export, err := ui.Client.Backend.ExportContacts(context.Background(), &ricochet.ExportContactsRequest{})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	marshaler := jsonpb.Marshaler{Indent: "  "}
+	data, err := marshaler.MarshalToString(export)
+	if err == nil {
+		err = ioutil.WriteFile(params[0], []byte(data), 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "Exported %d contacts to %s\n", len(export.Contacts), params[0])
+}
+
+func (ui *UI) ImportContacts(params []string) {
+	if len(params) < 1 || params[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: import-contacts [path]\n")
+		return
+	}
+
+	data, err := ioutil.ReadFile(params[0])
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	var export ricochet.ContactListExport
+	if err := jsonpb.UnmarshalString(string(data), &export); err != nil {
+		fmt.Fprintf(ui.Stdout, "Invalid contacts file: %s\n", err)
+		return
+	}
+
+	reply, err := ui.Client.Backend.ImportContacts(context.Background(), &export)
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	for _, skipped := range reply.Skipped {
+		fmt.Fprintf(ui.Stdout, "    Skipped %s\n", skipped)
+	}
+	fmt.Fprintf(ui.Stdout, "Imported %d contacts\n", len(reply.Contacts))
+}
+
+func (ui *UI) RenameContact(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 2 {
+		fmt.Fprintf(ui.Stdout, "Usage: rename [address] [nickname]\n")
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(words[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(words[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", words[0])
+		return
+	}
+
+	oldNickname := contact.Data.Nickname
+	data, err := ui.Client.Backend.SetContactNickname(context.Background(),
+		&ricochet.SetContactNicknameRequest{
+			Address:  contact.Data.Address,
+			Nickname: words[1],
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ui.Stdout, "Renamed \x1b[1m%s\x1b[0m to \x1b[1m%s\x1b[0m\n", oldNickname, data.Nickname)
+}
+
+// Pin or unpin a contact, optionally with a sort index for its position
+func (ui *UI) SetContactPinned(params []string, pinned bool) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	var sortIndex int64
+	var err error
+	if len(words) > 1 && pinned {
+		sortIndex, err = strconv.ParseInt(words[1], 10, 32)
+	}
+	if len(words) < 1 || words[0] == "" || err != nil || (len(words) > 1 && !pinned) {
+		if pinned {
+			fmt.Fprintf(ui.Stdout, "Usage: pin [address] [index]\n")
+		} else {
+			fmt.Fprintf(ui.Stdout, "Usage: unpin [address]\n")
+		}
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(words[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(words[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", words[0])
+		return
+	}
+
+	_, err = ui.Client.Backend.SetContactOrder(context.Background(),
+		&ricochet.SetContactOrderRequest{
+			Address:   contact.Data.Address,
+			Pinned:    pinned,
+			SortIndex: int32(sortIndex),
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if pinned {
+		fmt.Fprintf(ui.Stdout, "Pinned \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+	} else {
+		fmt.Fprintf(ui.Stdout, "Unpinned \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+	}
+}
+
+// Search the open conversation, or the conversation with a contact given by
+// address, for messages containing some text
+// Reply to the most recent message from the current contact
+func (ui *UI) Reply(params []string) {
+	contact := ui.CurrentContact
+	if contact == nil || len(params) < 1 || params[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: reply [text], in a conversation\n")
+		return
+	}
+	replyTo := contact.Conversation.LastReceivedMessage()
+	if replyTo == nil {
+		fmt.Fprintf(ui.Stdout, "No message from %s to reply to\n", contact.Data.Nickname)
+		return
+	}
+	contact.Conversation.SendReply(params[0], replyTo)
+}
+
+func (ui *UI) Search(params []string) {
+	contact := ui.CurrentContact
+	query := ""
+	if len(params) > 0 {
+		query = params[0]
+	}
+	if contact == nil {
+		words := strings.SplitN(query, " ", 2)
+		if len(words) == 2 {
+			contact = ui.Client.Contacts.ByAddress(words[0])
+			if contact == nil {
+				contact, _ = ui.EntityByPrefix(words[0])
+			}
+			query = words[1]
+		}
+		if contact == nil {
+			fmt.Fprintf(ui.Stdout, "Usage: search [address] [text]\n")
+			return
+		}
+	}
+	if query == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: search [text]\n")
+		return
+	}
+
+	contact.Conversation.PrintSearch(query)
+}
+
+// Scroll pages through the current conversation's backlog
+func (ui *UI) Scroll(older bool) {
+	if ui.CurrentContact == nil {
+		if older {
+			fmt.Fprintf(ui.Stdout, "Usage: older, in a conversation\n")
+		} else {
+			fmt.Fprintf(ui.Stdout, "Usage: newer, in a conversation\n")
+		}
+		return
+	}
+	if older {
+		ui.CurrentContact.Conversation.ScrollOlder()
+	} else {
+		ui.CurrentContact.Conversation.ScrollNewer()
+	}
+}
+
+func (ui *UI) ContactDiagnostics(params []string) {
+	request := &ricochet.ContactDiagnosticsRequest{}
+	if len(params) > 0 && params[0] != "" {
+		contact := ui.Client.Contacts.ByAddress(params[0])
+		if contact == nil {
+			contact, _ = ui.EntityByPrefix(params[0])
+		}
+		if contact == nil {
+			fmt.Fprintf(ui.Stdout, "No contact with address %s\n", params[0])
+			return
+		}
+		request.Address = contact.Data.Address
+	}
+
+	reply, err := ui.Client.Backend.GetContactDiagnostics(context.Background(), request)
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	for _, diag := range reply.Contacts {
+		fmt.Fprintf(ui.Stdout, "\x1b[1m%s\x1b[0m (%s) -- %s\n", diag.Nickname, diag.Address, ColoredContactStatus(diag.Status))
+		connection := "none"
+		if diag.Connected {
+			direction := "outbound"
+			if diag.Inbound {
+				direction = "inbound"
+			}
+			connection = fmt.Sprintf("%s since %s", direction, diag.WhenConnected)
+		}
+		fmt.Fprintf(ui.Stdout, "    connection:\t%s\n", connection)
+		outbound := "not attempting"
+		if diag.OutboundAuthenticating {
+			outbound = "authenticating"
+		} else if diag.OutboundAttempting {
+			outbound = "attempting"
+		}
+		if !diag.ConnectionsEnabled {
+			outbound += " (connections disabled)"
+		}
+		fmt.Fprintf(ui.Stdout, "    outbound:\t%s\n", outbound)
+		fmt.Fprintf(ui.Stdout, "    unread:\t%d\n", diag.UnreadCount)
+		if diag.QueuedCount > 0 {
+			fmt.Fprintf(ui.Stdout, "    queued:\t%d, oldest from %s\n", diag.QueuedCount, time.Unix(diag.OldestQueued, 0).Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(ui.Stdout, "    queued:\t0\n")
+		}
+	}
+}
+
+func (ui *UI) SetContactBlocked(params []string, blocked bool) {
+	command := "block"
+	if !blocked {
+		command = "unblock"
+	}
+	if len(params) < 1 {
+		fmt.Fprintf(ui.Stdout, "Usage: %s [address]\n", command)
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(params[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(params[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.SetContactBlocked(context.Background(),
+		&ricochet.SetContactBlockedRequest{
+			Address: contact.Data.Address,
+			Blocked: blocked,
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if blocked {
+		fmt.Fprintf(ui.Stdout, "Blocked \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+	} else {
+		fmt.Fprintf(ui.Stdout, "Unblocked \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+	}
+}
+
+func (ui *UI) SetContactConnectOnStartup(params []string, enabled bool) {
+	command := "dormant"
+	if enabled {
+		command = "autoconnect"
+	}
+	if len(params) < 1 {
+		fmt.Fprintf(ui.Stdout, "Usage: %s [address]\n", command)
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(params[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(params[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.SetContactConnectOnStartup(context.Background(),
+		&ricochet.SetContactConnectOnStartupRequest{
+			Address:          contact.Data.Address,
+			ConnectOnStartup: enabled,
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if enabled {
+		fmt.Fprintf(ui.Stdout, "Connecting to \x1b[1m%s\x1b[0m on startup\n", contact.Data.Nickname)
+	} else {
+		fmt.Fprintf(ui.Stdout, "\x1b[1m%s\x1b[0m is dormant, and won't be connected on startup; use 'wake' to connect\n", contact.Data.Nickname)
+	}
+}
+
+func (ui *UI) SetContactMuted(params []string, muted bool) {
+	command := "unmute"
+	if muted {
+		command = "mute"
+	}
+	if len(params) < 1 {
+		fmt.Fprintf(ui.Stdout, "Usage: %s [address]\n", command)
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(params[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(params[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.SetContactMuted(context.Background(),
+		&ricochet.SetContactMutedRequest{
+			Address: contact.Data.Address,
+			Muted:   muted,
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if muted {
+		fmt.Fprintf(ui.Stdout, "Muted \x1b[1m%s\x1b[0m; new messages are still received, but not announced\n", contact.Data.Nickname)
+	} else {
+		fmt.Fprintf(ui.Stdout, "Unmuted \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+	}
+}
+
+func (ui *UI) ConnectContact(params []string) {
+	if len(params) < 1 {
+		fmt.Fprintf(ui.Stdout, "Usage: wake [address]\n")
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(params[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(params[0])
+	}
+	if contact == nil {
+		fmt.Fprintf(ui.Stdout, "No contact with address %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.ConnectContact(context.Background(),
+		&ricochet.ConnectContactRequest{Address: contact.Data.Address})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	fmt.Fprintf(ui.Stdout, "Connecting to \x1b[1m%s\x1b[0m\n", contact.Data.Nickname)
+}
+
 // This type acts as a readline Listener and handles special behavior for
 // the prompt in a conversation. In particular, it swaps temporarily back to
 // the normal prompt for command lines (starting with /), and it keeps the
-// timestamp in the conversation prompt updated.
+// timestamp and typing indicator in the conversation prompt updated.
 type conversationInputConfig struct {
-	Input      *readline.Instance
-	Config     *readline.Config
-	BaseConfig *readline.Config
-	PromptFmt  string
+	Input        *readline.Instance
+	Config       *readline.Config
+	BaseConfig   *readline.Config
+	PromptFmt    string
+	Conversation *Conversation
 
 	CommandModeFlag *bool
 	editingLine     []rune
 
 	usingConfig     bool
 	stopPromptTimer chan struct{}
+	refreshPrompt   chan struct{}
 }
 
 func (cc *conversationInputConfig) OnChange(line []rune, pos int, key rune) ([]rune, int, bool) {
@@ -316,6 +979,10 @@ func (cc *conversationInputConfig) OnChange(line []rune, pos int, key rune) ([]r
 		cc.Install()
 	}
 
+	if cc.usingConfig {
+		cc.Conversation.SetTyping(len(line) > 0)
+	}
+
 	return line, pos, true
 }
 
@@ -348,6 +1015,7 @@ func (cc *conversationInputConfig) Install() {
 		cc.Config.FuncFilterInputRune = cc.FilterInputRune
 		cc.Input.SetConfig(cc.Config)
 		cc.stopPromptTimer = make(chan struct{})
+		cc.refreshPrompt = make(chan struct{}, 1)
 		go cc.updatePromptTimer()
 		*cc.CommandModeFlag = false
 	}
@@ -366,16 +1034,32 @@ func (cc *conversationInputConfig) Remove() {
 	}
 }
 
+// Redraw the prompt, e.g. after the typing indicator has changed
+func (cc *conversationInputConfig) Refresh() {
+	if cc.usingConfig {
+		select {
+		case cc.refreshPrompt <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (cc *conversationInputConfig) updatePromptTimer() {
 	for {
 		t := time.Now()
-		cc.Input.SetPrompt(fmt.Sprintf(cc.PromptFmt, t.Format("15:04")))
+		typing := ""
+		if cc.Conversation.RemoteTyping() {
+			typing = " \x1b[90m(typing)\x1b[39m"
+		}
+		cc.Input.SetPrompt(fmt.Sprintf(cc.PromptFmt, t.Format("15:04"), typing))
 		cc.Input.Refresh()
 
 		sec := 61 - t.Second()
 		select {
 		case <-time.After(time.Duration(sec) * time.Second):
 			continue
+		case <-cc.refreshPrompt:
+			continue
 		case <-cc.stopPromptTimer:
 			return
 		}
@@ -391,13 +1075,21 @@ func (ui *UI) setupConversationPrompt() {
 		Input:           ui.Input,
 		Config:          ui.baseChatConfig.Clone(),
 		BaseConfig:      ui.baseConfig,
-		PromptFmt:       fmt.Sprintf(ui.baseChatConfig.Prompt, "%s", ui.CurrentContact.Data.Nickname),
+		PromptFmt:       fmt.Sprintf(ui.baseChatConfig.Prompt, "%s", strings.Replace(ui.CurrentContact.Data.Nickname, "%", "%%", -1)+"%s"),
+		Conversation:    ui.CurrentContact.Conversation,
 		CommandModeFlag: &ui.commandMode,
 	}
 	listener.Config.Listener = listener
 	listener.Install()
 }
 
+// Redraw the conversation prompt, if a conversation is open
+func (ui *UI) RefreshConversationPrompt() {
+	if listener, ok := ui.Input.Config.Listener.(*conversationInputConfig); ok {
+		listener.Refresh()
+	}
+}
+
 func ColoredContactStatus(status ricochet.Contact_Status) string {
 	switch status {
 	case ricochet.Contact_UNKNOWN:
@@ -406,24 +1098,28 @@ func ColoredContactStatus(status ricochet.Contact_Status) string {
 		return "\x1b[31moffline\x1b[39m"
 	case ricochet.Contact_ONLINE:
 		return "\x1b[32monline\x1b[39m"
+	case ricochet.Contact_CONNECTING:
+		return "\x1b[33mconnecting\x1b[39m"
 	case ricochet.Contact_REQUEST:
 		return "\x1b[33mcontact request\x1b[39m"
 	case ricochet.Contact_REJECTED:
 		return "\x1b[31mrejected\x1b[39m"
+	case ricochet.Contact_BLOCKED:
+		return "\x1b[90mblocked\x1b[39m"
 	default:
 		return status.String()
 	}
 }
 
 func (ui *UI) EntityByPrefix(prefix string) (*Contact, *ricochet.ContactRequest) {
-	if len(prefix) < MinContactPrefix || len(prefix) > 16 {
+	if len(prefix) < MinContactPrefix {
 		return nil, nil
 	}
 
 	var contact *Contact
 	for _, c := range ui.Client.Contacts.Contacts {
 		host, _ := core.PlainHostFromAddress(c.Data.Address)
-		if prefix == host[:len(prefix)] {
+		if strings.HasPrefix(host, prefix) {
 			if contact != nil {
 				// Ambiguous prefix
 				return nil, nil
@@ -435,7 +1131,7 @@ func (ui *UI) EntityByPrefix(prefix string) (*Contact, *ricochet.ContactRequest)
 	var request *ricochet.ContactRequest
 	for _, r := range ui.Client.Contacts.Requests {
 		host, _ := core.PlainHostFromAddress(r.Address)
-		if prefix == host[:len(prefix)] {
+		if strings.HasPrefix(host, prefix) {
 			if contact != nil || request != nil {
 				return nil, nil
 			}
@@ -455,7 +1151,7 @@ func (ui *UI) PrefixForAddress(address string) string {
 		if cHost == host {
 			continue
 		}
-		for prefix == cHost[:len(prefix)] && len(prefix) < len(host) {
+		for strings.HasPrefix(cHost, prefix) && len(prefix) < len(host) {
 			prefix = host[:len(prefix)+1]
 		}
 	}
@@ -465,7 +1161,7 @@ func (ui *UI) PrefixForAddress(address string) string {
 		if rHost == host {
 			continue
 		}
-		for prefix == rHost[:len(prefix)] && len(prefix) < len(host) {
+		for strings.HasPrefix(rHost, prefix) && len(prefix) < len(host) {
 			prefix = host[:len(prefix)+1]
 		}
 	}
@@ -510,10 +1206,18 @@ func (ui *UI) ShowContactRequest(request *ricochet.ContactRequest) {
 	}
 
 	if strings.HasPrefix("reject", action) {
-		_, err := ui.Client.Backend.RejectInboundRequest(context.Background(), request)
-		if err != nil {
-			fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		var reason string
+		for {
+			reason, err = readline.Line("reason (optional): ")
+			if err != nil {
+				return
+			} else if reason != "" && !ui.Client.Acceptance.IsRejectReasonAcceptable(reason) {
+				fmt.Fprintf(ui.Stdout, "Invalid reason; it may be at most %d bytes\n", ui.Client.Acceptance.MaxRejectReasonLength)
+				continue
+			}
+			break
 		}
+		ui.rejectContactRequest(request, reason)
 		return
 	} else if !strings.HasPrefix("accept", action) {
 		// Anything other than accept is wait
@@ -521,27 +1225,214 @@ func (ui *UI) ShowContactRequest(request *ricochet.ContactRequest) {
 		return
 	}
 
+	var nickname string
 	for {
-		nickname, err := readline.Line("nickname: ")
+		nickname, err = readline.Line("nickname: ")
 		if err != nil {
 			return
 		} else if nickname == "" {
 			fmt.Fprintf(ui.Stdout, "Aborted.\n")
 			return
-		} else if !core.IsNicknameAcceptable(nickname) {
+		} else if !ui.Client.Acceptance.IsNicknameAcceptable(nickname) {
 			fmt.Fprintf(ui.Stdout, "Invalid nickname '%s'\n", nickname)
 			continue
 		} else {
-			request.FromNickname = nickname
 			break
 		}
 	}
 
-	_, err = ui.Client.Backend.AcceptInboundRequest(context.Background(), request)
+	ui.acceptContactRequest(request, nickname)
+}
+
+// Find a pending inbound request by its address or a prefix of it
+func (ui *UI) requestByAddress(address string) *ricochet.ContactRequest {
+	if request := ui.Client.Contacts.Requests[address]; request != nil {
+		return request
+	}
+	_, request := ui.EntityByPrefix(address)
+	return request
+}
+
+func (ui *UI) ListContactRequests() {
+	if len(ui.Client.Contacts.Requests) == 0 {
+		fmt.Fprintf(ui.Stdout, "No contact requests are waiting\n")
+		return
+	}
+
+	requests := make([]*ricochet.ContactRequest, 0, len(ui.Client.Contacts.Requests))
+	for _, request := range ui.Client.Contacts.Requests {
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].WhenCreated < requests[j].WhenCreated })
+
+	for _, request := range requests {
+		state := "connected, waiting for your reply"
+		if !request.Connected {
+			state = "disconnected"
+		}
+		// XXX Proper output sanitization here
+		fmt.Fprintf(ui.Stdout, "    \x1b[1m%s\x1b[0m (\x1b[1m%s\x1b[0m) -- %s\n", request.Address, ui.PrefixForAddress(request.Address), state)
+		if len(request.FromNickname) > 0 {
+			fmt.Fprintf(ui.Stdout, "        Name:\t%s\n", request.FromNickname)
+		}
+		if len(request.Text) > 0 {
+			fmt.Fprintf(ui.Stdout, "        Message:\t%s\n", request.Text)
+		}
+	}
+}
+
+func (ui *UI) AcceptContactRequest(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 1 || words[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: accept-request [address] [nickname]\n")
+		return
+	}
+	request := ui.requestByAddress(words[0])
+	if request == nil {
+		fmt.Fprintf(ui.Stdout, "No contact request from %s\n", words[0])
+		return
+	}
+
+	nickname := request.FromNickname
+	if len(words) > 1 {
+		nickname = words[1]
+	}
+	if nickname == "" {
+		fmt.Fprintf(ui.Stdout, "The request has no name; give a nickname for the contact\n")
+		return
+	} else if !ui.Client.Acceptance.IsNicknameAcceptable(nickname) {
+		fmt.Fprintf(ui.Stdout, "Invalid nickname '%s'\n", nickname)
+		return
+	}
+	ui.acceptContactRequest(request, nickname)
+}
+
+func (ui *UI) RejectContactRequest(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 1 || words[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: reject-request [address] [reason]\n")
+		return
+	}
+	request := ui.requestByAddress(words[0])
+	if request == nil {
+		fmt.Fprintf(ui.Stdout, "No contact request from %s\n", words[0])
+		return
+	}
+
+	var reason string
+	if len(words) > 1 {
+		reason = words[1]
+		if !ui.Client.Acceptance.IsRejectReasonAcceptable(reason) {
+			fmt.Fprintf(ui.Stdout, "Invalid reason; it may be at most %d bytes\n", ui.Client.Acceptance.MaxRejectReasonLength)
+			return
+		}
+	}
+	ui.rejectContactRequest(request, reason)
+}
+
+// Change the message of a contact request we sent that hasn't been delivered
+// yet, keeping the nickname we present
+func (ui *UI) EditContactRequest(params []string) {
+	var words []string
+	if len(params) > 0 {
+		words = strings.SplitN(params[0], " ", 2)
+	}
+	if len(words) < 1 || words[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: edit-request [address] [message]\n")
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(words[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(words[0])
+	}
+	if contact == nil || contact.Data.Request == nil {
+		fmt.Fprintf(ui.Stdout, "No pending contact request to %s\n", words[0])
+		return
+	}
+	var text string
+	if len(words) > 1 {
+		text = words[1]
+	}
+
+	_, err := ui.Client.Backend.EditOutboundRequest(context.Background(),
+		&ricochet.ContactRequest{
+			Direction:    ricochet.ContactRequest_OUTBOUND,
+			Address:      contact.Data.Address,
+			FromNickname: contact.Data.Request.FromNickname,
+			Text:         text,
+		})
 	if err != nil {
 		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
 		return
 	}
+	fmt.Fprintf(ui.Stdout, "Changed contact request to \x1b[1m%s\x1b[0m\n", contact.Data.Address)
+}
 
-	fmt.Fprintf(ui.Stdout, "Accepted!\n")
+// Withdraw a contact request we sent that hasn't been accepted yet, which also
+// removes the contact
+func (ui *UI) CancelContactRequest(params []string) {
+	if len(params) < 1 || params[0] == "" {
+		fmt.Fprintf(ui.Stdout, "Usage: cancel-request [address]\n")
+		return
+	}
+	contact := ui.Client.Contacts.ByAddress(params[0])
+	if contact == nil {
+		contact, _ = ui.EntityByPrefix(params[0])
+	}
+	if contact == nil || contact.Data.Request == nil {
+		fmt.Fprintf(ui.Stdout, "No pending contact request to %s\n", params[0])
+		return
+	}
+
+	_, err := ui.Client.Backend.CancelOutboundRequest(context.Background(),
+		&ricochet.ContactRequest{
+			Direction: ricochet.ContactRequest_OUTBOUND,
+			Address:   contact.Data.Address,
+		})
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+	fmt.Fprintf(ui.Stdout, "Cancelled contact request to \x1b[1m%s\x1b[0m\n", contact.Data.Address)
+}
+
+// Accept the request, which adds the requester as a contact. If they are still
+// connected, that connection is used for the contact; otherwise, the backend
+// connects to them, which they'll also take as accepting.
+func (ui *UI) acceptContactRequest(request *ricochet.ContactRequest, nickname string) {
+	reply := *request
+	reply.FromNickname = nickname
+	_, err := ui.Client.Backend.AcceptInboundRequest(context.Background(), &reply)
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if request.Connected {
+		fmt.Fprintf(ui.Stdout, "Accepted!\n")
+	} else {
+		fmt.Fprintf(ui.Stdout, "Accepted; \x1b[1m%s\x1b[0m will be added when they are next online\n", nickname)
+	}
+}
+
+func (ui *UI) rejectContactRequest(request *ricochet.ContactRequest, reason string) {
+	reply := *request
+	reply.RejectReason = reason
+	_, err := ui.Client.Backend.RejectInboundRequest(context.Background(), &reply)
+	if err != nil {
+		fmt.Fprintf(ui.Stdout, "Failed: %s\n", err)
+		return
+	}
+
+	if reason != "" && !request.Connected {
+		fmt.Fprintf(ui.Stdout, "Rejected; the reason couldn't be sent, because they are not connected\n")
+	} else {
+		fmt.Fprintf(ui.Stdout, "Rejected\n")
+	}
 }
@@ -13,10 +13,41 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+type KeyStorage_Type int32
+
+const (
+	// In secrets.servicePrivateKey of the config
+	KeyStorage_CONFIG KeyStorage_Type = 0
+	// In a separate file, encrypted with a passphrase that is given each
+	// time the backend starts
+	KeyStorage_ENCRYPTED_FILE KeyStorage_Type = 1
+)
+
+var KeyStorage_Type_name = map[int32]string{
+	0: "CONFIG",
+	1: "ENCRYPTED_FILE",
+}
+var KeyStorage_Type_value = map[string]int32{
+	"CONFIG":         0,
+	"ENCRYPTED_FILE": 1,
+}
+
+func (x KeyStorage_Type) String() string {
+	return proto.EnumName(KeyStorage_Type_name, int32(x))
+}
+func (KeyStorage_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor5, []int{1, 0} }
+
 type Config struct {
 	Identity *Identity           `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
 	Contacts map[string]*Contact `protobuf:"bytes,2,rep,name=contacts" json:"contacts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Secrets  *Secrets            `protobuf:"bytes,3,opt,name=secrets" json:"secrets,omitempty"`
+	// Port of the ricochet service, both for our own onion service and for
+	// connections to contacts. The standard port (9878) is used if unset.
+	ServicePort          uint32                `protobuf:"varint,4,opt,name=servicePort" json:"servicePort,omitempty"`
+	ContactRequestPolicy *ContactRequestPolicy `protobuf:"bytes,5,opt,name=contactRequestPolicy" json:"contactRequestPolicy,omitempty"`
+	ConnectionMode       *ConnectionMode       `protobuf:"bytes,6,opt,name=connectionMode" json:"connectionMode,omitempty"`
+	Presence             *Presence             `protobuf:"bytes,7,opt,name=presence" json:"presence,omitempty"`
+	KeyStorage           *KeyStorage           `protobuf:"bytes,8,opt,name=keyStorage" json:"keyStorage,omitempty"`
 }
 
 func (m *Config) Reset()                    { *m = Config{} }
@@ -45,6 +76,67 @@ func (m *Config) GetSecrets() *Secrets {
 	return nil
 }
 
+func (m *Config) GetServicePort() uint32 {
+	if m != nil {
+		return m.ServicePort
+	}
+	return 0
+}
+
+func (m *Config) GetContactRequestPolicy() *ContactRequestPolicy {
+	if m != nil {
+		return m.ContactRequestPolicy
+	}
+	return nil
+}
+
+func (m *Config) GetConnectionMode() *ConnectionMode {
+	if m != nil {
+		return m.ConnectionMode
+	}
+	return nil
+}
+
+func (m *Config) GetPresence() *Presence {
+	if m != nil {
+		return m.Presence
+	}
+	return nil
+}
+
+func (m *Config) GetKeyStorage() *KeyStorage {
+	if m != nil {
+		return m.KeyStorage
+	}
+	return nil
+}
+
+// Where the identity's private key is kept
+type KeyStorage struct {
+	Type KeyStorage_Type `protobuf:"varint,1,opt,name=type,enum=ricochet.KeyStorage_Type" json:"type,omitempty"`
+	// Absolute path of the file for ENCRYPTED_FILE
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *KeyStorage) Reset()                    { *m = KeyStorage{} }
+func (m *KeyStorage) String() string            { return proto.CompactTextString(m) }
+func (*KeyStorage) ProtoMessage()               {}
+func (*KeyStorage) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{1} }
+
+func (m *KeyStorage) GetType() KeyStorage_Type {
+	if m != nil {
+		return m.Type
+	}
+	return KeyStorage_CONFIG
+}
+
+func (m *KeyStorage) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
 // Secrets are not transmitted to frontend RPC clients
 type Secrets struct {
 	ServicePrivateKey []byte `protobuf:"bytes,1,opt,name=servicePrivateKey,proto3" json:"servicePrivateKey,omitempty"`
@@ -53,7 +145,7 @@ type Secrets struct {
 func (m *Secrets) Reset()                    { *m = Secrets{} }
 func (m *Secrets) String() string            { return proto.CompactTextString(m) }
 func (*Secrets) ProtoMessage()               {}
-func (*Secrets) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{1} }
+func (*Secrets) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{2} }
 
 func (m *Secrets) GetServicePrivateKey() []byte {
 	if m != nil {
@@ -62,28 +154,83 @@ func (m *Secrets) GetServicePrivateKey() []byte {
 	return nil
 }
 
+// History is the persistent message history, which is stored in a separate
+// file from Config
+type History struct {
+	// Keyed by contact address
+	Conversations map[string]*MessageList `protobuf:"bytes,1,rep,name=conversations" json:"conversations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *History) Reset()                    { *m = History{} }
+func (m *History) String() string            { return proto.CompactTextString(m) }
+func (*History) ProtoMessage()               {}
+func (*History) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{3} }
+
+func (m *History) GetConversations() map[string]*MessageList {
+	if m != nil {
+		return m.Conversations
+	}
+	return nil
+}
+
+type MessageList struct {
+	Messages []*Message `protobuf:"bytes,1,rep,name=messages" json:"messages,omitempty"`
+}
+
+func (m *MessageList) Reset()                    { *m = MessageList{} }
+func (m *MessageList) String() string            { return proto.CompactTextString(m) }
+func (*MessageList) ProtoMessage()               {}
+func (*MessageList) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{4} }
+
+func (m *MessageList) GetMessages() []*Message {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Config)(nil), "ricochet.Config")
+	proto.RegisterType((*KeyStorage)(nil), "ricochet.KeyStorage")
 	proto.RegisterType((*Secrets)(nil), "ricochet.Secrets")
+	proto.RegisterType((*History)(nil), "ricochet.History")
+	proto.RegisterType((*MessageList)(nil), "ricochet.MessageList")
+	proto.RegisterEnum("ricochet.KeyStorage_Type", KeyStorage_Type_name, KeyStorage_Type_value)
 }
 
 func init() { proto.RegisterFile("config.proto", fileDescriptor5) }
 
 var fileDescriptor5 = []byte{
-	// 235 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x49, 0xce, 0xcf, 0x4b,
-	0xcb, 0x4c, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x28, 0xca, 0x4c, 0xce, 0x4f, 0xce,
-	0x48, 0x2d, 0x91, 0xe2, 0x4d, 0xce, 0xcf, 0x2b, 0x49, 0x4c, 0x2e, 0x81, 0x48, 0x48, 0xf1, 0x65,
-	0xa6, 0xa4, 0xe6, 0x95, 0x64, 0x96, 0x54, 0x42, 0xf8, 0x4a, 0x1f, 0x19, 0xb9, 0xd8, 0x9c, 0xc1,
-	0x3a, 0x85, 0xf4, 0xb8, 0x38, 0x60, 0x92, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0xdc, 0x46, 0x42, 0x7a,
-	0x30, 0x63, 0xf4, 0x3c, 0xa1, 0x32, 0x41, 0x70, 0x35, 0x42, 0x56, 0x5c, 0x1c, 0x50, 0xb3, 0x8b,
-	0x25, 0x98, 0x14, 0x98, 0x35, 0xb8, 0x8d, 0xe4, 0x10, 0xea, 0x21, 0x66, 0x82, 0x28, 0xb0, 0x02,
-	0xd7, 0xbc, 0x92, 0xa2, 0xca, 0x20, 0xb8, 0x7a, 0x21, 0x6d, 0x2e, 0xf6, 0xe2, 0xd4, 0xe4, 0xa2,
-	0xd4, 0x92, 0x62, 0x09, 0x66, 0xb0, 0x55, 0x82, 0x08, 0xad, 0xc1, 0x10, 0x89, 0x20, 0x98, 0x0a,
-	0x29, 0x3f, 0x2e, 0x5e, 0x14, 0x73, 0x84, 0x04, 0xb8, 0x98, 0xb3, 0x53, 0x21, 0x8e, 0xe4, 0x0c,
-	0x02, 0x31, 0x85, 0xd4, 0xb9, 0x58, 0xcb, 0x12, 0x73, 0x4a, 0x53, 0x25, 0x98, 0xd0, 0x4d, 0x83,
-	0xea, 0x0c, 0x82, 0xc8, 0x5b, 0x31, 0x59, 0x30, 0x2a, 0x99, 0x73, 0xb1, 0x43, 0xed, 0x10, 0xd2,
-	0xe1, 0x12, 0x2c, 0x4e, 0x2d, 0x2a, 0xcb, 0x4c, 0x4e, 0x0d, 0x28, 0xca, 0x2c, 0x4b, 0x2c, 0x49,
-	0xf5, 0x86, 0x9a, 0xcb, 0x13, 0x84, 0x29, 0x91, 0xc4, 0x06, 0x0e, 0x33, 0x63, 0x40, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0x42, 0xba, 0x23, 0x37, 0x6c, 0x01, 0x00, 0x00,
+	// 496 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x6c, 0x93, 0xd1, 0x6e, 0xd3, 0x30,
+	0x14, 0x86, 0xc9, 0xda, 0xb5, 0xdd, 0xe9, 0x5a, 0xb5, 0x47, 0x43, 0x32, 0xbd, 0x40, 0x55, 0x85,
+	0xa0, 0xd2, 0x58, 0x2e, 0x0a, 0x12, 0x68, 0xe2, 0x02, 0xa9, 0x74, 0x50, 0xb6, 0x95, 0xca, 0x9b,
+	0x84, 0xb8, 0x42, 0xc1, 0x3b, 0x74, 0xd6, 0x46, 0x5c, 0x6c, 0xaf, 0x28, 0x4f, 0xc1, 0x9b, 0xf0,
+	0x8c, 0xa8, 0x4e, 0xd2, 0x24, 0x4d, 0xef, 0x6c, 0xff, 0xdf, 0xf9, 0xcf, 0xc9, 0x6f, 0x07, 0x0e,
+	0x85, 0x0a, 0x7f, 0xca, 0x85, 0xbf, 0xd4, 0xca, 0x2a, 0x6c, 0x68, 0x29, 0x94, 0xb8, 0x25, 0xdb,
+	0x6b, 0x09, 0x15, 0xda, 0x40, 0xd8, 0x58, 0xe8, 0xa1, 0x50, 0xe1, 0x8a, 0xb4, 0x09, 0xac, 0x54,
+	0x61, 0x72, 0xd6, 0x96, 0x37, 0x14, 0x5a, 0x69, 0xa3, 0x78, 0x3f, 0xf8, 0x5b, 0x85, 0xda, 0xd8,
+	0xb9, 0xa1, 0x0f, 0x8d, 0x54, 0x64, 0x5e, 0xdf, 0x1b, 0x36, 0x47, 0xe8, 0xa7, 0xd6, 0xfe, 0x34,
+	0x51, 0xf8, 0x86, 0xc1, 0x53, 0x68, 0x24, 0xfd, 0x0c, 0xdb, 0xeb, 0x57, 0x86, 0xcd, 0xd1, 0xd3,
+	0x8c, 0x8f, 0x3d, 0xfd, 0x71, 0x02, 0x4c, 0x42, 0xab, 0x23, 0xbe, 0xe1, 0xf1, 0x18, 0xea, 0x86,
+	0x84, 0x26, 0x6b, 0x58, 0xc5, 0xb5, 0xea, 0x66, 0xa5, 0x57, 0xb1, 0xc0, 0x53, 0x02, 0xfb, 0xd0,
+	0x34, 0xa4, 0x57, 0x52, 0xd0, 0x5c, 0x69, 0xcb, 0xaa, 0x7d, 0x6f, 0xd8, 0xe2, 0xf9, 0x23, 0xe4,
+	0x70, 0x94, 0x58, 0x73, 0xfa, 0xfd, 0x40, 0xc6, 0xce, 0xd5, 0xbd, 0x14, 0x11, 0xdb, 0xef, 0x7b,
+	0xa5, 0xb1, 0x4a, 0x14, 0xdf, 0x59, 0x8b, 0xef, 0xa1, 0x2d, 0x54, 0x18, 0x92, 0x58, 0xa7, 0x77,
+	0xa9, 0x6e, 0x88, 0xd5, 0x9c, 0x1b, 0x2b, 0xb8, 0xe5, 0x74, 0xbe, 0xc5, 0xaf, 0x03, 0x5d, 0x6a,
+	0x32, 0x14, 0x0a, 0x62, 0xf5, 0xed, 0x40, 0xe7, 0x89, 0xc2, 0x37, 0x0c, 0xbe, 0x06, 0xb8, 0xa3,
+	0xe8, 0xca, 0x2a, 0x1d, 0x2c, 0x88, 0x35, 0x5c, 0xc5, 0x51, 0x56, 0x71, 0xbe, 0xd1, 0x78, 0x8e,
+	0xeb, 0xcd, 0xa0, 0x55, 0x48, 0x19, 0x3b, 0x50, 0xb9, 0xa3, 0xf8, 0x0a, 0x0f, 0xf8, 0x7a, 0x89,
+	0x2f, 0x60, 0x7f, 0x15, 0xdc, 0x3f, 0x10, 0xdb, 0xdb, 0xce, 0x3a, 0xcd, 0x23, 0xd6, 0x4f, 0xf7,
+	0xde, 0x7a, 0x83, 0x3f, 0x00, 0x59, 0x27, 0x3c, 0x81, 0xaa, 0x8d, 0x96, 0xe4, 0xdc, 0xda, 0xa3,
+	0x27, 0xbb, 0xa6, 0xf1, 0xaf, 0xa3, 0x25, 0x71, 0x87, 0x21, 0x42, 0x75, 0x19, 0xd8, 0x5b, 0xd7,
+	0xe8, 0x80, 0xbb, 0xf5, 0xe0, 0x39, 0x54, 0xd7, 0x04, 0x02, 0xd4, 0xc6, 0x5f, 0x66, 0x67, 0xd3,
+	0x8f, 0x9d, 0x47, 0x88, 0xd0, 0x9e, 0xcc, 0xc6, 0xfc, 0xdb, 0xfc, 0x7a, 0xf2, 0xe1, 0xfb, 0xd9,
+	0xf4, 0x62, 0xd2, 0xf1, 0x06, 0x6f, 0xa0, 0x9e, 0x5c, 0x3d, 0xbe, 0x84, 0x6e, 0x7a, 0xbd, 0x5a,
+	0xae, 0x02, 0x4b, 0xe7, 0xc9, 0x07, 0x1d, 0xf2, 0xb2, 0x30, 0xf8, 0xe7, 0x41, 0xfd, 0x93, 0x34,
+	0x56, 0xe9, 0x08, 0x3f, 0x43, 0x2b, 0xff, 0xea, 0x0d, 0xf3, 0xdc, 0xcb, 0x7c, 0x96, 0x0d, 0x9e,
+	0x90, 0xfe, 0x38, 0x8f, 0xc5, 0xef, 0xb3, 0x58, 0xda, 0xfb, 0x0a, 0x58, 0x86, 0x76, 0xc4, 0x7b,
+	0x5c, 0x8c, 0xf7, 0x71, 0xd6, 0xeb, 0x92, 0x8c, 0x09, 0x16, 0x74, 0x21, 0x4d, 0x21, 0xe2, 0x77,
+	0xd0, 0xcc, 0x29, 0x78, 0x02, 0x8d, 0x5f, 0xf1, 0x36, 0x1d, 0xb7, 0x5b, 0xb2, 0xe0, 0x1b, 0xe4,
+	0x47, 0xcd, 0xfd, 0xb9, 0xaf, 0xfe, 0x0f, 0x00, 0xa1, 0x20, 0xd8, 0x26, 0x06, 0x04, 0x00, 0x00,
 }
@@ -12,35 +12,91 @@ It is generated from these files:
 	identity.proto
 	network.proto
 	config.proto
+	filetransfer.proto
 
 It has these top-level messages:
 	Contact
+	ContactCapabilities
+	Presence
+	PresenceRequest
+	ContactConnection
 	ContactRequest
 	MonitorContactsRequest
 	ContactEvent
 	AddContactReply
+	GetContactRequest
 	DeleteContactRequest
 	DeleteContactReply
 	RejectInboundRequestReply
+	CancelOutboundRequestReply
+	ContactListExport
+	ContactExport
+	ExportContactsRequest
+	ImportContactsReply
+	SetContactNicknameRequest
+	SetContactOrderRequest
+	SetContactBlockedRequest
+	SetContactConnectOnStartupRequest
+	ConnectContactRequest
+	SetContactMutedRequest
+	ContactDiagnosticsRequest
+	ContactDiagnostics
+	ContactDiagnosticsReply
 	ConversationEvent
 	MonitorConversationsRequest
 	Entity
 	Message
+	MessageReference
+	UnreadCountRequest
+	UnreadCountReply
+	QueuedMessagesRequest
+	QueuedMessagesReply
 	MarkConversationReadRequest
+	SetConversationTypingRequest
+	ConversationDraftRequest
+	ConversationDraft
 	Reply
 	ServerStatusRequest
 	ServerStatusReply
+	HealthRequest
+	HealthReply
+	LogLevelRequest
+	LogLevel
+	AuditLogRequest
+	AuditLogReply
+	AuditEntry
+	AcceptanceLimits
 	Identity
 	IdentityRequest
+	ContactRequestPolicy
+	ContactRequestPolicyRequest
+	ConnectionMode
+	ConnectionModeRequest
+	IdentityExport
+	ExportIdentityRequest
+	ExportIdentityReply
+	ImportIdentityRequest
+	ImportIdentityReply
 	MonitorNetworkRequest
 	TorProcessStatus
 	TorControlStatus
 	TorConnectionStatus
+	TorSocksStatus
+	OnionServiceStatus
 	NetworkStatus
 	StartNetworkRequest
 	StopNetworkRequest
 	Config
+	KeyStorage
 	Secrets
+	History
+	MessageList
+	FileTransfer
+	FileTransferEvent
+	MonitorFileTransfersRequest
+	SendFileRequest
+	RespondFileTransferRequest
+	CancelFileTransferRequest
 */
 package ricochet
 
@@ -67,6 +123,9 @@ const (
 	Contact_ONLINE   Contact_Status = 2
 	Contact_REQUEST  Contact_Status = 3
 	Contact_REJECTED Contact_Status = 4
+	Contact_BLOCKED  Contact_Status = 5
+	// Offline, and an outbound connection attempt is in progress
+	Contact_CONNECTING Contact_Status = 6
 )
 
 var Contact_Status_name = map[int32]string{
@@ -75,13 +134,17 @@ var Contact_Status_name = map[int32]string{
 	2: "ONLINE",
 	3: "REQUEST",
 	4: "REJECTED",
+	5: "BLOCKED",
+	6: "CONNECTING",
 }
 var Contact_Status_value = map[string]int32{
-	"UNKNOWN":  0,
-	"OFFLINE":  1,
-	"ONLINE":   2,
-	"REQUEST":  3,
-	"REJECTED": 4,
+	"UNKNOWN":    0,
+	"OFFLINE":    1,
+	"ONLINE":     2,
+	"REQUEST":    3,
+	"REJECTED":   4,
+	"BLOCKED":    5,
+	"CONNECTING": 6,
 }
 
 func (x Contact_Status) String() string {
@@ -89,6 +152,30 @@ func (x Contact_Status) String() string {
 }
 func (Contact_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
+type Presence_Status int32
+
+const (
+	Presence_AVAILABLE Presence_Status = 0
+	Presence_AWAY      Presence_Status = 1
+	Presence_BUSY      Presence_Status = 2
+)
+
+var Presence_Status_name = map[int32]string{
+	0: "AVAILABLE",
+	1: "AWAY",
+	2: "BUSY",
+}
+var Presence_Status_value = map[string]int32{
+	"AVAILABLE": 0,
+	"AWAY":      1,
+	"BUSY":      2,
+}
+
+func (x Presence_Status) String() string {
+	return proto.EnumName(Presence_Status_name, int32(x))
+}
+func (Presence_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
 type ContactRequest_Direction int32
 
 const (
@@ -108,7 +195,41 @@ var ContactRequest_Direction_value = map[string]int32{
 func (x ContactRequest_Direction) String() string {
 	return proto.EnumName(ContactRequest_Direction_name, int32(x))
 }
-func (ContactRequest_Direction) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1, 0} }
+func (ContactRequest_Direction) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
+// Progress of an outbound request. Once accepted, the request is removed
+// from the contact, which is sent in an UPDATE event as a normal contact.
+type ContactRequest_Phase int32
+
+const (
+	// Not yet delivered, usually because the contact is offline
+	ContactRequest_UNDELIVERED ContactRequest_Phase = 0
+	// Delivered and waiting for the contact to respond
+	ContactRequest_DELIVERED ContactRequest_Phase = 1
+	// Rejected by the contact, with any reason in remoteError
+	ContactRequest_REJECTED ContactRequest_Phase = 2
+	// Failed, as described by remoteError; delivery is attempted again
+	// when the contact is next online
+	ContactRequest_ERROR ContactRequest_Phase = 3
+)
+
+var ContactRequest_Phase_name = map[int32]string{
+	0: "UNDELIVERED",
+	1: "DELIVERED",
+	2: "REJECTED",
+	3: "ERROR",
+}
+var ContactRequest_Phase_value = map[string]int32{
+	"UNDELIVERED": 0,
+	"DELIVERED":   1,
+	"REJECTED":    2,
+	"ERROR":       3,
+}
+
+func (x ContactRequest_Phase) String() string {
+	return proto.EnumName(ContactRequest_Phase_name, int32(x))
+}
+func (ContactRequest_Phase) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 1} }
 
 type ContactEvent_Type int32
 
@@ -138,7 +259,47 @@ var ContactEvent_Type_value = map[string]int32{
 func (x ContactEvent_Type) String() string {
 	return proto.EnumName(ContactEvent_Type_name, int32(x))
 }
-func (ContactEvent_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3, 0} }
+func (ContactEvent_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{7, 0} }
+
+// For UPDATE events of contacts, the most notable change, so clients
+// don't need to compare with the previous state to notice it.
+type ContactEvent_Change int32
+
+const (
+	ContactEvent_OTHER        ContactEvent_Change = 0
+	ContactEvent_CAME_ONLINE  ContactEvent_Change = 1
+	ContactEvent_WENT_OFFLINE ContactEvent_Change = 2
+	// The state of an outbound contact request changed
+	ContactEvent_REQUEST ContactEvent_Change = 3
+	// An outbound contact request was accepted, explicitly or by the
+	// contact connecting. The contact may also have come online, which
+	// isn't reported separately.
+	ContactEvent_REQUEST_ACCEPTED ContactEvent_Change = 4
+	// The contact refused our authentication; see authenticationRejected
+	ContactEvent_AUTHENTICATION_REJECTED ContactEvent_Change = 5
+)
+
+var ContactEvent_Change_name = map[int32]string{
+	0: "OTHER",
+	1: "CAME_ONLINE",
+	2: "WENT_OFFLINE",
+	3: "REQUEST",
+	4: "REQUEST_ACCEPTED",
+	5: "AUTHENTICATION_REJECTED",
+}
+var ContactEvent_Change_value = map[string]int32{
+	"OTHER":                   0,
+	"CAME_ONLINE":             1,
+	"WENT_OFFLINE":            2,
+	"REQUEST":                 3,
+	"REQUEST_ACCEPTED":        4,
+	"AUTHENTICATION_REJECTED": 5,
+}
+
+func (x ContactEvent_Change) String() string {
+	return proto.EnumName(ContactEvent_Change_name, int32(x))
+}
+func (ContactEvent_Change) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{7, 1} }
 
 type Contact struct {
 	Address       string          `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
@@ -146,7 +307,47 @@ type Contact struct {
 	WhenCreated   string          `protobuf:"bytes,4,opt,name=whenCreated" json:"whenCreated,omitempty"`
 	LastConnected string          `protobuf:"bytes,5,opt,name=lastConnected" json:"lastConnected,omitempty"`
 	Request       *ContactRequest `protobuf:"bytes,6,opt,name=request" json:"request,omitempty"`
-	Status        Contact_Status  `protobuf:"varint,10,opt,name=status,enum=ricochet.Contact_Status" json:"status,omitempty"`
+	// Blocked contacts are kept in the contact list, but no connections or
+	// contact requests are accepted from them
+	Blocked bool `protobuf:"varint,7,opt,name=blocked" json:"blocked,omitempty"`
+	// Pinned contacts are listed before others. Within that, contacts are
+	// ordered by sortIndex, then by nickname.
+	Pinned    bool           `protobuf:"varint,8,opt,name=pinned" json:"pinned,omitempty"`
+	SortIndex int32          `protobuf:"varint,9,opt,name=sortIndex" json:"sortIndex,omitempty"`
+	Status    Contact_Status `protobuf:"varint,10,opt,name=status,enum=ricochet.Contact_Status" json:"status,omitempty"`
+	// Details of the active connection, if the contact is connected. This is
+	// not saved in the config.
+	Connection *ContactConnection `protobuf:"bytes,11,opt,name=connection" json:"connection,omitempty"`
+	// Time the contact was last connected, which is the current time while
+	// a connection is active
+	LastSeen string `protobuf:"bytes,12,opt,name=lastSeen" json:"lastSeen,omitempty"`
+	// Total time the contact has been connected, including the active
+	// connection
+	OnlineSeconds int64 `protobuf:"varint,13,opt,name=onlineSeconds" json:"onlineSeconds,omitempty"`
+	// Number of received messages that haven't been marked as read. This is
+	// derived from the conversation and not saved in the config.
+	UnreadCount uint32 `protobuf:"varint,14,opt,name=unreadCount" json:"unreadCount,omitempty"`
+	// Unsent message saved by SetConversationDraft. This is only kept in the
+	// config, and is never sent to the contact or in contact events.
+	Draft string `protobuf:"bytes,15,opt,name=draft" json:"draft,omitempty"`
+	// Optional channel types the contact's client has been seen to support or
+	// reject, such as file transfer and typing notifications
+	Capabilities *ContactCapabilities `protobuf:"bytes,16,opt,name=capabilities" json:"capabilities,omitempty"`
+	// Presence received from the contact on the active connection, if any.
+	// This is not saved in the config.
+	Presence *Presence `protobuf:"bytes,17,opt,name=presence" json:"presence,omitempty"`
+	// Dormant contacts aren't connected when the backend starts, i.e. this is
+	// the inverse of connectOnStartup, so that contacts connect by default.
+	// Connections from the contact are still accepted, and ConnectContact
+	// makes outbound attempts until the next restart.
+	Dormant bool `protobuf:"varint,18,opt,name=dormant" json:"dormant,omitempty"`
+	// Time the contact last refused our authentication on an outbound
+	// connection, e.g. because they blocked us or added the wrong address.
+	// It's cleared by the next successful connection.
+	AuthenticationRejected string `protobuf:"bytes,19,opt,name=authenticationRejected" json:"authenticationRejected,omitempty"`
+	// Messages from muted contacts are received and stored as usual, but
+	// don't run the notify command or count towards GetUnreadCount
+	Muted bool `protobuf:"varint,20,opt,name=muted" json:"muted,omitempty"`
 }
 
 func (m *Contact) Reset()                    { *m = Contact{} }
@@ -189,6 +390,27 @@ func (m *Contact) GetRequest() *ContactRequest {
 	return nil
 }
 
+func (m *Contact) GetBlocked() bool {
+	if m != nil {
+		return m.Blocked
+	}
+	return false
+}
+
+func (m *Contact) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *Contact) GetSortIndex() int32 {
+	if m != nil {
+		return m.SortIndex
+	}
+	return 0
+}
+
 func (m *Contact) GetStatus() Contact_Status {
 	if m != nil {
 		return m.Status
@@ -196,23 +418,197 @@ func (m *Contact) GetStatus() Contact_Status {
 	return Contact_UNKNOWN
 }
 
+func (m *Contact) GetConnection() *ContactConnection {
+	if m != nil {
+		return m.Connection
+	}
+	return nil
+}
+
+func (m *Contact) GetLastSeen() string {
+	if m != nil {
+		return m.LastSeen
+	}
+	return ""
+}
+
+func (m *Contact) GetOnlineSeconds() int64 {
+	if m != nil {
+		return m.OnlineSeconds
+	}
+	return 0
+}
+
+func (m *Contact) GetUnreadCount() uint32 {
+	if m != nil {
+		return m.UnreadCount
+	}
+	return 0
+}
+
+func (m *Contact) GetDraft() string {
+	if m != nil {
+		return m.Draft
+	}
+	return ""
+}
+
+func (m *Contact) GetCapabilities() *ContactCapabilities {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+func (m *Contact) GetPresence() *Presence {
+	if m != nil {
+		return m.Presence
+	}
+	return nil
+}
+
+func (m *Contact) GetDormant() bool {
+	if m != nil {
+		return m.Dormant
+	}
+	return false
+}
+
+func (m *Contact) GetAuthenticationRejected() string {
+	if m != nil {
+		return m.AuthenticationRejected
+	}
+	return ""
+}
+
+func (m *Contact) GetMuted() bool {
+	if m != nil {
+		return m.Muted
+	}
+	return false
+}
+
+// The protocol has no feature advertisement, so capabilities are learned from
+// the channels opened on each connection. Channel types in neither list are
+// unknown, and may be supported.
+type ContactCapabilities struct {
+	// Channel types the contact has opened, or accepted when we opened them
+	Supported []string `protobuf:"bytes,1,rep,name=supported" json:"supported,omitempty"`
+	// Channel types the contact rejected as unknown
+	Unsupported []string `protobuf:"bytes,2,rep,name=unsupported" json:"unsupported,omitempty"`
+}
+
+func (m *ContactCapabilities) Reset()                    { *m = ContactCapabilities{} }
+func (m *ContactCapabilities) String() string            { return proto.CompactTextString(m) }
+func (*ContactCapabilities) ProtoMessage()               {}
+func (*ContactCapabilities) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ContactCapabilities) GetSupported() []string {
+	if m != nil {
+		return m.Supported
+	}
+	return nil
+}
+
+func (m *ContactCapabilities) GetUnsupported() []string {
+	if m != nil {
+		return m.Unsupported
+	}
+	return nil
+}
+
+// Availability shown to contacts, with an optional status message. Our own
+// presence is set with SetPresence and sent to connected contacts.
+type Presence struct {
+	Status Presence_Status `protobuf:"varint,1,opt,name=status,enum=ricochet.Presence_Status" json:"status,omitempty"`
+	// Single line of text, up to 140 bytes of UTF-8 without control characters
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *Presence) Reset()                    { *m = Presence{} }
+func (m *Presence) String() string            { return proto.CompactTextString(m) }
+func (*Presence) ProtoMessage()               {}
+func (*Presence) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *Presence) GetStatus() Presence_Status {
+	if m != nil {
+		return m.Status
+	}
+	return Presence_AVAILABLE
+}
+
+func (m *Presence) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type PresenceRequest struct {
+}
+
+func (m *PresenceRequest) Reset()                    { *m = PresenceRequest{} }
+func (m *PresenceRequest) String() string            { return proto.CompactTextString(m) }
+func (*PresenceRequest) ProtoMessage()               {}
+func (*PresenceRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type ContactConnection struct {
+	// True if the connection was made by the contact
+	Inbound bool `protobuf:"varint,1,opt,name=inbound" json:"inbound,omitempty"`
+	// Time the connection was established, for determining its uptime
+	WhenConnected string `protobuf:"bytes,2,opt,name=whenConnected" json:"whenConnected,omitempty"`
+}
+
+func (m *ContactConnection) Reset()                    { *m = ContactConnection{} }
+func (m *ContactConnection) String() string            { return proto.CompactTextString(m) }
+func (*ContactConnection) ProtoMessage()               {}
+func (*ContactConnection) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ContactConnection) GetInbound() bool {
+	if m != nil {
+		return m.Inbound
+	}
+	return false
+}
+
+func (m *ContactConnection) GetWhenConnected() string {
+	if m != nil {
+		return m.WhenConnected
+	}
+	return ""
+}
+
 type ContactRequest struct {
-	Direction     ContactRequest_Direction `protobuf:"varint,1,opt,name=direction,enum=ricochet.ContactRequest_Direction" json:"direction,omitempty"`
-	Address       string                   `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
-	Nickname      string                   `protobuf:"bytes,3,opt,name=nickname" json:"nickname,omitempty"`
-	Text          string                   `protobuf:"bytes,4,opt,name=text" json:"text,omitempty"`
-	FromNickname  string                   `protobuf:"bytes,5,opt,name=fromNickname" json:"fromNickname,omitempty"`
-	WhenCreated   string                   `protobuf:"bytes,6,opt,name=whenCreated" json:"whenCreated,omitempty"`
-	Rejected      bool                     `protobuf:"varint,7,opt,name=rejected" json:"rejected,omitempty"`
-	WhenDelivered string                   `protobuf:"bytes,8,opt,name=whenDelivered" json:"whenDelivered,omitempty"`
-	WhenRejected  string                   `protobuf:"bytes,9,opt,name=whenRejected" json:"whenRejected,omitempty"`
-	RemoteError   string                   `protobuf:"bytes,10,opt,name=remoteError" json:"remoteError,omitempty"`
+	Direction ContactRequest_Direction `protobuf:"varint,1,opt,name=direction,enum=ricochet.ContactRequest_Direction" json:"direction,omitempty"`
+	Address   string                   `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Nickname  string                   `protobuf:"bytes,3,opt,name=nickname" json:"nickname,omitempty"`
+	Text      string                   `protobuf:"bytes,4,opt,name=text" json:"text,omitempty"`
+	// Nickname of the requester. For outbound requests, this is the name we
+	// present to this contact, which can differ for each request; if empty,
+	// no nickname is sent.
+	FromNickname  string `protobuf:"bytes,5,opt,name=fromNickname" json:"fromNickname,omitempty"`
+	WhenCreated   string `protobuf:"bytes,6,opt,name=whenCreated" json:"whenCreated,omitempty"`
+	Rejected      bool   `protobuf:"varint,7,opt,name=rejected" json:"rejected,omitempty"`
+	WhenDelivered string `protobuf:"bytes,8,opt,name=whenDelivered" json:"whenDelivered,omitempty"`
+	WhenRejected  string `protobuf:"bytes,9,opt,name=whenRejected" json:"whenRejected,omitempty"`
+	// For outbound requests, the reason given by the contact for a rejection
+	// or a description of an error
+	RemoteError string `protobuf:"bytes,10,opt,name=remoteError" json:"remoteError,omitempty"`
+	// For inbound requests, an optional reason sent to the requester when
+	// the request is rejected with RejectInboundRequest
+	RejectReason string `protobuf:"bytes,11,opt,name=rejectReason" json:"rejectReason,omitempty"`
+	// For outbound requests, derived from the other fields
+	Phase ContactRequest_Phase `protobuf:"varint,12,opt,name=phase,enum=ricochet.ContactRequest_Phase" json:"phase,omitempty"`
+	// For inbound requests, whether the requester is still connected and
+	// waiting for a reply. Requests can be accepted or rejected either way,
+	// but a reject reason can only be sent while connected.
+	Connected bool `protobuf:"varint,13,opt,name=connected" json:"connected,omitempty"`
 }
 
 func (m *ContactRequest) Reset()                    { *m = ContactRequest{} }
 func (m *ContactRequest) String() string            { return proto.CompactTextString(m) }
 func (*ContactRequest) ProtoMessage()               {}
-func (*ContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*ContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *ContactRequest) GetDirection() ContactRequest_Direction {
 	if m != nil {
@@ -284,13 +680,34 @@ func (m *ContactRequest) GetRemoteError() string {
 	return ""
 }
 
+func (m *ContactRequest) GetRejectReason() string {
+	if m != nil {
+		return m.RejectReason
+	}
+	return ""
+}
+
+func (m *ContactRequest) GetPhase() ContactRequest_Phase {
+	if m != nil {
+		return m.Phase
+	}
+	return ContactRequest_UNDELIVERED
+}
+
+func (m *ContactRequest) GetConnected() bool {
+	if m != nil {
+		return m.Connected
+	}
+	return false
+}
+
 type MonitorContactsRequest struct {
 }
 
 func (m *MonitorContactsRequest) Reset()                    { *m = MonitorContactsRequest{} }
 func (m *MonitorContactsRequest) String() string            { return proto.CompactTextString(m) }
 func (*MonitorContactsRequest) ProtoMessage()               {}
-func (*MonitorContactsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*MonitorContactsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 type ContactEvent struct {
 	Type ContactEvent_Type `protobuf:"varint,1,opt,name=type,enum=ricochet.ContactEvent_Type" json:"type,omitempty"`
@@ -298,12 +715,13 @@ type ContactEvent struct {
 	//	*ContactEvent_Contact
 	//	*ContactEvent_Request
 	Subject isContactEvent_Subject `protobuf_oneof:"subject"`
+	Change  ContactEvent_Change    `protobuf:"varint,4,opt,name=change,enum=ricochet.ContactEvent_Change" json:"change,omitempty"`
 }
 
 func (m *ContactEvent) Reset()                    { *m = ContactEvent{} }
 func (m *ContactEvent) String() string            { return proto.CompactTextString(m) }
 func (*ContactEvent) ProtoMessage()               {}
-func (*ContactEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*ContactEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 type isContactEvent_Subject interface {
 	isContactEvent_Subject()
@@ -347,6 +765,13 @@ func (m *ContactEvent) GetRequest() *ContactRequest {
 	return nil
 }
 
+func (m *ContactEvent) GetChange() ContactEvent_Change {
+	if m != nil {
+		return m.Change
+	}
+	return ContactEvent_OTHER
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*ContactEvent) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _ContactEvent_OneofMarshaler, _ContactEvent_OneofUnmarshaler, _ContactEvent_OneofSizer, []interface{}{
@@ -427,7 +852,24 @@ type AddContactReply struct {
 func (m *AddContactReply) Reset()                    { *m = AddContactReply{} }
 func (m *AddContactReply) String() string            { return proto.CompactTextString(m) }
 func (*AddContactReply) ProtoMessage()               {}
-func (*AddContactReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*AddContactReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+// Contacts are identified by address
+type GetContactRequest struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *GetContactRequest) Reset()                    { *m = GetContactRequest{} }
+func (m *GetContactRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetContactRequest) ProtoMessage()               {}
+func (*GetContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *GetContactRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
 
 type DeleteContactRequest struct {
 	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
@@ -436,7 +878,7 @@ type DeleteContactRequest struct {
 func (m *DeleteContactRequest) Reset()                    { *m = DeleteContactRequest{} }
 func (m *DeleteContactRequest) String() string            { return proto.CompactTextString(m) }
 func (*DeleteContactRequest) ProtoMessage()               {}
-func (*DeleteContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*DeleteContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *DeleteContactRequest) GetAddress() string {
 	if m != nil {
@@ -451,7 +893,7 @@ type DeleteContactReply struct {
 func (m *DeleteContactReply) Reset()                    { *m = DeleteContactReply{} }
 func (m *DeleteContactReply) String() string            { return proto.CompactTextString(m) }
 func (*DeleteContactReply) ProtoMessage()               {}
-func (*DeleteContactReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*DeleteContactReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 type RejectInboundRequestReply struct {
 }
@@ -459,60 +901,517 @@ type RejectInboundRequestReply struct {
 func (m *RejectInboundRequestReply) Reset()                    { *m = RejectInboundRequestReply{} }
 func (m *RejectInboundRequestReply) String() string            { return proto.CompactTextString(m) }
 func (*RejectInboundRequestReply) ProtoMessage()               {}
-func (*RejectInboundRequestReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*RejectInboundRequestReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+type CancelOutboundRequestReply struct {
+}
+
+func (m *CancelOutboundRequestReply) Reset()                    { *m = CancelOutboundRequestReply{} }
+func (m *CancelOutboundRequestReply) String() string            { return proto.CompactTextString(m) }
+func (*CancelOutboundRequestReply) ProtoMessage()               {}
+func (*CancelOutboundRequestReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+// Portable list of contacts, for moving them to another device along with the
+// identity. Only established contacts are included, without any request state.
+type ContactListExport struct {
+	Contacts []*ContactExport `protobuf:"bytes,1,rep,name=contacts" json:"contacts,omitempty"`
+}
+
+func (m *ContactListExport) Reset()                    { *m = ContactListExport{} }
+func (m *ContactListExport) String() string            { return proto.CompactTextString(m) }
+func (*ContactListExport) ProtoMessage()               {}
+func (*ContactListExport) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *ContactListExport) GetContacts() []*ContactExport {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+type ContactExport struct {
+	Address     string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Nickname    string `protobuf:"bytes,2,opt,name=nickname" json:"nickname,omitempty"`
+	WhenCreated string `protobuf:"bytes,3,opt,name=whenCreated" json:"whenCreated,omitempty"`
+}
+
+func (m *ContactExport) Reset()                    { *m = ContactExport{} }
+func (m *ContactExport) String() string            { return proto.CompactTextString(m) }
+func (*ContactExport) ProtoMessage()               {}
+func (*ContactExport) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *ContactExport) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *ContactExport) GetNickname() string {
+	if m != nil {
+		return m.Nickname
+	}
+	return ""
+}
+
+func (m *ContactExport) GetWhenCreated() string {
+	if m != nil {
+		return m.WhenCreated
+	}
+	return ""
+}
+
+type ExportContactsRequest struct {
+}
+
+func (m *ExportContactsRequest) Reset()                    { *m = ExportContactsRequest{} }
+func (m *ExportContactsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExportContactsRequest) ProtoMessage()               {}
+func (*ExportContactsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+type ImportContactsReply struct {
+	// Contacts added by the import
+	Contacts []*Contact `protobuf:"bytes,1,rep,name=contacts" json:"contacts,omitempty"`
+	// Contacts not imported, each with the address and reason
+	Skipped []string `protobuf:"bytes,2,rep,name=skipped" json:"skipped,omitempty"`
+}
+
+func (m *ImportContactsReply) Reset()                    { *m = ImportContactsReply{} }
+func (m *ImportContactsReply) String() string            { return proto.CompactTextString(m) }
+func (*ImportContactsReply) ProtoMessage()               {}
+func (*ImportContactsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *ImportContactsReply) GetContacts() []*Contact {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+func (m *ImportContactsReply) GetSkipped() []string {
+	if m != nil {
+		return m.Skipped
+	}
+	return nil
+}
+
+type SetContactNicknameRequest struct {
+	Address  string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Nickname string `protobuf:"bytes,2,opt,name=nickname" json:"nickname,omitempty"`
+}
+
+func (m *SetContactNicknameRequest) Reset()                    { *m = SetContactNicknameRequest{} }
+func (m *SetContactNicknameRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetContactNicknameRequest) ProtoMessage()               {}
+func (*SetContactNicknameRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *SetContactNicknameRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SetContactNicknameRequest) GetNickname() string {
+	if m != nil {
+		return m.Nickname
+	}
+	return ""
+}
+
+type SetContactOrderRequest struct {
+	Address   string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Pinned    bool   `protobuf:"varint,2,opt,name=pinned" json:"pinned,omitempty"`
+	SortIndex int32  `protobuf:"varint,3,opt,name=sortIndex" json:"sortIndex,omitempty"`
+}
+
+func (m *SetContactOrderRequest) Reset()                    { *m = SetContactOrderRequest{} }
+func (m *SetContactOrderRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetContactOrderRequest) ProtoMessage()               {}
+func (*SetContactOrderRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *SetContactOrderRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SetContactOrderRequest) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *SetContactOrderRequest) GetSortIndex() int32 {
+	if m != nil {
+		return m.SortIndex
+	}
+	return 0
+}
+
+type SetContactBlockedRequest struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Blocked bool   `protobuf:"varint,2,opt,name=blocked" json:"blocked,omitempty"`
+}
+
+func (m *SetContactBlockedRequest) Reset()                    { *m = SetContactBlockedRequest{} }
+func (m *SetContactBlockedRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetContactBlockedRequest) ProtoMessage()               {}
+func (*SetContactBlockedRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *SetContactBlockedRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SetContactBlockedRequest) GetBlocked() bool {
+	if m != nil {
+		return m.Blocked
+	}
+	return false
+}
+
+type SetContactConnectOnStartupRequest struct {
+	Address          string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	ConnectOnStartup bool   `protobuf:"varint,2,opt,name=connectOnStartup" json:"connectOnStartup,omitempty"`
+}
+
+func (m *SetContactConnectOnStartupRequest) Reset()         { *m = SetContactConnectOnStartupRequest{} }
+func (m *SetContactConnectOnStartupRequest) String() string { return proto.CompactTextString(m) }
+func (*SetContactConnectOnStartupRequest) ProtoMessage()    {}
+func (*SetContactConnectOnStartupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{21}
+}
+
+func (m *SetContactConnectOnStartupRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SetContactConnectOnStartupRequest) GetConnectOnStartup() bool {
+	if m != nil {
+		return m.ConnectOnStartup
+	}
+	return false
+}
+
+type ConnectContactRequest struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *ConnectContactRequest) Reset()                    { *m = ConnectContactRequest{} }
+func (m *ConnectContactRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConnectContactRequest) ProtoMessage()               {}
+func (*ConnectContactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *ConnectContactRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+type SetContactMutedRequest struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Muted   bool   `protobuf:"varint,2,opt,name=muted" json:"muted,omitempty"`
+}
+
+func (m *SetContactMutedRequest) Reset()                    { *m = SetContactMutedRequest{} }
+func (m *SetContactMutedRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetContactMutedRequest) ProtoMessage()               {}
+func (*SetContactMutedRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *SetContactMutedRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SetContactMutedRequest) GetMuted() bool {
+	if m != nil {
+		return m.Muted
+	}
+	return false
+}
+
+type ContactDiagnosticsRequest struct {
+	// If empty, all contacts are included
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *ContactDiagnosticsRequest) Reset()                    { *m = ContactDiagnosticsRequest{} }
+func (m *ContactDiagnosticsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ContactDiagnosticsRequest) ProtoMessage()               {}
+func (*ContactDiagnosticsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *ContactDiagnosticsRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+// Connection state of a contact, for debugging
+type ContactDiagnostics struct {
+	Address  string         `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Nickname string         `protobuf:"bytes,2,opt,name=nickname" json:"nickname,omitempty"`
+	Status   Contact_Status `protobuf:"varint,3,opt,name=status,enum=ricochet.Contact_Status" json:"status,omitempty"`
+	// Whether connections to the contact are started
+	ConnectionsEnabled bool   `protobuf:"varint,4,opt,name=connectionsEnabled" json:"connectionsEnabled,omitempty"`
+	Connected          bool   `protobuf:"varint,5,opt,name=connected" json:"connected,omitempty"`
+	Inbound            bool   `protobuf:"varint,6,opt,name=inbound" json:"inbound,omitempty"`
+	WhenConnected      string `protobuf:"bytes,7,opt,name=whenConnected" json:"whenConnected,omitempty"`
+	// Whether an outbound connector is running, and whether it has reached
+	// the contact and is authenticating
+	OutboundAttempting     bool   `protobuf:"varint,8,opt,name=outboundAttempting" json:"outboundAttempting,omitempty"`
+	OutboundAuthenticating bool   `protobuf:"varint,9,opt,name=outboundAuthenticating" json:"outboundAuthenticating,omitempty"`
+	UnreadCount            uint32 `protobuf:"varint,10,opt,name=unreadCount" json:"unreadCount,omitempty"`
+	QueuedCount            uint32 `protobuf:"varint,11,opt,name=queuedCount" json:"queuedCount,omitempty"`
+	// Timestamp of the oldest queued message, if any
+	OldestQueued int64 `protobuf:"varint,12,opt,name=oldestQueued" json:"oldestQueued,omitempty"`
+}
+
+func (m *ContactDiagnostics) Reset()                    { *m = ContactDiagnostics{} }
+func (m *ContactDiagnostics) String() string            { return proto.CompactTextString(m) }
+func (*ContactDiagnostics) ProtoMessage()               {}
+func (*ContactDiagnostics) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *ContactDiagnostics) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *ContactDiagnostics) GetNickname() string {
+	if m != nil {
+		return m.Nickname
+	}
+	return ""
+}
+
+func (m *ContactDiagnostics) GetStatus() Contact_Status {
+	if m != nil {
+		return m.Status
+	}
+	return Contact_UNKNOWN
+}
+
+func (m *ContactDiagnostics) GetConnectionsEnabled() bool {
+	if m != nil {
+		return m.ConnectionsEnabled
+	}
+	return false
+}
+
+func (m *ContactDiagnostics) GetConnected() bool {
+	if m != nil {
+		return m.Connected
+	}
+	return false
+}
+
+func (m *ContactDiagnostics) GetInbound() bool {
+	if m != nil {
+		return m.Inbound
+	}
+	return false
+}
+
+func (m *ContactDiagnostics) GetWhenConnected() string {
+	if m != nil {
+		return m.WhenConnected
+	}
+	return ""
+}
+
+func (m *ContactDiagnostics) GetOutboundAttempting() bool {
+	if m != nil {
+		return m.OutboundAttempting
+	}
+	return false
+}
+
+func (m *ContactDiagnostics) GetOutboundAuthenticating() bool {
+	if m != nil {
+		return m.OutboundAuthenticating
+	}
+	return false
+}
+
+func (m *ContactDiagnostics) GetUnreadCount() uint32 {
+	if m != nil {
+		return m.UnreadCount
+	}
+	return 0
+}
+
+func (m *ContactDiagnostics) GetQueuedCount() uint32 {
+	if m != nil {
+		return m.QueuedCount
+	}
+	return 0
+}
+
+func (m *ContactDiagnostics) GetOldestQueued() int64 {
+	if m != nil {
+		return m.OldestQueued
+	}
+	return 0
+}
+
+type ContactDiagnosticsReply struct {
+	Contacts []*ContactDiagnostics `protobuf:"bytes,1,rep,name=contacts" json:"contacts,omitempty"`
+}
+
+func (m *ContactDiagnosticsReply) Reset()                    { *m = ContactDiagnosticsReply{} }
+func (m *ContactDiagnosticsReply) String() string            { return proto.CompactTextString(m) }
+func (*ContactDiagnosticsReply) ProtoMessage()               {}
+func (*ContactDiagnosticsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ContactDiagnosticsReply) GetContacts() []*ContactDiagnostics {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
 
 func init() {
 	proto.RegisterType((*Contact)(nil), "ricochet.Contact")
+	proto.RegisterType((*ContactCapabilities)(nil), "ricochet.ContactCapabilities")
+	proto.RegisterType((*Presence)(nil), "ricochet.Presence")
+	proto.RegisterType((*PresenceRequest)(nil), "ricochet.PresenceRequest")
+	proto.RegisterType((*ContactConnection)(nil), "ricochet.ContactConnection")
 	proto.RegisterType((*ContactRequest)(nil), "ricochet.ContactRequest")
 	proto.RegisterType((*MonitorContactsRequest)(nil), "ricochet.MonitorContactsRequest")
 	proto.RegisterType((*ContactEvent)(nil), "ricochet.ContactEvent")
 	proto.RegisterType((*AddContactReply)(nil), "ricochet.AddContactReply")
+	proto.RegisterType((*GetContactRequest)(nil), "ricochet.GetContactRequest")
 	proto.RegisterType((*DeleteContactRequest)(nil), "ricochet.DeleteContactRequest")
 	proto.RegisterType((*DeleteContactReply)(nil), "ricochet.DeleteContactReply")
 	proto.RegisterType((*RejectInboundRequestReply)(nil), "ricochet.RejectInboundRequestReply")
+	proto.RegisterType((*CancelOutboundRequestReply)(nil), "ricochet.CancelOutboundRequestReply")
+	proto.RegisterType((*ContactListExport)(nil), "ricochet.ContactListExport")
+	proto.RegisterType((*ContactExport)(nil), "ricochet.ContactExport")
+	proto.RegisterType((*ExportContactsRequest)(nil), "ricochet.ExportContactsRequest")
+	proto.RegisterType((*ImportContactsReply)(nil), "ricochet.ImportContactsReply")
+	proto.RegisterType((*SetContactNicknameRequest)(nil), "ricochet.SetContactNicknameRequest")
+	proto.RegisterType((*SetContactOrderRequest)(nil), "ricochet.SetContactOrderRequest")
+	proto.RegisterType((*SetContactBlockedRequest)(nil), "ricochet.SetContactBlockedRequest")
+	proto.RegisterType((*SetContactConnectOnStartupRequest)(nil), "ricochet.SetContactConnectOnStartupRequest")
+	proto.RegisterType((*ConnectContactRequest)(nil), "ricochet.ConnectContactRequest")
+	proto.RegisterType((*SetContactMutedRequest)(nil), "ricochet.SetContactMutedRequest")
+	proto.RegisterType((*ContactDiagnosticsRequest)(nil), "ricochet.ContactDiagnosticsRequest")
+	proto.RegisterType((*ContactDiagnostics)(nil), "ricochet.ContactDiagnostics")
+	proto.RegisterType((*ContactDiagnosticsReply)(nil), "ricochet.ContactDiagnosticsReply")
 	proto.RegisterEnum("ricochet.Contact_Status", Contact_Status_name, Contact_Status_value)
+	proto.RegisterEnum("ricochet.Presence_Status", Presence_Status_name, Presence_Status_value)
 	proto.RegisterEnum("ricochet.ContactRequest_Direction", ContactRequest_Direction_name, ContactRequest_Direction_value)
+	proto.RegisterEnum("ricochet.ContactRequest_Phase", ContactRequest_Phase_name, ContactRequest_Phase_value)
 	proto.RegisterEnum("ricochet.ContactEvent_Type", ContactEvent_Type_name, ContactEvent_Type_value)
+	proto.RegisterEnum("ricochet.ContactEvent_Change", ContactEvent_Change_name, ContactEvent_Change_value)
 }
 
 func init() { proto.RegisterFile("contact.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 569 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x54, 0x5d, 0x73, 0x93, 0x40,
-	0x14, 0x2d, 0x01, 0xf9, 0xb8, 0x69, 0x2b, 0xdd, 0xe9, 0x38, 0xd8, 0xbe, 0x64, 0x76, 0x1c, 0x27,
-	0x2f, 0x62, 0xa7, 0xfa, 0xae, 0x6d, 0xa0, 0x63, 0x34, 0x42, 0xdd, 0xc2, 0xf8, 0x4c, 0x61, 0x9d,
-	0xa2, 0x29, 0x1b, 0x97, 0x4d, 0x35, 0x7f, 0xc3, 0x5f, 0xe3, 0xcf, 0xf2, 0x27, 0x38, 0xbb, 0x40,
-	0x9a, 0x0f, 0xf5, 0xc1, 0xb7, 0xbd, 0xe7, 0x9e, 0x0b, 0x87, 0x7b, 0xce, 0x02, 0x7b, 0x39, 0xab,
-	0x44, 0x96, 0x0b, 0x7f, 0xc6, 0x99, 0x60, 0xc8, 0xe6, 0x65, 0xce, 0xf2, 0x1b, 0x2a, 0xf0, 0xcf,
-	0x1e, 0x58, 0xa3, 0xa6, 0x87, 0x3c, 0xb0, 0xb2, 0xa2, 0xe0, 0xb4, 0xae, 0xbd, 0xde, 0x40, 0x1b,
-	0x3a, 0xa4, 0x2b, 0xd1, 0x11, 0xd8, 0x55, 0x99, 0x7f, 0xa9, 0xb2, 0x5b, 0xea, 0xe9, 0xaa, 0xb5,
-	0xac, 0xd1, 0x00, 0xfa, 0xdf, 0x6e, 0x68, 0x35, 0xe2, 0x34, 0x13, 0xb4, 0xf0, 0x0c, 0xd5, 0x5e,
-	0x85, 0xd0, 0x13, 0xd8, 0x9b, 0x66, 0xb5, 0x18, 0xb1, 0xaa, 0xa2, 0xb9, 0xe4, 0x3c, 0x50, 0x9c,
-	0x75, 0x10, 0x9d, 0x82, 0xc5, 0xe9, 0xd7, 0x39, 0xad, 0x85, 0x67, 0x0e, 0xb4, 0x61, 0xff, 0xd4,
-	0xf3, 0x3b, 0x95, 0x7e, 0xab, 0x90, 0x34, 0x7d, 0xd2, 0x11, 0xd1, 0x09, 0x98, 0xb5, 0xc8, 0xc4,
-	0xbc, 0xf6, 0x60, 0xa0, 0x0d, 0xf7, 0xff, 0x30, 0xe2, 0x5f, 0xa9, 0x3e, 0x69, 0x79, 0x78, 0x0c,
-	0x66, 0x83, 0xa0, 0x3e, 0x58, 0x69, 0xf4, 0x2e, 0x8a, 0x3f, 0x46, 0xee, 0x8e, 0x2c, 0xe2, 0x8b,
-	0x8b, 0xc9, 0x38, 0x0a, 0x5d, 0x0d, 0x01, 0x98, 0x71, 0xa4, 0xce, 0x3d, 0xd9, 0x20, 0xe1, 0x87,
-	0x34, 0xbc, 0x4a, 0x5c, 0x1d, 0xed, 0x82, 0x4d, 0xc2, 0xb7, 0xe1, 0x28, 0x09, 0x03, 0xd7, 0xc0,
-	0x3f, 0x74, 0xd8, 0x5f, 0x17, 0x86, 0x5e, 0x83, 0x53, 0x94, 0x9c, 0xe6, 0xa2, 0x64, 0x95, 0xa7,
-	0x29, 0x49, 0xf8, 0x6f, 0x5f, 0xe1, 0x07, 0x1d, 0x93, 0xdc, 0x0f, 0xfd, 0xa7, 0x07, 0x08, 0x0c,
-	0x41, 0xbf, 0x8b, 0x76, 0xf9, 0xea, 0x8c, 0x30, 0xec, 0x7e, 0xe2, 0xec, 0x36, 0xea, 0x66, 0x9a,
-	0xa5, 0xaf, 0x61, 0x9b, 0xde, 0x99, 0xdb, 0xde, 0x1d, 0x81, 0xcd, 0xe9, 0xe7, 0xc6, 0x36, 0x6b,
-	0xa0, 0x0d, 0x6d, 0xb2, 0xac, 0xa5, 0xaf, 0x92, 0x1a, 0xd0, 0x69, 0x79, 0x47, 0x39, 0x2d, 0x3c,
-	0xbb, 0xf1, 0x75, 0x0d, 0x94, 0x3a, 0x24, 0x40, 0xba, 0xa7, 0x38, 0x8d, 0x8e, 0x55, 0x4c, 0xea,
-	0xe0, 0xf4, 0x96, 0x09, 0x1a, 0x72, 0xce, 0xb8, 0x32, 0xd3, 0x21, 0xab, 0x10, 0x7e, 0x0a, 0xce,
-	0x72, 0x5f, 0xd2, 0x94, 0x71, 0x74, 0x1e, 0xa7, 0x51, 0xe0, 0xee, 0x48, 0x53, 0xe2, 0x34, 0x69,
-	0x2a, 0x0d, 0x7b, 0xf0, 0xe8, 0x3d, 0xab, 0x4a, 0xc1, 0x78, 0xbb, 0xed, 0xba, 0x5d, 0x37, 0xfe,
-	0xa5, 0xc1, 0x6e, 0x8b, 0x85, 0x77, 0xb4, 0x12, 0xe8, 0x39, 0x18, 0x62, 0x31, 0xa3, 0xad, 0x4f,
-	0xc7, 0x5b, 0x3e, 0x29, 0x96, 0x9f, 0x2c, 0x66, 0x94, 0x28, 0x22, 0x7a, 0x06, 0x56, 0x7b, 0x8d,
-	0x94, 0x37, 0xfd, 0xd3, 0x83, 0xad, 0x99, 0x37, 0x3b, 0xa4, 0xe3, 0xa0, 0x97, 0xf7, 0x81, 0xd6,
-	0xff, 0x1d, 0x68, 0x39, 0xd5, 0x52, 0xf1, 0x2b, 0x30, 0xe4, 0x2b, 0x91, 0x0d, 0x46, 0x94, 0x4e,
-	0x26, 0xcd, 0x07, 0x5e, 0xc6, 0x97, 0xe9, 0xe4, 0x2c, 0x91, 0xe1, 0xb4, 0x40, 0x3f, 0x0b, 0x02,
-	0xb7, 0x27, 0x53, 0x9a, 0x5e, 0x06, 0x12, 0xd4, 0xe5, 0x39, 0x08, 0x27, 0x61, 0x12, 0xba, 0xc6,
-	0xb9, 0x03, 0x56, 0x3d, 0xbf, 0x96, 0x8b, 0xc5, 0x07, 0xf0, 0xf0, 0xac, 0x28, 0x96, 0xef, 0x9a,
-	0x4d, 0x17, 0xf8, 0x04, 0x0e, 0x03, 0x3a, 0xa5, 0x82, 0x6e, 0x24, 0x77, 0x25, 0x77, 0xda, 0x5a,
-	0xee, 0xf0, 0x21, 0xa0, 0x8d, 0x09, 0xf9, 0x9c, 0x63, 0x78, 0xdc, 0xb8, 0x37, 0xae, 0xae, 0xd9,
-	0xbc, 0x2a, 0xba, 0xab, 0x29, 0x9b, 0xd7, 0xa6, 0xfa, 0xcb, 0xbc, 0xf8, 0x1d, 0x00, 0x00, 0xff,
-	0xff, 0x2d, 0x76, 0xf2, 0x61, 0x76, 0x04, 0x00, 0x00,
+	// 1442 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x57, 0xdb, 0x6e, 0xdb, 0x46,
+	0x13, 0x36, 0x75, 0xd6, 0xc8, 0x72, 0xe8, 0x8d, 0x63, 0xd3, 0x39, 0xfc, 0xd0, 0x4f, 0x14, 0x85,
+	0xd0, 0x22, 0x6a, 0xe2, 0x24, 0x45, 0x81, 0x5e, 0x34, 0x32, 0xc5, 0xc4, 0x6a, 0x14, 0xd2, 0x59,
+	0x49, 0x09, 0x72, 0x53, 0x83, 0x26, 0x37, 0x36, 0x1b, 0x69, 0xc9, 0x90, 0xab, 0x34, 0xbe, 0xef,
+	0x7b, 0xf4, 0x31, 0xfa, 0x12, 0x7d, 0x82, 0x3e, 0x4d, 0xb1, 0xcb, 0xb3, 0x68, 0xc7, 0x41, 0xee,
+	0x38, 0x33, 0xdf, 0xee, 0xce, 0xce, 0xe1, 0xdb, 0x21, 0x74, 0x6d, 0x8f, 0x32, 0xcb, 0x66, 0x03,
+	0x3f, 0xf0, 0x98, 0x87, 0x5a, 0x81, 0x6b, 0x7b, 0xf6, 0x39, 0x61, 0xea, 0xdf, 0x0d, 0x68, 0x6a,
+	0x91, 0x0d, 0x29, 0xd0, 0xb4, 0x1c, 0x27, 0x20, 0x61, 0xa8, 0x54, 0x7a, 0x52, 0xbf, 0x8d, 0x13,
+	0x11, 0xdd, 0x86, 0x16, 0x75, 0xed, 0xf7, 0xd4, 0x5a, 0x12, 0xa5, 0x2a, 0x4c, 0xa9, 0x8c, 0x7a,
+	0xd0, 0xf9, 0xe3, 0x9c, 0x50, 0x2d, 0x20, 0x16, 0x23, 0x8e, 0x52, 0x13, 0xe6, 0xbc, 0x0a, 0x7d,
+	0x03, 0xdd, 0x85, 0x15, 0x32, 0xcd, 0xa3, 0x94, 0xd8, 0x1c, 0x53, 0x17, 0x98, 0xa2, 0x12, 0x1d,
+	0x40, 0x33, 0x20, 0x1f, 0x56, 0x24, 0x64, 0x4a, 0xa3, 0x27, 0xf5, 0x3b, 0x07, 0xca, 0x20, 0xf1,
+	0x72, 0x10, 0x7b, 0x88, 0x23, 0x3b, 0x4e, 0x80, 0xdc, 0xe3, 0xd3, 0x85, 0x67, 0xbf, 0x27, 0x8e,
+	0xd2, 0xec, 0x49, 0xfd, 0x16, 0x4e, 0x44, 0xb4, 0x0b, 0x0d, 0xdf, 0xa5, 0x94, 0x38, 0x4a, 0x4b,
+	0x18, 0x62, 0x09, 0xdd, 0x85, 0x76, 0xe8, 0x05, 0x6c, 0x4c, 0x1d, 0xf2, 0x49, 0x69, 0xf7, 0xa4,
+	0x7e, 0x1d, 0x67, 0x0a, 0xf4, 0x00, 0x1a, 0x21, 0xb3, 0xd8, 0x2a, 0x54, 0xa0, 0x27, 0xf5, 0xb7,
+	0x2e, 0x71, 0x61, 0x30, 0x15, 0x76, 0x1c, 0xe3, 0xd0, 0xcf, 0x00, 0x76, 0x74, 0x05, 0xd7, 0xa3,
+	0x4a, 0x47, 0x38, 0x7e, 0xa7, 0xb4, 0x4a, 0x4b, 0x21, 0x38, 0x07, 0xe7, 0x61, 0xe5, 0x31, 0x98,
+	0x12, 0x42, 0x95, 0xcd, 0x28, 0xac, 0x89, 0xcc, 0x83, 0xe6, 0xd1, 0x85, 0x4b, 0xc9, 0x94, 0xd8,
+	0x1e, 0x75, 0x42, 0xa5, 0xdb, 0x93, 0xfa, 0x55, 0x5c, 0x54, 0xf2, 0xe0, 0xaf, 0x68, 0x40, 0x2c,
+	0x47, 0xf3, 0x56, 0x94, 0x29, 0x5b, 0x3d, 0xa9, 0xdf, 0xc5, 0x79, 0x15, 0xda, 0x81, 0xba, 0x13,
+	0x58, 0xef, 0x98, 0x72, 0x43, 0x1c, 0x10, 0x09, 0x68, 0x08, 0x9b, 0xb6, 0xe5, 0x5b, 0xa7, 0xee,
+	0xc2, 0x65, 0x2e, 0x09, 0x15, 0x59, 0x38, 0x7e, 0xaf, 0xec, 0x78, 0x0e, 0x84, 0x0b, 0x4b, 0xd0,
+	0x00, 0x5a, 0x7e, 0x40, 0x42, 0x42, 0x6d, 0xa2, 0x6c, 0x8b, 0xe5, 0x28, 0x5b, 0x7e, 0x1c, 0x5b,
+	0x70, 0x8a, 0xe1, 0xb9, 0x72, 0xbc, 0x60, 0x69, 0x51, 0xa6, 0xa0, 0x28, 0x57, 0xb1, 0x88, 0x7e,
+	0x84, 0x5d, 0x6b, 0xc5, 0xce, 0x09, 0x65, 0xae, 0x6d, 0x89, 0x20, 0x91, 0xdf, 0xa3, 0x42, 0xb9,
+	0x29, 0x7c, 0xbe, 0xc2, 0xca, 0xaf, 0xb6, 0x5c, 0x71, 0xd8, 0x8e, 0xd8, 0x2f, 0x12, 0xd4, 0x77,
+	0xd0, 0x88, 0x72, 0x84, 0x3a, 0xd0, 0x9c, 0x1b, 0x2f, 0x0c, 0xf3, 0x8d, 0x21, 0x6f, 0x70, 0xc1,
+	0x7c, 0xf6, 0x6c, 0x32, 0x36, 0x74, 0x59, 0x42, 0x00, 0x0d, 0xd3, 0x10, 0xdf, 0x15, 0x6e, 0xc0,
+	0xfa, 0xab, 0xb9, 0x3e, 0x9d, 0xc9, 0x55, 0xb4, 0x09, 0x2d, 0xac, 0xff, 0xaa, 0x6b, 0x33, 0x7d,
+	0x24, 0xd7, 0xb8, 0xe9, 0x70, 0x62, 0x6a, 0x2f, 0xf4, 0x91, 0x5c, 0x47, 0x5b, 0x00, 0x9a, 0x69,
+	0x18, 0xba, 0x36, 0x1b, 0x1b, 0xcf, 0xe5, 0x86, 0x3a, 0x87, 0x9b, 0x97, 0x04, 0x49, 0x14, 0xd8,
+	0xca, 0xf7, 0xbd, 0x80, 0x3b, 0x26, 0xf5, 0xaa, 0xfd, 0x36, 0xce, 0x14, 0x51, 0xbe, 0x32, 0x7b,
+	0x45, 0xd8, 0xf3, 0x2a, 0xf5, 0x4f, 0x09, 0x5a, 0x49, 0xf4, 0xd0, 0xc3, 0xb4, 0x1e, 0x25, 0x51,
+	0x8f, 0xfb, 0xe5, 0x08, 0xaf, 0x17, 0xa4, 0x02, 0xcd, 0x25, 0x09, 0x43, 0xeb, 0x8c, 0x24, 0x4d,
+	0x1c, 0x8b, 0xea, 0xf7, 0x69, 0x60, 0xba, 0xd0, 0x1e, 0xbe, 0x1e, 0x8e, 0x27, 0xc3, 0xc3, 0x89,
+	0x2e, 0x6f, 0xa0, 0x16, 0xd4, 0x86, 0x6f, 0x86, 0x6f, 0x65, 0x89, 0x7f, 0x1d, 0xce, 0xa7, 0x6f,
+	0xe5, 0x8a, 0xba, 0x0d, 0x37, 0xd2, 0x1c, 0x46, 0xcd, 0xa6, 0x4e, 0x61, 0xbb, 0x54, 0xce, 0xfc,
+	0x38, 0x97, 0x9e, 0x7a, 0x2b, 0xea, 0x08, 0x17, 0x5b, 0x38, 0x11, 0x79, 0x01, 0x0b, 0x12, 0x48,
+	0xbb, 0x3e, 0x72, 0xa7, 0xa8, 0x54, 0xff, 0xa9, 0xc1, 0x56, 0xb1, 0xbb, 0xd1, 0x53, 0x68, 0x3b,
+	0x6e, 0x10, 0x77, 0x54, 0x74, 0x6f, 0xf5, 0x2a, 0x2a, 0x18, 0x8c, 0x12, 0x24, 0xce, 0x16, 0x7d,
+	0x25, 0x91, 0x21, 0xa8, 0x31, 0xf2, 0x89, 0xc5, 0x0c, 0x26, 0xbe, 0x91, 0x0a, 0x9b, 0xef, 0x02,
+	0x6f, 0x69, 0x24, 0x6b, 0x22, 0xe6, 0x2a, 0xe8, 0xd6, 0x09, 0xb0, 0x51, 0x26, 0xc0, 0xdb, 0xd0,
+	0x0a, 0x92, 0x92, 0x8e, 0x78, 0x2a, 0x95, 0x93, 0x30, 0x8d, 0xc8, 0xc2, 0xfd, 0x48, 0x82, 0x98,
+	0xaf, 0xda, 0xb8, 0xa8, 0xe4, 0x7e, 0x70, 0x45, 0xda, 0x18, 0xed, 0xc8, 0x8f, 0xbc, 0x8e, 0xfb,
+	0x11, 0x90, 0xa5, 0xc7, 0x88, 0x1e, 0x04, 0x5e, 0x20, 0x18, 0xac, 0x8d, 0xf3, 0x2a, 0xbe, 0x4b,
+	0x74, 0x2e, 0x26, 0x56, 0x18, 0xd3, 0x55, 0x1b, 0x17, 0x74, 0xe8, 0x31, 0xd4, 0xfd, 0x73, 0x2b,
+	0x24, 0x82, 0x90, 0xb6, 0x0e, 0xfe, 0x77, 0x65, 0xe4, 0x8f, 0x39, 0x0a, 0x47, 0x60, 0x5e, 0xf5,
+	0x76, 0x9a, 0xe8, 0xae, 0xb8, 0x62, 0xa6, 0x50, 0xbf, 0x85, 0x76, 0x9a, 0x27, 0xde, 0x54, 0x63,
+	0xe3, 0xd0, 0x9c, 0x1b, 0x23, 0x79, 0x83, 0xf7, 0x9b, 0x39, 0x9f, 0x45, 0x92, 0xa4, 0x3e, 0x85,
+	0xba, 0xd8, 0x15, 0xdd, 0x80, 0xce, 0xdc, 0x18, 0xe9, 0x93, 0xf1, 0x6b, 0x1d, 0xeb, 0x1c, 0xd7,
+	0x85, 0x76, 0x26, 0x4a, 0x85, 0x36, 0xad, 0xa0, 0x36, 0xd4, 0x75, 0x8c, 0x4d, 0x2c, 0x57, 0x55,
+	0x05, 0x76, 0x5f, 0x7a, 0xd4, 0x65, 0x5e, 0x10, 0x7b, 0x1b, 0x26, 0xd5, 0xfb, 0x57, 0x15, 0x36,
+	0x63, 0x9d, 0xfe, 0x91, 0x50, 0x86, 0x7e, 0x80, 0x1a, 0xbb, 0xf0, 0x49, 0x5c, 0x61, 0x65, 0xce,
+	0x16, 0xa8, 0xc1, 0xec, 0xc2, 0x27, 0x58, 0x00, 0xd1, 0x7d, 0x68, 0xc6, 0xaf, 0xa8, 0xa8, 0xaa,
+	0xce, 0xc1, 0x76, 0x69, 0xcd, 0xd1, 0x06, 0x4e, 0x30, 0xe8, 0x71, 0xf6, 0x9e, 0x55, 0x3f, 0xff,
+	0x9e, 0xf1, 0x55, 0x31, 0x14, 0x3d, 0x81, 0x86, 0x7d, 0x6e, 0xd1, 0x33, 0x22, 0xca, 0x70, 0xeb,
+	0xe0, 0xde, 0x15, 0x7e, 0x69, 0x02, 0x84, 0x63, 0xb0, 0xfa, 0x0b, 0xd4, 0xb8, 0xa7, 0xbc, 0x81,
+	0x8d, 0xf9, 0x64, 0x12, 0x45, 0xf6, 0xd8, 0x3c, 0x9e, 0x4f, 0x86, 0x33, 0x4e, 0x78, 0x4d, 0xa8,
+	0x0e, 0x47, 0x3c, 0x56, 0x00, 0x8d, 0xf9, 0xf1, 0x88, 0x2b, 0xab, 0xfc, 0x7b, 0xa4, 0x4f, 0xf4,
+	0x99, 0x2e, 0xd7, 0xd4, 0x8f, 0xd0, 0x88, 0xb6, 0xe4, 0xd1, 0x34, 0x67, 0x47, 0x3a, 0x96, 0x37,
+	0x78, 0x1a, 0xb4, 0xe1, 0x4b, 0xfd, 0x24, 0xe6, 0x4a, 0x09, 0xc9, 0xb0, 0xf9, 0x46, 0x37, 0x66,
+	0x27, 0x09, 0x93, 0xae, 0xb1, 0xe7, 0x0e, 0xc8, 0xb1, 0x70, 0x32, 0xd4, 0x34, 0xfd, 0x38, 0x62,
+	0xd1, 0x3b, 0xb0, 0x37, 0x9c, 0xcf, 0x8e, 0x74, 0x63, 0x36, 0xd6, 0x86, 0xb3, 0xb1, 0x69, 0x9c,
+	0xa4, 0xb9, 0xab, 0x1f, 0xb6, 0xa1, 0x19, 0xae, 0x4e, 0x79, 0xfd, 0x71, 0xca, 0x19, 0x3a, 0x4e,
+	0x1a, 0x1a, 0x7f, 0x71, 0xa1, 0xde, 0x87, 0xed, 0xe7, 0x84, 0xad, 0xf1, 0x43, 0xae, 0xbb, 0xa5,
+	0x42, 0x77, 0xab, 0x0f, 0x60, 0x67, 0x44, 0x16, 0x84, 0x91, 0x2f, 0x5e, 0xb1, 0x03, 0x68, 0x6d,
+	0x05, 0x3f, 0xf6, 0x0e, 0xec, 0x47, 0x5d, 0x35, 0x8e, 0xb8, 0x2c, 0xde, 0x27, 0x32, 0xde, 0x85,
+	0xdb, 0x9a, 0x45, 0x6d, 0xb2, 0x30, 0x57, 0xac, 0x6c, 0x3d, 0x4a, 0x49, 0x72, 0xe2, 0x86, 0x4c,
+	0xff, 0xc4, 0x59, 0x1d, 0x3d, 0x82, 0x56, 0x5c, 0x15, 0xa1, 0x78, 0x12, 0x3a, 0x07, 0x7b, 0xe5,
+	0xb4, 0x0a, 0x28, 0x4e, 0x81, 0xea, 0x19, 0x74, 0x0b, 0xa6, 0xab, 0x6f, 0x51, 0x60, 0xb5, 0xca,
+	0xe7, 0xc7, 0xb3, 0x6a, 0x89, 0x9d, 0xd4, 0x3d, 0xb8, 0x15, 0x9d, 0xb0, 0xde, 0x32, 0xbf, 0xc1,
+	0xcd, 0xf1, 0xb2, 0x68, 0xf0, 0x17, 0x17, 0xe8, 0x7e, 0xe9, 0x36, 0xe5, 0x46, 0xc8, 0xee, 0xc1,
+	0xdd, 0x0e, 0xdf, 0xbb, 0xbe, 0x9f, 0x3e, 0x77, 0x89, 0xa8, 0xbe, 0x82, 0xfd, 0x69, 0x9a, 0xdd,
+	0x84, 0x4e, 0xaf, 0xcd, 0xd9, 0xe7, 0x6e, 0xab, 0x9e, 0xc3, 0x6e, 0xb6, 0xa5, 0x19, 0x38, 0x24,
+	0xb8, 0x7e, 0xbf, 0x6c, 0x54, 0xac, 0x5c, 0x3d, 0x2a, 0x56, 0xd7, 0x46, 0x45, 0xd5, 0x00, 0x25,
+	0x3b, 0xe9, 0x30, 0x9a, 0x3a, 0xaf, 0x3f, 0x2b, 0x37, 0xb0, 0x56, 0x0a, 0x03, 0xab, 0xea, 0xc2,
+	0xff, 0xb3, 0xfd, 0xe2, 0xf7, 0xd1, 0xa4, 0x53, 0x66, 0x05, 0x6c, 0xe5, 0x5f, 0xbf, 0xf1, 0x77,
+	0x20, 0xdb, 0x6b, 0x8b, 0xe2, 0x13, 0x4a, 0x7a, 0xf5, 0x21, 0xdc, 0x8a, 0x0f, 0xf8, 0xe2, 0x3e,
+	0x39, 0xca, 0xc7, 0xf5, 0x25, 0x9f, 0xb3, 0xae, 0x77, 0x29, 0x1d, 0xcf, 0x2a, 0xf9, 0xf1, 0xec,
+	0x09, 0xec, 0xc7, 0xdb, 0x8c, 0x5c, 0xeb, 0x8c, 0x7a, 0x21, 0x73, 0xed, 0xf0, 0x7a, 0x07, 0xfe,
+	0xad, 0x02, 0x2a, 0xaf, 0xfb, 0xca, 0x9e, 0xc8, 0xc6, 0xfc, 0xea, 0x17, 0x8e, 0xf9, 0x03, 0x40,
+	0xd9, 0xdc, 0x1e, 0xea, 0xd4, 0x3a, 0x5d, 0xc4, 0xff, 0x3a, 0x2d, 0x7c, 0x89, 0xa5, 0xf8, 0x1e,
+	0xd6, 0xd7, 0xde, 0xc3, 0xfc, 0xd0, 0xd4, 0xb8, 0x66, 0x68, 0x6a, 0x5e, 0x32, 0x34, 0x71, 0x6f,
+	0xbc, 0x98, 0x7c, 0x86, 0x8c, 0x91, 0xa5, 0xcf, 0x5c, 0x7a, 0x16, 0xff, 0xe8, 0x5c, 0x62, 0xe1,
+	0x03, 0x76, 0xaa, 0xcd, 0x8d, 0xd2, 0xf4, 0x4c, 0xcc, 0x11, 0x2d, 0x7c, 0x85, 0x75, 0xfd, 0xef,
+	0x02, 0xca, 0x7f, 0x17, 0x3d, 0xe8, 0x7c, 0x58, 0x91, 0x15, 0x89, 0x11, 0x9d, 0x08, 0x91, 0x53,
+	0xf1, 0x99, 0xc3, 0x5b, 0x38, 0x24, 0x64, 0xaf, 0x84, 0x52, 0x8c, 0x15, 0x55, 0x5c, 0xd0, 0xa9,
+	0x53, 0xd8, 0xbb, 0xac, 0x26, 0x38, 0xd9, 0xfc, 0x54, 0x22, 0x9b, 0xbb, 0xa5, 0x64, 0xe5, 0x17,
+	0xa5, 0xe8, 0xd3, 0x86, 0xf8, 0xd5, 0x7d, 0xf4, 0xdf, 0x00, 0x16, 0x89, 0xa8, 0x86, 0xfb, 0x0e,
+	0x00, 0x00,
 }
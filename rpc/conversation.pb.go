@@ -21,6 +21,8 @@ const (
 	ConversationEvent_RECEIVE  ConversationEvent_Type = 2
 	ConversationEvent_SEND     ConversationEvent_Type = 3
 	ConversationEvent_UPDATE   ConversationEvent_Type = 4
+	// The contact in entity started or stopped typing. No msg is set.
+	ConversationEvent_TYPING ConversationEvent_Type = 5
 )
 
 var ConversationEvent_Type_name = map[int32]string{
@@ -29,6 +31,7 @@ var ConversationEvent_Type_name = map[int32]string{
 	2: "RECEIVE",
 	3: "SEND",
 	4: "UPDATE",
+	5: "TYPING",
 }
 var ConversationEvent_Type_value = map[string]int32{
 	"NULL":     0,
@@ -36,6 +39,7 @@ var ConversationEvent_Type_value = map[string]int32{
 	"RECEIVE":  2,
 	"SEND":     3,
 	"UPDATE":   4,
+	"TYPING":   5,
 }
 
 func (x ConversationEvent_Type) String() string {
@@ -84,6 +88,9 @@ func (Message_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor1,
 type ConversationEvent struct {
 	Type ConversationEvent_Type `protobuf:"varint,1,opt,name=type,enum=ricochet.ConversationEvent_Type" json:"type,omitempty"`
 	Msg  *Message               `protobuf:"bytes,2,opt,name=msg" json:"msg,omitempty"`
+	// For TYPING events
+	Entity *Entity `protobuf:"bytes,3,opt,name=entity" json:"entity,omitempty"`
+	Typing bool    `protobuf:"varint,4,opt,name=typing" json:"typing,omitempty"`
 }
 
 func (m *ConversationEvent) Reset()                    { *m = ConversationEvent{} }
@@ -105,6 +112,20 @@ func (m *ConversationEvent) GetMsg() *Message {
 	return nil
 }
 
+func (m *ConversationEvent) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *ConversationEvent) GetTyping() bool {
+	if m != nil {
+		return m.Typing
+	}
+	return false
+}
+
 type MonitorConversationsRequest struct {
 }
 
@@ -142,12 +163,22 @@ type Message struct {
 	Sender    *Entity `protobuf:"bytes,1,opt,name=sender" json:"sender,omitempty"`
 	Recipient *Entity `protobuf:"bytes,2,opt,name=recipient" json:"recipient,omitempty"`
 	Timestamp int64   `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
-	// Identifiers are unique for the tuple of (sender, recipient, direction)
-	// within a single session, and should be randomized between sessions to
-	// reduce the chance of collision.
+	// Identifier of the message in the protocol, which is only unique for the
+	// tuple of (sender, recipient, direction) within a single connection, and
+	// is zero for outbound messages that haven't been sent. It's informational;
+	// the backend matches acks to messages itself, and RPC calls refer to
+	// messages by sequence.
 	Identifier uint64         `protobuf:"varint,4,opt,name=identifier" json:"identifier,omitempty"`
 	Status     Message_Status `protobuf:"varint,5,opt,name=status,enum=ricochet.Message_Status" json:"status,omitempty"`
 	Text       string         `protobuf:"bytes,6,opt,name=text" json:"text,omitempty"`
+	// Assigned by the backend, and unique and increasing within each
+	// conversation. Unlike identifier, which comes from the protocol and may
+	// be reused or zero, this is never zero and is kept in message history.
+	// Use it to refer to messages in RPC calls.
+	Sequence uint64 `protobuf:"varint,7,opt,name=sequence" json:"sequence,omitempty"`
+	// Set if the message is a reply to an earlier message in the same
+	// conversation. To send a reply, set inReplyTo.sequence in SendMessage.
+	InReplyTo *MessageReference `protobuf:"bytes,8,opt,name=inReplyTo" json:"inReplyTo,omitempty"`
 }
 
 func (m *Message) Reset()                    { *m = Message{} }
@@ -197,15 +228,120 @@ func (m *Message) GetText() string {
 	return ""
 }
 
+func (m *Message) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *Message) GetInReplyTo() *MessageReference {
+	if m != nil {
+		return m.InReplyTo
+	}
+	return nil
+}
+
+type MessageReference struct {
+	// Message.sequence of the referenced message, or zero for a reply from the
+	// contact to a message that isn't known, e.g. because it was sent before
+	// the history was kept.
+	Sequence uint64 `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+}
+
+func (m *MessageReference) Reset()                    { *m = MessageReference{} }
+func (m *MessageReference) String() string            { return proto.CompactTextString(m) }
+func (*MessageReference) ProtoMessage()               {}
+func (*MessageReference) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+
+func (m *MessageReference) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+type UnreadCountRequest struct {
+}
+
+func (m *UnreadCountRequest) Reset()                    { *m = UnreadCountRequest{} }
+func (m *UnreadCountRequest) String() string            { return proto.CompactTextString(m) }
+func (*UnreadCountRequest) ProtoMessage()               {}
+func (*UnreadCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{5} }
+
+type UnreadCountReply struct {
+	// Unread messages in all conversations, except those of muted contacts.
+	// Each contact's own count is in Contact.unreadCount.
+	Total uint32 `protobuf:"varint,1,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *UnreadCountReply) Reset()                    { *m = UnreadCountReply{} }
+func (m *UnreadCountReply) String() string            { return proto.CompactTextString(m) }
+func (*UnreadCountReply) ProtoMessage()               {}
+func (*UnreadCountReply) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{6} }
+
+func (m *UnreadCountReply) GetTotal() uint32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+type QueuedMessagesRequest struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+}
+
+func (m *QueuedMessagesRequest) Reset()                    { *m = QueuedMessagesRequest{} }
+func (m *QueuedMessagesRequest) String() string            { return proto.CompactTextString(m) }
+func (*QueuedMessagesRequest) ProtoMessage()               {}
+func (*QueuedMessagesRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{7} }
+
+func (m *QueuedMessagesRequest) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+type QueuedMessagesReply struct {
+	// Number of outbound messages waiting for the contact to be online
+	Count uint32 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+	// Timestamp of the oldest queued message, if any
+	OldestTimestamp int64 `protobuf:"varint,2,opt,name=oldestTimestamp" json:"oldestTimestamp,omitempty"`
+}
+
+func (m *QueuedMessagesReply) Reset()                    { *m = QueuedMessagesReply{} }
+func (m *QueuedMessagesReply) String() string            { return proto.CompactTextString(m) }
+func (*QueuedMessagesReply) ProtoMessage()               {}
+func (*QueuedMessagesReply) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{8} }
+
+func (m *QueuedMessagesReply) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *QueuedMessagesReply) GetOldestTimestamp() int64 {
+	if m != nil {
+		return m.OldestTimestamp
+	}
+	return 0
+}
+
+// Marks unread messages up to and including the message with lastRecvSequence
+// as read. lastRecvIdentifier is deprecated, and only used if lastRecvSequence
+// is unset; it can't tell apart messages from peers that reuse identifiers.
 type MarkConversationReadRequest struct {
 	Entity             *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
 	LastRecvIdentifier uint64  `protobuf:"varint,2,opt,name=lastRecvIdentifier" json:"lastRecvIdentifier,omitempty"`
+	LastRecvSequence   uint64  `protobuf:"varint,3,opt,name=lastRecvSequence" json:"lastRecvSequence,omitempty"`
 }
 
 func (m *MarkConversationReadRequest) Reset()                    { *m = MarkConversationReadRequest{} }
 func (m *MarkConversationReadRequest) String() string            { return proto.CompactTextString(m) }
 func (*MarkConversationReadRequest) ProtoMessage()               {}
-func (*MarkConversationReadRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+func (*MarkConversationReadRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{9} }
 
 func (m *MarkConversationReadRequest) GetEntity() *Entity {
 	if m != nil {
@@ -221,12 +357,93 @@ func (m *MarkConversationReadRequest) GetLastRecvIdentifier() uint64 {
 	return 0
 }
 
+func (m *MarkConversationReadRequest) GetLastRecvSequence() uint64 {
+	if m != nil {
+		return m.LastRecvSequence
+	}
+	return 0
+}
+
+type SetConversationTypingRequest struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	Typing bool    `protobuf:"varint,2,opt,name=typing" json:"typing,omitempty"`
+}
+
+func (m *SetConversationTypingRequest) Reset()                    { *m = SetConversationTypingRequest{} }
+func (m *SetConversationTypingRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetConversationTypingRequest) ProtoMessage()               {}
+func (*SetConversationTypingRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{10} }
+
+func (m *SetConversationTypingRequest) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *SetConversationTypingRequest) GetTyping() bool {
+	if m != nil {
+		return m.Typing
+	}
+	return false
+}
+
+type ConversationDraftRequest struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+}
+
+func (m *ConversationDraftRequest) Reset()                    { *m = ConversationDraftRequest{} }
+func (m *ConversationDraftRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConversationDraftRequest) ProtoMessage()               {}
+func (*ConversationDraftRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{11} }
+
+func (m *ConversationDraftRequest) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+// Text of a message that the user hasn't sent yet. An empty text means there
+// is no draft.
+type ConversationDraft struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	Text   string  `protobuf:"bytes,2,opt,name=text" json:"text,omitempty"`
+}
+
+func (m *ConversationDraft) Reset()                    { *m = ConversationDraft{} }
+func (m *ConversationDraft) String() string            { return proto.CompactTextString(m) }
+func (*ConversationDraft) ProtoMessage()               {}
+func (*ConversationDraft) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{12} }
+
+func (m *ConversationDraft) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *ConversationDraft) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ConversationEvent)(nil), "ricochet.ConversationEvent")
 	proto.RegisterType((*MonitorConversationsRequest)(nil), "ricochet.MonitorConversationsRequest")
 	proto.RegisterType((*Entity)(nil), "ricochet.Entity")
 	proto.RegisterType((*Message)(nil), "ricochet.Message")
+	proto.RegisterType((*MessageReference)(nil), "ricochet.MessageReference")
+	proto.RegisterType((*UnreadCountRequest)(nil), "ricochet.UnreadCountRequest")
+	proto.RegisterType((*UnreadCountReply)(nil), "ricochet.UnreadCountReply")
+	proto.RegisterType((*QueuedMessagesRequest)(nil), "ricochet.QueuedMessagesRequest")
+	proto.RegisterType((*QueuedMessagesReply)(nil), "ricochet.QueuedMessagesReply")
 	proto.RegisterType((*MarkConversationReadRequest)(nil), "ricochet.MarkConversationReadRequest")
+	proto.RegisterType((*SetConversationTypingRequest)(nil), "ricochet.SetConversationTypingRequest")
+	proto.RegisterType((*ConversationDraftRequest)(nil), "ricochet.ConversationDraftRequest")
+	proto.RegisterType((*ConversationDraft)(nil), "ricochet.ConversationDraft")
 	proto.RegisterEnum("ricochet.ConversationEvent_Type", ConversationEvent_Type_name, ConversationEvent_Type_value)
 	proto.RegisterEnum("ricochet.Message_Status", Message_Status_name, Message_Status_value)
 }
@@ -234,34 +451,46 @@ func init() {
 func init() { proto.RegisterFile("conversation.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 453 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x92, 0x41, 0x8f, 0x93, 0x4c,
-	0x18, 0xc7, 0x5f, 0x0a, 0xa5, 0xe5, 0xe9, 0xab, 0x99, 0x7d, 0x0e, 0x86, 0x64, 0xd5, 0x10, 0xbc,
-	0x70, 0x22, 0xa6, 0x7a, 0xf2, 0xd6, 0x2c, 0x13, 0xd3, 0xa4, 0xed, 0xd6, 0xe9, 0xb2, 0x17, 0x4f,
-	0x08, 0xcf, 0xae, 0x13, 0xb7, 0x80, 0xcc, 0x6c, 0xb5, 0x1f, 0xcb, 0xef, 0xe0, 0x07, 0x33, 0x4c,
-	0xd9, 0x40, 0x52, 0xbd, 0x31, 0xf3, 0xff, 0xcd, 0xe4, 0x99, 0xdf, 0x1f, 0xc0, 0xbc, 0x2a, 0x0f,
-	0xd4, 0xa8, 0x4c, 0xcb, 0xaa, 0x8c, 0xeb, 0xa6, 0xd2, 0x15, 0x4e, 0x1b, 0x99, 0x57, 0xf9, 0x57,
-	0xd2, 0xe1, 0x2f, 0x0b, 0x2e, 0xae, 0x06, 0x00, 0x3f, 0x50, 0xa9, 0xf1, 0x3d, 0x38, 0xfa, 0x58,
-	0x93, 0x6f, 0x05, 0x56, 0xf4, 0x7c, 0x1e, 0xc4, 0x4f, 0x78, 0x7c, 0x86, 0xc6, 0x37, 0xc7, 0x9a,
-	0x84, 0xa1, 0xf1, 0x0d, 0xd8, 0x7b, 0x75, 0xef, 0x8f, 0x02, 0x2b, 0x9a, 0xcd, 0x2f, 0xfa, 0x43,
-	0x6b, 0x52, 0x2a, 0xbb, 0x27, 0xd1, 0xa6, 0xe1, 0x02, 0x9c, 0xf6, 0x08, 0x4e, 0xc1, 0xd9, 0xa4,
-	0xab, 0x15, 0xfb, 0x0f, 0xff, 0x87, 0xe9, 0xf6, 0x7a, 0x9b, 0xae, 0x16, 0x37, 0x9c, 0x59, 0x38,
-	0x83, 0x89, 0xe0, 0x57, 0x7c, 0x79, 0xcb, 0xd9, 0xa8, 0x85, 0x76, 0x7c, 0x93, 0x30, 0x1b, 0x01,
-	0xdc, 0x74, 0x9b, 0xb4, 0x88, 0x13, 0xbe, 0x82, 0xcb, 0x75, 0x55, 0x4a, 0x5d, 0x35, 0xc3, 0x71,
-	0x94, 0xa0, 0xef, 0x8f, 0xa4, 0x74, 0xf8, 0x01, 0x5c, 0x5e, 0x6a, 0xa9, 0x8f, 0xe8, 0xc3, 0x24,
-	0x2b, 0x8a, 0x86, 0x94, 0x32, 0x43, 0x79, 0xe2, 0x69, 0x89, 0x2f, 0xc0, 0x95, 0x6a, 0x47, 0x0f,
-	0x77, 0xbe, 0x1d, 0x58, 0xd1, 0x54, 0x74, 0xab, 0xf0, 0xf7, 0x08, 0x26, 0xdd, 0xb8, 0x18, 0x81,
-	0xab, 0xa8, 0x2c, 0xa8, 0x31, 0x1a, 0x66, 0x73, 0xd6, 0xbf, 0xe8, 0x74, 0xbf, 0xe8, 0x72, 0x8c,
-	0xc1, 0x6b, 0x28, 0x97, 0xb5, 0xa4, 0x52, 0x77, 0xcf, 0x3f, 0x87, 0x7b, 0x04, 0x5f, 0x82, 0xa7,
-	0xe5, 0x9e, 0x94, 0xce, 0xf6, 0xb5, 0x19, 0xc0, 0x16, 0xfd, 0x06, 0xbe, 0x06, 0x90, 0x05, 0x95,
-	0x5a, 0xde, 0x49, 0x6a, 0x7c, 0x27, 0xb0, 0x22, 0x47, 0x0c, 0x76, 0xf0, 0x2d, 0xb8, 0x4a, 0x67,
-	0xfa, 0x51, 0xf9, 0x63, 0x53, 0x8f, 0x7f, 0x66, 0x3a, 0xde, 0x99, 0x5c, 0x74, 0x1c, 0x22, 0x38,
-	0x9a, 0x7e, 0x6a, 0xdf, 0x35, 0x12, 0xcc, 0x77, 0xf8, 0x19, 0xdc, 0x13, 0x35, 0x68, 0xc2, 0x83,
-	0x31, 0x17, 0xe2, 0x5a, 0x30, 0xab, 0xf5, 0xfd, 0x29, 0xe5, 0x29, 0x4f, 0xd8, 0xa8, 0xad, 0xa4,
-	0x6d, 0x61, 0xb9, 0xf9, 0xc8, 0x6c, 0x7c, 0x06, 0x5e, 0xc2, 0x57, 0xcb, 0x5b, 0x2e, 0x78, 0xc2,
-	0x1c, 0xd3, 0xcb, 0x46, 0xf0, 0x45, 0xc2, 0xc6, 0xed, 0x45, 0xe6, 0xcb, 0x0d, 0x7f, 0xc0, 0xe5,
-	0x3a, 0x6b, 0xbe, 0x0d, 0xeb, 0x11, 0x94, 0x15, 0x5d, 0x43, 0xad, 0x59, 0x32, 0x52, 0xfe, 0x6d,
-	0xf6, 0x94, 0x63, 0x0c, 0xf8, 0x90, 0x29, 0x2d, 0x28, 0x3f, 0x2c, 0x7b, 0x27, 0x23, 0xe3, 0xe4,
-	0x2f, 0xc9, 0x17, 0xd7, 0xfc, 0xdf, 0xef, 0xfe, 0x04, 0x00, 0x00, 0xff, 0xff, 0x5c, 0x9e, 0xac,
-	0x63, 0xf5, 0x02, 0x00, 0x00,
+	// 655 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x54, 0x41, 0x6f, 0xd3, 0x4c,
+	0x10, 0xfd, 0xec, 0x38, 0x8e, 0x33, 0xfd, 0x0a, 0xee, 0x52, 0x90, 0xd5, 0x16, 0x14, 0x99, 0x4b,
+	0xc4, 0xc1, 0x42, 0x85, 0x03, 0xe2, 0x56, 0xd5, 0x2b, 0x14, 0x29, 0x49, 0xd3, 0x4d, 0x5c, 0x09,
+	0x71, 0xc1, 0xd8, 0x93, 0x62, 0x91, 0xda, 0xc6, 0xbb, 0xa9, 0xc8, 0x5f, 0xe1, 0xca, 0x0f, 0x05,
+	0xed, 0xc6, 0xae, 0x9d, 0x86, 0x22, 0xe5, 0xe6, 0x99, 0x79, 0x9e, 0x99, 0x37, 0x6f, 0x76, 0x80,
+	0x44, 0x59, 0x7a, 0x8b, 0x05, 0x0f, 0x45, 0x92, 0xa5, 0x5e, 0x5e, 0x64, 0x22, 0x23, 0x56, 0x91,
+	0x44, 0x59, 0xf4, 0x15, 0x85, 0xfb, 0x5b, 0x83, 0x83, 0xf3, 0x06, 0x80, 0xde, 0x62, 0x2a, 0xc8,
+	0x5b, 0x30, 0xc4, 0x2a, 0x47, 0x47, 0xeb, 0x69, 0xfd, 0x47, 0xa7, 0x3d, 0xaf, 0x82, 0x7b, 0x5b,
+	0x50, 0x6f, 0xb6, 0xca, 0x91, 0x29, 0x34, 0x79, 0x09, 0xad, 0x1b, 0x7e, 0xed, 0xe8, 0x3d, 0xad,
+	0xbf, 0x77, 0x7a, 0x50, 0xff, 0x34, 0x42, 0xce, 0xc3, 0x6b, 0x64, 0x32, 0x4a, 0xfa, 0x60, 0x62,
+	0x2a, 0x12, 0xb1, 0x72, 0x5a, 0x0a, 0x67, 0xd7, 0x38, 0xaa, 0xfc, 0xac, 0x8c, 0x93, 0x67, 0x60,
+	0x8a, 0x55, 0x9e, 0xa4, 0xd7, 0x8e, 0xd1, 0xd3, 0xfa, 0x16, 0x2b, 0x2d, 0x77, 0x04, 0x86, 0x2c,
+	0x4a, 0x2c, 0x30, 0xc6, 0xc1, 0x70, 0x68, 0xff, 0x47, 0xfe, 0x07, 0x6b, 0x72, 0x31, 0x09, 0x86,
+	0x67, 0x33, 0x6a, 0x6b, 0x64, 0x0f, 0x3a, 0x8c, 0x9e, 0xd3, 0xc1, 0x15, 0xb5, 0x75, 0x09, 0x9a,
+	0xd2, 0xb1, 0x6f, 0xb7, 0x08, 0x80, 0x19, 0x4c, 0x7c, 0x09, 0x31, 0xe4, 0xf7, 0xec, 0xe3, 0x64,
+	0x30, 0xfe, 0x60, 0xb7, 0xdd, 0xe7, 0x70, 0x3c, 0xca, 0xd2, 0x44, 0x64, 0x45, 0x93, 0x1c, 0x67,
+	0xf8, 0x7d, 0x89, 0x5c, 0xb8, 0xef, 0xc1, 0x5c, 0xf7, 0x45, 0x1c, 0xe8, 0x84, 0x71, 0x5c, 0x20,
+	0xe7, 0x8a, 0x62, 0x97, 0x55, 0xa6, 0xec, 0x34, 0xe1, 0x53, 0x5c, 0xcc, 0x15, 0x27, 0x8b, 0x95,
+	0x96, 0xfb, 0xb3, 0x05, 0x9d, 0x92, 0xbc, 0xe4, 0xcd, 0x31, 0x8d, 0xb1, 0x70, 0xb4, 0x87, 0x78,
+	0xaf, 0xe3, 0xc4, 0x83, 0x6e, 0x81, 0x51, 0x92, 0x27, 0x98, 0x0a, 0x47, 0x7f, 0x00, 0x5c, 0x43,
+	0xc8, 0x09, 0x74, 0x45, 0x72, 0x83, 0x5c, 0x84, 0x37, 0xb9, 0x6a, 0xa0, 0xc5, 0x6a, 0x07, 0x79,
+	0x01, 0x90, 0xc4, 0x72, 0xa2, 0xf3, 0x04, 0x0b, 0x35, 0x49, 0x83, 0x35, 0x3c, 0xe4, 0x35, 0x98,
+	0x5c, 0x84, 0x62, 0xc9, 0x9d, 0xb6, 0x12, 0xdb, 0xd9, 0xd2, 0xcd, 0x9b, 0xaa, 0x38, 0x2b, 0x71,
+	0x84, 0x80, 0x21, 0xf0, 0x87, 0x70, 0x4c, 0x35, 0x04, 0xf5, 0x4d, 0x8e, 0xc0, 0xe2, 0x72, 0x60,
+	0x69, 0x84, 0x4e, 0x47, 0xd5, 0xb8, 0xb3, 0xc9, 0x3b, 0xe8, 0x26, 0x29, 0xc3, 0x7c, 0xb1, 0x9a,
+	0x65, 0x8e, 0xa5, 0xf8, 0x1c, 0x6d, 0x2f, 0x07, 0xce, 0xb1, 0x90, 0x70, 0x56, 0x83, 0xdd, 0x4f,
+	0x60, 0xae, 0x6b, 0x37, 0xb4, 0xee, 0x42, 0x9b, 0x32, 0x76, 0xc1, 0x6c, 0x4d, 0xaa, 0x78, 0x19,
+	0xd0, 0x80, 0xfa, 0xb6, 0x2e, 0x45, 0x97, 0x3a, 0x4b, 0x49, 0x5b, 0x64, 0x1f, 0xba, 0x3e, 0x1d,
+	0x0e, 0xae, 0x28, 0xa3, 0xfe, 0x5a, 0xed, 0x60, 0xcc, 0xe8, 0x99, 0x6f, 0xb7, 0x65, 0x22, 0xf5,
+	0x65, 0xba, 0x1e, 0xd8, 0xf7, 0x6b, 0x6f, 0xd0, 0xd0, 0x36, 0x69, 0xb8, 0x87, 0x40, 0x82, 0xb4,
+	0xc0, 0x30, 0x3e, 0xcf, 0x96, 0xa9, 0xa8, 0xd6, 0xa3, 0x0f, 0xf6, 0x86, 0x37, 0x5f, 0xac, 0xc8,
+	0x21, 0xb4, 0x45, 0x26, 0xc2, 0x85, 0x4a, 0xb1, 0xcf, 0xd6, 0x86, 0x7b, 0x06, 0x4f, 0x2f, 0x97,
+	0xb8, 0xc4, 0xb8, 0xac, 0x5a, 0x6d, 0x58, 0xe3, 0x45, 0x68, 0xff, 0x7e, 0x11, 0x6e, 0x00, 0x4f,
+	0xee, 0xa7, 0x28, 0xeb, 0x45, 0xb2, 0x7a, 0x55, 0x4f, 0x19, 0xa4, 0x0f, 0x8f, 0xb3, 0x45, 0x8c,
+	0x5c, 0xcc, 0xee, 0x96, 0x43, 0x57, 0xcb, 0x71, 0xdf, 0xed, 0xfe, 0xd2, 0xe0, 0x78, 0x14, 0x16,
+	0xdf, 0x9a, 0xfb, 0xcf, 0x30, 0x8c, 0x77, 0x6e, 0x90, 0x78, 0x40, 0x16, 0x21, 0x17, 0x0c, 0xa3,
+	0xdb, 0x41, 0xbd, 0x74, 0xba, 0x9a, 0xe4, 0x5f, 0x22, 0xe4, 0x15, 0xd8, 0x95, 0x77, 0x5a, 0xcd,
+	0xbd, 0xa5, 0xd0, 0x5b, 0x7e, 0xf7, 0x33, 0x9c, 0x4c, 0x51, 0x34, 0x7b, 0x9c, 0xa9, 0x7b, 0xb0,
+	0x7b, 0x97, 0xf5, 0x61, 0xd1, 0x37, 0x0e, 0x8b, 0x0f, 0x4e, 0x33, 0xbd, 0x5f, 0x84, 0x73, 0xb1,
+	0xbb, 0x48, 0x97, 0x70, 0xb0, 0x95, 0x65, 0x87, 0xe6, 0xaa, 0xd7, 0xa5, 0xd7, 0xaf, 0xeb, 0x8b,
+	0xa9, 0xae, 0xf6, 0x9b, 0x3f, 0x03, 0x00, 0x82, 0xc0, 0x99, 0xc9, 0xcb, 0x05, 0x00, 0x00,
 }
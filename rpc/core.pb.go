@@ -18,6 +18,78 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+type LogLevel_Level int32
+
+const (
+	LogLevel_DEBUG   LogLevel_Level = 0
+	LogLevel_INFO    LogLevel_Level = 1
+	LogLevel_WARNING LogLevel_Level = 2
+	LogLevel_ERROR   LogLevel_Level = 3
+)
+
+var LogLevel_Level_name = map[int32]string{
+	0: "DEBUG",
+	1: "INFO",
+	2: "WARNING",
+	3: "ERROR",
+}
+var LogLevel_Level_value = map[string]int32{
+	"DEBUG":   0,
+	"INFO":    1,
+	"WARNING": 2,
+	"ERROR":   3,
+}
+
+func (x LogLevel_Level) String() string {
+	return proto.EnumName(LogLevel_Level_name, int32(x))
+}
+func (LogLevel_Level) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{6, 0} }
+
+type AuditEntry_Type int32
+
+const (
+	AuditEntry_UNKNOWN                AuditEntry_Type = 0
+	AuditEntry_CONTACT_ADDED          AuditEntry_Type = 1
+	AuditEntry_CONTACT_REMOVED        AuditEntry_Type = 2
+	AuditEntry_REQUEST_SENT           AuditEntry_Type = 3
+	AuditEntry_REQUEST_RECEIVED       AuditEntry_Type = 4
+	AuditEntry_REQUEST_ACCEPTED       AuditEntry_Type = 5
+	AuditEntry_REQUEST_REJECTED       AuditEntry_Type = 6
+	AuditEntry_CONNECTION_ESTABLISHED AuditEntry_Type = 7
+	AuditEntry_CONNECTION_LOST        AuditEntry_Type = 8
+	AuditEntry_AUTHENTICATION_FAILED  AuditEntry_Type = 9
+)
+
+var AuditEntry_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CONTACT_ADDED",
+	2: "CONTACT_REMOVED",
+	3: "REQUEST_SENT",
+	4: "REQUEST_RECEIVED",
+	5: "REQUEST_ACCEPTED",
+	6: "REQUEST_REJECTED",
+	7: "CONNECTION_ESTABLISHED",
+	8: "CONNECTION_LOST",
+	9: "AUTHENTICATION_FAILED",
+}
+var AuditEntry_Type_value = map[string]int32{
+	"UNKNOWN":                0,
+	"CONTACT_ADDED":          1,
+	"CONTACT_REMOVED":        2,
+	"REQUEST_SENT":           3,
+	"REQUEST_RECEIVED":       4,
+	"REQUEST_ACCEPTED":       5,
+	"REQUEST_REJECTED":       6,
+	"CONNECTION_ESTABLISHED": 7,
+	"CONNECTION_LOST":        8,
+	"AUTHENTICATION_FAILED":  9,
+}
+
+func (x AuditEntry_Type) String() string {
+	return proto.EnumName(AuditEntry_Type_name, int32(x))
+}
+func (AuditEntry_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{9, 0} }
+
 type Reply struct {
 }
 
@@ -45,6 +117,8 @@ func (m *ServerStatusRequest) GetRpcVersion() int32 {
 type ServerStatusReply struct {
 	RpcVersion    int32  `protobuf:"varint,1,opt,name=rpcVersion" json:"rpcVersion,omitempty"`
 	ServerVersion string `protobuf:"bytes,2,opt,name=serverVersion" json:"serverVersion,omitempty"`
+	// Text longer than these limits is refused by the backend
+	Limits *AcceptanceLimits `protobuf:"bytes,3,opt,name=limits" json:"limits,omitempty"`
 }
 
 func (m *ServerStatusReply) Reset()                    { *m = ServerStatusReply{} }
@@ -66,10 +140,246 @@ func (m *ServerStatusReply) GetServerVersion() string {
 	return ""
 }
 
+func (m *ServerStatusReply) GetLimits() *AcceptanceLimits {
+	if m != nil {
+		return m.Limits
+	}
+	return nil
+}
+
+type HealthRequest struct {
+}
+
+func (m *HealthRequest) Reset()                    { *m = HealthRequest{} }
+func (m *HealthRequest) String() string            { return proto.CompactTextString(m) }
+func (*HealthRequest) ProtoMessage()               {}
+func (*HealthRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
+
+type HealthReply struct {
+	ServerVersion string `protobuf:"bytes,1,opt,name=serverVersion" json:"serverVersion,omitempty"`
+	// Set if the identity is loaded, tor is reachable, and the onion service
+	// is published, so that contacts can connect in both directions
+	Ready          bool `protobuf:"varint,2,opt,name=ready" json:"ready,omitempty"`
+	IdentityLoaded bool `protobuf:"varint,3,opt,name=identityLoaded" json:"identityLoaded,omitempty"`
+	// The tor control connection is up, tor has bootstrapped, and its SOCKS
+	// port hasn't failed
+	TorReachable      bool   `protobuf:"varint,4,opt,name=torReachable" json:"torReachable,omitempty"`
+	OnionPublished    bool   `protobuf:"varint,5,opt,name=onionPublished" json:"onionPublished,omitempty"`
+	ConnectedContacts uint32 `protobuf:"varint,6,opt,name=connectedContacts" json:"connectedContacts,omitempty"`
+	TotalContacts     uint32 `protobuf:"varint,7,opt,name=totalContacts" json:"totalContacts,omitempty"`
+	UptimeSeconds     int64  `protobuf:"varint,8,opt,name=uptimeSeconds" json:"uptimeSeconds,omitempty"`
+}
+
+func (m *HealthReply) Reset()                    { *m = HealthReply{} }
+func (m *HealthReply) String() string            { return proto.CompactTextString(m) }
+func (*HealthReply) ProtoMessage()               {}
+func (*HealthReply) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{4} }
+
+func (m *HealthReply) GetServerVersion() string {
+	if m != nil {
+		return m.ServerVersion
+	}
+	return ""
+}
+
+func (m *HealthReply) GetReady() bool {
+	if m != nil {
+		return m.Ready
+	}
+	return false
+}
+
+func (m *HealthReply) GetIdentityLoaded() bool {
+	if m != nil {
+		return m.IdentityLoaded
+	}
+	return false
+}
+
+func (m *HealthReply) GetTorReachable() bool {
+	if m != nil {
+		return m.TorReachable
+	}
+	return false
+}
+
+func (m *HealthReply) GetOnionPublished() bool {
+	if m != nil {
+		return m.OnionPublished
+	}
+	return false
+}
+
+func (m *HealthReply) GetConnectedContacts() uint32 {
+	if m != nil {
+		return m.ConnectedContacts
+	}
+	return 0
+}
+
+func (m *HealthReply) GetTotalContacts() uint32 {
+	if m != nil {
+		return m.TotalContacts
+	}
+	return 0
+}
+
+func (m *HealthReply) GetUptimeSeconds() int64 {
+	if m != nil {
+		return m.UptimeSeconds
+	}
+	return 0
+}
+
+type LogLevelRequest struct {
+}
+
+func (m *LogLevelRequest) Reset()                    { *m = LogLevelRequest{} }
+func (m *LogLevelRequest) String() string            { return proto.CompactTextString(m) }
+func (*LogLevelRequest) ProtoMessage()               {}
+func (*LogLevelRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{5} }
+
+type LogLevel struct {
+	Level LogLevel_Level `protobuf:"varint,1,opt,name=level,enum=ricochet.LogLevel_Level" json:"level,omitempty"`
+}
+
+func (m *LogLevel) Reset()                    { *m = LogLevel{} }
+func (m *LogLevel) String() string            { return proto.CompactTextString(m) }
+func (*LogLevel) ProtoMessage()               {}
+func (*LogLevel) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{6} }
+
+func (m *LogLevel) GetLevel() LogLevel_Level {
+	if m != nil {
+		return m.Level
+	}
+	return LogLevel_DEBUG
+}
+
+type AuditLogRequest struct {
+	// Maximum number of entries to return, or all available entries if zero
+	Limit uint32 `protobuf:"varint,1,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *AuditLogRequest) Reset()                    { *m = AuditLogRequest{} }
+func (m *AuditLogRequest) String() string            { return proto.CompactTextString(m) }
+func (*AuditLogRequest) ProtoMessage()               {}
+func (*AuditLogRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
+
+func (m *AuditLogRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type AuditLogReply struct {
+	Entries []*AuditEntry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *AuditLogReply) Reset()                    { *m = AuditLogReply{} }
+func (m *AuditLogReply) String() string            { return proto.CompactTextString(m) }
+func (*AuditLogReply) ProtoMessage()               {}
+func (*AuditLogReply) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+func (m *AuditLogReply) GetEntries() []*AuditEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// AuditEntry records a significant event for a contact or connection. The
+// audit log is meant to be kept, and doesn't depend on the log level.
+type AuditEntry struct {
+	Time string          `protobuf:"bytes,1,opt,name=time" json:"time,omitempty"`
+	Type AuditEntry_Type `protobuf:"varint,2,opt,name=type,enum=ricochet.AuditEntry_Type" json:"type,omitempty"`
+	// Address of the contact or peer, if known
+	Address string `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+	// Further details, like the direction of a request or connection
+	Detail string `protobuf:"bytes,4,opt,name=detail" json:"detail,omitempty"`
+}
+
+func (m *AuditEntry) Reset()                    { *m = AuditEntry{} }
+func (m *AuditEntry) String() string            { return proto.CompactTextString(m) }
+func (*AuditEntry) ProtoMessage()               {}
+func (*AuditEntry) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *AuditEntry) GetTime() string {
+	if m != nil {
+		return m.Time
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetType() AuditEntry_Type {
+	if m != nil {
+		return m.Type
+	}
+	return AuditEntry_UNKNOWN
+}
+
+func (m *AuditEntry) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetDetail() string {
+	if m != nil {
+		return m.Detail
+	}
+	return ""
+}
+
+type AcceptanceLimits struct {
+	// In unicode characters
+	MaxNicknameLength int32 `protobuf:"varint,1,opt,name=maxNicknameLength" json:"maxNicknameLength,omitempty"`
+	// In bytes of UTF-8
+	MaxMessageLength      int32 `protobuf:"varint,2,opt,name=maxMessageLength" json:"maxMessageLength,omitempty"`
+	MaxRejectReasonLength int32 `protobuf:"varint,3,opt,name=maxRejectReasonLength" json:"maxRejectReasonLength,omitempty"`
+}
+
+func (m *AcceptanceLimits) Reset()                    { *m = AcceptanceLimits{} }
+func (m *AcceptanceLimits) String() string            { return proto.CompactTextString(m) }
+func (*AcceptanceLimits) ProtoMessage()               {}
+func (*AcceptanceLimits) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{10} }
+
+func (m *AcceptanceLimits) GetMaxNicknameLength() int32 {
+	if m != nil {
+		return m.MaxNicknameLength
+	}
+	return 0
+}
+
+func (m *AcceptanceLimits) GetMaxMessageLength() int32 {
+	if m != nil {
+		return m.MaxMessageLength
+	}
+	return 0
+}
+
+func (m *AcceptanceLimits) GetMaxRejectReasonLength() int32 {
+	if m != nil {
+		return m.MaxRejectReasonLength
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Reply)(nil), "ricochet.Reply")
 	proto.RegisterType((*ServerStatusRequest)(nil), "ricochet.ServerStatusRequest")
 	proto.RegisterType((*ServerStatusReply)(nil), "ricochet.ServerStatusReply")
+	proto.RegisterType((*HealthRequest)(nil), "ricochet.HealthRequest")
+	proto.RegisterType((*HealthReply)(nil), "ricochet.HealthReply")
+	proto.RegisterType((*LogLevelRequest)(nil), "ricochet.LogLevelRequest")
+	proto.RegisterType((*LogLevel)(nil), "ricochet.LogLevel")
+	proto.RegisterType((*AuditLogRequest)(nil), "ricochet.AuditLogRequest")
+	proto.RegisterType((*AuditLogReply)(nil), "ricochet.AuditLogReply")
+	proto.RegisterType((*AuditEntry)(nil), "ricochet.AuditEntry")
+	proto.RegisterType((*AcceptanceLimits)(nil), "ricochet.AcceptanceLimits")
+	proto.RegisterEnum("ricochet.LogLevel_Level", LogLevel_Level_name, LogLevel_Level_value)
+	proto.RegisterEnum("ricochet.AuditEntry_Type", AuditEntry_Type_name, AuditEntry_Type_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -85,6 +395,17 @@ const _ = grpc.SupportPackageIsVersion4
 type RicochetCoreClient interface {
 	// Query RPC server version and status
 	GetServerStatus(ctx context.Context, in *ServerStatusRequest, opts ...grpc.CallOption) (*ServerStatusReply, error)
+	// Report whether the backend is ready for use, e.g. for monitoring a
+	// service. This answers from state that's already known, and never waits
+	// for the network or contact connections.
+	GetHealth(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthReply, error)
+	// Query or change the lowest level of messages logged by the backend. The
+	// level isn't saved, and applies until the backend is restarted.
+	GetLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error)
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error)
+	// Query the most recent entries in the audit log, oldest first. Only
+	// entries recorded since the backend started are available.
+	GetAuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogReply, error)
 	// Open a stream to monitor changes to network status. The current
 	// NetworkStatus will be sent immediately, and the stream will receive a
 	// new NetworkStatus after any changes until the stream is closed.
@@ -100,21 +421,100 @@ type RicochetCoreClient interface {
 	// has been taken offline, and returns the new network status.
 	StopNetwork(ctx context.Context, in *StopNetworkRequest, opts ...grpc.CallOption) (*NetworkStatus, error)
 	GetIdentity(ctx context.Context, in *IdentityRequest, opts ...grpc.CallOption) (*Identity, error)
+	// Export the identity's private key, encrypted with a passphrase, for
+	// backup or moving to another device. Import replaces the identity when
+	// the backend is restarted.
+	ExportIdentity(ctx context.Context, in *ExportIdentityRequest, opts ...grpc.CallOption) (*ExportIdentityReply, error)
+	ImportIdentity(ctx context.Context, in *ImportIdentityRequest, opts ...grpc.CallOption) (*ImportIdentityReply, error)
+	// Query or change how inbound contact requests are handled
+	GetContactRequestPolicy(ctx context.Context, in *ContactRequestPolicyRequest, opts ...grpc.CallOption) (*ContactRequestPolicy, error)
+	SetContactRequestPolicy(ctx context.Context, in *ContactRequestPolicy, opts ...grpc.CallOption) (*ContactRequestPolicy, error)
+	// Query or change whether connections are made to and accepted from
+	// contacts, such as to pause outbound connections or appear offline
+	GetConnectionMode(ctx context.Context, in *ConnectionModeRequest, opts ...grpc.CallOption) (*ConnectionMode, error)
+	SetConnectionMode(ctx context.Context, in *ConnectionMode, opts ...grpc.CallOption) (*ConnectionMode, error)
+	// Query or change our presence, which is sent to connected contacts
+	GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*Presence, error)
+	SetPresence(ctx context.Context, in *Presence, opts ...grpc.CallOption) (*Presence, error)
 	// Query contacts and monitor for contact changes. The full contact list
 	// is sent in POPULATE events, terminated by a POPULATE event with no
 	// subject. Any new, removed, or modified contacts, including changes in
 	// the state of contacts, are sent as ADD, UPDATE, or DELETE events until
 	// the stream is closed.
 	MonitorContacts(ctx context.Context, in *MonitorContactsRequest, opts ...grpc.CallOption) (RicochetCore_MonitorContactsClient, error)
+	// Get the current state of one contact, which is the same as in the
+	// latest event for it from MonitorContacts. The address is accepted in
+	// the same forms as for AddContactRequest; INVALID_ARGUMENT is returned
+	// if it isn't valid, and NOT_FOUND if it isn't a contact.
+	GetContact(ctx context.Context, in *GetContactRequest, opts ...grpc.CallOption) (*Contact, error)
+	// The request's address may have any case, surrounding whitespace, and
+	// an optional 'ricochet:' prefix or '.onion' suffix. The contact is added
+	// with the canonical address, and INVALID_ARGUMENT is returned if it isn't
+	// valid.
 	AddContactRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*Contact, error)
 	UpdateContact(ctx context.Context, in *Contact, opts ...grpc.CallOption) (*Contact, error)
 	DeleteContact(ctx context.Context, in *DeleteContactRequest, opts ...grpc.CallOption) (*DeleteContactReply, error)
 	AcceptInboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*Contact, error)
 	RejectInboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*RejectInboundRequestReply, error)
+	// Withdraw an outbound contact request that hasn't been accepted, and
+	// remove the contact. Fails if the request has already been accepted.
+	CancelOutboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*CancelOutboundRequestReply, error)
+	// Change the fromNickname and text of an outbound contact request that
+	// hasn't been delivered yet, which are used for the next delivery
+	// attempt. FAILED_PRECONDITION is returned once it has been delivered.
+	EditOutboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Block or unblock a contact. Blocked contacts can't connect or send
+	// contact requests, and no connections are made to them.
+	SetContactBlocked(ctx context.Context, in *SetContactBlockedRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Change the local nickname of a contact
+	SetContactNickname(ctx context.Context, in *SetContactNicknameRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Pin a contact, or change its position in the contact list. Contacts
+	// are sent by MonitorContacts in this order.
+	SetContactOrder(ctx context.Context, in *SetContactOrderRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Choose whether connections to a contact are made when the backend
+	// starts. Contacts that aren't are dormant until ConnectContact is used,
+	// a message is sent to them, or they connect to us.
+	SetContactConnectOnStartup(ctx context.Context, in *SetContactConnectOnStartupRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Mute or unmute notifications of messages from a contact
+	SetContactMuted(ctx context.Context, in *SetContactMutedRequest, opts ...grpc.CallOption) (*Contact, error)
+	ConnectContact(ctx context.Context, in *ConnectContactRequest, opts ...grpc.CallOption) (*Contact, error)
+	// Describe the connection state of contacts, for debugging
+	GetContactDiagnostics(ctx context.Context, in *ContactDiagnosticsRequest, opts ...grpc.CallOption) (*ContactDiagnosticsReply, error)
+	// Export and import the list of established contacts. Imported contacts
+	// are added as known contacts, without sending a contact request.
+	ExportContacts(ctx context.Context, in *ExportContactsRequest, opts ...grpc.CallOption) (*ContactListExport, error)
+	ImportContacts(ctx context.Context, in *ContactListExport, opts ...grpc.CallOption) (*ImportContactsReply, error)
 	// Open a stream to monitor messages in conversations with contacts.
 	MonitorConversations(ctx context.Context, in *MonitorConversationsRequest, opts ...grpc.CallOption) (RicochetCore_MonitorConversationsClient, error)
+	// Send a message to recipient.address, which must be an accepted contact.
+	// Other fields identifying the recipient and sender can be omitted.
 	SendMessage(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Message, error)
 	MarkConversationRead(ctx context.Context, in *MarkConversationReadRequest, opts ...grpc.CallOption) (*Reply, error)
+	// Get the number of unread messages in all conversations, such as for a
+	// badge. Changes to each contact's count are sent by MonitorContacts.
+	GetUnreadCount(ctx context.Context, in *UnreadCountRequest, opts ...grpc.CallOption) (*UnreadCountReply, error)
+	// Get the number of messages queued to send to a contact while it is offline
+	GetQueuedMessages(ctx context.Context, in *QueuedMessagesRequest, opts ...grpc.CallOption) (*QueuedMessagesReply, error)
+	// Tell the contact whether the user is typing a message. Clients may call
+	// this as often as they like while the user is typing; the backend limits
+	// how often the contact is notified, and stops the indicator if it isn't
+	// renewed.
+	SetConversationTyping(ctx context.Context, in *SetConversationTypingRequest, opts ...grpc.CallOption) (*Reply, error)
+	// Save or get the unsent message for a conversation, so a client can
+	// restore it later, including after restarting. Drafts are stored by the
+	// backend only, and setting an empty text removes the draft.
+	GetConversationDraft(ctx context.Context, in *ConversationDraftRequest, opts ...grpc.CallOption) (*ConversationDraft, error)
+	SetConversationDraft(ctx context.Context, in *ConversationDraft, opts ...grpc.CallOption) (*ConversationDraft, error)
+	// Monitor file transfers with contacts. Existing transfers are sent in
+	// POPULATE events, terminated by a POPULATE event with no transfer, and
+	// new transfers and changes are sent as ADD and UPDATE events.
+	MonitorFileTransfers(ctx context.Context, in *MonitorFileTransfersRequest, opts ...grpc.CallOption) (RicochetCore_MonitorFileTransfersClient, error)
+	// Offer a file to a contact. The transfer starts once the contact is
+	// online and has accepted the offer.
+	SendFile(ctx context.Context, in *SendFileRequest, opts ...grpc.CallOption) (*FileTransfer, error)
+	// Accept or reject an inbound file transfer offer
+	RespondFileTransfer(ctx context.Context, in *RespondFileTransferRequest, opts ...grpc.CallOption) (*FileTransfer, error)
+	CancelFileTransfer(ctx context.Context, in *CancelFileTransferRequest, opts ...grpc.CallOption) (*FileTransfer, error)
 }
 
 type ricochetCoreClient struct {
@@ -134,6 +534,42 @@ func (c *ricochetCoreClient) GetServerStatus(ctx context.Context, in *ServerStat
 	return out, nil
 }
 
+func (c *ricochetCoreClient) GetHealth(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthReply, error) {
+	out := new(HealthReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetHealth", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetAuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogReply, error) {
+	out := new(AuditLogReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetAuditLog", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *ricochetCoreClient) MonitorNetwork(ctx context.Context, in *MonitorNetworkRequest, opts ...grpc.CallOption) (RicochetCore_MonitorNetworkClient, error) {
 	stream, err := grpc.NewClientStream(ctx, &_RicochetCore_serviceDesc.Streams[0], c.cc, "/ricochet.RicochetCore/MonitorNetwork", opts...)
 	if err != nil {
@@ -193,6 +629,78 @@ func (c *ricochetCoreClient) GetIdentity(ctx context.Context, in *IdentityReques
 	return out, nil
 }
 
+func (c *ricochetCoreClient) ExportIdentity(ctx context.Context, in *ExportIdentityRequest, opts ...grpc.CallOption) (*ExportIdentityReply, error) {
+	out := new(ExportIdentityReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/ExportIdentity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) ImportIdentity(ctx context.Context, in *ImportIdentityRequest, opts ...grpc.CallOption) (*ImportIdentityReply, error) {
+	out := new(ImportIdentityReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/ImportIdentity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetContactRequestPolicy(ctx context.Context, in *ContactRequestPolicyRequest, opts ...grpc.CallOption) (*ContactRequestPolicy, error) {
+	out := new(ContactRequestPolicy)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetContactRequestPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetContactRequestPolicy(ctx context.Context, in *ContactRequestPolicy, opts ...grpc.CallOption) (*ContactRequestPolicy, error) {
+	out := new(ContactRequestPolicy)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactRequestPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetConnectionMode(ctx context.Context, in *ConnectionModeRequest, opts ...grpc.CallOption) (*ConnectionMode, error) {
+	out := new(ConnectionMode)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetConnectionMode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetConnectionMode(ctx context.Context, in *ConnectionMode, opts ...grpc.CallOption) (*ConnectionMode, error) {
+	out := new(ConnectionMode)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetConnectionMode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*Presence, error) {
+	out := new(Presence)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetPresence", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetPresence(ctx context.Context, in *Presence, opts ...grpc.CallOption) (*Presence, error) {
+	out := new(Presence)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetPresence", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *ricochetCoreClient) MonitorContacts(ctx context.Context, in *MonitorContactsRequest, opts ...grpc.CallOption) (RicochetCore_MonitorContactsClient, error) {
 	stream, err := grpc.NewClientStream(ctx, &_RicochetCore_serviceDesc.Streams[1], c.cc, "/ricochet.RicochetCore/MonitorContacts", opts...)
 	if err != nil {
@@ -225,6 +733,15 @@ func (x *ricochetCoreMonitorContactsClient) Recv() (*ContactEvent, error) {
 	return m, nil
 }
 
+func (c *ricochetCoreClient) GetContact(ctx context.Context, in *GetContactRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetContact", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *ricochetCoreClient) AddContactRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*Contact, error) {
 	out := new(Contact)
 	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/AddContactRequest", in, out, c.cc, opts...)
@@ -270,61 +787,275 @@ func (c *ricochetCoreClient) RejectInboundRequest(ctx context.Context, in *Conta
 	return out, nil
 }
 
-func (c *ricochetCoreClient) MonitorConversations(ctx context.Context, in *MonitorConversationsRequest, opts ...grpc.CallOption) (RicochetCore_MonitorConversationsClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_RicochetCore_serviceDesc.Streams[2], c.cc, "/ricochet.RicochetCore/MonitorConversations", opts...)
+func (c *ricochetCoreClient) CancelOutboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*CancelOutboundRequestReply, error) {
+	out := new(CancelOutboundRequestReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/CancelOutboundRequest", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &ricochetCoreMonitorConversationsClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
+	return out, nil
+}
+
+func (c *ricochetCoreClient) EditOutboundRequest(ctx context.Context, in *ContactRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/EditOutboundRequest", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetContactBlocked(ctx context.Context, in *SetContactBlockedRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactBlocked", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	return x, nil
+	return out, nil
 }
 
-type RicochetCore_MonitorConversationsClient interface {
-	Recv() (*ConversationEvent, error)
-	grpc.ClientStream
+func (c *ricochetCoreClient) SetContactNickname(ctx context.Context, in *SetContactNicknameRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactNickname", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type ricochetCoreMonitorConversationsClient struct {
-	grpc.ClientStream
+func (c *ricochetCoreClient) SetContactOrder(ctx context.Context, in *SetContactOrderRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactOrder", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (x *ricochetCoreMonitorConversationsClient) Recv() (*ConversationEvent, error) {
-	m := new(ConversationEvent)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func (c *ricochetCoreClient) SetContactConnectOnStartup(ctx context.Context, in *SetContactConnectOnStartupRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactConnectOnStartup", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
-func (c *ricochetCoreClient) SendMessage(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Message, error) {
-	out := new(Message)
-	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SendMessage", in, out, c.cc, opts...)
+func (c *ricochetCoreClient) SetContactMuted(ctx context.Context, in *SetContactMutedRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetContactMuted", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *ricochetCoreClient) MarkConversationRead(ctx context.Context, in *MarkConversationReadRequest, opts ...grpc.CallOption) (*Reply, error) {
-	out := new(Reply)
-	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/MarkConversationRead", in, out, c.cc, opts...)
+func (c *ricochetCoreClient) ConnectContact(ctx context.Context, in *ConnectContactRequest, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/ConnectContact", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for RicochetCore service
-
-type RicochetCoreServer interface {
-	// Query RPC server version and status
-	GetServerStatus(context.Context, *ServerStatusRequest) (*ServerStatusReply, error)
+func (c *ricochetCoreClient) GetContactDiagnostics(ctx context.Context, in *ContactDiagnosticsRequest, opts ...grpc.CallOption) (*ContactDiagnosticsReply, error) {
+	out := new(ContactDiagnosticsReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetContactDiagnostics", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) ExportContacts(ctx context.Context, in *ExportContactsRequest, opts ...grpc.CallOption) (*ContactListExport, error) {
+	out := new(ContactListExport)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/ExportContacts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) ImportContacts(ctx context.Context, in *ContactListExport, opts ...grpc.CallOption) (*ImportContactsReply, error) {
+	out := new(ImportContactsReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/ImportContacts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) MonitorConversations(ctx context.Context, in *MonitorConversationsRequest, opts ...grpc.CallOption) (RicochetCore_MonitorConversationsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_RicochetCore_serviceDesc.Streams[2], c.cc, "/ricochet.RicochetCore/MonitorConversations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ricochetCoreMonitorConversationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RicochetCore_MonitorConversationsClient interface {
+	Recv() (*ConversationEvent, error)
+	grpc.ClientStream
+}
+
+type ricochetCoreMonitorConversationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *ricochetCoreMonitorConversationsClient) Recv() (*ConversationEvent, error) {
+	m := new(ConversationEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *ricochetCoreClient) SendMessage(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SendMessage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) MarkConversationRead(ctx context.Context, in *MarkConversationReadRequest, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/MarkConversationRead", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetUnreadCount(ctx context.Context, in *UnreadCountRequest, opts ...grpc.CallOption) (*UnreadCountReply, error) {
+	out := new(UnreadCountReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetUnreadCount", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetQueuedMessages(ctx context.Context, in *QueuedMessagesRequest, opts ...grpc.CallOption) (*QueuedMessagesReply, error) {
+	out := new(QueuedMessagesReply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetQueuedMessages", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetConversationTyping(ctx context.Context, in *SetConversationTypingRequest, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetConversationTyping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) GetConversationDraft(ctx context.Context, in *ConversationDraftRequest, opts ...grpc.CallOption) (*ConversationDraft, error) {
+	out := new(ConversationDraft)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/GetConversationDraft", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) SetConversationDraft(ctx context.Context, in *ConversationDraft, opts ...grpc.CallOption) (*ConversationDraft, error) {
+	out := new(ConversationDraft)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SetConversationDraft", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) MonitorFileTransfers(ctx context.Context, in *MonitorFileTransfersRequest, opts ...grpc.CallOption) (RicochetCore_MonitorFileTransfersClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_RicochetCore_serviceDesc.Streams[3], c.cc, "/ricochet.RicochetCore/MonitorFileTransfers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ricochetCoreMonitorFileTransfersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RicochetCore_MonitorFileTransfersClient interface {
+	Recv() (*FileTransferEvent, error)
+	grpc.ClientStream
+}
+
+type ricochetCoreMonitorFileTransfersClient struct {
+	grpc.ClientStream
+}
+
+func (x *ricochetCoreMonitorFileTransfersClient) Recv() (*FileTransferEvent, error) {
+	m := new(FileTransferEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *ricochetCoreClient) SendFile(ctx context.Context, in *SendFileRequest, opts ...grpc.CallOption) (*FileTransfer, error) {
+	out := new(FileTransfer)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/SendFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) RespondFileTransfer(ctx context.Context, in *RespondFileTransferRequest, opts ...grpc.CallOption) (*FileTransfer, error) {
+	out := new(FileTransfer)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/RespondFileTransfer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ricochetCoreClient) CancelFileTransfer(ctx context.Context, in *CancelFileTransferRequest, opts ...grpc.CallOption) (*FileTransfer, error) {
+	out := new(FileTransfer)
+	err := grpc.Invoke(ctx, "/ricochet.RicochetCore/CancelFileTransfer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for RicochetCore service
+
+type RicochetCoreServer interface {
+	// Query RPC server version and status
+	GetServerStatus(context.Context, *ServerStatusRequest) (*ServerStatusReply, error)
+	// Report whether the backend is ready for use, e.g. for monitoring a
+	// service. This answers from state that's already known, and never waits
+	// for the network or contact connections.
+	GetHealth(context.Context, *HealthRequest) (*HealthReply, error)
+	// Query or change the lowest level of messages logged by the backend. The
+	// level isn't saved, and applies until the backend is restarted.
+	GetLogLevel(context.Context, *LogLevelRequest) (*LogLevel, error)
+	SetLogLevel(context.Context, *LogLevel) (*LogLevel, error)
+	// Query the most recent entries in the audit log, oldest first. Only
+	// entries recorded since the backend started are available.
+	GetAuditLog(context.Context, *AuditLogRequest) (*AuditLogReply, error)
 	// Open a stream to monitor changes to network status. The current
 	// NetworkStatus will be sent immediately, and the stream will receive a
 	// new NetworkStatus after any changes until the stream is closed.
@@ -340,284 +1071,960 @@ type RicochetCoreServer interface {
 	// has been taken offline, and returns the new network status.
 	StopNetwork(context.Context, *StopNetworkRequest) (*NetworkStatus, error)
 	GetIdentity(context.Context, *IdentityRequest) (*Identity, error)
+	// Export the identity's private key, encrypted with a passphrase, for
+	// backup or moving to another device. Import replaces the identity when
+	// the backend is restarted.
+	ExportIdentity(context.Context, *ExportIdentityRequest) (*ExportIdentityReply, error)
+	ImportIdentity(context.Context, *ImportIdentityRequest) (*ImportIdentityReply, error)
+	// Query or change how inbound contact requests are handled
+	GetContactRequestPolicy(context.Context, *ContactRequestPolicyRequest) (*ContactRequestPolicy, error)
+	SetContactRequestPolicy(context.Context, *ContactRequestPolicy) (*ContactRequestPolicy, error)
+	// Query or change whether connections are made to and accepted from
+	// contacts, such as to pause outbound connections or appear offline
+	GetConnectionMode(context.Context, *ConnectionModeRequest) (*ConnectionMode, error)
+	SetConnectionMode(context.Context, *ConnectionMode) (*ConnectionMode, error)
+	// Query or change our presence, which is sent to connected contacts
+	GetPresence(context.Context, *PresenceRequest) (*Presence, error)
+	SetPresence(context.Context, *Presence) (*Presence, error)
 	// Query contacts and monitor for contact changes. The full contact list
 	// is sent in POPULATE events, terminated by a POPULATE event with no
 	// subject. Any new, removed, or modified contacts, including changes in
 	// the state of contacts, are sent as ADD, UPDATE, or DELETE events until
 	// the stream is closed.
 	MonitorContacts(*MonitorContactsRequest, RicochetCore_MonitorContactsServer) error
+	// Get the current state of one contact, which is the same as in the
+	// latest event for it from MonitorContacts. The address is accepted in
+	// the same forms as for AddContactRequest; INVALID_ARGUMENT is returned
+	// if it isn't valid, and NOT_FOUND if it isn't a contact.
+	GetContact(context.Context, *GetContactRequest) (*Contact, error)
+	// The request's address may have any case, surrounding whitespace, and
+	// an optional 'ricochet:' prefix or '.onion' suffix. The contact is added
+	// with the canonical address, and INVALID_ARGUMENT is returned if it isn't
+	// valid.
 	AddContactRequest(context.Context, *ContactRequest) (*Contact, error)
 	UpdateContact(context.Context, *Contact) (*Contact, error)
 	DeleteContact(context.Context, *DeleteContactRequest) (*DeleteContactReply, error)
 	AcceptInboundRequest(context.Context, *ContactRequest) (*Contact, error)
 	RejectInboundRequest(context.Context, *ContactRequest) (*RejectInboundRequestReply, error)
+	// Withdraw an outbound contact request that hasn't been accepted, and
+	// remove the contact. Fails if the request has already been accepted.
+	CancelOutboundRequest(context.Context, *ContactRequest) (*CancelOutboundRequestReply, error)
+	// Change the fromNickname and text of an outbound contact request that
+	// hasn't been delivered yet, which are used for the next delivery
+	// attempt. FAILED_PRECONDITION is returned once it has been delivered.
+	EditOutboundRequest(context.Context, *ContactRequest) (*Contact, error)
+	// Block or unblock a contact. Blocked contacts can't connect or send
+	// contact requests, and no connections are made to them.
+	SetContactBlocked(context.Context, *SetContactBlockedRequest) (*Contact, error)
+	// Change the local nickname of a contact
+	SetContactNickname(context.Context, *SetContactNicknameRequest) (*Contact, error)
+	// Pin a contact, or change its position in the contact list. Contacts
+	// are sent by MonitorContacts in this order.
+	SetContactOrder(context.Context, *SetContactOrderRequest) (*Contact, error)
+	// Choose whether connections to a contact are made when the backend
+	// starts. Contacts that aren't are dormant until ConnectContact is used,
+	// a message is sent to them, or they connect to us.
+	SetContactConnectOnStartup(context.Context, *SetContactConnectOnStartupRequest) (*Contact, error)
+	// Mute or unmute notifications of messages from a contact
+	SetContactMuted(context.Context, *SetContactMutedRequest) (*Contact, error)
+	ConnectContact(context.Context, *ConnectContactRequest) (*Contact, error)
+	// Describe the connection state of contacts, for debugging
+	GetContactDiagnostics(context.Context, *ContactDiagnosticsRequest) (*ContactDiagnosticsReply, error)
+	// Export and import the list of established contacts. Imported contacts
+	// are added as known contacts, without sending a contact request.
+	ExportContacts(context.Context, *ExportContactsRequest) (*ContactListExport, error)
+	ImportContacts(context.Context, *ContactListExport) (*ImportContactsReply, error)
 	// Open a stream to monitor messages in conversations with contacts.
 	MonitorConversations(*MonitorConversationsRequest, RicochetCore_MonitorConversationsServer) error
+	// Send a message to recipient.address, which must be an accepted contact.
+	// Other fields identifying the recipient and sender can be omitted.
 	SendMessage(context.Context, *Message) (*Message, error)
 	MarkConversationRead(context.Context, *MarkConversationReadRequest) (*Reply, error)
+	// Get the number of unread messages in all conversations, such as for a
+	// badge. Changes to each contact's count are sent by MonitorContacts.
+	GetUnreadCount(context.Context, *UnreadCountRequest) (*UnreadCountReply, error)
+	// Get the number of messages queued to send to a contact while it is offline
+	GetQueuedMessages(context.Context, *QueuedMessagesRequest) (*QueuedMessagesReply, error)
+	// Tell the contact whether the user is typing a message. Clients may call
+	// this as often as they like while the user is typing; the backend limits
+	// how often the contact is notified, and stops the indicator if it isn't
+	// renewed.
+	SetConversationTyping(context.Context, *SetConversationTypingRequest) (*Reply, error)
+	// Save or get the unsent message for a conversation, so a client can
+	// restore it later, including after restarting. Drafts are stored by the
+	// backend only, and setting an empty text removes the draft.
+	GetConversationDraft(context.Context, *ConversationDraftRequest) (*ConversationDraft, error)
+	SetConversationDraft(context.Context, *ConversationDraft) (*ConversationDraft, error)
+	// Monitor file transfers with contacts. Existing transfers are sent in
+	// POPULATE events, terminated by a POPULATE event with no transfer, and
+	// new transfers and changes are sent as ADD and UPDATE events.
+	MonitorFileTransfers(*MonitorFileTransfersRequest, RicochetCore_MonitorFileTransfersServer) error
+	// Offer a file to a contact. The transfer starts once the contact is
+	// online and has accepted the offer.
+	SendFile(context.Context, *SendFileRequest) (*FileTransfer, error)
+	// Accept or reject an inbound file transfer offer
+	RespondFileTransfer(context.Context, *RespondFileTransferRequest) (*FileTransfer, error)
+	CancelFileTransfer(context.Context, *CancelFileTransferRequest) (*FileTransfer, error)
 }
 
 func RegisterRicochetCoreServer(s *grpc.Server, srv RicochetCoreServer) {
 	s.RegisterService(&_RicochetCore_serviceDesc, srv)
 }
 
-func _RicochetCore_GetServerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ServerStatusRequest)
+func _RicochetCore_GetServerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServerStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetServerStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetServerStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetServerStatus(ctx, req.(*ServerStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetHealth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetHealth(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetLogLevel(ctx, req.(*LogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetLogLevel(ctx, req.(*LogLevel))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetAuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetAuditLog(ctx, req.(*AuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_MonitorNetwork_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MonitorNetworkRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RicochetCoreServer).MonitorNetwork(m, &ricochetCoreMonitorNetworkServer{stream})
+}
+
+type RicochetCore_MonitorNetworkServer interface {
+	Send(*NetworkStatus) error
+	grpc.ServerStream
+}
+
+type ricochetCoreMonitorNetworkServer struct {
+	grpc.ServerStream
+}
+
+func (x *ricochetCoreMonitorNetworkServer) Send(m *NetworkStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RicochetCore_StartNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartNetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).StartNetwork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/StartNetwork",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).StartNetwork(ctx, req.(*StartNetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_StopNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopNetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).StopNetwork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/StopNetwork",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).StopNetwork(ctx, req.(*StopNetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdentityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetIdentity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetIdentity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetIdentity(ctx, req.(*IdentityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_ExportIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportIdentityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).ExportIdentity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/ExportIdentity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).ExportIdentity(ctx, req.(*ExportIdentityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_ImportIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportIdentityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).ImportIdentity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/ImportIdentity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).ImportIdentity(ctx, req.(*ImportIdentityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetContactRequestPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequestPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetContactRequestPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetContactRequestPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetContactRequestPolicy(ctx, req.(*ContactRequestPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetContactRequestPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequestPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetContactRequestPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetContactRequestPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetContactRequestPolicy(ctx, req.(*ContactRequestPolicy))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetConnectionMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectionModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetConnectionMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetConnectionMode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetConnectionMode(ctx, req.(*ConnectionModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetConnectionMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectionMode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetConnectionMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetConnectionMode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetConnectionMode(ctx, req.(*ConnectionMode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_GetPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetPresence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetPresence(ctx, req.(*PresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Presence)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetPresence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetPresence(ctx, req.(*Presence))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_MonitorContacts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MonitorContactsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RicochetCoreServer).MonitorContacts(m, &ricochetCoreMonitorContactsServer{stream})
+}
+
+type RicochetCore_MonitorContactsServer interface {
+	Send(*ContactEvent) error
+	grpc.ServerStream
+}
+
+type ricochetCoreMonitorContactsServer struct {
+	grpc.ServerStream
+}
+
+func (x *ricochetCoreMonitorContactsServer) Send(m *ContactEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RicochetCore_GetContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetContact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetContact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetContact(ctx, req.(*GetContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_AddContactRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).AddContactRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/AddContactRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).AddContactRequest(ctx, req.(*ContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_UpdateContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Contact)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).UpdateContact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/UpdateContact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).UpdateContact(ctx, req.(*Contact))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_DeleteContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).DeleteContact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/DeleteContact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).DeleteContact(ctx, req.(*DeleteContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_AcceptInboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).AcceptInboundRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/AcceptInboundRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).AcceptInboundRequest(ctx, req.(*ContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_RejectInboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).RejectInboundRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/RejectInboundRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).RejectInboundRequest(ctx, req.(*ContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_CancelOutboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).CancelOutboundRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/CancelOutboundRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).CancelOutboundRequest(ctx, req.(*ContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_EditOutboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).EditOutboundRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/EditOutboundRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).EditOutboundRequest(ctx, req.(*ContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetContactBlocked_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetContactBlockedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetContactBlocked(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetContactBlocked",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetContactBlocked(ctx, req.(*SetContactBlockedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetContactNickname_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetContactNicknameRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).GetServerStatus(ctx, in)
+		return srv.(RicochetCoreServer).SetContactNickname(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/GetServerStatus",
+		FullMethod: "/ricochet.RicochetCore/SetContactNickname",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).GetServerStatus(ctx, req.(*ServerStatusRequest))
+		return srv.(RicochetCoreServer).SetContactNickname(ctx, req.(*SetContactNicknameRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_MonitorNetwork_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(MonitorNetworkRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
+func _RicochetCore_SetContactOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetContactOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	return srv.(RicochetCoreServer).MonitorNetwork(m, &ricochetCoreMonitorNetworkServer{stream})
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetContactOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetContactOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetContactOrder(ctx, req.(*SetContactOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-type RicochetCore_MonitorNetworkServer interface {
-	Send(*NetworkStatus) error
-	grpc.ServerStream
+func _RicochetCore_SetContactConnectOnStartup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetContactConnectOnStartupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetContactConnectOnStartup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetContactConnectOnStartup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetContactConnectOnStartup(ctx, req.(*SetContactConnectOnStartupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-type ricochetCoreMonitorNetworkServer struct {
-	grpc.ServerStream
+func _RicochetCore_SetContactMuted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetContactMutedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetContactMuted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetContactMuted",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetContactMuted(ctx, req.(*SetContactMutedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *ricochetCoreMonitorNetworkServer) Send(m *NetworkStatus) error {
-	return x.ServerStream.SendMsg(m)
+func _RicochetCore_ConnectContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).ConnectContact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/ConnectContact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).ConnectContact(ctx, req.(*ConnectContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_StartNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(StartNetworkRequest)
+func _RicochetCore_GetContactDiagnostics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactDiagnosticsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).StartNetwork(ctx, in)
+		return srv.(RicochetCoreServer).GetContactDiagnostics(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/StartNetwork",
+		FullMethod: "/ricochet.RicochetCore/GetContactDiagnostics",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).StartNetwork(ctx, req.(*StartNetworkRequest))
+		return srv.(RicochetCoreServer).GetContactDiagnostics(ctx, req.(*ContactDiagnosticsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_StopNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(StopNetworkRequest)
+func _RicochetCore_ExportContacts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportContactsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).StopNetwork(ctx, in)
+		return srv.(RicochetCoreServer).ExportContacts(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/StopNetwork",
+		FullMethod: "/ricochet.RicochetCore/ExportContacts",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).StopNetwork(ctx, req.(*StopNetworkRequest))
+		return srv.(RicochetCoreServer).ExportContacts(ctx, req.(*ExportContactsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_GetIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(IdentityRequest)
+func _RicochetCore_ImportContacts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactListExport)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).GetIdentity(ctx, in)
+		return srv.(RicochetCoreServer).ImportContacts(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/GetIdentity",
+		FullMethod: "/ricochet.RicochetCore/ImportContacts",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).GetIdentity(ctx, req.(*IdentityRequest))
+		return srv.(RicochetCoreServer).ImportContacts(ctx, req.(*ContactListExport))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_MonitorContacts_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(MonitorContactsRequest)
+func _RicochetCore_MonitorConversations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MonitorConversationsRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(RicochetCoreServer).MonitorContacts(m, &ricochetCoreMonitorContactsServer{stream})
+	return srv.(RicochetCoreServer).MonitorConversations(m, &ricochetCoreMonitorConversationsServer{stream})
 }
 
-type RicochetCore_MonitorContactsServer interface {
-	Send(*ContactEvent) error
+type RicochetCore_MonitorConversationsServer interface {
+	Send(*ConversationEvent) error
 	grpc.ServerStream
 }
 
-type ricochetCoreMonitorContactsServer struct {
+type ricochetCoreMonitorConversationsServer struct {
 	grpc.ServerStream
 }
 
-func (x *ricochetCoreMonitorContactsServer) Send(m *ContactEvent) error {
+func (x *ricochetCoreMonitorConversationsServer) Send(m *ConversationEvent) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _RicochetCore_AddContactRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ContactRequest)
+func _RicochetCore_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Message)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).AddContactRequest(ctx, in)
+		return srv.(RicochetCoreServer).SendMessage(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/AddContactRequest",
+		FullMethod: "/ricochet.RicochetCore/SendMessage",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).AddContactRequest(ctx, req.(*ContactRequest))
+		return srv.(RicochetCoreServer).SendMessage(ctx, req.(*Message))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_UpdateContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Contact)
+func _RicochetCore_MarkConversationRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkConversationReadRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).UpdateContact(ctx, in)
+		return srv.(RicochetCoreServer).MarkConversationRead(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/UpdateContact",
+		FullMethod: "/ricochet.RicochetCore/MarkConversationRead",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).UpdateContact(ctx, req.(*Contact))
+		return srv.(RicochetCoreServer).MarkConversationRead(ctx, req.(*MarkConversationReadRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_DeleteContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteContactRequest)
+func _RicochetCore_GetUnreadCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnreadCountRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).DeleteContact(ctx, in)
+		return srv.(RicochetCoreServer).GetUnreadCount(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/DeleteContact",
+		FullMethod: "/ricochet.RicochetCore/GetUnreadCount",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).DeleteContact(ctx, req.(*DeleteContactRequest))
+		return srv.(RicochetCoreServer).GetUnreadCount(ctx, req.(*UnreadCountRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_AcceptInboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ContactRequest)
+func _RicochetCore_GetQueuedMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueuedMessagesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).AcceptInboundRequest(ctx, in)
+		return srv.(RicochetCoreServer).GetQueuedMessages(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/AcceptInboundRequest",
+		FullMethod: "/ricochet.RicochetCore/GetQueuedMessages",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).AcceptInboundRequest(ctx, req.(*ContactRequest))
+		return srv.(RicochetCoreServer).GetQueuedMessages(ctx, req.(*QueuedMessagesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_RejectInboundRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ContactRequest)
+func _RicochetCore_SetConversationTyping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetConversationTypingRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).RejectInboundRequest(ctx, in)
+		return srv.(RicochetCoreServer).SetConversationTyping(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/RejectInboundRequest",
+		FullMethod: "/ricochet.RicochetCore/SetConversationTyping",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).RejectInboundRequest(ctx, req.(*ContactRequest))
+		return srv.(RicochetCoreServer).SetConversationTyping(ctx, req.(*SetConversationTypingRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_MonitorConversations_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(MonitorConversationsRequest)
+func _RicochetCore_GetConversationDraft_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConversationDraftRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).GetConversationDraft(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/GetConversationDraft",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).GetConversationDraft(ctx, req.(*ConversationDraftRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_SetConversationDraft_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConversationDraft)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).SetConversationDraft(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/SetConversationDraft",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).SetConversationDraft(ctx, req.(*ConversationDraft))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_MonitorFileTransfers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MonitorFileTransfersRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(RicochetCoreServer).MonitorConversations(m, &ricochetCoreMonitorConversationsServer{stream})
+	return srv.(RicochetCoreServer).MonitorFileTransfers(m, &ricochetCoreMonitorFileTransfersServer{stream})
 }
 
-type RicochetCore_MonitorConversationsServer interface {
-	Send(*ConversationEvent) error
+type RicochetCore_MonitorFileTransfersServer interface {
+	Send(*FileTransferEvent) error
 	grpc.ServerStream
 }
 
-type ricochetCoreMonitorConversationsServer struct {
+type ricochetCoreMonitorFileTransfersServer struct {
 	grpc.ServerStream
 }
 
-func (x *ricochetCoreMonitorConversationsServer) Send(m *ConversationEvent) error {
+func (x *ricochetCoreMonitorFileTransfersServer) Send(m *FileTransferEvent) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _RicochetCore_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Message)
+func _RicochetCore_SendFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).SendMessage(ctx, in)
+		return srv.(RicochetCoreServer).SendFile(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/SendMessage",
+		FullMethod: "/ricochet.RicochetCore/SendFile",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).SendMessage(ctx, req.(*Message))
+		return srv.(RicochetCoreServer).SendFile(ctx, req.(*SendFileRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RicochetCore_MarkConversationRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MarkConversationReadRequest)
+func _RicochetCore_RespondFileTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RespondFileTransferRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RicochetCoreServer).MarkConversationRead(ctx, in)
+		return srv.(RicochetCoreServer).RespondFileTransfer(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/ricochet.RicochetCore/MarkConversationRead",
+		FullMethod: "/ricochet.RicochetCore/RespondFileTransfer",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RicochetCoreServer).MarkConversationRead(ctx, req.(*MarkConversationReadRequest))
+		return srv.(RicochetCoreServer).RespondFileTransfer(ctx, req.(*RespondFileTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RicochetCore_CancelFileTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelFileTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RicochetCoreServer).CancelFileTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ricochet.RicochetCore/CancelFileTransfer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RicochetCoreServer).CancelFileTransfer(ctx, req.(*CancelFileTransferRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -630,6 +2037,22 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetServerStatus",
 			Handler:    _RicochetCore_GetServerStatus_Handler,
 		},
+		{
+			MethodName: "GetHealth",
+			Handler:    _RicochetCore_GetHealth_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _RicochetCore_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _RicochetCore_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "GetAuditLog",
+			Handler:    _RicochetCore_GetAuditLog_Handler,
+		},
 		{
 			MethodName: "StartNetwork",
 			Handler:    _RicochetCore_StartNetwork_Handler,
@@ -642,6 +2065,42 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetIdentity",
 			Handler:    _RicochetCore_GetIdentity_Handler,
 		},
+		{
+			MethodName: "ExportIdentity",
+			Handler:    _RicochetCore_ExportIdentity_Handler,
+		},
+		{
+			MethodName: "ImportIdentity",
+			Handler:    _RicochetCore_ImportIdentity_Handler,
+		},
+		{
+			MethodName: "GetContactRequestPolicy",
+			Handler:    _RicochetCore_GetContactRequestPolicy_Handler,
+		},
+		{
+			MethodName: "SetContactRequestPolicy",
+			Handler:    _RicochetCore_SetContactRequestPolicy_Handler,
+		},
+		{
+			MethodName: "GetConnectionMode",
+			Handler:    _RicochetCore_GetConnectionMode_Handler,
+		},
+		{
+			MethodName: "SetConnectionMode",
+			Handler:    _RicochetCore_SetConnectionMode_Handler,
+		},
+		{
+			MethodName: "GetPresence",
+			Handler:    _RicochetCore_GetPresence_Handler,
+		},
+		{
+			MethodName: "SetPresence",
+			Handler:    _RicochetCore_SetPresence_Handler,
+		},
+		{
+			MethodName: "GetContact",
+			Handler:    _RicochetCore_GetContact_Handler,
+		},
 		{
 			MethodName: "AddContactRequest",
 			Handler:    _RicochetCore_AddContactRequest_Handler,
@@ -662,6 +2121,50 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RejectInboundRequest",
 			Handler:    _RicochetCore_RejectInboundRequest_Handler,
 		},
+		{
+			MethodName: "CancelOutboundRequest",
+			Handler:    _RicochetCore_CancelOutboundRequest_Handler,
+		},
+		{
+			MethodName: "EditOutboundRequest",
+			Handler:    _RicochetCore_EditOutboundRequest_Handler,
+		},
+		{
+			MethodName: "SetContactBlocked",
+			Handler:    _RicochetCore_SetContactBlocked_Handler,
+		},
+		{
+			MethodName: "SetContactNickname",
+			Handler:    _RicochetCore_SetContactNickname_Handler,
+		},
+		{
+			MethodName: "SetContactOrder",
+			Handler:    _RicochetCore_SetContactOrder_Handler,
+		},
+		{
+			MethodName: "SetContactConnectOnStartup",
+			Handler:    _RicochetCore_SetContactConnectOnStartup_Handler,
+		},
+		{
+			MethodName: "SetContactMuted",
+			Handler:    _RicochetCore_SetContactMuted_Handler,
+		},
+		{
+			MethodName: "ConnectContact",
+			Handler:    _RicochetCore_ConnectContact_Handler,
+		},
+		{
+			MethodName: "GetContactDiagnostics",
+			Handler:    _RicochetCore_GetContactDiagnostics_Handler,
+		},
+		{
+			MethodName: "ExportContacts",
+			Handler:    _RicochetCore_ExportContacts_Handler,
+		},
+		{
+			MethodName: "ImportContacts",
+			Handler:    _RicochetCore_ImportContacts_Handler,
+		},
 		{
 			MethodName: "SendMessage",
 			Handler:    _RicochetCore_SendMessage_Handler,
@@ -670,6 +2173,38 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "MarkConversationRead",
 			Handler:    _RicochetCore_MarkConversationRead_Handler,
 		},
+		{
+			MethodName: "GetUnreadCount",
+			Handler:    _RicochetCore_GetUnreadCount_Handler,
+		},
+		{
+			MethodName: "GetQueuedMessages",
+			Handler:    _RicochetCore_GetQueuedMessages_Handler,
+		},
+		{
+			MethodName: "SetConversationTyping",
+			Handler:    _RicochetCore_SetConversationTyping_Handler,
+		},
+		{
+			MethodName: "GetConversationDraft",
+			Handler:    _RicochetCore_GetConversationDraft_Handler,
+		},
+		{
+			MethodName: "SetConversationDraft",
+			Handler:    _RicochetCore_SetConversationDraft_Handler,
+		},
+		{
+			MethodName: "SendFile",
+			Handler:    _RicochetCore_SendFile_Handler,
+		},
+		{
+			MethodName: "RespondFileTransfer",
+			Handler:    _RicochetCore_RespondFileTransfer_Handler,
+		},
+		{
+			MethodName: "CancelFileTransfer",
+			Handler:    _RicochetCore_CancelFileTransfer_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -687,6 +2222,11 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 			Handler:       _RicochetCore_MonitorConversations_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "MonitorFileTransfers",
+			Handler:       _RicochetCore_MonitorFileTransfers_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "core.proto",
 }
@@ -694,34 +2234,103 @@ var _RicochetCore_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("core.proto", fileDescriptor2) }
 
 var fileDescriptor2 = []byte{
-	// 462 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x94, 0xd1, 0x6b, 0xd4, 0x40,
-	0x10, 0xc6, 0x89, 0x50, 0xad, 0x73, 0x4d, 0xcb, 0x8d, 0x87, 0xd6, 0xb3, 0xd6, 0xe3, 0x54, 0xe8,
-	0xd3, 0x51, 0x2c, 0x7d, 0xf3, 0xc1, 0x72, 0xd5, 0x52, 0x30, 0x7d, 0x48, 0xa8, 0x20, 0xf8, 0x92,
-	0x6e, 0x06, 0x8d, 0x3d, 0x76, 0xe3, 0xee, 0xdc, 0xc9, 0xfd, 0x11, 0xfe, 0xcf, 0x72, 0xcd, 0x6e,
-	0x77, 0x43, 0x72, 0x9c, 0xf8, 0x98, 0xef, 0xf7, 0xcd, 0x97, 0xd9, 0xd9, 0x49, 0x00, 0x84, 0xd2,
-	0x34, 0xa9, 0xb4, 0x62, 0x85, 0xdb, 0xba, 0x14, 0x4a, 0xfc, 0x20, 0x1e, 0xc6, 0x92, 0xf8, 0xb7,
-	0xd2, 0xb7, 0x35, 0x18, 0xee, 0x96, 0x05, 0x49, 0x2e, 0x79, 0x69, 0x9f, 0x63, 0xa1, 0x24, 0xe7,
-	0x82, 0xed, 0x23, 0x0a, 0x25, 0x17, 0xa4, 0x4d, 0xce, 0xa5, 0x92, 0xb5, 0x36, 0x7e, 0x04, 0x5b,
-	0x29, 0x55, 0xb3, 0xe5, 0xf8, 0x14, 0x9e, 0x64, 0xa4, 0x17, 0xa4, 0x33, 0xce, 0x79, 0x6e, 0x52,
-	0xfa, 0x35, 0x27, 0xc3, 0x78, 0x08, 0xa0, 0x2b, 0xf1, 0x85, 0xb4, 0x29, 0x95, 0xdc, 0x8f, 0x46,
-	0xd1, 0xd1, 0x56, 0x1a, 0x28, 0xe3, 0xaf, 0xd0, 0x6f, 0x96, 0x55, 0xb3, 0xe5, 0xa6, 0x22, 0x7c,
-	0x03, 0xb1, 0xb9, 0x2b, 0x72, 0x96, 0x07, 0xa3, 0xe8, 0xe8, 0x71, 0xda, 0x14, 0xdf, 0xfd, 0xd9,
-	0x86, 0x9d, 0xd4, 0x9e, 0x74, 0xaa, 0x34, 0x61, 0x02, 0x7b, 0x17, 0xc4, 0xe1, 0xeb, 0xf0, 0xe5,
-	0xc4, 0xcd, 0x62, 0xd2, 0xd1, 0xfd, 0xf0, 0xc5, 0x3a, 0xbc, 0xea, 0xf2, 0x33, 0xec, 0x26, 0x4a,
-	0x96, 0xac, 0xf4, 0x55, 0x3d, 0x45, 0x7c, 0xe5, 0xed, 0x4d, 0xe2, 0xf2, 0x9e, 0x79, 0x83, 0x25,
-	0x75, 0xe0, 0x71, 0x84, 0x9f, 0x60, 0x27, 0xe3, 0x5c, 0xb3, 0xcb, 0x0a, 0x3b, 0x0b, 0xf4, 0x4d,
-	0x49, 0x78, 0x0e, 0xbd, 0x8c, 0x55, 0xe5, 0x62, 0x0e, 0xc2, 0x98, 0x7b, 0x79, 0x63, 0xca, 0x7b,
-	0xe8, 0x5d, 0x10, 0x5f, 0xda, 0x75, 0xc0, 0xe7, 0xde, 0xe7, 0x34, 0x17, 0x81, 0x6d, 0xb4, 0x1a,
-	0xb4, 0x3d, 0xff, 0xb4, 0x5e, 0x20, 0x83, 0xa3, 0xd6, 0x68, 0x1c, 0x72, 0x41, 0x4f, 0xbd, 0xc3,
-	0xa2, 0x8f, 0x0b, 0x92, 0x7c, 0x1c, 0xe1, 0x07, 0xe8, 0x9f, 0x15, 0x85, 0x15, 0xdd, 0x62, 0xed,
-	0xb7, 0xec, 0x2e, 0xa8, 0xdf, 0x22, 0x78, 0x0a, 0xf1, 0x75, 0x55, 0xe4, 0x4c, 0x4e, 0x68, 0x7b,
-	0xba, 0xca, 0x12, 0x88, 0xcf, 0x69, 0x46, 0xbe, 0xec, 0xd0, 0x7b, 0x1a, 0xc0, 0xbd, 0xfa, 0x60,
-	0x2d, 0x5f, 0x2d, 0xcc, 0x14, 0x06, 0x67, 0x42, 0x50, 0xc5, 0x97, 0xf2, 0x46, 0xcd, 0x65, 0xf1,
-	0x5f, 0x47, 0xb9, 0x86, 0x41, 0x4a, 0x3f, 0x49, 0xfc, 0x7b, 0xc8, 0x6b, 0x4f, 0xba, 0x2a, 0xeb,
-	0xde, 0xbe, 0xc1, 0xc0, 0xdf, 0xcb, 0xfd, 0x47, 0x6e, 0xf0, 0x6d, 0xd7, 0xbd, 0x79, 0xde, 0xf1,
-	0xa1, 0x84, 0xdc, 0xdd, 0xe0, 0x09, 0xf4, 0x32, 0x92, 0x45, 0x42, 0xc6, 0xe4, 0xdf, 0x29, 0x9c,
-	0xbe, 0x95, 0x86, 0x6d, 0x09, 0xaf, 0x60, 0x90, 0xe4, 0xfa, 0x36, 0xcc, 0x4b, 0x29, 0x2f, 0x1a,
-	0x2d, 0x75, 0x70, 0xd7, 0xd2, 0x5e, 0x78, 0xec, 0x6a, 0xb6, 0xbc, 0x79, 0x78, 0xf7, 0xc7, 0x3a,
-	0xf9, 0x1b, 0x00, 0x00, 0xff, 0xff, 0xa5, 0xe8, 0x3e, 0x1e, 0x0b, 0x05, 0x00, 0x00,
+	// 1564 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x58, 0xdf, 0x52, 0xdb, 0xc6,
+	0x17, 0x8e, 0x01, 0x83, 0x39, 0x60, 0x5b, 0x2c, 0x26, 0x38, 0xce, 0x9f, 0x1f, 0x3f, 0x27, 0x4d,
+	0x99, 0xfe, 0x61, 0x32, 0x24, 0xb9, 0x6a, 0x3a, 0xad, 0xb1, 0x85, 0xe3, 0xd4, 0x96, 0x89, 0x64,
+	0xc2, 0x74, 0x26, 0x33, 0x54, 0x48, 0x1b, 0x50, 0x91, 0x77, 0x55, 0x69, 0x4d, 0xf1, 0x43, 0xf4,
+	0x25, 0xfa, 0x12, 0x7d, 0x98, 0x5e, 0xf4, 0x45, 0x7a, 0xd1, 0x59, 0x49, 0x6b, 0x49, 0x96, 0x6c,
+	0xd3, 0xde, 0xa1, 0xf3, 0x7d, 0xe7, 0xdb, 0xb3, 0x67, 0xcf, 0x9e, 0xb3, 0x18, 0xc0, 0xa0, 0x2e,
+	0x3e, 0x70, 0x5c, 0xca, 0x28, 0x2a, 0xb8, 0x96, 0x41, 0x8d, 0x2b, 0xcc, 0x6a, 0x45, 0x82, 0xd9,
+	0xaf, 0xd4, 0xbd, 0x0e, 0x80, 0x5a, 0xc9, 0x32, 0x31, 0x61, 0x16, 0x1b, 0x87, 0xdf, 0x45, 0x83,
+	0x12, 0xa6, 0x1b, 0x2c, 0xfc, 0x44, 0x06, 0x25, 0x37, 0xd8, 0xf5, 0x74, 0x66, 0x51, 0x22, 0x6c,
+	0x9f, 0x2c, 0x1b, 0x33, 0x57, 0x27, 0xde, 0x27, 0xec, 0x06, 0xb6, 0xfa, 0x1a, 0xe4, 0x55, 0xec,
+	0xd8, 0xe3, 0xfa, 0x6b, 0xd8, 0xd6, 0xb0, 0x7b, 0x83, 0x5d, 0x8d, 0xe9, 0x6c, 0xe4, 0xa9, 0xf8,
+	0x97, 0x11, 0xf6, 0x18, 0x7a, 0x02, 0xe0, 0x3a, 0xc6, 0x07, 0xec, 0x7a, 0x16, 0x25, 0xd5, 0xdc,
+	0x5e, 0x6e, 0x3f, 0xaf, 0xc6, 0x2c, 0xf5, 0xdf, 0x72, 0xb0, 0x95, 0xf4, 0x73, 0xec, 0xf1, 0x22,
+	0x2f, 0xf4, 0x0c, 0x8a, 0x9e, 0xef, 0x24, 0x28, 0x4b, 0x7b, 0xb9, 0xfd, 0x75, 0x35, 0x69, 0x44,
+	0x87, 0xb0, 0x6a, 0x5b, 0x43, 0x8b, 0x79, 0xd5, 0xe5, 0xbd, 0xdc, 0xfe, 0xc6, 0x61, 0xed, 0x40,
+	0x24, 0xe3, 0xa0, 0x61, 0x18, 0xd8, 0x61, 0x3a, 0x31, 0x70, 0xd7, 0x67, 0xa8, 0x21, 0xb3, 0x5e,
+	0x86, 0xe2, 0x5b, 0xac, 0xdb, 0xec, 0x2a, 0xdc, 0x40, 0xfd, 0x8f, 0x25, 0xd8, 0x10, 0x16, 0x1e,
+	0x5a, 0x6a, 0xe9, 0x5c, 0xd6, 0xd2, 0x15, 0xc8, 0xbb, 0x58, 0x37, 0xc7, 0x7e, 0x60, 0x05, 0x35,
+	0xf8, 0x40, 0xcf, 0x61, 0x92, 0xf5, 0x2e, 0xd5, 0x4d, 0x6c, 0xfa, 0x81, 0x15, 0xd4, 0x29, 0x2b,
+	0xaa, 0xc3, 0x26, 0xa3, 0xae, 0x8a, 0x75, 0xe3, 0x4a, 0xbf, 0xb0, 0x71, 0x75, 0xc5, 0x67, 0x25,
+	0x6c, 0x5c, 0x8b, 0x12, 0x8b, 0x92, 0x93, 0xd1, 0x85, 0x6d, 0x79, 0x57, 0xd8, 0xac, 0xe6, 0x03,
+	0xad, 0xa4, 0x15, 0x7d, 0x05, 0x5b, 0x06, 0x25, 0x04, 0x1b, 0x0c, 0x9b, 0xcd, 0xe0, 0x88, 0xbd,
+	0xea, 0xea, 0x5e, 0x6e, 0xbf, 0xa8, 0xa6, 0x01, 0xbe, 0x3b, 0x46, 0x99, 0x6e, 0x4f, 0x98, 0x6b,
+	0x3e, 0x33, 0x69, 0xe4, 0xac, 0x91, 0xc3, 0xac, 0x21, 0xd6, 0xb0, 0x41, 0x89, 0xe9, 0x55, 0x0b,
+	0x7b, 0xb9, 0xfd, 0x65, 0x35, 0x69, 0xac, 0x6f, 0x41, 0xb9, 0x4b, 0x2f, 0xbb, 0xf8, 0x06, 0xdb,
+	0x22, 0x99, 0x0e, 0x14, 0x84, 0x09, 0x1d, 0x40, 0xde, 0xe6, 0x7f, 0xf8, 0x09, 0x2c, 0x1d, 0x56,
+	0xa3, 0xc3, 0x11, 0x94, 0x83, 0xc0, 0x37, 0xa0, 0xd5, 0x5f, 0x41, 0x3e, 0x70, 0x5c, 0x87, 0x7c,
+	0x4b, 0x3e, 0x3a, 0x6d, 0x4b, 0xf7, 0x50, 0x01, 0x56, 0x3a, 0xca, 0x71, 0x5f, 0xca, 0xa1, 0x0d,
+	0x58, 0x3b, 0x6b, 0xa8, 0x4a, 0x47, 0x69, 0x4b, 0x4b, 0x9c, 0x21, 0xab, 0x6a, 0x5f, 0x95, 0x96,
+	0xeb, 0x9f, 0x43, 0xb9, 0x31, 0x32, 0x2d, 0xd6, 0xa5, 0x97, 0xa2, 0x24, 0x2b, 0x90, 0xf7, 0x0f,
+	0xdb, 0x5f, 0xb8, 0xa8, 0x06, 0x1f, 0xf5, 0xef, 0xa0, 0x18, 0x11, 0xf9, 0x41, 0x1f, 0xc0, 0x1a,
+	0x26, 0xcc, 0xb5, 0xb0, 0x57, 0xcd, 0xed, 0x2d, 0xef, 0x6f, 0x1c, 0x56, 0x62, 0xe5, 0xc3, 0x99,
+	0x32, 0x61, 0xee, 0x58, 0x15, 0xa4, 0xfa, 0x5f, 0x4b, 0x00, 0x91, 0x1d, 0x21, 0x58, 0xe1, 0xc9,
+	0x08, 0xcb, 0xc3, 0xff, 0x1b, 0x7d, 0x0d, 0x2b, 0x6c, 0xec, 0x60, 0xbf, 0x28, 0x4a, 0x87, 0x0f,
+	0xb2, 0xf4, 0x0e, 0x06, 0x63, 0x07, 0xab, 0x3e, 0x0d, 0x55, 0x61, 0x4d, 0x37, 0x4d, 0x17, 0x7b,
+	0x41, 0x01, 0xaf, 0xab, 0xe2, 0x13, 0xdd, 0x87, 0x55, 0x13, 0x33, 0xdd, 0xb2, 0xfd, 0xd2, 0x58,
+	0x57, 0xc3, 0xaf, 0xfa, 0x9f, 0x39, 0x58, 0xe1, 0x02, 0x3c, 0x1d, 0xa7, 0xca, 0x0f, 0x4a, 0xff,
+	0x4c, 0x91, 0xee, 0xa1, 0x2d, 0x28, 0x36, 0xfb, 0xca, 0xa0, 0xd1, 0x1c, 0x9c, 0x37, 0x5a, 0x2d,
+	0xb9, 0x25, 0xe5, 0xd0, 0x36, 0x94, 0x85, 0x49, 0x95, 0x7b, 0xfd, 0x0f, 0x72, 0x4b, 0x5a, 0x42,
+	0x12, 0x6c, 0xaa, 0xf2, 0xfb, 0x53, 0x59, 0x1b, 0x9c, 0x6b, 0xb2, 0x32, 0x90, 0x96, 0x51, 0x05,
+	0x24, 0x61, 0x51, 0xe5, 0xa6, 0xdc, 0xe1, 0xbc, 0x95, 0xb8, 0xb5, 0xd1, 0x6c, 0xca, 0x27, 0x03,
+	0xb9, 0x25, 0xe5, 0x93, 0xdc, 0x77, 0x72, 0x93, 0x5b, 0x57, 0x51, 0x0d, 0xee, 0x37, 0xfb, 0x8a,
+	0x22, 0x37, 0x07, 0x9d, 0xbe, 0x72, 0x2e, 0x6b, 0x83, 0xc6, 0x51, 0xb7, 0xa3, 0xbd, 0x95, 0x5b,
+	0xd2, 0x5a, 0x18, 0x84, 0xc0, 0xba, 0x7d, 0x6d, 0x20, 0x15, 0xd0, 0x03, 0xd8, 0x69, 0x9c, 0x0e,
+	0xde, 0xca, 0xca, 0xa0, 0xd3, 0x6c, 0xf8, 0xc0, 0x71, 0xa3, 0xd3, 0x95, 0x5b, 0xd2, 0x7a, 0xfd,
+	0xf7, 0x1c, 0x48, 0xd3, 0x17, 0x97, 0xd7, 0xf7, 0x50, 0xbf, 0x55, 0x2c, 0xe3, 0x9a, 0xe8, 0x43,
+	0xdc, 0xc5, 0xe4, 0x92, 0x5d, 0x85, 0x1d, 0x23, 0x0d, 0xa0, 0x2f, 0x40, 0x1a, 0xea, 0xb7, 0x3d,
+	0xec, 0x79, 0xfa, 0xa5, 0x20, 0x2f, 0xf9, 0xe4, 0x94, 0x1d, 0xbd, 0x82, 0x9d, 0xa1, 0x7e, 0xab,
+	0xe2, 0x9f, 0xb1, 0xc1, 0x54, 0xac, 0x7b, 0x94, 0x84, 0x0e, 0xcb, 0xbe, 0x43, 0x36, 0x78, 0xf8,
+	0xf7, 0x43, 0xd8, 0x54, 0xc3, 0x73, 0x6d, 0x52, 0x17, 0xa3, 0x1e, 0x94, 0xdb, 0x98, 0xc5, 0x7b,
+	0x1c, 0x7a, 0x1c, 0x9d, 0x7c, 0x46, 0xcf, 0xac, 0x3d, 0x9c, 0x05, 0xf3, 0xb2, 0xfc, 0x06, 0xd6,
+	0xdb, 0x98, 0x05, 0x1d, 0x09, 0xed, 0x46, 0xcc, 0x44, 0xd7, 0xaa, 0xed, 0xa4, 0x01, 0xee, 0xfc,
+	0x06, 0x36, 0xda, 0x98, 0x4d, 0xae, 0xe0, 0x83, 0xf4, 0x9d, 0x13, 0x02, 0x28, 0x0d, 0xa1, 0xd7,
+	0xb0, 0xa1, 0xc5, 0xbc, 0x33, 0x28, 0x99, 0x6e, 0x0d, 0x7f, 0x51, 0x71, 0xb9, 0xd0, 0x74, 0xd9,
+	0x47, 0x37, 0xb3, 0xb6, 0x9b, 0x05, 0xf1, 0xb8, 0xbb, 0x50, 0xea, 0x51, 0x62, 0x31, 0xea, 0x2a,
+	0xc1, 0x10, 0x43, 0xff, 0x8b, 0xa8, 0x49, 0x24, 0x43, 0x2b, 0x44, 0x82, 0x2c, 0xbe, 0xc8, 0xa1,
+	0x63, 0xd8, 0xd4, 0x98, 0xee, 0x32, 0xa1, 0x15, 0x3f, 0x8e, 0x98, 0x7d, 0x91, 0x12, 0x6a, 0xc1,
+	0x86, 0xc6, 0xa8, 0x23, 0x64, 0x1e, 0xc5, 0x65, 0xa8, 0x73, 0x57, 0x95, 0xe0, 0x4c, 0x3a, 0xe1,
+	0x04, 0x88, 0xa7, 0x47, 0xd8, 0x32, 0xce, 0x64, 0x42, 0x3f, 0x81, 0x92, 0x7c, 0xeb, 0x50, 0x37,
+	0x12, 0x88, 0x65, 0x26, 0x89, 0x08, 0x99, 0xc7, 0xb3, 0x09, 0x3c, 0xd7, 0x27, 0x50, 0xea, 0x0c,
+	0x67, 0x29, 0x76, 0x86, 0x0b, 0x14, 0x3b, 0xc3, 0xb4, 0xe2, 0x4f, 0xb0, 0xdb, 0xc6, 0x2c, 0x9c,
+	0x1e, 0xa1, 0xcf, 0x09, 0xb5, 0x2d, 0x63, 0x8c, 0x3e, 0x8b, 0x3c, 0xb3, 0x70, 0xb1, 0xc0, 0x93,
+	0xf9, 0x34, 0xf4, 0x23, 0xec, 0x6a, 0x33, 0x56, 0x58, 0xe0, 0xba, 0x50, 0x5a, 0x81, 0xad, 0x20,
+	0x78, 0x82, 0x0d, 0x66, 0x51, 0xd2, 0xa3, 0x26, 0x8e, 0x67, 0x24, 0x89, 0x88, 0x80, 0xab, 0xb3,
+	0x08, 0xa8, 0xcd, 0xdf, 0x3b, 0xd3, 0x7a, 0x33, 0xe9, 0x73, 0x84, 0x82, 0xba, 0x39, 0x71, 0xb1,
+	0x87, 0x89, 0x81, 0xe3, 0x75, 0x23, 0x6c, 0x19, 0x75, 0x33, 0xa1, 0x07, 0x77, 0x79, 0xf2, 0x99,
+	0x41, 0xc9, 0x74, 0xeb, 0x41, 0x39, 0xbc, 0x6e, 0x93, 0xc7, 0xc0, 0x5e, 0xea, 0x26, 0x0a, 0x48,
+	0xac, 0x7f, 0x3f, 0x95, 0x62, 0xf9, 0x06, 0x13, 0xf6, 0x22, 0x87, 0xde, 0x00, 0x44, 0x95, 0x81,
+	0x62, 0x7d, 0x2f, 0x55, 0x2f, 0xb5, 0xad, 0x94, 0x08, 0xfa, 0x1e, 0xb6, 0x1a, 0xa6, 0x99, 0xe4,
+	0x4d, 0xa5, 0x72, 0x81, 0xc2, 0x6b, 0x28, 0x9e, 0x3a, 0xa6, 0xce, 0xb0, 0x30, 0xa4, 0x39, 0x59,
+	0x6e, 0x3d, 0x28, 0xb6, 0xb0, 0x8d, 0x23, 0xb7, 0x58, 0x11, 0x25, 0x00, 0xb1, 0xf4, 0xa3, 0x99,
+	0x38, 0xbf, 0x1f, 0x4d, 0xa8, 0x04, 0x63, 0xad, 0x43, 0x2e, 0xe8, 0x88, 0x98, 0xff, 0x69, 0x2b,
+	0xa7, 0x50, 0x09, 0xa6, 0xd1, 0x9d, 0x45, 0x9e, 0x46, 0x48, 0x96, 0x67, 0x10, 0xdb, 0x19, 0xec,
+	0x34, 0xf9, 0xb4, 0xb5, 0xfb, 0x23, 0x76, 0x47, 0xdd, 0x67, 0x31, 0x24, 0xcb, 0x35, 0x10, 0x3e,
+	0x82, 0x6d, 0xd9, 0xb4, 0xd8, 0xdd, 0x65, 0x33, 0xf6, 0xfc, 0x4e, 0xdc, 0x25, 0xfe, 0x75, 0x64,
+	0x53, 0xe3, 0x9a, 0x3f, 0x9e, 0xe3, 0xd3, 0x73, 0x0a, 0x9c, 0xa3, 0xd5, 0x05, 0x14, 0xd1, 0xc5,
+	0xab, 0x01, 0x3d, 0xcd, 0x12, 0x13, 0xe8, 0x1c, 0xb5, 0x63, 0x28, 0x47, 0xfc, 0xbe, 0x6b, 0x62,
+	0x37, 0x7e, 0x4f, 0xa6, 0xa0, 0x39, 0x3a, 0x1f, 0xa1, 0x16, 0x91, 0xc3, 0x06, 0xd0, 0x27, 0xfe,
+	0x94, 0x1a, 0x39, 0xe8, 0xcb, 0x2c, 0xc9, 0x69, 0xd6, 0x5d, 0xa3, 0xec, 0x8d, 0x18, 0x36, 0xb3,
+	0xa3, 0xf4, 0xa1, 0x39, 0x3a, 0x2d, 0x28, 0x85, 0xab, 0x0a, 0x4b, 0xba, 0x41, 0x2e, 0x3e, 0xcd,
+	0x73, 0xd8, 0x89, 0xae, 0x7d, 0xcb, 0xd2, 0x2f, 0x09, 0xf5, 0x98, 0x65, 0x78, 0xf1, 0x43, 0x48,
+	0xa3, 0x42, 0xf0, 0xff, 0xf3, 0x49, 0xbc, 0xe4, 0x14, 0x31, 0x2b, 0x27, 0xbd, 0x2b, 0x35, 0x2b,
+	0xa7, 0x5b, 0xd7, 0xc3, 0x94, 0x6a, 0xd7, 0xf2, 0x58, 0xc0, 0xe5, 0xaf, 0x92, 0x60, 0xdc, 0x4d,
+	0xf4, 0xe6, 0xd1, 0xd3, 0x53, 0x32, 0x5a, 0x8c, 0x47, 0xf7, 0x11, 0x2a, 0x51, 0xff, 0x9c, 0xfc,
+	0xeb, 0xed, 0xc5, 0x47, 0x64, 0x16, 0x9e, 0x1d, 0xe9, 0x04, 0x17, 0x9d, 0xf6, 0x25, 0xef, 0xf7,
+	0xc4, 0x0c, 0x5f, 0xb8, 0xf1, 0x3e, 0x17, 0x9a, 0x6a, 0x69, 0x13, 0x52, 0xa0, 0xd2, 0xd3, 0xdd,
+	0xeb, 0xb8, 0x9e, 0x8a, 0x75, 0x33, 0x11, 0x52, 0x06, 0x2e, 0x42, 0x2a, 0xc7, 0x1b, 0x0c, 0xdf,
+	0xe2, 0x3b, 0x28, 0xb5, 0x31, 0x3b, 0x25, 0xfc, 0xbf, 0xe1, 0x26, 0x1d, 0x11, 0x16, 0x7f, 0x33,
+	0xc5, 0xcc, 0x42, 0xa0, 0x36, 0x03, 0xe5, 0x5a, 0x9a, 0x3f, 0x97, 0xdf, 0x8f, 0xf0, 0x08, 0x8b,
+	0x5d, 0x25, 0xce, 0x33, 0x89, 0x64, 0xbc, 0x54, 0xa6, 0x09, 0xc1, 0xdb, 0x67, 0x27, 0xa8, 0xfa,
+	0xc9, 0x7e, 0x06, 0x63, 0xc7, 0x22, 0x97, 0xe8, 0xf9, 0xf4, 0xb5, 0x98, 0x22, 0xcc, 0xdc, 0xf2,
+	0x19, 0x54, 0xda, 0x49, 0x87, 0x96, 0xab, 0x7f, 0x62, 0xf1, 0x2e, 0x95, 0x02, 0x17, 0x1c, 0x69,
+	0x20, 0x70, 0x02, 0x15, 0x2d, 0x4b, 0x78, 0x9e, 0xd3, 0x7c, 0xc5, 0xa8, 0x00, 0x8f, 0x2d, 0x1b,
+	0x0f, 0xc2, 0xdf, 0x79, 0xb2, 0x0a, 0x30, 0x81, 0x67, 0x44, 0x1b, 0xc7, 0x45, 0x01, 0x7e, 0x0b,
+	0x05, 0x5e, 0x80, 0x1c, 0x8a, 0xbf, 0x55, 0x84, 0x2d, 0xe3, 0xad, 0x10, 0x57, 0x41, 0x1a, 0x6c,
+	0xab, 0xd8, 0x73, 0x28, 0x31, 0x13, 0xe6, 0x67, 0xf1, 0x7c, 0xa7, 0xe0, 0x45, 0xa2, 0xef, 0x01,
+	0x05, 0x13, 0x2a, 0x61, 0x7d, 0x3a, 0x3d, 0xbf, 0xfe, 0x85, 0xe4, 0xc5, 0xaa, 0xff, 0xb3, 0xd8,
+	0xcb, 0x7f, 0x06, 0x00, 0xad, 0x41, 0x24, 0x84, 0x84, 0x13, 0x00, 0x00,
 }
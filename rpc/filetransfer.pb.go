@@ -0,0 +1,347 @@
+// Code generated by protoc-gen-go.
+// source: filetransfer.proto
+// DO NOT EDIT!
+
+package ricochet
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type FileTransfer_Direction int32
+
+const (
+	FileTransfer_INBOUND  FileTransfer_Direction = 0
+	FileTransfer_OUTBOUND FileTransfer_Direction = 1
+)
+
+var FileTransfer_Direction_name = map[int32]string{
+	0: "INBOUND",
+	1: "OUTBOUND",
+}
+var FileTransfer_Direction_value = map[string]int32{
+	"INBOUND":  0,
+	"OUTBOUND": 1,
+}
+
+func (x FileTransfer_Direction) String() string {
+	return proto.EnumName(FileTransfer_Direction_name, int32(x))
+}
+func (FileTransfer_Direction) EnumDescriptor() ([]byte, []int) { return fileDescriptor6, []int{0, 0} }
+
+type FileTransfer_Status int32
+
+const (
+	FileTransfer_NULL FileTransfer_Status = 0
+	// Waiting for the recipient to accept or reject the offer
+	FileTransfer_OFFERED      FileTransfer_Status = 1
+	FileTransfer_TRANSFERRING FileTransfer_Status = 2
+	// Connection was lost; the transfer resumes when the contact reconnects
+	FileTransfer_INTERRUPTED FileTransfer_Status = 3
+	FileTransfer_COMPLETE    FileTransfer_Status = 4
+	FileTransfer_REJECTED    FileTransfer_Status = 5
+	FileTransfer_CANCELLED   FileTransfer_Status = 6
+	// Transfer failed, e.g. because the received file didn't match the
+	// sender's hash. errorMessage has details.
+	FileTransfer_FAILED FileTransfer_Status = 7
+)
+
+var FileTransfer_Status_name = map[int32]string{
+	0: "NULL",
+	1: "OFFERED",
+	2: "TRANSFERRING",
+	3: "INTERRUPTED",
+	4: "COMPLETE",
+	5: "REJECTED",
+	6: "CANCELLED",
+	7: "FAILED",
+}
+var FileTransfer_Status_value = map[string]int32{
+	"NULL":         0,
+	"OFFERED":      1,
+	"TRANSFERRING": 2,
+	"INTERRUPTED":  3,
+	"COMPLETE":     4,
+	"REJECTED":     5,
+	"CANCELLED":    6,
+	"FAILED":       7,
+}
+
+func (x FileTransfer_Status) String() string {
+	return proto.EnumName(FileTransfer_Status_name, int32(x))
+}
+func (FileTransfer_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor6, []int{0, 1} }
+
+type FileTransferEvent_Type int32
+
+const (
+	FileTransferEvent_NULL     FileTransferEvent_Type = 0
+	FileTransferEvent_POPULATE FileTransferEvent_Type = 1
+	FileTransferEvent_ADD      FileTransferEvent_Type = 2
+	FileTransferEvent_UPDATE   FileTransferEvent_Type = 3
+)
+
+var FileTransferEvent_Type_name = map[int32]string{
+	0: "NULL",
+	1: "POPULATE",
+	2: "ADD",
+	3: "UPDATE",
+}
+var FileTransferEvent_Type_value = map[string]int32{
+	"NULL":     0,
+	"POPULATE": 1,
+	"ADD":      2,
+	"UPDATE":   3,
+}
+
+func (x FileTransferEvent_Type) String() string {
+	return proto.EnumName(FileTransferEvent_Type_name, int32(x))
+}
+func (FileTransferEvent_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor6, []int{1, 0} }
+
+type FileTransfer struct {
+	// Identifiers are assigned by the backend and are unique within a
+	// single session. They are not shared with the peer.
+	Identifier uint32                 `protobuf:"varint,1,opt,name=identifier" json:"identifier,omitempty"`
+	Address    string                 `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Direction  FileTransfer_Direction `protobuf:"varint,3,opt,name=direction,enum=ricochet.FileTransfer_Direction" json:"direction,omitempty"`
+	// Name of the file, as offered by the sender
+	Name        string              `protobuf:"bytes,4,opt,name=name" json:"name,omitempty"`
+	Size        uint64              `protobuf:"varint,5,opt,name=size" json:"size,omitempty"`
+	Transferred uint64              `protobuf:"varint,6,opt,name=transferred" json:"transferred,omitempty"`
+	Status      FileTransfer_Status `protobuf:"varint,7,opt,name=status,enum=ricochet.FileTransfer_Status" json:"status,omitempty"`
+	// Local path of the file; the source file for outbound transfers, and
+	// the destination for accepted inbound transfers.
+	Path         string `protobuf:"bytes,8,opt,name=path" json:"path,omitempty"`
+	ErrorMessage string `protobuf:"bytes,9,opt,name=errorMessage" json:"errorMessage,omitempty"`
+}
+
+func (m *FileTransfer) Reset()                    { *m = FileTransfer{} }
+func (m *FileTransfer) String() string            { return proto.CompactTextString(m) }
+func (*FileTransfer) ProtoMessage()               {}
+func (*FileTransfer) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{0} }
+
+func (m *FileTransfer) GetIdentifier() uint32 {
+	if m != nil {
+		return m.Identifier
+	}
+	return 0
+}
+
+func (m *FileTransfer) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *FileTransfer) GetDirection() FileTransfer_Direction {
+	if m != nil {
+		return m.Direction
+	}
+	return FileTransfer_INBOUND
+}
+
+func (m *FileTransfer) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *FileTransfer) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *FileTransfer) GetTransferred() uint64 {
+	if m != nil {
+		return m.Transferred
+	}
+	return 0
+}
+
+func (m *FileTransfer) GetStatus() FileTransfer_Status {
+	if m != nil {
+		return m.Status
+	}
+	return FileTransfer_NULL
+}
+
+func (m *FileTransfer) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *FileTransfer) GetErrorMessage() string {
+	if m != nil {
+		return m.ErrorMessage
+	}
+	return ""
+}
+
+type FileTransferEvent struct {
+	Type     FileTransferEvent_Type `protobuf:"varint,1,opt,name=type,enum=ricochet.FileTransferEvent_Type" json:"type,omitempty"`
+	Transfer *FileTransfer          `protobuf:"bytes,2,opt,name=transfer" json:"transfer,omitempty"`
+}
+
+func (m *FileTransferEvent) Reset()                    { *m = FileTransferEvent{} }
+func (m *FileTransferEvent) String() string            { return proto.CompactTextString(m) }
+func (*FileTransferEvent) ProtoMessage()               {}
+func (*FileTransferEvent) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{1} }
+
+func (m *FileTransferEvent) GetType() FileTransferEvent_Type {
+	if m != nil {
+		return m.Type
+	}
+	return FileTransferEvent_NULL
+}
+
+func (m *FileTransferEvent) GetTransfer() *FileTransfer {
+	if m != nil {
+		return m.Transfer
+	}
+	return nil
+}
+
+type MonitorFileTransfersRequest struct {
+}
+
+func (m *MonitorFileTransfersRequest) Reset()                    { *m = MonitorFileTransfersRequest{} }
+func (m *MonitorFileTransfersRequest) String() string            { return proto.CompactTextString(m) }
+func (*MonitorFileTransfersRequest) ProtoMessage()               {}
+func (*MonitorFileTransfersRequest) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{2} }
+
+type SendFileRequest struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	// Path of the file to send, which must be accessible to the backend
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *SendFileRequest) Reset()                    { *m = SendFileRequest{} }
+func (m *SendFileRequest) String() string            { return proto.CompactTextString(m) }
+func (*SendFileRequest) ProtoMessage()               {}
+func (*SendFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{3} }
+
+func (m *SendFileRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SendFileRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type RespondFileTransferRequest struct {
+	Identifier uint32 `protobuf:"varint,1,opt,name=identifier" json:"identifier,omitempty"`
+	Accept     bool   `protobuf:"varint,2,opt,name=accept" json:"accept,omitempty"`
+	// Destination path for an accepted file, which must not already exist
+	Path string `protobuf:"bytes,3,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *RespondFileTransferRequest) Reset()                    { *m = RespondFileTransferRequest{} }
+func (m *RespondFileTransferRequest) String() string            { return proto.CompactTextString(m) }
+func (*RespondFileTransferRequest) ProtoMessage()               {}
+func (*RespondFileTransferRequest) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{4} }
+
+func (m *RespondFileTransferRequest) GetIdentifier() uint32 {
+	if m != nil {
+		return m.Identifier
+	}
+	return 0
+}
+
+func (m *RespondFileTransferRequest) GetAccept() bool {
+	if m != nil {
+		return m.Accept
+	}
+	return false
+}
+
+func (m *RespondFileTransferRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type CancelFileTransferRequest struct {
+	Identifier uint32 `protobuf:"varint,1,opt,name=identifier" json:"identifier,omitempty"`
+}
+
+func (m *CancelFileTransferRequest) Reset()                    { *m = CancelFileTransferRequest{} }
+func (m *CancelFileTransferRequest) String() string            { return proto.CompactTextString(m) }
+func (*CancelFileTransferRequest) ProtoMessage()               {}
+func (*CancelFileTransferRequest) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{5} }
+
+func (m *CancelFileTransferRequest) GetIdentifier() uint32 {
+	if m != nil {
+		return m.Identifier
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*FileTransfer)(nil), "ricochet.FileTransfer")
+	proto.RegisterType((*FileTransferEvent)(nil), "ricochet.FileTransferEvent")
+	proto.RegisterType((*MonitorFileTransfersRequest)(nil), "ricochet.MonitorFileTransfersRequest")
+	proto.RegisterType((*SendFileRequest)(nil), "ricochet.SendFileRequest")
+	proto.RegisterType((*RespondFileTransferRequest)(nil), "ricochet.RespondFileTransferRequest")
+	proto.RegisterType((*CancelFileTransferRequest)(nil), "ricochet.CancelFileTransferRequest")
+	proto.RegisterEnum("ricochet.FileTransfer_Direction", FileTransfer_Direction_name, FileTransfer_Direction_value)
+	proto.RegisterEnum("ricochet.FileTransfer_Status", FileTransfer_Status_name, FileTransfer_Status_value)
+	proto.RegisterEnum("ricochet.FileTransferEvent_Type", FileTransferEvent_Type_name, FileTransferEvent_Type_value)
+}
+
+func init() { proto.RegisterFile("filetransfer.proto", fileDescriptor6) }
+
+var fileDescriptor6 = []byte{
+	// 516 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x53, 0xdd, 0x6e, 0x9b, 0x4c,
+	0x10, 0x0d, 0x86, 0x60, 0x3c, 0x76, 0xbe, 0xf0, 0xed, 0x45, 0x44, 0x5b, 0xa5, 0x42, 0x5c, 0x54,
+	0xbe, 0xf2, 0x45, 0xd2, 0x5e, 0x55, 0x6a, 0xe5, 0x9a, 0x75, 0xe5, 0x8a, 0x60, 0x6b, 0x0d, 0x0f,
+	0x40, 0x61, 0x5c, 0x23, 0xb9, 0x40, 0x77, 0x37, 0x95, 0xdc, 0xc7, 0xea, 0x33, 0xf5, 0x41, 0xaa,
+	0x5d, 0x1b, 0x87, 0x48, 0xfd, 0x91, 0x7a, 0x37, 0x73, 0xe6, 0x70, 0xce, 0x0c, 0x33, 0x0b, 0x64,
+	0x53, 0xee, 0x50, 0xf2, 0xac, 0x12, 0x1b, 0xe4, 0x93, 0x86, 0xd7, 0xb2, 0x26, 0x0e, 0x2f, 0xf3,
+	0x3a, 0xdf, 0xa2, 0x0c, 0x7e, 0x98, 0x30, 0x9a, 0x97, 0x3b, 0x4c, 0x8e, 0x04, 0xf2, 0x1c, 0xa0,
+	0x2c, 0xb0, 0x92, 0xe5, 0xa6, 0x44, 0xee, 0x19, 0xbe, 0x31, 0xbe, 0x60, 0x1d, 0x84, 0x78, 0xd0,
+	0xcf, 0x8a, 0x82, 0xa3, 0x10, 0x5e, 0xcf, 0x37, 0xc6, 0x03, 0xd6, 0xa6, 0xe4, 0x0d, 0x0c, 0x8a,
+	0x92, 0x63, 0x2e, 0xcb, 0xba, 0xf2, 0x4c, 0xdf, 0x18, 0xff, 0x77, 0xe3, 0x4f, 0x5a, 0xa3, 0x49,
+	0xd7, 0x64, 0x12, 0xb6, 0x3c, 0xf6, 0xf0, 0x09, 0x21, 0x60, 0x55, 0xd9, 0x67, 0xf4, 0x2c, 0x2d,
+	0xab, 0x63, 0x85, 0x89, 0xf2, 0x1b, 0x7a, 0xe7, 0xbe, 0x31, 0xb6, 0x98, 0x8e, 0x89, 0x0f, 0xc3,
+	0x76, 0x1c, 0x8e, 0x85, 0x67, 0xeb, 0x52, 0x17, 0x22, 0xaf, 0xc0, 0x16, 0x32, 0x93, 0xf7, 0xc2,
+	0xeb, 0xeb, 0x36, 0xae, 0x7f, 0xd3, 0xc6, 0x5a, 0x93, 0xd8, 0x91, 0xac, 0xcc, 0x9a, 0x4c, 0x6e,
+	0x3d, 0xe7, 0xd0, 0x80, 0x8a, 0x49, 0x00, 0x23, 0xe4, 0xbc, 0xe6, 0x77, 0x28, 0x44, 0xf6, 0x09,
+	0xbd, 0x81, 0xae, 0x3d, 0xc2, 0x82, 0x17, 0x30, 0x38, 0x0d, 0x44, 0x86, 0xd0, 0x5f, 0xc4, 0xef,
+	0x96, 0x69, 0x1c, 0xba, 0x67, 0x64, 0x04, 0xce, 0x32, 0x4d, 0x0e, 0x99, 0x11, 0xec, 0xc1, 0x3e,
+	0x38, 0x12, 0x07, 0xac, 0x38, 0x8d, 0x22, 0xf7, 0x4c, 0xd1, 0x97, 0xf3, 0x39, 0x65, 0x34, 0x74,
+	0x0d, 0xe2, 0xc2, 0x28, 0x61, 0xd3, 0x78, 0x3d, 0xa7, 0x8c, 0x2d, 0xe2, 0xf7, 0x6e, 0x8f, 0x5c,
+	0xc2, 0x70, 0x11, 0x27, 0x94, 0xb1, 0x74, 0x95, 0xd0, 0xd0, 0x35, 0x95, 0xe2, 0x6c, 0x79, 0xb7,
+	0x8a, 0x68, 0x42, 0x5d, 0x4b, 0x65, 0x8c, 0x7e, 0xa0, 0x33, 0x55, 0x3b, 0x27, 0x17, 0x30, 0x98,
+	0x4d, 0xe3, 0x19, 0x8d, 0x22, 0x1a, 0xba, 0x36, 0x01, 0xb0, 0xe7, 0xd3, 0x85, 0x8a, 0xfb, 0xc1,
+	0x77, 0x03, 0xfe, 0xef, 0x8e, 0x4e, 0xbf, 0x62, 0x25, 0xc9, 0x4b, 0xb0, 0xe4, 0xbe, 0x41, 0xcf,
+	0xf8, 0xd3, 0xb2, 0x34, 0x75, 0x92, 0xec, 0x1b, 0x64, 0x9a, 0x4d, 0x6e, 0xc0, 0x69, 0x7f, 0xb6,
+	0x3e, 0x81, 0xe1, 0xcd, 0xd5, 0xaf, 0xbf, 0x64, 0x27, 0x5e, 0x70, 0x0b, 0x96, 0x52, 0xe8, 0x0c,
+	0x3e, 0x02, 0x67, 0xb5, 0x5c, 0xa5, 0xd1, 0x34, 0xa1, 0xae, 0x41, 0xfa, 0x60, 0x4e, 0xc3, 0xd0,
+	0xed, 0xa9, 0xa6, 0xd3, 0x55, 0xa8, 0x40, 0x33, 0xb8, 0x86, 0x67, 0x77, 0x75, 0x55, 0xca, 0x9a,
+	0x77, 0x55, 0x05, 0xc3, 0x2f, 0xf7, 0x28, 0x64, 0xf0, 0x16, 0x2e, 0xd7, 0x58, 0x15, 0xaa, 0x76,
+	0x84, 0xba, 0xc7, 0x69, 0x3c, 0x3e, 0xce, 0x76, 0xb7, 0xbd, 0x87, 0xdd, 0x06, 0x5b, 0x78, 0xca,
+	0x50, 0x34, 0x75, 0x55, 0x74, 0xf5, 0x5b, 0xad, 0xbf, 0x3d, 0x84, 0x2b, 0xb0, 0xb3, 0x3c, 0xc7,
+	0x46, 0x6a, 0x4d, 0x87, 0x1d, 0xb3, 0x93, 0x93, 0xd9, 0x71, 0x7a, 0x0d, 0x4f, 0x66, 0x59, 0x95,
+	0xe3, 0xee, 0x1f, 0x8c, 0x3e, 0xda, 0xfa, 0xcd, 0xde, 0xfe, 0x1c, 0x00, 0x1f, 0xa7, 0xcf, 0xd2,
+	0xc9, 0x03, 0x00, 0x00,
+}
@@ -13,6 +13,63 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+type ContactRequestPolicy_Mode int32
+
+const (
+	// Requests are pending until accepted or rejected by the user
+	ContactRequestPolicy_MANUAL      ContactRequestPolicy_Mode = 0
+	ContactRequestPolicy_REJECT_ALL  ContactRequestPolicy_Mode = 1
+	ContactRequestPolicy_AUTO_ACCEPT ContactRequestPolicy_Mode = 2
+)
+
+var ContactRequestPolicy_Mode_name = map[int32]string{
+	0: "MANUAL",
+	1: "REJECT_ALL",
+	2: "AUTO_ACCEPT",
+}
+var ContactRequestPolicy_Mode_value = map[string]int32{
+	"MANUAL":      0,
+	"REJECT_ALL":  1,
+	"AUTO_ACCEPT": 2,
+}
+
+func (x ContactRequestPolicy_Mode) String() string {
+	return proto.EnumName(ContactRequestPolicy_Mode_name, int32(x))
+}
+func (ContactRequestPolicy_Mode) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor3, []int{2, 0}
+}
+
+type ConnectionMode_Mode int32
+
+const (
+	// Connections are made to contacts, and accepted from them
+	ConnectionMode_ONLINE ConnectionMode_Mode = 0
+	// No outbound connections are attempted, but established connections
+	// are kept, and contacts can still connect to us
+	ConnectionMode_NO_OUTBOUND ConnectionMode_Mode = 1
+	// All connections are closed, and inbound connections and contact
+	// requests are refused. Our onion service is still published, so this
+	// hides us from contacts, but not from someone watching the service.
+	ConnectionMode_INVISIBLE ConnectionMode_Mode = 2
+)
+
+var ConnectionMode_Mode_name = map[int32]string{
+	0: "ONLINE",
+	1: "NO_OUTBOUND",
+	2: "INVISIBLE",
+}
+var ConnectionMode_Mode_value = map[string]int32{
+	"ONLINE":      0,
+	"NO_OUTBOUND": 1,
+	"INVISIBLE":   2,
+}
+
+func (x ConnectionMode_Mode) String() string {
+	return proto.EnumName(ConnectionMode_Mode_name, int32(x))
+}
+func (ConnectionMode_Mode) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{4, 0} }
+
 type Identity struct {
 	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
 }
@@ -37,19 +94,222 @@ func (m *IdentityRequest) String() string            { return proto.CompactTextS
 func (*IdentityRequest) ProtoMessage()               {}
 func (*IdentityRequest) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{1} }
 
+// How new inbound contact requests are handled. Requests from existing
+// contacts, and replies to our own outbound requests, are always accepted.
+type ContactRequestPolicy struct {
+	Mode ContactRequestPolicy_Mode `protobuf:"varint,1,opt,name=mode,enum=ricochet.ContactRequestPolicy_Mode" json:"mode,omitempty"`
+	// If set with AUTO_ACCEPT, only requests with a message containing the
+	// token are accepted automatically; others are pending.
+	Token string `protobuf:"bytes,2,opt,name=token" json:"token,omitempty"`
+}
+
+func (m *ContactRequestPolicy) Reset()                    { *m = ContactRequestPolicy{} }
+func (m *ContactRequestPolicy) String() string            { return proto.CompactTextString(m) }
+func (*ContactRequestPolicy) ProtoMessage()               {}
+func (*ContactRequestPolicy) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{2} }
+
+func (m *ContactRequestPolicy) GetMode() ContactRequestPolicy_Mode {
+	if m != nil {
+		return m.Mode
+	}
+	return ContactRequestPolicy_MANUAL
+}
+
+func (m *ContactRequestPolicy) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type ContactRequestPolicyRequest struct {
+}
+
+func (m *ContactRequestPolicyRequest) Reset()                    { *m = ContactRequestPolicyRequest{} }
+func (m *ContactRequestPolicyRequest) String() string            { return proto.CompactTextString(m) }
+func (*ContactRequestPolicyRequest) ProtoMessage()               {}
+func (*ContactRequestPolicyRequest) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{3} }
+
+// Which connections to and from contacts are allowed. This applies to all
+// contacts, and is saved in the config.
+type ConnectionMode struct {
+	Mode ConnectionMode_Mode `protobuf:"varint,1,opt,name=mode,enum=ricochet.ConnectionMode_Mode" json:"mode,omitempty"`
+}
+
+func (m *ConnectionMode) Reset()                    { *m = ConnectionMode{} }
+func (m *ConnectionMode) String() string            { return proto.CompactTextString(m) }
+func (*ConnectionMode) ProtoMessage()               {}
+func (*ConnectionMode) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{4} }
+
+func (m *ConnectionMode) GetMode() ConnectionMode_Mode {
+	if m != nil {
+		return m.Mode
+	}
+	return ConnectionMode_ONLINE
+}
+
+type ConnectionModeRequest struct {
+}
+
+func (m *ConnectionModeRequest) Reset()                    { *m = ConnectionModeRequest{} }
+func (m *ConnectionModeRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConnectionModeRequest) ProtoMessage()               {}
+func (*ConnectionModeRequest) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{5} }
+
+// The exported identity, before it's encrypted
+type IdentityExport struct {
+	Address           string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	ServicePrivateKey []byte `protobuf:"bytes,2,opt,name=servicePrivateKey,proto3" json:"servicePrivateKey,omitempty"`
+}
+
+func (m *IdentityExport) Reset()                    { *m = IdentityExport{} }
+func (m *IdentityExport) String() string            { return proto.CompactTextString(m) }
+func (*IdentityExport) ProtoMessage()               {}
+func (*IdentityExport) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{6} }
+
+func (m *IdentityExport) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *IdentityExport) GetServicePrivateKey() []byte {
+	if m != nil {
+		return m.ServicePrivateKey
+	}
+	return nil
+}
+
+type ExportIdentityRequest struct {
+	Passphrase string `protobuf:"bytes,1,opt,name=passphrase" json:"passphrase,omitempty"`
+}
+
+func (m *ExportIdentityRequest) Reset()                    { *m = ExportIdentityRequest{} }
+func (m *ExportIdentityRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExportIdentityRequest) ProtoMessage()               {}
+func (*ExportIdentityRequest) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{7} }
+
+func (m *ExportIdentityRequest) GetPassphrase() string {
+	if m != nil {
+		return m.Passphrase
+	}
+	return ""
+}
+
+type ExportIdentityReply struct {
+	// Encrypted address and private key. Anyone able to decrypt this can
+	// use the identity, so it must be stored carefully.
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *ExportIdentityReply) Reset()                    { *m = ExportIdentityReply{} }
+func (m *ExportIdentityReply) String() string            { return proto.CompactTextString(m) }
+func (*ExportIdentityReply) ProtoMessage()               {}
+func (*ExportIdentityReply) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{8} }
+
+func (m *ExportIdentityReply) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type ImportIdentityRequest struct {
+	Data       []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Passphrase string `protobuf:"bytes,2,opt,name=passphrase" json:"passphrase,omitempty"`
+	// Replace the existing identity, which is lost unless it was exported
+	Force bool `protobuf:"varint,3,opt,name=force" json:"force,omitempty"`
+}
+
+func (m *ImportIdentityRequest) Reset()                    { *m = ImportIdentityRequest{} }
+func (m *ImportIdentityRequest) String() string            { return proto.CompactTextString(m) }
+func (*ImportIdentityRequest) ProtoMessage()               {}
+func (*ImportIdentityRequest) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{9} }
+
+func (m *ImportIdentityRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ImportIdentityRequest) GetPassphrase() string {
+	if m != nil {
+		return m.Passphrase
+	}
+	return ""
+}
+
+func (m *ImportIdentityRequest) GetForce() bool {
+	if m != nil {
+		return m.Force
+	}
+	return false
+}
+
+type ImportIdentityReply struct {
+	// Address of the imported identity, which is used after the backend is
+	// restarted
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *ImportIdentityReply) Reset()                    { *m = ImportIdentityReply{} }
+func (m *ImportIdentityReply) String() string            { return proto.CompactTextString(m) }
+func (*ImportIdentityReply) ProtoMessage()               {}
+func (*ImportIdentityReply) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{10} }
+
+func (m *ImportIdentityReply) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Identity)(nil), "ricochet.Identity")
 	proto.RegisterType((*IdentityRequest)(nil), "ricochet.IdentityRequest")
+	proto.RegisterType((*ContactRequestPolicy)(nil), "ricochet.ContactRequestPolicy")
+	proto.RegisterType((*ContactRequestPolicyRequest)(nil), "ricochet.ContactRequestPolicyRequest")
+	proto.RegisterType((*ConnectionMode)(nil), "ricochet.ConnectionMode")
+	proto.RegisterType((*ConnectionModeRequest)(nil), "ricochet.ConnectionModeRequest")
+	proto.RegisterType((*IdentityExport)(nil), "ricochet.IdentityExport")
+	proto.RegisterType((*ExportIdentityRequest)(nil), "ricochet.ExportIdentityRequest")
+	proto.RegisterType((*ExportIdentityReply)(nil), "ricochet.ExportIdentityReply")
+	proto.RegisterType((*ImportIdentityRequest)(nil), "ricochet.ImportIdentityRequest")
+	proto.RegisterType((*ImportIdentityReply)(nil), "ricochet.ImportIdentityReply")
+	proto.RegisterEnum("ricochet.ContactRequestPolicy_Mode", ContactRequestPolicy_Mode_name, ContactRequestPolicy_Mode_value)
+	proto.RegisterEnum("ricochet.ConnectionMode_Mode", ConnectionMode_Mode_name, ConnectionMode_Mode_value)
 }
 
 func init() { proto.RegisterFile("identity.proto", fileDescriptor3) }
 
 var fileDescriptor3 = []byte{
-	// 95 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0xcb, 0x4c, 0x49, 0xcd,
-	0x2b, 0xc9, 0x2c, 0xa9, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x28, 0xca, 0x4c, 0xce,
-	0x4f, 0xce, 0x48, 0x2d, 0x51, 0x52, 0xe1, 0xe2, 0xf0, 0x84, 0xca, 0x09, 0x49, 0x70, 0xb1, 0x27,
-	0xa6, 0xa4, 0x14, 0xa5, 0x16, 0x17, 0x4b, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0xc1, 0xb8, 0x4a,
-	0x82, 0x5c, 0xfc, 0x30, 0x55, 0x41, 0xa9, 0x85, 0xa5, 0xa9, 0xc5, 0x25, 0x49, 0x6c, 0x60, 0x93,
-	0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xcc, 0xc7, 0x44, 0x6d, 0x5b, 0x00, 0x00, 0x00,
+	// 401 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x92, 0xc1, 0x8f, 0x93, 0x40,
+	0x14, 0xc6, 0x17, 0xac, 0x6b, 0xf7, 0xb9, 0xb2, 0xec, 0x6c, 0x1b, 0x49, 0x4c, 0x4d, 0x33, 0x7a,
+	0xa8, 0x89, 0xc1, 0xd8, 0x1e, 0x7a, 0xa6, 0xc8, 0x01, 0xa5, 0xd0, 0x20, 0x18, 0x6f, 0xcd, 0x08,
+	0x63, 0x4a, 0x6c, 0x19, 0x1c, 0xc6, 0x2a, 0xff, 0x8a, 0x7f, 0xad, 0xe9, 0xb4, 0xc4, 0x96, 0xd2,
+	0xdb, 0xbc, 0x99, 0xef, 0x7d, 0xdf, 0x2f, 0x6f, 0x1e, 0x68, 0x59, 0x4a, 0x73, 0x91, 0x89, 0xca,
+	0x2c, 0x38, 0x13, 0x0c, 0x75, 0x79, 0x96, 0xb0, 0x64, 0x45, 0x05, 0x7e, 0x0d, 0x5d, 0xf7, 0xf0,
+	0x86, 0x0c, 0x78, 0x42, 0xd2, 0x94, 0xd3, 0xb2, 0x34, 0x94, 0xa1, 0x32, 0xba, 0x09, 0xeb, 0x12,
+	0xdf, 0xc3, 0x5d, 0xad, 0x0a, 0xe9, 0xcf, 0x5f, 0xb4, 0x14, 0xf8, 0xaf, 0x02, 0x3d, 0x9b, 0xe5,
+	0x82, 0x24, 0xe2, 0x70, 0xb5, 0x60, 0xeb, 0x2c, 0xa9, 0xd0, 0x14, 0x3a, 0x1b, 0x96, 0x52, 0x69,
+	0xa1, 0x8d, 0x5f, 0x99, 0x75, 0x94, 0xd9, 0xa6, 0x36, 0xe7, 0x2c, 0xa5, 0xa1, 0x6c, 0x40, 0x3d,
+	0x78, 0x2c, 0xd8, 0x0f, 0x9a, 0x1b, 0xaa, 0x0c, 0xdf, 0x17, 0x78, 0x02, 0x9d, 0x9d, 0x06, 0x01,
+	0x5c, 0xcf, 0x2d, 0x3f, 0xb6, 0x3c, 0xfd, 0x0a, 0x69, 0x00, 0xa1, 0xf3, 0xd1, 0xb1, 0xa3, 0xa5,
+	0xe5, 0x79, 0xba, 0x82, 0xee, 0xe0, 0xa9, 0x15, 0x47, 0xc1, 0xd2, 0xb2, 0x6d, 0x67, 0x11, 0xe9,
+	0x2a, 0x1e, 0xc0, 0x8b, 0xb6, 0xb4, 0x9a, 0xfd, 0x37, 0x68, 0x36, 0xcb, 0x73, 0x9a, 0x88, 0x8c,
+	0xe5, 0xd2, 0xfd, 0xfd, 0x09, 0xf4, 0xe0, 0x04, 0xfa, 0x48, 0x77, 0x84, 0x8b, 0xc7, 0xff, 0xc1,
+	0x02, 0xdf, 0x73, 0x7d, 0x47, 0xbf, 0xda, 0x81, 0xf8, 0xc1, 0x32, 0x88, 0xa3, 0x59, 0x10, 0xfb,
+	0x1f, 0x74, 0x05, 0x3d, 0x83, 0x1b, 0xd7, 0xff, 0xe2, 0x7e, 0x76, 0x67, 0x9e, 0xa3, 0xab, 0xf8,
+	0x39, 0xf4, 0x4f, 0x0d, 0x6b, 0xa2, 0xaf, 0xa0, 0xd5, 0x03, 0x76, 0xfe, 0x14, 0x8c, 0x8b, 0xcb,
+	0x9f, 0x81, 0xde, 0xc2, 0x7d, 0x49, 0xf9, 0x36, 0x4b, 0xe8, 0x82, 0x67, 0x5b, 0x22, 0xe8, 0x27,
+	0x5a, 0xc9, 0x99, 0xdd, 0x86, 0xe7, 0x0f, 0x78, 0x0a, 0xfd, 0xbd, 0x63, 0xe3, 0x03, 0xd1, 0x4b,
+	0x80, 0x82, 0x94, 0x65, 0xb1, 0xe2, 0xa4, 0xa4, 0x87, 0x8c, 0xa3, 0x1b, 0xfc, 0x06, 0x1e, 0x9a,
+	0x8d, 0xc5, 0xba, 0x42, 0x08, 0x3a, 0x29, 0x11, 0x44, 0x36, 0xdc, 0x86, 0xf2, 0x8c, 0x09, 0xf4,
+	0xdd, 0x4d, 0x5b, 0x46, 0x8b, 0xb8, 0x91, 0xab, 0x36, 0x73, 0x77, 0x6b, 0xf0, 0x9d, 0xf1, 0x84,
+	0x1a, 0x8f, 0x86, 0xca, 0xa8, 0x1b, 0xee, 0x0b, 0xfc, 0x0e, 0x1e, 0xdc, 0xcd, 0x39, 0xcd, 0xc5,
+	0x29, 0x7d, 0xbb, 0x96, 0x9b, 0x3e, 0xf9, 0x37, 0x00, 0xdc, 0x77, 0x2b, 0x4b, 0xfb, 0x02, 0x00,
+	0x00,
 }
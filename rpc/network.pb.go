@@ -96,6 +96,56 @@ func (TorConnectionStatus_Status) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor4, []int{3, 0}
 }
 
+type TorSocksStatus_Status int32
+
+const (
+	TorSocksStatus_UNKNOWN     TorSocksStatus_Status = 0
+	TorSocksStatus_AVAILABLE   TorSocksStatus_Status = 1
+	TorSocksStatus_UNAVAILABLE TorSocksStatus_Status = 2
+)
+
+var TorSocksStatus_Status_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "AVAILABLE",
+	2: "UNAVAILABLE",
+}
+var TorSocksStatus_Status_value = map[string]int32{
+	"UNKNOWN":     0,
+	"AVAILABLE":   1,
+	"UNAVAILABLE": 2,
+}
+
+func (x TorSocksStatus_Status) String() string {
+	return proto.EnumName(TorSocksStatus_Status_name, int32(x))
+}
+func (TorSocksStatus_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor4, []int{4, 0} }
+
+type OnionServiceStatus_Status int32
+
+const (
+	OnionServiceStatus_UNKNOWN     OnionServiceStatus_Status = 0
+	OnionServiceStatus_REACHABLE   OnionServiceStatus_Status = 1
+	OnionServiceStatus_UNREACHABLE OnionServiceStatus_Status = 2
+)
+
+var OnionServiceStatus_Status_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "REACHABLE",
+	2: "UNREACHABLE",
+}
+var OnionServiceStatus_Status_value = map[string]int32{
+	"UNKNOWN":     0,
+	"REACHABLE":   1,
+	"UNREACHABLE": 2,
+}
+
+func (x OnionServiceStatus_Status) String() string {
+	return proto.EnumName(OnionServiceStatus_Status_name, int32(x))
+}
+func (OnionServiceStatus_Status) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor4, []int{5, 0}
+}
+
 type MonitorNetworkRequest struct {
 }
 
@@ -192,16 +242,81 @@ func (m *TorConnectionStatus) GetSocksAddress() []string {
 	return nil
 }
 
+// Whether connections through the tor SOCKS port are possible, as seen by
+// attempts to connect to contacts. UNAVAILABLE means tor itself can't be
+// reached, as opposed to contacts being offline.
+type TorSocksStatus struct {
+	Status       TorSocksStatus_Status `protobuf:"varint,1,opt,name=status,enum=ricochet.TorSocksStatus_Status" json:"status,omitempty"`
+	ErrorMessage string                `protobuf:"bytes,2,opt,name=errorMessage" json:"errorMessage,omitempty"`
+}
+
+func (m *TorSocksStatus) Reset()                    { *m = TorSocksStatus{} }
+func (m *TorSocksStatus) String() string            { return proto.CompactTextString(m) }
+func (*TorSocksStatus) ProtoMessage()               {}
+func (*TorSocksStatus) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{4} }
+
+func (m *TorSocksStatus) GetStatus() TorSocksStatus_Status {
+	if m != nil {
+		return m.Status
+	}
+	return TorSocksStatus_UNKNOWN
+}
+
+func (m *TorSocksStatus) GetErrorMessage() string {
+	if m != nil {
+		return m.ErrorMessage
+	}
+	return ""
+}
+
+// Whether our own onion service could be reached through tor by the last
+// self-check. UNREACHABLE means contacts are probably unable to connect to us,
+// even though outbound connections may work.
+type OnionServiceStatus struct {
+	Status       OnionServiceStatus_Status `protobuf:"varint,1,opt,name=status,enum=ricochet.OnionServiceStatus_Status" json:"status,omitempty"`
+	ErrorMessage string                    `protobuf:"bytes,2,opt,name=errorMessage" json:"errorMessage,omitempty"`
+	// Unix timestamp of the last self-check
+	LastChecked int64 `protobuf:"varint,3,opt,name=lastChecked" json:"lastChecked,omitempty"`
+}
+
+func (m *OnionServiceStatus) Reset()                    { *m = OnionServiceStatus{} }
+func (m *OnionServiceStatus) String() string            { return proto.CompactTextString(m) }
+func (*OnionServiceStatus) ProtoMessage()               {}
+func (*OnionServiceStatus) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{5} }
+
+func (m *OnionServiceStatus) GetStatus() OnionServiceStatus_Status {
+	if m != nil {
+		return m.Status
+	}
+	return OnionServiceStatus_UNKNOWN
+}
+
+func (m *OnionServiceStatus) GetErrorMessage() string {
+	if m != nil {
+		return m.ErrorMessage
+	}
+	return ""
+}
+
+func (m *OnionServiceStatus) GetLastChecked() int64 {
+	if m != nil {
+		return m.LastChecked
+	}
+	return 0
+}
+
 type NetworkStatus struct {
 	Process    *TorProcessStatus    `protobuf:"bytes,1,opt,name=process" json:"process,omitempty"`
 	Control    *TorControlStatus    `protobuf:"bytes,2,opt,name=control" json:"control,omitempty"`
 	Connection *TorConnectionStatus `protobuf:"bytes,3,opt,name=connection" json:"connection,omitempty"`
+	Socks      *TorSocksStatus      `protobuf:"bytes,4,opt,name=socks" json:"socks,omitempty"`
+	Service    *OnionServiceStatus  `protobuf:"bytes,5,opt,name=service" json:"service,omitempty"`
 }
 
 func (m *NetworkStatus) Reset()                    { *m = NetworkStatus{} }
 func (m *NetworkStatus) String() string            { return proto.CompactTextString(m) }
 func (*NetworkStatus) ProtoMessage()               {}
-func (*NetworkStatus) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{4} }
+func (*NetworkStatus) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{6} }
 
 func (m *NetworkStatus) GetProcess() *TorProcessStatus {
 	if m != nil {
@@ -224,13 +339,27 @@ func (m *NetworkStatus) GetConnection() *TorConnectionStatus {
 	return nil
 }
 
+func (m *NetworkStatus) GetSocks() *TorSocksStatus {
+	if m != nil {
+		return m.Socks
+	}
+	return nil
+}
+
+func (m *NetworkStatus) GetService() *OnionServiceStatus {
+	if m != nil {
+		return m.Service
+	}
+	return nil
+}
+
 type StartNetworkRequest struct {
 }
 
 func (m *StartNetworkRequest) Reset()                    { *m = StartNetworkRequest{} }
 func (m *StartNetworkRequest) String() string            { return proto.CompactTextString(m) }
 func (*StartNetworkRequest) ProtoMessage()               {}
-func (*StartNetworkRequest) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{5} }
+func (*StartNetworkRequest) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{7} }
 
 type StopNetworkRequest struct {
 }
@@ -238,52 +367,64 @@ type StopNetworkRequest struct {
 func (m *StopNetworkRequest) Reset()                    { *m = StopNetworkRequest{} }
 func (m *StopNetworkRequest) String() string            { return proto.CompactTextString(m) }
 func (*StopNetworkRequest) ProtoMessage()               {}
-func (*StopNetworkRequest) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{6} }
+func (*StopNetworkRequest) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{8} }
 
 func init() {
 	proto.RegisterType((*MonitorNetworkRequest)(nil), "ricochet.MonitorNetworkRequest")
 	proto.RegisterType((*TorProcessStatus)(nil), "ricochet.TorProcessStatus")
 	proto.RegisterType((*TorControlStatus)(nil), "ricochet.TorControlStatus")
 	proto.RegisterType((*TorConnectionStatus)(nil), "ricochet.TorConnectionStatus")
+	proto.RegisterType((*TorSocksStatus)(nil), "ricochet.TorSocksStatus")
+	proto.RegisterType((*OnionServiceStatus)(nil), "ricochet.OnionServiceStatus")
 	proto.RegisterType((*NetworkStatus)(nil), "ricochet.NetworkStatus")
 	proto.RegisterType((*StartNetworkRequest)(nil), "ricochet.StartNetworkRequest")
 	proto.RegisterType((*StopNetworkRequest)(nil), "ricochet.StopNetworkRequest")
 	proto.RegisterEnum("ricochet.TorProcessStatus_Status", TorProcessStatus_Status_name, TorProcessStatus_Status_value)
 	proto.RegisterEnum("ricochet.TorControlStatus_Status", TorControlStatus_Status_name, TorControlStatus_Status_value)
 	proto.RegisterEnum("ricochet.TorConnectionStatus_Status", TorConnectionStatus_Status_name, TorConnectionStatus_Status_value)
+	proto.RegisterEnum("ricochet.TorSocksStatus_Status", TorSocksStatus_Status_name, TorSocksStatus_Status_value)
+	proto.RegisterEnum("ricochet.OnionServiceStatus_Status", OnionServiceStatus_Status_name, OnionServiceStatus_Status_value)
 }
 
 func init() { proto.RegisterFile("network.proto", fileDescriptor4) }
 
 var fileDescriptor4 = []byte{
-	// 455 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x53, 0xcd, 0x6e, 0xd3, 0x40,
-	0x10, 0xee, 0xd6, 0x6a, 0xda, 0x4c, 0x9a, 0xca, 0xdd, 0x52, 0x11, 0x21, 0x81, 0xc2, 0x8a, 0x43,
-	0x0f, 0x28, 0x87, 0xc2, 0x05, 0x89, 0x3f, 0x37, 0x76, 0x51, 0x44, 0xbb, 0xb6, 0xd6, 0x2e, 0x88,
-	0x63, 0xea, 0xae, 0x4a, 0x54, 0xe4, 0x09, 0xb3, 0x5b, 0xf1, 0x5c, 0x5c, 0x79, 0x0c, 0x5e, 0x82,
-	0xd7, 0x40, 0xb6, 0x37, 0x91, 0x6d, 0x04, 0x42, 0x3d, 0xd9, 0x3b, 0xf3, 0x7d, 0xb3, 0xdf, 0x37,
-	0x33, 0x0b, 0xc3, 0x42, 0xdb, 0x6f, 0x48, 0x37, 0x93, 0x25, 0xa1, 0x45, 0xbe, 0x43, 0x8b, 0x1c,
-	0xf3, 0xcf, 0xda, 0x8a, 0xfb, 0x70, 0x78, 0x8e, 0xc5, 0xc2, 0x22, 0xc9, 0x1a, 0xa1, 0xf4, 0xd7,
-	0x5b, 0x6d, 0xac, 0xf8, 0xce, 0xc0, 0xcf, 0x90, 0x12, 0xc2, 0x5c, 0x1b, 0x93, 0xda, 0xb9, 0xbd,
-	0x35, 0xfc, 0x05, 0xf4, 0x4c, 0xf5, 0x37, 0x62, 0x63, 0x76, 0xb4, 0x77, 0xfc, 0x78, 0xb2, 0x2a,
-	0x34, 0xe9, 0x62, 0x27, 0xf5, 0x47, 0x39, 0x02, 0x17, 0xb0, 0xab, 0x89, 0x90, 0xce, 0xb5, 0x31,
-	0xf3, 0x6b, 0x3d, 0xda, 0x1c, 0xb3, 0xa3, 0xbe, 0x6a, 0xc5, 0xc4, 0x6b, 0xe8, 0xb9, 0x8b, 0x76,
-	0x61, 0x27, 0x9c, 0xa5, 0xc1, 0xc9, 0x59, 0x14, 0xfa, 0x1b, 0x7c, 0x00, 0xdb, 0x69, 0x16, 0x27,
-	0x49, 0x14, 0xfa, 0xac, 0x4c, 0xa5, 0x59, 0xa0, 0xb2, 0x99, 0x7c, 0xe7, 0x6f, 0x96, 0x29, 0x75,
-	0x21, 0x65, 0x79, 0xf0, 0xc4, 0xcf, 0x5a, 0xf3, 0x14, 0x0b, 0x4b, 0xf8, 0xe5, 0xbf, 0x34, 0xb7,
-	0xb0, 0x77, 0xd0, 0xcc, 0x1f, 0x01, 0x58, 0xa4, 0x0f, 0x9a, 0xcc, 0x02, 0x8b, 0x11, 0x54, 0x88,
-	0x46, 0x44, 0xbc, 0x59, 0x7b, 0x6a, 0xb8, 0xd8, 0xe0, 0x7d, 0xd8, 0x8a, 0x94, 0x8a, 0x95, 0xcf,
-	0xf8, 0x1e, 0xc0, 0x34, 0x96, 0x32, 0x9a, 0x3a, 0x4b, 0x43, 0xe8, 0xbb, 0x73, 0x14, 0xfa, 0x9e,
-	0xf8, 0xc5, 0xe0, 0xa0, 0x16, 0x5a, 0xe8, 0xdc, 0x2e, 0xb0, 0x70, 0xe5, 0x5e, 0x76, 0x7c, 0x3d,
-	0xe9, 0xfa, 0x6a, 0xc1, 0xbb, 0xd6, 0x9e, 0xc2, 0xfe, 0x25, 0xa2, 0x35, 0x96, 0xe6, 0xcb, 0x84,
-	0xf0, 0x9a, 0xb4, 0x31, 0x4e, 0xfd, 0x9f, 0x89, 0xb2, 0x11, 0x06, 0xf3, 0x1b, 0x13, 0x5c, 0x5d,
-	0x55, 0xc0, 0xc1, 0xd8, 0x2b, 0x1b, 0xd1, 0x8c, 0x89, 0xb7, 0x4d, 0xa3, 0x17, 0xf2, 0xbd, 0x8c,
-	0x3f, 0xca, 0x7a, 0x76, 0xf1, 0xe9, 0xe9, 0xd9, 0x4c, 0x46, 0x3e, 0xe3, 0xfb, 0x30, 0x3c, 0x89,
-	0xe3, 0x2c, 0xcd, 0x54, 0x90, 0x24, 0xb5, 0xdb, 0x3e, 0x6c, 0xa9, 0x28, 0x08, 0x3f, 0xf9, 0x9e,
-	0xf8, 0xc1, 0x60, 0xe8, 0xb6, 0xd0, 0x55, 0x7a, 0x0e, 0xdb, 0xcb, 0x7a, 0xa9, 0x2a, 0x93, 0x83,
-	0xe3, 0x07, 0x7f, 0x5f, 0x38, 0xb5, 0x82, 0x96, 0xac, 0xbc, 0x1e, 0x6b, 0x35, 0xb1, 0x2e, 0xab,
-	0x35, 0x72, 0xb5, 0x82, 0xf2, 0x57, 0x00, 0xf9, 0xba, 0x69, 0x23, 0xaf, 0x22, 0x3e, 0xfc, 0x67,
-	0x4f, 0x55, 0x83, 0x20, 0x0e, 0xe1, 0x20, 0xb5, 0x73, 0xb2, 0x9d, 0x67, 0x74, 0x0f, 0x78, 0x6a,
-	0x71, 0xd9, 0x8e, 0x5e, 0xf6, 0xaa, 0x67, 0xf8, 0xec, 0x77, 0x00, 0x00, 0x00, 0xff, 0xff, 0x78,
-	0x89, 0xf8, 0x34, 0x97, 0x03, 0x00, 0x00,
+	// 586 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x94, 0xcb, 0x6e, 0xd3, 0x4c,
+	0x14, 0xc7, 0x3b, 0xf6, 0x97, 0xb6, 0x39, 0x6e, 0xfa, 0xb9, 0x53, 0x2a, 0x2c, 0xc4, 0x25, 0x0c,
+	0x2c, 0xb2, 0x40, 0x59, 0x84, 0x9b, 0x10, 0x57, 0x37, 0x71, 0x21, 0x22, 0xb5, 0xad, 0xb1, 0x53,
+	0xc4, 0x32, 0x75, 0x46, 0x6d, 0x94, 0xca, 0x13, 0x66, 0xa6, 0xf0, 0x3e, 0x88, 0x17, 0xe0, 0x45,
+	0x58, 0xf0, 0x12, 0xbc, 0x06, 0xf2, 0x25, 0x8d, 0xed, 0xb6, 0x11, 0xca, 0x2a, 0x99, 0x33, 0xff,
+	0x73, 0xfc, 0xff, 0xcd, 0x9c, 0x33, 0xd0, 0x88, 0x99, 0xfa, 0xc6, 0xc5, 0xb4, 0x3d, 0x13, 0x5c,
+	0x71, 0xbc, 0x29, 0x26, 0x11, 0x8f, 0x4e, 0x99, 0x22, 0x37, 0x61, 0xef, 0x90, 0xc7, 0x13, 0xc5,
+	0x85, 0x9b, 0x29, 0x28, 0xfb, 0x72, 0xce, 0xa4, 0x22, 0x3f, 0x11, 0x98, 0x21, 0x17, 0xbe, 0xe0,
+	0x11, 0x93, 0x32, 0x50, 0x23, 0x75, 0x2e, 0xf1, 0x0b, 0x58, 0x97, 0xe9, 0x3f, 0x0b, 0x35, 0x51,
+	0x6b, 0xbb, 0x73, 0xbf, 0x3d, 0x2f, 0xd4, 0xae, 0x6a, 0xdb, 0xd9, 0x0f, 0xcd, 0x13, 0x30, 0x81,
+	0x2d, 0x26, 0x04, 0x17, 0x87, 0x4c, 0xca, 0xd1, 0x09, 0xb3, 0xb4, 0x26, 0x6a, 0xd5, 0x69, 0x29,
+	0x46, 0xde, 0xc0, 0x7a, 0xfe, 0xa1, 0x2d, 0xd8, 0xec, 0xf5, 0x03, 0x7b, 0x7f, 0xe0, 0xf4, 0xcc,
+	0x35, 0x6c, 0xc0, 0x46, 0x10, 0x7a, 0xbe, 0xef, 0xf4, 0x4c, 0x94, 0x6c, 0x05, 0xa1, 0x4d, 0xc3,
+	0xbe, 0xfb, 0xde, 0xd4, 0x92, 0x2d, 0x3a, 0x74, 0xdd, 0x64, 0xa1, 0x93, 0xdf, 0x99, 0xe7, 0x2e,
+	0x8f, 0x95, 0xe0, 0x67, 0xff, 0xe4, 0xb9, 0xa4, 0x5d, 0xc1, 0x33, 0xbe, 0x0b, 0xa0, 0xb8, 0x38,
+	0x62, 0x42, 0x4e, 0x78, 0x6c, 0x41, 0xaa, 0x28, 0x44, 0xc8, 0xdb, 0x0b, 0xa6, 0x02, 0xc5, 0x1a,
+	0xae, 0x43, 0xcd, 0xa1, 0xd4, 0xa3, 0x26, 0xc2, 0xdb, 0x00, 0x5d, 0xcf, 0x75, 0x9d, 0x6e, 0x8e,
+	0xd4, 0x80, 0x7a, 0xbe, 0x76, 0x7a, 0xa6, 0x4e, 0xfe, 0x20, 0xd8, 0xcd, 0x8c, 0xc6, 0x2c, 0x52,
+	0x13, 0x1e, 0xe7, 0xe5, 0x5e, 0x55, 0xb8, 0x1e, 0x56, 0xb9, 0x4a, 0xf2, 0x2a, 0xda, 0x23, 0xd8,
+	0x39, 0xe6, 0x5c, 0x49, 0x25, 0x46, 0x33, 0x5f, 0xf0, 0x13, 0xc1, 0xa4, 0xcc, 0xdd, 0x5f, 0xde,
+	0x48, 0x0e, 0x42, 0xf2, 0x68, 0x2a, 0xed, 0xf1, 0x38, 0x15, 0x1a, 0x4d, 0x3d, 0x39, 0x88, 0x62,
+	0x8c, 0xbc, 0x2b, 0x82, 0x0e, 0xdd, 0x8f, 0xae, 0xf7, 0xc9, 0xcd, 0xee, 0xce, 0x3b, 0x38, 0x18,
+	0xf4, 0x5d, 0xc7, 0x44, 0x78, 0x07, 0x1a, 0xfb, 0x9e, 0x17, 0x06, 0x21, 0xb5, 0x7d, 0x3f, 0xa3,
+	0xad, 0x43, 0x8d, 0x3a, 0x76, 0xef, 0xb3, 0xa9, 0x93, 0x1f, 0x08, 0xb6, 0x43, 0x2e, 0x82, 0xa4,
+	0x6a, 0x5e, 0xea, 0x79, 0x05, 0xf2, 0x5e, 0x09, 0xb2, 0xa0, 0x5c, 0xa5, 0xdd, 0x9e, 0x5e, 0xed,
+	0xb8, 0x01, 0x75, 0xfb, 0xc8, 0xee, 0x0f, 0x92, 0xee, 0x33, 0x11, 0xfe, 0x1f, 0x8c, 0xa1, 0xbb,
+	0x08, 0x68, 0xe4, 0x17, 0x02, 0xec, 0xc5, 0xc9, 0xc9, 0x32, 0xf1, 0x75, 0x12, 0xb1, 0xbc, 0xc6,
+	0xcb, 0x8a, 0xd5, 0x07, 0x0b, 0xab, 0x97, 0xd5, 0xab, 0x74, 0x5a, 0x13, 0x8c, 0xb3, 0x91, 0x54,
+	0xdd, 0x53, 0x16, 0x4d, 0xd9, 0xd8, 0xd2, 0x9b, 0xa8, 0xa5, 0xd3, 0x62, 0x68, 0x09, 0x10, 0x75,
+	0xec, 0xee, 0x87, 0x22, 0xd0, 0x22, 0xa0, 0x91, 0xef, 0x1a, 0x34, 0xf2, 0xe9, 0xcf, 0xd3, 0x9f,
+	0xc0, 0xc6, 0x2c, 0x1b, 0xe6, 0x14, 0xc6, 0xe8, 0xdc, 0xba, 0x7e, 0xd0, 0xe9, 0x5c, 0x9a, 0x64,
+	0x45, 0xd9, 0x38, 0x59, 0xda, 0x15, 0x59, 0xa5, 0x51, 0xa3, 0x73, 0x29, 0x7e, 0x0d, 0x10, 0x5d,
+	0x34, 0x6b, 0x4a, 0x65, 0x74, 0xee, 0x2c, 0xed, 0x65, 0x5a, 0x48, 0xc0, 0x6d, 0xa8, 0xa5, 0x6d,
+	0x68, 0xfd, 0x97, 0x66, 0x5a, 0xd7, 0x35, 0x08, 0xcd, 0x64, 0xf8, 0x19, 0x6c, 0xc8, 0xec, 0x26,
+	0xac, 0x5a, 0x9a, 0x71, 0x7b, 0xd9, 0x3d, 0xd1, 0xb9, 0x98, 0xec, 0xc1, 0x6e, 0xa0, 0x46, 0x42,
+	0x55, 0x9e, 0xc9, 0x1b, 0x80, 0x03, 0xc5, 0x67, 0xe5, 0xe8, 0xf1, 0x7a, 0xfa, 0xcc, 0x3e, 0xfe,
+	0x3b, 0x00, 0x78, 0x7d, 0x4c, 0x2b, 0x77, 0x05, 0x00, 0x00,
 }